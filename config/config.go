@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 
+	"pixly/pkg/pathtemplate"
+	"pixly/pkg/scheduler"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -25,6 +27,15 @@ type Config struct {
 	// 输出设置
 	Output OutputConfig `mapstructure:"output"`
 
+	// 撤销设置
+	Undo UndoConfig `mapstructure:"undo"`
+
+	// 会话状态设置
+	State StateConfig `mapstructure:"state"`
+
+	// 监视目录设置
+	Watch WatchConfig `mapstructure:"watch"`
+
 	// 外部工具路径
 	Tools ToolsConfig `mapstructure:"tools"`
 
@@ -586,6 +597,154 @@ type ConversionConfig struct {
 
 	// 视频文件扩展名
 	VideoExtensions []string `mapstructure:"video_extensions"`
+
+	// 感知质量门限
+	Perceptual PerceptualConfig `mapstructure:"perceptual"`
+
+	// 有损参数搜索
+	QualitySearch QualitySearchConfig `mapstructure:"quality_search"`
+
+	// 视频处理
+	Video VideoConfig `mapstructure:"video"`
+
+	// 带增益图的HDR照片（Ultra HDR、Apple HDR）处理方式
+	// (preserve: 工具能保留增益图时转换，否则跳过; skip: 始终跳过; ignore: 按普通照片转换，丢弃增益图)
+	GainMapPolicy string `mapstructure:"gain_map_policy"`
+
+	// 连拍照片处理
+	Burst BurstConfig `mapstructure:"burst"`
+
+	// 全景照片处理
+	Panorama PanoramaConfig `mapstructure:"panorama"`
+
+	// 相机RAW文件处理
+	Raw RawConfig `mapstructure:"raw"`
+
+	// 转换失败后的重试与后备转换
+	Recovery RecoveryConfig `mapstructure:"recovery"`
+}
+
+// BurstConfig 连拍照片处理配置：按Apple的BurstUUID或文件名中的BURST序号（Pixel等）分组
+type BurstConfig struct {
+	// 处理方式 (keep_all: 每帧按普通照片转换; keep_picked: 只转换选中帧，其余帧保持原样;
+	// animate: 整组合成为一个动图，各帧保持原样)；文件名不含BURST序号时用一次exiftool调用批量读取BurstUUID，keep_all不分组。
+	// 选中帧按用户的标记：Pixel的COVER帧、BurstPrimary，其次评级最高的帧，都没有时为文件名最前的帧
+	Policy string `mapstructure:"policy"`
+
+	// 合成动图的格式 (avif, jxl)
+	AnimatedFormat string `mapstructure:"animated_format"`
+
+	// 合成动图的帧率
+	FrameRate float64 `mapstructure:"frame_rate"`
+}
+
+// PanoramaConfig 全景照片处理配置：更慢更充分的编码，超大图像分块编码避免avifenc内存不足
+type PanoramaConfig struct {
+	// 长边与短边之比超过该值视为全景照片
+	MinAspectRatio float64 `mapstructure:"min_aspect_ratio"`
+
+	// AVIF编码速度 (0-10，越小越慢、压缩越充分；普通照片为4)
+	AVIFSpeed int `mapstructure:"avif_speed"`
+
+	// JXL编码努力程度 (1-10，10需要cjxl的专家选项；普通照片为9)
+	JXLEffort int `mapstructure:"jxl_effort"`
+
+	// 超过该像素数（百万像素）时AVIF按网格分块编码
+	TileMegapixels int `mapstructure:"tile_megapixels"`
+
+	// 分块编码时单块的最大边长（像素）
+	MaxTileSize int `mapstructure:"max_tile_size"`
+}
+
+// RawConfig 相机RAW文件（DNG、CR2、NEF、ARW）处理配置
+type RawConfig struct {
+	// 处理方式 (keep: 保持原样; preview: 将嵌入的全尺寸JPEG预览无损转换为同名JXL旁车文件，RAW保持原样;
+	// dng_jxl: 用DNG转换工具将DNG无损转换为JPEG XL压缩的DNG，其他RAW保持原样)
+	Policy string `mapstructure:"policy"`
+
+	// DNG转换工具参数，输出目录、文件名与输入文件由程序追加；默认参数适用于Adobe DNG Converter 16及以上
+	DNGConverterArgs []string `mapstructure:"dng_converter_args"`
+}
+
+// RecoveryConfig 转换失败后的恢复配置：先重新执行失败的转换，再依次尝试降低品质、换用另一种目标格式、
+// 简单转换与复制原文件；品质模式与无损路由的后备只输出无损结果
+type RecoveryConfig struct {
+	// 启用错误恢复
+	Enabled bool `mapstructure:"enabled"`
+
+	// 失败转换的最大重试次数（0为不重试）
+	MaxRetries int `mapstructure:"max_retries"`
+
+	// 重试仍失败时启用后备转换
+	Fallback bool `mapstructure:"fallback"`
+
+	// 降低品质后备相对配置品质下调的幅度
+	LowerQualityStep int `mapstructure:"lower_quality_step"`
+}
+
+// VideoConfig 视频处理配置：重包装为MOV，或按采样片段的感知评分选择CRF重新编码
+type VideoConfig struct {
+	// 处理方式 (remux: 重包装为MOV, transcode: 重新编码)，transcode仅用于auto+模式，品质模式始终无损重包装
+	Mode string `mapstructure:"mode"`
+
+	// 目标编码 (av1, hevc)
+	Codec string `mapstructure:"codec"`
+
+	// AV1编码器 (libsvtav1, libaom-av1)，为空时按此顺序自动选择
+	AV1Encoder string `mapstructure:"av1_encoder"`
+
+	// 输出容器 (mkv, mp4, mov)，AV1不支持MOV，自动使用MP4
+	Container string `mapstructure:"container"`
+
+	// 编码预设，为空时使用编码器默认值（libsvtav1为0-13，libaom-av1为cpu-used 0-8，libx265为ultrafast-placebo）
+	Preset string `mapstructure:"preset"`
+
+	// VMAF目标 (1-100)，ffmpeg带libvmaf时使用
+	TargetVMAF float64 `mapstructure:"target_vmaf"`
+
+	// SSIM目标 (0-1)，ffmpeg不带libvmaf时使用
+	TargetSSIM float64 `mapstructure:"target_ssim"`
+
+	// CRF搜索区间，0表示使用编码器默认区间
+	MinCRF int `mapstructure:"min_crf"`
+	MaxCRF int `mapstructure:"max_crf"`
+
+	// 每个文件的采样片段数与片段时长（秒）
+	SampleCount   int     `mapstructure:"sample_count"`
+	SampleSeconds float64 `mapstructure:"sample_seconds"`
+
+	// 最小体积减小百分比 (0-95)，未达到时保留原文件
+	MinReduction float64 `mapstructure:"min_reduction"`
+}
+
+// QualitySearchConfig 有损参数二分搜索配置，取代固定质量阶梯
+type QualitySearchConfig struct {
+	// 搜索目标 (perceptual: 达到感知门限的最低质量, size: 达到体积减小比例的最高质量)
+	Target string `mapstructure:"target"`
+
+	// 体积减小目标百分比 (1-95)，target为size时使用
+	TargetReduction float64 `mapstructure:"target_reduction"`
+
+	// 单个文件的最大编码尝试次数
+	MaxSteps int `mapstructure:"max_steps"`
+}
+
+// PerceptualConfig 感知质量门限配置：有损候选必须达到下限才会被采用
+type PerceptualConfig struct {
+	// 是否启用感知质量门限
+	Enabled bool `mapstructure:"enabled"`
+
+	// 评分指标 (ssim, ms-ssim, ssimulacra2, butteraugli)
+	Metric string `mapstructure:"metric"`
+
+	// SSIM/MS-SSIM下限 (0-1)
+	MinSSIM float64 `mapstructure:"min_ssim"`
+
+	// SSIMULACRA2下限 (-inf-100)
+	MinSSIMULACRA2 float64 `mapstructure:"min_ssimulacra2"`
+
+	// butteraugli距离上限
+	MaxButteraugli float64 `mapstructure:"max_butteraugli"`
 }
 
 // QualityConfig 质量配置
@@ -670,9 +829,13 @@ type ConcurrencyConfig struct {
 	// 转换并发数
 	ConversionWorkers int `mapstructure:"conversion_workers"`
 
-	// 内存限制 (MB)
+	// 内存限制 (MB)：编码任务按估算峰值内存在此预算内准入，与cgroup及主机内存限制取较小者，<=0时只按后两者
 	MemoryLimit int `mapstructure:"memory_limit"`
 
+	// 编码线程分配策略：auto每个任务至少分到可用CPU在并发任务间均分的份额，大图再按队列中的工作量占比多分，
+	// single每个编码器单线程，even可用CPU在并发任务间均分，off不限制编码器线程数
+	ThreadPlan string `mapstructure:"thread_plan"`
+
 	// 自动调整并发数
 	AutoAdjust bool `mapstructure:"auto_adjust"`
 }
@@ -682,16 +845,48 @@ type OutputConfig struct {
 	// 保留原文件
 	KeepOriginal bool `mapstructure:"keep_original"`
 
-	// 输出目录模板
+	// 输出目录模板：为空时原地转换；不含变量时视为输出根目录并保持原目录结构；
+	// 可使用 {relpath} {date} {date:2006} {camera} {mode} {quality} {filename} {ext} 等变量，
+	// {date:布局} 的布局不能包含路径分隔符（多级日期目录写作 {date:2006}/{date:01}）
 	DirectoryTemplate string `mapstructure:"directory_template"`
 
-	// 文件名模板
+	// 文件名模板（不含扩展名），为空时等同于 {filename}
 	FilenameTemplate string `mapstructure:"filename_template"`
 
+	// 输出路径冲突策略 (suffix: 追加 _1/_2 后缀, overwrite: 覆盖)
+	CollisionPolicy string `mapstructure:"collision_policy"`
+
 	// 生成报告
 	GenerateReport bool `mapstructure:"generate_report"`
 }
 
+// UndoConfig 撤销日志配置（仅原地转换）
+type UndoConfig struct {
+	// 是否记录撤销日志并保留被替换的原件
+	Enabled bool `mapstructure:"enabled"`
+
+	// 原件保留天数，过期后原件与日志一并清理
+	RetentionDays int `mapstructure:"retention_days"`
+}
+
+// StateConfig 会话状态配置
+type StateConfig struct {
+	// 状态目录，保存检查点数据库；为空时使用用户状态目录（$XDG_STATE_HOME/pixly 或 ~/.local/state/pixly）
+	Dir string `mapstructure:"dir"`
+
+	// 未完成会话的保留天数，sessions prune 默认清理更早的会话
+	SessionRetentionDays int `mapstructure:"session_retention_days"`
+}
+
+// WatchConfig 监视目录配置（pixly watch）
+type WatchConfig struct {
+	// 文件最后一次写入后等待的秒数，大小与修改时间稳定后才开始转换
+	SettleSeconds int `mapstructure:"settle_seconds"`
+
+	// 启动时是否处理目录中已有的文件
+	InitialScan bool `mapstructure:"initial_scan"`
+}
+
 // ToolsConfig 外部工具配置
 type ToolsConfig struct {
 	// FFmpeg路径
@@ -708,6 +903,15 @@ type ToolsConfig struct {
 
 	// exiftool路径
 	ExiftoolPath string `mapstructure:"exiftool_path"`
+
+	// ssimulacra2路径（可选）
+	Ssimulacra2Path string `mapstructure:"ssimulacra2_path"`
+
+	// butteraugli路径（可选）
+	ButteraugliPath string `mapstructure:"butteraugli_path"`
+
+	// DNG转换工具路径（可选，如Adobe DNG Converter），用于生成JPEG XL压缩的DNG
+	DNGConverterPath string `mapstructure:"dng_converter_path"`
 }
 
 // SecurityConfig 安全配置
@@ -868,8 +1072,55 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("conversion.quality_thresholds.video.medium_quality", 10.0)
 	v.SetDefault("conversion.quality_thresholds.video.low_quality", 1.0)
 
+	// 感知质量门限默认值
+	v.SetDefault("conversion.perceptual.enabled", true)
+	v.SetDefault("conversion.perceptual.metric", "ms-ssim")
+	v.SetDefault("conversion.perceptual.min_ssim", 0.95)
+	v.SetDefault("conversion.perceptual.min_ssimulacra2", 70.0)
+	v.SetDefault("conversion.perceptual.max_butteraugli", 1.5)
+
+	// 有损参数搜索默认值
+	v.SetDefault("conversion.quality_search.target", "perceptual")
+	v.SetDefault("conversion.quality_search.target_reduction", 10.0)
+	v.SetDefault("conversion.quality_search.max_steps", 6)
+
+	// 增益图照片默认值
+	v.SetDefault("conversion.gain_map_policy", "preserve")
+
+	// 连拍与全景照片默认值
+	v.SetDefault("conversion.burst.policy", "keep_all")
+	v.SetDefault("conversion.burst.animated_format", "avif")
+	v.SetDefault("conversion.burst.frame_rate", 10.0)
+	v.SetDefault("conversion.panorama.min_aspect_ratio", 2.0)
+	v.SetDefault("conversion.panorama.avif_speed", 3)
+	v.SetDefault("conversion.panorama.jxl_effort", 9)
+	v.SetDefault("conversion.panorama.tile_megapixels", 64)
+	v.SetDefault("conversion.panorama.max_tile_size", 8192)
+
+	// 相机RAW文件默认值
+	v.SetDefault("conversion.raw.policy", "keep")
+	v.SetDefault("conversion.raw.dng_converter_args", []string{"-dng1.7.1", "-jxl"})
+	v.SetDefault("conversion.recovery.enabled", true)
+	v.SetDefault("conversion.recovery.max_retries", 2)
+	v.SetDefault("conversion.recovery.fallback", true)
+	v.SetDefault("conversion.recovery.lower_quality_step", 15)
+
+	// 视频处理默认值
+	v.SetDefault("conversion.video.mode", "remux")
+	v.SetDefault("conversion.video.codec", "av1")
+	v.SetDefault("conversion.video.av1_encoder", "")
+	v.SetDefault("conversion.video.container", "mkv")
+	v.SetDefault("conversion.video.preset", "")
+	v.SetDefault("conversion.video.target_vmaf", 95.0)
+	v.SetDefault("conversion.video.target_ssim", 0.98)
+	v.SetDefault("conversion.video.min_crf", 0)
+	v.SetDefault("conversion.video.max_crf", 0)
+	v.SetDefault("conversion.video.sample_count", 3)
+	v.SetDefault("conversion.video.sample_seconds", 4.0)
+	v.SetDefault("conversion.video.min_reduction", 5.0)
+
 	// 并发设置默认值 - 优化为保守配置避免系统卡顿
-	maxWorkers := runtime.NumCPU()
+	maxWorkers := scheduler.AvailableCPUs()
 	if maxWorkers > 4 {
 		maxWorkers = 4 // 限制最大worker数量，避免过度并发
 	}
@@ -877,19 +1128,36 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("concurrency.conversion_workers", maxWorkers)
 	v.SetDefault("concurrency.memory_limit", 4096) // 4GB，降低内存使用
 	v.SetDefault("concurrency.auto_adjust", true)
+	v.SetDefault("concurrency.thread_plan", scheduler.ThreadPlanAuto)
 
 	// 输出设置默认值
 	v.SetDefault("output.keep_original", false)
 	v.SetDefault("output.directory_template", "")
 	v.SetDefault("output.filename_template", "")
+	v.SetDefault("output.collision_policy", "suffix")
 	v.SetDefault("output.generate_report", true)
 
+	// 撤销日志默认值
+	v.SetDefault("undo.enabled", true)
+	v.SetDefault("undo.retention_days", 7)
+
+	// 会话状态默认值
+	v.SetDefault("state.dir", "")
+	v.SetDefault("state.session_retention_days", 30)
+
+	// 监视目录默认值
+	v.SetDefault("watch.settle_seconds", 5)
+	v.SetDefault("watch.initial_scan", true)
+
 	// 外部工具默认路径
 	v.SetDefault("tools.ffmpeg_path", "ffmpeg")
 	v.SetDefault("tools.ffprobe_path", "ffprobe")
 	v.SetDefault("tools.cjxl_path", "cjxl")
 	v.SetDefault("tools.avifenc_path", "avifenc")
 	v.SetDefault("tools.exiftool_path", "exiftool")
+	v.SetDefault("tools.ssimulacra2_path", "ssimulacra2")
+	v.SetDefault("tools.butteraugli_path", "butteraugli")
+	v.SetDefault("tools.dng_converter_path", "")
 
 	// 安全设置默认值
 	v.SetDefault("security.forbidden_directories", []string{
@@ -919,11 +1187,15 @@ func setDefaults(v *viper.Viper) {
 func validateConfig(config *Config) error {
 	// 验证并发数设置
 	if config.Concurrency.ScanWorkers <= 0 {
-		config.Concurrency.ScanWorkers = runtime.NumCPU() * 2
+		config.Concurrency.ScanWorkers = scheduler.AvailableCPUs() * 2
 	}
 
 	if config.Concurrency.ConversionWorkers <= 0 {
-		config.Concurrency.ConversionWorkers = runtime.NumCPU()
+		config.Concurrency.ConversionWorkers = scheduler.AvailableCPUs()
+	}
+
+	if !scheduler.IsThreadPlan(config.Concurrency.ThreadPlan) {
+		config.Concurrency.ThreadPlan = scheduler.ThreadPlanAuto
 	}
 
 	// 验证质量设置
@@ -944,15 +1216,220 @@ func validateConfig(config *Config) error {
 		quality.VideoCRF = 23
 	}
 
+	// 验证感知质量门限
+	validatePerceptualConfig(&config.Conversion.Perceptual)
+
+	// 验证有损参数搜索
+	validateQualitySearchConfig(&config.Conversion.QualitySearch)
+
+	// 验证视频处理
+	validateVideoConfig(&config.Conversion.Video)
+
+	// 验证增益图照片处理方式
+	switch config.Conversion.GainMapPolicy {
+	case "preserve", "skip", "ignore":
+	default:
+		config.Conversion.GainMapPolicy = "preserve"
+	}
+
+	// 验证连拍与全景照片处理
+	validateBurstConfig(&config.Conversion.Burst)
+	validatePanoramaConfig(&config.Conversion.Panorama)
+
+	// 验证相机RAW文件处理
+	validateRawConfig(&config.Conversion.Raw)
+
+	// 验证错误恢复
+	validateRecoveryConfig(&config.Conversion.Recovery)
+
+	// 验证输出模板
+	if err := validateOutputConfig(&config.Output); err != nil {
+		return err
+	}
+
 	// 验证问题文件处理策略
 	validateProblemFileHandlingConfig(&config.ProblemFileHandling)
 
+	// 验证撤销日志保留期
+	if config.Undo.RetentionDays <= 0 {
+		config.Undo.RetentionDays = 7
+	}
+
+	// 验证会话保留期
+	if config.State.SessionRetentionDays <= 0 {
+		config.State.SessionRetentionDays = 30
+	}
+
+	// 验证监视稳定时间
+	if config.Watch.SettleSeconds <= 0 {
+		config.Watch.SettleSeconds = 5
+	}
+
 	// 验证工具路径
 	validateToolPaths(config)
 
 	return nil
 }
 
+// validatePerceptualConfig 验证感知质量门限配置
+func validatePerceptualConfig(config *PerceptualConfig) {
+	validMetrics := map[string]bool{
+		"ssim":        true,
+		"ms-ssim":     true,
+		"ssimulacra2": true,
+		"butteraugli": true,
+	}
+	if !validMetrics[config.Metric] {
+		config.Metric = "ms-ssim"
+	}
+	if config.MinSSIM <= 0 || config.MinSSIM > 1 {
+		config.MinSSIM = 0.95
+	}
+	if config.MinSSIMULACRA2 > 100 {
+		config.MinSSIMULACRA2 = 70
+	}
+	if config.MaxButteraugli <= 0 {
+		config.MaxButteraugli = 1.5
+	}
+}
+
+// validateOutputConfig 验证输出模板与冲突策略
+func validateOutputConfig(config *OutputConfig) error {
+	if err := pathtemplate.Validate(config.DirectoryTemplate); err != nil {
+		return &ValidationError{
+			Field:   "output.directory_template",
+			Value:   config.DirectoryTemplate,
+			Message: err.Error(),
+		}
+	}
+
+	if err := pathtemplate.ValidateFilename(config.FilenameTemplate); err != nil {
+		return &ValidationError{
+			Field:   "output.filename_template",
+			Value:   config.FilenameTemplate,
+			Message: err.Error(),
+		}
+	}
+
+	if config.CollisionPolicy != "suffix" && config.CollisionPolicy != "overwrite" {
+		config.CollisionPolicy = "suffix"
+	}
+	return nil
+}
+
+// validateQualitySearchConfig 验证有损参数搜索配置
+func validateQualitySearchConfig(config *QualitySearchConfig) {
+	if config.Target != "perceptual" && config.Target != "size" {
+		config.Target = "perceptual"
+	}
+	if config.TargetReduction < 1 || config.TargetReduction > 95 {
+		config.TargetReduction = 10
+	}
+	if config.MaxSteps <= 0 || config.MaxSteps > 10 {
+		config.MaxSteps = 6
+	}
+}
+
+// validateVideoConfig 验证视频处理配置
+func validateVideoConfig(config *VideoConfig) {
+	if config.Mode != "remux" && config.Mode != "transcode" {
+		config.Mode = "remux"
+	}
+	if config.Codec != "av1" && config.Codec != "hevc" {
+		config.Codec = "av1"
+	}
+	if config.AV1Encoder != "" && config.AV1Encoder != "libsvtav1" && config.AV1Encoder != "libaom-av1" {
+		config.AV1Encoder = ""
+	}
+	if config.Container != "mkv" && config.Container != "mp4" && config.Container != "mov" {
+		config.Container = "mkv"
+	}
+	if config.TargetVMAF <= 0 || config.TargetVMAF > 100 {
+		config.TargetVMAF = 95
+	}
+	if config.TargetSSIM <= 0 || config.TargetSSIM > 1 {
+		config.TargetSSIM = 0.98
+	}
+	if config.MinCRF < 0 || config.MinCRF > 63 {
+		config.MinCRF = 0
+	}
+	if config.MaxCRF < 0 || config.MaxCRF > 63 {
+		config.MaxCRF = 0
+	}
+	if config.MinCRF > 0 && config.MaxCRF > 0 && config.MinCRF > config.MaxCRF {
+		config.MinCRF, config.MaxCRF = 0, 0
+	}
+	if config.SampleCount <= 0 || config.SampleCount > 10 {
+		config.SampleCount = 3
+	}
+	if config.SampleSeconds <= 0 || config.SampleSeconds > 30 {
+		config.SampleSeconds = 4
+	}
+	if config.MinReduction < 0 || config.MinReduction > 95 {
+		config.MinReduction = 5
+	}
+}
+
+// validateBurstConfig 验证连拍照片处理配置
+func validateBurstConfig(config *BurstConfig) {
+	switch config.Policy {
+	case "keep_all", "keep_picked", "animate":
+	default:
+		config.Policy = "keep_all"
+	}
+	if config.AnimatedFormat != "avif" && config.AnimatedFormat != "jxl" {
+		config.AnimatedFormat = "avif"
+	}
+	if config.FrameRate <= 0 || config.FrameRate > 60 {
+		config.FrameRate = 10
+	}
+}
+
+// validatePanoramaConfig 验证全景照片处理配置
+func validatePanoramaConfig(config *PanoramaConfig) {
+	if config.MinAspectRatio <= 1 {
+		config.MinAspectRatio = 2.0
+	}
+	if config.AVIFSpeed < 0 || config.AVIFSpeed > 10 {
+		config.AVIFSpeed = 3
+	}
+	if config.JXLEffort < 1 || config.JXLEffort > 10 {
+		config.JXLEffort = 9
+	}
+	if config.TileMegapixels <= 0 {
+		config.TileMegapixels = 64
+	}
+	// AVIF网格单块至少64像素
+	if config.MaxTileSize < 64 {
+		config.MaxTileSize = 8192
+	}
+}
+
+// validateRawConfig 验证相机RAW文件处理配置
+func validateRawConfig(config *RawConfig) {
+	switch config.Policy {
+	case "keep", "preview", "dng_jxl":
+	default:
+		config.Policy = "keep"
+	}
+	if len(config.DNGConverterArgs) == 0 {
+		config.DNGConverterArgs = []string{"-dng1.7.1", "-jxl"}
+	}
+}
+
+// validateRecoveryConfig 验证错误恢复配置
+func validateRecoveryConfig(config *RecoveryConfig) {
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.MaxRetries > 5 {
+		config.MaxRetries = 5
+	}
+	if config.LowerQualityStep <= 0 || config.LowerQualityStep >= 100 {
+		config.LowerQualityStep = 15
+	}
+}
+
 // validateProblemFileHandlingConfig 验证问题文件处理配置
 func validateProblemFileHandlingConfig(config *ProblemFileHandlingConfig) {
 	// 验证损坏文件处理策略
@@ -1012,6 +1489,8 @@ func validateToolPaths(config *Config) {
 	checkAndUpdatePath(&tools.CjxlPath, "cjxl")
 	checkAndUpdatePath(&tools.AvifencPath, "avifenc")
 	checkAndUpdatePath(&tools.ExiftoolPath, "exiftool")
+	checkAndUpdatePath(&tools.Ssimulacra2Path, "ssimulacra2")
+	checkAndUpdatePath(&tools.ButteraugliPath, "butteraugli")
 }
 
 // findInPath 在PATH中查找可执行文件
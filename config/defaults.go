@@ -56,6 +56,9 @@ func setToolsDefaults(v *viper.Viper) {
 	v.SetDefault("tools.cjxl_path", "cjxl")
 	v.SetDefault("tools.avifenc_path", "avifenc")
 	v.SetDefault("tools.exiftool_path", "exiftool")
+	v.SetDefault("tools.ssimulacra2_path", "ssimulacra2")
+	v.SetDefault("tools.butteraugli_path", "butteraugli")
+	v.SetDefault("tools.dng_converter_path", "")
 }
 
 // setUIDefaults 设置UI显示的默认值
@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"pixly/pkg/scheduler"
+
 	"github.com/panjf2000/ants/v2"
 	"go.uber.org/zap"
 )
@@ -67,13 +68,13 @@ type AdvancedPoolConfig struct {
 // GetDefaultAdvancedPoolConfig 获取默认高级池配置
 func GetDefaultAdvancedPoolConfig() *AdvancedPoolConfig {
 	// 计算合适的队列大小，确保能处理大量文件
-	maxSize := runtime.NumCPU() * 16 // 增加到16倍CPU核心数
+	maxSize := scheduler.AvailableCPUs() * 16 // 增加到16倍CPU核心数
 	if maxSize < 128 {
 		maxSize = 128 // 最小保证128个队列槽位
 	}
 
 	return &AdvancedPoolConfig{
-		InitialSize:             runtime.NumCPU(),
+		InitialSize:             scheduler.AvailableCPUs(),
 		MinSize:                 2,
 		MaxSize:                 maxSize,
 		ScaleUpThreshold:        0.8,                 // 当队列长度达到池大小的80%时扩容
@@ -117,6 +118,9 @@ type AdvancedPool struct {
 
 	// 任务监控
 	taskMonitor *TaskMonitor
+
+	// 编码任务的内存调度：有任务等待内存准入时不扩容
+	memoryScheduler *scheduler.MemoryScheduler
 }
 
 // NewAdvancedPool 创建新的高级池
@@ -128,14 +132,14 @@ func NewAdvancedPool(config *AdvancedPoolConfig, logger *zap.Logger) (*AdvancedP
 	// 验证配置参数
 	if config.InitialSize <= 0 {
 		logger.Warn("InitialSize无效，使用默认值", zap.Int("provided", config.InitialSize))
-		config.InitialSize = runtime.NumCPU()
+		config.InitialSize = scheduler.AvailableCPUs()
 		if config.InitialSize <= 0 {
 			config.InitialSize = 1
 		}
 	}
 	if config.MaxSize <= 0 {
 		logger.Warn("MaxSize无效，使用默认值", zap.Int("provided", config.MaxSize))
-		config.MaxSize = runtime.NumCPU() * 16
+		config.MaxSize = scheduler.AvailableCPUs() * 16
 		if config.MaxSize < 128 {
 			config.MaxSize = 128
 		}
@@ -541,6 +545,21 @@ func (ap *AdvancedPool) logMetrics() {
 	}
 }
 
+// SetMemoryScheduler 设置编码任务的内存调度器，自动扩容以其准入情况为准
+func (ap *AdvancedPool) SetMemoryScheduler(memoryScheduler *scheduler.MemoryScheduler) {
+	ap.mutex.Lock()
+	ap.memoryScheduler = memoryScheduler
+	ap.mutex.Unlock()
+}
+
+// memoryBound 是否有任务在等待内存准入：此时增加工作协程只会增加等待者
+func (ap *AdvancedPool) memoryBound() bool {
+	ap.mutex.RLock()
+	memoryScheduler := ap.memoryScheduler
+	ap.mutex.RUnlock()
+	return memoryScheduler.Stats().Waiting > 0
+}
+
 // autoScale 自动调整池大小
 func (ap *AdvancedPool) autoScale() {
 	for {
@@ -559,8 +578,8 @@ func (ap *AdvancedPool) performAutoScale() {
 	queuedTasks := atomic.LoadInt32(&ap.metrics.QueuedTasks)
 	queueRatio := float64(queuedTasks) / float64(currentSize)
 
-	// 扩容逻辑
-	if queueRatio > ap.config.ScaleUpThreshold && currentSize < ap.config.MaxSize {
+	// 扩容逻辑：并发受内存预算限制时由内存调度决定实际并发，不扩容
+	if queueRatio > ap.config.ScaleUpThreshold && currentSize < ap.config.MaxSize && !ap.memoryBound() {
 		newSize := currentSize + (currentSize / 4) // 增加25%
 		if newSize > ap.config.MaxSize {
 			newSize = ap.config.MaxSize
@@ -4,11 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +17,8 @@ import (
 	"pixly/core/input"
 	"pixly/core/output"
 	"pixly/internal/ui"
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/whitelist"
 
 	"go.uber.org/zap"
 )
@@ -186,6 +187,15 @@ func (bp *BatchProcessor) ScanAndAnalyze(inputDir string) error {
 		return bp.converter.errorHandler.WrapError("快速扫描失败", err)
 	}
 
+	// 内容缓存：未变更且已使用相同设置处理过的文件直接跳过，不再探测
+	files = bp.skipCachedFiles(files)
+
+	// Live Photo配对：配对的MOV随静态图一同转换，不单独排队；已跳过的目标格式MOV同样参与配对
+	files = bp.converter.pairLivePhotos(files, skippedFiles)
+
+	// 连拍分组：按连拍策略只保留选中帧，或由一帧代表整组合成动图
+	files = bp.converter.groupBursts(files)
+
 	// 阶段二：FFmpeg 深度验证（5%）
 	// 仅对阶段一无法确定的文件调用 ffprobe 进行深度分析
 	uncertainFiles := bp.identifyUncertainFiles(files, mediaInfoMap)
@@ -248,6 +258,7 @@ func (bp *BatchProcessor) ScanAndAnalyze(inputDir string) error {
 				CompressionRatio: 0,
 				Success:          true,
 				Skipped:          true,
+				SkipCategory:     whitelist.SkipTargetFormat,
 				SkipReason:       "already target format",
 				Method:           "skip",
 				Duration:         0,
@@ -278,6 +289,40 @@ func (bp *BatchProcessor) ScanAndAnalyze(inputDir string) error {
 	return nil
 }
 
+// skipCachedFiles 过滤内容缓存命中的文件，并记录为跳过结果
+func (bp *BatchProcessor) skipCachedFiles(files []*MediaFile) []*MediaFile {
+	if bp.converter.resultCache == nil {
+		return files
+	}
+
+	remaining := files[:0]
+	cachedCount := 0
+	for _, file := range files {
+		result := &ConversionResult{}
+		if !bp.converter.applyCachedOutcome(file, result) {
+			remaining = append(remaining, file)
+			continue
+		}
+
+		bp.mutex.Lock()
+		bp.results = append(bp.results, result)
+		bp.mutex.Unlock()
+		bp.converter.mutex.Lock()
+		bp.converter.results = append(bp.converter.results, result)
+		bp.converter.mutex.Unlock()
+		bp.converter.UpdateStats(result)
+		bp.converter.emitResult(result)
+		cachedCount++
+	}
+
+	if cachedCount > 0 {
+		bp.logger.Info("内容缓存命中，跳过未变更文件",
+			zap.Int("cached", cachedCount),
+			zap.Int("remaining", len(remaining)))
+	}
+	return remaining
+}
+
 // isCodecIncompatible 检查是否编解码器不兼容
 func (bp *BatchProcessor) isCodecIncompatible(mediaInfo *MediaInfo) bool {
 	// 这里可以添加具体的编解码器不兼容检查逻辑
@@ -332,6 +377,10 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 		}
 
 		if info.IsDir() {
+			// 跳过原件备份目录
+			if info.Name() == OriginalsDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -345,6 +394,13 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 			ui.UpdateDynamicProgress(int64(totalCount), fmt.Sprintf("已扫描 %d 个文件...", totalCount))
 		}
 
+		// 创作源文件与PDF不参与分析与转换，直接记录为跳过，报告中按类别列出
+		if reason := whitelist.CreativeByExtension(path); reason != nil {
+			bp.recordCreativeSkip(path, info, reason)
+			skippedCount++
+			return nil
+		}
+
 		// 检查是否为媒体文件
 		if !bp.converter.isMediaFile(path) {
 			return nil
@@ -373,7 +429,7 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 			if actualExt, corrected := bp.detectMagicNumberAndCorrectExtension(path); corrected {
 				// 检查修正后的扩展名是否为目标格式，如果是则跳过处理
 				correctedExt := "." + actualExt
-				if bp.converter.IsTargetFormat(correctedExt) {
+				if bp.converter.isTargetFile(path, correctedExt) {
 					bp.logger.Debug("修正后的扩展名为目标格式，跳过处理",
 						zap.String("file", path),
 						zap.String("original_ext", ext),
@@ -415,7 +471,7 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 		}
 
 		// 检查文件是否已经是目标格式，如果是则记录为跳过文件
-		if bp.converter.IsTargetFormat(ext) {
+		if bp.converter.isTargetFile(path, ext) {
 			// 文件已是目标格式，跳过扫描和扩展名修正
 			bp.logger.Info("跳过目标格式文件",
 				zap.String("file", path),
@@ -526,6 +582,7 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 				CompressionRatio: 0,
 				Success:          true,
 				Skipped:          true,
+				SkipCategory:     whitelist.SkipTargetFormat,
 				SkipReason:       "已经是目标格式",
 				Method:           "skip",
 				Duration:         0,
@@ -538,6 +595,7 @@ func (bp *BatchProcessor) quickScan(inputDir string) ([]*MediaFile, map[string]*
 
 			// 立即更新统计信息，确保跳过文件被正确统计
 			bp.converter.UpdateStats(result)
+			bp.converter.emitResult(result)
 		}
 		bp.mutex.Unlock()
 
@@ -627,6 +685,9 @@ var (
 		".jp2": true, ".jpx": true, ".j2k": true, ".j2c": true, ".jpc": true,
 		".apng": true,
 
+		// 图片格式 - 相机RAW
+		".dng": true, ".cr2": true, ".nef": true, ".arw": true,
+
 		// 视频格式 - 主流格式（仅包含真正的视频媒体文件）
 		".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".webm": true,
 		".flv": true, ".wmv": true, ".asf": true, ".m4v": true, ".3gp": true,
@@ -812,8 +873,8 @@ func (bp *BatchProcessor) deepAnalysis(filesToAnalyze []*MediaFile, mediaInfoMap
 					done <- true
 				}()
 
-				// 调用 ffprobe 获取详细媒体信息
-				mediaInfo, err := bp.getMediaInfo(fileCopy.Path)
+				// 读取共享探测结果获取详细媒体信息
+				mediaInfo, err := bp.getMediaInfo(ctx, fileCopy.Path)
 				if err != nil {
 					bp.logger.Warn("获取媒体信息失败", zap.String("file", fileCopy.Path), zap.Error(err))
 					// 标记文件为损坏
@@ -879,56 +940,26 @@ func (bp *BatchProcessor) deepAnalysis(filesToAnalyze []*MediaFile, mediaInfoMap
 	return nil
 }
 
-// getMediaInfo 使用 ffprobe 获取媒体文件信息
-func (bp *BatchProcessor) getMediaInfo(filePath string) (*MediaInfo, error) {
+// getMediaInfo 从共享探测结果获取媒体文件信息：可解析头部的图像不启动ffprobe
+func (bp *BatchProcessor) getMediaInfo(ctx context.Context, filePath string) (*MediaInfo, error) {
 	// 首先尝试从内存池获取媒体信息
 	if cachedInfo := bp.memoryPool.GetMediaInfo(); cachedInfo != nil && cachedInfo.FullPath == filePath {
 		return cachedInfo, nil
 	}
 
-	// 使用FFprobe获取媒体信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
-	}
-
-	cmd := exec.Command(bp.converter.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
+	probe := bp.converter.prober.Get(filePath)
+	media, err := probe.Media(ctx)
 	if err != nil {
 		return nil, bp.converter.errorHandler.WrapError("ffprobe failed", err)
 	}
 
-	// 解析FFprobe输出
-	var probeData struct {
-		Format struct {
-			FormatName string `json:"format_name"`
-			Duration   string `json:"duration"`
-			BitRate    string `json:"bit_rate"`
-		} `json:"format"`
-		Streams []struct {
-			CodecName  string `json:"codec_name"`
-			CodecType  string `json:"codec_type"`
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			RFrameRate string `json:"r_frame_rate"`
-			ColorSpace string `json:"color_space"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil {
-		return nil, bp.converter.errorHandler.WrapError("failed to parse ffprobe output", err)
-	}
-
 	mediaInfo := &MediaInfo{
 		FullPath:                filePath,
-		Codec:                   "",
-		FrameCount:              1,
-		IsAnimated:              false,
+		Codec:                   media.Codec,
+		FrameCount:              max(media.FrameCount, 1),
+		IsAnimated:              media.Animated,
 		IsCorrupted:             false,
-		Container:               probeData.Format.FormatName,
+		Container:               media.Format,
 		IsCodecIncompatible:     false,
 		IsContainerIncompatible: false,
 	}
@@ -939,13 +970,21 @@ func (bp *BatchProcessor) getMediaInfo(filePath string) (*MediaInfo, error) {
 		mediaInfo.ModTime = fileInfo.ModTime()
 	}
 
+	// 头部解析的图像编解码器与容器均受支持
+	if media.Source == mediaprobe.SourceHeader {
+		return mediaInfo, nil
+	}
+
+	// 与Media共用同一次FFprobe结果
+	probeData, err := probe.FFprobe(ctx)
+	if err != nil {
+		return nil, bp.converter.errorHandler.WrapError("ffprobe failed", err)
+	}
+
 	// 解析编解码器信息
 	if len(probeData.Streams) > 0 {
-		// 安全访问第一个流
-		if firstStream := probeData.Streams[0]; firstStream.CodecName != "" {
-			mediaInfo.Codec = firstStream.CodecName
-		} else {
-			bp.logger.Warn("第一个流的编解码器名称为空", zap.String("file", filePath))
+		if mediaInfo.Codec == "" {
+			bp.logger.Warn("没有可用的视频流编解码器名称", zap.String("file", filePath))
 			mediaInfo.Codec = "unknown"
 		}
 
@@ -960,30 +999,11 @@ func (bp *BatchProcessor) getMediaInfo(filePath string) (*MediaInfo, error) {
 	// 检查容器是否不兼容
 	mediaInfo.IsContainerIncompatible = bp.isContainerIncompatibleByFFprobe(probeData.Format.FormatName)
 
-	// 判断是否为动图或视频
-	for _, stream := range probeData.Streams {
-		if stream.CodecType == "video" {
-			// 检查帧率是否大于1来判断是否为动图
-			if stream.RFrameRate != "0/0" && stream.RFrameRate != "1/1" {
-				mediaInfo.IsAnimated = true
-				mediaInfo.FrameCount = 10 // 简化处理，假设动图至少10帧
-			}
-			break
-		}
-	}
-
 	return mediaInfo, nil
 }
 
 // isCodecIncompatibleByFFprobe 根据FFprobe结果检查编解码器是否不兼容
-func (bp *BatchProcessor) isCodecIncompatibleByFFprobe(streams []struct {
-	CodecName  string `json:"codec_name"`
-	CodecType  string `json:"codec_type"`
-	Width      int    `json:"width"`
-	Height     int    `json:"height"`
-	RFrameRate string `json:"r_frame_rate"`
-	ColorSpace string `json:"color_space"`
-}) bool {
+func (bp *BatchProcessor) isCodecIncompatibleByFFprobe(streams []mediaprobe.Stream) bool {
 	// 这里可以添加具体的编解码器不兼容检查逻辑
 	// 例如：检查是否为不支持的编解码器
 	incompatibleCodecs := []string{"unsupported_codec1", "unsupported_codec2"}
@@ -1251,6 +1271,8 @@ func (bp *BatchProcessor) processFiles(files []*MediaFile) error {
 	// 启动转换进度条
 	ui.StartNamedProgress("convert", int64(len(files)), "转换文件")
 	defer ui.FinishNamedProgress("convert")
+	eta := bp.converter.newETATracker(files)
+	bp.converter.queueThreadPlan(files)
 
 	// 记录开始处理
 	// 开始批处理文件
@@ -1272,7 +1294,10 @@ func (bp *BatchProcessor) processFiles(files []*MediaFile) error {
 		// 检查是否收到中断信号
 		select {
 		case <-bp.ctx.Done():
-			// 收到中断信号，停止启动新的转换任务
+			// 收到中断信号，停止启动新的转换任务；未提交的文件不再计入队列组成
+			for _, pending := range files[i:] {
+				bp.converter.threadPlanner.Release(pending.Path)
+			}
 			return bp.ctx.Err()
 		default:
 		}
@@ -1284,6 +1309,7 @@ func (bp *BatchProcessor) processFiles(files []*MediaFile) error {
 		taskID := fmt.Sprintf("batch_task_%d", i)
 		err := workerPool.SubmitWithPriority(func() {
 			defer wg.Done()
+			defer bp.converter.threadPlanner.Release(currentFile.Path) // 跳过或分析失败的文件不再计入队列组成
 
 			// 在任务内部检查中断信号
 			select {
@@ -1304,11 +1330,13 @@ func (bp *BatchProcessor) processFiles(files []*MediaFile) error {
 			progressMsg.WriteString("/")
 			progressMsg.WriteString(strconv.Itoa(len(files)))
 			progressMsg.WriteString(")")
+			progressMsg.WriteString(eta.Done(currentFile.Path))
 			ui.UpdateNamedProgress("convert", processed, progressMsg.String())
 		}, PriorityNormal, taskID)
 
 		if err != nil {
 			wg.Done() // 如果提交失败，需要减少计数器
+			bp.converter.threadPlanner.Release(currentFile.Path)
 			bp.logger.Error("提交任务到高级池失败", zap.String("file", currentFile.Path), zap.Error(err))
 		}
 	}
@@ -0,0 +1,359 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/qualitysearch"
+
+	"go.uber.org/zap"
+)
+
+// burstGroup 连拍分组：同一目录下同一次连拍的各帧，按文件名排序
+type burstGroup struct {
+	Frames []*MediaFile
+	Picked *MediaFile // 选中帧（选中程度最高的帧），没有标记时为第一帧；合成动图时由它代表整组
+}
+
+// burstKey 连拍帧的分组标识与选中程度
+type burstKey struct {
+	ID   string
+	Pick int // Pixel的COVER帧或BurstPrimary标记为burstPicked，否则为用户评级（Rating，拒绝为-1），没有标记为0
+}
+
+// burstPicked 明确标记为选中帧时的选中程度，高于任何评级
+const burstPicked = 10
+
+// burstNamePattern Pixel等机型连拍文件名中的BURST时间戳，如 00001IMG_00001_BURST20230101123456789.jpg
+var burstNamePattern = regexp.MustCompile(`(?i)_BURST(\d{8,})`)
+
+// burstExts 连拍照片的格式（扫描阶段尚未精确识别类型，按扩展名判断）
+var burstExts = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+
+// groupBursts 按连拍策略分组：keep_picked的非选中帧在路由时跳过，animate的其余帧从任务列表移除、
+// 随代表帧处理；keep_all不分组，也不读取EXIF
+func (c *Converter) groupBursts(files []*MediaFile) []*MediaFile {
+	policy := c.config.Conversion.Burst.Policy
+	if policy != "keep_picked" && policy != "animate" {
+		return files
+	}
+
+	keys := c.burstKeys(files)
+	groups := make(map[string]*burstGroup)
+	var order []*burstGroup
+	for _, file := range files {
+		key, ok := keys[file]
+		if !ok {
+			continue
+		}
+		id := filepath.Dir(file.Path) + "\x00" + key.ID
+		group := groups[id]
+		if group == nil {
+			group = &burstGroup{}
+			groups[id] = group
+			order = append(order, group)
+		}
+		group.Frames = append(group.Frames, file)
+	}
+
+	merged := make(map[*MediaFile]bool)
+	count := 0
+	for _, group := range order {
+		if len(group.Frames) < 2 {
+			continue
+		}
+		sort.Slice(group.Frames, func(i, j int) bool {
+			return group.Frames[i].Path < group.Frames[j].Path
+		})
+		group.Picked = group.Frames[0]
+		for _, frame := range group.Frames[1:] {
+			if keys[frame].Pick > keys[group.Picked].Pick {
+				group.Picked = frame
+			}
+		}
+		for _, frame := range group.Frames {
+			frame.burst = group
+			if policy == "animate" && frame != group.Picked {
+				merged[frame] = true
+			}
+		}
+		count++
+	}
+	if count == 0 {
+		return files
+	}
+	c.logger.Info("检测到连拍照片", zap.Int("groups", count), zap.String("policy", policy))
+	if len(merged) == 0 {
+		return files
+	}
+
+	remaining := files[:0]
+	for _, file := range files {
+		if !merged[file] {
+			remaining = append(remaining, file)
+		}
+	}
+	return remaining
+}
+
+// burstKeys 返回各文件的连拍标识与选中程度：优先使用文件名中的BURST时间戳（文件名含COVER的为选中帧），
+// 其次读取Apple MakerNotes的BurstUUID及用户的选中标记（BurstPrimary、Rating）；不属于连拍的文件没有条目。
+// EXIF由一次exiftool调用批量读取，扫描阶段不为每张照片启动exiftool
+func (c *Converter) burstKeys(files []*MediaFile) map[*MediaFile]burstKey {
+	keys := make(map[*MediaFile]burstKey)
+	var candidates []*MediaFile
+	for _, file := range files {
+		if file.livePhoto != nil || !burstExts[strings.ToLower(file.Extension)] {
+			continue
+		}
+		name := filepath.Base(file.Path)
+		if match := burstNamePattern.FindStringSubmatch(name); match != nil {
+			key := burstKey{ID: "name:" + match[1]}
+			if strings.Contains(strings.ToUpper(name), "COVER") {
+				key.Pick = burstPicked
+			}
+			keys[file] = key
+			continue
+		}
+		candidates = append(candidates, file)
+	}
+	if len(candidates) == 0 {
+		return keys
+	}
+
+	tags, err := c.readBurstTags(candidates)
+	if err != nil {
+		c.logger.Warn("读取BurstUUID失败，只按文件名识别连拍", zap.Int("files", len(candidates)), zap.Error(err))
+		return keys
+	}
+	for _, file := range candidates {
+		if key, ok := tags[file.Path]; ok {
+			keys[file] = key
+		}
+	}
+	return keys
+}
+
+// readBurstTags 用一次exiftool调用读取多个文件的BurstUUID与选中标记，路径经参数文件传入以免命令行过长；
+// 返回以路径为键、带非空BurstUUID的连拍标识
+func (c *Converter) readBurstTags(files []*MediaFile) (map[string]burstKey, error) {
+	if _, err := exec.LookPath(c.config.Tools.ExiftoolPath); err != nil {
+		return nil, err
+	}
+
+	argFile, err := os.CreateTemp("", "pixly_burst_*.args")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(argFile.Name())
+	var builder strings.Builder
+	for _, file := range files {
+		builder.WriteString(file.Path)
+		builder.WriteByte('\n')
+	}
+	_, err = argFile.WriteString(builder.String())
+	if closeErr := argFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 部分文件无法读取时exiftool以非零状态退出，但仍输出其余文件的结果
+	cmd := exec.CommandContext(c.ctx, c.config.Tools.ExiftoolPath,
+		"-json", "-fast", "-BurstUUID", "-BurstPrimary", "-Rating", "-@", argFile.Name())
+	output, runErr := cmd.Output()
+	if len(output) == 0 {
+		if runErr != nil {
+			return nil, c.errorHandler.WrapError("exiftool执行失败", runErr)
+		}
+		return nil, nil
+	}
+
+	var tags []mediaprobe.Exif
+	if err := json.Unmarshal(output, &tags); err != nil {
+		return nil, c.errorHandler.WrapError("解析exiftool输出失败", err)
+	}
+	keys := make(map[string]burstKey, len(tags))
+	for _, tag := range tags {
+		uuid := tag.String("BurstUUID")
+		if uuid == "" {
+			continue
+		}
+		key := burstKey{ID: "uuid:" + uuid}
+		if primary := strings.ToLower(tag.String("BurstPrimary")); primary == "1" || primary == "true" {
+			key.Pick = burstPicked
+		} else if rating, err := strconv.Atoi(tag.String("Rating")); err == nil {
+			key.Pick = max(min(rating, burstPicked-1), -1)
+		}
+		keys[tag.String("SourceFile")] = key
+	}
+	return keys, nil
+}
+
+// burstRoute 按连拍策略决定分组内文件的路由；不由连拍策略决定时返回false，交给当前策略路由
+func (c *Converter) burstRoute(file *MediaFile) (Route, bool) {
+	group := file.burst
+	if group == nil {
+		return Route{}, false
+	}
+
+	burst := c.config.Conversion.Burst
+	switch {
+	case burst.Policy == "keep_picked" && file != group.Picked:
+		return skipRoute(fmt.Sprintf("连拍非选中帧（共%d帧），按配置只转换选中帧", len(group.Frames))), true
+	case burst.Policy == "animate" && file == group.Picked:
+		frames := make([]string, len(group.Frames))
+		for i, frame := range group.Frames {
+			frames[i] = frame.Path
+		}
+		return Route{
+			Strategy:  RouteStrategyBurst,
+			Action:    ActionBurstAnimation,
+			TargetExt: "." + burst.AnimatedFormat,
+			Reason:    fmt.Sprintf("连拍%d帧合成为%s动图", len(frames), strings.ToUpper(burst.AnimatedFormat)),
+			Frames:    frames,
+		}, true
+	}
+	return Route{}, false
+}
+
+// processBurstCompanions 代表帧处理完成后处理同组其余帧：动图合成成功时各帧保持原样并记录为跳过，
+// 否则各帧按普通照片转换
+func (c *Converter) processBurstCompanions(file *MediaFile, result *ConversionResult) {
+	group := file.burst
+	if group == nil || group.Picked != file || c.config.Conversion.Burst.Policy != "animate" {
+		return
+	}
+	animated := result.Success && !result.Skipped && result.Method == string(ActionBurstAnimation)
+	for _, frame := range group.Frames {
+		if frame == file {
+			continue
+		}
+		if animated {
+			route := skipRoute("已合并入连拍动图: " + filepath.Base(result.OutputPath))
+			frame.route = &route
+		}
+		frame.ctx = file.ctx
+		c.processFile(frame)
+	}
+}
+
+// convertBurstAnimation 将连拍各帧按文件名顺序合成为动图：AVIF由ffmpeg的libaom-av1编码，
+// JXL先合成APNG再交给cjxl；各帧原文件保持不变。品质模式下JXL为无损编码，
+// AVIF以最低CRF编码，但经4:2:0色度抽样，并非无损
+func (c *Converter) convertBurstAnimation(file *MediaFile, route Route) (string, error) {
+	if len(route.Frames) < 2 {
+		return "", fmt.Errorf("连拍组至少需要两帧: %s", file.Path)
+	}
+	var totalSize int64
+	for _, frame := range route.Frames {
+		stat, err := os.Stat(frame)
+		if err != nil {
+			return "", c.errorHandler.WrapError("连拍帧不可读", err, "frame", frame)
+		}
+		totalSize += stat.Size()
+	}
+
+	listPath, err := writeBurstConcatList(route.Frames, c.config.Conversion.Burst.FrameRate)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to write burst frame list", err)
+	}
+	defer os.Remove(listPath)
+
+	quality := c.config.Conversion.Quality.AVIFQuality
+	if route.TargetExt == ".jxl" {
+		quality = c.config.Conversion.Quality.JXLQuality
+	}
+	if c.mode == ModeQuality {
+		quality = 100
+	}
+	// 只有JXL质量100是数学无损；其余情况在输出模板的{quality}中记录实际质量，而不是lossless
+	if route.TargetExt != ".jxl" || quality < 100 {
+		file.QualitySetting = quality
+	}
+
+	outputPath := c.getOutputPath(file, route.TargetExt)
+	tempPath := outputPath + ".tmp"
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+	defer os.Remove(tempPath)
+
+	fps := strconv.FormatFloat(c.config.Conversion.Burst.FrameRate, 'f', -1, 64)
+	input := []string{"-hide_banner", "-nostats", "-y", "-f", "concat", "-safe", "0", "-i", listPath, "-r", fps}
+	switch route.TargetExt {
+	case ".jxl":
+		apngPath := tempPath + ".apng"
+		defer os.Remove(apngPath)
+		args := append(input, "-c:v", "apng", "-plays", "0", "-f", "apng", apngPath)
+		if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...); err != nil {
+			return "", c.errorHandler.WrapErrorWithOutput("burst APNG assembly failed", err, output)
+		}
+
+		distance := strconv.FormatFloat(qualitysearch.JXLDistance(quality), 'f', 2, 64)
+		args = append([]string{apngPath, tempPath, "--distance=" + distance}, defaultEncoderProfile.jxlEffortArgs()...)
+		if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.CjxlPath, args...); err != nil {
+			return "", c.errorHandler.WrapErrorWithOutput("burst JXL animation encode failed", err, output)
+		}
+	default:
+		args := append(input,
+			"-c:v", "libaom-av1",
+			"-crf", strconv.Itoa(qualitysearch.CRF(quality, 63)),
+			"-b:v", "0",
+			"-pix_fmt", "yuv420p",
+			"-f", "avif", tempPath)
+		if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...); err != nil {
+			return "", c.errorHandler.WrapErrorWithOutput("burst AVIF animation encode failed", err, output)
+		}
+	}
+
+	if stat, err := os.Stat(tempPath); err != nil || stat.Size() == 0 {
+		return "", fmt.Errorf("连拍动图输出为空: %s", outputPath)
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", err
+	}
+
+	c.logger.Debug("连拍动图合成完成",
+		zap.String("file", file.Path),
+		zap.Int("frames", len(route.Frames)),
+		zap.Int64("frames_size", totalSize),
+		zap.String("output", outputPath))
+	return outputPath, nil
+}
+
+// writeBurstConcatList 写出ffmpeg concat分离器的帧列表，每帧显示1/frameRate秒
+func writeBurstConcatList(frames []string, frameRate float64) (string, error) {
+	list, err := os.CreateTemp("", "pixly_burst_*.ffconcat")
+	if err != nil {
+		return "", err
+	}
+	duration := strconv.FormatFloat(1/frameRate, 'f', 6, 64)
+
+	var builder strings.Builder
+	builder.WriteString("ffconcat version 1.0\n")
+	for _, frame := range frames {
+		fmt.Fprintf(&builder, "file '%s'\nduration %s\n", strings.ReplaceAll(frame, "'", `'\''`), duration)
+	}
+	// 最后一帧需要重复一次，其时长才会生效
+	fmt.Fprintf(&builder, "file '%s'\n", strings.ReplaceAll(frames[len(frames)-1], "'", `'\''`))
+
+	_, err = list.WriteString(builder.String())
+	if closeErr := list.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(list.Name())
+		return "", err
+	}
+	return list.Name(), nil
+}
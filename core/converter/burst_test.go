@@ -0,0 +1,286 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"pixly/config"
+
+	"go.uber.org/zap"
+)
+
+// fakeBurstExiftool 模拟exiftool批量读取：参数文件中每个路径的标签取自同名.burst文件（JSON字段片段）
+const fakeBurstExiftool = `#!/bin/sh
+for arg; do last=$arg; done
+printf '['
+sep=''
+while IFS= read -r path; do
+	if [ -f "$path.burst" ]; then
+		printf '%s{"SourceFile":"%s",%s}' "$sep" "$path" "$(cat "$path.burst")"
+		sep=','
+	fi
+done < "$last"
+printf ']'
+`
+
+// newBurstConverter 创建按给定连拍策略分组的转换器，exiftool为空时不可用
+func newBurstConverter(t *testing.T, policy string, exiftool bool) *Converter {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Conversion.Burst.Policy = policy
+	cfg.Conversion.Burst.AnimatedFormat = "avif"
+	cfg.Tools.ExiftoolPath = filepath.Join(t.TempDir(), "exiftool")
+	if exiftool {
+		if err := os.WriteFile(cfg.Tools.ExiftoolPath, []byte(fakeBurstExiftool), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Converter{config: cfg, ctx: context.Background(), logger: zap.NewNop(), errorHandler: NewErrorHandler(zap.NewNop())}
+}
+
+// burstFile 连拍测试文件：tags为exiftool输出的JSON字段（为空时没有BurstUUID）
+type burstFile struct {
+	name string
+	tags string
+}
+
+// writeBurstFiles 创建测试文件并写入模拟的EXIF标签
+func writeBurstFiles(t *testing.T, dir string, specs []burstFile) []*MediaFile {
+	t.Helper()
+	var files []*MediaFile
+	for _, spec := range specs {
+		path := filepath.Join(dir, spec.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if spec.tags != "" {
+			if err := os.WriteFile(path+".burst", []byte(spec.tags), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files = append(files, &MediaFile{Path: path, Name: filepath.Base(path), Extension: filepath.Ext(path), Type: TypeImage})
+	}
+	return files
+}
+
+func TestBurstKeys(t *testing.T) {
+	dir := t.TempDir()
+	files := writeBurstFiles(t, dir, []burstFile{
+		{"00000IMG_00000_BURST20230101123456789.jpg", ""},
+		{"00001IMG_00001_BURST20230101123456789_COVER.jpg", ""},
+		{"IMG_0001.HEIC", `"BurstUUID":"U1"`},
+		{"IMG_0002.HEIC", `"BurstUUID":"U1","BurstPrimary":1`},
+		{"IMG_0003.JPG", `"BurstUUID":"U1","Rating":4`},
+		{"IMG_0004.JPG", `"BurstUUID":"U1","Rating":-1`},
+		{"IMG_0005.jpg", `"BurstUUID":"U2","BurstPrimary":"True","Rating":2`},
+		{"IMG_0006.jpg", `"Rating":5`},
+		{"IMG_0007.png", `"BurstUUID":"U3"`},
+		{"IMG_0008.HEIC", `"BurstUUID":"U4"`},
+	})
+	files[len(files)-1].livePhoto = &livePhotoPair{} // Live Photo不参与连拍分组
+
+	want := map[string]burstKey{
+		"00000IMG_00000_BURST20230101123456789.jpg":       {ID: "name:20230101123456789"},
+		"00001IMG_00001_BURST20230101123456789_COVER.jpg": {ID: "name:20230101123456789", Pick: burstPicked},
+		"IMG_0001.HEIC": {ID: "uuid:U1"},
+		"IMG_0002.HEIC": {ID: "uuid:U1", Pick: burstPicked},
+		"IMG_0003.JPG":  {ID: "uuid:U1", Pick: 4},
+		"IMG_0004.JPG":  {ID: "uuid:U1", Pick: -1},
+		"IMG_0005.jpg":  {ID: "uuid:U2", Pick: burstPicked},
+	}
+
+	t.Run("读取EXIF", func(t *testing.T) {
+		keys := newBurstConverter(t, "keep_picked", true).burstKeys(files)
+		if len(keys) != len(want) {
+			t.Errorf("burstKeys 返回 %d 个条目, 期望 %d 个", len(keys), len(want))
+		}
+		for _, file := range files {
+			got, ok := keys[file]
+			expected, wantOK := want[file.Name]
+			if ok != wantOK || got != expected {
+				t.Errorf("%s: burstKeys = %+v, %v, 期望 %+v, %v", file.Name, got, ok, expected, wantOK)
+			}
+		}
+	})
+
+	t.Run("exiftool不可用时只按文件名", func(t *testing.T) {
+		keys := newBurstConverter(t, "keep_picked", false).burstKeys(files)
+		if len(keys) != 2 || keys[files[1]].Pick != burstPicked {
+			t.Errorf("burstKeys = %v, 期望只有两个按文件名的条目", keys)
+		}
+	})
+}
+
+func TestGroupBursts(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		files     []burstFile
+		picked    []string // 各组的选中帧
+		remaining []string
+	}{
+		{"keep_all不分组", "keep_all", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1"`}, {"IMG_0002.HEIC", `"BurstUUID":"U1"`}},
+			nil, []string{"IMG_0001.HEIC", "IMG_0002.HEIC"}},
+		{"没有标记时选第一帧", "keep_picked", []burstFile{{"IMG_0002.HEIC", `"BurstUUID":"U1"`}, {"IMG_0001.HEIC", `"BurstUUID":"U1"`}},
+			[]string{"IMG_0001.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0002.HEIC"}},
+		{"Apple连拍按BurstPrimary", "keep_picked", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1","Rating":5`}, {"IMG_0002.HEIC", `"BurstUUID":"U1","BurstPrimary":1`}, {"IMG_0003.HEIC", `"BurstUUID":"U1"`}},
+			[]string{"IMG_0002.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0002.HEIC", "IMG_0003.HEIC"}},
+		{"Apple连拍按评级", "keep_picked", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1","Rating":-1`}, {"IMG_0002.HEIC", `"BurstUUID":"U1","Rating":2`}, {"IMG_0003.HEIC", `"BurstUUID":"U1","Rating":3`}},
+			[]string{"IMG_0003.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0002.HEIC", "IMG_0003.HEIC"}},
+		{"被拒绝的第一帧不作为选中帧", "keep_picked", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1","Rating":-1`}, {"IMG_0002.HEIC", `"BurstUUID":"U1"`}},
+			[]string{"IMG_0002.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0002.HEIC"}},
+		{"Pixel连拍的COVER帧", "animate", []burstFile{{"00000IMG_00000_BURST20230101123456789.jpg", ""}, {"00001IMG_00001_BURST20230101123456789_COVER.jpg", ""}, {"00002IMG_00002_BURST20230101123456789.jpg", ""}},
+			[]string{"00001IMG_00001_BURST20230101123456789_COVER.jpg"}, []string{"00001IMG_00001_BURST20230101123456789_COVER.jpg"}},
+		{"animate只保留代表帧", "animate", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1"`}, {"IMG_0002.HEIC", `"BurstUUID":"U1"`}, {"IMG_0003.HEIC", ""}},
+			[]string{"IMG_0001.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0003.HEIC"}},
+		{"单帧不成组", "animate", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1"`}, {"IMG_0002.HEIC", `"BurstUUID":"U2"`}},
+			nil, []string{"IMG_0001.HEIC", "IMG_0002.HEIC"}},
+		{"不同目录分别成组", "animate", []burstFile{{"a/IMG_0001.HEIC", `"BurstUUID":"U1"`}, {"b/IMG_0001.HEIC", `"BurstUUID":"U1"`}},
+			nil, []string{"a/IMG_0001.HEIC", "b/IMG_0001.HEIC"}},
+		{"多个分组", "keep_picked", []burstFile{{"IMG_0001.HEIC", `"BurstUUID":"U1"`}, {"IMG_0002.HEIC", `"BurstUUID":"U1","BurstPrimary":1`}, {"IMG_0003.HEIC", `"BurstUUID":"U2","Rating":1`}, {"IMG_0004.HEIC", `"BurstUUID":"U2"`}},
+			[]string{"IMG_0002.HEIC", "IMG_0003.HEIC"}, []string{"IMG_0001.HEIC", "IMG_0002.HEIC", "IMG_0003.HEIC", "IMG_0004.HEIC"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := writeBurstFiles(t, dir, tt.files)
+			all := append([]*MediaFile{}, files...)
+			c := newBurstConverter(t, tt.policy, true)
+
+			remaining := c.groupBursts(files)
+			var names []string
+			for _, file := range remaining {
+				rel, _ := filepath.Rel(dir, file.Path)
+				names = append(names, filepath.ToSlash(rel))
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.remaining, ",") {
+				t.Errorf("剩余任务 = %v, 期望 %v", names, tt.remaining)
+			}
+
+			var picked []string
+			groups := make(map[*burstGroup]bool)
+			for _, file := range all {
+				if file.burst == nil {
+					continue
+				}
+				groups[file.burst] = true
+				if file.burst.Picked == file {
+					picked = append(picked, file.Name)
+				}
+			}
+			sort.Strings(picked)
+			if strings.Join(picked, ",") != strings.Join(tt.picked, ",") {
+				t.Errorf("选中帧 = %v, 期望 %v", picked, tt.picked)
+			}
+			for group := range groups {
+				if !sort.SliceIsSorted(group.Frames, func(i, j int) bool { return group.Frames[i].Path < group.Frames[j].Path }) {
+					t.Error("组内各帧应按文件名排序")
+				}
+			}
+		})
+	}
+}
+
+func TestBurstRoute(t *testing.T) {
+	first := &MediaFile{Path: "/p/IMG_0001.HEIC"}
+	second := &MediaFile{Path: "/p/IMG_0002.HEIC"}
+	group := &burstGroup{Frames: []*MediaFile{first, second}, Picked: second}
+	first.burst, second.burst = group, group
+
+	tests := []struct {
+		policy string
+		file   *MediaFile
+		ok     bool
+		action RouteAction
+	}{
+		{"keep_picked", first, true, ActionSkip},
+		{"keep_picked", second, false, ""},
+		{"animate", second, true, ActionBurstAnimation},
+		{"animate", first, false, ""},
+		{"keep_all", second, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy+"_"+filepath.Base(tt.file.Path), func(t *testing.T) {
+			route, ok := newBurstConverter(t, tt.policy, false).burstRoute(tt.file)
+			if ok != tt.ok || route.Action != tt.action {
+				t.Fatalf("burstRoute = %+v, %v, 期望 %s, %v", route, ok, tt.action, tt.ok)
+			}
+			if route.Action == ActionBurstAnimation && (route.TargetExt != ".avif" || strings.Join(route.Frames, ",") != first.Path+","+second.Path) {
+				t.Errorf("动图路由 = %+v", route)
+			}
+		})
+	}
+}
+
+func TestWriteBurstConcatList(t *testing.T) {
+	frames := []string{"/p/IMG_0001.jpg", "/p/it's.jpg"}
+	listPath, err := writeBurstConcatList(frames, 4)
+	if err != nil {
+		t.Fatalf("writeBurstConcatList: %v", err)
+	}
+	defer os.Remove(listPath)
+	data, err := os.ReadFile(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ffconcat version 1.0\n" +
+		"file '/p/IMG_0001.jpg'\nduration 0.250000\n" +
+		"file '/p/it'\\''s.jpg'\nduration 0.250000\n" +
+		"file '/p/it'\\''s.jpg'\n"
+	if string(data) != want {
+		t.Errorf("帧列表 = %q, 期望 %q", data, want)
+	}
+}
+
+func TestGridDivisions(t *testing.T) {
+	tests := []struct {
+		length  int
+		maxTile int
+		want    []int
+	}{
+		{1000, 4096, []int{1, 2, 4, 5, 10}}, // 8份时单块125像素为奇数
+		{1000, 400, []int{4, 5, 10}},        // 单块不超过400像素
+		{100, 4096, []int{1}},               // 两份时单块小于64像素
+		{8192, 4096, []int{2, 4, 8, 16, 32, 64, 128}},
+		{4097, 4096, nil}, // 17份时单块241像素为奇数
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d_%d", tt.length, tt.maxTile), func(t *testing.T) {
+			if got := gridDivisions(tt.length, tt.maxTile); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("gridDivisions(%d, %d) = %v, 期望 %v", tt.length, tt.maxTile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvifGrid(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxTile       int
+		maxPixels     int64
+		cols, rows    int
+	}{
+		{"不超过限制时为单块", 1000, 1000, 4096, 1000000, 1, 1},
+		{"边长超过限制", 8192, 4096, 4096, 16000000, 2, 2},
+		{"像素数超过限制时取块数最少的网格", 1000, 1000, 4096, 250000, 1, 4},
+		{"无法均分", 4097, 1000, 4096, 16000000, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, rows := avifGrid(tt.width, tt.height, tt.maxTile, tt.maxPixels)
+			if cols != tt.cols || rows != tt.rows {
+				t.Errorf("avifGrid = %dx%d, 期望 %dx%d", cols, rows, tt.cols, tt.rows)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package converter
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -85,35 +87,35 @@ const (
 	FilesBucket   = "files"
 )
 
-// NewCheckpointManager 创建新的断点续传管理器
-func NewCheckpointManager(logger *zap.Logger, targetDir string, errorHandler *ErrorHandler) (*CheckpointManager, error) {
-	// 创建数据库文件路径
-	dbDir := filepath.Join(os.TempDir(), "pixly_checkpoints")
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return nil, errorHandler.WrapError("创建检查点目录失败", err)
-	}
-
-	dbPath := filepath.Join(dbDir, "conversion.db")
+// checkpointOpenTimeout 数据库锁等待时间：同一目标目录已有会话运行时快速失败，而不是长时间阻塞
+const checkpointOpenTimeout = 2 * time.Second
 
-	// 打开数据库 - 增加超时时间并添加重试机制
-	var db *bbolt.DB
-	var err error
+// ErrCheckpointBusy 检查点数据库正被其他进程使用（同一目标目录上已有会话在运行）
+var ErrCheckpointBusy = errors.New("检查点数据库正被其他进程使用")
 
-	// 重试机制：最多尝试3次
-	for i := 0; i < 3; i++ {
-		db, err = bbolt.Open(dbPath, 0600, &bbolt.Options{
-			Timeout: 30 * time.Second, // 增加超时时间
-		})
-		if err == nil {
-			break
-		}
+// NewCheckpointManager 创建新的断点续传管理器，dbPath 为检查点数据库文件（由 SessionStore 按目标目录分配）
+func NewCheckpointManager(logger *zap.Logger, dbPath string, errorHandler *ErrorHandler) (*CheckpointManager, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, errorHandler.WrapError("创建检查点目录失败", err)
+	}
 
-		// 如果是超时错误，尝试删除可能损坏的数据库文件
-		if i < 2 { // 前两次尝试时
-			if os.Remove(dbPath) == nil {
-				logger.Warn("删除可能损坏的数据库文件", zap.String("path", dbPath))
-			}
-			time.Sleep(time.Second) // 等待1秒后重试
+	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{
+		Timeout: checkpointOpenTimeout,
+	})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s", ErrCheckpointBusy, dbPath)
+	}
+	if err != nil {
+		// 数据库损坏：移到一旁后重建，不直接删除，其中的撤销日志仍可人工恢复
+		corruptPath := dbPath + ".corrupt"
+		if os.Rename(dbPath, corruptPath) == nil {
+			logger.Warn("检查点数据库损坏，已移到一旁并重建",
+				zap.String("path", dbPath),
+				zap.String("corrupt", corruptPath),
+				zap.Error(err))
+			db, err = bbolt.Open(dbPath, 0600, &bbolt.Options{
+				Timeout: checkpointOpenTimeout,
+			})
 		}
 	}
 	if err != nil {
@@ -128,6 +130,12 @@ func NewCheckpointManager(logger *zap.Logger, targetDir string, errorHandler *Er
 		if _, err := tx.CreateBucketIfNotExists([]byte(FilesBucket)); err != nil {
 			return err
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(JournalBucket)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(OutputsBucket)); err != nil {
+			return err
+		}
 		return nil
 	})
 	if err != nil {
@@ -183,10 +191,7 @@ func (cm *CheckpointManager) ResumeSession(sessionID string) (*SessionInfo, erro
 		b := tx.Bucket([]byte(SessionBucket))
 		data := b.Get([]byte(sessionID))
 		if data == nil {
-			var builder strings.Builder
-			builder.WriteString("会话不存在: sessionID: ")
-			builder.WriteString(sessionID)
-			return cm.errorHandler.WrapError(builder.String(), nil)
+			return fmt.Errorf("会话不存在: sessionID: %s", sessionID)
 		}
 
 		return json.Unmarshal(data, &cm.session)
@@ -199,6 +204,34 @@ func (cm *CheckpointManager) ResumeSession(sessionID string) (*SessionInfo, erro
 	return cm.session, nil
 }
 
+// GetSession 读取指定会话，不改变当前会话
+func (cm *CheckpointManager) GetSession(sessionID string) (*SessionInfo, error) {
+	var session *SessionInfo
+
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket([]byte(SessionBucket)).Get([]byte(sessionID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &session)
+	})
+
+	return session, err
+}
+
+// AddTotalFiles 增加当前会话的总文件数（监视模式下文件陆续加入）
+func (cm *CheckpointManager) AddTotalFiles(count int) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.session == nil {
+		return nil
+	}
+	cm.session.TotalFiles += count
+	cm.session.LastUpdate = time.Now()
+	return cm.saveSession()
+}
+
 // ListSessions 列出所有可恢复的会话
 func (cm *CheckpointManager) ListSessions() ([]*SessionInfo, error) {
 	var sessions []*SessionInfo
@@ -227,6 +260,11 @@ func (cm *CheckpointManager) UpdateFileStatus(filePath string, status FileStatus
 		return cm.errorHandler.WrapError("没有活动会话", nil)
 	}
 
+	// 记录使用绝对路径，从其他工作目录恢复会话时仍能匹配
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+
 	// 获取文件信息
 	fileInfo, err := os.Stat(filePath)
 	var fileSize int64
@@ -297,6 +335,10 @@ func (cm *CheckpointManager) GetFileStatus(filePath string) (*FileRecord, error)
 		return nil, cm.errorHandler.WrapError("没有活动会话", nil)
 	}
 
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+
 	var keyBuilder strings.Builder
 	keyBuilder.WriteString(cm.sessionID)
 	keyBuilder.WriteString(":")
@@ -352,6 +394,69 @@ func (cm *CheckpointManager) GetPendingFiles() ([]string, error) {
 	return pendingFiles, err
 }
 
+// ListFileRecords 列出会话的所有文件记录，按文件路径排序
+func (cm *CheckpointManager) ListFileRecords(sessionID string) ([]*FileRecord, error) {
+	var records []*FileRecord
+	prefix := sessionID + ":"
+
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(FilesBucket)).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			var record FileRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				continue
+			}
+			records = append(records, &record)
+		}
+		return nil
+	})
+
+	return records, err
+}
+
+// GetFinishedFiles 获取当前会话中已完成或已跳过的文件，恢复会话时不再处理
+func (cm *CheckpointManager) GetFinishedFiles() (map[string]bool, error) {
+	cm.mutex.RLock()
+	sessionID := cm.sessionID
+	cm.mutex.RUnlock()
+
+	if sessionID == "" {
+		return nil, errors.New("没有活动会话")
+	}
+
+	records, err := cm.ListFileRecords(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	finished := make(map[string]bool, len(records))
+	for _, record := range records {
+		if record.Status == StatusCompleted || record.Status == StatusSkipped {
+			finished[record.FilePath] = true
+		}
+	}
+	return finished, nil
+}
+
+// IsEmpty 数据库的所有bucket（会话、文件记录、撤销日志、输出登记以及其他模块写入的数据）都没有记录时返回true
+func (cm *CheckpointManager) IsEmpty() (bool, error) {
+	empty := true
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			if k, _ := b.Cursor().First(); k != nil {
+				empty = false
+			}
+			return nil
+		})
+	})
+	return empty, err
+}
+
+// DBPath 返回检查点数据库文件路径
+func (cm *CheckpointManager) DBPath() string {
+	return cm.dbPath
+}
+
 // SaveCurrentState 保存当前状态 - 强制同步到磁盘
 func (cm *CheckpointManager) SaveCurrentState() error {
 	cm.mutex.Lock()
@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// jxlPrimaries/jxlTransfers CICP取值到cjxl色彩空间描述（color_space=RGB_<白点>_<色域>_<意图>_<传输>）的映射
+var (
+	jxlPrimaries = map[int]string{
+		mediaprobe.PrimariesBT709:     "D65_SRG",
+		mediaprobe.PrimariesBT2020:    "D65_202",
+		mediaprobe.PrimariesDCIP3:     "DCI_DCI",
+		mediaprobe.PrimariesDisplayP3: "D65_DCI",
+	}
+	jxlTransfers = map[int]string{
+		mediaprobe.TransferBT709:  "709",
+		mediaprobe.TransferLinear: "Lin",
+		mediaprobe.TransferSRGB:   "SRG",
+		mediaprobe.TransferPQ:     "PeQ",
+		mediaprobe.TransferHLG:    "HLG",
+	}
+)
+
+// sourceColor 返回源文件的色彩描述，无需保留色彩信息（8位sRGB）或无法探测时为nil
+func (c *Converter) sourceColor(path string) *mediaprobe.Color {
+	color, err := c.prober.Get(path).Color(c.ctx)
+	if err != nil {
+		c.logger.Debug("色彩探测失败", zap.String("file", path), zap.Error(err))
+		return nil
+	}
+	if !color.NeedsPreservation() {
+		return nil
+	}
+	c.logger.Debug("检测到需要保留的色彩信息", zap.String("file", path), zap.Stringer("color", color))
+	return color
+}
+
+// avifColorArgs avifenc的色彩参数：位深、CICP与内容亮度。无损编码直接保存RGB，矩阵系数固定为0（恒等）。
+// 嵌入的ICC配置由avifenc从PNG/JPEG输入直接复制；avifenc不支持写入母版显示器元数据（mdcv）
+func avifColorArgs(color *mediaprobe.Color, lossless bool) []string {
+	if color == nil {
+		return nil
+	}
+	var args []string
+	if color.HighBitDepth() {
+		depth := "10"
+		if color.BitDepth > 10 {
+			depth = "12"
+		}
+		args = append(args, "-d", depth)
+	}
+	if color.Primaries > 0 && color.Transfer > 0 {
+		// RGB输入的矩阵系数为0（恒等），需要YUV 4:4:4；有损编码按色域选择YUV矩阵
+		matrix := color.Matrix
+		switch {
+		case lossless:
+			matrix = mediaprobe.MatrixIdentity
+		case matrix == mediaprobe.MatrixIdentity:
+			matrix = mediaprobe.MatrixBT601
+			if color.Primaries == mediaprobe.PrimariesBT2020 {
+				matrix = mediaprobe.MatrixBT2020NCL
+			}
+		}
+		args = append(args, "--cicp", fmt.Sprintf("%d/%d/%d", color.Primaries, color.Transfer, matrix))
+	}
+	if color.MaxCLL > 0 {
+		args = append(args, "--clli", fmt.Sprintf("%d,%d", color.MaxCLL, color.MaxFALL))
+	}
+	return args
+}
+
+// jxlColorArgs cjxl的色彩参数：源文件没有ICC配置而以CICP声明色彩时显式指定色彩空间，
+// HDR源按母版最大亮度设置强度目标。位深与ICC配置由cjxl从输入直接保留
+func jxlColorArgs(color *mediaprobe.Color) []string {
+	if color == nil {
+		return nil
+	}
+	var args []string
+	if !color.ICC {
+		primaries, okPrimaries := jxlPrimaries[color.Primaries]
+		transfer, okTransfer := jxlTransfers[color.Transfer]
+		if okPrimaries && okTransfer {
+			args = append(args, "-x", "color_space=RGB_"+primaries+"_Rel_"+transfer)
+		}
+	}
+	if color.HDR() && color.Mastering != nil {
+		args = append(args, "--intensity_target="+strconv.Itoa(int(color.Mastering.MaxLuminance)))
+	}
+	return args
+}
+
+// intermediatePNGArgs 生成中间PNG时的像素格式：高位深源使用16位PNG，避免被截断为8位
+func intermediatePNGArgs(color *mediaprobe.Color, hasAlpha bool) []string {
+	if color == nil || !color.HighBitDepth() {
+		return nil
+	}
+	if hasAlpha {
+		return []string{"-pix_fmt", "rgba64be"}
+	}
+	return []string{"-pix_fmt", "rgb48be"}
+}
+
+// videoColorArgs ffmpeg视频编码的色彩标签与HDR母版元数据；返回通用参数与编码器私有参数（x265-params/svtav1-params）
+func videoColorArgs(encoder videoEncoderSpec, stream *mediaprobe.Stream, color *mediaprobe.Color) ([]string, []string) {
+	var args []string
+	for _, tag := range []struct{ option, value string }{
+		{"-color_primaries", stream.ColorPrimaries},
+		{"-color_trc", stream.ColorTransfer},
+		{"-colorspace", stream.ColorSpace},
+		{"-color_range", stream.ColorRange},
+	} {
+		if tag.value != "" && tag.value != "unknown" {
+			args = append(args, tag.option, tag.value)
+		}
+	}
+	if color == nil || !color.HDR() {
+		return args, nil
+	}
+
+	var params []string
+	switch encoder.Name {
+	case "libx265":
+		params = append(params, "hdr10=1", "repeat-headers=1")
+		if m := color.Mastering; m != nil {
+			// x265的色度坐标单位为0.00002，亮度单位为0.0001 cd/m²
+			xy := func(v [2]float64) string {
+				return fmt.Sprintf("(%d,%d)", int(v[0]*50000+0.5), int(v[1]*50000+0.5))
+			}
+			params = append(params, fmt.Sprintf("master-display=G%sB%sR%sWP%sL(%d,%d)",
+				xy(m.Green), xy(m.Blue), xy(m.Red), xy(m.WhitePoint),
+				int(m.MaxLuminance*10000+0.5), int(m.MinLuminance*10000+0.5)))
+		}
+		if color.MaxCLL > 0 {
+			params = append(params, fmt.Sprintf("max-cll=%d,%d", color.MaxCLL, color.MaxFALL))
+		}
+	case "libsvtav1":
+		params = append(params, "enable-hdr=1")
+		if m := color.Mastering; m != nil {
+			xy := func(v [2]float64) string { return fmt.Sprintf("(%.4f,%.4f)", v[0], v[1]) }
+			params = append(params, fmt.Sprintf("mastering-display=G%sB%sR%sWP%sL(%.4f,%.4f)",
+				xy(m.Green), xy(m.Blue), xy(m.Red), xy(m.WhitePoint), m.MaxLuminance, m.MinLuminance))
+		}
+		if color.MaxCLL > 0 {
+			params = append(params, fmt.Sprintf("content-light=%d,%d", color.MaxCLL, color.MaxFALL))
+		}
+	}
+	return args, params
+}
+
+// verifyColorPreserved 检查输出是否保留了源文件的位深、HDR传输特性与广色域；
+// 输出被静默色调映射为8位sRGB或无法探测输出色彩时返回错误，使本次转换失败
+func (c *Converter) verifyColorPreserved(source *mediaprobe.Color, outputPath string) error {
+	if source == nil {
+		return nil
+	}
+	// 临时输出会被重命名或删除，不保留在共享探测缓存中
+	output, err := c.prober.Get(outputPath).Color(c.ctx)
+	c.prober.Forget(outputPath)
+	if err != nil {
+		return c.errorHandler.WrapError("failed to probe output color", err)
+	}
+
+	problems := colorProblems(source, output)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	c.logger.Warn("输出未保留源文件色彩信息",
+		zap.String("output", outputPath),
+		zap.Stringer("source", source),
+		zap.Stringer("result", output),
+		zap.Strings("problems", problems))
+	return fmt.Errorf("色彩验证失败: %s", strings.Join(problems, "，"))
+}
+
+// colorProblems 比较源文件与输出的色彩描述，返回输出丢失的色彩特征
+func colorProblems(source, output *mediaprobe.Color) []string {
+	var problems []string
+	if source.HighBitDepth() && !output.HighBitDepth() {
+		problems = append(problems, fmt.Sprintf("位深从%d位降为%d位", source.BitDepth, output.BitDepth))
+	}
+	if source.HDR() && !sameTransfer(source, output) {
+		problems = append(problems, "HDR传输特性丢失")
+	}
+	if source.WideGamut() && !samePrimaries(source, output) {
+		problems = append(problems, "广色域被转换为sRGB")
+	}
+	return problems
+}
+
+// sameTransfer 输出的传输特性是否与源文件一致：双方都声明了CICP时直接比较，
+// 否则按输出的CICP或ICC配置描述判断是否仍为HDR
+func sameTransfer(source, output *mediaprobe.Color) bool {
+	if source.Transfer > 0 && output.Transfer > 0 {
+		return output.Transfer == source.Transfer
+	}
+	return output.HDR()
+}
+
+// samePrimaries 输出的色域是否与源文件一致：双方都声明了CICP时直接比较（DCI-P3与Display P3视为同一色域，
+// JPEG XL的色域枚举不区分两者），否则按输出的CICP或ICC配置描述判断是否仍为广色域
+func samePrimaries(source, output *mediaprobe.Color) bool {
+	if source.Primaries > 0 && output.Primaries > 0 {
+		return p3Family(output.Primaries) == p3Family(source.Primaries)
+	}
+	return output.WideGamut()
+}
+
+// p3Family 将Display P3归入DCI-P3
+func p3Family(primaries int) int {
+	if primaries == mediaprobe.PrimariesDisplayP3 {
+		return mediaprobe.PrimariesDCIP3
+	}
+	return primaries
+}
@@ -0,0 +1,146 @@
+package converter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// hdr10Mastering 常见HDR10母版：P3-D65三原色，1000/0.0001 cd/m²
+var hdr10Mastering = &mediaprobe.MasteringDisplay{
+	Red:          [2]float64{0.68, 0.32},
+	Green:        [2]float64{0.265, 0.69},
+	Blue:         [2]float64{0.15, 0.06},
+	WhitePoint:   [2]float64{0.3127, 0.329},
+	MaxLuminance: 1000,
+	MinLuminance: 0.0001,
+}
+
+func TestAvifColorArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		color    *mediaprobe.Color
+		lossless bool
+		want     string
+	}{
+		{"无需保留色彩", nil, false, ""},
+		{"10位HDR有损沿用矩阵", &mediaprobe.Color{Primaries: 9, Transfer: 16, Matrix: 9, BitDepth: 10, MaxCLL: 1000, MaxFALL: 400}, false, "-d 10 --cicp 9/16/9 --clli 1000,400"},
+		{"16位源使用12位", &mediaprobe.Color{Primaries: 12, Transfer: 13, Matrix: 0, BitDepth: 16}, false, "-d 12 --cicp 12/13/6"},
+		{"Rec.2020的RGB源有损使用BT.2020矩阵", &mediaprobe.Color{Primaries: 9, Transfer: 18, Matrix: 0, BitDepth: 10}, false, "-d 10 --cicp 9/18/9"},
+		{"无损固定恒等矩阵", &mediaprobe.Color{Primaries: 12, Transfer: 13, Matrix: 1, BitDepth: 8}, true, "--cicp 12/13/0"},
+		{"只有ICC时不写CICP", &mediaprobe.Color{ICC: true, ICCDescription: "Display P3", BitDepth: 8}, false, ""},
+		{"缺少传输特性时不写CICP", &mediaprobe.Color{Primaries: 9, BitDepth: 12}, false, "-d 12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(avifColorArgs(tt.color, tt.lossless), " "); got != tt.want {
+				t.Errorf("avifColorArgs = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJxlColorArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		color *mediaprobe.Color
+		want  string
+	}{
+		{"无需保留色彩", nil, ""},
+		{"Display P3", &mediaprobe.Color{Primaries: 12, Transfer: 13}, "-x color_space=RGB_D65_DCI_Rel_SRG"},
+		{"DCI-P3线性", &mediaprobe.Color{Primaries: 11, Transfer: 8}, "-x color_space=RGB_DCI_DCI_Rel_Lin"},
+		{"HDR10带母版亮度", &mediaprobe.Color{Primaries: 9, Transfer: 16, Mastering: hdr10Mastering}, "-x color_space=RGB_D65_202_Rel_PeQ --intensity_target=1000"},
+		{"HLG无母版", &mediaprobe.Color{Primaries: 9, Transfer: 18}, "-x color_space=RGB_D65_202_Rel_HLG"},
+		{"有ICC时由cjxl保留", &mediaprobe.Color{Primaries: 12, Transfer: 13, ICC: true}, ""},
+		{"不支持的色域", &mediaprobe.Color{Primaries: 22, Transfer: 13, BitDepth: 16}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(jxlColorArgs(tt.color), " "); got != tt.want {
+				t.Errorf("jxlColorArgs = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVideoColorArgs(t *testing.T) {
+	stream := &mediaprobe.Stream{ColorPrimaries: "bt2020", ColorTransfer: "smpte2084", ColorSpace: "bt2020nc", ColorRange: "unknown"}
+	hdr := &mediaprobe.Color{Primaries: 9, Transfer: 16, Matrix: 9, BitDepth: 10, Mastering: hdr10Mastering, MaxCLL: 1000, MaxFALL: 400}
+
+	tests := []struct {
+		name    string
+		encoder string
+		color   *mediaprobe.Color
+		params  string
+	}{
+		{"x265母版元数据", "libx265", hdr, "hdr10=1:repeat-headers=1:master-display=G(13250,34500)B(7500,3000)R(34000,16000)WP(15635,16450)L(10000000,1):max-cll=1000,400"},
+		{"SVT-AV1母版元数据", "libsvtav1", hdr, "enable-hdr=1:mastering-display=G(0.2650,0.6900)B(0.1500,0.0600)R(0.6800,0.3200)WP(0.3127,0.3290)L(1000.0000,0.0001):content-light=1000,400"},
+		{"x265无母版", "libx265", &mediaprobe.Color{Transfer: 18}, "hdr10=1:repeat-headers=1"},
+		{"libaom没有私有参数", "libaom-av1", hdr, ""},
+		{"SDR不写HDR参数", "libx265", &mediaprobe.Color{Primaries: 12, Transfer: 13, BitDepth: 10}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, params := videoColorArgs(videoEncoders[tt.encoder], stream, tt.color)
+			if got, want := strings.Join(args, " "), "-color_primaries bt2020 -color_trc smpte2084 -colorspace bt2020nc"; got != want {
+				t.Errorf("色彩标签 = %q, 期望 %q", got, want)
+			}
+			if got := strings.Join(params, ":"); got != tt.params {
+				t.Errorf("私有参数 = %q, 期望 %q", got, tt.params)
+			}
+		})
+	}
+}
+
+func TestColorProblems(t *testing.T) {
+	hdr := &mediaprobe.Color{Primaries: 9, Transfer: 16, BitDepth: 10}
+	p3 := &mediaprobe.Color{Primaries: 12, Transfer: 13, BitDepth: 8}
+	p3ICC := &mediaprobe.Color{ICC: true, ICCDescription: "Display P3", BitDepth: 8}
+
+	tests := []struct {
+		name   string
+		source *mediaprobe.Color
+		output *mediaprobe.Color
+		want   []string
+	}{
+		{"完全保留", hdr, &mediaprobe.Color{Primaries: 9, Transfer: 16, BitDepth: 10}, nil},
+		{"色调映射为8位sRGB", hdr, &mediaprobe.Color{Primaries: 1, Transfer: 13, BitDepth: 8}, []string{"位深从10位降为8位", "HDR传输特性丢失", "广色域被转换为sRGB"}},
+		{"PQ变为HLG", hdr, &mediaprobe.Color{Primaries: 9, Transfer: 18, BitDepth: 10}, []string{"HDR传输特性丢失"}},
+		{"sRGB的ICC不满足HDR与广色域", hdr, &mediaprobe.Color{ICC: true, ICCDescription: "sRGB IEC61966-2.1", BitDepth: 10}, []string{"HDR传输特性丢失", "广色域被转换为sRGB"}},
+		{"描述未知的ICC不满足广色域", p3, &mediaprobe.Color{ICC: true, BitDepth: 8}, []string{"广色域被转换为sRGB"}},
+		{"Display P3输出为JXL的P3枚举", p3, &mediaprobe.Color{Primaries: 11, Transfer: 13, BitDepth: 8}, nil},
+		{"P3被转换为Rec.2020", p3, &mediaprobe.Color{Primaries: 9, Transfer: 13, BitDepth: 8}, []string{"广色域被转换为sRGB"}},
+		{"源为ICC时输出保留P3配置", p3ICC, &mediaprobe.Color{ICC: true, ICCDescription: "Display P3", BitDepth: 8}, nil},
+		{"源为ICC时输出以CICP声明P3", p3ICC, &mediaprobe.Color{Primaries: 12, Transfer: 13, BitDepth: 8}, nil},
+		{"源为ICC时输出为sRGB", p3ICC, &mediaprobe.Color{Primaries: 1, Transfer: 13, BitDepth: 8}, []string{"广色域被转换为sRGB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := colorProblems(tt.source, tt.output)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("colorProblems = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyColorPreservedProbeFailure(t *testing.T) {
+	c := &Converter{
+		ctx:          context.Background(),
+		logger:       zap.NewNop(),
+		errorHandler: NewErrorHandler(zap.NewNop()),
+		prober:       mediaprobe.NewProber("", ""),
+	}
+	missing := filepath.Join(t.TempDir(), "missing.avif")
+	if err := c.verifyColorPreserved(nil, missing); err != nil {
+		t.Errorf("无需保留色彩时 err = %v", err)
+	}
+	if err := c.verifyColorPreserved(&mediaprobe.Color{Primaries: 9, Transfer: 16, BitDepth: 10}, missing); err == nil {
+		t.Error("无法探测输出色彩时应返回错误")
+	}
+}
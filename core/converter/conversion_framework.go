@@ -1,12 +1,16 @@
 package converter
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/qualitysearch"
 )
 
 // ConversionConfig 统一的转换配置
@@ -14,8 +18,9 @@ type ConversionConfig struct {
 	OutputExtension string
 	ToolPath        string
 	ArgsBuilder     func(input, output string, quality int) []string
-	PreProcessor    func(inputPath string) (processedPath string, cleanup func(), err error)
+	PreProcessor    func(ctx context.Context, job ToolJob, inputPath string) (processedPath string, cleanup func(), err error)
 	PostProcessor   func(outputPath string) error
+	SourceColor     *mediaprobe.Color // 需要保留的源文件色彩信息（HDR/广色域/高位深），非nil时验证输出
 }
 
 // ConversionFramework 统一的转换框架，消除重复代码
@@ -32,6 +37,9 @@ func NewConversionFramework(converter *Converter) *ConversionFramework {
 
 // Execute 执行统一的转换流程，消除所有特殊情况
 func (cf *ConversionFramework) Execute(file *MediaFile, config ConversionConfig, quality int) (string, error) {
+	ctx := cf.converter.fileContext(file)
+	job := cf.converter.toolJob(file)
+
 	// 1. 计算输出路径（统一逻辑）
 	outputPath := cf.converter.getOutputPath(file, config.OutputExtension)
 
@@ -43,7 +51,7 @@ func (cf *ConversionFramework) Execute(file *MediaFile, config ConversionConfig,
 	inputPath := file.Path
 	var cleanup func()
 	if config.PreProcessor != nil {
-		processedPath, cleanupFunc, err := config.PreProcessor(file.Path)
+		processedPath, cleanupFunc, err := config.PreProcessor(ctx, job, file.Path)
 		if err != nil {
 			return "", cf.converter.errorHandler.WrapError("preprocessing failed", err)
 		}
@@ -63,7 +71,7 @@ func (cf *ConversionFramework) Execute(file *MediaFile, config ConversionConfig,
 	args := config.ArgsBuilder(inputPath, actualOutputPath, quality)
 
 	// 6. 执行转换命令（统一逻辑）
-	output, err := cf.converter.toolManager.ExecuteWithPathValidation(config.ToolPath, args...)
+	output, err := cf.converter.toolManager.ExecuteWithPathValidation(ctx, job, config.ToolPath, args...)
 	if err != nil {
 		return "", cf.converter.errorHandler.WrapErrorWithOutput("conversion failed", err, output)
 	}
@@ -75,7 +83,12 @@ func (cf *ConversionFramework) Execute(file *MediaFile, config ConversionConfig,
 		}
 	}
 
-	// 8. 验证临时文件（在移动之前验证）
+	// 8. 验证色彩信息未被静默降为8位sRGB
+	if err := cf.converter.verifyColorPreserved(config.SourceColor, actualOutputPath); err != nil {
+		return "", err
+	}
+
+	// 9. 验证临时文件（在移动之前验证）
 	if !cf.converter.verifyOutputFile(actualOutputPath, file.Size) {
 		var errorBuilder strings.Builder
 		errorBuilder.WriteString("temp file verification failed: path: ")
@@ -83,7 +96,7 @@ func (cf *ConversionFramework) Execute(file *MediaFile, config ConversionConfig,
 		return "", cf.converter.errorHandler.WrapError(errorBuilder.String(), nil)
 	}
 
-	// 9. 移动临时文件到最终位置（统一逻辑）
+	// 10. 移动临时文件到最终位置（统一逻辑）
 	if err := cf.finalizeTempFile(actualOutputPath, outputPath); err != nil {
 		return "", err
 	}
@@ -143,45 +156,75 @@ func (cf *ConversionFramework) finalizeTempFile(actualOutputPath, outputPath str
 
 // 预定义的转换配置，消除重复的参数构建逻辑
 
-// JXLConfig JXL转换配置
-func (cf *ConversionFramework) JXLConfig() ConversionConfig {
+// JXLConfig JXL转换配置，color为需要保留的源文件色彩信息（可为nil），profile为编码参数（全景照片更慢更充分）
+func (cf *ConversionFramework) JXLConfig(color *mediaprobe.Color, profile encoderProfile) ConversionConfig {
 	return ConversionConfig{
 		OutputExtension: ".jxl",
 		ToolPath:        cf.converter.config.Tools.CjxlPath,
 		ArgsBuilder: func(input, output string, quality int) []string {
-			// 使用无损参数进行JXL转换
-			return []string{
+			// 质量100为数学无损，其余映射为cjxl的distance
+			distance := strconv.FormatFloat(qualitysearch.JXLDistance(quality), 'f', 2, 64)
+			args := []string{
 				input,
 				output,
-				"--distance=0",  // 无损转换
-				"-e", "9",       // 最高压缩效率
+				"--distance=" + distance,
 			}
+			args = append(args, profile.jxlEffortArgs()...)
+			return append(args, jxlColorArgs(color)...)
 		},
-		PreProcessor: cf.universalToJXLPreProcessor,
+		PreProcessor: func(ctx context.Context, job ToolJob, inputPath string) (string, func(), error) {
+			return cf.universalToJXLPreProcessor(ctx, job, inputPath, color)
+		},
+		SourceColor: color,
 	}
 }
 
-// AVIFConfig AVIF转换配置
-func (cf *ConversionFramework) AVIFConfig() ConversionConfig {
+// AVIFConfig AVIF转换配置，color为需要保留的源文件色彩信息（可为nil），profile为编码速度与分块参数
+func (cf *ConversionFramework) AVIFConfig(color *mediaprobe.Color, profile encoderProfile) ConversionConfig {
 	return ConversionConfig{
 		OutputExtension: ".avif",
 		ToolPath:        cf.converter.config.Tools.AvifencPath,
 		ArgsBuilder: func(input, output string, quality int) []string {
-			return []string{
-				// 修复参数：使用--qcolor而不是-q，并调整参数顺序
-				"--qcolor", strconv.Itoa(quality),
-				"-s", "4",
-				"-j", "all",
-				input,
-				output,
-			}
+			// 修复参数：使用--qcolor而不是-q，并调整参数顺序
+			args := []string{"--qcolor", strconv.Itoa(quality)}
+			args = append(args, profile.avifArgs()...)
+			args = append(args, avifColorArgs(color, false)...)
+			return append(args, input, output)
 		},
-		PreProcessor: cf.universalToAVIFPreProcessor,
+		PreProcessor: func(ctx context.Context, job ToolJob, inputPath string) (string, func(), error) {
+			return cf.universalToAVIFPreProcessor(ctx, job, inputPath, color)
+		},
+		SourceColor: color,
+	}
+}
+
+// AVIFLosslessConfig 数学无损的AVIF转换配置：avifenc --lossless 以YUV 4:4:4与恒等矩阵直接保存RGB，忽略品质参数
+func (cf *ConversionFramework) AVIFLosslessConfig(color *mediaprobe.Color, profile encoderProfile) ConversionConfig {
+	config := cf.AVIFConfig(color, profile)
+	config.ArgsBuilder = func(input, output string, quality int) []string {
+		args := append([]string{"--lossless"}, profile.avifArgs()...)
+		args = append(args, avifColorArgs(color, true)...)
+		return append(args, input, output)
 	}
+	return config
 }
 
-// universalToAVIFPreProcessor 通用AVIF预处理器，处理avifenc不兼容的格式
-func (cf *ConversionFramework) universalToAVIFPreProcessor(inputPath string) (string, func(), error) {
+// GainMapAVIFConfig 保留增益图的AVIF转换配置：JPEG不经中间PNG（会丢失增益图）直接交给avifenc，
+// 输出缺少增益图时转换失败
+func (cf *ConversionFramework) GainMapAVIFConfig(color *mediaprobe.Color, profile encoderProfile, gainMapFlags []string) ConversionConfig {
+	config := cf.AVIFConfig(color, profile)
+	buildArgs := config.ArgsBuilder
+	config.ArgsBuilder = func(input, output string, quality int) []string {
+		args := append([]string{}, gainMapFlags...)
+		return append(args, buildArgs(input, output, quality)...)
+	}
+	config.PreProcessor = nil
+	config.PostProcessor = cf.converter.verifyGainMapPreserved
+	return config
+}
+
+// universalToAVIFPreProcessor 通用AVIF预处理器，处理avifenc不兼容的格式（高位深源保持16位PNG）
+func (cf *ConversionFramework) universalToAVIFPreProcessor(ctx context.Context, job ToolJob, inputPath string, color *mediaprobe.Color) (string, func(), error) {
 	ext := strings.ToLower(filepath.Ext(inputPath))
 
 	// 需要预处理的格式列表
@@ -211,9 +254,10 @@ func (cf *ConversionFramework) universalToAVIFPreProcessor(inputPath string) (st
 		args = append(args, "-vframes", "1")
 	}
 
+	args = append(args, intermediatePNGArgs(color, cf.converter.hasTransparency(inputPath))...)
 	args = append(args, "-c:v", "png", "-y", tempFile)
 
-	output, err := cf.converter.toolManager.ExecuteWithPathValidation(cf.converter.config.Tools.FFmpegPath, args...)
+	output, err := cf.converter.toolManager.ExecuteWithPathValidation(ctx, job, cf.converter.config.Tools.FFmpegPath, args...)
 	if err != nil {
 		os.Remove(tempFile)
 		var errorBuilder strings.Builder
@@ -230,7 +274,7 @@ func (cf *ConversionFramework) universalToAVIFPreProcessor(inputPath string) (st
 }
 
 // universalToJXLPreProcessor 通用JXL预处理器：处理JXL编码器不直接支持的静态GIF（取第一帧转PNG）
-func (cf *ConversionFramework) universalToJXLPreProcessor(inputPath string) (string, func(), error) {
+func (cf *ConversionFramework) universalToJXLPreProcessor(ctx context.Context, job ToolJob, inputPath string, color *mediaprobe.Color) (string, func(), error) {
 	ext := strings.ToLower(filepath.Ext(inputPath))
 
 	// 目前仅对 GIF 进行预处理（提取第一帧为 PNG）
@@ -246,11 +290,11 @@ func (cf *ConversionFramework) universalToJXLPreProcessor(inputPath string) (str
 	args := []string{
 		"-i", inputPath,
 		"-vframes", "1",
-		"-c:v", "png",
-		"-y", tempFile,
 	}
+	args = append(args, intermediatePNGArgs(color, cf.converter.hasTransparency(inputPath))...)
+	args = append(args, "-c:v", "png", "-y", tempFile)
 
-	output, err := cf.converter.toolManager.ExecuteWithPathValidation(cf.converter.config.Tools.FFmpegPath, args...)
+	output, err := cf.converter.toolManager.ExecuteWithPathValidation(ctx, job, cf.converter.config.Tools.FFmpegPath, args...)
 	if err != nil {
 		_ = os.Remove(tempFile)
 		return "", nil, cf.converter.errorHandler.WrapErrorWithOutput("GIF to PNG conversion failed", err, output)
@@ -2,9 +2,10 @@ package converter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,7 +15,16 @@ import (
 	"pixly/config"
 	"pixly/internal/theme"
 	"pixly/internal/ui"
-
+	"pixly/pkg/contentcache"
+	"pixly/pkg/errorhandling"
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/perceptual"
+	"pixly/pkg/processmonitor"
+	"pixly/pkg/scheduler"
+	"pixly/pkg/videoquality"
+	"pixly/pkg/whitelist"
+
+	"go.etcd.io/bbolt"
 	"go.uber.org/zap"
 )
 
@@ -42,6 +52,26 @@ type MediaFile struct {
 	IsCodecIncompatible     bool
 	IsContainerIncompatible bool
 	SkipReason             string // 跳过原因，用于记录为何跳过此文件
+	// 被采用的有损候选的感知质量评分（无损转换时为空）
+	QualityMetric string
+	QualityScore  float64
+	// 被采用的有损质量设置（0表示无损），用于输出模板{quality}
+	QualitySetting int
+
+	isProbe bool            // 探测阶段的临时链接文件
+	route   *Route          // 执行计划时预先确定的路由决策
+	ctx     context.Context // 单文件转换调用方的上下文，取消时终止该文件的编码器进程；为nil时使用转换器的上下文
+	job     *ToolJob        // 执行中路由的工具进程参数（内存准入与线程数）；未在执行时为nil
+
+	livePhoto *livePhotoPair // Live Photo配对（静态图与MOV共享），未配对时为nil
+	burst     *burstGroup    // 连拍分组（组内各帧共享），未分组时为nil
+
+	// 内容缓存：形态与品质分析结果在一次运行内复用，并跨运行持久化
+	details     *MediaDetails
+	metrics     *ImageQualityMetrics
+	cacheEntry  *contentcache.Entry
+	cacheLoaded bool
+	cacheHit    bool
 }
 
 // ConversionMode 转换模式枚举
@@ -78,8 +108,11 @@ type ConversionResult struct {
 	Success          bool
 	Method           string
 	Error            error
-	Skipped          bool   // 文件是否被跳过
-	SkipReason       string // 跳过原因
+	Skipped          bool                   // 文件是否被跳过
+	SkipReason       string                 // 跳过原因
+	SkipCategory     whitelist.SkipCategory // 跳过类别，报告按类别列出跳过的文件
+	QualityMetric    string                 // 感知质量指标（仅有损转换）
+	QualityScore     float64                // 感知质量评分
 }
 
 // Converter 转换器主结构
@@ -96,11 +129,47 @@ type Converter struct {
 	metadataManager  *MetadataManager
 	toolManager      *ToolManager
 	fileTypeDetector *FileTypeDetector
+	prober           *mediaprobe.Prober // 每个文件一份的共享探测结果
 	checkpointMgr    *CheckpointManager
+	sessionStore     *SessionStore
+	eventSink        EventSink // 机器可读事件流（未启用时为nil）
 	signalHandler    *SignalHandler
 	fileOpHandler    *FileOperationHandler // 统一文件操作处理器
 	errorHandler     *ErrorHandler         // 统一错误处理器
 	memoryPool       *MemoryPool           // 内存池
+	perceptualScorer *perceptual.Scorer    // 感知质量评分器（未启用时为nil）
+
+	// 转换失败后的重试与后备转换（未启用时为nil）
+	recovery *errorhandling.ErrorRecoveryManager
+
+	// 按编码器拟合的处理耗时模型，样本跨目录、跨运行保存在全局状态数据库中，用于估计剩余时间与文件超时
+	timing *processmonitor.TimingModel
+
+	// 全局状态数据库（耗时样本），转换开始时打开，未能打开时为nil
+	stateDB *bbolt.DB
+
+	// 编码任务的内存调度：按估算峰值RSS在内存预算内准入
+	memoryScheduler *scheduler.MemoryScheduler
+
+	// 编码线程分配：按队列组成为每个任务决定编码器线程数
+	threadPlanner *scheduler.ThreadPlanner
+
+	// ffmpeg编码器与libvmaf支持（首次重新编码时查询）
+	videoCaps     *videoquality.Capabilities
+	videoCapsOnce sync.Once
+
+	// avifenc增益图支持（首次遇到增益图照片时查询）
+	avifGainMapCaps *avifGainMapSupport
+	avifGainMapOnce sync.Once
+
+	// 输出路径
+	inputRoot   string              // 输入根目录，用于计算{relpath}
+	outputPaths *outputPathRegistry // 输出路径登记表（冲突处理）
+	outputMutex sync.Mutex
+
+	// 持久化内容缓存（未启用时为nil）
+	resultCache *contentcache.Cache
+	settingsKey string // 转换设置指纹
 
 	// 增强系统组件已删除 - 根据"好品味"原则，删除过度设计的复杂日志系统
 
@@ -137,7 +206,8 @@ func NewConverter(config *config.Config, logger *zap.Logger, mode string) (*Conv
 	// 创建高级ants池配置
 	advancedPoolConfig := GetDefaultAdvancedPoolConfig()
 	advancedPoolConfig.InitialSize = config.Concurrency.ConversionWorkers
-	advancedPoolConfig.MaxSize = config.Concurrency.ConversionWorkers * 2
+	poolMaxSize := poolSizing(config.Concurrency.ConversionWorkers)
+	advancedPoolConfig.MaxSize = poolMaxSize
 	advancedPoolConfig.MinSize = 2
 	advancedPoolConfig.EnablePriority = true
 	advancedPoolConfig.EnableMetrics = true
@@ -149,6 +219,10 @@ func NewConverter(config *config.Config, logger *zap.Logger, mode string) (*Conv
 		return nil, fmt.Errorf("创建高级ants池失败: %w", err)
 	}
 
+	// 共享探测缓存：各分析阶段复用同一份头部、FFprobe与exiftool结果
+	toolManager := NewToolManager(config, logger, errorHandler)
+	prober := newProber(config, toolManager)
+
 	converter := &Converter{
 		config:           config,
 		logger:           logger,
@@ -158,12 +232,16 @@ func NewConverter(config *config.Config, logger *zap.Logger, mode string) (*Conv
 		results:          make([]*ConversionResult, 0),
 		watchdog:         watchdog,
 		atomicOps:        NewAtomicFileOperations(logger, config, errorHandler),
-		metadataManager:  NewMetadataManager(logger, config, errorHandler),
-		toolManager:      NewToolManager(config, logger, errorHandler),
-		fileTypeDetector: NewFileTypeDetector(config, logger, NewToolManager(config, logger, errorHandler)),
+		metadataManager:  NewMetadataManager(logger, config, errorHandler, prober),
+		toolManager:      toolManager,
+		fileTypeDetector: NewFileTypeDetector(config, logger, toolManager, prober),
+		prober:           prober,
 		errorHandler:     errorHandler,
 		fileOpHandler:    fileOpHandler,
 		memoryPool:       GetGlobalMemoryPool(logger),
+		perceptualScorer: newPerceptualScorer(config, logger),
+		outputPaths:      newOutputPathRegistry(),
+		resultCache:      newResultCache(config, logger),
 		ctx:              ctx,
 		cancel:           cancel,
 		advancedPool:     advancedPool,
@@ -171,19 +249,18 @@ func NewConverter(config *config.Config, logger *zap.Logger, mode string) (*Conv
 
 	// 创建转换策略
 	converter.strategy = NewStrategy(converter.mode, converter)
+	converter.recovery = newRecoveryManager(converter)
+	converter.timing = processmonitor.NewTimingModel(logger)
+	converter.memoryScheduler = newMemoryScheduler(converter)
+	converter.threadPlanner = newThreadPlanner(converter, poolMaxSize)
 
 	// 移除传统channel池，统一使用高级ants池
 
-	// 初始化checkpoint管理器
-	checkpointMgr, err := NewCheckpointManager(logger, "", errorHandler)
-	if err != nil {
-		advancedPool.Close() // 清理高级ants池
-		return nil, errorHandler.WrapError("初始化checkpoint管理器失败", err)
-	}
-	converter.checkpointMgr = checkpointMgr
+	// 会话存储：检查点数据库在转换开始时按目标目录打开
+	converter.sessionStore = NewSessionStore(logger, config.State.Dir, errorHandler)
 
 	// 初始化信号处理器
-	signalHandler := NewSignalHandler(logger, converter, checkpointMgr)
+	signalHandler := NewSignalHandler(logger, converter, nil)
 	converter.signalHandler = signalHandler
 
 	// 启动看门狗
@@ -211,7 +288,8 @@ func NewConverterWithWatchdog(config *config.Config, logger *zap.Logger, mode st
 	// 创建高级ants池配置
 	advancedPoolConfig := GetDefaultAdvancedPoolConfig()
 	advancedPoolConfig.InitialSize = config.Concurrency.ConversionWorkers
-	advancedPoolConfig.MaxSize = config.Concurrency.ConversionWorkers * 2
+	poolMaxSize := poolSizing(config.Concurrency.ConversionWorkers)
+	advancedPoolConfig.MaxSize = poolMaxSize
 	advancedPoolConfig.MinSize = 2
 	advancedPoolConfig.EnablePriority = true
 	advancedPoolConfig.EnableMetrics = true
@@ -223,6 +301,10 @@ func NewConverterWithWatchdog(config *config.Config, logger *zap.Logger, mode st
 		return nil, fmt.Errorf("创建高级ants池失败: %w", err)
 	}
 
+	// 共享探测缓存：各分析阶段复用同一份头部、FFprobe与exiftool结果
+	toolManager := NewToolManager(config, logger, errorHandler)
+	prober := newProber(config, toolManager)
+
 	converter := &Converter{
 		config:           config,
 		logger:           logger,
@@ -232,12 +314,16 @@ func NewConverterWithWatchdog(config *config.Config, logger *zap.Logger, mode st
 		results:          make([]*ConversionResult, 0),
 		watchdog:         watchdog,
 		atomicOps:        NewAtomicFileOperations(logger, config, errorHandler),
-		metadataManager:  NewMetadataManager(logger, config, errorHandler),
-		toolManager:      NewToolManager(config, logger, errorHandler),
-		fileTypeDetector: NewFileTypeDetector(config, logger, NewToolManager(config, logger, errorHandler)),
+		metadataManager:  NewMetadataManager(logger, config, errorHandler, prober),
+		toolManager:      toolManager,
+		fileTypeDetector: NewFileTypeDetector(config, logger, toolManager, prober),
+		prober:           prober,
 		errorHandler:     errorHandler,
 		fileOpHandler:    fileOpHandler,
 		memoryPool:       GetGlobalMemoryPool(logger),
+		perceptualScorer: newPerceptualScorer(config, logger),
+		outputPaths:      newOutputPathRegistry(),
+		resultCache:      newResultCache(config, logger),
 		ctx:              ctx,
 		cancel:           cancel,
 		advancedPool:     advancedPool,
@@ -245,17 +331,16 @@ func NewConverterWithWatchdog(config *config.Config, logger *zap.Logger, mode st
 
 	// 创建转换策略
 	converter.strategy = NewStrategy(converter.mode, converter)
+	converter.recovery = newRecoveryManager(converter)
+	converter.timing = processmonitor.NewTimingModel(logger)
+	converter.memoryScheduler = newMemoryScheduler(converter)
+	converter.threadPlanner = newThreadPlanner(converter, poolMaxSize)
 
-	// 初始化checkpoint管理器
-	checkpointMgr, err := NewCheckpointManager(logger, "", errorHandler)
-	if err != nil {
-		advancedPool.Close() // 清理高级ants池
-		return nil, errorHandler.WrapError("初始化checkpoint管理器失败", err)
-	}
-	converter.checkpointMgr = checkpointMgr
+	// 会话存储：检查点数据库在转换开始时按目标目录打开
+	converter.sessionStore = NewSessionStore(logger, config.State.Dir, errorHandler)
 
 	// 初始化信号处理器
-	signalHandler := NewSignalHandler(logger, converter, checkpointMgr)
+	signalHandler := NewSignalHandler(logger, converter, nil)
 	converter.signalHandler = signalHandler
 
 	// 启动看门狗
@@ -271,6 +356,14 @@ func (c *Converter) GetWorkerPool() *AdvancedPool {
 
 // Convert 执行转换操作
 func (c *Converter) Convert(inputDir string) error {
+	c.setInputRoot(inputDir)
+	c.settingsKey = c.settingsFingerprint()
+
+	// 打开目标目录的检查点数据库
+	if err := c.openCheckpoint(c.inputRoot); err != nil {
+		return c.errorHandler.WrapError("打开检查点数据库失败", err)
+	}
+
 	// 启动信号处理器
 	c.signalHandler.Start()
 	defer c.signalHandler.Stop()
@@ -282,7 +375,7 @@ func (c *Converter) Convert(inputDir string) error {
 	} else if len(sessions) > 0 {
 		// 找到未完成的会话，询问用户是否恢复
 		for _, session := range sessions {
-			if session.TargetDir == inputDir && session.Mode == string(c.mode) {
+			if session.TargetDir == c.inputRoot && session.Mode == string(c.mode) {
 				// 发现未完成的转换会话
 
 				// 恢复会话
@@ -310,6 +403,7 @@ func (c *Converter) Convert(inputDir string) error {
 
 	// 创建批处理器
 	batchProcessor := NewBatchProcessor(c, c.logger)
+	c.emitScanStarted()
 
 	// 使用批处理器进行统一扫描和分析
 	if err := batchProcessor.ScanAndAnalyze(inputDir); err != nil {
@@ -319,11 +413,12 @@ func (c *Converter) Convert(inputDir string) error {
 	// 检查是否有文件需要处理
 	if c.stats.TotalFiles == 0 {
 		c.logger.Info("没有找到需要转换的文件")
+		c.emitSessionSummary()
 		return nil
 	}
 
 	// 启动新的转换会话
-	err = c.checkpointMgr.StartSession(inputDir, string(c.mode), c.stats.TotalFiles)
+	err = c.checkpointMgr.StartSession(c.inputRoot, string(c.mode), c.stats.TotalFiles)
 	if err != nil {
 		return c.errorHandler.WrapError("启动转换会话失败", err)
 	}
@@ -339,6 +434,12 @@ func (c *Converter) Convert(inputDir string) error {
 		return c.errorHandler.WrapError("处理任务队列失败", err)
 	}
 
+	c.finishSession()
+	return nil
+}
+
+// finishSession 等待任务完成，生成报告并清理会话
+func (c *Converter) finishSession() {
 	// 等待所有goroutine完成
 	c.wg.Wait()
 
@@ -352,6 +453,9 @@ func (c *Converter) Convert(inputDir string) error {
 		c.logger.Error("生成报告失败", zap.Error(err))
 	}
 
+	// 会话清理前发送汇总，保留会话ID
+	c.emitSessionSummary()
+
 	// 清理会话
 	sessionInfo := c.checkpointMgr.GetSessionInfo()
 	if sessionInfo != nil {
@@ -360,9 +464,62 @@ func (c *Converter) Convert(inputDir string) error {
 		}
 	}
 
+	// 撤销日志按保留期清理（不随会话清理）
+	c.pruneJournal()
+}
+
+// openCheckpoint 打开目标目录的检查点数据库；同一转换器处理其他目录时切换数据库
+func (c *Converter) openCheckpoint(targetDir string) error {
+	dbPath := c.sessionStore.DBPath(targetDir)
+	if c.checkpointMgr != nil {
+		if c.checkpointMgr.DBPath() == dbPath {
+			return nil
+		}
+		if err := c.checkpointMgr.Close(); err != nil {
+			c.logger.Warn("关闭checkpoint管理器失败", zap.Error(err))
+		}
+		c.checkpointMgr = nil
+	}
+
+	checkpointMgr, err := c.sessionStore.Open(targetDir)
+	if errors.Is(err, ErrCheckpointBusy) {
+		return fmt.Errorf("该目录已有转换会话正在运行: %s: %w", targetDir, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.checkpointMgr = checkpointMgr
+	c.signalHandler.setCheckpoint(checkpointMgr)
+
+	// 耗时与目标目录无关，样本保存在全局状态数据库中
+	c.attachTimingState()
 	return nil
 }
 
+// Resume 恢复指定的未完成会话：重新扫描会话的目标目录，跳过会话中已完成的文件
+func (c *Converter) Resume(sessionID string) error {
+	session, err := c.sessionStore.FindSession(sessionID)
+	if err != nil {
+		return c.errorHandler.WrapError("查找会话失败", err)
+	}
+	if session.Mode != string(c.mode) {
+		return fmt.Errorf("会话模式与当前模式不一致: %s", session.Mode)
+	}
+
+	if err := c.openCheckpoint(session.TargetDir); err != nil {
+		return c.errorHandler.WrapError("打开检查点数据库失败", err)
+	}
+
+	c.signalHandler.Start()
+	defer c.signalHandler.Stop()
+
+	if _, err := c.checkpointMgr.ResumeSession(sessionID); err != nil {
+		return c.errorHandler.WrapError("恢复会话失败", err)
+	}
+	return c.resumeConversion(session.TargetDir)
+}
+
 // checkPathPermissions 检查路径权限和白名单
 func (c *Converter) checkPathPermissions(inputDir string) error {
 	// 创建路径安全检查器
@@ -399,6 +556,9 @@ func (c *Converter) scanFiles(inputDir string) ([]*MediaFile, error) {
 			return err
 		}
 		if d.IsDir() {
+			if d.Name() == OriginalsDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if !c.isMediaFile(path) {
@@ -493,12 +653,43 @@ func (c *Converter) getFileType(filePath string) MediaType {
 	}
 }
 
+// fileContext 返回文件转换使用的上下文：调用方传入的上下文，未指定时为转换器的上下文
+func (c *Converter) fileContext(file *MediaFile) context.Context {
+	if file != nil && file.ctx != nil {
+		return file.ctx
+	}
+	return c.ctx
+}
+
+// fileTaskPriority 确定文件任务的优先级（大文件优先处理）
+func fileTaskPriority(file *MediaFile) TaskPriority {
+	if file.Size > 50*1024*1024 { // 50MB以上的文件
+		return PriorityHigh
+	}
+	if file.Size < 1024*1024 { // 1MB以下的文件
+		return PriorityLow
+	}
+	return PriorityNormal
+}
+
+// fileTaskID 生成高级池中文件任务的ID
+func fileTaskID(file *MediaFile) string {
+	var taskIDBuilder strings.Builder
+	taskIDBuilder.WriteString("file_")
+	taskIDBuilder.WriteString(filepath.Base(file.Path))
+	taskIDBuilder.WriteString("_")
+	taskIDBuilder.WriteString(strconv.FormatInt(time.Now().UnixNano(), 10))
+	return taskIDBuilder.String()
+}
+
 // processFiles 处理文件
 func (c *Converter) processFiles(files []*MediaFile) error {
 	// 注意：统计信息已由BatchProcessor在ScanAndAnalyze阶段设置
 	// 启动转换进度条
 	ui.StartDynamicProgress(int64(len(files)), "转换处理")
 	defer ui.FinishDynamicProgress()
+	eta := c.newETATracker(files)
+	c.queueThreadPlan(files)
 	// 开始处理文件
 
 	// 创建结果通道
@@ -509,29 +700,17 @@ func (c *Converter) processFiles(files []*MediaFile) error {
 		c.wg.Add(1)
 		file := file // 避免闭包问题
 
-		// 确定任务优先级（大文件优先处理）
-		priority := PriorityNormal
-		if file.Size > 50*1024*1024 { // 50MB以上的文件
-			priority = PriorityHigh
-		} else if file.Size < 1024*1024 { // 1MB以下的文件
-			priority = PriorityLow
-		}
-
 		// 使用统一的高级池进行并发控制
-		var taskIDBuilder strings.Builder
-		taskIDBuilder.WriteString("file_")
-		taskIDBuilder.WriteString(filepath.Base(file.Path))
-		taskIDBuilder.WriteString("_")
-		taskIDBuilder.WriteString(strconv.FormatInt(time.Now().UnixNano(), 10))
-		taskID := taskIDBuilder.String()
 		err := c.advancedPool.SubmitWithPriority(func() {
 			defer c.wg.Done()
+			defer c.threadPlanner.Release(file.Path) // 跳过或分析失败的文件不再计入队列组成
 			result := c.processFile(file)
 			resultChan <- result
-		}, priority, taskID)
+		}, fileTaskPriority(file), fileTaskID(file))
 
 		if err != nil {
 			c.wg.Done() // 如果提交失败，需要减少计数器
+			c.threadPlanner.Release(file.Path) // 未提交的文件不再计入队列组成
 			c.logger.Error("提交任务到高级池失败", zap.String("file", file.Path), zap.Error(err))
 			// 创建失败结果
 			result := &ConversionResult{
@@ -568,7 +747,7 @@ func (c *Converter) processFiles(files []*MediaFile) error {
 
 		// 更新进度
 		processedCount++
-		ui.UpdateDynamicProgress(int64(processedCount), "转换处理")
+		ui.UpdateDynamicProgress(int64(processedCount), "转换处理"+eta.Done(snapshot.OriginalFile.Path))
 
 		// 记录处理结果
 		if snapshot.Success {
@@ -594,9 +773,11 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 
 	c.logger.Debug("开始处理文件", zap.String("file", file.Path), zap.String("type", string(file.Type)))
 
-	// 标记文件开始处理
-	if err := c.checkpointMgr.UpdateFileStatus(file.Path, StatusProcessing, "", ""); err != nil {
-		c.logger.Warn("更新文件状态失败", zap.String("file", file.Path), zap.Error(err))
+	// 标记文件开始处理（单文件转换没有检查点会话）
+	if c.checkpointMgr != nil {
+		if err := c.checkpointMgr.UpdateFileStatus(file.Path, StatusProcessing, "", ""); err != nil {
+			c.logger.Warn("更新文件状态失败", zap.String("file", file.Path), zap.Error(err))
+		}
 	}
 
 	// 从内存池获取ConversionResult对象
@@ -624,12 +805,28 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 			}
 		}
 
-		if err := c.checkpointMgr.UpdateFileStatus(file.Path, status, errorMsg, result.OutputPath); err != nil {
-			c.logger.Warn("保存文件最终状态失败", zap.String("file", file.Path), zap.Error(err))
+		if c.checkpointMgr != nil {
+			if err := c.checkpointMgr.UpdateFileStatus(file.Path, status, errorMsg, result.OutputPath); err != nil {
+				c.logger.Warn("保存文件最终状态失败", zap.String("file", file.Path), zap.Error(err))
+			}
 		}
 
+		// 记录分析结果与最终结果，下次运行可直接跳过
+		c.storeCacheResult(file, result)
+
+		// 原地转换处理原文件：记录撤销日志，不保留时移入备份目录或删除
+		c.finalizeOriginal(file, result)
+
+		// 记录输出来源并移除登记表条目
+		c.recordOutputSource(file, result)
+		c.releaseOutputPaths(file)
+
+		// 文件处理完毕，释放其共享探测结果
+		c.prober.Forget(file.Path)
+
 		// 更新统计信息
 		c.UpdateStats(result)
+		c.emitResult(result)
 
 		c.logger.Debug("文件处理完成",
 			zap.String("file", file.Path),
@@ -638,36 +835,30 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 			zap.Error(result.Error))
 	}()
 
-	// 使用文件类型检测器精确识别文件类型
-	if c.fileTypeDetector != nil {
-		c.logger.Debug("开始文件类型检测", zap.String("file", file.Path))
-		details, err := c.fileTypeDetector.DetectFileType(file.Path)
-		if err == nil && !details.IsCorrupted {
-			// 根据精确的文件类型更新文件信息
-			switch details.FileType {
-			case FileTypeVideo:
-				file.Type = TypeVideo
-				c.logger.Debug("检测为视频文件", zap.String("file", file.Path))
-			// 音频文件不再支持，跳过处理
-			case FileTypeAnimatedImage:
-				file.Type = TypeImage
-				c.logger.Debug("检测为动图文件", zap.String("file", file.Path))
-				// 标记为动图
-				// 这里可以添加额外的标记逻辑
-			case FileTypeStaticImage:
-				file.Type = TypeImage
-				c.logger.Debug("检测为静态图片文件", zap.String("file", file.Path))
-			}
-		} else if err != nil {
-			c.logger.Warn("文件类型检测失败", zap.String("file", file.Path), zap.Error(err))
-		} else if details.IsCorrupted {
-			c.logger.Warn("检测到损坏文件", zap.String("file", file.Path))
-		}
+	// 已使用相同设置处理过的未变更文件直接跳过
+	if c.applyCachedOutcome(file, result) {
+		return result
+	}
+
+	// 创作源文件不做类型检测，也不交给任何编码器
+	if c.applyCreativeSkip(file, result) {
+		return result
 	}
 
+	// 输出会超出输出目录的文件直接失败，不计算输出路径
+	if err := c.checkOutputScope(file); err != nil {
+		result.Error = err
+		result.Success = false
+		return result
+	}
+
+	// 使用文件类型检测器精确识别文件类型
+	c.refineFileType(file)
+
 	// 直接处理文件，避免不必要的goroutine创建
 	// 根据文件类型分发处理逻辑
 	c.logger.Debug("开始文件类型分发处理", zap.String("file", file.Path), zap.String("type", string(file.Type)))
+	var route Route
 	switch file.Type {
 	case TypeImage:
 		c.logger.Debug("开始图片转换处理", zap.String("file", file.Path))
@@ -675,8 +866,11 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 		var err error
 		var outputPath string
 
-		// 调用对应的转换策略
-		outputPath, err = c.strategy.ConvertImage(file)
+		// 调用对应的转换策略（执行计划时使用计划中的路由）
+		route = c.routeFile(file)
+		c.emitAssessed(file, route)
+		result.Method = string(route.Action)
+		outputPath, err = c.executeWithRecovery(file, route, result)
 		if err != nil {
 			c.logger.Error("图片转换失败", zap.String("file", file.Path), zap.Error(err))
 			result.Error = c.errorHandler.WrapError("图片转换失败", err)
@@ -684,6 +878,8 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 		} else {
 			c.logger.Debug("图片转换成功", zap.String("file", file.Path), zap.String("output", outputPath))
 			result.OutputPath = outputPath
+			result.QualityMetric = file.QualityMetric
+			result.QualityScore = file.QualityScore
 			// 获取实际转换后的文件大小
 			if stat, err := os.Stat(outputPath); err == nil {
 				result.CompressedSize = stat.Size()
@@ -699,7 +895,10 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 	case TypeVideo:
 		c.logger.Debug("开始视频转换处理", zap.String("file", file.Path))
 		// 使用策略模式处理视频转换
-		outputPath, err := c.strategy.ConvertVideo(file)
+		route = c.routeFile(file)
+		c.emitAssessed(file, route)
+		result.Method = string(route.Action)
+		outputPath, err := c.executeWithRecovery(file, route, result)
 		if err != nil {
 			c.logger.Error("视频转换失败", zap.String("file", file.Path), zap.Error(err))
 			result.Error = c.errorHandler.WrapError("视频转换失败", err)
@@ -707,6 +906,8 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 		} else {
 			c.logger.Debug("视频转换成功", zap.String("file", file.Path), zap.String("output", outputPath))
 			result.OutputPath = outputPath
+			result.QualityMetric = file.QualityMetric
+			result.QualityScore = file.QualityScore
 			// 获取实际转换后的文件大小
 			if stat, err := os.Stat(outputPath); err == nil {
 				result.CompressedSize = stat.Size()
@@ -724,14 +925,73 @@ func (c *Converter) processFile(file *MediaFile) *ConversionResult {
 		errorBuilder.WriteString("不支持的文件类型: ")
 		errorBuilder.WriteString(string(file.Type))
 		c.logger.Error("不支持的文件类型", zap.String("file", file.Path), zap.String("type", string(file.Type)))
-		result.Error = c.errorHandler.WrapError(errorBuilder.String(), nil)
+		result.Error = errors.New(errorBuilder.String())
 		result.Success = false
 		// 不支持的文件类型
 	}
 
+	// 跳过路由保持原文件，记录为跳过而非转换
+	if result.Success && route.Action == ActionSkip {
+		result.Skipped = true
+		result.CompressedSize = result.OriginalSize
+		result.SkipReason = route.Reason
+		result.SkipCategory = whitelist.SkipStrategy
+	}
+
+	// RAW预览是新增的旁车文件，RAW保持原样，输出体积计入两者
+	if result.Success && route.Action == ActionRawPreview {
+		result.CompressedSize += result.OriginalSize
+	}
+
+	// Live Photo：静态图处理完成后处理配对的视频；连拍动图：处理已合并的其余帧
+	c.processLivePhotoCompanion(file, result)
+	c.processBurstCompanions(file, result)
+
 	return result
 }
 
+// refineFileType 使用文件类型检测器精确识别文件类型并更新文件信息
+func (c *Converter) refineFileType(file *MediaFile) {
+	if c.fileTypeDetector == nil {
+		return
+	}
+	c.logger.Debug("开始文件类型检测", zap.String("file", file.Path))
+	details, err := c.detectFileType(file)
+	if err == nil && !details.IsCorrupted {
+		// 根据精确的文件类型更新文件信息
+		switch details.FileType {
+		case FileTypeVideo:
+			file.Type = TypeVideo
+			c.logger.Debug("检测为视频文件", zap.String("file", file.Path))
+		// 音频文件不再支持，跳过处理
+		case FileTypeAnimatedImage:
+			file.Type = TypeImage
+			c.logger.Debug("检测为动图文件", zap.String("file", file.Path))
+			// 标记为动图
+			// 这里可以添加额外的标记逻辑
+		case FileTypeStaticImage:
+			file.Type = TypeImage
+			c.logger.Debug("检测为静态图片文件", zap.String("file", file.Path))
+		case FileTypeLivePhoto:
+			file.Type = TypeVideo
+			c.logger.Debug("检测为Live Photo视频", zap.String("file", file.Path))
+		case FileTypePanorama, FileTypeBurstPhoto:
+			file.Type = TypeImage
+			c.logger.Debug("检测为全景或连拍照片", zap.String("file", file.Path), zap.String("type", string(details.FileType)))
+		case FileTypeGainMap:
+			file.Type = TypeImage
+			c.logger.Debug("检测为带增益图的HDR照片", zap.String("file", file.Path), zap.String("gain_map", details.GainMap))
+		case FileTypeRaw:
+			file.Type = TypeImage
+			c.logger.Debug("检测为相机RAW文件", zap.String("file", file.Path), zap.String("raw", details.Raw))
+		}
+	} else if err != nil {
+		c.logger.Warn("文件类型检测失败", zap.String("file", file.Path), zap.Error(err))
+	} else if details.IsCorrupted {
+		c.logger.Warn("检测到损坏文件", zap.String("file", file.Path))
+	}
+}
+
 // UpdateStats 更新统计信息
 func (c *Converter) UpdateStats(result *ConversionResult) {
 	c.mutex.Lock()
@@ -783,8 +1043,26 @@ func (c *Converter) IsTargetFormat(ext string) bool {
 	}
 }
 
+// isTargetFile 检查文件是否已是目标格式；视频重新编码模式下只有已是高效编码的MOV才算目标格式，
+// 其他编码的MOV（如iPhone拍摄的H.264视频）仍需重新编码。无法探测编码时按目标格式跳过
+func (c *Converter) isTargetFile(path, ext string) bool {
+	if !c.IsTargetFormat(ext) {
+		return false
+	}
+	if strings.ToLower(ext) != ".mov" || c.config.Conversion.Video.Mode != "transcode" {
+		return true
+	}
+	media, err := c.prober.Get(path).Media(c.ctx)
+	return err != nil || efficientVideoCodecs[media.Codec]
+}
+
 // convertToAVIFAnimated 转换动图为AVIF（使用ffmpeg）
 func (c *Converter) ConvertToAVIFAnimated(file *MediaFile) (string, error) {
+	return c.convertToAVIFAnimatedCRF(file, 30) // 适度压缩
+}
+
+// convertToAVIFAnimatedCRF 以指定CRF将动图转换为AVIF
+func (c *Converter) convertToAVIFAnimatedCRF(file *MediaFile, crf int) (string, error) {
 	outputPath := c.getOutputPath(file, ".avif")
 
 	// 使用FFmpeg将动图转换为AVIF格式
@@ -797,7 +1075,7 @@ func (c *Converter) ConvertToAVIFAnimated(file *MediaFile) (string, error) {
 	args := []string{
 		"-i", file.Path,
 		"-c:v", "libaom-av1", // 使用libaom AV1编码器
-		"-crf", "30", // 适度压缩
+		"-crf", strconv.Itoa(crf),
 		"-b:v", "0", // 使用CRF模式
 		"-pix_fmt", "yuv420p", // 像素格式
 		"-auto-alt-ref", "0", // 禁用自动参考帧
@@ -807,9 +1085,7 @@ func (c *Converter) ConvertToAVIFAnimated(file *MediaFile) (string, error) {
 		outputPath,
 	}
 
-	cmd := exec.CommandContext(c.ctx, c.config.Tools.FFmpegPath, args...)
-
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...); err != nil {
 		return "", c.errorHandler.WrapErrorWithOutput("ffmpeg AVIF animation conversion failed", err, output)
 	}
 
@@ -1049,6 +1325,26 @@ func (c *Converter) Close() error {
 		}
 	}
 
+	// 耗时模型停止写入后关闭全局状态数据库
+	c.detachTimingState()
+
+	// 停止内存调度器的RSS采样
+	c.memoryScheduler.Close()
+
+	// 关闭内容缓存
+	if c.resultCache != nil {
+		if err := c.resultCache.Close(); err != nil {
+			c.logger.Warn("关闭内容缓存失败", zap.Error(err))
+		}
+	}
+
+	// 关闭事件输出
+	if closer, ok := c.eventSink.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			c.logger.Warn("关闭事件输出失败", zap.Error(err))
+		}
+	}
+
 	return nil
 }
 
@@ -1149,85 +1445,58 @@ func (c *Converter) GetMetadataManager() *MetadataManager {
 	return c.metadataManager
 }
 
-// resumeConversion 恢复未完成的转换会话
+// resumeConversion 恢复未完成的转换会话：重新扫描目标目录，跳过会话中已完成或已跳过的文件
 func (c *Converter) resumeConversion(inputDir string) error {
-	// 获取会话信息并设置统计数据
-	sessionInfo := c.checkpointMgr.GetSessionInfo()
-	if sessionInfo != nil {
-		c.mutex.Lock()
-		c.stats.TotalFiles = sessionInfo.TotalFiles
-		c.stats.StartTime = sessionInfo.StartTime
-		c.mutex.Unlock()
-		// 从会话恢复统计信息
-	}
+	c.setInputRoot(inputDir)
+	c.settingsKey = c.settingsFingerprint()
 
-	// 获取未完成的文件列表
-	pendingFiles, err := c.checkpointMgr.GetPendingFiles()
+	finished, err := c.checkpointMgr.GetFinishedFiles()
 	if err != nil {
-		return c.errorHandler.WrapError("获取未完成文件列表失败", err)
+		return c.errorHandler.WrapError("获取已完成文件列表失败", err)
 	}
 
-	if len(pendingFiles) == 0 {
-		// 所有文件已完成，清理会话
-		if sessionInfo != nil {
-			if err := c.checkpointMgr.CleanupSession(sessionInfo.SessionID); err != nil {
-				c.logger.Warn("清理会话失败",
-					zap.String("sessionID", sessionInfo.SessionID),
-					zap.Error(err))
-			}
-		}
-		return nil
+	// 检查路径权限
+	if err := c.checkPathPermissions(inputDir); err != nil {
+		return c.errorHandler.WrapError("路径权限检查失败", err)
 	}
 
-	// 继续处理未完成的文件
-
-	// 将文件路径转换为MediaFile对象
-	var mediaFiles []*MediaFile
-	for _, filePath := range pendingFiles {
-		// 检查文件是否仍然存在
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			c.logger.Warn("文件不存在，跳过", zap.String("file", filePath))
-			if err := c.checkpointMgr.UpdateFileStatus(filePath, StatusSkipped, "文件不存在", ""); err != nil {
-				c.logger.Warn("更新文件状态失败",
-					zap.String("file", filePath),
-					zap.Error(err))
-			}
-			continue
-		}
+	c.mutex.Lock()
+	c.stats.StartTime = time.Now()
+	c.mutex.Unlock()
 
-		// 创建MediaFile对象
-		mediaFile := &MediaFile{
-			Path: filePath,
-			Name: filepath.Base(filePath),
-		}
+	batchProcessor := NewBatchProcessor(c, c.logger)
+	c.emitScanStarted()
+	if err := batchProcessor.ScanAndAnalyze(inputDir); err != nil {
+		return c.errorHandler.WrapError("扫描和分析文件失败", err)
+	}
 
-		// 获取文件信息
-		if info, err := os.Stat(filePath); err == nil {
-			mediaFile.Size = info.Size()
-			mediaFile.ModTime = info.ModTime()
+	// 会话中已完成的文件不再处理
+	batchProcessor.mutex.Lock()
+	remaining := make([]*MediaFile, 0, len(batchProcessor.taskQueue))
+	for _, file := range batchProcessor.taskQueue {
+		absPath, err := filepath.Abs(file.Path)
+		if err == nil && finished[absPath] {
+			continue
 		}
-
-		// 确定文件类型
-		ext := strings.ToLower(filepath.Ext(filePath))
-		mediaFile.Extension = ext
-		mediaFile.Type = c.getFileType(ext)
-
-		mediaFiles = append(mediaFiles, mediaFile)
+		remaining = append(remaining, file)
 	}
+	batchProcessor.taskQueue = remaining
+	batchProcessor.mutex.Unlock()
 
-	if len(mediaFiles) == 0 {
-		// 没有有效的未完成文件
-		return nil
+	c.logger.Info("恢复转换会话",
+		zap.String("session", c.checkpointMgr.CurrentSessionID()),
+		zap.Int("finished", len(finished)),
+		zap.Int("remaining", len(remaining)))
+
+	// 处理损坏文件
+	if err := batchProcessor.HandleCorruptedFiles(); err != nil {
+		c.logger.Warn("处理损坏文件时出错", zap.Error(err))
 	}
 
-	// 检查路径权限
-	if err := c.checkPathPermissions(inputDir); err != nil {
-		return c.errorHandler.WrapError("路径权限检查失败", err)
+	if err := batchProcessor.ProcessTaskQueue(); err != nil {
+		return c.errorHandler.WrapError("处理任务队列失败", err)
 	}
 
-	// 处理文件
-	// 启动动态进度条
-	ui.StartDynamicProgress(int64(len(mediaFiles)), "转换进度")
-	
-	return c.processFiles(mediaFiles)
+	c.finishSession()
+	return nil
 }
@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"os"
+
+	"pixly/pkg/whitelist"
+
+	"go.uber.org/zap"
+)
+
+// creativeSkipReason 创作源文件的跳过原因文字
+func creativeSkipReason(reason *whitelist.SkipReason) string {
+	return reason.Reason + "：" + reason.Description
+}
+
+// applyCreativeSkip 创作源文件（PSD、SVG、分层TIFF等）与PDF不交给任何编码器：按内容识别后以跳过结果填充result，
+// 返回是否跳过。在类型检测之前执行，计划中的路由同样不能越过
+func (c *Converter) applyCreativeSkip(file *MediaFile, result *ConversionResult) bool {
+	reason := whitelist.ClassifyCreative(c.prober.Get(file.Path))
+	if reason == nil {
+		return false
+	}
+
+	result.OutputPath = file.Path
+	result.CompressedSize = file.Size
+	result.Success = true
+	result.Skipped = true
+	result.Method = string(ActionSkip)
+	result.SkipCategory = reason.Category
+	result.SkipReason = creativeSkipReason(reason)
+
+	c.logger.Debug("创作源文件，保持原样",
+		zap.String("file", file.Path),
+		zap.String("category", reason.Category.String()),
+		zap.String("reason", result.SkipReason))
+	return true
+}
+
+// creativeRoute 计划模式中创作源文件的跳过路由；不是创作源文件时返回false
+func (c *Converter) creativeRoute(file *MediaFile) (Route, bool) {
+	reason := whitelist.ClassifyCreative(c.prober.Get(file.Path))
+	if reason == nil {
+		return Route{}, false
+	}
+	return skipRoute(creativeSkipReason(reason)), true
+}
+
+// recordCreativeSkip 扫描阶段按扩展名识别的创作源文件直接记录为跳过结果，不参与分析与转换
+func (bp *BatchProcessor) recordCreativeSkip(path string, info os.FileInfo, reason *whitelist.SkipReason) {
+	file := bp.converter.newMediaFile(path, info)
+	file.SkipReason = creativeSkipReason(reason)
+	result := &ConversionResult{
+		OriginalFile:   file,
+		OutputPath:     file.Path,
+		OriginalSize:   file.Size,
+		CompressedSize: file.Size,
+		Success:        true,
+		Skipped:        true,
+		SkipCategory:   reason.Category,
+		SkipReason:     file.SkipReason,
+		Method:         string(ActionSkip),
+	}
+
+	bp.mutex.Lock()
+	bp.results = append(bp.results, result)
+	bp.mutex.Unlock()
+	bp.converter.mutex.Lock()
+	bp.converter.results = append(bp.converter.results, result)
+	bp.converter.mutex.Unlock()
+	bp.converter.UpdateStats(result)
+	bp.converter.emitResult(result)
+
+	bp.logger.Debug("跳过创作源文件",
+		zap.String("file", path),
+		zap.String("category", reason.Category.String()),
+		zap.String("reason", result.SkipReason))
+}
@@ -359,13 +359,8 @@ func (eh *ErrorHandler) WrapErrorWithOutput(operation string, err error, output
 	if err == nil {
 		return nil
 	}
-	var errorBuilder strings.Builder
-	errorBuilder.WriteString(operation)
-	errorBuilder.WriteString(" failed: ")
-	errorBuilder.WriteString(err.Error())
-	errorBuilder.WriteString(", output: ")
-	errorBuilder.WriteString(string(output))
-	return fmt.Errorf("%s", errorBuilder.String())
+	// 保留错误链，恢复管理器据此识别进程退出状态与上下文超时
+	return fmt.Errorf("%s failed: %w, output: %s", operation, err, output)
 }
 
 // LogAndWrapError 记录日志并包装错误
@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventScanStarted    EventType = "scan_started"
+	EventFileAssessed   EventType = "file_assessed"
+	EventFileConverted  EventType = "file_converted"
+	EventFileSkipped    EventType = "file_skipped"
+	EventFileFailed     EventType = "file_failed"
+	EventSessionSummary EventType = "session_summary"
+)
+
+// Event 机器可读的转换事件，字段按事件类型填充，未使用的字段省略
+type Event struct {
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id,omitempty"`
+
+	// scan_started
+	InputDir string `json:"input_dir,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+
+	// 文件事件
+	Path         string    `json:"path,omitempty"`
+	MediaType    MediaType `json:"media_type,omitempty"`
+	Strategy     string    `json:"strategy,omitempty"`
+	Action       string    `json:"action,omitempty"`
+	TargetFormat string    `json:"target_format,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	OriginalSize int64     `json:"original_size,omitempty"`
+	OutputSize   int64     `json:"output_size,omitempty"`
+	OutputPath   string    `json:"output_path,omitempty"`
+	Method       string    `json:"method,omitempty"`
+	DurationMs   int64     `json:"duration_ms,omitempty"`
+
+	QualityMetric string  `json:"quality_metric,omitempty"`
+	QualityScore  float64 `json:"quality_score,omitempty"`
+
+	// file_failed
+	Error     string        `json:"error,omitempty"`
+	ErrorType ErrorType     `json:"error_type,omitempty"`
+	Severity  ErrorSeverity `json:"severity,omitempty"`
+	Retryable bool          `json:"retryable,omitempty"`
+
+	// session_summary
+	Summary *EventSummary `json:"summary,omitempty"`
+}
+
+// EventSummary 会话汇总
+type EventSummary struct {
+	TotalFiles     int   `json:"total_files"`
+	Successful     int   `json:"successful"`
+	Failed         int   `json:"failed"`
+	Skipped        int   `json:"skipped"`
+	OriginalSize   int64 `json:"original_size"`
+	CompressedSize int64 `json:"compressed_size"`
+	DurationMs     int64 `json:"duration_ms"`
+}
+
+// EventSink 事件接收器，Emit 可能被多个goroutine并发调用
+type EventSink interface {
+	Emit(event Event)
+}
+
+// NDJSONSink 将事件逐行写为JSON（NDJSON）
+type NDJSONSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewNDJSONSink 创建NDJSON事件接收器；closer 不为nil时随 Close 一起关闭
+func NewNDJSONSink(w io.Writer, closer io.Closer) *NDJSONSink {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &NDJSONSink{encoder: encoder, closer: closer}
+}
+
+// Emit 写入一行事件，写入失败时丢弃（事件流不影响转换）
+func (s *NDJSONSink) Emit(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_ = s.encoder.Encode(event)
+}
+
+// Close 关闭底层输出
+func (s *NDJSONSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// SetEventSink 设置事件接收器，为nil时不产生事件；转换器关闭时若接收器实现 io.Closer 会一并关闭
+func (c *Converter) SetEventSink(sink EventSink) {
+	c.eventSink = sink
+}
+
+// emit 发送事件，补全时间与会话ID
+func (c *Converter) emit(event Event) {
+	if c.eventSink == nil {
+		return
+	}
+	event.Time = time.Now()
+	if event.SessionID == "" && c.checkpointMgr != nil {
+		event.SessionID = c.checkpointMgr.CurrentSessionID()
+	}
+	c.eventSink.Emit(event)
+}
+
+// emitScanStarted 发送开始扫描事件
+func (c *Converter) emitScanStarted() {
+	c.emit(Event{
+		Type:     EventScanStarted,
+		InputDir: c.inputRoot,
+		Mode:     string(c.mode),
+	})
+}
+
+// emitAssessed 发送文件评估事件：形态检测与路由决策的结果
+func (c *Converter) emitAssessed(file *MediaFile, route Route) {
+	c.emit(Event{
+		Type:         EventFileAssessed,
+		Path:         file.Path,
+		MediaType:    file.Type,
+		Strategy:     route.Strategy,
+		Action:       string(route.Action),
+		TargetFormat: route.TargetExt,
+		Reason:       route.Reason,
+		OriginalSize: file.Size,
+	})
+}
+
+// emitResult 按结果发送 file_converted、file_skipped 或 file_failed 事件
+func (c *Converter) emitResult(result *ConversionResult) {
+	if c.eventSink == nil || result.OriginalFile == nil {
+		return
+	}
+
+	event := Event{
+		Path:         result.OriginalFile.Path,
+		MediaType:    result.OriginalFile.Type,
+		OriginalSize: result.OriginalSize,
+		Method:       result.Method,
+		DurationMs:   result.Duration.Milliseconds(),
+	}
+	switch {
+	case result.Skipped:
+		event.Type = EventFileSkipped
+		event.Reason = result.SkipReason
+	case result.Success:
+		event.Type = EventFileConverted
+		event.OutputPath = result.OutputPath
+		event.OutputSize = result.CompressedSize
+		event.QualityMetric = result.QualityMetric
+		event.QualityScore = result.QualityScore
+	default:
+		event.Type = EventFileFailed
+		if result.Error != nil {
+			event.Error = result.Error.Error()
+		}
+		event.ErrorType, event.Severity, event.Retryable = classifyError(result.Error)
+	}
+	c.emit(event)
+}
+
+// emitSessionSummary 发送会话汇总事件
+func (c *Converter) emitSessionSummary() {
+	if c.eventSink == nil {
+		return
+	}
+	stats := c.GetStats()
+	c.emit(Event{
+		Type: EventSessionSummary,
+		Summary: &EventSummary{
+			TotalFiles:     stats.TotalFiles,
+			Successful:     stats.SuccessfulFiles,
+			Failed:         stats.FailedFiles,
+			Skipped:        stats.SkippedFiles,
+			OriginalSize:   stats.TotalSize,
+			CompressedSize: stats.CompressedSize,
+			DurationMs:     stats.TotalDuration.Milliseconds(),
+		},
+	})
+}
+
+// classifyError 取错误链中 PixlyError 的类型；普通错误按原因推断
+func classifyError(err error) (ErrorType, ErrorSeverity, bool) {
+	var pe *PixlyError
+	if errors.As(err, &pe) {
+		return pe.Type, pe.Severity, pe.Retryable
+	}
+
+	var exitErr *exec.ExitError
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return ErrorTypeUnknown, SeverityMedium, false
+	case errors.Is(err, exec.ErrNotFound), errors.As(err, &exitErr):
+		return ErrorTypeToolExecution, SeverityMedium, true
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return ErrorTypeSystemResource, SeverityMedium, true
+	case errors.As(err, &pathErr):
+		return ErrorTypeFileOperation, SeverityMedium, !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission)
+	default:
+		return ErrorTypeConversion, SeverityMedium, false
+	}
+}
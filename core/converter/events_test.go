@@ -0,0 +1,192 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// goldenTime 黄金输出中使用的固定事件时间
+var goldenTime = time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+
+// goldenSink 固定事件时间后写为NDJSON，输出可与黄金行逐字比较
+type goldenSink struct {
+	sink *NDJSONSink
+}
+
+// Emit 替换事件时间后写入
+func (s goldenSink) Emit(event Event) {
+	event.Time = goldenTime
+	s.sink.Emit(event)
+}
+
+// newGoldenConverter 创建事件写入缓冲区的转换器
+func newGoldenConverter() (*Converter, *bytes.Buffer) {
+	var buf bytes.Buffer
+	c := &Converter{
+		logger:    zap.NewNop(),
+		mode:      ModeAutoPlus,
+		inputRoot: "/photos",
+		stats:     &ConversionStats{},
+	}
+	c.SetEventSink(goldenSink{sink: NewNDJSONSink(&buf, nil)})
+	return c, &buf
+}
+
+func TestEventsGolden(t *testing.T) {
+	png := &MediaFile{Path: "/photos/a.png", Type: TypeImage, Size: 1000}
+	toolErr := &PixlyError{
+		Type:      ErrorTypeToolExecution,
+		Severity:  SeverityHigh,
+		Message:   "cjxl failed",
+		Operation: "cjxl",
+		Retryable: true,
+	}
+
+	tests := []struct {
+		name   string
+		emit   func(c *Converter)
+		golden string
+	}{
+		{"scan_started", func(c *Converter) {
+			c.emitScanStarted()
+		}, `{"type":"scan_started","time":"2024-03-09T14:30:00Z","input_dir":"/photos","mode":"auto+"}`},
+		{"file_assessed", func(c *Converter) {
+			c.emitAssessed(png, Route{Action: ActionJXLLossless, Strategy: "auto+", TargetExt: ".jxl", Reason: "无损源文件 & <原样>"})
+		}, `{"type":"file_assessed","time":"2024-03-09T14:30:00Z","path":"/photos/a.png","media_type":"image","strategy":"auto+","action":"jxl_lossless","target_format":".jxl","reason":"无损源文件 & <原样>","original_size":1000}`},
+		{"file_converted", func(c *Converter) {
+			c.emitResult(&ConversionResult{
+				OriginalFile:   png,
+				OutputPath:     "/photos/a.jxl",
+				OriginalSize:   1000,
+				CompressedSize: 600,
+				Duration:       1500 * time.Millisecond,
+				Success:        true,
+				Method:         string(ActionBalanced),
+				QualityMetric:  "ssim",
+				QualityScore:   0.987,
+			})
+		}, `{"type":"file_converted","time":"2024-03-09T14:30:00Z","path":"/photos/a.png","media_type":"image","original_size":1000,"output_size":600,"output_path":"/photos/a.jxl","method":"balanced","duration_ms":1500,"quality_metric":"ssim","quality_score":0.987}`},
+		{"file_skipped", func(c *Converter) {
+			c.emitResult(&ConversionResult{
+				OriginalFile: png,
+				OriginalSize: 1000,
+				Success:      true,
+				Skipped:      true,
+				SkipReason:   "已是目标格式",
+				Method:       string(ActionSkip),
+			})
+		}, `{"type":"file_skipped","time":"2024-03-09T14:30:00Z","path":"/photos/a.png","media_type":"image","reason":"已是目标格式","original_size":1000,"method":"skip"}`},
+		{"file_failed", func(c *Converter) {
+			c.emitResult(&ConversionResult{
+				OriginalFile: png,
+				OriginalSize: 1000,
+				Duration:     20 * time.Millisecond,
+				Error:        toolErr,
+			})
+		}, `{"type":"file_failed","time":"2024-03-09T14:30:00Z","path":"/photos/a.png","media_type":"image","original_size":1000,"duration_ms":20,"error":"[TOOL_EXECUTION:HIGH] cjxl failed (operation: cjxl)","error_type":"TOOL_EXECUTION","severity":"HIGH","retryable":true}`},
+		{"file_failed无错误详情", func(c *Converter) {
+			c.emitResult(&ConversionResult{OriginalFile: png, OriginalSize: 1000})
+		}, `{"type":"file_failed","time":"2024-03-09T14:30:00Z","path":"/photos/a.png","media_type":"image","original_size":1000,"error_type":"UNKNOWN","severity":"MEDIUM"}`},
+		{"session_summary", func(c *Converter) {
+			*c.stats = ConversionStats{
+				TotalFiles:      3,
+				SuccessfulFiles: 1,
+				FailedFiles:     1,
+				SkippedFiles:    1,
+				TotalSize:       3000,
+				CompressedSize:  2600,
+				TotalDuration:   2 * time.Second,
+			}
+			c.emitSessionSummary()
+		}, `{"type":"session_summary","time":"2024-03-09T14:30:00Z","summary":{"total_files":3,"successful":1,"failed":1,"skipped":1,"original_size":3000,"compressed_size":2600,"duration_ms":2000}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, buf := newGoldenConverter()
+			tt.emit(c)
+			if got := strings.TrimSuffix(buf.String(), "\n"); got != tt.golden {
+				t.Errorf("事件输出不一致\n实际: %s\n期望: %s", got, tt.golden)
+			}
+			if strings.Count(buf.String(), "\n") != 1 {
+				t.Errorf("每个事件应恰好占一行: %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestEventsDisabled(t *testing.T) {
+	// 未设置接收器时所有事件都是空操作
+	c := &Converter{logger: zap.NewNop(), stats: &ConversionStats{}}
+	c.emitScanStarted()
+	c.emitAssessed(&MediaFile{Path: "a.png"}, Route{})
+	c.emitResult(&ConversionResult{OriginalFile: &MediaFile{Path: "a.png"}})
+	c.emitSessionSummary()
+
+	// 没有源文件的结果不产生事件
+	c, buf := newGoldenConverter()
+	c.emitResult(&ConversionResult{Success: true})
+	if buf.Len() != 0 {
+		t.Errorf("没有源文件的结果不应产生事件: %s", buf.String())
+	}
+}
+
+func TestEventSessionID(t *testing.T) {
+	c, buf := newGoldenConverter()
+	c.checkpointMgr = newTestCheckpoint(t, t.TempDir())
+	c.emitScanStarted()
+	if want := `"session_id":"` + c.checkpointMgr.CurrentSessionID() + `"`; !strings.Contains(buf.String(), want) {
+		t.Errorf("事件应带当前会话ID %s: %s", want, buf.String())
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	var exitType *exec.ExitError
+	if !errors.As(exitErr, &exitType) {
+		t.Fatalf("无法构造退出错误: %v", exitErr)
+	}
+	_, notExist := os.Open("/nonexistent/pixly")
+	permission := &fs.PathError{Op: "open", Path: "/root/a.png", Err: fs.ErrPermission}
+	diskFull := &fs.PathError{Op: "write", Path: "/photos/a.jxl", Err: errors.New("no space left on device")}
+	pixly := &PixlyError{Type: ErrorTypeConfiguration, Severity: SeverityCritical, Message: "bad", Retryable: false}
+
+	tests := []struct {
+		name      string
+		err       error
+		errType   ErrorType
+		severity  ErrorSeverity
+		retryable bool
+	}{
+		{"无错误", nil, ErrorTypeUnknown, SeverityMedium, false},
+		{"PixlyError保留原分类", pixly, ErrorTypeConfiguration, SeverityCritical, false},
+		{"包装的PixlyError", fmt.Errorf("处理失败: %w", pixly), ErrorTypeConfiguration, SeverityCritical, false},
+		{"可重试的PixlyError", &PixlyError{Type: ErrorTypeFileOperation, Severity: SeverityLow, Retryable: true}, ErrorTypeFileOperation, SeverityLow, true},
+		{"工具不存在", fmt.Errorf("启动cjxl: %w", exec.ErrNotFound), ErrorTypeToolExecution, SeverityMedium, true},
+		{"工具非零退出", fmt.Errorf("cjxl: %w", exitErr), ErrorTypeToolExecution, SeverityMedium, true},
+		{"超时", fmt.Errorf("编码: %w", context.DeadlineExceeded), ErrorTypeSystemResource, SeverityMedium, true},
+		{"取消", context.Canceled, ErrorTypeSystemResource, SeverityMedium, true},
+		{"文件不存在", notExist, ErrorTypeFileOperation, SeverityMedium, false},
+		{"权限不足", permission, ErrorTypeFileOperation, SeverityMedium, false},
+		{"其他文件错误可重试", diskFull, ErrorTypeFileOperation, SeverityMedium, true},
+		{"其他错误", errors.New("输出文件验证失败"), ErrorTypeConversion, SeverityMedium, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errType, severity, retryable := classifyError(tt.err)
+			if errType != tt.errType || severity != tt.severity || retryable != tt.retryable {
+				t.Errorf("classifyError = %s, %s, %v, 期望 %s, %s, %v",
+					errType, severity, retryable, tt.errType, tt.severity, tt.retryable)
+			}
+		})
+	}
+}
@@ -1,11 +1,11 @@
 package converter
 
 import (
-	"encoding/json"
-	"strconv"
+	"context"
 	"strings"
 
 	"pixly/config"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -21,12 +21,20 @@ const (
 	FileTypeLivePhoto  FileType = "live_photo"  // Live Photo
 	FileTypeBurstPhoto FileType = "burst_photo" // 连拍照片
 	FileTypePanorama   FileType = "panorama"    // 全景照片
+	FileTypeGainMap    FileType = "gain_map"    // 带增益图的HDR照片（Ultra HDR、Apple HDR）
+	FileTypeRaw        FileType = "raw"         // 相机RAW文件（DNG、CR2、NEF、ARW）
 	FileTypeUnknown    FileType = "unknown"
 )
 
+// mediaDetailsVersion 检测结果的算法版本，变更检测逻辑时递增以使缓存的检测结果失效
+const mediaDetailsVersion = 4
+
 // MediaDetails 媒体文件详细信息
 type MediaDetails struct {
+	Version     int
 	FileType    FileType
+	GainMap     string // 增益图类型（mediaprobe.GainMap*），没有增益图时为空
+	Raw         string // 相机RAW格式（mediaprobe.Raw*），不是RAW时为空
 	Codec       string
 	Container   string
 	FrameCount  int
@@ -41,58 +49,34 @@ type FileTypeDetector struct {
 	config      *config.Config
 	logger      *zap.Logger
 	toolManager *ToolManager
+	prober      *mediaprobe.Prober
 }
 
-// NewFileTypeDetector 创建新的文件类型检测器
-func NewFileTypeDetector(config *config.Config, logger *zap.Logger, toolManager *ToolManager) *FileTypeDetector {
+// NewFileTypeDetector 创建新的文件类型检测器；prober为nil时创建独立的探测缓存
+func NewFileTypeDetector(config *config.Config, logger *zap.Logger, toolManager *ToolManager, prober *mediaprobe.Prober) *FileTypeDetector {
+	if prober == nil {
+		prober = newProber(config, toolManager)
+	}
 	return &FileTypeDetector{
 		config:      config,
 		logger:      logger,
 		toolManager: toolManager,
+		prober:      prober,
 	}
 }
 
-// DetectFileType 精确检测文件类型
+// DetectFileType 精确检测文件类型：图像优先解析容器头部，视频与无法解析的格式使用FFprobe
 func (fd *FileTypeDetector) DetectFileType(filePath string) (*MediaDetails, error) {
-	// 使用FFprobe获取媒体信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
+	probe := fd.prober.Get(filePath)
+	if info, err := probe.Header(); err == nil {
+		return fd.detailsFromHeader(filePath, info), nil
 	}
 
-	// 使用工具管理器执行命令，支持路径验证
-	output, err := fd.toolManager.ExecuteWithPathValidation(fd.config.Tools.FFprobePath, args...)
+	// 使用共享的FFprobe结果
+	probeData, err := probe.FFprobe(context.Background())
 	if err != nil {
-		// 如果FFprobe失败，标记为损坏文件
-		return &MediaDetails{
-			FileType:    FileTypeUnknown,
-			IsCorrupted: true,
-		}, nil
-	}
-
-	// 解析FFprobe输出
-	var probeData struct {
-		Format struct {
-			FormatName string `json:"format_name"`
-			Duration   string `json:"duration"`
-			BitRate    string `json:"bit_rate"`
-		} `json:"format"`
-		Streams []struct {
-			CodecName  string `json:"codec_name"`
-			CodecType  string `json:"codec_type"`
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			RFrameRate string `json:"r_frame_rate"`
-			NbFrames   string `json:"nb_frames"`
-			Duration   string `json:"duration"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil {
-		fd.logger.Warn("解析FFprobe输出失败", zap.String("file", filePath), zap.Error(err))
+		// 如果FFprobe失败或输出无法解析，标记为损坏文件
+		fd.logger.Debug("FFprobe探测失败", zap.String("file", filePath), zap.Error(err))
 		return &MediaDetails{
 			FileType:    FileTypeUnknown,
 			IsCorrupted: true,
@@ -101,51 +85,13 @@ func (fd *FileTypeDetector) DetectFileType(filePath string) (*MediaDetails, erro
 
 	// 创建媒体详情对象
 	details := &MediaDetails{
+		Version:   mediaDetailsVersion,
 		Container: probeData.Format.FormatName,
+		Duration:  probeData.Duration(),
 	}
 
-	// 解析持续时间
-	if probeData.Format.Duration != "" {
-		if duration, err := strconv.ParseFloat(probeData.Format.Duration, 64); err == nil {
-			details.Duration = duration
-		}
-	}
-
-	// 分析流信息
-	type StreamInfo struct {
-		CodecName  string `json:"codec_name"`
-		CodecType  string `json:"codec_type"`
-		Width      int    `json:"width,omitempty"`
-		Height     int    `json:"height,omitempty"`
-		RFrameRate string `json:"r_frame_rate,omitempty"`
-		NbFrames   string `json:"nb_frames,omitempty"`
-		Duration   string `json:"duration,omitempty"`
-	}
-
-	var videoStream *StreamInfo
-	var audioStream *StreamInfo
-
-	// 查找视频和音频流
-	for i := range probeData.Streams {
-		stream := probeData.Streams[i]
-		switch stream.CodecType {
-		case "video":
-			videoStream = &StreamInfo{
-				CodecName:  stream.CodecName,
-				CodecType:  stream.CodecType,
-				Width:      stream.Width,
-				Height:     stream.Height,
-				RFrameRate: stream.RFrameRate,
-				NbFrames:   stream.NbFrames,
-				Duration:   stream.Duration,
-			}
-		case "audio":
-			audioStream = &StreamInfo{
-				CodecName: stream.CodecName,
-				CodecType: stream.CodecType,
-			}
-		}
-	}
+	videoStream := probeData.VideoStream()
+	audioStream := probeData.AudioStream()
 
 	// 根据流类型确定文件类型
 	if videoStream != nil {
@@ -154,19 +100,8 @@ func (fd *FileTypeDetector) DetectFileType(filePath string) (*MediaDetails, erro
 		details.Width = videoStream.Width
 		details.Height = videoStream.Height
 
-		// 解析帧数
-		if videoStream.NbFrames != "" {
-			if frames, err := strconv.Atoi(videoStream.NbFrames); err == nil {
-				details.FrameCount = frames
-			}
-		} else if videoStream.RFrameRate != "" {
-			// 如果没有明确的帧数，通过帧率和持续时间计算
-			if duration := details.Duration; duration > 0 {
-				if frameRate := fd.parseFrameRate(videoStream.RFrameRate); frameRate > 0 {
-					details.FrameCount = int(duration * frameRate)
-				}
-			}
-		}
+		// 解析帧数：没有明确的帧数时通过帧率和持续时间计算
+		details.FrameCount = videoStream.Frames(details.Duration)
 
 		// 判断是视频还是图片：基于持续时间和文件扩展名
 		fileExt := strings.ToLower(filePath[strings.LastIndex(filePath, "."):])
@@ -177,7 +112,7 @@ func (fd *FileTypeDetector) DetectFileType(filePath string) (*MediaDetails, erro
 			details.FileType = FileTypeVideo
 
 			// 检查是否为 Live Photo
-			if fd.isLivePhoto(filePath, details) {
+			if fd.isLivePhoto(filePath) {
 				details.FileType = FileTypeLivePhoto
 			}
 		} else {
@@ -215,21 +150,42 @@ func (fd *FileTypeDetector) DetectFileType(filePath string) (*MediaDetails, erro
 	return details, nil
 }
 
-// parseFrameRate 解析帧率字符串
-func (fd *FileTypeDetector) parseFrameRate(frameRateStr string) float64 {
-	parts := strings.Split(frameRateStr, "/")
-	if len(parts) != 2 {
-		return 0
+// detailsFromHeader 根据容器头部信息创建检测结果，判定规则与FFprobe路径的图片分支一致
+func (fd *FileTypeDetector) detailsFromHeader(filePath string, info *mediaprobe.Info) *MediaDetails {
+	details := &MediaDetails{
+		Version:    mediaDetailsVersion,
+		Codec:      info.Codec,
+		Container:  info.Format,
+		FrameCount: info.FrameCount,
+		Width:      info.Width,
+		Height:     info.Height,
+	}
+
+	if info.Animated {
+		details.FileType = FileTypeAnimatedImage
+	} else {
+		details.FileType = FileTypeStaticImage
+		details.FrameCount = 1
 	}
 
-	numerator, err1 := strconv.ParseFloat(parts[0], 64)
-	denominator, err2 := strconv.ParseFloat(parts[1], 64)
+	if fd.isPanorama(details) {
+		details.FileType = FileTypePanorama
+	} else if fd.isBurstPhoto(filePath) {
+		details.FileType = FileTypeBurstPhoto
+	}
 
-	if err1 != nil || err2 != nil || denominator == 0 {
-		return 0
+	// 增益图决定转换能否保留HDR渲染，优先于全景与连拍分类
+	if info.GainMap != "" && !info.Animated {
+		details.FileType = FileTypeGainMap
+		details.GainMap = info.GainMap
 	}
 
-	return numerator / denominator
+	// RAW文件的尺寸与预览不代表可直接转换的图像，优先于其他分类
+	if info.Raw != "" {
+		details.FileType = FileTypeRaw
+		details.Raw = info.Raw
+	}
+	return details
 }
 
 // isAnimatedFormat 检查是否为动图格式
@@ -252,44 +208,28 @@ func (fd *FileTypeDetector) isAnimatedFormat(filePath string) bool {
 	return false
 }
 
-// isLivePhoto 检查是否为 Live Photo
-func (fd *FileTypeDetector) isLivePhoto(filePath string, details *MediaDetails) bool {
-	// Live Photo 特征：
-	// 1. 时长很短（通常小于3秒）
-	// 2. 有音频轨道
-	// 3. 文件名可能包含 "IMG_" 和 "MOV" 等特征
-
-	// 检查时长
-	if details.Duration > 3.0 || details.Duration <= 0 {
+// isLivePhoto 检查是否为 Live Photo 的视频部分：QuickTime元数据中带有关联静态图的ContentIdentifier
+func (fd *FileTypeDetector) isLivePhoto(filePath string) bool {
+	identifier, err := mediaprobe.QuickTimeContentIdentifier(filePath)
+	if err != nil {
 		return false
 	}
-
-	// 检查文件名特征（iOS Live Photo 通常有相似的文件名）
-	fileName := strings.ToLower(filePath)
-	if strings.Contains(fileName, "img_") && strings.Contains(fileName, ".mov") {
-		return true
-	}
-
-	// 检查元数据中的特殊标记（这里简化处理）
-	// 在实际实现中，可能需要检查 EXIF 或其他元数据
-	return false
+	return identifier != ""
 }
 
 // isPanorama 检查是否为全景照片
 func (fd *FileTypeDetector) isPanorama(details *MediaDetails) bool {
-	// 全景照片特征：
-	// 1. 宽高比很大（通常大于2:1）
-	// 2. 分辨率很高
-
+	// 全景照片特征：长边与短边之比很大（横向或纵向，默认大于2:1）
 	if details.Width <= 0 || details.Height <= 0 {
 		return false
 	}
 
-	// 计算宽高比
-	aspectRatio := float64(details.Width) / float64(details.Height)
-
-	// 如果宽高比大于2:1，认为是全景照片
-	return aspectRatio > 2.0
+	minRatio := fd.config.Conversion.Panorama.MinAspectRatio
+	if minRatio <= 1 {
+		minRatio = 2.0
+	}
+	long, short := max(details.Width, details.Height), min(details.Width, details.Height)
+	return float64(long)/float64(short) > minRatio
 }
 
 // isBurstPhoto 检查是否为连拍照片
@@ -310,16 +250,7 @@ func (fd *FileTypeDetector) isBurstPhoto(filePath string) bool {
 
 // IsCorrupted 检查文件是否损坏
 func (fd *FileTypeDetector) IsCorrupted(filePath string) bool {
-	// 使用FFprobe检查文件是否可读
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
-	}
-
-	// 使用工具管理器执行命令，支持路径验证
-	_, err := fd.toolManager.ExecuteWithPathValidation(fd.config.Tools.FFprobePath, args...)
+	// 使用共享的FFprobe结果检查文件是否可读
+	_, err := fd.prober.Get(filePath).FFprobe(context.Background())
 	return err != nil
 }
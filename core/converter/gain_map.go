@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// gainMapLabels 增益图类型的显示名称
+var gainMapLabels = map[string]string{
+	mediaprobe.GainMapUltraHDR: "Ultra HDR",
+	mediaprobe.GainMapISO:      "ISO 21496-1",
+	mediaprobe.GainMapApple:    "Apple HDR",
+}
+
+// avifGainMapSupport avifenc的增益图支持情况
+type avifGainMapSupport struct {
+	Supported bool
+	Flags     []string // 读取JPEG增益图所需的额外参数（早期版本需要显式启用）
+}
+
+// avifGainMap 查询一次avifenc是否能从JPEG读取并写入增益图
+func (c *Converter) avifGainMap() *avifGainMapSupport {
+	c.avifGainMapOnce.Do(func() {
+		c.avifGainMapCaps = &avifGainMapSupport{}
+		// --help的退出码因版本而异，只解析输出；查询命令不占用编码线程
+		output, _ := c.toolManager.Run(c.ctx, ToolJob{}, c.config.Tools.AvifencPath, "--help")
+		help := string(output)
+		switch {
+		case strings.Contains(help, "--jpeg-gain-map"):
+			c.avifGainMapCaps.Supported = true
+			c.avifGainMapCaps.Flags = []string{"--jpeg-gain-map"}
+		case strings.Contains(help, "--ignore-gain-map"), strings.Contains(help, "--qgain-map"):
+			c.avifGainMapCaps.Supported = true // 默认读取JPEG中的增益图
+		}
+		c.logger.Debug("avifenc增益图支持", zap.Bool("supported", c.avifGainMapCaps.Supported))
+	})
+	return c.avifGainMapCaps
+}
+
+// gainMapRoute 带增益图的HDR照片路由：能保留增益图时转换，否则按配置与模式跳过并说明原因；
+// ok为false时按普通照片路由（非增益图照片，或配置为ignore）
+func (c *Converter) gainMapRoute(file *MediaFile, qualityMode bool) (Route, bool) {
+	details, err := c.detectFileType(file)
+	if err != nil || details.FileType != FileTypeGainMap {
+		return Route{}, false
+	}
+	label := gainMapLabels[details.GainMap]
+
+	switch policy := c.config.Conversion.GainMapPolicy; {
+	case policy == "ignore":
+		c.logger.Warn("按配置将带增益图的照片作为普通照片转换，HDR渲染将丢失",
+			zap.String("file", file.Path),
+			zap.String("gain_map", details.GainMap))
+		return Route{}, false
+	case policy == "skip":
+		return skipRoute(fmt.Sprintf("含%s增益图，按配置跳过", label)), true
+	case qualityMode:
+		return skipRoute(fmt.Sprintf("含%s增益图，品质模式跳过以免丢失HDR渲染", label)), true
+	}
+
+	ext := strings.ToLower(file.Extension)
+	if details.GainMap == mediaprobe.GainMapUltraHDR && (ext == ".jpg" || ext == ".jpeg") && c.avifGainMap().Supported {
+		return Route{
+			Strategy:  RouteStrategyBalanced,
+			Action:    ActionGainMapAVIF,
+			TargetExt: ".avif",
+			Reason:    "Ultra HDR照片转换为带增益图的AVIF",
+		}, true
+	}
+	return skipRoute(fmt.Sprintf("含%s增益图，当前工具无法保留，跳过以免丢失HDR渲染", label)), true
+}
+
+// convertToGainMapAVIF 转换为带增益图的AVIF：JPEG直接交给avifenc读取增益图，输出缺少增益图时转换失败
+func (c *Converter) convertToGainMapAVIF(file *MediaFile) (string, error) {
+	framework := NewConversionFramework(c)
+	config := framework.GainMapAVIFConfig(c.sourceColor(file.Path), c.encoderProfile(file), c.avifGainMap().Flags)
+	return framework.Execute(file, config, c.config.Conversion.Quality.AVIFQuality)
+}
+
+// verifyGainMapPreserved 检查输出文件是否仍带有增益图
+func (c *Converter) verifyGainMapPreserved(outputPath string) error {
+	info, err := mediaprobe.ProbeFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("无法解析输出文件以验证增益图: %w", err)
+	}
+	if info.GainMap == "" {
+		return fmt.Errorf("输出未包含增益图，HDR渲染会丢失: %s", outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pixly/config"
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// 测试用的JPEG应用段内容
+const (
+	hdrgmXMP       = "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta xmlns:hdrgm=\"http://ns.adobe.com/hdr-gain-map/1.0/\" hdrgm:Version=\"1.0\"/>"
+	isoGainMapAPP2 = "urn:iso:std:iso:ts:21496:-1\x00\x00\x00"
+)
+
+// writeJPEG 写入一个小JPEG，并在SOI之后插入给定的APP段（marker为0时不插入）
+func writeJPEG(t *testing.T, path string, marker byte, payload string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if marker != 0 {
+		segment := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+		segment = append(segment, payload...)
+		data = append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeAvifenc 写入模拟的avifenc：--help输出给定文本，并把每次调用的参数追加到calls文件
+func fakeAvifenc(t *testing.T, dir, help string) (string, string) {
+	t.Helper()
+	tool := filepath.Join(dir, "avifenc")
+	calls := filepath.Join(dir, "avifenc.calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\nprintf '%s\\n' '" + help + "'\nexit 1\n"
+	if err := os.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return tool, calls
+}
+
+// newGainMapConverter 创建只做类型检测与增益图路由的转换器
+func newGainMapConverter(avifenc, policy string) *Converter {
+	cfg := &config.Config{}
+	cfg.Tools.AvifencPath = avifenc
+	cfg.Conversion.GainMapPolicy = policy
+	toolManager := NewToolManager(cfg, zap.NewNop(), nil)
+	prober := mediaprobe.NewProber("", "")
+	return &Converter{
+		config:           cfg,
+		ctx:              context.Background(),
+		logger:           zap.NewNop(),
+		toolManager:      toolManager,
+		prober:           prober,
+		fileTypeDetector: NewFileTypeDetector(cfg, zap.NewNop(), toolManager, prober),
+	}
+}
+
+// gainMapFile 由路径创建待路由的文件
+func gainMapFile(t *testing.T, path string) *MediaFile {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MediaFile{Path: path, Name: filepath.Base(path), Size: stat.Size(), ModTime: stat.ModTime(), Extension: filepath.Ext(path), Type: TypeImage}
+}
+
+func TestDetectGainMapFileType(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		marker   byte
+		payload  string
+		wantType FileType
+		wantMap  string
+	}{
+		{"普通JPEG", 0, "", FileTypeStaticImage, ""},
+		{"Ultra HDR", 0xE1, hdrgmXMP, FileTypeGainMap, mediaprobe.GainMapUltraHDR},
+		{"ISO增益图", 0xE2, isoGainMapAPP2, FileTypeGainMap, mediaprobe.GainMapISO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".jpg")
+			writeJPEG(t, path, tt.marker, tt.payload)
+			c := newGainMapConverter("", "preserve")
+			details, err := c.detectFileType(gainMapFile(t, path))
+			if err != nil {
+				t.Fatalf("detectFileType: %v", err)
+			}
+			if details.FileType != tt.wantType || details.GainMap != tt.wantMap {
+				t.Errorf("检测结果 = %s/%q, 期望 %s/%q", details.FileType, details.GainMap, tt.wantType, tt.wantMap)
+			}
+		})
+	}
+}
+
+func TestGainMapRoute(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.jpg")
+	writeJPEG(t, plain, 0, "")
+	ultra := filepath.Join(dir, "ultra.jpg")
+	writeJPEG(t, ultra, 0xE1, hdrgmXMP)
+	iso := filepath.Join(dir, "iso.jpg")
+	writeJPEG(t, iso, 0xE2, isoGainMapAPP2)
+
+	tests := []struct {
+		name        string
+		path        string
+		help        string
+		policy      string
+		qualityMode bool
+		wantOK      bool
+		wantAction  RouteAction
+		wantReason  string
+		wantFlags   []string
+	}{
+		{"普通照片", plain, "--jpeg-gain-map", "preserve", false, false, "", "", nil},
+		{"配置为ignore时按普通照片", ultra, "--jpeg-gain-map", "ignore", false, false, "", "", nil},
+		{"配置为skip", ultra, "--jpeg-gain-map", "skip", false, true, ActionSkip, "按配置跳过", nil},
+		{"品质模式跳过", ultra, "--jpeg-gain-map", "preserve", true, true, ActionSkip, "品质模式跳过", nil},
+		{"avifenc需要显式启用", ultra, "  --jpeg-gain-map  Read the gain map", "preserve", false, true, ActionGainMapAVIF, "带增益图的AVIF", []string{"--jpeg-gain-map"}},
+		{"avifenc默认读取增益图", ultra, "  --qgain-map Q", "preserve", false, true, ActionGainMapAVIF, "带增益图的AVIF", nil},
+		{"avifenc不支持增益图", ultra, "Usage: avifenc [options] input.[jpg|png|y4m] output.avif", "preserve", false, true, ActionSkip, "当前工具无法保留", nil},
+		{"ISO增益图无法保留", iso, "--jpeg-gain-map", "preserve", false, true, ActionSkip, "ISO 21496-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			avifenc, calls := fakeAvifenc(t, t.TempDir(), tt.help)
+			c := newGainMapConverter(avifenc, tt.policy)
+			route, ok := c.gainMapRoute(gainMapFile(t, tt.path), tt.qualityMode)
+			if ok != tt.wantOK || route.Action != tt.wantAction {
+				t.Fatalf("gainMapRoute = %+v, %v, 期望 %s, %v", route, ok, tt.wantAction, tt.wantOK)
+			}
+			if !strings.Contains(route.Reason, tt.wantReason) {
+				t.Errorf("Reason = %q, 期望包含 %q", route.Reason, tt.wantReason)
+			}
+			if route.Action == ActionGainMapAVIF {
+				if route.TargetExt != ".avif" {
+					t.Errorf("TargetExt = %q, 期望 .avif", route.TargetExt)
+				}
+				if got := strings.Join(c.avifGainMap().Flags, " "); got != strings.Join(tt.wantFlags, " ") {
+					t.Errorf("Flags = %q, 期望 %q", got, tt.wantFlags)
+				}
+			}
+			// 能力查询经工具管理器执行且只执行一次
+			c.avifGainMap()
+			if data, err := os.ReadFile(calls); err == nil && (strings.Count(string(data), "--help") != 1) {
+				t.Errorf("avifenc调用 = %q, 期望只查询一次--help", data)
+			}
+		})
+	}
+}
+
+func TestVerifyGainMapPreserved(t *testing.T) {
+	dir := t.TempDir()
+	with := filepath.Join(dir, "with.jpg")
+	writeJPEG(t, with, 0xE1, hdrgmXMP)
+	without := filepath.Join(dir, "without.jpg")
+	writeJPEG(t, without, 0, "")
+
+	c := newGainMapConverter("", "preserve")
+	if err := c.verifyGainMapPreserved(with); err != nil {
+		t.Errorf("带增益图的输出 err = %v", err)
+	}
+	if err := c.verifyGainMapPreserved(without); err == nil {
+		t.Error("缺少增益图的输出应返回错误")
+	}
+	if err := c.verifyGainMapPreserved(filepath.Join(dir, "missing.avif")); err == nil {
+		t.Error("无法解析的输出应返回错误")
+	}
+}
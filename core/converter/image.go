@@ -3,6 +3,7 @@ package converter
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
@@ -11,125 +12,41 @@ import (
 // convertToJXL 转换为JPEG XL格式
 func (c *Converter) convertToJXL(file *MediaFile, quality int) (string, error) {
 	framework := NewConversionFramework(c)
-	return framework.Execute(file, framework.JXLConfig(), quality)
+	return framework.Execute(file, framework.JXLConfig(c.sourceColor(file.Path), c.encoderProfile(file)), quality)
 }
 
 // convertToAVIF 转换为AVIF格式
 func (c *Converter) convertToAVIF(file *MediaFile, quality int) (string, error) {
 	framework := NewConversionFramework(c)
-	return framework.Execute(file, framework.AVIFConfig(), quality)
+	return framework.Execute(file, framework.AVIFConfig(c.sourceColor(file.Path), c.encoderProfile(file)), quality)
+}
+
+// convertToAVIFLossless 静态图片数学无损转换为AVIF
+func (c *Converter) convertToAVIFLossless(file *MediaFile) (string, error) {
+	framework := NewConversionFramework(c)
+	return framework.Execute(file, framework.AVIFLosslessConfig(c.sourceColor(file.Path), c.encoderProfile(file)), 100)
 }
 
 // 辅助函数
 
 // hasTransparency 检查图片是否有透明度
 func (c *Converter) hasTransparency(path string) bool {
-	// 使用FFprobe检查透明度
-	args := []string{
-		"-v", "quiet",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=pix_fmt",
-		"-of", "csv=p=0",
-		path,
-	}
-
-	// 使用工具管理器执行命令，支持路径验证
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFprobePath, args...)
+	// 共享探测结果：优先解析容器头部，无法解析的格式使用FFprobe像素格式
+	media, err := c.prober.Get(path).Media(c.ctx)
 	if err != nil {
 		return false
 	}
-
-	pixFmt := strings.TrimSpace(string(output))
-	// 常见的带透明度的像素格式
-	transparentFormats := []string{"rgba", "argb", "bgra", "abgr", "yuva", "gbra"}
-
-	for _, format := range transparentFormats {
-		if strings.Contains(pixFmt, format) {
-			return true
-		}
-	}
-
-	return false
+	return media.HasAlpha
 }
 
 // isAnimated 检查是否为动图
 func (c *Converter) isAnimated(path string) bool {
-	// 使用FFprobe检查帧数
-	args := []string{
-		"-v", "quiet",
-		"-select_streams", "v:0",
-		"-count_frames",
-		"-show_entries", "stream=nb_read_frames",
-		"-of", "csv=p=0",
-		path,
-	}
-
-	// 使用工具管理器执行命令，支持路径验证
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFprobePath, args...)
+	// 共享探测结果：优先从容器头部读取帧数，无法解析的格式使用FFprobe的帧数或时长估算
+	media, err := c.prober.Get(path).Media(c.ctx)
 	if err != nil {
 		return false
 	}
-
-	frames := strings.TrimSpace(string(output))
-	// 如果帧数大于1，则为动图
-	return frames != "1" && frames != "0" && frames != ""
-}
-
-// getOutputPath 获取输出文件路径 - 支持原地转换和指定目录输出，同时保持目录结构
-func (c *Converter) getOutputPath(file *MediaFile, newExt string) string {
-	// 路径计算逻辑
-
-	// 使用GlobalPathUtils处理路径
-	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
-	if err != nil {
-		return ""
-	}
-	
-	// 获取相对于工作目录的相对路径
-	workingDir, err := os.Getwd()
-	if err != nil {
-		return ""
-	}
-	
-	// 计算相对于工作目录的相对路径
-	relPath, err := filepath.Rel(workingDir, normalizedPath)
-	if err != nil {
-		return ""
-	}
-	
-	// 获取文件名和扩展名
-	baseName := GlobalPathUtils.GetBaseName(normalizedPath)
-	ext := GlobalPathUtils.GetExtension(normalizedPath)
-	name := strings.TrimSuffix(baseName, ext)
-
-	var outputDir string
-	
-	// 核心逻辑：默认原地转换，只有指定输出目录时才使用输出目录
-	if c.config.Output.DirectoryTemplate != "" {
-		// 指定了输出目录，保持原始目录结构
-		outputDir = filepath.Join(c.config.Output.DirectoryTemplate, filepath.Dir(relPath))
-		
-		// 确保输出目录存在
-		if err := c.fileOpHandler.SafeCreateDir(outputDir); err != nil {
-			c.logger.Warn("Failed to create output directory", zap.String("dir", outputDir), zap.Error(err))
-			// 如果创建目录失败，回退到基本输出目录
-			outputDir = c.config.Output.DirectoryTemplate
-		}
-	} else {
-		// 默认原地转换：使用原文件所在目录
-		outputDir = filepath.Dir(normalizedPath)
-	}
-
-	outputPath, err := GlobalPathUtils.JoinPath(outputDir, name+newExt)
-	if err != nil {
-		return ""
-	}
-	// 规范化输出路径
-	normalizedOutput, err := GlobalPathUtils.NormalizePath(outputPath)
-	if err != nil {
-		return outputPath // 如果规范化失败，返回原路径
-	}
-	return normalizedOutput
+	return media.Animated
 }
 
 // verifyOutputFile 验证输出文件
@@ -170,24 +87,15 @@ func (c *Converter) verifyOutputFile(outputPath string, originalSize int64) bool
 
 // verifyFileIntegrity 使用FFprobe验证文件的实际有效性
 func (c *Converter) verifyFileIntegrity(filePath string) bool {
-	// 使用FFprobe检查文件是否为有效的媒体文件
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		filePath,
-	}
-
-	// 使用工具管理器执行命令，支持路径验证
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFprobePath, args...)
+	// 使用FFprobe检查文件是否为有效的媒体文件；结果进入共享缓存，报告阶段直接复用
+	probeData, err := c.prober.Get(filePath).FFprobe(c.ctx)
 	if err != nil {
 		// File integrity verification failed - ffprobe failed
 		return false
 	}
 
 	// 检查输出是否包含有效的格式信息
-	outputStr := string(output)
-	if len(outputStr) < 10 || !strings.Contains(outputStr, "format") {
+	if probeData.Format.FormatName == "" {
 		// File integrity verification failed - invalid format info
 		return false
 	}
@@ -252,7 +160,7 @@ func (c *Converter) convertToJXLRepackaging(file *MediaFile, config RepackagingC
 			tempFile,
 		}
 
-		output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFmpegPath, ffmpegArgs...)
+		output, err := c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, ffmpegArgs...)
 		if err != nil {
 			if removeErr := c.fileOpHandler.SafeRemoveFile(tempFile); removeErr != nil {
 				// Failed to cleanup temp file after FFmpeg error
@@ -276,7 +184,7 @@ func (c *Converter) convertToJXLRepackaging(file *MediaFile, config RepackagingC
 	// cjxl 会自动处理透明度，无需额外参数
 
 	// 执行cjxl转换
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.CjxlPath, args...)
+	output, err := c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.CjxlPath, args...)
 	if err != nil {
 		if removeErr := c.fileOpHandler.SafeRemoveFile(actualOutputPath); removeErr != nil {
 			// Failed to cleanup output file after cjxl error
@@ -371,7 +279,7 @@ func (c *Converter) convertToJXLMathematicalLossless(file *MediaFile) (string, e
 		actualOutputPath,
 	}
 
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFmpegPath, args...)
+	output, err := c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...)
 	if err != nil {
 		if removeErr := c.fileOpHandler.SafeRemoveFile(actualOutputPath); removeErr != nil {
 			// Failed to cleanup output file after FFmpeg error
@@ -439,6 +347,10 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 
 	c.logger.Debug("临时输出路径", zap.String("actualOutputPath", actualOutputPath))
 
+	// HDR/广色域/高位深源需要显式保留色彩信息，全景照片使用更高的努力程度
+	color := c.sourceColor(file.Path)
+	profile := c.encoderProfile(file)
+
 	// 对于WebP格式，需要先转换为PNG然后再用cjxl处理
 	var inputPath string
 	var tempFile string
@@ -468,7 +380,7 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 		}
 
 		// 使用工具管理器执行命令，支持路径验证
-		output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFmpegPath, args...)
+		output, err := c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...)
 		if err != nil {
 			if removeErr := c.fileOpHandler.SafeRemoveFile(tempFile); removeErr != nil {
 				// Failed to cleanup temp file after FFmpeg error
@@ -497,7 +409,6 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 		inputPath,
 		actualOutputPath,
 		"--lossless_jpeg=1", // 对于JPEG使用lossless_jpeg
-		"-e", "9",           // 固定JXL压缩参数为-e 9
 	}
 
 	// 对于非JPEG文件，使用distance参数实现无损
@@ -507,14 +418,15 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 			inputPath,
 			actualOutputPath,
 			"--distance=0", // distance=0表示无损
-			"-e", "9",      // 固定JXL压缩参数为-e 9
 		}
+		args = append(args, jxlColorArgs(color)...)
 	}
+	args = append(args, profile.jxlEffortArgs()...)
 
 	c.logger.Debug("执行cjxl命令", zap.Strings("args", args))
 
 	// 首选cjxl工具进行无损JXL转换
-	output, err := c.toolManager.ExecuteWithPathValidation(c.config.Tools.CjxlPath, args...)
+	output, err := c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.CjxlPath, args...)
 	if err != nil {
 		// cjxl失败，使用FFmpeg作为备选方案
 		// cjxl lossless conversion failed, trying FFmpeg as fallback
@@ -526,13 +438,13 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 			"-i", inputPath,
 			"-c:v", "libjxl",
 			"-distance", "0", // distance=0表示无损
-			"-effort", "9",
+			"-effort", strconv.Itoa(min(profile.JXLEffort, 9)), // libjxl编码器不支持专家级努力程度
 			"-y",
 			actualOutputPath,
 		}
 
 		// 使用FFmpeg执行无损转换
-		output, err = c.toolManager.ExecuteWithPathValidation(c.config.Tools.FFmpegPath, ffmpegArgs...)
+		output, err = c.toolManager.ExecuteWithPathValidation(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, ffmpegArgs...)
 		if err != nil {
 			return "", c.errorHandler.WrapError("both cjxl and FFmpeg lossless JXL conversion failed", err, "output", string(output))
 		}
@@ -540,6 +452,10 @@ func (c *Converter) convertToJXLLossless(file *MediaFile) (string, error) {
 
 	c.logger.Debug("cjxl转换成功")
 
+	if err := c.verifyColorPreserved(color, actualOutputPath); err != nil {
+		return "", err
+	}
+
 	// 验证输出文件
 	if !c.verifyOutputFile(actualOutputPath, file.Size) {
 		var verifyErrorBuilder strings.Builder
@@ -0,0 +1,555 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// JournalBucket 撤销日志bucket，独立于会话记录，会话清理后仍然保留
+const JournalBucket = "journal"
+
+// OriginalsDirName 原件备份目录名：位于源文件所在目录，与源文件同一文件系统，移动无需拷贝
+const OriginalsDirName = ".pixly_originals"
+
+// JournalEntry 撤销日志条目：一次原地转换的源文件、输出与原件备份位置
+type JournalEntry struct {
+	SessionID  string    `json:"session_id"`
+	SourcePath string    `json:"source_path"`
+	SourceHash string    `json:"source_hash"`
+	SourceSize int64     `json:"source_size"`
+	OutputPath string    `json:"output_path"`
+	OutputHash string    `json:"output_hash"`
+	BackupPath string    `json:"backup_path,omitempty"` // 保留原文件时为空
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// JournalSession 撤销日志中的会话汇总
+type JournalSession struct {
+	SessionID  string    `json:"session_id"`
+	Entries    int       `json:"entries"`
+	BackupSize int64     `json:"backup_size"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+}
+
+// UndoOptions 撤销选项
+type UndoOptions struct {
+	Patterns []string // 仅撤销匹配的源文件（glob，匹配完整路径或文件名），为空时撤销整个会话
+	DryRun   bool     // 只校验不执行
+	Force    bool     // 输出文件已被修改时仍然删除
+}
+
+// UndoResult 单个条目的撤销结果
+type UndoResult struct {
+	Entry    *JournalEntry
+	Restored bool
+	Error    error
+}
+
+// journalKey 日志键：会话ID:源文件路径
+func journalKey(sessionID, sourcePath string) []byte {
+	var builder strings.Builder
+	builder.WriteString(sessionID)
+	builder.WriteString(":")
+	builder.WriteString(sourcePath)
+	return []byte(builder.String())
+}
+
+// CurrentSessionID 返回当前会话ID，没有活动会话时为空
+func (cm *CheckpointManager) CurrentSessionID() string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.sessionID
+}
+
+// RecordJournal 写入撤销日志条目并同步到磁盘
+func (cm *CheckpointManager) RecordJournal(entry *JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return cm.errorHandler.WrapError("序列化撤销日志失败", err)
+	}
+
+	err = cm.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(JournalBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put(journalKey(entry.SessionID, entry.SourcePath), data)
+	})
+	if err != nil {
+		return cm.errorHandler.WrapError("写入撤销日志失败", err)
+	}
+	return cm.db.Sync()
+}
+
+// ListJournal 列出会话的撤销日志条目
+func (cm *CheckpointManager) ListJournal(sessionID string) ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+	prefix := journalKey(sessionID, "")
+
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(JournalBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var entry JournalEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				cm.logger.Warn("撤销日志条目损坏", zap.String("key", string(k)), zap.Error(err))
+				continue
+			}
+			entries = append(entries, &entry)
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// ListJournalSessions 汇总撤销日志中的所有会话，按最近记录时间倒序
+func (cm *CheckpointManager) ListJournalSessions() ([]*JournalSession, error) {
+	sessions := make(map[string]*JournalSession)
+
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(JournalBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var entry JournalEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return nil
+			}
+			session, ok := sessions[entry.SessionID]
+			if !ok {
+				session = &JournalSession{SessionID: entry.SessionID, FirstAt: entry.RecordedAt}
+				sessions[entry.SessionID] = session
+			}
+			session.Entries++
+			if entry.BackupPath != "" {
+				session.BackupSize += entry.SourceSize
+			}
+			if entry.RecordedAt.Before(session.FirstAt) {
+				session.FirstAt = entry.RecordedAt
+			}
+			if entry.RecordedAt.After(session.LastAt) {
+				session.LastAt = entry.RecordedAt
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*JournalSession, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, session)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastAt.After(result[j].LastAt)
+	})
+	return result, nil
+}
+
+// deleteJournal 删除撤销日志条目
+func (cm *CheckpointManager) deleteJournal(entry *JournalEntry) error {
+	return cm.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(JournalBucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete(journalKey(entry.SessionID, entry.SourcePath))
+	})
+}
+
+// PruneJournal 清理超过保留期的撤销日志及其原件备份，返回清理的条目数
+func (cm *CheckpointManager) PruneJournal(retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	var expired []*JournalEntry
+
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(JournalBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var entry JournalEntry
+			if err := json.Unmarshal(v, &entry); err == nil && entry.RecordedAt.Before(cutoff) {
+				expired = append(expired, &entry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, entry := range expired {
+		if entry.BackupPath != "" {
+			if err := os.Remove(entry.BackupPath); err != nil && !os.IsNotExist(err) {
+				cm.logger.Warn("删除过期原件备份失败", zap.String("backup", entry.BackupPath), zap.Error(err))
+				continue
+			}
+			removeEmptyOriginalsDirs(entry.BackupPath)
+		}
+		if err := cm.deleteJournal(entry); err != nil {
+			return pruned, cm.errorHandler.WrapError("删除过期撤销日志失败", err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// Undo 撤销会话中的原地转换：校验哈希后删除输出并恢复原件
+func (cm *CheckpointManager) Undo(sessionID string, opts UndoOptions) ([]*UndoResult, error) {
+	entries, err := cm.ListJournal(sessionID)
+	if err != nil {
+		return nil, cm.errorHandler.WrapError("读取撤销日志失败", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("会话没有可撤销的记录: %s", sessionID)
+	}
+
+	var results []*UndoResult
+	for _, entry := range entries {
+		if !matchesUndoPatterns(entry.SourcePath, opts.Patterns) {
+			continue
+		}
+
+		result := &UndoResult{Entry: entry}
+		if err := cm.verifyUndo(entry, opts.Force); err != nil {
+			result.Error = err
+		} else if !opts.DryRun {
+			if err := cm.restoreEntry(entry); err != nil {
+				result.Error = err
+			} else {
+				result.Restored = true
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// verifyUndo 撤销前校验：原件备份完整、源路径未被占用、输出未被修改
+func (cm *CheckpointManager) verifyUndo(entry *JournalEntry, force bool) error {
+	if entry.BackupPath != "" {
+		hash, err := fileSHA256(entry.BackupPath)
+		if err != nil {
+			return cm.errorHandler.WrapError("原件备份不可读", err)
+		}
+		if hash != entry.SourceHash {
+			return fmt.Errorf("原件备份哈希不匹配: %s", entry.BackupPath)
+		}
+		// 原地替换时源路径上就是输出，由下面的输出校验确认未被修改
+		if _, err := os.Stat(entry.SourcePath); err == nil && entry.OutputPath != entry.SourcePath {
+			return fmt.Errorf("源路径已存在文件，拒绝覆盖: %s", entry.SourcePath)
+		}
+	} else {
+		// 原文件保留在原处，撤销只需删除输出；原文件必须仍是转换时的内容
+		hash, err := fileSHA256(entry.SourcePath)
+		if err != nil {
+			return cm.errorHandler.WrapError("原文件不可读", err)
+		}
+		if hash != entry.SourceHash {
+			return fmt.Errorf("原文件自转换后已被修改: %s", entry.SourcePath)
+		}
+	}
+
+	if force || (entry.OutputPath == entry.SourcePath && entry.BackupPath == "") {
+		return nil
+	}
+	hash, err := fileSHA256(entry.OutputPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return cm.errorHandler.WrapError("输出文件不可读", err)
+	}
+	if hash != entry.OutputHash {
+		return fmt.Errorf("输出文件自转换后已被修改（使用 --force 强制撤销）: %s", entry.OutputPath)
+	}
+	return nil
+}
+
+// restoreEntry 删除输出并将原件移回源路径（原地替换时原件直接覆盖输出），成功后删除日志条目与输出来源记录
+func (cm *CheckpointManager) restoreEntry(entry *JournalEntry) error {
+	if entry.OutputPath != entry.SourcePath {
+		if err := os.Remove(entry.OutputPath); err != nil && !os.IsNotExist(err) {
+			return cm.errorHandler.WrapError("删除输出文件失败", err)
+		}
+	}
+
+	if entry.BackupPath != "" {
+		if err := moveFile(entry.BackupPath, entry.SourcePath); err != nil {
+			return cm.errorHandler.WrapError("恢复原件失败", err)
+		}
+		removeEmptyOriginalsDirs(entry.BackupPath)
+	}
+	if err := cm.deleteOutput(entry.OutputPath); err != nil {
+		cm.logger.Warn("删除输出来源记录失败", zap.String("output", entry.OutputPath), zap.Error(err))
+	}
+
+	cm.logger.Info("已撤销转换",
+		zap.String("source", entry.SourcePath),
+		zap.String("output", entry.OutputPath))
+	return cm.deleteJournal(entry)
+}
+
+// matchesUndoPatterns 检查源文件是否匹配任一过滤模式
+func matchesUndoPatterns(path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+		// 目录前缀按路径分隔符对齐，/photos/2023 不匹配 /photos/2023-old 下的文件
+		prefix := strings.TrimSuffix(pattern, string(filepath.Separator))
+		if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// finalizeOriginal 原地转换成功后处理原文件：启用撤销时记录撤销日志，不保留原文件时将原件移入备份目录；
+// 未启用撤销时不保留的原文件直接删除，保证keep_original的效果与撤销开关无关
+func (c *Converter) finalizeOriginal(file *MediaFile, result *ConversionResult) {
+	if c.config.Output.DirectoryTemplate != "" || file.cacheHit {
+		return
+	}
+	if !result.Success || result.Skipped || result.OutputPath == "" || result.OutputPath == file.Path {
+		return
+	}
+
+	// RAW预览是旁车文件，RAW本身仍是原件；连拍动图由整组帧合成，各帧原文件保持不变。两者都保留原文件
+	keepOriginal := c.config.Output.KeepOriginal || result.Method == string(ActionRawPreview) || result.Method == string(ActionBurstAnimation)
+
+	sessionID := ""
+	if c.config.Undo.Enabled && c.checkpointMgr != nil {
+		sessionID = c.checkpointMgr.CurrentSessionID()
+	}
+	if sessionID != "" {
+		c.journalConversion(file, result, sessionID, keepOriginal)
+		return
+	}
+	if keepOriginal {
+		return
+	}
+
+	if err := c.fileOpHandler.SafeRemoveFile(file.Path); err != nil {
+		c.logger.Warn("删除原文件失败", zap.String("file", file.Path), zap.Error(err))
+		return
+	}
+	c.logger.Debug("已删除原文件", zap.String("file", file.Path))
+}
+
+// journalConversion 记录撤销日志；不保留原文件时将原件移入备份目录，任一步骤失败都保留原文件
+func (c *Converter) journalConversion(file *MediaFile, result *ConversionResult, sessionID string, keepOriginal bool) {
+	sourceHash, err := fileSHA256(file.Path)
+	if err != nil {
+		c.logger.Warn("计算原件哈希失败，不记录撤销日志", zap.String("file", file.Path), zap.Error(err))
+		return
+	}
+	outputHash, err := fileSHA256(result.OutputPath)
+	if err != nil {
+		c.logger.Warn("计算输出哈希失败，不记录撤销日志", zap.String("file", result.OutputPath), zap.Error(err))
+		return
+	}
+
+	entry := &JournalEntry{
+		SessionID:  sessionID,
+		SourcePath: file.Path,
+		SourceHash: sourceHash,
+		SourceSize: file.Size,
+		OutputPath: result.OutputPath,
+		OutputHash: outputHash,
+		RecordedAt: time.Now(),
+	}
+	if !keepOriginal {
+		entry.BackupPath = filepath.Join(filepath.Dir(file.Path), OriginalsDirName, sessionID, filepath.Base(file.Path))
+	}
+
+	// 先写日志再移动原件：移动失败时日志指向的备份不存在，撤销会报告而不是误删输出
+	if err := c.checkpointMgr.RecordJournal(entry); err != nil {
+		c.logger.Warn("写入撤销日志失败，保留原文件", zap.String("file", file.Path), zap.Error(err))
+		return
+	}
+	if entry.BackupPath == "" {
+		return
+	}
+
+	if err := c.fileOpHandler.SafeCreateDir(filepath.Dir(entry.BackupPath)); err != nil {
+		c.logger.Warn("创建原件备份目录失败，保留原文件", zap.String("file", file.Path), zap.Error(err))
+		c.checkpointMgr.deleteJournal(entry)
+		return
+	}
+	if err := moveFile(file.Path, entry.BackupPath); err != nil {
+		c.logger.Warn("移动原件到备份目录失败，保留原文件", zap.String("file", file.Path), zap.Error(err))
+		c.checkpointMgr.deleteJournal(entry)
+		return
+	}
+
+	c.logger.Debug("原件已移入备份目录",
+		zap.String("file", file.Path),
+		zap.String("backup", entry.BackupPath))
+}
+
+// replaceOriginal 以同目录的临时文件原子替换原文件，用于输出与原文件同名的原地转换（如DNG重新压缩为JPEG XL）：
+// 启用撤销时先写撤销日志并把原件链接到备份目录，再以重命名替换，任一步骤失败都保留原文件。
+// 替换后的文件记录为输出，监视模式不会把它当作新文件再次处理
+func (c *Converter) replaceOriginal(file *MediaFile, tempPath string) error {
+	var entry *JournalEntry
+	if c.config.Undo.Enabled && c.checkpointMgr != nil {
+		if sessionID := c.checkpointMgr.CurrentSessionID(); sessionID != "" {
+			sourceHash, err := fileSHA256(file.Path)
+			if err != nil {
+				return c.errorHandler.WrapError("failed to hash original file", err)
+			}
+			outputHash, err := fileSHA256(tempPath)
+			if err != nil {
+				return c.errorHandler.WrapError("failed to hash output file", err)
+			}
+			entry = &JournalEntry{
+				SessionID:  sessionID,
+				SourcePath: file.Path,
+				SourceHash: sourceHash,
+				SourceSize: file.Size,
+				OutputPath: file.Path,
+				OutputHash: outputHash,
+				BackupPath: filepath.Join(filepath.Dir(file.Path), OriginalsDirName, sessionID, filepath.Base(file.Path)),
+				RecordedAt: time.Now(),
+			}
+			if err := c.checkpointMgr.RecordJournal(entry); err != nil {
+				return err
+			}
+			if err := c.backupOriginal(file.Path, entry.BackupPath); err != nil {
+				c.checkpointMgr.deleteJournal(entry)
+				return c.errorHandler.WrapError("failed to back up original file", err)
+			}
+		}
+	}
+
+	if err := os.Rename(tempPath, file.Path); err != nil {
+		if entry != nil {
+			os.Remove(entry.BackupPath)
+			removeEmptyOriginalsDirs(entry.BackupPath)
+			c.checkpointMgr.deleteJournal(entry)
+		}
+		return c.errorHandler.WrapError("failed to replace original file", err)
+	}
+	c.recordOutputPath(file.Path, file)
+
+	if entry != nil {
+		c.logger.Debug("原件已备份并被原地替换",
+			zap.String("file", file.Path),
+			zap.String("backup", entry.BackupPath))
+	}
+	return nil
+}
+
+// backupOriginal 在原文件仍在原处时为其建立备份：优先硬链接，不支持时拷贝
+func (c *Converter) backupOriginal(path, backupPath string) error {
+	if err := c.fileOpHandler.SafeCreateDir(filepath.Dir(backupPath)); err != nil {
+		return err
+	}
+	if err := os.Link(path, backupPath); err == nil {
+		return nil
+	}
+	return copyFile(path, backupPath)
+}
+
+// pruneJournal 按保留期清理撤销日志
+func (c *Converter) pruneJournal() {
+	retention := time.Duration(c.config.Undo.RetentionDays) * 24 * time.Hour
+	pruned, err := c.checkpointMgr.PruneJournal(retention)
+	if err != nil {
+		c.logger.Warn("清理过期撤销日志失败", zap.Error(err))
+		return
+	}
+	if pruned > 0 {
+		c.logger.Info("已清理过期的撤销日志与原件备份", zap.Int("entries", pruned))
+	}
+}
+
+// fileSHA256 计算文件完整内容的SHA256
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// moveFile 移动文件，跨文件系统时退回为拷贝后删除
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyFile(from, to); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// copyFile 拷贝文件并保留权限与修改时间，目标已存在时失败
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	target, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	os.Chtimes(to, stat.ModTime(), stat.ModTime())
+	return nil
+}
+
+// removeEmptyOriginalsDirs 删除备份文件后清理空的会话目录与备份根目录
+func removeEmptyOriginalsDirs(backupPath string) {
+	sessionDir := filepath.Dir(backupPath)
+	if os.Remove(sessionDir) == nil {
+		os.Remove(filepath.Dir(sessionDir))
+	}
+}
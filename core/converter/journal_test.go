@@ -0,0 +1,312 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pixly/config"
+
+	"go.uber.org/zap"
+)
+
+// newTestCheckpoint 在临时目录中创建检查点并开始会话
+func newTestCheckpoint(t *testing.T, targetDir string) *CheckpointManager {
+	t.Helper()
+	cm, err := NewCheckpointManager(zap.NewNop(), filepath.Join(t.TempDir(), "checkpoint.db"), NewErrorHandler(zap.NewNop()))
+	if err != nil {
+		t.Fatalf("NewCheckpointManager: %v", err)
+	}
+	t.Cleanup(func() { cm.Close() })
+	if err := cm.StartSession(targetDir, "auto+", 1); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	return cm
+}
+
+// newJournalConverter 创建只用于处理原文件的转换器
+func newJournalConverter(cm *CheckpointManager, keepOriginal, undo bool) *Converter {
+	cfg := &config.Config{}
+	cfg.Output.KeepOriginal = keepOriginal
+	cfg.Undo.Enabled = undo
+	return &Converter{
+		config:        cfg,
+		logger:        zap.NewNop(),
+		errorHandler:  NewErrorHandler(zap.NewNop()),
+		fileOpHandler: NewFileOperationHandler(zap.NewNop()),
+		checkpointMgr: cm,
+	}
+}
+
+// convertInPlace 模拟一次原地转换：写入源文件与输出并交给finalizeOriginal处理
+func convertInPlace(t *testing.T, c *Converter, dir, method string) (*MediaFile, *ConversionResult) {
+	t.Helper()
+	source := filepath.Join(dir, "IMG_0001.png")
+	output := filepath.Join(dir, "IMG_0001.jxl")
+	if err := os.WriteFile(source, []byte("original png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("converted jxl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := &MediaFile{Path: source, Size: int64(len("original png"))}
+	result := &ConversionResult{OriginalFile: file, OutputPath: output, Success: true, Method: method}
+	c.finalizeOriginal(file, result)
+	return file, result
+}
+
+func TestFinalizeOriginal(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepOriginal bool
+		undo         bool
+		method       string
+		sourceKept   bool
+		journaled    bool
+		backedUp     bool
+	}{
+		{"启用撤销且不保留原文件", false, true, string(ActionJXLLossless), false, true, true},
+		{"启用撤销且保留原文件", true, true, string(ActionJXLLossless), true, true, false},
+		{"未启用撤销且不保留原文件", false, false, string(ActionJXLLossless), false, false, false},
+		{"未启用撤销且保留原文件", true, false, string(ActionJXLLossless), true, false, false},
+		{"RAW预览保留RAW", false, true, string(ActionRawPreview), true, true, false},
+		{"未启用撤销时RAW预览保留RAW", false, false, string(ActionRawPreview), true, false, false},
+		{"连拍动图保留各帧", false, false, string(ActionBurstAnimation), true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cm := newTestCheckpoint(t, dir)
+			c := newJournalConverter(cm, tt.keepOriginal, tt.undo)
+			file, _ := convertInPlace(t, c, dir, tt.method)
+
+			if _, err := os.Stat(file.Path); (err == nil) != tt.sourceKept {
+				t.Errorf("原文件保留 = %v, 期望 %v", err == nil, tt.sourceKept)
+			}
+			entries, err := cm.ListJournal(cm.CurrentSessionID())
+			if err != nil {
+				t.Fatalf("ListJournal: %v", err)
+			}
+			if (len(entries) == 1) != tt.journaled {
+				t.Fatalf("撤销日志条目 = %d, 期望记录 %v", len(entries), tt.journaled)
+			}
+			if !tt.journaled {
+				return
+			}
+			entry := entries[0]
+			if (entry.BackupPath != "") != tt.backedUp {
+				t.Errorf("BackupPath = %q, 期望备份 %v", entry.BackupPath, tt.backedUp)
+			}
+			if tt.backedUp {
+				data, err := os.ReadFile(entry.BackupPath)
+				if err != nil || string(data) != "original png" {
+					t.Errorf("原件备份内容 = %q, %v", data, err)
+				}
+				if want := filepath.Join(dir, OriginalsDirName, cm.CurrentSessionID(), "IMG_0001.png"); entry.BackupPath != want {
+					t.Errorf("BackupPath = %q, 期望 %q", entry.BackupPath, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFinalizeOriginalSkipsNonInPlace(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestCheckpoint(t, dir)
+	c := newJournalConverter(cm, false, false)
+	c.config.Output.DirectoryTemplate = filepath.Join(dir, "out")
+	file, _ := convertInPlace(t, c, dir, string(ActionJXLLossless))
+	if _, err := os.Stat(file.Path); err != nil {
+		t.Errorf("输出到其他目录时不应处理原文件: %v", err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep        bool
+		modify      func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry)
+		opts        UndoOptions
+		wantRestore bool
+		wantErr     string
+	}{
+		{"恢复备份的原件", false, nil, UndoOptions{}, true, ""},
+		{"保留原文件时只删除输出", true, nil, UndoOptions{}, true, ""},
+		{"只校验不执行", false, nil, UndoOptions{DryRun: true}, false, ""},
+		{"原件备份被修改", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.WriteFile(entry.BackupPath, []byte("tampered"), 0644)
+		}, UndoOptions{}, false, "原件备份哈希不匹配"},
+		{"原件备份丢失", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.Remove(entry.BackupPath)
+		}, UndoOptions{}, false, "原件备份不可读"},
+		{"源路径已被占用", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.WriteFile(file.Path, []byte("new file"), 0644)
+		}, UndoOptions{}, false, "源路径已存在文件"},
+		{"保留的原文件被修改", true, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.WriteFile(file.Path, []byte("edited"), 0644)
+		}, UndoOptions{}, false, "原文件自转换后已被修改"},
+		{"输出被修改", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.WriteFile(result.OutputPath, []byte("edited jxl"), 0644)
+		}, UndoOptions{}, false, "输出文件自转换后已被修改"},
+		{"输出被修改时强制撤销", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.WriteFile(result.OutputPath, []byte("edited jxl"), 0644)
+		}, UndoOptions{Force: true}, true, ""},
+		{"输出已被删除", false, func(t *testing.T, file *MediaFile, result *ConversionResult, entry *JournalEntry) {
+			os.Remove(result.OutputPath)
+		}, UndoOptions{}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cm := newTestCheckpoint(t, dir)
+			c := newJournalConverter(cm, tt.keep, true)
+			file, result := convertInPlace(t, c, dir, string(ActionJXLLossless))
+			sessionID := cm.CurrentSessionID()
+			entries, _ := cm.ListJournal(sessionID)
+			if len(entries) != 1 {
+				t.Fatalf("撤销日志条目 = %d, 期望 1", len(entries))
+			}
+			if tt.modify != nil {
+				tt.modify(t, file, result, entries[0])
+			}
+
+			results, err := cm.Undo(sessionID, tt.opts)
+			if err != nil {
+				t.Fatalf("Undo: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("撤销结果 = %d, 期望 1", len(results))
+			}
+			got := results[0]
+			if got.Restored != tt.wantRestore {
+				t.Errorf("Restored = %v, 期望 %v（%v）", got.Restored, tt.wantRestore, got.Error)
+			}
+			if tt.wantErr == "" && got.Error != nil {
+				t.Errorf("Error = %v, 期望无错误", got.Error)
+			}
+			if tt.wantErr != "" && (got.Error == nil || !strings.Contains(got.Error.Error(), tt.wantErr)) {
+				t.Errorf("Error = %v, 期望包含 %q", got.Error, tt.wantErr)
+			}
+
+			remaining, _ := cm.ListJournal(sessionID)
+			if !got.Restored {
+				if len(remaining) != 1 {
+					t.Errorf("未撤销时日志条目应保留，剩余 %d", len(remaining))
+				}
+				return
+			}
+			data, err := os.ReadFile(file.Path)
+			if err != nil || string(data) != "original png" {
+				t.Errorf("恢复后的原文件 = %q, %v", data, err)
+			}
+			if _, err := os.Stat(result.OutputPath); !os.IsNotExist(err) {
+				t.Error("撤销后输出应被删除")
+			}
+			if len(remaining) != 0 {
+				t.Errorf("撤销后日志条目应删除，剩余 %d", len(remaining))
+			}
+			if _, err := os.Stat(filepath.Join(dir, OriginalsDirName)); !os.IsNotExist(err) {
+				t.Error("撤销后空的备份目录应被清理")
+			}
+		})
+	}
+}
+
+func TestUndoUnknownSession(t *testing.T) {
+	cm := newTestCheckpoint(t, t.TempDir())
+	if _, err := cm.Undo("missing", UndoOptions{}); err == nil {
+		t.Error("没有记录的会话应返回错误")
+	}
+}
+
+func TestUndoPatterns(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestCheckpoint(t, dir)
+	sessionID := cm.CurrentSessionID()
+	for _, path := range []string{"/photos/2023/a.png", "/photos/2023-old/b.png", "/photos/c.jpg"} {
+		if err := cm.RecordJournal(&JournalEntry{SessionID: sessionID, SourcePath: path, OutputPath: path + ".jxl"}); err != nil {
+			t.Fatalf("RecordJournal: %v", err)
+		}
+	}
+
+	results, err := cm.Undo(sessionID, UndoOptions{Patterns: []string{"/photos/2023"}, DryRun: true})
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if len(results) != 1 || results[0].Entry.SourcePath != "/photos/2023/a.png" {
+		var paths []string
+		for _, result := range results {
+			paths = append(paths, result.Entry.SourcePath)
+		}
+		t.Errorf("按目录过滤撤销 = %v, 期望只有 /photos/2023/a.png", paths)
+	}
+}
+
+func TestMatchesUndoPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		patterns []string
+		want     bool
+	}{
+		{"无模式时全部匹配", "/photos/a.png", nil, true},
+		{"完整路径glob", "/photos/a.png", []string{"/photos/*.png"}, true},
+		{"文件名glob", "/photos/2023/a.png", []string{"*.png"}, true},
+		{"文件名不匹配", "/photos/a.jpg", []string{"*.png"}, false},
+		{"目录前缀", "/photos/2023/a.png", []string{"/photos/2023"}, true},
+		{"带结尾分隔符的目录前缀", "/photos/2023/a.png", []string{"/photos/2023/"}, true},
+		{"嵌套目录", "/photos/2023/trip/a.png", []string{"/photos/2023"}, true},
+		{"同名前缀的兄弟目录", "/photos/2023-old/a.png", []string{"/photos/2023"}, false},
+		{"文件名前缀不算目录", "/photos/2023.png", []string{"/photos/2023"}, false},
+		{"完整路径相等", "/photos/a.png", []string{"/photos/a.png"}, true},
+		{"任一模式匹配", "/photos/a.png", []string{"*.jpg", "/photos"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesUndoPatterns(filepath.FromSlash(tt.path), tt.patterns); got != tt.want {
+				t.Errorf("matchesUndoPatterns(%q, %v) = %v, 期望 %v", tt.path, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileSHA256(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.bin")
+	os.WriteFile(path, []byte("abc"), 0644)
+	hash, err := fileSHA256(path)
+	if err != nil {
+		t.Fatalf("fileSHA256: %v", err)
+	}
+	if want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; hash != want {
+		t.Errorf("fileSHA256 = %s, 期望 %s", hash, want)
+	}
+	if _, err := fileSHA256(filepath.Join(dir, "missing")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestPruneJournal(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestCheckpoint(t, dir)
+	c := newJournalConverter(cm, false, true)
+	convertInPlace(t, c, dir, string(ActionJXLLossless))
+	sessionID := cm.CurrentSessionID()
+
+	if pruned, err := cm.PruneJournal(time.Hour); err != nil || pruned != 0 {
+		t.Errorf("PruneJournal(1h) = %d, %v, 期望未过期条目不清理", pruned, err)
+	}
+	entries, _ := cm.ListJournal(sessionID)
+	pruned, err := cm.PruneJournal(0)
+	if err != nil || pruned != 1 {
+		t.Fatalf("PruneJournal(0) = %d, %v, 期望清理 1 条", pruned, err)
+	}
+	if _, err := os.Stat(entries[0].BackupPath); !os.IsNotExist(err) {
+		t.Error("过期条目的原件备份应被删除")
+	}
+	if _, err := os.Stat(filepath.Join(dir, OriginalsDirName)); !os.IsNotExist(err) {
+		t.Error("空的备份目录应被清理")
+	}
+}
@@ -0,0 +1,304 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// livePhotoPair Live Photo配对：静态图的任务负责整对转换，配对的MOV不单独进入任务队列
+type livePhotoPair struct {
+	Still      *MediaFile
+	Motion     *MediaFile // 需要随静态图转换的视频；已是目标格式而保持原样时为nil
+	Identifier string     // Apple ContentIdentifier
+}
+
+// livePhotoStillExts 可能作为Live Photo静态图的格式
+var livePhotoStillExts = map[string]bool{".heic": true, ".heif": true, ".jpg": true, ".jpeg": true}
+
+// livePhotoMotionExts 能保存ContentIdentifier（QuickTime keys）的视频容器
+var livePhotoMotionExts = map[string]bool{".mov": true, ".mp4": true}
+
+// pairLivePhotos 按ContentIdentifier将同目录下的静态图与MOV配对，返回去掉已配对MOV的任务列表；
+// exiftool不可用而无法读取静态图标识时，按同名且带标识的MOV配对。
+// skipped为扫描阶段已作为目标格式跳过的文件：其中的MOV同样参与配对，静态图转换时仍保留配对标识，
+// 视频本身保持原样
+func (c *Converter) pairLivePhotos(files, skipped []*MediaFile) []*MediaFile {
+	type motionKey struct{ dir, identifier string }
+	byIdentifier := make(map[motionKey]*MediaFile)
+	byStem := make(map[string]*MediaFile)
+	identifiers := make(map[*MediaFile]string)
+	unchanged := make(map[*MediaFile]bool)
+	dirs := make(map[string]bool)
+	for _, file := range skipped {
+		unchanged[file] = true
+	}
+	candidates := make([]*MediaFile, 0, len(skipped)+len(files))
+	candidates = append(append(candidates, skipped...), files...)
+	for _, file := range candidates {
+		if strings.ToLower(file.Extension) != ".mov" {
+			continue
+		}
+		identifier, err := c.prober.Get(file.Path).ContentIdentifier(c.ctx)
+		if err != nil || identifier == "" {
+			continue
+		}
+		dir := filepath.Dir(file.Path)
+		byIdentifier[motionKey{dir, identifier}] = file
+		byStem[livePhotoStem(file.Path)] = file
+		identifiers[file] = identifier
+		dirs[dir] = true
+	}
+	if len(identifiers) == 0 {
+		return files
+	}
+
+	paired := make(map[*MediaFile]bool)
+	for _, still := range files {
+		dir := filepath.Dir(still.Path)
+		if !livePhotoStillExts[strings.ToLower(still.Extension)] || !dirs[dir] {
+			continue
+		}
+		var motion *MediaFile
+		identifier, err := c.prober.Get(still.Path).ContentIdentifier(c.ctx)
+		switch {
+		case err != nil:
+			motion = byStem[livePhotoStem(still.Path)]
+			c.logger.Debug("无法读取静态图的ContentIdentifier，按文件名配对Live Photo",
+				zap.String("file", still.Path), zap.Error(err))
+		case identifier != "":
+			motion = byIdentifier[motionKey{dir, identifier}]
+		}
+		if motion == nil || paired[motion] {
+			continue
+		}
+
+		pair := &livePhotoPair{Still: still, Identifier: identifiers[motion]}
+		if !unchanged[motion] {
+			pair.Motion = motion
+			motion.livePhoto = pair
+		}
+		still.livePhoto = pair
+		paired[motion] = true
+	}
+	if len(paired) == 0 {
+		return files
+	}
+
+	remaining := files[:0]
+	for _, file := range files {
+		if !paired[file] {
+			remaining = append(remaining, file)
+		}
+	}
+	c.logger.Info("检测到Live Photo配对，静态图与视频将作为一个单元转换", zap.Int("pairs", len(paired)))
+	return remaining
+}
+
+// livePhotoStem 不含扩展名的小写路径，用于同名配对
+func livePhotoStem(path string) string {
+	return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+}
+
+// markLivePhotoRoute 为Live Photo成员与Motion Photo标记路由：执行时保留配对标识，
+// 静态图还会一并处理视频；保留不了配对标识的转换改为跳过
+func (c *Converter) markLivePhotoRoute(file *MediaFile, route Route) Route {
+	if route.Action == ActionSkip {
+		return route
+	}
+
+	if file.Type == TypeVideo {
+		if file.livePhoto == nil {
+			details, err := c.detectFileType(file)
+			if err != nil || details.FileType != FileTypeLivePhoto {
+				return route
+			}
+		}
+		if !livePhotoMotionExts[route.TargetExt] {
+			return skipRoute(fmt.Sprintf("Live Photo视频的目标容器%s无法保存配对标识", route.TargetExt))
+		}
+		route.LivePhoto = true
+		route.Reason = "Live Photo视频（保留配对标识）：" + route.Reason
+		return route
+	}
+
+	switch {
+	case file.livePhoto != nil:
+		if _, err := exec.LookPath(c.config.Tools.ExiftoolPath); err != nil {
+			return skipRoute("缺少exiftool，无法在转换后保留Live Photo配对标识")
+		}
+		route.Reason = "Live Photo配对转换，静态图：" + route.Reason
+	case c.motionPhoto(file) != nil:
+		route.Reason = "Motion Photo，嵌入视频提取为同名MOV，静态图：" + route.Reason
+	default:
+		return route
+	}
+	route.LivePhoto = true
+	return route
+}
+
+// motionPhoto 返回JPEG尾部嵌入的Motion Photo视频，没有时为nil
+func (c *Converter) motionPhoto(file *MediaFile) *mediaprobe.MotionPhoto {
+	info, err := c.prober.Get(file.Path).Header()
+	if err != nil {
+		return nil
+	}
+	return info.MotionPhoto
+}
+
+// executeLivePhoto 执行带配对标识的路由：转换后检查并恢复ContentIdentifier，Motion Photo先提取嵌入视频
+func (c *Converter) executeLivePhoto(file *MediaFile, route Route) (string, error) {
+	route.LivePhoto = false
+	identifier, err := c.prober.Get(file.Path).ContentIdentifier(c.ctx)
+	if err != nil {
+		c.logger.Debug("读取ContentIdentifier失败", zap.String("file", file.Path), zap.Error(err))
+	}
+
+	if file.Type == TypeVideo {
+		outputPath, err := c.executeRoute(file, route)
+		if err != nil || identifier == "" {
+			return outputPath, err
+		}
+		// 原地重包装会覆盖源文件，输出与源路径相同时同样需要检查
+		if err := c.restoreMotionIdentifier(outputPath, identifier); err != nil {
+			if outputPath != file.Path {
+				os.Remove(outputPath)
+			}
+			return "", err
+		}
+		return outputPath, nil
+	}
+
+	var motionPath string
+	if motion := c.motionPhoto(file); motion != nil {
+		motionPath, err = c.extractMotionPhoto(file, motion)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	outputPath, err := c.executeRoute(file, route)
+	if err == nil && outputPath != file.Path && identifier != "" {
+		if err = c.restoreStillIdentifier(file.Path, outputPath, identifier); err != nil {
+			os.Remove(outputPath)
+		}
+	}
+	if motionPath != "" && (err != nil || outputPath == file.Path) {
+		os.Remove(motionPath) // 静态图未转换时不留下单独的视频
+	}
+	if err != nil {
+		return "", err
+	}
+	if motionPath != "" {
+		// 提取的视频不是任何源文件的主输出，单独登记来源，监视模式不会把它当作新文件再次处理
+		c.recordOutputPath(motionPath, file)
+	}
+	return outputPath, nil
+}
+
+// processLivePhotoCompanion 静态图处理完成后处理配对的视频；静态图未转换时视频也保持原样，
+// 视频的结果与统计由其自身的processFile记录
+func (c *Converter) processLivePhotoCompanion(still *MediaFile, result *ConversionResult) {
+	pair := still.livePhoto
+	if pair == nil || pair.Still != still || pair.Motion == nil {
+		return
+	}
+	if !result.Success || result.Skipped {
+		route := skipRoute("Live Photo静态图未转换，视频保持原样以维持配对")
+		pair.Motion.route = &route
+	}
+	pair.Motion.ctx = still.ctx
+	c.processFile(pair.Motion)
+}
+
+// extractMotionPhoto 将Motion Photo尾部的视频流复制重包装为与静态图同名的MOV
+func (c *Converter) extractMotionPhoto(file *MediaFile, motion *mediaprobe.MotionPhoto) (string, error) {
+	source, err := os.Open(file.Path)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to open motion photo", err)
+	}
+	defer source.Close()
+
+	temp, err := os.CreateTemp("", "pixly_motion_*.mp4")
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to create temp file", err)
+	}
+	defer os.Remove(temp.Name())
+	_, err = io.Copy(temp, io.NewSectionReader(source, motion.Offset, motion.Length))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to extract motion photo video", err)
+	}
+
+	outputPath := c.getOutputPath(file, ".mov")
+	tempPath := outputPath + ".tmp"
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+	defer os.Remove(tempPath)
+
+	args := []string{"-hide_banner", "-nostats", "-y", "-i", temp.Name(),
+		"-map", "0", "-c", "copy", "-map_metadata", "0",
+		"-movflags", "+faststart+use_metadata_tags", "-f", "mov", tempPath}
+	if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.FFmpegPath, args...); err != nil {
+		return "", c.errorHandler.WrapErrorWithOutput("motion photo video remux failed", err, output)
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", err
+	}
+
+	c.logger.Debug("已提取Motion Photo视频",
+		zap.String("file", file.Path),
+		zap.String("vendor", motion.Vendor),
+		zap.String("output", outputPath))
+	return outputPath, nil
+}
+
+// restoreStillIdentifier 确保静态图输出带有ContentIdentifier：缺失时从源文件迁移全部元数据（含MakerNotes）
+func (c *Converter) restoreStillIdentifier(sourcePath, outputPath, identifier string) error {
+	if c.outputContentIdentifier(outputPath) == identifier {
+		return nil
+	}
+	if err := c.metadataManager.MigrateMetadata(sourcePath, outputPath); err != nil {
+		return err
+	}
+	if c.outputContentIdentifier(outputPath) != identifier {
+		return fmt.Errorf("输出未保留Live Photo配对标识: %s", outputPath)
+	}
+	return nil
+}
+
+// restoreMotionIdentifier 确保视频输出的QuickTime keys中带有ContentIdentifier，缺失时用exiftool写回
+func (c *Converter) restoreMotionIdentifier(outputPath, identifier string) error {
+	if c.outputContentIdentifier(outputPath) == identifier {
+		return nil
+	}
+	cmd := exec.CommandContext(c.ctx, c.config.Tools.ExiftoolPath,
+		"-overwrite_original", "-Keys:ContentIdentifier="+identifier, outputPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return c.errorHandler.WrapErrorWithOutput("写入Live Photo配对标识失败", err, output)
+	}
+	if c.outputContentIdentifier(outputPath) != identifier {
+		return fmt.Errorf("输出未保留Live Photo配对标识: %s", outputPath)
+	}
+	return nil
+}
+
+// outputContentIdentifier 读取输出文件的ContentIdentifier；输出会被重命名或再次修改，不保留在共享探测缓存中
+func (c *Converter) outputContentIdentifier(path string) string {
+	identifier, err := c.prober.Get(path).ContentIdentifier(c.ctx)
+	c.prober.Forget(path)
+	if err != nil {
+		c.logger.Debug("读取输出的ContentIdentifier失败", zap.String("output", path), zap.Error(err))
+	}
+	return identifier
+}
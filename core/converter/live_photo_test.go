@@ -0,0 +1,343 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"pixly/config"
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// fakeExiftoolScript 模拟exiftool：静态图的ContentIdentifier保存在同名.id文件中（内容为error时读取失败），
+// 迁移元数据时复制.id文件，写入视频标识时用预置的.restored文件替换输出
+const fakeExiftoolScript = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/exiftool.calls"
+case "$1" in
+-json)
+	[ "$(cat "$2.id" 2>/dev/null)" = error ] && exit 1
+	if [ -f "$2.id" ]; then printf '[{"ContentIdentifier":"%s"}]' "$(cat "$2.id")"; else echo '[{}]'; fi ;;
+-TagsFromFile)
+	[ -f "$2.id" ] && cp "$2.id" "$5.id" ;;
+-overwrite_original)
+	[ -f "$3.restored" ] || exit 1
+	cp "$3.restored" "$3" ;;
+esac
+exit 0
+`
+
+// qtBox 构造QuickTime盒
+func qtBox(kind string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, kind...), body...)
+}
+
+// liveMovie 带ContentIdentifier（为空时不带）的最小QuickTime影片
+func liveMovie(identifier string) []byte {
+	ftyp := qtBox("ftyp", []byte("qt  "), make([]byte, 4), []byte("qt  "))
+	moov := [][]byte{qtBox("mvhd", make([]byte, 100))}
+	if identifier != "" {
+		const key = "com.apple.quicktime.content.identifier"
+		keys := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+		keys = binary.BigEndian.AppendUint32(keys, uint32(8+len(key)))
+		keys = append(append(keys, "mdta"...), key...)
+		item := qtBox(string([]byte{0, 0, 0, 1}), qtBox("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(identifier)))
+		hdlr := qtBox("hdlr", make([]byte, 8), []byte("mdta"), make([]byte, 13))
+		moov = append(moov, qtBox("meta", hdlr, qtBox("keys", keys), qtBox("ilst", item)))
+	}
+	return bytes.Join([][]byte{ftyp, qtBox("moov", moov...), qtBox("mdat", make([]byte, 64))}, nil)
+}
+
+// newLivePhotoConverter 创建使用模拟exiftool的转换器，返回转换器与exiftool调用记录文件
+func newLivePhotoConverter(t *testing.T) (*Converter, string) {
+	t.Helper()
+	toolDir := t.TempDir()
+	exiftool := filepath.Join(toolDir, "exiftool")
+	if err := os.WriteFile(exiftool, []byte(fakeExiftoolScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Tools.ExiftoolPath = exiftool
+	logger := zap.NewNop()
+	errorHandler := NewErrorHandler(logger)
+	toolManager := NewToolManager(cfg, logger, errorHandler)
+	prober := mediaprobe.NewProber("", exiftool)
+	return &Converter{
+		config:           cfg,
+		ctx:              context.Background(),
+		logger:           logger,
+		errorHandler:     errorHandler,
+		toolManager:      toolManager,
+		prober:           prober,
+		fileTypeDetector: NewFileTypeDetector(cfg, logger, toolManager, prober),
+		metadataManager:  NewMetadataManager(logger, cfg, errorHandler, prober),
+	}, filepath.Join(toolDir, "exiftool.calls")
+}
+
+// liveFile Live Photo测试文件：名称、ContentIdentifier（静态图"error"表示exiftool读取失败）与是否已在扫描时跳过
+type liveFile struct {
+	name       string
+	identifier string
+	skipped    bool
+}
+
+// writeLiveFiles 写入静态图（JPEG内容，标识写入.id文件）与MOV（标识写入容器元数据）
+func writeLiveFiles(t *testing.T, dir string, specs []liveFile) (files, skipped []*MediaFile) {
+	t.Helper()
+	for _, spec := range specs {
+		path := filepath.Join(dir, spec.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		file := &MediaFile{Path: path, Name: filepath.Base(path), Extension: filepath.Ext(path), Type: TypeImage}
+		if strings.EqualFold(file.Extension, ".mov") {
+			file.Type = TypeVideo
+			if err := os.WriteFile(path, liveMovie(spec.identifier), 0644); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			writeJPEG(t, path, 0, "")
+			if spec.identifier != "" {
+				if err := os.WriteFile(path+".id", []byte(spec.identifier), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if spec.skipped {
+			skipped = append(skipped, file)
+		} else {
+			files = append(files, file)
+		}
+	}
+	return files, skipped
+}
+
+func TestPairLivePhotos(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []liveFile
+		pairs     map[string]string // 静态图 -> 随之转换的视频（已跳过的视频为"-"）
+		remaining []string
+	}{
+		{"按标识配对", []liveFile{{"IMG_0001.HEIC", "A", false}, {"IMG_0001.MOV", "A", false}},
+			map[string]string{"IMG_0001.HEIC": "IMG_0001.MOV"}, []string{"IMG_0001.HEIC"}},
+		{"标识相同文件名不同", []liveFile{{"IMG_0002.JPG", "B", false}, {"IMG_E0002.MOV", "B", false}},
+			map[string]string{"IMG_0002.JPG": "IMG_E0002.MOV"}, []string{"IMG_0002.JPG"}},
+		{"同名但标识不同", []liveFile{{"IMG_0003.HEIC", "C", false}, {"IMG_0003.MOV", "D", false}},
+			nil, []string{"IMG_0003.HEIC", "IMG_0003.MOV"}},
+		{"静态图没有标识", []liveFile{{"IMG_0004.HEIC", "", false}, {"IMG_0004.MOV", "E", false}},
+			nil, []string{"IMG_0004.HEIC", "IMG_0004.MOV"}},
+		{"无法读取标识时按同名配对", []liveFile{{"IMG_0005.heic", "error", false}, {"IMG_0005.mov", "F", false}},
+			map[string]string{"IMG_0005.heic": "IMG_0005.mov"}, []string{"IMG_0005.heic"}},
+		{"视频没有标识", []liveFile{{"IMG_0006.HEIC", "G", false}, {"IMG_0006.MOV", "", false}},
+			nil, []string{"IMG_0006.HEIC", "IMG_0006.MOV"}},
+		{"不同目录不配对", []liveFile{{"a/IMG_0007.HEIC", "H", false}, {"b/IMG_0007.MOV", "H", false}},
+			nil, []string{"a/IMG_0007.HEIC", "b/IMG_0007.MOV"}},
+		{"已跳过的视频只保留标识", []liveFile{{"IMG_0008.JPG", "I", false}, {"IMG_0008.MOV", "I", true}},
+			map[string]string{"IMG_0008.JPG": "-"}, []string{"IMG_0008.JPG"}},
+		{"一个视频只配对一次", []liveFile{{"IMG_0009.HEIC", "J", false}, {"IMG_0009.JPG", "J", false}, {"IMG_0009.MOV", "J", false}},
+			map[string]string{"IMG_0009.HEIC": "IMG_0009.MOV"}, []string{"IMG_0009.HEIC", "IMG_0009.JPG"}},
+		{"PNG不作为静态图", []liveFile{{"IMG_0010.PNG", "K", false}, {"IMG_0010.MOV", "K", false}},
+			nil, []string{"IMG_0010.MOV", "IMG_0010.PNG"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newLivePhotoConverter(t)
+			dir := t.TempDir()
+			files, skipped := writeLiveFiles(t, dir, tt.files)
+			all := append(append([]*MediaFile{}, files...), skipped...)
+
+			remaining := c.pairLivePhotos(files, skipped)
+			var names []string
+			for _, file := range remaining {
+				rel, _ := filepath.Rel(dir, file.Path)
+				names = append(names, filepath.ToSlash(rel))
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.remaining, ",") {
+				t.Errorf("剩余任务 = %v, 期望 %v", names, tt.remaining)
+			}
+
+			for _, file := range all {
+				rel, _ := filepath.Rel(dir, file.Path)
+				want, paired := tt.pairs[filepath.ToSlash(rel)]
+				if file.Type == TypeVideo {
+					continue
+				}
+				if !paired {
+					if file.livePhoto != nil {
+						t.Errorf("%s 不应配对", rel)
+					}
+					continue
+				}
+				pair := file.livePhoto
+				if pair == nil || pair.Still != file {
+					t.Fatalf("%s 应作为静态图配对: %+v", rel, pair)
+				}
+				if want == "-" {
+					if pair.Motion != nil {
+						t.Errorf("已跳过的视频不应随静态图转换: %s", pair.Motion.Path)
+					}
+				} else if pair.Motion == nil || pair.Motion.Name != want || pair.Motion.livePhoto != pair {
+					t.Errorf("%s 配对的视频 = %+v, 期望 %s", rel, pair.Motion, want)
+				}
+				var spec liveFile
+				for _, f := range tt.files {
+					if strings.EqualFold(filepath.Ext(f.name), ".mov") {
+						spec = f
+					}
+				}
+				if pair.Identifier != spec.identifier {
+					t.Errorf("Identifier = %q, 期望视频的标识 %q", pair.Identifier, spec.identifier)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkLivePhotoRoute(t *testing.T) {
+	dir := t.TempDir()
+	files, _ := writeLiveFiles(t, dir, []liveFile{{"IMG_0001.HEIC", "A", false}, {"IMG_0001.MOV", "A", false}, {"plain.jpg", "", false}, {"clip.mov", "", false}})
+	still, motion, plain, clip := files[0], files[1], files[2], files[3]
+	pair := &livePhotoPair{Still: still, Motion: motion, Identifier: "A"}
+	still.livePhoto, motion.livePhoto = pair, pair
+
+	// Motion Photo：XMP声明MicroVideo，视频附加在JPEG之后
+	movie := liveMovie("")
+	motionPhoto := filepath.Join(dir, "PXL_0001.jpg")
+	writeJPEG(t, motionPhoto, 0xE1, fmt.Sprintf("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta GCamera:MicroVideo=\"1\" GCamera:MicroVideoOffset=\"%d\"/>", len(movie)))
+	data, _ := os.ReadFile(motionPhoto)
+	if err := os.WriteFile(motionPhoto, append(data, movie...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	google := &MediaFile{Path: motionPhoto, Name: "PXL_0001.jpg", Extension: ".jpg", Type: TypeImage}
+
+	convert := Route{Strategy: RouteStrategyBalanced, Action: ActionBalanced, TargetExt: ".avif", Reason: "转换"}
+	remux := Route{Strategy: RouteStrategyVideo, Action: ActionMOVRemux, TargetExt: ".mov", Reason: "重包装"}
+	transcode := Route{Strategy: RouteStrategyVideo, Action: ActionVideoTranscode, TargetExt: ".mkv", Reason: "重新编码"}
+
+	tests := []struct {
+		name       string
+		file       *MediaFile
+		route      Route
+		noExiftool bool
+		wantAction RouteAction
+		wantLive   bool
+		wantReason string
+	}{
+		{"跳过路由不变", still, skipRoute("已是目标格式"), false, ActionSkip, false, "已是目标格式"},
+		{"配对静态图", still, convert, false, ActionBalanced, true, "Live Photo配对转换"},
+		{"缺少exiftool时跳过静态图", still, convert, true, ActionSkip, false, "缺少exiftool"},
+		{"配对视频重包装为MOV", motion, remux, false, ActionMOVRemux, true, "保留配对标识"},
+		{"配对视频的目标容器无法保存标识", motion, transcode, false, ActionSkip, false, ".mkv"},
+		{"普通照片", plain, convert, false, ActionBalanced, false, "转换"},
+		{"普通视频", clip, transcode, false, ActionVideoTranscode, false, "重新编码"},
+		{"Motion Photo", google, convert, false, ActionBalanced, true, "Motion Photo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newLivePhotoConverter(t)
+			if tt.noExiftool {
+				c.config.Tools.ExiftoolPath = filepath.Join(dir, "missing-exiftool")
+			}
+			got := c.markLivePhotoRoute(tt.file, tt.route)
+			if got.Action != tt.wantAction || got.LivePhoto != tt.wantLive {
+				t.Errorf("markLivePhotoRoute = %s/%v, 期望 %s/%v", got.Action, got.LivePhoto, tt.wantAction, tt.wantLive)
+			}
+			if !strings.Contains(got.Reason, tt.wantReason) {
+				t.Errorf("Reason = %q, 期望包含 %q", got.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRestoreStillIdentifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		sourceID    string // 源文件的标识，迁移元数据时复制到输出
+		outputID    string
+		wantErr     bool
+		wantMigrate bool
+	}{
+		{"输出已带标识", "A", "A", false, false},
+		{"迁移元数据后恢复", "A", "", false, true},
+		{"输出标识不同时迁移", "A", "B", false, true},
+		{"迁移后仍缺少标识", "", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, calls := newLivePhotoConverter(t)
+			dir := t.TempDir()
+			source := filepath.Join(dir, "IMG_0001.HEIC")
+			output := filepath.Join(dir, "IMG_0001.avif")
+			writeJPEG(t, source, 0, "")
+			writeJPEG(t, output, 0, "")
+			for path, id := range map[string]string{source: tt.sourceID, output: tt.outputID} {
+				if id != "" {
+					os.WriteFile(path+".id", []byte(id), 0644)
+				}
+			}
+
+			err := c.restoreStillIdentifier(source, output, "A")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("restoreStillIdentifier err = %v, 期望错误 %v", err, tt.wantErr)
+			}
+			log, _ := os.ReadFile(calls)
+			if migrated := strings.Contains(string(log), "-TagsFromFile"); migrated != tt.wantMigrate {
+				t.Errorf("迁移元数据 = %v, 期望 %v", migrated, tt.wantMigrate)
+			}
+			// 输出的标识不应留在共享探测缓存中
+			if err == nil {
+				os.Remove(output + ".id")
+				if got := c.outputContentIdentifier(output); got != "" {
+					t.Errorf("输出标识被缓存: %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreMotionIdentifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string // 输出视频中的标识
+		restored []byte // exiftool写入后的内容，nil表示写入失败
+		wantErr  bool
+		wantCall bool
+	}{
+		{"输出已带标识", "A", nil, false, false},
+		{"写回标识", "", liveMovie("A"), false, true},
+		{"写入失败", "", nil, true, true},
+		{"写入后仍缺少标识", "", liveMovie(""), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, calls := newLivePhotoConverter(t)
+			output := filepath.Join(t.TempDir(), "IMG_0001.mov")
+			if err := os.WriteFile(output, liveMovie(tt.output), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.restored != nil {
+				os.WriteFile(output+".restored", tt.restored, 0644)
+			}
+
+			err := c.restoreMotionIdentifier(output, "A")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("restoreMotionIdentifier err = %v, 期望错误 %v", err, tt.wantErr)
+			}
+			log, _ := os.ReadFile(calls)
+			if called := strings.Contains(string(log), "-Keys:ContentIdentifier=A"); called != tt.wantCall {
+				t.Errorf("写入标识 = %v, 期望 %v (%s)", called, tt.wantCall, log)
+			}
+		})
+	}
+}
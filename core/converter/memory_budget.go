@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"pixly/pkg/scheduler"
+
+	"go.uber.org/zap"
+)
+
+// newMemoryScheduler 按并发配置的内存限制、cgroup限制与主机内存创建编码任务的内存调度器。
+// 它是并发的唯一内存限制：工作池在有任务等待内存准入时不再自动扩容
+func newMemoryScheduler(c *Converter) *scheduler.MemoryScheduler {
+	budget := scheduler.MemoryBudget(c.config.Concurrency.MemoryLimit)
+	c.logger.Debug("编码任务内存预算",
+		zap.Uint64("budget_mb", budget>>20),
+		zap.Int("memory_limit_mb", c.config.Concurrency.MemoryLimit))
+
+	memoryScheduler := scheduler.NewMemoryScheduler(budget, c.logger)
+	if c.advancedPool != nil {
+		c.advancedPool.SetMemoryScheduler(memoryScheduler)
+	}
+	return memoryScheduler
+}
+
+// toolJob 文件的工具进程参数：路由执行中为其内存准入与分配的线程数，否则不登记并使用策略的默认线程数
+func (c *Converter) toolJob(file *MediaFile) ToolJob {
+	if file != nil && file.job != nil {
+		return *file.job
+	}
+	return ToolJob{Threads: c.threadPlanner.DefaultThreads()}
+}
+
+// scheduledEncoder 路由实际使用的编码器：动态AVIF与视频由ffmpeg编码，视频重包装不解码
+func (c *Converter) scheduledEncoder(file *MediaFile, route Route) string {
+	switch {
+	case route.Action == ActionDNGJXL:
+		return scheduler.EncoderDNG
+	case route.Action == ActionMOVRemux || route.Action == ActionVideoContainer:
+		return scheduler.EncoderRemux
+	case file.Type == TypeVideo:
+		return scheduler.EncoderFFmpeg
+	case route.TargetExt == ".jxl":
+		return scheduler.EncoderCjxl
+	case route.Action == ActionBurstAnimation || c.isAnimated(file.Path):
+		return scheduler.EncoderFFmpeg
+	default:
+		return scheduler.EncoderAvifenc
+	}
+}
+
+// schedulerJob 路由的内存特征：像素数与帧数来自共享探测结果，连拍动图按各帧计
+func (c *Converter) schedulerJob(file *MediaFile, route Route) scheduler.Job {
+	job := scheduler.Job{
+		Encoder: c.scheduledEncoder(file, route),
+		Frames:  1,
+		Size:    file.Size,
+	}
+	if sample, ok := c.timingSample(file, route); ok {
+		job.Pixels = sample.Pixels
+		job.Frames = sample.Frames
+		job.Effort = sample.Effort
+	}
+	if len(route.Frames) > 1 {
+		job.Frames = len(route.Frames)
+	}
+	return job
+}
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"pixly/config"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -20,14 +22,19 @@ type MetadataManager struct {
 	logger       *zap.Logger
 	config       *config.Config
 	errorHandler *ErrorHandler
+	prober       *mediaprobe.Prober // 共享探测缓存，拍摄信息复用同一次exiftool输出
 }
 
 // NewMetadataManager 创建元数据管理器实例
-func NewMetadataManager(logger *zap.Logger, config *config.Config, errorHandler *ErrorHandler) *MetadataManager {
+func NewMetadataManager(logger *zap.Logger, config *config.Config, errorHandler *ErrorHandler, prober *mediaprobe.Prober) *MetadataManager {
+	if prober == nil {
+		prober = mediaprobe.NewProber(config.Tools.FFprobePath, config.Tools.ExiftoolPath)
+	}
 	return &MetadataManager{
 		logger:       logger,
 		config:       config,
 		errorHandler: errorHandler,
+		prober:       prober,
 	}
 }
 
@@ -209,6 +216,43 @@ func (mm *MetadataManager) GetMetadata(filePath string) (map[string]string, erro
 	return metadata, nil
 }
 
+// exifDateLayout EXIF日期时间格式
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// GetCaptureInfo 读取拍摄时间（DateTimeOriginal）与相机型号（Make/Model）
+// 缺失的字段返回零值，调用方负责回退
+func (mm *MetadataManager) GetCaptureInfo(filePath string) (time.Time, string, error) {
+	if _, err := exec.LookPath(mm.config.Tools.ExiftoolPath); err != nil {
+		return time.Time{}, "", mm.errorHandler.WrapError("exiftool不可用", err)
+	}
+
+	// 复用文件的共享exiftool结果（exiftool -json file）
+	exif, err := mm.prober.Get(filePath).Exif(context.Background())
+	if err != nil {
+		return time.Time{}, "", mm.errorHandler.WrapError("获取拍摄信息失败", err)
+	}
+
+	var captured time.Time
+	if value := exif.String("DateTimeOriginal"); value != "" {
+		// 部分相机会附带时区或亚秒，只取前19个字符
+		if len(value) > len(exifDateLayout) {
+			value = value[:len(exifDateLayout)]
+		}
+		if t, err := time.ParseInLocation(exifDateLayout, value, time.Local); err == nil {
+			captured = t
+		}
+	}
+
+	// 多数厂商的Model已包含Make，避免重复
+	camera := strings.TrimSpace(exif.String("Model"))
+	maker := strings.TrimSpace(exif.String("Make"))
+	if maker != "" && !strings.HasPrefix(strings.ToLower(camera), strings.ToLower(strings.Fields(maker)[0])) {
+		camera = strings.TrimSpace(maker + " " + camera)
+	}
+
+	return captured, camera, nil
+}
+
 // ValidateMetadata 验证元数据完整性
 func (mm *MetadataManager) ValidateMetadata(filePath string) error {
 	// 验证元数据完整性
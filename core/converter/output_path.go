@@ -0,0 +1,393 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pixly/pkg/pathtemplate"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// OutputsBucket 输出来源bucket：输出路径 -> 生成它的源文件，重复运行时据此识别自己上次的输出
+const OutputsBucket = "outputs"
+
+// errOutputEscapesRoot 源文件不在输入根目录内，按相对目录展开的输出路径会超出输出目录
+var errOutputEscapesRoot = errors.New("源文件不在输入根目录内，输出路径会超出输出目录")
+
+// maxCollisionSuffix 冲突时尝试的最大后缀序号
+const maxCollisionSuffix = 9999
+
+// outputPathRegistry 输出路径登记表：同一源文件/扩展名多次计算得到相同路径，不同源文件之间互不冲突
+type outputPathRegistry struct {
+	byKey    map[string]string // 源文件|扩展名 -> 输出路径
+	reserved map[string]string // 输出路径 -> 源文件|扩展名
+}
+
+func newOutputPathRegistry() *outputPathRegistry {
+	return &outputPathRegistry{
+		byKey:    make(map[string]string),
+		reserved: make(map[string]string),
+	}
+}
+
+// setInputRoot 记录输入根目录，用于计算输出路径中的相对目录
+func (c *Converter) setInputRoot(inputDir string) {
+	if normalized, err := GlobalPathUtils.NormalizePath(inputDir); err == nil {
+		c.inputRoot = normalized
+	}
+}
+
+// getOutputPath 获取输出文件路径 - 支持原地转换、字面输出目录与模板展开，并处理路径冲突
+func (c *Converter) getOutputPath(file *MediaFile, newExt string) string {
+	// 使用GlobalPathUtils处理路径
+	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
+	if err != nil {
+		return ""
+	}
+	key := normalizedPath + "|" + newExt
+
+	c.outputMutex.Lock()
+	defer c.outputMutex.Unlock()
+
+	if outputPath, ok := c.outputPaths.byKey[key]; ok {
+		return outputPath
+	}
+
+	outputPath := c.buildOutputPath(file, normalizedPath, newExt)
+	if outputPath == "" {
+		return ""
+	}
+
+	// 探测文件的产出是临时文件，名称已唯一，无需冲突处理
+	if !file.isProbe {
+		outputPath = c.resolveOutputCollision(outputPath, normalizedPath, key)
+		if outputPath == "" {
+			return ""
+		}
+	}
+
+	c.outputPaths.byKey[key] = outputPath
+	c.outputPaths.reserved[outputPath] = key
+	return outputPath
+}
+
+// forgetOutputPath 使下次计算重新展开模板（如{quality}在选定参数后才确定）
+// 旧路径仍归属该源文件，重新计算得到相同路径时不会被视为冲突
+func (c *Converter) forgetOutputPath(file *MediaFile, newExt string) {
+	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
+	if err != nil {
+		return
+	}
+	key := normalizedPath + "|" + newExt
+
+	c.outputMutex.Lock()
+	defer c.outputMutex.Unlock()
+
+	delete(c.outputPaths.byKey, key)
+}
+
+// buildOutputPath 根据输出配置计算未处理冲突的输出路径
+func (c *Converter) buildOutputPath(file *MediaFile, normalizedPath, newExt string) string {
+	baseName := GlobalPathUtils.GetBaseName(normalizedPath)
+	ext := GlobalPathUtils.GetExtension(normalizedPath)
+	name := strings.TrimSuffix(baseName, ext)
+
+	relDir, err := c.outputRelDir(normalizedPath)
+	if err != nil {
+		return ""
+	}
+
+	dirTemplate := c.config.Output.DirectoryTemplate
+	nameTemplate := c.config.Output.FilenameTemplate
+
+	// 相对目录含..时输出会落到输出目录之外
+	if dirTemplate != "" && !file.isProbe && !filepath.IsLocal(relDir) {
+		c.logger.Warn("输出路径超出输出目录，拒绝转换", zap.String("file", normalizedPath), zap.String("rel_dir", relDir))
+		return ""
+	}
+
+	var vars pathtemplate.Vars
+	if !file.isProbe && (pathtemplate.HasTokens(dirTemplate) || nameTemplate != "") {
+		vars = c.templateVars(file, normalizedPath, name, ext, relDir,
+			pathtemplate.NeedsMetadata(dirTemplate) || pathtemplate.NeedsMetadata(nameTemplate))
+	}
+
+	var outputDir string
+	switch {
+	case dirTemplate == "" || file.isProbe:
+		// 默认原地转换：使用原文件所在目录（探测产出始终与探测文件相邻）
+		outputDir = filepath.Dir(normalizedPath)
+	case !pathtemplate.HasTokens(dirTemplate):
+		// 字面输出目录，保持原始目录结构
+		outputDir = filepath.Join(dirTemplate, relDir)
+	default:
+		outputDir = filepath.Clean(pathtemplate.Expand(dirTemplate, vars))
+		// 展开后的目录必须仍在模板的静态根目录之内
+		if root := pathtemplate.Root(dirTemplate); !pathtemplate.Within(root, outputDir) {
+			c.logger.Warn("输出路径超出输出目录，拒绝转换", zap.String("file", normalizedPath), zap.String("root", root), zap.String("output_dir", outputDir))
+			return ""
+		}
+	}
+
+	if outputDir != filepath.Dir(normalizedPath) {
+		// 确保输出目录存在
+		if err := c.fileOpHandler.SafeCreateDir(outputDir); err != nil {
+			c.logger.Warn("Failed to create output directory", zap.String("dir", outputDir), zap.Error(err))
+			// 如果创建目录失败，回退到原文件所在目录
+			outputDir = filepath.Dir(normalizedPath)
+		}
+	}
+
+	if nameTemplate != "" && !file.isProbe {
+		name = pathtemplate.Expand(nameTemplate, vars)
+	}
+
+	outputPath, err := GlobalPathUtils.JoinPath(outputDir, name+newExt)
+	if err != nil {
+		return ""
+	}
+	// 规范化输出路径
+	normalizedOutput, err := GlobalPathUtils.NormalizePath(outputPath)
+	if err != nil {
+		return outputPath // 如果规范化失败，返回原路径
+	}
+	return normalizedOutput
+}
+
+// outputRelDir 计算源文件相对于输入根目录的相对目录（未设置时使用工作目录）
+func (c *Converter) outputRelDir(normalizedPath string) (string, error) {
+	root := c.inputRoot
+	if root == "" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		root = workingDir
+	}
+	relPath, err := filepath.Rel(root, normalizedPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(relPath), nil
+}
+
+// checkOutputScope 使用输出目录时确认源文件位于输入根目录内，否则输出会写到输出目录之外
+func (c *Converter) checkOutputScope(file *MediaFile) error {
+	if c.config.Output.DirectoryTemplate == "" {
+		return nil
+	}
+	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
+	if err != nil {
+		return c.errorHandler.WrapError("规范化源文件路径失败", err)
+	}
+	relDir, err := c.outputRelDir(normalizedPath)
+	if err != nil {
+		return c.errorHandler.WrapError("计算输出相对目录失败", err)
+	}
+	if !filepath.IsLocal(relDir) {
+		return fmt.Errorf("%w: %s", errOutputEscapesRoot, file.Path)
+	}
+	return nil
+}
+
+// templateVars 收集模板变量；仅在模板引用{date}/{camera}时读取EXIF
+func (c *Converter) templateVars(file *MediaFile, normalizedPath, name, ext, relDir string, needsMetadata bool) pathtemplate.Vars {
+	vars := pathtemplate.Vars{
+		Filename: name,
+		Ext:      ext,
+		RelPath:  relDir,
+		Date:     file.ModTime,
+		Mode:     string(c.mode),
+		Quality:  "lossless",
+	}
+	if file.QualitySetting > 0 {
+		vars.Quality = "q" + strconv.Itoa(file.QualitySetting)
+	}
+
+	if needsMetadata {
+		captured, camera, err := c.metadataManager.GetCaptureInfo(normalizedPath)
+		if err != nil {
+			c.logger.Debug("读取拍摄信息失败，使用文件修改时间", zap.String("file", normalizedPath), zap.Error(err))
+		}
+		if !captured.IsZero() {
+			vars.Date = captured
+		}
+		vars.Camera = camera
+	}
+
+	if vars.Date.IsZero() {
+		if stat, err := os.Stat(normalizedPath); err == nil {
+			vars.Date = stat.ModTime()
+		}
+	}
+	return vars
+}
+
+// resolveOutputCollision 处理输出路径冲突：与本次运行中其他源文件或磁盘上已有文件重名时追加序号；
+// 磁盘上的已有文件是同一源文件上次运行的输出时沿用原路径，重复运行不会产生_1、_2副本。
+// 输出与源文件同名（同扩展名的原地重新编码）时无论冲突策略都追加序号：覆盖源文件既有损又无法撤销，
+// 改名后原件按撤销日志移入备份目录；需要保持文件名的原地替换（DNG重新压缩）经replaceOriginal完成，不经过这里
+func (c *Converter) resolveOutputCollision(outputPath, sourcePath, key string) string {
+	isSource := func(path string) bool {
+		return strings.EqualFold(path, sourcePath) // 不区分大小写的文件系统上 .MOV 与 .mov 是同一文件
+	}
+	if c.config.Output.CollisionPolicy == "overwrite" && !isSource(outputPath) {
+		return outputPath
+	}
+
+	taken := func(path string) bool {
+		if isSource(path) {
+			return true
+		}
+		if owner, ok := c.outputPaths.reserved[path]; ok {
+			return owner != key
+		}
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+		return c.outputSourceOf(path) != sourcePath
+	}
+
+	if !taken(outputPath) {
+		return outputPath
+	}
+
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	for i := 1; i <= maxCollisionSuffix; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if !taken(candidate) {
+			c.logger.Debug("输出路径冲突，已追加序号",
+				zap.String("source", sourcePath),
+				zap.String("requested", outputPath),
+				zap.String("resolved", candidate))
+			return candidate
+		}
+	}
+
+	if isSource(outputPath) {
+		c.logger.Warn("输出路径冲突无法解决，拒绝覆盖源文件", zap.String("path", outputPath))
+		return ""
+	}
+	c.logger.Warn("输出路径冲突无法解决，将覆盖已有文件", zap.String("path", outputPath))
+	return outputPath
+}
+
+// outputSourceOf 查询磁盘上已有输出文件的来源，没有记录时为空（调用方持有outputMutex）
+func (c *Converter) outputSourceOf(outputPath string) string {
+	if c.checkpointMgr == nil {
+		return ""
+	}
+	source, err := c.checkpointMgr.OutputSource(outputPath)
+	if err != nil {
+		c.logger.Debug("查询输出来源失败", zap.String("output", outputPath), zap.Error(err))
+	}
+	return source
+}
+
+// recordOutputSource 记录成功转换的输出来源，供之后的运行识别
+func (c *Converter) recordOutputSource(file *MediaFile, result *ConversionResult) {
+	if c.checkpointMgr == nil || !result.Success || result.Skipped || file.cacheHit {
+		return
+	}
+	if result.OutputPath == "" || result.OutputPath == file.Path {
+		return
+	}
+	c.recordOutputPath(result.OutputPath, file)
+}
+
+// recordOutputPath 记录输出文件由源文件生成，也用于主输出之外的附带输出（如Motion Photo提取的视频）
+func (c *Converter) recordOutputPath(outputPath string, file *MediaFile) {
+	if c.checkpointMgr == nil {
+		return
+	}
+	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
+	if err != nil {
+		return
+	}
+	if err := c.checkpointMgr.RecordOutput(outputPath, normalizedPath); err != nil {
+		c.logger.Warn("记录输出来源失败", zap.String("output", outputPath), zap.Error(err))
+	}
+}
+
+// isEngineOutput 检查文件是否为本程序生成的输出
+func (c *Converter) isEngineOutput(path string) bool {
+	if c.checkpointMgr == nil {
+		return false
+	}
+	source, err := c.checkpointMgr.OutputSource(path)
+	if err != nil {
+		c.logger.Debug("查询输出来源失败", zap.String("output", path), zap.Error(err))
+	}
+	return source != ""
+}
+
+// releaseOutputPaths 文件处理完成后移除其在输出路径登记表中的条目，监视模式长期运行时登记表不会持续增长；
+// 之后的冲突判断由磁盘上的文件与输出来源记录完成
+func (c *Converter) releaseOutputPaths(file *MediaFile) {
+	normalizedPath, err := GlobalPathUtils.NormalizePath(file.Path)
+	if err != nil {
+		return
+	}
+	prefix := normalizedPath + "|"
+
+	c.outputMutex.Lock()
+	defer c.outputMutex.Unlock()
+
+	for key := range c.outputPaths.byKey {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.outputPaths.byKey, key)
+		}
+	}
+	for outputPath, key := range c.outputPaths.reserved {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.outputPaths.reserved, outputPath)
+		}
+	}
+}
+
+// RecordOutput 记录输出文件由哪个源文件生成
+func (cm *CheckpointManager) RecordOutput(outputPath, sourcePath string) error {
+	err := cm.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(OutputsBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(outputPath), []byte(sourcePath))
+	})
+	if err != nil {
+		return cm.errorHandler.WrapError("写入输出来源失败", err)
+	}
+	return nil
+}
+
+// deleteOutput 删除输出来源记录（撤销转换后输出已不存在）
+func (cm *CheckpointManager) deleteOutput(outputPath string) error {
+	return cm.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(OutputsBucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(outputPath))
+	})
+}
+
+// OutputSource 返回生成输出文件的源文件，没有记录时为空
+func (cm *CheckpointManager) OutputSource(outputPath string) (string, error) {
+	var source string
+	err := cm.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(OutputsBucket))
+		if b == nil {
+			return nil
+		}
+		source = string(b.Get([]byte(outputPath)))
+		return nil
+	})
+	return source, err
+}
@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// encoderProfile 静态图编码器的速度与分块参数：普通照片使用固定设置，全景照片按配置放慢编码并分块
+type encoderProfile struct {
+	AVIFSpeed  int
+	JXLEffort  int
+	AVIFTiling []string // avifenc分块参数（--grid或--autotiling），不分块时为空
+}
+
+// defaultEncoderProfile 普通照片的编码参数
+var defaultEncoderProfile = encoderProfile{AVIFSpeed: 4, JXLEffort: 9}
+
+// AVIF网格限制：单块边长至少64像素，行列数以8位存储
+const (
+	avifMinTile = 64
+	avifMaxGrid = 256
+)
+
+// encoderProfile 返回文件的编码参数：全景照片使用配置的速度与努力程度，超过像素或边长限制时按网格分块编码
+func (c *Converter) encoderProfile(file *MediaFile) encoderProfile {
+	if c.fileTypeDetector == nil {
+		return defaultEncoderProfile
+	}
+	details, err := c.detectFileType(file)
+	if err != nil || details.FileType == FileTypeAnimatedImage || !c.fileTypeDetector.isPanorama(details) {
+		return defaultEncoderProfile
+	}
+
+	panorama := c.config.Conversion.Panorama
+	profile := encoderProfile{
+		AVIFSpeed:  panorama.AVIFSpeed,
+		JXLEffort:  panorama.JXLEffort,
+		AVIFTiling: []string{"--autotiling"},
+	}
+	maxPixels := int64(panorama.TileMegapixels) * 1000000
+	width, height := details.Width, details.Height
+	if int64(width)*int64(height) > maxPixels || width > panorama.MaxTileSize || height > panorama.MaxTileSize {
+		if cols, rows := avifGrid(width, height, panorama.MaxTileSize, maxPixels); cols > 0 {
+			profile.AVIFTiling = []string{"--grid", fmt.Sprintf("%dx%d", cols, rows)}
+		} else {
+			c.logger.Debug("全景照片尺寸无法均分为AVIF网格，使用编码器分块",
+				zap.String("file", file.Path), zap.Int("width", width), zap.Int("height", height))
+		}
+	}
+	return profile
+}
+
+// avifArgs avifenc的速度与分块参数
+func (p encoderProfile) avifArgs() []string {
+	return append([]string{"-s", strconv.Itoa(p.AVIFSpeed)}, p.AVIFTiling...)
+}
+
+// jxlEffortArgs cjxl的努力程度参数，10需要开启专家选项
+func (p encoderProfile) jxlEffortArgs() []string {
+	args := []string{"-e", strconv.Itoa(p.JXLEffort)}
+	if p.JXLEffort > 9 {
+		args = append(args, "--allow_expert_options")
+	}
+	return args
+}
+
+// avifGrid 选择块数最少的AVIF网格：图像均分为相同大小的块，单块边长不超过maxTile、像素数不超过maxPixels；
+// 无法均分时返回0
+func avifGrid(width, height, maxTile int, maxPixels int64) (cols, rows int) {
+	best := 0
+	for _, c := range gridDivisions(width, maxTile) {
+		for _, r := range gridDivisions(height, maxTile) {
+			if int64(width/c)*int64(height/r) > maxPixels {
+				continue
+			}
+			if best == 0 || c*r < best {
+				best, cols, rows = c*r, c, r
+			}
+		}
+	}
+	return cols, rows
+}
+
+// gridDivisions 返回能把长度均分的份数：多于一份时单块边长须为偶数（4:2:0色度子采样），且不小于64像素
+func gridDivisions(length, maxTile int) []int {
+	var divisions []int
+	for n := 1; n <= avifMaxGrid && length/n >= avifMinTile; n++ {
+		tile := length / n
+		if length%n != 0 || tile > maxTile || (n > 1 && tile%2 != 0) {
+			continue
+		}
+		divisions = append(divisions, n)
+	}
+	return divisions
+}
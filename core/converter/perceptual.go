@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"pixly/config"
+	"pixly/pkg/perceptual"
+
+	"go.uber.org/zap"
+)
+
+// newPerceptualScorer 根据配置创建感知质量评分器，未启用时返回nil
+func newPerceptualScorer(cfg *config.Config, logger *zap.Logger) *perceptual.Scorer {
+	pc := cfg.Conversion.Perceptual
+	if !pc.Enabled {
+		return nil
+	}
+
+	metric, ok := perceptual.ParseMetric(pc.Metric)
+	if !ok {
+		metric = perceptual.MetricMSSSIM
+	}
+
+	return perceptual.NewScorer(perceptual.Options{
+		Metric:          metric,
+		FFmpegPath:      cfg.Tools.FFmpegPath,
+		SSIMULACRA2Path: cfg.Tools.Ssimulacra2Path,
+		ButteraugliPath: cfg.Tools.ButteraugliPath,
+		MinSSIM:         pc.MinSSIM,
+		MinSSIMULACRA2:  pc.MinSSIMULACRA2,
+		MaxButteraugli:  pc.MaxButteraugli,
+	}, logger)
+}
+
+// checkPerceptualQuality 对有损候选进行感知评分
+// 返回评分与是否通过门限；未启用时视为通过。评分失败时拒绝候选：无法确认质量的有损结果不采用，
+// 调用方随之退回更保守的质量或无损转换
+func (c *Converter) checkPerceptualQuality(originalPath, candidatePath string) (*perceptual.Score, bool) {
+	if c.perceptualScorer == nil {
+		return nil, true
+	}
+
+	score, err := c.perceptualScorer.Compare(c.ctx, originalPath, candidatePath)
+	if err != nil {
+		c.logger.Warn("感知质量评分失败，拒绝有损候选",
+			zap.String("original", originalPath),
+			zap.String("candidate", candidatePath),
+			zap.Error(err))
+		return nil, false
+	}
+
+	passed := c.perceptualScorer.Passes(score)
+	if !passed {
+		c.logger.Debug("有损候选未达到感知质量门限",
+			zap.String("candidate", candidatePath),
+			zap.String("metric", string(score.Metric)),
+			zap.Float64("score", score.Value))
+	}
+	return &score, passed
+}
+
+// recordQualityScore 将被采用候选的感知评分记录到媒体文件上，供结果与报告使用
+func (file *MediaFile) recordQualityScore(score *perceptual.Score) {
+	if score == nil {
+		return
+	}
+	file.QualityMetric = string(score.Metric)
+	file.QualityScore = score.Value
+}
@@ -7,6 +7,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"pixly/pkg/scheduler"
+
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
@@ -90,7 +92,7 @@ func NewPerformanceOptimizer(logger *zap.Logger, maxWorkers int, memoryLimitMB i
 		metrics:              &PerformanceMetrics{},
 		memoryPool:           GetGlobalMemoryPool(logger),
 		adjustmentFactor:     1.2,
-		minWorkers:           int32(max(1, runtime.NumCPU()/2)),
+		minWorkers:           int32(max(1, scheduler.AvailableCPUs()/2)),
 		maxMemoryMB:          memoryLimitMB,
 		optimizationCooldown: time.Second * 10,
 	}
@@ -139,7 +141,7 @@ func (po *PerformanceOptimizer) monitor() {
 		case <-po.ctx.Done():
 			return
 		case <-ticker.C:
-			memInfo, err := mem.VirtualMemory()
+			memInfo, err := scheduler.VirtualMemory()
 			if err != nil {
 				po.logger.Error("获取内存信息失败", zap.Error(err))
 				continue
@@ -157,7 +159,7 @@ func (po *PerformanceOptimizer) monitor() {
 // performOptimization 执行性能优化
 func (po *PerformanceOptimizer) performOptimization() {
 	// 获取内存使用情况
-	memInfo, err := mem.VirtualMemory()
+	memInfo, err := scheduler.VirtualMemory()
 	if err != nil {
 		po.logger.Warn("获取内存信息失败", zap.Error(err))
 		return
@@ -255,7 +257,7 @@ func (po *PerformanceOptimizer) updateMetrics(memInfo *mem.VirtualMemoryStat) {
 	}
 
 	// 获取CPU核心数
-	po.metrics.CPUCores = runtime.NumCPU()
+	po.metrics.CPUCores = scheduler.AvailableCPUs()
 
 	// 获取运行时信息
 	var m runtime.MemStats
@@ -389,7 +391,7 @@ func (po *PerformanceOptimizer) Shutdown() {
 
 // IsMemoryPressureHigh 检查内存压力是否过高
 func (po *PerformanceOptimizer) IsMemoryPressureHigh() bool {
-	memInfo, err := mem.VirtualMemory()
+	memInfo, err := scheduler.VirtualMemory()
 	if err != nil {
 		return false
 	}
@@ -399,7 +401,7 @@ func (po *PerformanceOptimizer) IsMemoryPressureHigh() bool {
 // GetRecommendedBatchSize 获取推荐的批处理大小
 func (po *PerformanceOptimizer) GetRecommendedBatchSize() int {
 	currentWorkers := atomic.LoadInt32(&po.currentWorkers)
-	memInfo, err := mem.VirtualMemory()
+	memInfo, err := scheduler.VirtualMemory()
 	if err != nil {
 		return int(currentWorkers) * 2
 	}
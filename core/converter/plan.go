@@ -0,0 +1,339 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pixly/pkg/contentcache"
+	"pixly/pkg/whitelist"
+
+	"go.uber.org/zap"
+)
+
+// PlanVersion 计划文件格式版本
+const PlanVersion = 1
+
+// Plan 转换计划：dry-run的产出，apply时按原样执行
+type Plan struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	InputDir  string      `json:"input_dir"`
+	Mode      string      `json:"mode"`
+	Settings  string      `json:"settings"` // 设置指纹，设置变化后计划失效
+	Entries   []PlanEntry `json:"entries"`
+	Summary   PlanSummary `json:"summary"`
+}
+
+// PlanEntry 单个文件的计划
+type PlanEntry struct {
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
+	ContentHash  string    `json:"content_hash,omitempty"`
+	MediaType    MediaType `json:"media_type"`
+	DetectedType string    `json:"detected_type,omitempty"`
+	QualityLevel string    `json:"quality_level,omitempty"`
+	Route
+	EstimatedSize int64 `json:"estimated_size"`
+}
+
+// PlanSummary 计划汇总
+type PlanSummary struct {
+	TotalFiles    int   `json:"total_files"`
+	ConvertFiles  int   `json:"convert_files"`
+	SkipFiles     int   `json:"skip_files"`
+	OriginalSize  int64 `json:"original_size"`
+	EstimatedSize int64 `json:"estimated_size"`
+}
+
+// Plan 生成转换计划：执行与转换相同的扫描、形态检测、品质评估与路由，但不转换任何文件
+func (c *Converter) Plan(inputDir string) (*Plan, error) {
+	c.setInputRoot(inputDir)
+	c.settingsKey = c.settingsFingerprint()
+
+	if err := c.checkPathPermissions(inputDir); err != nil {
+		return nil, c.errorHandler.WrapError("路径权限检查失败", err)
+	}
+
+	batchProcessor := NewBatchProcessor(c, c.logger)
+	if err := batchProcessor.ScanAndAnalyze(inputDir); err != nil {
+		return nil, c.errorHandler.WrapError("扫描和分析文件失败", err)
+	}
+
+	plan := &Plan{
+		Version:   PlanVersion,
+		CreatedAt: time.Now(),
+		InputDir:  c.inputRoot,
+		Mode:      string(c.mode),
+		Settings:  c.settingsKey,
+	}
+
+	// 扫描阶段已确定跳过的文件（已是目标格式、缓存命中）
+	seen := make(map[string]bool)
+	c.mutex.RLock()
+	scanned := make([]*ConversionResult, len(c.results))
+	copy(scanned, c.results)
+	c.mutex.RUnlock()
+	for _, result := range scanned {
+		file := result.OriginalFile
+		if file == nil || seen[file.Path] {
+			continue
+		}
+		seen[file.Path] = true
+		plan.Entries = append(plan.Entries, c.newPlanEntry(file, skipRoute(result.SkipReason)))
+	}
+
+	for _, file := range batchProcessor.GetCorruptedFiles() {
+		plan.Entries = append(plan.Entries, c.newPlanEntry(file, skipRoute("文件已损坏")))
+	}
+
+	// 并发评估任务队列中的文件，结果按队列顺序写入
+	tasks := batchProcessor.GetTaskQueue()
+	entries := make([]PlanEntry, len(tasks))
+	workers := c.config.Concurrency.ConversionWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	semaphore := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, file := range tasks {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, file *MediaFile) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			entries[i] = c.planFile(file)
+		}(i, file)
+	}
+	wg.Wait()
+	plan.Entries = append(plan.Entries, entries...)
+
+	// Live Photo配对的视频与合并入连拍动图的帧随代表文件处理，在计划中单独列出
+	for i, file := range tasks {
+		if pair := file.livePhoto; pair != nil && pair.Motion != nil {
+			if entries[i].Action == ActionSkip {
+				route := skipRoute("Live Photo静态图未转换，视频保持原样以维持配对")
+				pair.Motion.route = &route
+			}
+			plan.Entries = append(plan.Entries, c.planFile(pair.Motion))
+		}
+		if burst := file.burst; burst != nil && burst.Picked == file && c.config.Conversion.Burst.Policy == "animate" {
+			for _, frame := range burst.Frames {
+				if frame == file {
+					continue
+				}
+				if entries[i].Action == ActionBurstAnimation {
+					route := skipRoute("已合并入连拍动图")
+					frame.route = &route
+				}
+				plan.Entries = append(plan.Entries, c.planFile(frame))
+			}
+		}
+	}
+
+	sort.Slice(plan.Entries, func(i, j int) bool {
+		return plan.Entries[i].Path < plan.Entries[j].Path
+	})
+	plan.summarize()
+
+	c.logger.Info("转换计划生成完成",
+		zap.Int("total", plan.Summary.TotalFiles),
+		zap.Int("convert", plan.Summary.ConvertFiles),
+		zap.Int("skip", plan.Summary.SkipFiles))
+	return plan, nil
+}
+
+// planFile 对单个文件执行形态检测、品质评估与路由
+func (c *Converter) planFile(file *MediaFile) PlanEntry {
+	if route, ok := c.creativeRoute(file); ok {
+		return c.newPlanEntry(file, route)
+	}
+	c.refineFileType(file)
+
+	var route Route
+	switch file.Type {
+	case TypeImage, TypeVideo:
+		route = c.routeFile(file)
+	default:
+		route = skipRoute("不支持的文件类型: " + string(file.Type))
+	}
+
+	entry := c.newPlanEntry(file, route)
+	if file.Type == TypeImage {
+		// 品质等级对所有模式都报告，分析结果随文件缓存，不会重复探测
+		autoPlus := &AutoPlusStrategy{converter: c, errorHandler: c.errorHandler}
+		entry.QualityLevel = autoPlus.analyzeImageQuality(file)
+	}
+	return entry
+}
+
+// newPlanEntry 根据文件与路由创建计划条目
+func (c *Converter) newPlanEntry(file *MediaFile, route Route) PlanEntry {
+	entry := PlanEntry{
+		Path:          file.Path,
+		Size:          file.Size,
+		ModTime:       file.ModTime,
+		MediaType:     file.Type,
+		Route:         route,
+		EstimatedSize: route.EstimateSize(file.Size),
+	}
+	if file.details != nil {
+		entry.DetectedType = string(file.details.FileType)
+	}
+
+	if file.cacheEntry != nil {
+		entry.ContentHash = file.cacheEntry.ContentHash
+	} else if hash, err := contentcache.Fingerprint(file.Path, file.Size); err == nil {
+		entry.ContentHash = hash
+	} else {
+		c.logger.Debug("计算内容指纹失败", zap.String("file", file.Path), zap.Error(err))
+	}
+	return entry
+}
+
+// summarize 汇总计划
+func (p *Plan) summarize() {
+	summary := PlanSummary{TotalFiles: len(p.Entries)}
+	for _, entry := range p.Entries {
+		summary.OriginalSize += entry.Size
+		summary.EstimatedSize += entry.EstimatedSize
+		if entry.Action == ActionSkip {
+			summary.SkipFiles++
+		} else {
+			summary.ConvertFiles++
+		}
+	}
+	p.Summary = summary
+}
+
+// ApplyPlan 按计划执行转换：直接使用计划中的路由而不重新决策，计划生成后变更过的文件不处理
+func (c *Converter) ApplyPlan(plan *Plan) error {
+	if plan.Version != PlanVersion {
+		return fmt.Errorf("不支持的计划版本: %d", plan.Version)
+	}
+	if plan.Mode != string(c.mode) {
+		return fmt.Errorf("计划模式与当前模式不一致: %s", plan.Mode)
+	}
+
+	c.setInputRoot(plan.InputDir)
+	c.settingsKey = c.settingsFingerprint()
+	if plan.Settings != c.settingsKey {
+		return fmt.Errorf("转换设置自计划生成后已变化，请重新生成计划")
+	}
+
+	// 打开目标目录的检查点数据库
+	if err := c.openCheckpoint(plan.InputDir); err != nil {
+		return c.errorHandler.WrapError("打开检查点数据库失败", err)
+	}
+
+	// 启动信号处理器
+	c.signalHandler.Start()
+	defer c.signalHandler.Stop()
+
+	if err := c.checkPathPermissions(plan.InputDir); err != nil {
+		return c.errorHandler.WrapError("路径权限检查失败", err)
+	}
+
+	c.mutex.Lock()
+	c.stats.StartTime = time.Now()
+	c.stats.TotalFiles = len(plan.Entries)
+	c.mutex.Unlock()
+	c.emitScanStarted()
+
+	tasks := make([]*MediaFile, 0, len(plan.Entries))
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		if entry.Action == ActionSkip {
+			c.recordPlanSkip(entry, entry.Reason)
+			continue
+		}
+
+		file, err := c.plannedFile(entry)
+		if err != nil {
+			c.logger.Warn("计划中的文件不可执行，跳过", zap.String("file", entry.Path), zap.Error(err))
+			c.recordPlanSkip(entry, err.Error())
+			continue
+		}
+		tasks = append(tasks, file)
+	}
+
+	if len(tasks) > 0 {
+		if err := c.checkpointMgr.StartSession(plan.InputDir, string(c.mode), c.stats.TotalFiles); err != nil {
+			return c.errorHandler.WrapError("启动转换会话失败", err)
+		}
+
+		batchProcessor := NewBatchProcessor(c, c.logger)
+		batchProcessor.taskQueue = tasks
+		if err := batchProcessor.ProcessTaskQueue(); err != nil {
+			return c.errorHandler.WrapError("处理任务队列失败", err)
+		}
+	}
+
+	c.finishSession()
+	return nil
+}
+
+// plannedFile 校验文件自计划生成后未变更，并创建带预定路由的任务
+func (c *Converter) plannedFile(entry *PlanEntry) (*MediaFile, error) {
+	stat, err := os.Stat(entry.Path)
+	if err != nil {
+		return nil, c.errorHandler.WrapError("文件已不存在", err)
+	}
+	if stat.Size() != entry.Size || !stat.ModTime().Equal(entry.ModTime) {
+		return nil, fmt.Errorf("文件自计划生成后已变更")
+	}
+	if entry.ContentHash != "" {
+		hash, err := contentcache.Fingerprint(entry.Path, stat.Size())
+		if err != nil {
+			return nil, c.errorHandler.WrapError("计算内容指纹失败", err)
+		}
+		if hash != entry.ContentHash {
+			return nil, fmt.Errorf("文件内容自计划生成后已变更")
+		}
+	}
+
+	route := entry.Route
+	return &MediaFile{
+		Path:      entry.Path,
+		Name:      GlobalPathUtils.GetBaseName(entry.Path),
+		Size:      stat.Size(),
+		Extension: strings.ToLower(GlobalPathUtils.GetExtension(entry.Path)),
+		Type:      entry.MediaType,
+		ModTime:   stat.ModTime(),
+		route:     &route,
+	}, nil
+}
+
+// recordPlanSkip 记录计划中跳过或无法执行的文件
+func (c *Converter) recordPlanSkip(entry *PlanEntry, reason string) {
+	file := &MediaFile{
+		Path:       entry.Path,
+		Name:       GlobalPathUtils.GetBaseName(entry.Path),
+		Size:       entry.Size,
+		Extension:  strings.ToLower(GlobalPathUtils.GetExtension(entry.Path)),
+		Type:       entry.MediaType,
+		ModTime:    entry.ModTime,
+		SkipReason: reason,
+	}
+	result := &ConversionResult{
+		OriginalFile:   file,
+		OutputPath:     file.Path,
+		OriginalSize:   file.Size,
+		CompressedSize: file.Size,
+		Success:        true,
+		Skipped:        true,
+		SkipCategory:   whitelist.SkipStrategy,
+		SkipReason:     reason,
+		Method:         string(ActionSkip),
+	}
+
+	c.mutex.Lock()
+	c.results = append(c.results, result)
+	c.mutex.Unlock()
+	c.UpdateStats(result)
+	c.emitResult(result)
+}
@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pixly/pkg/contentcache"
+
+	"go.uber.org/zap"
+)
+
+func TestRouteEstimateSize(t *testing.T) {
+	tests := []struct {
+		action RouteAction
+		want   int64
+	}{
+		{ActionSkip, 1000},
+		{ActionJXLLossless, 800},
+		{ActionAVIFLossless, 700},
+		{ActionBalanced, 600},
+		{ActionEmojiAVIF, 350},
+		{ActionEmojiAnimatedAVIF, 300},
+		{ActionVideoContainer, 1000},
+		{ActionMOVRemux, 1000},
+		{ActionVideoTranscode, 500},
+		{ActionGainMapAVIF, 600},
+		{ActionBurstAnimation, 500},
+		{ActionRawPreview, 100},
+		{ActionDNGJXL, 600},
+		{RouteAction("unknown"), 1000},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			if got := (Route{Action: tt.action}).EstimateSize(1000); got != tt.want {
+				t.Errorf("EstimateSize = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanSummarize(t *testing.T) {
+	plan := &Plan{Entries: []PlanEntry{
+		{Path: "a.png", Size: 1000, Route: Route{Action: ActionJXLLossless}, EstimatedSize: 800},
+		{Path: "b.jpg", Size: 2000, Route: skipRoute("已是目标格式"), EstimatedSize: 2000},
+		{Path: "c.gif", Size: 500, Route: Route{Action: ActionAVIFLossless}, EstimatedSize: 350},
+	}}
+	plan.summarize()
+
+	want := PlanSummary{TotalFiles: 3, ConvertFiles: 2, SkipFiles: 1, OriginalSize: 3500, EstimatedSize: 3150}
+	if plan.Summary != want {
+		t.Errorf("Summary = %+v, 期望 %+v", plan.Summary, want)
+	}
+}
+
+func TestNewPlanEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	if err := os.WriteFile(path, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Converter{logger: zap.NewNop()}
+	file := &MediaFile{Path: path, Size: 3, Type: TypeImage, details: &MediaDetails{FileType: FileTypeStaticImage}}
+
+	entry := c.newPlanEntry(file, Route{Action: ActionJXLLossless, TargetExt: ".jxl"})
+	hash, _ := contentcache.Fingerprint(path, 3)
+	if entry.EstimatedSize != 2 || entry.ContentHash != hash || entry.DetectedType != string(FileTypeStaticImage) || entry.TargetExt != ".jxl" {
+		t.Errorf("newPlanEntry = %+v", entry)
+	}
+}
+
+func TestPlannedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, _ := os.Stat(path)
+	hash, _ := contentcache.Fingerprint(path, stat.Size())
+	c := &Converter{logger: zap.NewNop(), errorHandler: NewErrorHandler(zap.NewNop())}
+
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T)
+		entry   PlanEntry
+		wantErr bool
+	}{
+		{"未变更", func(t *testing.T) {}, PlanEntry{}, false},
+		{"大小变化", func(t *testing.T) {}, PlanEntry{Size: stat.Size() + 1}, true},
+		{"修改时间变化", func(t *testing.T) {}, PlanEntry{ModTime: stat.ModTime().Add(time.Second)}, true},
+		{"内容变化但大小与修改时间不变", func(t *testing.T) {
+			if err := os.WriteFile(path, []byte("modified"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			os.Chtimes(path, stat.ModTime(), stat.ModTime())
+		}, PlanEntry{}, true},
+		{"文件已删除", func(t *testing.T) { os.Remove(path) }, PlanEntry{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			os.Chtimes(path, stat.ModTime(), stat.ModTime())
+			tt.modify(t)
+
+			entry := PlanEntry{
+				Path:        path,
+				Size:        stat.Size(),
+				ModTime:     stat.ModTime(),
+				ContentHash: hash,
+				MediaType:   TypeImage,
+				Route:       Route{Action: ActionJXLLossless, TargetExt: ".jxl"},
+			}
+			if tt.entry.Size != 0 {
+				entry.Size = tt.entry.Size
+			}
+			if !tt.entry.ModTime.IsZero() {
+				entry.ModTime = tt.entry.ModTime
+			}
+
+			file, err := c.plannedFile(&entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("plannedFile err = %v, 期望错误 %v", err, tt.wantErr)
+			}
+			if err == nil && (file.route == nil || file.route.Action != ActionJXLLossless || file.Type != TypeImage) {
+				t.Errorf("计划文件应带预定路由: %+v", file)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package converter
+
+import (
+	"context"
+
+	"pixly/config"
+	"pixly/pkg/mediaprobe"
+)
+
+// newProber 创建会话级共享探测缓存，外部命令经工具管理器执行（路径验证、超时与重试）
+func newProber(config *config.Config, toolManager *ToolManager) *mediaprobe.Prober {
+	prober := mediaprobe.NewProber(config.Tools.FFprobePath, config.Tools.ExiftoolPath)
+	prober.SetRunner(func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return toolManager.ExecuteWithPathValidation(ctx, ToolJob{}, name, args...)
+	})
+	return prober
+}
+
+// Prober 返回转换器的共享探测缓存，供扫描与品质评估阶段复用同一份探测结果
+func (c *Converter) Prober() *mediaprobe.Prober {
+	return c.prober
+}
@@ -0,0 +1,177 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pixly/pkg/qualitysearch"
+
+	"go.uber.org/zap"
+)
+
+// withProbeLink 为探测阶段创建带标签后缀的输入链接（硬链接/符号链接，无需拷贝），
+// 使每次探测的输出落在独立文件中，互不覆盖
+func (c *Converter) withProbeLink(file *MediaFile, tag string, fn func(probe *MediaFile) (string, error)) (string, error) {
+	dir := filepath.Dir(file.Path)
+	base := filepath.Base(file.Path)
+	extName := filepath.Ext(base)
+	name := strings.TrimSuffix(base, extName)
+	probePath := filepath.Join(dir, fmt.Sprintf("%s._probe_%s%s", name, tag, extName))
+
+	// 预清理同名残留
+	_ = c.fileOpHandler.SafeRemoveFile(probePath)
+
+	// 优先创建硬链接，失败则退回符号链接
+	linkCreated := false
+	if err := os.Link(file.Path, probePath); err == nil {
+		linkCreated = true
+	} else if err := os.Symlink(file.Path, probePath); err == nil {
+		linkCreated = true
+	} else {
+		c.logger.Warn("创建探测链接失败，将回退为直接使用源文件（可能导致输出命名冲突）",
+			zap.String("source", file.Path),
+			zap.String("probe", probePath))
+	}
+
+	if linkCreated {
+		defer func() {
+			if err := os.Remove(probePath); err != nil {
+				c.logger.Debug("清理探测链接失败", zap.String("probe", probePath), zap.Error(err))
+			}
+		}()
+	}
+
+	probeFile := *file
+	probeFile.isProbe = true
+	if linkCreated {
+		probeFile.Path = probePath
+	}
+	// 链接创建失败时保持原路径，输出将可能与其他探测产出冲突（极少数情况）
+
+	return fn(&probeFile)
+}
+
+// promoteProbeResult 将选中的探测产出移动到最终输出路径（原地使用原子替换，非原地直接重命名）
+func (c *Converter) promoteProbeResult(file *MediaFile, probe *ProbeResult, targetExt string) (string, error) {
+	probeOutput := probe.Path
+
+	// 质量设置已确定，重新计算输出路径以展开{quality}
+	file.QualitySetting = probe.Quality
+	c.forgetOutputPath(file, targetExt)
+	finalOutputPath := c.getOutputPath(file, targetExt)
+
+	// 确保输出目录存在（统一走文件操作助手）
+	if err := c.fileOpHandler.EnsureOutputDirectory(finalOutputPath); err != nil {
+		c.logger.Error("创建输出目录失败",
+			zap.String("dir", filepath.Dir(finalOutputPath)),
+			zap.Error(err))
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+
+	isInPlace := c.config.Output.DirectoryTemplate == ""
+	if isInPlace {
+		if err := c.fileOpHandler.AtomicFileReplace(probeOutput, finalOutputPath, true); err != nil {
+			c.logger.Error("原子替换失败",
+				zap.String("from", probeOutput),
+				zap.String("to", finalOutputPath),
+				zap.Error(err))
+			return "", c.errorHandler.WrapError("failed to atomically replace best result to final path", err)
+		}
+	} else {
+		if err := os.Rename(probeOutput, finalOutputPath); err != nil {
+			c.logger.Error("文件移动失败",
+				zap.String("from", probeOutput),
+				zap.String("to", finalOutputPath),
+				zap.Error(err))
+			return "", c.errorHandler.WrapError("failed to move best result to final path", err)
+		}
+	}
+
+	return finalOutputPath, nil
+}
+
+// searchLossyQuality 在质量区间内二分搜索满足目标的最便宜有损候选，取代固定质量阶梯
+// encode 必须为每个质量产出独立的文件；未被采用的候选会被删除
+func (c *Converter) searchLossyQuality(file *MediaFile, minQuality, maxQuality int, encode func(quality int) (string, error)) *ProbeResult {
+	searchConfig := c.config.Conversion.QualitySearch
+	originalSize := file.Size
+	if stat, err := os.Stat(file.Path); err == nil {
+		originalSize = stat.Size()
+	}
+
+	// 感知目标需要评分器，未启用时退回体积目标
+	goal, ok := qualitysearch.ParseGoal(searchConfig.Target)
+	if !ok || (goal == qualitysearch.GoalPerceptual && c.perceptualScorer == nil) {
+		goal = qualitysearch.GoalSizeReduction
+	}
+
+	probes := make(map[string]*ProbeResult)
+	run := func(quality int) (qualitysearch.Candidate, error) {
+		path, err := encode(quality)
+		if err != nil {
+			return qualitysearch.Candidate{}, err
+		}
+		if path == "" || path == file.Path { // 避免把原文件路径当作探测结果
+			return qualitysearch.Candidate{}, fmt.Errorf("no lossy candidate produced for %s", file.Path)
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return qualitysearch.Candidate{}, err
+		}
+		probes[path] = &ProbeResult{Path: path, Quality: quality, Size: stat.Size()}
+		return qualitysearch.Candidate{Setting: quality, Path: path, Size: stat.Size()}, nil
+	}
+
+	meets := qualitysearch.SizeReductionMeets(originalSize, searchConfig.TargetReduction/100)
+	if goal == qualitysearch.GoalPerceptual {
+		meets = func(candidate qualitysearch.Candidate) bool {
+			if candidate.Size >= originalSize {
+				return false
+			}
+			// 评分失败视为未达标，使搜索向更保守的质量收缩
+			score, passed := c.checkPerceptualQuality(file.Path, candidate.Path)
+			probes[candidate.Path].Score = score
+			return passed && score != nil
+		}
+	}
+
+	result, err := qualitysearch.Run(run, qualitysearch.Options{
+		Min:         minQuality,
+		Max:         maxQuality,
+		MaxSteps:    searchConfig.MaxSteps,
+		PreferLower: goal == qualitysearch.GoalPerceptual,
+		Meets:       meets,
+		Discard: func(candidate qualitysearch.Candidate) {
+			os.Remove(candidate.Path)
+			delete(probes, candidate.Path)
+		},
+	})
+	if err != nil || result.Best == nil {
+		c.logger.Debug("有损参数搜索未找到达标候选",
+			zap.String("file", file.Path),
+			zap.String("goal", string(goal)),
+			zap.Int("attempts", result.Attempts))
+		return nil
+	}
+
+	best := probes[result.Best.Path]
+	c.logger.Debug("有损参数搜索完成",
+		zap.String("file", file.Path),
+		zap.String("goal", string(goal)),
+		zap.Int("quality", best.Quality),
+		zap.Int("attempts", result.Attempts))
+
+	// 体积目标下仍需通过感知质量门限
+	if goal == qualitysearch.GoalSizeReduction {
+		score, passed := c.checkPerceptualQuality(file.Path, best.Path)
+		if !passed {
+			os.Remove(best.Path)
+			return nil
+		}
+		best.Score = score
+	}
+
+	return best
+}
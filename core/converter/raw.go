@@ -0,0 +1,268 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// rawExtensions 相机RAW格式的扩展名（扩展名与内容不符时以检测结果为准）
+var rawExtensions = map[string]string{
+	".dng": mediaprobe.RawDNG,
+	".cr2": mediaprobe.RawCR2,
+	".nef": mediaprobe.RawNEF,
+	".arw": mediaprobe.RawARW,
+}
+
+// rawPairExts 与RAW同名配对的机内JPEG/HEIF：存在时由它承担缩减体积，RAW不再生成预览
+var rawPairExts = []string{".jpg", ".jpeg", ".JPG", ".JPEG", ".heic", ".HEIC", ".heif", ".HEIF"}
+
+// rawRoute 按RAW策略决定相机RAW文件的路由；不是RAW时返回false，交给当前策略路由
+func (c *Converter) rawRoute(file *MediaFile) (Route, bool) {
+	format := c.rawFormat(file)
+	if format == "" {
+		return Route{}, false
+	}
+
+	switch c.config.Conversion.Raw.Policy {
+	case "preview":
+		return c.rawPreviewRoute(file), true
+	case "dng_jxl":
+		return c.dngJXLRoute(file, format), true
+	default:
+		return skipRoute("RAW原始文件，按配置保持原样"), true
+	}
+}
+
+// rawFormat 返回文件的RAW格式：优先使用检测结果，检测不可用时按扩展名判断
+func (c *Converter) rawFormat(file *MediaFile) string {
+	if c.fileTypeDetector != nil {
+		if details, err := c.detectFileType(file); err == nil && details.FileType == FileTypeRaw {
+			return details.Raw
+		}
+	}
+	return rawExtensions[strings.ToLower(filepath.Ext(file.Path))]
+}
+
+// rawPreviewRoute 嵌入的全尺寸JPEG预览无损转换为同名JXL旁车文件，RAW保持原样
+func (c *Converter) rawPreviewRoute(file *MediaFile) Route {
+	if pair := rawPairedImage(file.Path); pair != "" {
+		return skipRoute("RAW+JPEG配对，由 " + filepath.Base(pair) + " 转换，RAW保持原样")
+	}
+	raw, err := mediaprobe.ProbeRawFile(file.Path)
+	if err != nil {
+		return skipRoute("无法解析RAW结构，保持原样")
+	}
+	preview := raw.FullSizePreview()
+	if preview == nil {
+		return skipRoute("RAW未嵌入全尺寸JPEG预览，保持原样")
+	}
+	if _, err := exec.LookPath(c.config.Tools.ExiftoolPath); err != nil {
+		return skipRoute("缺少exiftool，无法为RAW预览保留元数据")
+	}
+	return Route{
+		Strategy:  RouteStrategyRaw,
+		Action:    ActionRawPreview,
+		TargetExt: ".jxl",
+		Reason:    fmt.Sprintf("提取%dx%d嵌入预览，无损转换为JXL旁车文件，RAW保持原样", preview.Width, preview.Height),
+	}
+}
+
+// dngJXLRoute DNG由DNG转换工具无损转换为JPEG XL压缩的DNG；其他RAW格式保持原样
+func (c *Converter) dngJXLRoute(file *MediaFile, format string) Route {
+	if format != mediaprobe.RawDNG {
+		return skipRoute(fmt.Sprintf("%s格式RAW无法转换为JPEG XL压缩的DNG，保持原样", strings.ToUpper(format)))
+	}
+	tool := c.config.Tools.DNGConverterPath
+	if tool == "" {
+		return skipRoute("未配置DNG转换工具（tools.dng_converter_path），DNG保持原样")
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return skipRoute("DNG转换工具不可用，DNG保持原样")
+	}
+	raw, err := mediaprobe.ProbeRawFile(file.Path)
+	if err != nil {
+		return skipRoute("无法解析DNG结构，保持原样")
+	}
+	if raw.Compression == mediaprobe.DNGCompressionJXL {
+		return skipRoute("DNG已使用JPEG XL压缩")
+	}
+	if _, err := exec.LookPath(c.config.Tools.ExiftoolPath); err != nil {
+		return skipRoute("缺少exiftool，无法为DNG保留元数据")
+	}
+	return Route{
+		Strategy:  RouteStrategyRaw,
+		Action:    ActionDNGJXL,
+		TargetExt: ".dng",
+		Reason:    "DNG无损转换为JPEG XL压缩的DNG",
+	}
+}
+
+// rawPairedImage 返回与RAW同目录同名的机内JPEG/HEIF，没有时为空
+func rawPairedImage(path string) string {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range rawPairExts {
+		if stat, err := os.Stat(stem + ext); err == nil && stat.Mode().IsRegular() {
+			return stem + ext
+		}
+	}
+	return ""
+}
+
+// convertRawPreview 提取RAW嵌入的全尺寸JPEG预览，经cjxl无损重压缩为JXL旁车文件，并从RAW迁移元数据
+func (c *Converter) convertRawPreview(file *MediaFile) (string, error) {
+	raw, err := mediaprobe.ProbeRawFile(file.Path)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to parse raw file", err)
+	}
+	preview := raw.FullSizePreview()
+	if preview == nil {
+		return "", fmt.Errorf("RAW未嵌入全尺寸JPEG预览: %s", file.Path)
+	}
+
+	source, err := os.Open(file.Path)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to open raw file", err)
+	}
+	defer source.Close()
+
+	temp, err := os.CreateTemp("", "pixly_raw_preview_*.jpg")
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to create temp file", err)
+	}
+	defer os.Remove(temp.Name())
+	_, err = io.Copy(temp, io.NewSectionReader(source, preview.Offset, preview.Length))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to extract raw preview", err)
+	}
+
+	outputPath := c.getOutputPath(file, ".jxl")
+	tempPath := outputPath + ".tmp"
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+	defer os.Remove(tempPath)
+
+	args := append([]string{temp.Name(), tempPath, "--lossless_jpeg=1"}, defaultEncoderProfile.jxlEffortArgs()...)
+	if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.CjxlPath, args...); err != nil {
+		return "", c.errorHandler.WrapErrorWithOutput("raw preview JXL encode failed", err, output)
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", err
+	}
+
+	// 预览本身通常只带少量EXIF，拍摄信息以RAW为准
+	if err := c.metadataManager.MigrateMetadata(file.Path, outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+
+	c.logger.Debug("RAW预览已转换为JXL",
+		zap.String("file", file.Path),
+		zap.Int("width", preview.Width),
+		zap.Int("height", preview.Height),
+		zap.String("output", outputPath))
+	return outputPath, nil
+}
+
+// convertDNGToJXL 用DNG转换工具将DNG重新编码为JPEG XL压缩的DNG：输出须仍为相同尺寸与光度解释的DNG，
+// 且比原文件小，否则保持原文件
+func (c *Converter) convertDNGToJXL(file *MediaFile) (string, error) {
+	source, err := mediaprobe.ProbeRawFile(file.Path)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to parse dng file", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "pixly_dng_*")
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to create temp directory", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	name := filepath.Base(file.Path)
+	args := append(append([]string{}, c.config.Conversion.Raw.DNGConverterArgs...), "-d", tempDir, "-o", name, file.Path)
+	if output, err := c.toolManager.Run(c.fileContext(file), c.toolJob(file), c.config.Tools.DNGConverterPath, args...); err != nil {
+		return "", c.errorHandler.WrapErrorWithOutput("dng converter failed", err, output)
+	}
+
+	converted := filepath.Join(tempDir, name)
+	result, err := mediaprobe.ProbeRawFile(converted)
+	if err != nil {
+		return "", c.errorHandler.WrapError("dng converter output is not a valid dng", err)
+	}
+	if result.Format != mediaprobe.RawDNG || result.Compression != mediaprobe.DNGCompressionJXL {
+		return "", fmt.Errorf("DNG转换工具未输出JPEG XL压缩的DNG（压缩方式%d）: %s", result.Compression, file.Path)
+	}
+	if result.Width != source.Width || result.Height != source.Height || result.Photometric != source.Photometric {
+		return "", fmt.Errorf("DNG转换前后的主图像不一致（%dx%d → %dx%d）: %s",
+			source.Width, source.Height, result.Width, result.Height, file.Path)
+	}
+
+	stat, err := os.Stat(converted)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to stat converted dng", err)
+	}
+	if stat.Size() >= file.Size {
+		c.logger.Debug("JPEG XL压缩的DNG不小于原文件，保持原样",
+			zap.String("file", file.Path),
+			zap.Int64("original_size", file.Size),
+			zap.Int64("converted_size", stat.Size()))
+		return file.Path, nil
+	}
+
+	if err := c.metadataManager.MigrateMetadata(file.Path, converted); err != nil {
+		return "", err
+	}
+
+	// 原地转换且不保留原文件时替换原文件、保持文件名，原件由撤销日志记录并移入备份目录；
+	// 保留原文件或使用输出模板时按输出路径写出，与原文件同名时由冲突处理追加序号
+	output := c.config.Output
+	if output.DirectoryTemplate == "" && output.FilenameTemplate == "" && !output.KeepOriginal {
+		tempPath := file.Path + ".tmp"
+		defer os.Remove(tempPath)
+		if err := moveFile(converted, tempPath); err != nil {
+			return "", c.errorHandler.WrapError("failed to move converted dng", err)
+		}
+		if err := c.replaceOriginal(file, tempPath); err != nil {
+			return "", err
+		}
+		c.logger.Debug("DNG已原地转换为JPEG XL压缩",
+			zap.String("file", file.Path),
+			zap.Int64("original_size", file.Size),
+			zap.Int64("converted_size", stat.Size()))
+		return file.Path, nil
+	}
+
+	outputPath := c.getOutputPath(file, ".dng")
+	if outputPath == "" {
+		return "", fmt.Errorf("无法确定输出路径: %s", file.Path)
+	}
+	tempPath := outputPath + ".tmp"
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+	defer os.Remove(tempPath)
+	if err := moveFile(converted, tempPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to move converted dng", err)
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", err
+	}
+
+	c.logger.Debug("DNG已转换为JPEG XL压缩",
+		zap.String("file", file.Path),
+		zap.Int64("original_size", file.Size),
+		zap.Int64("converted_size", stat.Size()),
+		zap.String("output", outputPath))
+	return outputPath, nil
+}
@@ -0,0 +1,322 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pixly/config"
+	"pixly/pkg/mediaprobe"
+
+	"go.uber.org/zap"
+)
+
+// dngBytes 构造小端DNG：IFD0为主图像，preview非空时IFD1以JPEGInterchangeFormat嵌入预览
+func dngBytes(width, height uint32, compression uint16, preview []byte) []byte {
+	type entry struct {
+		tag, kind uint16
+		value     uint32
+	}
+	ifd := func(next uint32, entries ...entry) []byte {
+		data := binary.LittleEndian.AppendUint16(nil, uint16(len(entries)))
+		for _, e := range entries {
+			data = binary.LittleEndian.AppendUint16(data, e.tag)
+			data = binary.LittleEndian.AppendUint16(data, e.kind)
+			data = binary.LittleEndian.AppendUint32(data, 1)
+			data = binary.LittleEndian.AppendUint32(data, e.value)
+		}
+		return binary.LittleEndian.AppendUint32(data, next)
+	}
+
+	main := []entry{
+		{254, 4, 0},     // NewSubfileType：主图像
+		{256, 4, width}, // ImageWidth
+		{257, 4, height},
+		{259, 3, uint32(compression)},
+		{262, 3, 32803},    // CFA
+		{50706, 1, 0x0701}, // DNGVersion
+	}
+	data := []byte("II*\x00\x08\x00\x00\x00")
+	if len(preview) == 0 {
+		return append(data, ifd(0, main...)...)
+	}
+	previewIFD := uint32(8 + 2 + len(main)*12 + 4)
+	jpegOffset := previewIFD + 2 + 3*12 + 4
+	data = append(data, ifd(previewIFD, main...)...)
+	data = append(data, ifd(0, entry{254, 4, 1}, entry{513, 4, jpegOffset}, entry{514, 4, uint32(len(preview))})...)
+	return append(data, preview...)
+}
+
+// previewJPEG 编码指定尺寸的JPEG预览
+func previewJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeTool 写出模拟的命令行工具
+func writeTool(t *testing.T, path, script string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRawPairedImage(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"没有配对", []string{"IMG_0001.dng"}, ""},
+		{"机内JPEG", []string{"IMG_0001.dng", "IMG_0001.JPG"}, "IMG_0001.JPG"},
+		{"机内HEIF", []string{"IMG_0001.dng", "IMG_0001.heic"}, "IMG_0001.heic"},
+		{"不同名的JPEG", []string{"IMG_0001.dng", "IMG_0002.jpg"}, ""},
+		{"同名的目录不算配对", []string{"IMG_0001.dng", "IMG_0001.jpg/"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				path := filepath.Join(dir, name)
+				if strings.HasSuffix(name, "/") {
+					if err := os.Mkdir(path, 0755); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(dir, tt.want)
+			}
+			if got := rawPairedImage(filepath.Join(dir, "IMG_0001.dng")); got != want {
+				t.Errorf("rawPairedImage = %q, 期望 %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRawRoute(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		file      string
+		data      func(t *testing.T) []byte
+		paired    bool   // 存在同名JPEG
+		converter string // DNG转换工具："missing"为配置了但不存在
+		exiftool  bool
+		ok        bool
+		action    RouteAction
+		reason    string
+	}{
+		{"不是RAW", "preview", "IMG_0001.png", nil, false, "", true, false, "", ""},
+		{"默认保持原样", "keep", "IMG_0001.dng", nil, false, "", true, true, ActionSkip, "保持原样"},
+		{"预览：RAW+JPEG配对", "preview", "IMG_0001.dng", nil, true, "", true, true, ActionSkip, "RAW+JPEG配对"},
+		{"预览：无法解析", "preview", "IMG_0001.dng", func(*testing.T) []byte { return []byte("not raw") }, false, "", true, true, ActionSkip, "无法解析"},
+		{"预览：没有全尺寸预览", "preview", "IMG_0001.dng", func(t *testing.T) []byte { return dngBytes(4000, 3000, 7, previewJPEG(t, 160, 120)) }, false, "", true, true, ActionSkip, "未嵌入全尺寸"},
+		{"预览：缺少exiftool", "preview", "IMG_0001.dng", nil, false, "", false, true, ActionSkip, "缺少exiftool"},
+		{"预览：提取", "preview", "IMG_0001.dng", nil, false, "", true, true, ActionRawPreview, "640x480"},
+		{"DNG-JXL：其他RAW格式", "dng_jxl", "IMG_0001.nef", nil, false, "tool", true, true, ActionSkip, "NEF格式"},
+		{"DNG-JXL：未配置工具", "dng_jxl", "IMG_0001.dng", nil, false, "", true, true, ActionSkip, "未配置"},
+		{"DNG-JXL：工具不可用", "dng_jxl", "IMG_0001.dng", nil, false, "missing", true, true, ActionSkip, "不可用"},
+		{"DNG-JXL：已是JPEG XL压缩", "dng_jxl", "IMG_0001.dng", func(*testing.T) []byte { return dngBytes(640, 480, mediaprobe.DNGCompressionJXL, nil) }, false, "tool", true, true, ActionSkip, "已使用JPEG XL"},
+		{"DNG-JXL：缺少exiftool", "dng_jxl", "IMG_0001.dng", nil, false, "tool", false, true, ActionSkip, "缺少exiftool"},
+		{"DNG-JXL：转换", "dng_jxl", "IMG_0001.dng", nil, false, "tool", true, true, ActionDNGJXL, "JPEG XL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			data := dngBytes(640, 480, 7, previewJPEG(t, 640, 480))
+			if tt.data != nil {
+				data = tt.data(t)
+			}
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.paired {
+				if err := os.WriteFile(filepath.Join(dir, "IMG_0001.jpg"), []byte("jpeg"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := &config.Config{}
+			cfg.Conversion.Raw.Policy = tt.policy
+			cfg.Tools.ExiftoolPath = filepath.Join(dir, "exiftool")
+			if tt.exiftool {
+				writeTool(t, cfg.Tools.ExiftoolPath, "exit 0\n")
+			}
+			switch tt.converter {
+			case "tool":
+				cfg.Tools.DNGConverterPath = filepath.Join(dir, "dngconverter")
+				writeTool(t, cfg.Tools.DNGConverterPath, "exit 0\n")
+			case "missing":
+				cfg.Tools.DNGConverterPath = filepath.Join(dir, "missing")
+			}
+			c := &Converter{config: cfg, logger: zap.NewNop()}
+
+			route, ok := c.rawRoute(&MediaFile{Path: path})
+			if ok != tt.ok || route.Action != tt.action {
+				t.Fatalf("rawRoute = %+v, %v, 期望 %s, %v", route, ok, tt.action, tt.ok)
+			}
+			if !strings.Contains(route.Reason, tt.reason) {
+				t.Errorf("Reason = %q, 期望包含 %q", route.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+// newDNGConverter 创建使用模拟DNG转换工具的转换器：工具输出converted的内容，exiftool总是成功
+func newDNGConverter(t *testing.T, converted []byte, cm *CheckpointManager, keepOriginal, undo bool) *Converter {
+	t.Helper()
+	toolDir := t.TempDir()
+	convertedPath := filepath.Join(toolDir, "converted.dng")
+	if err := os.WriteFile(convertedPath, converted, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newJournalConverter(cm, keepOriginal, undo)
+	c.config.Tools.ExiftoolPath = filepath.Join(toolDir, "exiftool")
+	c.config.Tools.DNGConverterPath = filepath.Join(toolDir, "dngconverter")
+	writeTool(t, c.config.Tools.ExiftoolPath, "exit 0\n")
+	// 参数形如 ... -d 目录 -o 文件名 源文件
+	writeTool(t, c.config.Tools.DNGConverterPath, `while [ $# -gt 1 ]; do
+	case $1 in
+	-d) dir=$2; shift ;;
+	-o) name=$2; shift ;;
+	esac
+	shift
+done
+cp "`+convertedPath+`" "$dir/$name"
+`)
+	c.ctx = context.Background()
+	c.toolManager = NewToolManager(c.config, c.logger, c.errorHandler)
+	c.metadataManager = NewMetadataManager(c.logger, c.config, c.errorHandler, mediaprobe.NewProber("", c.config.Tools.ExiftoolPath))
+	c.outputPaths = newOutputPathRegistry()
+	return c
+}
+
+func TestConvertDNGToJXL(t *testing.T) {
+	original := dngBytes(640, 480, 7, bytes.Repeat([]byte{0xAB}, 4096))
+	smaller := dngBytes(640, 480, mediaprobe.DNGCompressionJXL, nil)
+	larger := dngBytes(640, 480, mediaprobe.DNGCompressionJXL, bytes.Repeat([]byte{0xCD}, 8192))
+
+	tests := []struct {
+		name         string
+		converted    []byte
+		keepOriginal bool
+		undo         bool
+		output       string // 相对目录的输出文件名
+		replaced     bool   // 原文件位置上是转换结果
+		backedUp     bool
+	}{
+		{"原地替换并备份原件", smaller, false, true, "IMG_0001.dng", true, true},
+		{"未启用撤销时直接替换", smaller, false, false, "IMG_0001.dng", true, false},
+		{"保留原文件时追加序号", smaller, true, true, "IMG_0001_1.dng", false, false},
+		{"输出不小于原文件时保持原样", larger, false, true, "IMG_0001.dng", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cm := newTestCheckpoint(t, dir)
+			c := newDNGConverter(t, tt.converted, cm, tt.keepOriginal, tt.undo)
+			path := filepath.Join(dir, "IMG_0001.dng")
+			if err := os.WriteFile(path, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+			file := &MediaFile{Path: path, Size: int64(len(original))}
+
+			outputPath, err := c.convertDNGToJXL(file)
+			if err != nil {
+				t.Fatalf("convertDNGToJXL: %v", err)
+			}
+			if outputPath != filepath.Join(dir, tt.output) {
+				t.Errorf("输出 = %s, 期望 %s", outputPath, tt.output)
+			}
+			if data, _ := os.ReadFile(path); bytes.Equal(data, tt.converted) != tt.replaced {
+				t.Errorf("原文件位置被替换 = %v, 期望 %v", !tt.replaced, tt.replaced)
+			}
+			if tt.keepOriginal {
+				if data, _ := os.ReadFile(outputPath); !bytes.Equal(data, tt.converted) {
+					t.Error("输出内容应为转换结果")
+				}
+			}
+			if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) > 0 {
+				t.Errorf("遗留临时文件 %v", leftovers)
+			}
+
+			session := cm.CurrentSessionID()
+			backup := filepath.Join(dir, OriginalsDirName, session, "IMG_0001.dng")
+			data, err := os.ReadFile(backup)
+			if (err == nil) != tt.backedUp || (err == nil && !bytes.Equal(data, original)) {
+				t.Errorf("原件备份存在 = %v, 期望 %v", err == nil, tt.backedUp)
+			}
+			entries, _ := cm.ListJournal(session)
+			if (len(entries) == 1) != tt.backedUp {
+				t.Fatalf("撤销日志 = %+v, 期望记录 %v", entries, tt.backedUp)
+			}
+			if source, _ := cm.OutputSource(path); (source != "") != tt.replaced {
+				t.Errorf("原地替换的文件记录为输出 = %v, 期望 %v", source != "", tt.replaced)
+			}
+			if !tt.backedUp {
+				return
+			}
+
+			// 撤销以原件覆盖原地替换的输出，并删除输出记录
+			results, err := cm.Undo(session, UndoOptions{})
+			if err != nil || len(results) != 1 || !results[0].Restored {
+				t.Fatalf("Undo = %+v, %v", results, err)
+			}
+			if data, _ := os.ReadFile(path); !bytes.Equal(data, original) {
+				t.Error("撤销后原文件内容未恢复")
+			}
+			if source, _ := cm.OutputSource(path); source != "" {
+				t.Error("撤销后输出记录应被删除")
+			}
+		})
+	}
+}
+
+func TestUndoInPlaceModifiedOutput(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestCheckpoint(t, dir)
+	c := newDNGConverter(t, dngBytes(640, 480, mediaprobe.DNGCompressionJXL, nil), cm, false, true)
+	path := filepath.Join(dir, "IMG_0001.dng")
+	original := dngBytes(640, 480, 7, bytes.Repeat([]byte{0xAB}, 4096))
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.convertDNGToJXL(&MediaFile{Path: path, Size: int64(len(original))}); err != nil {
+		t.Fatalf("convertDNGToJXL: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := cm.Undo(cm.CurrentSessionID(), UndoOptions{})
+	if err != nil || len(results) != 1 || results[0].Error == nil {
+		t.Fatalf("输出被修改后撤销应失败: %+v, %v", results, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "edited" {
+		t.Error("撤销失败时不应覆盖被修改的文件")
+	}
+	results, err = cm.Undo(cm.CurrentSessionID(), UndoOptions{Force: true})
+	if err != nil || len(results) != 1 || !results[0].Restored {
+		t.Fatalf("强制撤销 = %+v, %v", results, err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, original) {
+		t.Error("强制撤销后原文件内容未恢复")
+	}
+}
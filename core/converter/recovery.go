@@ -0,0 +1,279 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"pixly/pkg/errorhandling"
+	"pixly/pkg/qualitysearch"
+	"pixly/pkg/whitelist"
+
+	"go.uber.org/zap"
+)
+
+// recoveryFileKey 与 recoveryRouteKey 随错误记录传给后备处理器的文件与失败的路由
+const (
+	recoveryFileKey  = "file"
+	recoveryRouteKey = "route"
+)
+
+// newRecoveryManager 按配置创建错误恢复管理器并注册后备处理器，未启用时返回nil
+func newRecoveryManager(c *Converter) *errorhandling.ErrorRecoveryManager {
+	cfg := c.config.Conversion.Recovery
+	if !cfg.Enabled {
+		return nil
+	}
+
+	recoveryConfig := errorhandling.DefaultRecoveryConfig()
+	recoveryConfig.MaxRetries = cfg.MaxRetries
+	recoveryConfig.EnableFallback = cfg.Fallback
+	// 重试与后备都是完整的转换，耗时由各工具自身控制
+	recoveryConfig.RecoveryTimeout = 0
+
+	manager := errorhandling.NewErrorRecoveryManager(c.logger, recoveryConfig)
+	manager.RegisterFallback(errorhandling.FallbackLowerQuality, c.fallbackHandler(c.fallbackLowerQuality))
+	manager.RegisterFallback(errorhandling.FallbackDifferentFormat, c.fallbackHandler(c.fallbackDifferentFormat))
+	manager.RegisterFallback(errorhandling.FallbackSimpleConversion, c.fallbackHandler(c.fallbackSimpleConversion))
+	manager.RegisterFallback(errorhandling.FallbackCopyOriginal, c.fallbackHandler(c.fallbackCopyOriginal))
+	return manager
+}
+
+// recoverable 路由失败后是否交给错误恢复：Live Photo、连拍动图与RAW有各自的配对与旁车逻辑，不做后备转换
+func recoverable(route Route) bool {
+	return !route.LivePhoto &&
+		route.Strategy != RouteStrategyRaw &&
+		route.Action != ActionSkip &&
+		route.Action != ActionBurstAnimation
+}
+
+// executeWithRecovery 执行路由决策，失败时先重新执行同一路由，再依次尝试后备转换。
+// 后备成功时result.Method记录实际使用的后备方式；首次执行由看门狗按预测耗时监控时限，成功时记录耗时样本。
+// 跳过以外的路由执行前按内存预算等待准入，重试与后备沿用同一准入
+func (c *Converter) executeWithRecovery(file *MediaFile, route Route, result *ConversionResult) (string, error) {
+	if route.Action != ActionSkip {
+		job := c.schedulerJob(file, route)
+		ticket, err := c.memoryScheduler.Acquire(c.fileContext(file), job)
+		if err != nil {
+			return "", err
+		}
+		defer c.memoryScheduler.Release(ticket)
+		// 准入后再分配线程：等待内存期间运行中的任务可能已经完成
+		threads, releaseThreads := c.assignThreads(file, job)
+		defer releaseThreads()
+		// 路由中启动的编码器进程登记到该准入，并使用分配到的线程数
+		file.job = &ToolJob{Ticket: ticket, Threads: threads}
+		defer func() { file.job = nil }()
+	}
+
+	stopWatch := c.watchFile(file, route)
+	start := time.Now()
+	outputPath, err := c.executeRoute(file, route)
+	stopWatch()
+	if err == nil {
+		c.observeTiming(file, route, outputPath, time.Since(start))
+	}
+	if err == nil || c.recovery == nil || !recoverable(route) || c.fileContext(file).Err() != nil {
+		return outputPath, err
+	}
+
+	operationID := string(route.Action) + ":" + file.Path
+	c.recovery.RegisterOperation(operationID, func(ctx context.Context) error {
+		retried, err := c.executeRoute(file, route)
+		if err == nil {
+			outputPath = retried
+		}
+		return err
+	})
+	defer c.recovery.UnregisterOperation(operationID)
+
+	recovery, recoveryErr := c.recovery.HandleErrorWithContext(c.fileContext(file), err, operationID, file.Path, map[string]interface{}{
+		recoveryFileKey:  file,
+		recoveryRouteKey: route,
+	})
+	if recoveryErr != nil || recovery == nil || !recovery.Success {
+		return "", err
+	}
+
+	if fallback := recovery.Fallback; fallback != nil {
+		result.Method = "fallback_" + fallback.FallbackType.String()
+		if fallback.FallbackType == errorhandling.FallbackCopyOriginal {
+			result.Skipped = true
+			result.SkipCategory = whitelist.SkipConversionFailed
+			result.SkipReason = "转换失败，已将原文件复制到输出目录: " + err.Error()
+		}
+		outputPath = fallback.OutputPath
+	}
+	c.logger.Info("转换失败后已恢复",
+		zap.String("file", file.Path),
+		zap.String("action", string(route.Action)),
+		zap.String("method", result.Method),
+		zap.Strings("actions_taken", recovery.ActionsTaken),
+		zap.NamedError("original_error", err))
+	return outputPath, nil
+}
+
+// fallbackHandler 将后备转换函数包装为后备处理器：从参数中取回文件与失败的路由，输出路径写入后备结果
+func (c *Converter) fallbackHandler(convert func(*MediaFile, Route) (string, string, error)) errorhandling.FallbackHandler {
+	return func(ctx context.Context, action *errorhandling.FallbackAction, params map[string]interface{}) (*errorhandling.FallbackResult, error) {
+		file, ok := params[recoveryFileKey].(*MediaFile)
+		if !ok {
+			return nil, fmt.Errorf("后备转换缺少源文件: %v", params["source_file"])
+		}
+		route, _ := params[recoveryRouteKey].(Route)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		outputPath, qualityLevel, err := convert(file, route)
+		if err != nil {
+			return nil, err
+		}
+		return &errorhandling.FallbackResult{
+			Success:      true,
+			FallbackType: action.Type,
+			OutputPath:   outputPath,
+			QualityLevel: qualityLevel,
+			Details: map[string]interface{}{
+				"failed_action": string(route.Action),
+				"output":        outputPath,
+			},
+		}, nil
+	}
+}
+
+// losslessOnly 后备是否只能输出无损结果：品质模式，或失败的路由本身是无损转换（任何模式下都不以有损结果替代）
+func (c *Converter) losslessOnly(route Route) bool {
+	return c.mode == ModeQuality || route.Action == ActionJXLLossless || route.Action == ActionAVIFLossless
+}
+
+// fallbackLowerQuality 以低于配置的品质重新编码为原目标格式；只能输出无损结果时与视频不降低品质
+func (c *Converter) fallbackLowerQuality(file *MediaFile, route Route) (string, string, error) {
+	if c.losslessOnly(route) {
+		return "", "", fmt.Errorf("失败的路由只接受无损结果，不降低品质: %s", file.Path)
+	}
+	if file.Type != TypeImage {
+		return "", "", fmt.Errorf("视频不使用降低品质的后备: %s", file.Path)
+	}
+
+	step := c.config.Conversion.Recovery.LowerQualityStep
+	quality := c.config.Conversion.Quality.AVIFQuality
+	if route.TargetExt == ".jxl" {
+		quality = c.config.Conversion.Quality.JXLQuality
+	}
+	quality -= step
+	if quality < 1 {
+		quality = 1
+	}
+	level := fmt.Sprintf("quality_%d", quality)
+
+	if c.isAnimated(file.Path) {
+		outputPath, err := c.convertToAVIFAnimatedCRF(file, qualitysearch.CRF(quality, 63))
+		return outputPath, level, err
+	}
+	if route.TargetExt == ".jxl" {
+		outputPath, err := c.convertToJXL(file, quality)
+		return outputPath, level, err
+	}
+	outputPath, err := c.convertToAVIF(file, quality)
+	return outputPath, level, err
+}
+
+// fallbackDifferentFormat 换用另一种目标格式：JXL失败时编码为AVIF，反之亦然；只能输出无损结果时仍为数学无损，没有无损后备时失败
+func (c *Converter) fallbackDifferentFormat(file *MediaFile, route Route) (string, string, error) {
+	if file.Type != TypeImage {
+		return "", "", fmt.Errorf("视频没有可替代的目标格式: %s", file.Path)
+	}
+
+	if c.isAnimated(file.Path) {
+		if route.TargetExt == ".avif" {
+			return "", "", fmt.Errorf("动图没有可替代的目标格式: %s", file.Path)
+		}
+		if c.losslessOnly(route) {
+			return "", "", fmt.Errorf("失败的路由只接受无损结果，动图没有无损的AVIF后备: %s", file.Path)
+		}
+		outputPath, err := c.convertToAVIFAnimatedCRF(file, qualitysearch.CRF(c.config.Conversion.Quality.AVIFQuality, 63))
+		return outputPath, "avif", err
+	}
+
+	if route.TargetExt == ".avif" {
+		if c.losslessOnly(route) {
+			outputPath, err := c.convertToJXLLossless(file)
+			return outputPath, "jxl_lossless", err
+		}
+		outputPath, err := c.convertToJXL(file, c.config.Conversion.Quality.JXLQuality)
+		return outputPath, "jxl", err
+	}
+	if c.losslessOnly(route) {
+		outputPath, err := c.convertToAVIFLossless(file)
+		return outputPath, "avif_lossless", err
+	}
+	outputPath, err := c.convertToAVIF(file, c.config.Conversion.Quality.AVIFQuality)
+	return outputPath, "avif", err
+}
+
+// fallbackSimpleConversion 最直接的转换路径：静态图片无损转换为JXL，视频重包装为MOV；与失败的路由相同时不再执行
+func (c *Converter) fallbackSimpleConversion(file *MediaFile, route Route) (string, string, error) {
+	if file.Type == TypeVideo {
+		if route.Action == ActionMOVRemux || route.Action == ActionVideoContainer {
+			return "", "", fmt.Errorf("失败的路由已是MOV重包装: %s", file.Path)
+		}
+		outputPath, err := c.convertToMOV(file)
+		return outputPath, "remux", err
+	}
+
+	if route.Action == ActionJXLLossless {
+		return "", "", fmt.Errorf("失败的路由已是JXL无损转换: %s", file.Path)
+	}
+	if c.isAnimated(file.Path) {
+		return "", "", fmt.Errorf("动图没有简单转换路径: %s", file.Path)
+	}
+	outputPath, err := c.convertToJXLLossless(file)
+	return outputPath, "jxl_lossless", err
+}
+
+// fallbackCopyOriginal 将原文件复制到输出目录，使输出目录保持完整；原地转换时原文件本就保留，不算恢复
+func (c *Converter) fallbackCopyOriginal(file *MediaFile, route Route) (string, string, error) {
+	if c.config.Output.DirectoryTemplate == "" {
+		return "", "", fmt.Errorf("原地转换时原文件保持不变，无需复制: %s", file.Path)
+	}
+
+	outputPath := c.getOutputPath(file, file.Extension)
+	if outputPath == "" || outputPath == file.Path {
+		return "", "", fmt.Errorf("输出路径与原文件相同，无需复制: %s", file.Path)
+	}
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+
+	source, err := os.Open(file.Path)
+	if err != nil {
+		return "", "", c.errorHandler.WrapError("failed to open original file", err)
+	}
+	defer source.Close()
+
+	tempPath := outputPath + ".tmp"
+	defer os.Remove(tempPath)
+	temp, err := os.Create(tempPath)
+	if err != nil {
+		return "", "", c.errorHandler.WrapError("failed to create temp file", err)
+	}
+	_, err = io.Copy(temp, source)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", c.errorHandler.WrapError("failed to copy original file", err)
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", "", err
+	}
+
+	// 复制保留全部元数据，时间戳另行同步
+	if stat, err := os.Stat(file.Path); err == nil {
+		os.Chtimes(outputPath, stat.ModTime(), stat.ModTime())
+	}
+	return outputPath, "original", nil
+}
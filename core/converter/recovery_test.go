@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pixly/pkg/errorhandling"
+)
+
+func TestFallbackLowerQualityLossless(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    ConversionMode
+		action  RouteAction
+		refused bool
+	}{
+		{"自动模式+的JXL无损路由", ModeAutoPlus, ActionJXLLossless, true},
+		{"自动模式+的AVIF无损路由", ModeAutoPlus, ActionAVIFLossless, true},
+		{"表情包模式的JXL无损路由", ModeEmoji, ActionJXLLossless, true},
+		{"表情包模式的AVIF无损路由", ModeEmoji, ActionAVIFLossless, true},
+		{"品质模式", ModeQuality, ActionBalanced, true},
+		{"自动模式+的平衡优化路由", ModeAutoPlus, ActionBalanced, false},
+		{"表情包模式的有损路由", ModeEmoji, ActionEmojiAVIF, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newJournalConverter(nil, false, false)
+			c.mode = tt.mode
+			// 视频不降低品质：未因无损拒绝时以视频的错误结束，不会启动编码器
+			file := &MediaFile{Path: "/in/a.mov", Type: TypeVideo}
+			_, _, err := c.fallbackLowerQuality(file, Route{Action: tt.action, TargetExt: ".jxl"})
+			if err == nil {
+				t.Fatal("fallbackLowerQuality 应返回错误")
+			}
+			if refused := strings.Contains(err.Error(), "只接受无损结果"); refused != tt.refused {
+				t.Errorf("fallbackLowerQuality = %v, 期望因无损拒绝 %v", err, tt.refused)
+			}
+		})
+	}
+}
+
+func TestRecoveryLosslessRoute(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(source, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newJournalConverter(nil, false, false)
+	c.mode = ModeAutoPlus
+	c.inputRoot = dir
+	c.outputPaths = newOutputPathRegistry()
+	c.config.Output.DirectoryTemplate = filepath.Join(dir, "out")
+	c.config.Conversion.Recovery.Enabled = true
+	c.config.Conversion.Recovery.MaxRetries = 2
+	c.config.Conversion.Recovery.Fallback = true
+	c.config.Conversion.Recovery.LowerQualityStep = 15
+	c.recovery = newRecoveryManager(c)
+
+	strategy, _ := c.recovery.GetRecoveryStrategy(errorhandling.ErrorTypeMemoryExhausted)
+	strategy.RetryDelay = time.Millisecond
+	c.recovery.UpdateRecoveryStrategy(errorhandling.ErrorTypeMemoryExhausted, strategy)
+
+	// 重新执行同一路由总是失败，之后按内存耗尽策略后备
+	retries := 0
+	c.recovery.RegisterOperation("jxl_lossless:"+source, func(ctx context.Context) error {
+		retries++
+		return errors.New("cjxl失败")
+	})
+	file := &MediaFile{Path: source, Extension: ".png", Type: TypeImage}
+	route := Route{Action: ActionJXLLossless, TargetExt: ".jxl"}
+	err := errorhandling.Classify(errors.New("cjxl失败"), errorhandling.ErrorTypeMemoryExhausted, errorhandling.SeverityHigh)
+	result, recoveryErr := c.recovery.HandleErrorWithContext(context.Background(), err, "jxl_lossless:"+source, source, map[string]interface{}{
+		recoveryFileKey:  file,
+		recoveryRouteKey: route,
+	})
+	if recoveryErr != nil || result == nil || !result.Success {
+		t.Fatalf("HandleErrorWithContext = %+v, %v, 期望复制原文件后恢复", result, recoveryErr)
+	}
+	if retries != 2 {
+		t.Errorf("重试 %d 次, 期望 2", retries)
+	}
+
+	// 无损路由不降低品质，简单转换与失败的路由相同，最终复制原文件
+	if result.Fallback.FallbackType != errorhandling.FallbackCopyOriginal {
+		t.Errorf("FallbackType = %s, 期望 copy_original", result.Fallback.FallbackType)
+	}
+	if data, err := os.ReadFile(result.Fallback.OutputPath); err != nil || string(data) != "png" {
+		t.Errorf("复制的原文件 = %q, %v", data, err)
+	}
+	fallbacks := c.recovery.GetFallbackStatistics()
+	for _, fallbackType := range []errorhandling.FallbackType{errorhandling.FallbackLowerQuality, errorhandling.FallbackSimpleConversion} {
+		if stats := fallbacks[fallbackType]; stats.FailedUsage != 1 || stats.SuccessfulUsage != 0 {
+			t.Errorf("%s 后备统计 = %+v, 期望拒绝1次", fallbackType, stats)
+		}
+	}
+	if stats := fallbacks[errorhandling.FallbackCopyOriginal]; stats.SuccessfulUsage != 1 {
+		t.Errorf("copy_original 后备统计 = %+v, 期望成功1次", stats)
+	}
+	if retry := c.recovery.GetRetryStatistics()[errorhandling.ErrorTypeMemoryExhausted]; retry.TotalRetries != 2 || retry.FailedRetries != 2 {
+		t.Errorf("重试统计 = %+v, 期望失败2次", retry)
+	}
+}
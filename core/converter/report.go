@@ -4,34 +4,37 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"pixly/pkg/whitelist"
+
 	"go.uber.org/zap"
 )
 
 // DetailedReport 详细转换报告
 type DetailedReport struct {
-	StartTime        time.Time              `json:"start_time"`
-	EndTime          time.Time              `json:"end_time"`
-	Duration         time.Duration          `json:"duration"`
-	SourceDirectory  string                 `json:"source_directory"`
-	TotalFiles       int                    `json:"total_files"`
-	ProcessedFiles   int                    `json:"processed_files"`
-	SuccessfulFiles  int                    `json:"successful_files"`
-	FailedFiles      int                    `json:"failed_files"`
-	SkippedFiles     int                    `json:"skipped_files"`
-	TotalSizeBefore  int64                  `json:"total_size_before"`
-	TotalSizeAfter   int64                  `json:"total_size_after"`
-	SpaceSaved       int64                  `json:"space_saved"`
-	CompressionRatio float64                `json:"compression_ratio"`
-	ConversionMode   string                 `json:"conversion_mode"`
-	FileDetails      []FileConversionDetail `json:"file_details"`
-	FormatSummary    map[string]FormatStats `json:"format_summary"`
-	SystemInfo       SystemInfo             `json:"system_info"`
-	Errors           []ConversionError      `json:"errors"`
+	StartTime         time.Time              `json:"start_time"`
+	EndTime           time.Time              `json:"end_time"`
+	Duration          time.Duration          `json:"duration"`
+	SourceDirectory   string                 `json:"source_directory"`
+	TotalFiles        int                    `json:"total_files"`
+	ProcessedFiles    int                    `json:"processed_files"`
+	SuccessfulFiles   int                    `json:"successful_files"`
+	FailedFiles       int                    `json:"failed_files"`
+	SkippedFiles      int                    `json:"skipped_files"`
+	TotalSizeBefore   int64                  `json:"total_size_before"`
+	TotalSizeAfter    int64                  `json:"total_size_after"`
+	SpaceSaved        int64                  `json:"space_saved"`
+	CompressionRatio  float64                `json:"compression_ratio"`
+	ConversionMode    string                 `json:"conversion_mode"`
+	FileDetails       []FileConversionDetail `json:"file_details"`
+	FormatSummary     map[string]FormatStats `json:"format_summary"`
+	SkippedByCategory []SkippedCategory      `json:"skipped_by_category,omitempty"`
+	SystemInfo        SystemInfo             `json:"system_info"`
+	Errors            []ConversionError      `json:"errors"`
 }
 
 // FileConversionDetail 文件转换详细信息
@@ -46,9 +49,12 @@ type FileConversionDetail struct {
 	ProcessingTime   time.Duration `json:"processing_time"`
 	Method           string        `json:"method"`
 	Success          bool          `json:"success"`
-	Skipped          bool          `json:"skipped"`               // 文件是否被跳过
-	SkipReason       string        `json:"skip_reason,omitempty"` // 跳过原因
+	Skipped          bool          `json:"skipped"`                 // 文件是否被跳过
+	SkipReason       string        `json:"skip_reason,omitempty"`   // 跳过原因
+	SkipCategory     string        `json:"skip_category,omitempty"` // 跳过类别
 	Error            string        `json:"error,omitempty"`
+	QualityMetric    string        `json:"quality_metric,omitempty"` // 感知质量指标
+	QualityScore     float64       `json:"quality_score,omitempty"`  // 感知质量评分
 	MediaInfo        *MediaInfo    `json:"media_info,omitempty"`
 }
 
@@ -88,6 +94,18 @@ type MediaInfo struct {
 	ColorSpace string  `json:"color_space,omitempty"`
 }
 
+// SkippedCategory 同一跳过类别的全部文件
+type SkippedCategory struct {
+	Category string        `json:"category"`
+	Files    []SkippedFile `json:"files"`
+}
+
+// SkippedFile 跳过的文件及原因
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
 // FormatStats 格式统计
 type FormatStats struct {
 	Count              int     `json:"count"`
@@ -120,24 +138,25 @@ func (c *Converter) generateReport() error {
 	startTime := endTime.Add(-c.stats.TotalDuration)
 
 	report := DetailedReport{
-		StartTime:        startTime,
-		EndTime:          endTime,
-		Duration:         c.stats.TotalDuration,
-		SourceDirectory:  c.getSourceDirectory(),
-		TotalFiles:       c.stats.TotalFiles,
-		ProcessedFiles:   c.stats.ProcessedFiles,
-		SuccessfulFiles:  c.stats.SuccessfulFiles,
-		FailedFiles:      c.stats.FailedFiles,
-		SkippedFiles:     c.stats.SkippedFiles,
-		TotalSizeBefore:  c.stats.TotalSize,
-		TotalSizeAfter:   c.stats.CompressedSize,
-		SpaceSaved:       c.stats.TotalSize - c.stats.CompressedSize,
-		CompressionRatio: c.stats.CompressionRatio,
-		ConversionMode:   string(c.mode),
-		FileDetails:      c.generateFileDetails(),
-		FormatSummary:    c.generateFormatSummary(),
-		SystemInfo:       c.getSystemInfo(),
-		Errors:           c.collectErrors(),
+		StartTime:         startTime,
+		EndTime:           endTime,
+		Duration:          c.stats.TotalDuration,
+		SourceDirectory:   c.getSourceDirectory(),
+		TotalFiles:        c.stats.TotalFiles,
+		ProcessedFiles:    c.stats.ProcessedFiles,
+		SuccessfulFiles:   c.stats.SuccessfulFiles,
+		FailedFiles:       c.stats.FailedFiles,
+		SkippedFiles:      c.stats.SkippedFiles,
+		TotalSizeBefore:   c.stats.TotalSize,
+		TotalSizeAfter:    c.stats.CompressedSize,
+		SpaceSaved:        c.stats.TotalSize - c.stats.CompressedSize,
+		CompressionRatio:  c.stats.CompressionRatio,
+		ConversionMode:    string(c.mode),
+		FileDetails:       c.generateFileDetails(),
+		FormatSummary:     c.generateFormatSummary(),
+		SkippedByCategory: c.generateSkippedFiles(),
+		SystemInfo:        c.getSystemInfo(),
+		Errors:            c.collectErrors(),
 	}
 
 	// 保存JSON报告
@@ -174,10 +193,13 @@ func (c *Converter) generateFileDetails() []FileConversionDetail {
 			Success:          result.Success,
 			Skipped:          result.Skipped,
 			SkipReason:       result.SkipReason,
+			QualityMetric:    result.QualityMetric,
+			QualityScore:     result.QualityScore,
 		}
 
 		// 处理跳过的文件
 		if result.Skipped {
+			detail.SkipCategory = result.SkipCategory.String()
 			// 跳过的文件输出路径为原路径，输出格式为原格式
 			detail.OutputPath = result.OriginalFile.Path
 			detail.OutputFormat = result.OriginalFile.Extension
@@ -230,6 +252,34 @@ func (c *Converter) generateFormatSummary() map[string]FormatStats {
 	return formatStats
 }
 
+// generateSkippedFiles 按跳过类别列出全部跳过的文件，类别按定义顺序排列
+func (c *Converter) generateSkippedFiles() []SkippedCategory {
+	groups := make(map[whitelist.SkipCategory][]SkippedFile)
+	for _, result := range c.results {
+		if !result.Skipped || result.OriginalFile == nil {
+			continue
+		}
+		groups[result.SkipCategory] = append(groups[result.SkipCategory], SkippedFile{
+			Path:   result.OriginalFile.Path,
+			Reason: result.SkipReason,
+		})
+	}
+
+	categories := make([]whitelist.SkipCategory, 0, len(groups))
+	for category := range groups {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+
+	skipped := make([]SkippedCategory, 0, len(categories))
+	for _, category := range categories {
+		files := groups[category]
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		skipped = append(skipped, SkippedCategory{Category: category.String(), Files: files})
+	}
+	return skipped
+}
+
 // getMediaInfo 获取媒体文件信息
 func (c *Converter) getMediaInfo(filePath string) *MediaInfo {
 	if !c.isMediaFile(filePath) {
@@ -241,41 +291,12 @@ func (c *Converter) getMediaInfo(filePath string) *MediaInfo {
 		return cachedMediaInfo
 	}
 
-	// 使用FFprobe获取媒体信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
-	}
-
-	cmd := exec.Command(c.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
+	// 使用共享的FFprobe结果（输出文件在完整性验证时已探测）
+	probeData, err := c.prober.Get(filePath).FFprobe(c.ctx)
 	if err != nil {
 		return nil
 	}
 
-	// 解析FFprobe输出
-	var probeData struct {
-		Format struct {
-			Duration string `json:"duration"`
-			BitRate  string `json:"bit_rate"`
-		} `json:"format"`
-		Streams []struct {
-			CodecName  string `json:"codec_name"`
-			CodecType  string `json:"codec_type"`
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			RFrameRate string `json:"r_frame_rate"`
-			ColorSpace string `json:"color_space"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil {
-		return nil
-	}
-
 	mediaInfo := &MediaInfo{}
 
 	// 填充基本文件信息
@@ -288,43 +309,20 @@ func (c *Converter) getMediaInfo(filePath string) *MediaInfo {
 	}
 
 	// 解析格式信息
-	if probeData.Format.Duration != "" {
-		if duration, err := strconv.ParseFloat(probeData.Format.Duration, 64); err == nil {
-			mediaInfo.Duration = duration
-		}
-	}
-
-	if probeData.Format.BitRate != "" {
-		if bitrate, err := strconv.Atoi(probeData.Format.BitRate); err == nil {
-			mediaInfo.Bitrate = bitrate
-		}
+	mediaInfo.Duration = probeData.Duration()
+	if bitrate, err := strconv.Atoi(probeData.Format.BitRate); err == nil {
+		mediaInfo.Bitrate = bitrate
 	}
 
 	// 解析流信息
-	for _, stream := range probeData.Streams {
-		switch stream.CodecType {
-		case "video":
-			mediaInfo.Width = stream.Width
-			mediaInfo.Height = stream.Height
-			mediaInfo.Codec = stream.CodecName
-			mediaInfo.ColorSpace = stream.ColorSpace
-
-			// 解析帧率
-			if stream.RFrameRate != "" && strings.Contains(stream.RFrameRate, "/") {
-				parts := strings.Split(stream.RFrameRate, "/")
-				if len(parts) == 2 {
-					if num, err := strconv.ParseFloat(parts[0], 64); err == nil {
-						if den, err := strconv.ParseFloat(parts[1], 64); err == nil && den > 0 {
-							mediaInfo.FrameRate = num / den
-						}
-					}
-				}
-			}
-
-		case "audio":
-			mediaInfo.HasAudio = true
-		}
+	if video := probeData.VideoStream(); video != nil {
+		mediaInfo.Width = video.Width
+		mediaInfo.Height = video.Height
+		mediaInfo.Codec = video.CodecName
+		mediaInfo.ColorSpace = video.ColorSpace
+		mediaInfo.FrameRate = video.FrameRate()
 	}
+	mediaInfo.HasAudio = probeData.AudioStream() != nil
 
 	// 将获取到的媒体信息放入内存池
 	c.memoryPool.PutMediaInfo(mediaInfo)
@@ -508,6 +506,26 @@ func (c *Converter) generateReadableReport(report DetailedReport) error {
 		return c.errorHandler.WrapError("write newline to report", err)
 	}
 
+	// 写入跳过文件（按类别全部列出，便于确认源文件未被改动）
+	if len(report.SkippedByCategory) > 0 {
+		if _, err := fmt.Fprintf(file, "=== 跳过文件（按类别） ===\n"); err != nil {
+			return c.errorHandler.WrapError("write skipped files header to report", err)
+		}
+		for _, group := range report.SkippedByCategory {
+			if _, err := fmt.Fprintf(file, "[%s] %d个文件\n", group.Category, len(group.Files)); err != nil {
+				return c.errorHandler.WrapError("write skip category to report", err)
+			}
+			for _, skipped := range group.Files {
+				if _, err := fmt.Fprintf(file, "  %s — %s\n", skipped.Path, skipped.Reason); err != nil {
+					return c.errorHandler.WrapError("write skipped file to report", err)
+				}
+			}
+		}
+		if _, err := fmt.Fprintf(file, "\n"); err != nil {
+			return c.errorHandler.WrapError("write newline to report", err)
+		}
+	}
+
 	// 写入文件详情（限制前20个）
 	if _, err := fmt.Fprintf(file, "=== 文件转换详情 (前20个) ===\n"); err != nil {
 		return c.errorHandler.WrapError("write file details header to report", err)
@@ -538,6 +556,11 @@ func (c *Converter) generateReadableReport(report DetailedReport) error {
 			if _, err := fmt.Fprintf(file, "  格式: %s → %s\n", detail.OriginalFormat, detail.OutputFormat); err != nil {
 				return c.errorHandler.WrapError("write format conversion to report", err)
 			}
+			if detail.QualityMetric != "" {
+				if _, err := fmt.Fprintf(file, "  感知质量: %s %.4f\n", detail.QualityMetric, detail.QualityScore); err != nil {
+					return c.errorHandler.WrapError("write quality score to report", err)
+				}
+			}
 
 			if detail.MediaInfo != nil {
 				if detail.MediaInfo.Width > 0 {
@@ -649,6 +672,9 @@ func (c *Converter) verifyConversionResults() error {
 
 // getSourceDirectory 获取源目录
 func (c *Converter) getSourceDirectory() string {
+	if c.inputRoot != "" {
+		return c.inputRoot
+	}
 	// 简化版本，从第一个结果获取目录
 	if len(c.results) > 0 {
 		return GlobalPathUtils.GetDirName(c.results[0].OriginalFile.Path)
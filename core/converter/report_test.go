@@ -0,0 +1,40 @@
+package converter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"pixly/pkg/whitelist"
+)
+
+func TestGenerateSkippedFiles(t *testing.T) {
+	skipped := func(path string, category whitelist.SkipCategory, reason string) *ConversionResult {
+		return &ConversionResult{OriginalFile: &MediaFile{Path: path}, Success: true, Skipped: true, SkipCategory: category, SkipReason: reason}
+	}
+	c := &Converter{results: []*ConversionResult{
+		skipped("/p/b.psd", whitelist.SkipCreativeSource, "创作源文件：Photoshop工程文件"),
+		{OriginalFile: &MediaFile{Path: "/p/c.png"}, Success: true}, // 已转换
+		skipped("/p/z.jxl", whitelist.SkipTargetFormat, "已是目标格式"),
+		skipped("/p/a.svg", whitelist.SkipCreativeSource, "矢量图：SVG矢量图"),
+		skipped("/p/d.pdf", whitelist.SkipNonMedia, "文档文件：PDF文档"),
+		{Skipped: true, SkipCategory: whitelist.SkipNonMedia},                   // 没有原文件信息
+		{OriginalFile: &MediaFile{Path: "/p/e.png"}, Error: errors.New("编码失败")}, // 失败
+	}}
+
+	want := []SkippedCategory{
+		{Category: "已是目标格式", Files: []SkippedFile{{Path: "/p/z.jxl", Reason: "已是目标格式"}}},
+		{Category: "非媒体文件", Files: []SkippedFile{{Path: "/p/d.pdf", Reason: "文档文件：PDF文档"}}},
+		{Category: "创作源文件", Files: []SkippedFile{
+			{Path: "/p/a.svg", Reason: "矢量图：SVG矢量图"},
+			{Path: "/p/b.psd", Reason: "创作源文件：Photoshop工程文件"},
+		}},
+	}
+	if got := c.generateSkippedFiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("generateSkippedFiles = %+v, 期望 %+v", got, want)
+	}
+
+	if got := (&Converter{}).generateSkippedFiles(); len(got) != 0 {
+		t.Errorf("没有跳过的文件时 generateSkippedFiles = %+v, 期望为空", got)
+	}
+}
@@ -0,0 +1,213 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"pixly/config"
+	"pixly/pkg/contentcache"
+	"pixly/pkg/whitelist"
+
+	"go.uber.org/zap"
+)
+
+// newResultCache 打开持久化内容缓存；未启用或打开失败时返回nil（缓存是可选的）
+func newResultCache(cfg *config.Config, logger *zap.Logger) *contentcache.Cache {
+	if !cfg.Advanced.Cache.Enabled {
+		return nil
+	}
+
+	cache, err := contentcache.Open(contentcache.OptionsFromConfig(cfg.Advanced.Cache), logger)
+	if err != nil {
+		logger.Warn("打开内容缓存失败，本次运行不使用缓存", zap.Error(err))
+		return nil
+	}
+	return cache
+}
+
+// settingsFingerprint 转换设置指纹：模式、转换配置、输出配置、编码工具及其版本与按工具能力加入的参数
+// 任一变化都会使缓存的转换结果失效
+func (c *Converter) settingsFingerprint() string {
+	data, err := json.Marshal(struct {
+		Mode       ConversionMode
+		Conversion config.ConversionConfig
+		Output     config.OutputConfig
+		Tools      config.ToolsConfig
+		Versions   map[string]string
+		Flags      encoderFlags
+	}{c.mode, c.config.Conversion, c.config.Output, c.config.Tools, c.toolVersions(), c.toolFlags()})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// toolVersions 编码工具的版本，工具升级后编码结果可能不同
+func (c *Converter) toolVersions() map[string]string {
+	tools := c.config.Tools
+	versions := make(map[string]string)
+	for _, path := range []string{tools.FFmpegPath, tools.CjxlPath, tools.AvifencPath, tools.DNGConverterPath} {
+		if path != "" {
+			versions[path] = c.toolManager.Version(c.ctx, path)
+		}
+	}
+	return versions
+}
+
+// encoderFlags 按工具能力选择的编码参数
+type encoderFlags struct {
+	AVIFGainMap []string        // avifenc读取JPEG增益图的参数
+	Encoders    map[string]bool // ffmpeg可用的视频编码器
+	LibVMAF     bool            // 视频CRF搜索是否按VMAF评分
+}
+
+// toolFlags 收集按工具能力加入的编码参数
+func (c *Converter) toolFlags() encoderFlags {
+	video := c.videoCapabilities()
+	return encoderFlags{
+		AVIFGainMap: c.avifGainMap().Flags,
+		Encoders:    video.Encoders,
+		LibVMAF:     video.LibVMAF,
+	}
+}
+
+// loadCacheEntry 加载文件的缓存条目，命中时复用形态与品质分析结果（每个文件只查询一次）
+func (c *Converter) loadCacheEntry(file *MediaFile) *contentcache.Entry {
+	if c.resultCache == nil || file.cacheLoaded {
+		return file.cacheEntry
+	}
+	file.cacheLoaded = true
+
+	entry, ok := c.resultCache.Lookup(file.Path, file.Size, file.ModTime)
+	if !ok {
+		return nil
+	}
+	file.cacheEntry = entry
+
+	var details MediaDetails
+	if file.details == nil && entry.DecodeMorphology(&details) && details.Version == mediaDetailsVersion {
+		file.details = &details
+	}
+	var metrics ImageQualityMetrics
+	if file.metrics == nil && entry.DecodeQuality(&metrics) {
+		file.metrics = &metrics
+	}
+	return entry
+}
+
+// detectFileType 检测文件形态，优先使用缓存的检测结果
+func (c *Converter) detectFileType(file *MediaFile) (*MediaDetails, error) {
+	c.loadCacheEntry(file)
+	if file.details != nil {
+		return file.details, nil
+	}
+
+	details, err := c.fileTypeDetector.DetectFileType(file.Path)
+	if err == nil {
+		file.details = details
+	}
+	return details, err
+}
+
+// cachedOutcome 返回可复用的转换结果：设置一致，且已转换文件的输出仍然存在
+func (c *Converter) cachedOutcome(file *MediaFile) (*contentcache.Outcome, bool) {
+	entry := c.loadCacheEntry(file)
+	if entry == nil || entry.Outcome == nil || entry.Outcome.Settings != c.settingsKey {
+		return nil, false
+	}
+
+	outcome := entry.Outcome
+	switch outcome.Status {
+	case contentcache.StatusConverted:
+		stat, err := os.Stat(outcome.OutputPath)
+		if err != nil || stat.Size() != outcome.OutputSize {
+			return nil, false
+		}
+		return outcome, true
+	case contentcache.StatusSkipped:
+		return outcome, true
+	default:
+		// 失败的文件每次都重新尝试
+		return nil, false
+	}
+}
+
+// applyCachedOutcome 命中缓存时以跳过结果填充result，返回是否命中
+func (c *Converter) applyCachedOutcome(file *MediaFile, result *ConversionResult) bool {
+	outcome, ok := c.cachedOutcome(file)
+	if !ok {
+		return false
+	}
+
+	file.cacheHit = true
+	result.OriginalFile = file
+	result.OriginalSize = file.Size
+	result.OutputPath = outcome.OutputPath
+	result.CompressedSize = file.Size
+	result.Success = true
+	result.Skipped = true
+	result.Method = "cache"
+	result.SkipCategory = whitelist.SkipCached
+	result.SkipReason = "缓存命中：已使用相同设置处理"
+	if outcome.Status == contentcache.StatusSkipped && outcome.SkipReason != "" {
+		result.SkipReason = "缓存命中：" + outcome.SkipReason
+	}
+	if result.OutputPath == "" {
+		result.OutputPath = file.Path
+	}
+
+	c.logger.Debug("内容缓存命中，跳过文件",
+		zap.String("file", file.Path),
+		zap.String("status", outcome.Status),
+		zap.String("output", outcome.OutputPath))
+	return true
+}
+
+// storeCacheResult 写入分析结果与最终转换结果；原地转换后源文件已不存在时不写入
+func (c *Converter) storeCacheResult(file *MediaFile, result *ConversionResult) {
+	if c.resultCache == nil || file.cacheHit {
+		return
+	}
+
+	stat, err := os.Stat(file.Path)
+	if err != nil {
+		return
+	}
+
+	entry, err := c.resultCache.NewEntry(file.Path, stat.Size(), stat.ModTime())
+	if err != nil {
+		c.logger.Debug("计算内容指纹失败", zap.String("file", file.Path), zap.Error(err))
+		return
+	}
+	entry.SetMorphology(file.details)
+	entry.SetQuality(file.metrics)
+
+	outcome := &contentcache.Outcome{
+		Settings:       c.settingsKey,
+		OutputPath:     result.OutputPath,
+		Method:         result.Method,
+		QualityMetric:  result.QualityMetric,
+		QualityScore:   result.QualityScore,
+		QualitySetting: file.QualitySetting,
+	}
+	switch {
+	case result.Success && result.Skipped:
+		outcome.Status = contentcache.StatusSkipped
+		outcome.SkipReason = result.SkipReason
+	case result.Success:
+		outcome.Status = contentcache.StatusConverted
+		if outputStat, err := os.Stat(result.OutputPath); err == nil {
+			outcome.OutputSize = outputStat.Size()
+		}
+	default:
+		outcome.Status = contentcache.StatusFailed
+	}
+	entry.Outcome = outcome
+
+	if err := c.resultCache.Store(entry); err != nil {
+		c.logger.Debug("写入内容缓存失败", zap.String("file", file.Path), zap.Error(err))
+	}
+}
@@ -0,0 +1,142 @@
+package converter
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// RouteAction 路由动作：策略为单个文件选定的转换方法
+type RouteAction string
+
+const (
+	ActionSkip              RouteAction = "skip"                // 跳过，保持原文件
+	ActionJXLLossless       RouteAction = "jxl_lossless"        // 无损转换为JXL（JPEG使用lossless_jpeg=1）
+	ActionAVIFLossless      RouteAction = "avif_lossless"       // 无损转换为AVIF（动图）
+	ActionBalanced          RouteAction = "balanced"            // 平衡优化：无损重包装 → 数学无损 → 有损探测
+	ActionEmojiAVIF         RouteAction = "emoji_avif"          // 表情包模式：静图极限压缩为AVIF
+	ActionEmojiAnimatedAVIF RouteAction = "emoji_animated_avif" // 表情包模式：动图CRF搜索压缩为AVIF
+	ActionVideoContainer    RouteAction = "video_container"     // 视频容器转换（不兼容编码跳过）
+	ActionMOVRemux          RouteAction = "mov_remux"           // 视频重包装为MOV
+	ActionVideoTranscode    RouteAction = "video_transcode"     // 视频重新编码为AV1/HEVC（采样评分选择CRF）
+	ActionGainMapAVIF       RouteAction = "gain_map_avif"       // 带增益图的HDR照片转换为保留增益图的AVIF
+	ActionBurstAnimation    RouteAction = "burst_animation"     // 连拍各帧合成为一个AVIF/JXL动图
+	ActionRawPreview        RouteAction = "raw_preview"         // RAW嵌入的全尺寸JPEG预览无损转换为JXL旁车文件
+	ActionDNGJXL            RouteAction = "dng_jxl"             // DNG无损转换为JPEG XL压缩的DNG
+)
+
+// 路由所属的处理路线
+const (
+	RouteStrategySkip     = "skip"
+	RouteStrategyQuality  = "quality"
+	RouteStrategyBalanced = "balanced"
+	RouteStrategyEmoji    = "emoji"
+	RouteStrategyVideo    = "video"
+	RouteStrategyBurst    = "burst"
+	RouteStrategyRaw      = "raw"
+)
+
+// Route 路由决策：策略对单个文件的处理结论，转换与计划模式共用
+type Route struct {
+	Strategy  string      `json:"strategy"`
+	Action    RouteAction `json:"action"`
+	TargetExt string      `json:"target_format"`
+	Reason    string      `json:"reason"`
+	LivePhoto bool        `json:"live_photo,omitempty"` // Live Photo/Motion Photo：转换后保留配对标识
+	Frames    []string    `json:"frames,omitempty"`     // 连拍动图按顺序合成的各帧
+}
+
+// skipRoute 创建跳过路由
+func skipRoute(reason string) Route {
+	return Route{Strategy: RouteStrategySkip, Action: ActionSkip, Reason: reason}
+}
+
+// estimatedRatios 各动作的经验体积比例（输出/输入），仅用于计划预估
+var estimatedRatios = map[RouteAction]float64{
+	ActionSkip:              1.0,
+	ActionJXLLossless:       0.8,
+	ActionAVIFLossless:      0.7,
+	ActionBalanced:          0.6,
+	ActionEmojiAVIF:         0.35,
+	ActionEmojiAnimatedAVIF: 0.3,
+	ActionVideoContainer:    1.0,
+	ActionMOVRemux:          1.0,
+	ActionVideoTranscode:    0.5,
+	ActionGainMapAVIF:       0.6,
+	ActionBurstAnimation:    0.5,
+	ActionRawPreview:        0.1,
+	ActionDNGJXL:            0.6,
+}
+
+// EstimateSize 按经验比例预估输出体积
+func (r Route) EstimateSize(originalSize int64) int64 {
+	ratio, ok := estimatedRatios[r.Action]
+	if !ok {
+		ratio = 1.0
+	}
+	return int64(float64(originalSize) * ratio)
+}
+
+// routeFile 返回文件的路由决策：执行计划时使用计划中的决策，否则由当前策略路由
+func (c *Converter) routeFile(file *MediaFile) Route {
+	if file.route != nil {
+		return *file.route
+	}
+	if route, ok := c.rawRoute(file); ok {
+		return route
+	}
+	if route, ok := c.burstRoute(file); ok {
+		return route
+	}
+	if file.Type == TypeVideo {
+		return c.markLivePhotoRoute(file, c.strategy.RouteVideo(file))
+	}
+	return c.markLivePhotoRoute(file, c.strategy.RouteImage(file))
+}
+
+// executeRoute 执行路由决策
+func (c *Converter) executeRoute(file *MediaFile, route Route) (string, error) {
+	c.logger.Debug("执行路由决策",
+		zap.String("file", file.Path),
+		zap.String("strategy", route.Strategy),
+		zap.String("action", string(route.Action)),
+		zap.String("reason", route.Reason))
+
+	if route.LivePhoto {
+		return c.executeLivePhoto(file, route)
+	}
+
+	switch route.Action {
+	case ActionSkip:
+		return file.Path, nil
+	case ActionJXLLossless:
+		return c.convertToJXLLossless(file)
+	case ActionAVIFLossless:
+		return c.convertToAVIF(file, 100)
+	case ActionBalanced:
+		autoPlus := &AutoPlusStrategy{converter: c, errorHandler: c.errorHandler}
+		return autoPlus.applyBalancedOptimization(file)
+	case ActionEmojiAVIF:
+		emoji := &EmojiStrategy{converter: c, errorHandler: c.errorHandler}
+		return emoji.tryAggressiveAVIF(file)
+	case ActionEmojiAnimatedAVIF:
+		emoji := &EmojiStrategy{converter: c, errorHandler: c.errorHandler}
+		return emoji.tryAggressiveAnimatedAVIF(file)
+	case ActionVideoContainer:
+		return c.convertVideoContainer(file)
+	case ActionMOVRemux:
+		return c.convertToMOV(file)
+	case ActionVideoTranscode:
+		return c.transcodeVideo(file)
+	case ActionGainMapAVIF:
+		return c.convertToGainMapAVIF(file)
+	case ActionBurstAnimation:
+		return c.convertBurstAnimation(file, route)
+	case ActionRawPreview:
+		return c.convertRawPreview(file)
+	case ActionDNGJXL:
+		return c.convertDNGToJXL(file)
+	default:
+		return "", fmt.Errorf("未知的路由动作: %s", route.Action)
+	}
+}
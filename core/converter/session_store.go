@@ -0,0 +1,366 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// checkpointsDirName 状态目录下存放检查点数据库的子目录
+const checkpointsDirName = "checkpoints"
+
+// stateDBName 状态目录下与目标目录无关的全局状态数据库，保存处理耗时样本
+const stateDBName = "state.db"
+
+// stateOpenTimeout 全局状态数据库的锁等待时间：其他目录上的会话正在使用时很快放弃，不阻塞本次转换
+const stateOpenTimeout = 500 * time.Millisecond
+
+// DefaultStateDir 默认的用户状态目录：$XDG_STATE_HOME/pixly，其次 ~/.local/state/pixly
+func DefaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "pixly")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "pixly")
+	}
+	return filepath.Join(os.TempDir(), "pixly_state")
+}
+
+// SessionStore 会话存储：每个目标目录使用独立的检查点数据库，不同目录上的并发会话互不阻塞、互不覆盖
+type SessionStore struct {
+	dir          string
+	stateDir     string
+	logger       *zap.Logger
+	errorHandler *ErrorHandler
+}
+
+// StoredSession 会话存储中的会话
+type StoredSession struct {
+	SessionInfo
+	DBPath  string `json:"db_path"`
+	Running bool   `json:"running"` // 数据库被其他进程占用，会话正在运行，详情不可读
+}
+
+// SessionExport 会话导出内容
+type SessionExport struct {
+	ExportedAt time.Time       `json:"exported_at"`
+	DBPath     string          `json:"db_path"`
+	Session    *SessionInfo    `json:"session,omitempty"` // 已完成的会话只剩撤销日志
+	Files      []*FileRecord   `json:"files"`
+	Journal    []*JournalEntry `json:"journal,omitempty"`
+}
+
+// PruneOptions 会话清理选项
+type PruneOptions struct {
+	OlderThan time.Duration // 清理最近更新早于此时长的会话
+	All       bool          // 清理所有未运行的会话
+	DryRun    bool          // 只列出不删除
+}
+
+// NewSessionStore 创建会话存储，stateDir 为空时使用默认的用户状态目录
+func NewSessionStore(logger *zap.Logger, stateDir string, errorHandler *ErrorHandler) *SessionStore {
+	if stateDir == "" {
+		stateDir = DefaultStateDir()
+	}
+	return &SessionStore{
+		dir:          filepath.Join(stateDir, checkpointsDirName),
+		stateDir:     stateDir,
+		logger:       logger,
+		errorHandler: errorHandler,
+	}
+}
+
+// Dir 返回检查点数据库目录
+func (s *SessionStore) Dir() string {
+	return s.dir
+}
+
+// StateDBPath 返回全局状态数据库路径
+func (s *SessionStore) StateDBPath() string {
+	return filepath.Join(s.stateDir, stateDBName)
+}
+
+// OpenState 打开全局状态数据库，调用方负责关闭；其他进程正在使用时返回错误
+func (s *SessionStore) OpenState() (*bbolt.DB, error) {
+	if err := os.MkdirAll(s.stateDir, 0755); err != nil {
+		return nil, s.errorHandler.WrapError("创建状态目录失败", err)
+	}
+	db, err := bbolt.Open(s.StateDBPath(), 0600, &bbolt.Options{
+		Timeout: stateOpenTimeout,
+	})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("状态数据库正被其他进程使用: %s", s.StateDBPath())
+	}
+	if err != nil {
+		return nil, s.errorHandler.WrapError("打开状态数据库失败", err)
+	}
+	return db, nil
+}
+
+// DBPath 返回目标目录对应的检查点数据库：目录名便于辨认，路径哈希保证不同目录互不冲突
+func (s *SessionStore) DBPath(targetDir string) string {
+	absDir, err := filepath.Abs(targetDir)
+	if err != nil {
+		absDir = targetDir
+	}
+	sum := sha256.Sum256([]byte(absDir))
+
+	var builder strings.Builder
+	builder.WriteString(sanitizeDBName(filepath.Base(absDir)))
+	builder.WriteString("-")
+	builder.WriteString(hex.EncodeToString(sum[:6]))
+	builder.WriteString(".db")
+	return filepath.Join(s.dir, builder.String())
+}
+
+// Open 打开目标目录的检查点数据库，同一目录已有会话运行时返回 ErrCheckpointBusy
+func (s *SessionStore) Open(targetDir string) (*CheckpointManager, error) {
+	return NewCheckpointManager(s.logger, s.DBPath(targetDir), s.errorHandler)
+}
+
+// Databases 列出所有检查点数据库
+func (s *SessionStore) Databases() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ForEach 依次打开每个检查点数据库并调用fn，返回正被其他进程占用而跳过的数据库
+func (s *SessionStore) ForEach(fn func(cm *CheckpointManager) error) ([]string, error) {
+	paths, err := s.Databases()
+	if err != nil {
+		return nil, s.errorHandler.WrapError("列出检查点数据库失败", err)
+	}
+
+	var busy []string
+	for _, path := range paths {
+		cm, err := NewCheckpointManager(s.logger, path, s.errorHandler)
+		if errors.Is(err, ErrCheckpointBusy) {
+			busy = append(busy, path)
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("打开检查点数据库失败", zap.String("path", path), zap.Error(err))
+			continue
+		}
+
+		err = fn(cm)
+		if closeErr := cm.Close(); closeErr != nil {
+			s.logger.Warn("关闭检查点数据库失败", zap.String("path", path), zap.Error(closeErr))
+		}
+		if err != nil {
+			return busy, err
+		}
+	}
+	return busy, nil
+}
+
+// List 列出所有会话，按最近更新时间倒序；正在运行的会话排在最前
+func (s *SessionStore) List() ([]*StoredSession, error) {
+	var sessions []*StoredSession
+	busy, err := s.ForEach(func(cm *CheckpointManager) error {
+		infos, err := cm.ListSessions()
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			sessions = append(sessions, &StoredSession{SessionInfo: *info, DBPath: cm.DBPath()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range busy {
+		sessions = append(sessions, &StoredSession{DBPath: path, Running: true})
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].Running != sessions[j].Running {
+			return sessions[i].Running
+		}
+		return sessions[i].LastUpdate.After(sessions[j].LastUpdate)
+	})
+	return sessions, nil
+}
+
+// FindSession 查找会话
+func (s *SessionStore) FindSession(sessionID string) (*StoredSession, error) {
+	var found *StoredSession
+	busy, err := s.ForEach(func(cm *CheckpointManager) error {
+		if found != nil {
+			return nil
+		}
+		info, err := cm.GetSession(sessionID)
+		if err != nil || info == nil {
+			return err
+		}
+		found = &StoredSession{SessionInfo: *info, DBPath: cm.DBPath()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, s.notFound(sessionID, busy)
+	}
+	return found, nil
+}
+
+// OpenJournal 打开包含会话撤销日志的检查点数据库，调用方负责关闭
+func (s *SessionStore) OpenJournal(sessionID string) (*CheckpointManager, error) {
+	var dbPath string
+	busy, err := s.ForEach(func(cm *CheckpointManager) error {
+		if dbPath != "" {
+			return nil
+		}
+		entries, err := cm.ListJournal(sessionID)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			dbPath = cm.DBPath()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if dbPath == "" {
+		return nil, s.notFound(sessionID, busy)
+	}
+	return NewCheckpointManager(s.logger, dbPath, s.errorHandler)
+}
+
+// Export 导出会话的会话信息、文件记录与撤销日志；已完成的会话只导出撤销日志
+func (s *SessionStore) Export(sessionID string) (*SessionExport, error) {
+	var export *SessionExport
+	busy, err := s.ForEach(func(cm *CheckpointManager) error {
+		if export != nil {
+			return nil
+		}
+		info, err := cm.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+		journal, err := cm.ListJournal(sessionID)
+		if err != nil {
+			return err
+		}
+		if info == nil && len(journal) == 0 {
+			return nil
+		}
+
+		files, err := cm.ListFileRecords(sessionID)
+		if err != nil {
+			return err
+		}
+		export = &SessionExport{
+			ExportedAt: time.Now(),
+			DBPath:     cm.DBPath(),
+			Session:    info,
+			Files:      files,
+			Journal:    journal,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if export == nil {
+		return nil, s.notFound(sessionID, busy)
+	}
+	return export, nil
+}
+
+// Prune 清理过期、目标目录已不存在或全部（All）未运行的会话，并删除不再包含任何记录的数据库
+func (s *SessionStore) Prune(opts PruneOptions) ([]*StoredSession, error) {
+	cutoff := time.Now().Add(-opts.OlderThan)
+	var pruned []*StoredSession
+	var emptyDBs []string
+
+	_, err := s.ForEach(func(cm *CheckpointManager) error {
+		infos, err := cm.ListSessions()
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if !opts.All && !info.LastUpdate.Before(cutoff) && targetDirExists(info.TargetDir) {
+				continue
+			}
+			pruned = append(pruned, &StoredSession{SessionInfo: *info, DBPath: cm.DBPath()})
+			if opts.DryRun {
+				continue
+			}
+			if err := cm.CleanupSession(info.SessionID); err != nil {
+				return s.errorHandler.WrapError("清理会话失败", err)
+			}
+		}
+
+		if !opts.DryRun {
+			if empty, err := cm.IsEmpty(); err == nil && empty {
+				emptyDBs = append(emptyDBs, cm.DBPath())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return pruned, err
+	}
+
+	// 数据库关闭后再删除空文件
+	for _, path := range emptyDBs {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("删除空检查点数据库失败", zap.String("path", path), zap.Error(err))
+		}
+	}
+	return pruned, nil
+}
+
+// notFound 会话不存在的错误，有数据库被占用时提示可能正在运行
+func (s *SessionStore) notFound(sessionID string, busy []string) error {
+	if len(busy) > 0 {
+		return fmt.Errorf("会话不存在或正在运行（%d 个检查点数据库被占用）: %s", len(busy), sessionID)
+	}
+	return fmt.Errorf("会话不存在: %s", sessionID)
+}
+
+// targetDirExists 检查会话的目标目录是否仍然存在
+func targetDirExists(dir string) bool {
+	_, err := os.Stat(dir)
+	return !os.IsNotExist(err)
+}
+
+// sanitizeDBName 将目录名转换为安全的数据库文件名前缀
+func sanitizeDBName(name string) string {
+	var builder strings.Builder
+	count := 0
+	for _, r := range name {
+		if count >= 32 {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('_')
+		}
+		count++
+	}
+	if builder.Len() == 0 {
+		return "root"
+	}
+	return builder.String()
+}
@@ -0,0 +1,337 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pixly/pkg/processmonitor"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// newTestSessionStore 在临时状态目录中创建会话存储
+func newTestSessionStore(t *testing.T) *SessionStore {
+	t.Helper()
+	return NewSessionStore(zap.NewNop(), t.TempDir(), NewErrorHandler(zap.NewNop()))
+}
+
+// putSession 直接写入会话记录，用于构造指定更新时间的会话
+func putSession(t *testing.T, cm *CheckpointManager, info SessionInfo) {
+	t.Helper()
+	data, _ := json.Marshal(info)
+	err := cm.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(SessionBucket)).Put([]byte(info.SessionID), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// putBucket 在数据库中写入一个bucket与一条记录
+func putBucket(t *testing.T, cm *CheckpointManager, bucket string) {
+	t.Helper()
+	err := cm.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openStoreDB 打开目标目录的数据库，调用fn后关闭
+func openStoreDB(t *testing.T, store *SessionStore, targetDir string, fn func(cm *CheckpointManager)) {
+	t.Helper()
+	cm, err := store.Open(targetDir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer cm.Close()
+	fn(cm)
+}
+
+func TestSessionStoreDBPath(t *testing.T) {
+	store := newTestSessionStore(t)
+	root := t.TempDir()
+
+	a := store.DBPath(filepath.Join(root, "photos"))
+	if filepath.Dir(a) != store.Dir() {
+		t.Errorf("数据库应位于 %s, 实际 %s", store.Dir(), a)
+	}
+	if !strings.HasPrefix(filepath.Base(a), "photos-") || filepath.Ext(a) != ".db" {
+		t.Errorf("数据库文件名 = %s, 期望 photos-<哈希>.db", filepath.Base(a))
+	}
+	if again := store.DBPath(filepath.Join(root, "photos")); again != a {
+		t.Errorf("同一目录的数据库不一致: %s, %s", a, again)
+	}
+	if other := store.DBPath(filepath.Join(root, "other", "photos")); other == a {
+		t.Error("同名不同路径的目录应使用不同数据库")
+	}
+
+	// 相对路径与绝对路径解析为同一数据库
+	wd, _ := os.Getwd()
+	if rel := store.DBPath("photos"); rel != store.DBPath(filepath.Join(wd, "photos")) {
+		t.Errorf("相对路径的数据库 = %s, 期望与绝对路径一致", rel)
+	}
+}
+
+func TestSanitizeDBName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"普通目录名", "photos_2024-01", "photos_2024-01"},
+		{"空格与标点", "My Photos (old)", "My_Photos__old_"},
+		{"中文", "照片", "照片"},
+		{"根目录", "/", "_"},
+		{"空名称", "", "root"},
+		{"超长截断", strings.Repeat("a", 40), strings.Repeat("a", 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeDBName(tt.in); got != tt.want {
+				t.Errorf("sanitizeDBName(%q) = %q, 期望 %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionStorePerTargetDB(t *testing.T) {
+	store := newTestSessionStore(t)
+	root := t.TempDir()
+	dirA, dirB := filepath.Join(root, "a"), filepath.Join(root, "b")
+
+	// 不同目标目录的会话可同时打开
+	cmA, err := store.Open(dirA)
+	if err != nil {
+		t.Fatalf("Open(a): %v", err)
+	}
+	defer cmA.Close()
+	cmB, err := store.Open(dirB)
+	if err != nil {
+		t.Fatalf("Open(b): %v", err)
+	}
+	defer cmB.Close()
+	if cmA.DBPath() == cmB.DBPath() {
+		t.Fatal("不同目标目录应使用不同数据库")
+	}
+
+	// 同一目标目录已打开时返回ErrCheckpointBusy
+	if _, err := store.Open(dirA); !errors.Is(err, ErrCheckpointBusy) {
+		t.Errorf("重复打开 err = %v, 期望 ErrCheckpointBusy", err)
+	}
+
+	putSession(t, cmA, SessionInfo{SessionID: "a_1", TargetDir: dirA, LastUpdate: time.Now()})
+	putSession(t, cmB, SessionInfo{SessionID: "b_1", TargetDir: dirB, LastUpdate: time.Now()})
+	cmB.Close()
+
+	// 正在运行的数据库在列表中标记为运行中并排在最前
+	sessions, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 2 || !sessions[0].Running || sessions[0].DBPath != cmA.DBPath() || sessions[1].SessionID != "b_1" {
+		t.Errorf("List = %+v, 期望运行中的a在前、已结束的b_1在后", sessions)
+	}
+	cmA.Close()
+
+	sessions, err = store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Running || sessions[1].Running {
+		t.Fatalf("List = %+v, 期望两个已结束的会话", sessions)
+	}
+	found, err := store.FindSession("b_1")
+	if err != nil || found.DBPath != store.DBPath(dirB) || found.TargetDir != dirB {
+		t.Errorf("FindSession = %+v, %v", found, err)
+	}
+	if _, err := store.FindSession("missing"); err == nil {
+		t.Error("不存在的会话应返回错误")
+	}
+}
+
+func TestSessionStoreJournalAndExport(t *testing.T) {
+	store := newTestSessionStore(t)
+	dir := t.TempDir()
+	openStoreDB(t, store, dir, func(cm *CheckpointManager) {
+		if err := cm.StartSession(dir, "auto+", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := cm.UpdateFileStatus(filepath.Join(dir, "a.png"), StatusCompleted, "", filepath.Join(dir, "a.jxl")); err != nil {
+			t.Fatal(err)
+		}
+		cm.RecordJournal(&JournalEntry{SessionID: cm.CurrentSessionID(), SourcePath: filepath.Join(dir, "a.png")})
+		// 会话结束后只剩撤销日志
+		if err := cm.CleanupSession(cm.CurrentSessionID()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	var sessionID string
+	openStoreDB(t, store, dir, func(cm *CheckpointManager) {
+		sessions, _ := cm.ListJournalSessions()
+		if len(sessions) != 1 {
+			t.Fatalf("撤销日志会话 = %d, 期望 1", len(sessions))
+		}
+		sessionID = sessions[0].SessionID
+	})
+
+	cm, err := store.OpenJournal(sessionID)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	if cm.DBPath() != store.DBPath(dir) {
+		t.Errorf("OpenJournal 打开了 %s, 期望 %s", cm.DBPath(), store.DBPath(dir))
+	}
+	cm.Close()
+
+	export, err := store.Export(sessionID)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if export.Session != nil || len(export.Journal) != 1 || len(export.Files) != 0 {
+		t.Errorf("Export = %+v, 期望只有撤销日志", export)
+	}
+	if _, err := store.OpenJournal("missing"); err == nil {
+		t.Error("没有撤销日志的会话应返回错误")
+	}
+}
+
+func TestSessionStorePrune(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+
+	tests := []struct {
+		name       string
+		opts       PruneOptions
+		lastUpdate time.Time
+		targetGone bool
+		extra      string // 会话之外的数据所在bucket
+		wantPruned int
+		wantDB     bool
+	}{
+		{"未过期的会话保留", PruneOptions{OlderThan: 24 * time.Hour}, time.Now(), false, "", 0, true},
+		{"过期会话被清理并删除空数据库", PruneOptions{OlderThan: 24 * time.Hour}, old, false, "", 1, false},
+		{"目标目录已不存在", PruneOptions{OlderThan: 24 * time.Hour}, time.Now(), true, "", 1, false},
+		{"全部清理", PruneOptions{All: true}, time.Now(), false, "", 1, false},
+		{"只列出不删除", PruneOptions{All: true, DryRun: true}, old, false, "", 1, true},
+		{"保留有撤销日志的数据库", PruneOptions{All: true}, old, false, JournalBucket, 1, true},
+		{"保留有输出登记的数据库", PruneOptions{All: true}, old, false, OutputsBucket, 1, true},
+		{"保留有耗时样本的数据库", PruneOptions{All: true}, old, false, processmonitor.TimingBucket, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestSessionStore(t)
+			dir := filepath.Join(t.TempDir(), "photos")
+			if !tt.targetGone {
+				os.MkdirAll(dir, 0755)
+			}
+			openStoreDB(t, store, dir, func(cm *CheckpointManager) {
+				putSession(t, cm, SessionInfo{SessionID: "photos_1", TargetDir: dir, LastUpdate: tt.lastUpdate})
+				if tt.extra != "" {
+					putBucket(t, cm, tt.extra)
+				}
+			})
+
+			pruned, err := store.Prune(tt.opts)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			if len(pruned) != tt.wantPruned {
+				t.Errorf("清理了 %d 个会话, 期望 %d", len(pruned), tt.wantPruned)
+			}
+			_, statErr := os.Stat(store.DBPath(dir))
+			if (statErr == nil) != tt.wantDB {
+				t.Errorf("数据库保留 = %v, 期望 %v", statErr == nil, tt.wantDB)
+			}
+			if !tt.wantDB || tt.opts.DryRun || tt.wantPruned == 0 {
+				return
+			}
+			openStoreDB(t, store, dir, func(cm *CheckpointManager) {
+				if sessions, _ := cm.ListSessions(); len(sessions) != 0 {
+					t.Errorf("会话应被清理，剩余 %d", len(sessions))
+				}
+			})
+		})
+	}
+}
+
+func TestCheckpointIsEmpty(t *testing.T) {
+	for _, bucket := range []string{SessionBucket, FilesBucket, JournalBucket, OutputsBucket, processmonitor.TimingBucket, "other"} {
+		t.Run(bucket, func(t *testing.T) {
+			cm, err := NewCheckpointManager(zap.NewNop(), filepath.Join(t.TempDir(), "c.db"), NewErrorHandler(zap.NewNop()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cm.Close()
+			if empty, err := cm.IsEmpty(); err != nil || !empty {
+				t.Fatalf("新数据库 IsEmpty = %v, %v, 期望 true", empty, err)
+			}
+			putBucket(t, cm, bucket)
+			if empty, err := cm.IsEmpty(); err != nil || empty {
+				t.Errorf("写入 %s 后 IsEmpty = %v, %v, 期望 false", bucket, empty, err)
+			}
+		})
+	}
+}
+
+// newTimingConverter 创建只使用会话存储与耗时模型的转换器
+func newTimingConverter(store *SessionStore) *Converter {
+	return &Converter{
+		logger:       zap.NewNop(),
+		errorHandler: NewErrorHandler(zap.NewNop()),
+		sessionStore: store,
+		timing:       processmonitor.NewTimingModel(zap.NewNop()),
+	}
+}
+
+func TestTimingState(t *testing.T) {
+	store := newTestSessionStore(t)
+	sample := processmonitor.TimingSample{Format: "png", Encoder: "jxl_lossless.jxl", Frames: 1, Effort: 7}
+
+	// 样本写入全局状态数据库，而不是目标目录的检查点数据库
+	first := newTimingConverter(store)
+	first.attachTimingState()
+	if first.stateDB == nil || first.stateDB.Path() != store.StateDBPath() {
+		t.Fatalf("stateDB = %v, 期望打开 %s", first.stateDB, store.StateDBPath())
+	}
+	if filepath.Dir(store.StateDBPath()) == store.Dir() {
+		t.Error("状态数据库不应位于检查点目录")
+	}
+	for i := 1; i <= 10; i++ {
+		sample.Pixels = int64(i) * 1_000_000
+		sample.Duration = time.Duration(i) * time.Second
+		first.timing.Observe(sample)
+	}
+
+	// 其他进程正在使用时只在内存中记录
+	busy := newTimingConverter(store)
+	busy.attachTimingState()
+	if busy.stateDB != nil {
+		t.Error("状态数据库被占用时不应打开")
+	}
+	first.detachTimingState()
+	if first.stateDB != nil {
+		t.Error("detachTimingState 后 stateDB 应为nil")
+	}
+
+	// 之后的运行（可能处理其他目标目录）载入这些样本
+	second := newTimingConverter(store)
+	second.attachTimingState()
+	defer second.detachTimingState()
+	sample.Pixels = 5_000_000
+	prediction, ok := second.timing.Predict(sample)
+	if !ok || prediction.Samples != 10 {
+		t.Errorf("Predict = %+v, %v, 期望使用保存的10个样本", prediction, ok)
+	}
+}
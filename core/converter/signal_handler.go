@@ -72,6 +72,13 @@ func NewSignalHandlerWithConfig(logger *zap.Logger, converter *Converter, checkp
 	}
 }
 
+// setCheckpoint 设置中断时保存状态的检查点管理器（转换开始时按目标目录打开）
+func (sh *SignalHandler) setCheckpoint(checkpoint *CheckpointManager) {
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	sh.checkpoint = checkpoint
+}
+
 // Start 启动信号监听
 func (sh *SignalHandler) Start() {
 	// 注册信号监听
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"pixly/pkg/whitelist"
+)
+
+// ErrUnsupportedFile 文件不是受支持的媒体格式
+var ErrUnsupportedFile = errors.New("不支持的文件类型")
+
+// targetFormatReason 已是目标格式的跳过原因，与扫描阶段一致
+const targetFormatReason = "已经是目标格式"
+
+// PlanFile 对单个文件执行与 Plan 相同的形态检测、品质评估与路由，不转换任何文件
+func (c *Converter) PlanFile(path string) (PlanEntry, error) {
+	file, err := c.statMediaFile(path)
+	if err != nil {
+		return PlanEntry{}, err
+	}
+	if c.isTargetFile(file.Path, file.Extension) {
+		return c.newPlanEntry(file, skipRoute(targetFormatReason)), nil
+	}
+
+	entry := c.planFile(file)
+	c.emitAssessed(file, entry.Route)
+	return entry, nil
+}
+
+// ConvertFile 转换单个文件，不打开检查点会话（没有断点续传与撤销日志）。
+// 返回结果的副本；转换失败记录在结果的 Error 中，只有文件无法读取或不受支持时返回错误。
+func (c *Converter) ConvertFile(path string) (*ConversionResult, error) {
+	return c.ConvertFileContext(c.ctx, path)
+}
+
+// ConvertFileContext 与 ConvertFile 相同，ctx 取消或转换器关闭时终止该文件进行中的编码器进程
+func (c *Converter) ConvertFileContext(ctx context.Context, path string) (*ConversionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := c.statMediaFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 调用方的上下文与转换器的上下文任一结束都终止该文件的处理
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	file.ctx = ctx
+
+	if c.isTargetFile(file.Path, file.Extension) {
+		result := &ConversionResult{
+			OriginalFile:   file,
+			OutputPath:     file.Path,
+			OriginalSize:   file.Size,
+			CompressedSize: file.Size,
+			Success:        true,
+			Skipped:        true,
+			SkipCategory:   whitelist.SkipTargetFormat,
+			SkipReason:     targetFormatReason,
+			Method:         string(ActionSkip),
+		}
+		c.UpdateStats(result)
+		c.emitResult(result)
+		return result, nil
+	}
+
+	// 在归还到内存池之前创建快照
+	result := c.processFile(file)
+	snapshot := *result
+	c.memoryPool.PutConversionResult(result)
+	return &snapshot, nil
+}
+
+// statMediaFile 为单个文件创建任务，非媒体文件返回 ErrUnsupportedFile
+func (c *Converter) statMediaFile(path string) (*MediaFile, error) {
+	if normalized, err := GlobalPathUtils.NormalizePath(path); err == nil {
+		path = normalized
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, c.errorHandler.WrapError("读取文件信息失败", err)
+	}
+	// 创作源文件同样接受，由处理流程记录为跳过
+	if info.IsDir() || (!c.isMediaFile(path) && whitelist.CreativeByExtension(path) == nil) {
+		return nil, ErrUnsupportedFile
+	}
+	return c.newMediaFile(path, info), nil
+}
+
+// newMediaFile 根据文件信息创建任务
+func (c *Converter) newMediaFile(path string, info os.FileInfo) *MediaFile {
+	ext := strings.ToLower(GlobalPathUtils.GetExtension(path))
+	return &MediaFile{
+		Path:      path,
+		Name:      info.Name(),
+		Size:      info.Size(),
+		Extension: ext,
+		ModTime:   info.ModTime(),
+		Type:      c.GetFileType(ext),
+	}
+}
@@ -1,13 +1,18 @@
 package converter
 
 import (
-	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"pixly/pkg/jpegquality"
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/perceptual"
+	"pixly/pkg/qualitysearch"
+
 	"go.uber.org/zap"
 )
 
@@ -15,6 +20,9 @@ import (
 type ConversionStrategy interface {
 	ConvertImage(file *MediaFile) (string, error)
 	ConvertVideo(file *MediaFile) (string, error)
+	// RouteImage/RouteVideo 只做决策不写文件，供转换与计划模式共用
+	RouteImage(file *MediaFile) Route
+	RouteVideo(file *MediaFile) Route
 	GetName() string
 }
 
@@ -57,48 +65,75 @@ func (s *AutoPlusStrategy) GetName() string {
 }
 
 func (s *AutoPlusStrategy) ConvertImage(file *MediaFile) (string, error) {
-	// 0. 优先检测无损JPEG/PNG - 新增功能
+	return s.converter.executeRoute(file, s.RouteImage(file))
+}
+
+func (s *AutoPlusStrategy) ConvertVideo(file *MediaFile) (string, error) {
+	return s.converter.executeRoute(file, s.RouteVideo(file))
+}
+
+// RouteImage 智能决策：无损或高品质源路由至品质模式的无损逻辑，其余应用平衡优化
+func (s *AutoPlusStrategy) RouteImage(file *MediaFile) Route {
+	// 带增益图的HDR照片：保留增益图或跳过
+	if route, ok := s.converter.gainMapRoute(file, false); ok {
+		return route
+	}
+
+	// 0. 优先检测无损JPEG/PNG
 	if s.isLosslessFormat(file) {
-		// 检测到无损格式，优先使用质量模式
-		return s.applyQualityModeLogic(file)
+		route := s.qualityModeRoute(file)
+		route.Reason = "检测到无损源，" + route.Reason
+		return route
 	}
 
 	// 1. 品质分类体系
 	quality := s.analyzeImageQuality(file)
+	label := quality
+	if metrics := file.metrics; metrics != nil && metrics.JPEG != nil {
+		label += fmt.Sprintf("（JPEG品质Q%d）", metrics.JPEG.Quality)
+	}
 
 	switch quality {
 	case "极高", "高品质", "原画":
 		// 路由至品质模式的无损压缩逻辑
-		return s.applyQualityModeLogic(file)
-
-	case "中高", "中低", "中等":
-		// 平衡优化逻辑
-		return s.applyBalancedOptimization(file)
-
-	case "极低", "低品质":
-		// 根据最新README规范，移除低质量文件跳过功能
-		// 直接应用平衡优化逻辑
-		return s.applyBalancedOptimization(file)
+		route := s.qualityModeRoute(file)
+		route.Reason = "品质等级" + label + "，" + route.Reason
+		return route
 
 	default:
-		return s.applyBalancedOptimization(file)
+		// 根据最新README规范，移除低质量文件跳过功能
+		// 中等及以下品质统一应用平衡优化逻辑
+		return s.balancedRoute(file, label)
 	}
 }
 
-func (s *AutoPlusStrategy) ConvertVideo(file *MediaFile) (string, error) {
-	// 视频转换逻辑，主要是容器转换
-	return s.converter.convertVideoContainer(file)
+// RouteVideo 视频转换逻辑：默认容器转换，配置为transcode时重新编码
+func (s *AutoPlusStrategy) RouteVideo(file *MediaFile) Route {
+	if s.converter.config.Conversion.Video.Mode == "transcode" {
+		return s.converter.videoTranscodeRoute(file)
+	}
+	return Route{
+		Strategy:  RouteStrategyVideo,
+		Action:    ActionVideoContainer,
+		TargetExt: ".mov",
+		Reason:    "视频容器转换为MOV（不兼容编码将跳过）",
+	}
 }
 
 // ConvertAudio方法已删除 - 根据README要求，本程序不处理音频文件
 
+// imageMetricsVersion 图像度量的算法版本，变更分析逻辑时递增以使缓存的度量失效
+const imageMetricsVersion = 1
+
 // ImageQualityMetrics 图像质量度量
 type ImageQualityMetrics struct {
-	Complexity           float64 // 图像复杂度 (0-1)
-	NoiseLevel           float64 // 噪声水平 (0-1)
-	CompressionPotential float64 // 压缩潜力 (0-1)
-	ContentType          string  // 内容类型: photo, graphic, mixed
-	QualityScore         float64 // 综合质量分数 (0-100)
+	Complexity           float64           // 图像复杂度 (0-1)
+	NoiseLevel           float64           // 噪声水平 (0-1)
+	CompressionPotential float64           // 压缩潜力 (0-1)
+	ContentType          string            // 内容类型: photo, graphic, mixed
+	QualityScore         float64           // 综合质量分数 (0-100)
+	JPEG                 *jpegquality.Info // JPEG编码参数（量化表解析结果），非JPEG或解析失败时为nil
+	Version              int               // 度量算法版本
 }
 
 // analyzeImageQuality 智能图像质量分析
@@ -149,8 +184,13 @@ func (s *AutoPlusStrategy) isLosslessFormat(file *MediaFile) bool {
 	return false
 }
 
-// analyzeImageMetrics 分析图像度量指标
+// analyzeImageMetrics 分析图像度量指标（结果随文件缓存，避免重复探测）
 func (s *AutoPlusStrategy) analyzeImageMetrics(file *MediaFile) ImageQualityMetrics {
+	s.converter.loadCacheEntry(file)
+	if file.metrics != nil && file.metrics.Version == imageMetricsVersion {
+		return *file.metrics
+	}
+
 	ext := strings.ToLower(file.Extension)
 	sizeInMB := float64(file.Size) / (1024 * 1024)
 
@@ -177,94 +217,48 @@ func (s *AutoPlusStrategy) analyzeImageMetrics(file *MediaFile) ImageQualityMetr
 		metrics = s.analyzeGenericQuality(sizeInMB)
 	}
 
+	metrics.Version = imageMetricsVersion
+	file.metrics = &metrics
 	return metrics
 }
 
 // getImageDimensions 获取图像尺寸
 func (s *AutoPlusStrategy) getImageDimensions(file *MediaFile) (int, int) {
-	// 通过FFprobe获取图像尺寸
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		file.Path,
-	}
-
-	cmd := exec.Command(s.converter.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
-	if err != nil {
-		// 如果FFprobe失败，返回默认值
-		return 1920, 1080
-	}
-
-	// 解析FFprobe输出
-	var probeData struct {
-		Streams []struct {
-			Width  int `json:"width"`
-			Height int `json:"height"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil || len(probeData.Streams) == 0 {
-		return 1920, 1080
-	}
-
-	// 安全访问第一个流的尺寸信息
-	if len(probeData.Streams) == 0 {
-		s.converter.logger.Warn("No streams found for dimension analysis, using default dimensions",
-			zap.String("file", file.Path))
+	// 复用共享探测结果：优先容器头部，无法解析时使用FFprobe
+	media, err := s.converter.prober.Get(file.Path).Media(s.converter.ctx)
+	if err != nil || media.Width == 0 || media.Height == 0 {
+		// 如果探测失败，返回默认值
 		return 1920, 1080
 	}
-	return probeData.Streams[0].Width, probeData.Streams[0].Height
+	return media.Width, media.Height
 }
 
-// analyzeJPEGQuality 分析JPEG质量
-func (s *AutoPlusStrategy) analyzeJPEGQuality(file *MediaFile, pixelDensity, sizeInMB float64) ImageQualityMetrics {
-	var metrics ImageQualityMetrics
-	metrics.ContentType = "photo"
-
-	// 使用FFprobe获取JPEG详细信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		"-show_format",
-		file.Path,
-	}
-
-	cmd := exec.Command(s.converter.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
+// probeStream 返回文件共享FFprobe结果中的第一个视频流，探测失败或没有视频流时为nil
+func (s *AutoPlusStrategy) probeStream(file *MediaFile) *mediaprobe.Stream {
+	data, err := s.converter.prober.Get(file.Path).FFprobe(s.converter.ctx)
 	if err != nil {
-		// 回退到基础分析
-		return s.fallbackJPEGAnalysis(pixelDensity, sizeInMB)
+		return nil
 	}
+	return data.VideoStream()
+}
 
-	// 解析FFprobe输出
-	var probeData struct {
-		Streams []struct {
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			PixFmt     string `json:"pix_fmt"`
-			BitRate    string `json:"bit_rate"`
-			ColorSpace string `json:"color_space"`
-		} `json:"streams"`
-		Format struct {
-			BitRate string `json:"bit_rate"`
-			Size    string `json:"size"`
-		} `json:"format"`
+// analyzeJPEGQuality 分析JPEG质量：优先解析量化表得到编码品质，解析失败时回退到FFprobe像素格式分析
+func (s *AutoPlusStrategy) analyzeJPEGQuality(file *MediaFile, pixelDensity, sizeInMB float64) ImageQualityMetrics {
+	info, err := jpegquality.AnalyzeFile(file.Path)
+	if err == nil {
+		return jpegEncoderMetrics(info)
 	}
+	s.converter.logger.Debug("JPEG量化表解析失败，使用FFprobe分析",
+		zap.String("file", file.Path), zap.Error(err))
 
-	if err := json.Unmarshal(output, &probeData); err != nil || len(probeData.Streams) == 0 {
-		return s.fallbackJPEGAnalysis(pixelDensity, sizeInMB)
-	}
+	var metrics ImageQualityMetrics
+	metrics.ContentType = "photo"
 
-	// 安全访问第一个流
-	if len(probeData.Streams) == 0 {
-		s.converter.logger.Warn("No streams found in probe data, using fallback analysis",
-			zap.String("file", file.Path))
+	// 使用共享的FFprobe结果
+	stream := s.probeStream(file)
+	if stream == nil {
 		return s.fallbackJPEGAnalysis(pixelDensity, sizeInMB)
 	}
-	stream := probeData.Streams[0]
 
 	// 基于像素格式分析质量
 	switch stream.PixFmt {
@@ -317,6 +311,50 @@ func (s *AutoPlusStrategy) analyzeJPEGQuality(file *MediaFile, pixelDensity, siz
 	return metrics
 }
 
+// jpegEncoderMetrics 根据编码参数计算JPEG度量：以IJG等效品质为基础，
+// 色度下采样与重新编码各扣减少量分数（细节已在之前的编码中丢失）
+func jpegEncoderMetrics(info *jpegquality.Info) ImageQualityMetrics {
+	metrics := ImageQualityMetrics{ContentType: "photo", JPEG: info}
+
+	score := float64(info.Quality)
+	if info.Lossless {
+		score = 100
+	}
+	switch info.Subsampling {
+	case "4:2:0", "4:1:1":
+		score -= 3
+	}
+	if info.Resaved {
+		score -= 5
+	}
+	metrics.QualityScore = math.Max(1, math.Min(100, score))
+
+	switch info.Subsampling {
+	case "4:4:4":
+		metrics.Complexity = 0.9
+	case "4:2:2":
+		metrics.Complexity = 0.7
+	case "4:2:0":
+		metrics.Complexity = 0.6
+	default:
+		metrics.Complexity = 0.5
+	}
+
+	if !info.Lossless {
+		metrics.NoiseLevel = (100 - metrics.QualityScore) / 200.0
+	}
+
+	// 与FFprobe分析相同的压缩潜力分级
+	if metrics.QualityScore > 80 {
+		metrics.CompressionPotential = 0.2
+	} else if metrics.QualityScore > 60 {
+		metrics.CompressionPotential = 0.4
+	} else {
+		metrics.CompressionPotential = 0.6
+	}
+	return metrics
+}
+
 // fallbackJPEGAnalysis JPEG分析回退方案
 func (s *AutoPlusStrategy) fallbackJPEGAnalysis(pixelDensity, sizeInMB float64) ImageQualityMetrics {
 	var metrics ImageQualityMetrics
@@ -352,42 +390,11 @@ func (s *AutoPlusStrategy) analyzePNGQuality(file *MediaFile, pixelDensity float
 	metrics.ContentType = "graphic"
 	metrics.NoiseLevel = 0.0 // PNG无损格式无噪声
 
-	// 使用FFprobe获取PNG详细信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		file.Path,
-	}
-
-	cmd := exec.Command(s.converter.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return s.fallbackPNGAnalysis(pixelDensity)
-	}
-
-	// 解析FFprobe输出
-	var probeData struct {
-		Streams []struct {
-			Width            int    `json:"width"`
-			Height           int    `json:"height"`
-			PixFmt           string `json:"pix_fmt"`
-			BitsPerRawSample string `json:"bits_per_raw_sample"`
-			ColorSpace       string `json:"color_space"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil || len(probeData.Streams) == 0 {
-		return s.fallbackPNGAnalysis(pixelDensity)
-	}
-
-	// 安全访问第一个流
-	if len(probeData.Streams) == 0 {
-		s.converter.logger.Warn("No streams found in PNG probe data, using fallback analysis",
-			zap.String("file", file.Path))
+	// 使用共享的FFprobe结果
+	stream := s.probeStream(file)
+	if stream == nil {
 		return s.fallbackPNGAnalysis(pixelDensity)
 	}
-	stream := probeData.Streams[0]
 
 	// 基于像素格式和位深度分析质量
 	switch stream.PixFmt {
@@ -491,43 +498,12 @@ func (s *AutoPlusStrategy) analyzeWebPQuality(file *MediaFile, pixelDensity, siz
 	var metrics ImageQualityMetrics
 	metrics.ContentType = "mixed"
 
-	// 使用FFprobe获取WebP详细信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		file.Path,
-	}
-
-	cmd := exec.Command(s.converter.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return s.fallbackWebPAnalysis(pixelDensity)
-	}
-
-	// 解析FFprobe输出
-	var probeData struct {
-		Streams []struct {
-			Width      int    `json:"width"`
-			Height     int    `json:"height"`
-			PixFmt     string `json:"pix_fmt"`
-			CodecName  string `json:"codec_name"`
-			ColorSpace string `json:"color_space"`
-		} `json:"streams"`
-	}
-
-	if err := json.Unmarshal(output, &probeData); err != nil || len(probeData.Streams) == 0 {
+	// 使用共享的FFprobe结果
+	stream := s.probeStream(file)
+	if stream == nil {
 		return s.fallbackWebPAnalysis(pixelDensity)
 	}
 
-	// 安全访问第一个流
-	if len(probeData.Streams) == 0 {
-		s.converter.logger.Warn("No streams found in WebP probe data, using fallback analysis",
-			zap.String("file", file.Path))
-		return s.fallbackWebPAnalysis(pixelDensity)
-	}
-	stream := probeData.Streams[0]
-
 	// 基于像素格式判断质量
 	switch stream.PixFmt {
 	case "yuv420p", "yuv422p", "yuv444p":
@@ -629,20 +605,43 @@ func (s *AutoPlusStrategy) analyzeGenericQuality(sizeInMB float64) ImageQualityM
 	return metrics
 }
 
-// applyQualityModeLogic 应用品质模式逻辑
-func (s *AutoPlusStrategy) applyQualityModeLogic(file *MediaFile) (string, error) {
-	// 保存原始模式
-	originalMode := s.converter.mode
+// qualityModeRoute 应用品质模式路由
+func (s *AutoPlusStrategy) qualityModeRoute(file *MediaFile) Route {
+	qualityStrategy := &QualityStrategy{converter: s.converter, errorHandler: s.errorHandler}
+	return qualityStrategy.RouteImage(file)
+}
 
-	// 临时切换到品质模式
-	s.converter.mode = ModeQuality
-	defer func() {
-		// 恢复原始模式
-		s.converter.mode = originalMode
-	}()
+// balancedRoute 平衡优化路由：目标格式按第一个可行步骤预测（无损重包装或数学无损）
+// 与attemptMathematicalLossless中原样返回的情形一致的文件直接跳过
+func (s *AutoPlusStrategy) balancedRoute(file *MediaFile, quality string) Route {
+	route := Route{Strategy: RouteStrategyBalanced, Action: ActionBalanced}
+	prefix := "品质等级" + quality + "，平衡优化："
+
+	switch ext := strings.ToLower(file.Extension); ext {
+	case ".jpg", ".jpeg", ".png":
+		route.TargetExt = ".jxl"
+		route.Reason = prefix + "无损重包装为JXL"
+		return route
+	case ".avif":
+		return skipRoute("AVIF已是目标格式")
+	case ".heif", ".heic":
+		route.TargetExt = ".jxl"
+		route.Reason = prefix + "数学无损转换为JXL"
+		return route
+	case ".jxl":
+		if !s.converter.isAnimated(file.Path) {
+			return skipRoute("静态JXL已是目标格式")
+		}
+	}
 
-	qualityStrategy := &QualityStrategy{converter: s.converter}
-	return qualityStrategy.ConvertImage(file)
+	if s.converter.isAnimated(file.Path) {
+		route.TargetExt = ".avif"
+		route.Reason = prefix + "动图数学无损转换为AVIF"
+	} else {
+		route.TargetExt = ".jxl"
+		route.Reason = prefix + "数学无损转换为JXL"
+	}
+	return route
 }
 
 // ProbeResult 探测结果结构体
@@ -650,6 +649,7 @@ type ProbeResult struct {
 	Path    string
 	Quality int
 	Size    int64
+	Score   *perceptual.Score // 感知质量评分，评分不可用时为nil
 }
 
 // applyBalancedOptimization 平衡优化算法（严格按照README规范实现）
@@ -670,27 +670,19 @@ func (s *AutoPlusStrategy) applyBalancedOptimization(file *MediaFile) (string, e
 		return mathLosslessResult, nil
 	}
 
-	// 步骤 3: 有损探测
-	probeResults := []ProbeResult{}
+	// 步骤 3: 有损探测 - 在质量区间内二分搜索满足目标的最便宜参数
 	fileQuality := s.getFileQuality(file)
 
-	var qualityTargets []int
+	minQuality, maxQuality := 30, 70
 	if fileQuality > 70 {
-		qualityTargets = []int{90, 85, 75}
-	} else {
-		qualityTargets = []int{60, 55}
-	}
-
-	for _, quality := range qualityTargets {
-		result, err := s.attemptLossyCompression(file, quality)
-		if err == nil && result != file.Path { // 避免把原文件路径当作探测结果
-			probeResults = append(probeResults, ProbeResult{
-				Path:    result,
-				Quality: quality,
-				Size:    s.getFileSize(result),
-			})
-			// 平衡优化：有损探测成功
-		}
+		minQuality, maxQuality = 55, 95
+	}
+
+	probeResults := []ProbeResult{}
+	if probe := s.converter.searchLossyQuality(file, minQuality, maxQuality, func(quality int) (string, error) {
+		return s.attemptLossyCompression(file, quality)
+	}); probe != nil {
+		probeResults = append(probeResults, *probe)
 	}
 
 	// 步骤 4: 最终决策
@@ -710,47 +702,17 @@ func (s *AutoPlusStrategy) applyBalancedOptimization(file *MediaFile) (string, e
 			targetExt = ".jxl"
 		}
 
-		finalOutputPath := s.converter.getOutputPath(file, targetExt)
-
-		// 确保输出目录存在（统一走文件操作助手）
-		if err := s.converter.fileOpHandler.EnsureOutputDirectory(finalOutputPath); err != nil {
-			s.converter.logger.Error("平衡优化：创建输出目录失败",
-				zap.String("dir", filepath.Dir(finalOutputPath)),
-				zap.Error(err))
+		finalOutputPath, err := s.converter.promoteProbeResult(file, bestResult, targetExt)
+		if err != nil {
 			// 清理所有临时文件
 			for _, result := range probeResults {
 				os.Remove(result.Path)
 			}
-			return "", s.errorHandler.WrapError("failed to create output directory", err)
-		}
-
-		// 将最佳临时文件移动到最终输出路径（原地使用原子替换，非原地直接重命名）
-		isInPlace := s.converter.config.Output.DirectoryTemplate == ""
-		if isInPlace {
-			if err := s.converter.fileOpHandler.AtomicFileReplace(bestResult.Path, finalOutputPath, true); err != nil {
-				s.converter.logger.Error("平衡优化：原子替换失败",
-					zap.String("from", bestResult.Path),
-					zap.String("to", finalOutputPath),
-					zap.Error(err))
-				for _, result := range probeResults {
-					os.Remove(result.Path)
-				}
-				return "", s.errorHandler.WrapError("failed to atomically replace best result to final path", err)
-			}
-		} else {
-			if err := os.Rename(bestResult.Path, finalOutputPath); err != nil {
-				s.converter.logger.Error("平衡优化：文件移动失败",
-					zap.String("from", bestResult.Path),
-					zap.String("to", finalOutputPath),
-					zap.Error(err))
-				for _, result := range probeResults {
-					os.Remove(result.Path)
-				}
-				return "", s.errorHandler.WrapError("failed to move best result to final path", err)
-			}
+			return "", err
 		}
 
 		// 平衡优化：选择最佳结果
+		file.recordQualityScore(bestResult.Score)
 
 		// 清理其他探测结果的临时文件
 		for _, result := range probeResults {
@@ -871,16 +833,8 @@ func (s *AutoPlusStrategy) attemptMathematicalLossless(file *MediaFile) (string,
 			return s.converter.convertToJXLMathematicalLossless(file)
 		}
 	case ".heif", ".heic":
-		// HEIF/HEIC Live Photo检测：Live Photo跳过，静态图转JXL
-		detector := NewFileTypeDetector(s.converter.config, s.converter.logger, s.converter.toolManager)
-		details, err := detector.DetectFileType(file.Path)
-		if err == nil && details.FileType == FileTypeLivePhoto {
-			// Auto+模式：Live Photo跳过处理
-			return file.Path, nil
-		} else {
-			// Auto+模式：转换静态HEIF/HEIC为JXL (数学无损)
-			return s.converter.convertToJXLMathematicalLossless(file)
-		}
+		// Auto+模式：转换静态HEIF/HEIC为JXL (数学无损)；Live Photo由配对转换保留关联标识
+		return s.converter.convertToJXLMathematicalLossless(file)
 	default:
 		// 其他格式检测动静图：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
@@ -906,79 +860,47 @@ func (s *AutoPlusStrategy) getFileQuality(file *MediaFile) int {
 	return qualityScore
 }
 
-// attemptLossyCompression 尝试有损压缩
 // attemptLossyCompression 尝试有损压缩
 func (s *AutoPlusStrategy) attemptLossyCompression(file *MediaFile, quality int) (string, error) {
 	ext := strings.ToLower(file.Extension)
 
-	// 为探测阶段创建带质量后缀的临时输入副本路径（通过硬链接/符号链接，无需拷贝）
-	dir := filepath.Dir(file.Path)
-	base := filepath.Base(file.Path)
-	extName := filepath.Ext(base)
-	name := strings.TrimSuffix(base, extName)
-	probeBase := fmt.Sprintf("%s._probe_q%d%s", name, quality, extName)
-	probePath := filepath.Join(dir, probeBase)
-
-	// 预清理同名残留
-	_ = s.converter.fileOpHandler.SafeRemoveFile(probePath)
-
-	// 优先创建硬链接，失败则退回符号链接
-	linkCreated := false
-	if err := os.Link(file.Path, probePath); err == nil {
-		linkCreated = true
-	} else if err := os.Symlink(file.Path, probePath); err == nil {
-		linkCreated = true
-	} else {
-		s.converter.logger.Warn("创建探测链接失败，将回退为直接使用源文件（可能导致输出命名冲突）",
-			zap.String("source", file.Path),
-			zap.String("probe", probePath))
-	}
-
-	if linkCreated {
-		defer func() {
-			if err := os.Remove(probePath); err != nil {
-				s.converter.logger.Debug("清理探测链接失败", zap.String("probe", probePath), zap.Error(err))
-			}
-		}()
-	}
-
-	probeFile := *file
-	if linkCreated {
-		probeFile.Path = probePath
-	} else {
-		// 链接创建失败时，保持原路径，输出将可能与其他探测产出冲突（极少数情况）
-		probeFile.Path = file.Path
-	}
+	// 为探测阶段创建带质量后缀的输入链接，输出将落在带_probe后缀的独立文件中
+	return s.converter.withProbeLink(file, fmt.Sprintf("q%d", quality), func(probeFile *MediaFile) (string, error) {
+		return s.encodeLossy(file, probeFile, ext, quality)
+	})
+}
 
+// encodeLossy 根据源格式选择有损压缩方法并对探测文件编码
+func (s *AutoPlusStrategy) encodeLossy(file, probeFile *MediaFile, ext string, quality int) (string, error) {
 	// 根据格式选择最佳有损压缩方法（输出将落在带_probe后缀的独立文件中）
 	switch ext {
 	case ".jpg", ".jpeg":
 		// 使用AVIF进行有损压缩
-		return s.converter.convertToAVIF(&probeFile, quality)
+		return s.converter.convertToAVIF(probeFile, quality)
 	case ".png":
 		// PNG使用JXL进行有损压缩（JXL支持透明度且效率更优）
-		return s.converter.convertToJXL(&probeFile, quality)
+		return s.converter.convertToJXL(probeFile, quality)
 	case ".webp":
 		// WebP动静图检测：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
 			// 动图 -> AVIF（有损）
 			// WebP动图有损压缩为AVIF
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// 静图 -> JXL（有损）
 			// WebP静图有损压缩为JXL
-			return s.converter.convertToJXL(&probeFile, quality)
+			return s.converter.convertToJXL(probeFile, quality)
 		}
 	case ".gif":
 		// GIF动图检测：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
 			// 动图 -> AVIF（有损）
 			// GIF动图有损压缩为AVIF
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// 静图 -> JXL（有损）
 			// GIF静图有损压缩为JXL
-			return s.converter.convertToJXL(&probeFile, quality)
+			return s.converter.convertToJXL(probeFile, quality)
 		}
 	case ".avif":
 		// AVIF已是目标格式，跳过转换
@@ -987,7 +909,7 @@ func (s *AutoPlusStrategy) attemptLossyCompression(file *MediaFile, quality int)
 		// JXL动静图检测：动图转AVIF，静图保持JXL
 		if s.converter.isAnimated(file.Path) {
 			// Auto+模式：转换动态JXL为AVIF (有损)
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// Auto+模式：静态JXL已是目标格式，跳过转换
 			return file.Path, nil
@@ -996,39 +918,31 @@ func (s *AutoPlusStrategy) attemptLossyCompression(file *MediaFile, quality int)
 		// APNG动静图检测：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
 			// Auto+模式：转换动态APNG为AVIF (有损)
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// Auto+模式：转换静态APNG为JXL (有损)
-			return s.converter.convertToJXL(&probeFile, quality)
+			return s.converter.convertToJXL(probeFile, quality)
 		}
 	case ".tiff", ".tif":
 		// TIFF动静图检测：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
 			// Auto+模式：转换动态TIFF为AVIF (有损)
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// Auto+模式：转换静态TIFF为JXL (有损)
-			return s.converter.convertToJXL(&probeFile, quality)
+			return s.converter.convertToJXL(probeFile, quality)
 		}
 	case ".heif", ".heic":
-		// HEIF/HEIC Live Photo检测：Live Photo跳过，静态图转JXL
-		detector := NewFileTypeDetector(s.converter.config, s.converter.logger, s.converter.toolManager)
-		details, err := detector.DetectFileType(file.Path)
-		if err == nil && details.FileType == FileTypeLivePhoto {
-			// Auto+模式：Live Photo跳过处理
-			return file.Path, nil
-		} else {
-			// Auto+模式：转换静态HEIF/HEIC为JXL (有损)
-			return s.converter.convertToJXL(&probeFile, quality)
-		}
+		// Auto+模式：转换静态HEIF/HEIC为JXL (有损)
+		return s.converter.convertToJXL(probeFile, quality)
 	default:
 		// 其他格式检测动静图：动图转AVIF，静图转JXL
 		if s.converter.isAnimated(file.Path) {
 			// Auto+模式：转换其他动态格式为AVIF (有损)
-			return s.converter.convertToAVIF(&probeFile, quality)
+			return s.converter.convertToAVIF(probeFile, quality)
 		} else {
 			// Auto+模式：转换其他静态格式为JXL (有损)
-			return s.converter.convertToJXL(&probeFile, quality)
+			return s.converter.convertToJXL(probeFile, quality)
 		}
 	}
 }
@@ -1097,11 +1011,16 @@ func (s *AutoPlusStrategy) selectBestProbeResult(results []ProbeResult, original
 		}
 
 		// 计算综合评分：平衡质量和压缩比
-		// 评分 = 压缩比权重 * 压缩比 + 质量权重 * (质量/100)
+		// 评分 = 压缩比权重 * 压缩比 + 质量权重 * 质量
+		// 有感知评分时使用实测质量，否则退回编码参数
 		compressionWeight := 0.7
 		qualityWeight := 0.3
 
-		score := compressionWeight*reductionRatio + qualityWeight*(float64(result.Quality)/100.0)
+		quality := float64(result.Quality) / 100.0
+		if result.Score != nil {
+			quality = result.Score.Normalized()
+		}
+		score := compressionWeight*reductionRatio + qualityWeight*quality
 
 		if score > bestScore {
 			bestScore = score
@@ -1125,125 +1044,47 @@ func (s *QualityStrategy) GetName() string {
 }
 
 func (s *QualityStrategy) ConvertImage(file *MediaFile) (string, error) {
-	s.converter.logger.Debug("品质模式图片转换开始", zap.String("file", file.Path), zap.String("extension", file.Extension))
+	return s.converter.executeRoute(file, s.RouteImage(file))
+}
+
+func (s *QualityStrategy) ConvertVideo(file *MediaFile) (string, error) {
+	return s.converter.executeRoute(file, s.RouteVideo(file))
+}
+
+// RouteImage 强制转换为目标格式，采用数学无损压缩
+// 目标格式: 静图: JXL, 动图: AVIF (无损), 视频: MOV (仅重包装)
+func (s *QualityStrategy) RouteImage(file *MediaFile) Route {
 	ext := strings.ToLower(file.Extension)
 
 	// 检查是否已经是目标格式
 	if s.converter.IsTargetFormat(ext) {
-		s.converter.logger.Debug("文件已是目标格式，跳过转换", zap.String("file", file.Path))
-		// 品质模式：文件已是目标格式，跳过转换
-		return file.Path, nil
+		return skipRoute("已是目标格式")
+	}
+
+	// 带增益图的HDR照片：无损目标格式无法保留增益图，跳过
+	if route, ok := s.converter.gainMapRoute(file, true); ok {
+		return route
 	}
 
-	// 强制转换为目标格式，采用数学无损压缩
-	// 目标格式: 静图: JXL, 动图: AVIF (无损), 视频: MOV (仅重包装)
 	switch ext {
 	case ".jpg", ".jpeg":
 		// JPEG必须使用cjxl的lossless_jpeg=1参数
-		s.converter.logger.Debug("开始转换JPEG到JXL", zap.String("file", file.Path))
-		return s.converter.convertToJXLLossless(file)
-	case ".png":
-		// PNG动静图检测：动图转AVIF，静图转JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("PNG是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动态PNG为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100) // 动图AVIF无损
-		} else {
-			s.converter.logger.Debug("PNG是静图，转换为JXL", zap.String("file", file.Path))
-			// PNG无损转换为JXL（JXL完全支持透明度且压缩效率更优）
-			// 品质模式：转换静态PNG为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
-	case ".gif":
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("GIF是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动图为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100) // 动图AVIF无损
-		} else {
-			s.converter.logger.Debug("GIF是静图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换静态GIF为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
-	case ".webp":
-		// WebP动静图检测：动图转AVIF，静图转JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("WebP是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动态WebP为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100) // 动图AVIF无损
-		} else {
-			s.converter.logger.Debug("WebP是静图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换静态WebP为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
-	case ".avif":
-		// AVIF已是目标格式，跳过转换
-		s.converter.logger.Debug("AVIF已是目标格式，跳过转换", zap.String("file", file.Path))
-		return file.Path, nil
-	case ".jxl":
-		// JXL动静图检测：动图转AVIF，静图保持JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("JXL是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动态JXL为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100)
-		} else {
-			s.converter.logger.Debug("JXL是静图，已是目标格式，跳过转换", zap.String("file", file.Path))
-			// 品质模式：静态JXL已是目标格式，跳过转换
-			return file.Path, nil
-		}
-	case ".apng":
-		// APNG动静图检测：动图转AVIF，静图转JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("APNG是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动态APNG为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100)
-		} else {
-			s.converter.logger.Debug("APNG是静图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换静态APNG为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
-	case ".tiff", ".tif":
-		// TIFF动静图检测：动图转AVIF，静图转JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("TIFF是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换动态TIFF为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100)
-		} else {
-			s.converter.logger.Debug("TIFF是静图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换静态TIFF为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
+		return Route{Strategy: RouteStrategyQuality, Action: ActionJXLLossless, TargetExt: ".jxl", Reason: "JPEG无损转码为JXL"}
 	case ".heif", ".heic":
-		// HEIF/HEIC Live Photo检测：Live Photo跳过，静态图转JXL
-		s.converter.logger.Debug("处理HEIF/HEIC文件", zap.String("file", file.Path))
-		detector := NewFileTypeDetector(s.converter.config, s.converter.logger, s.converter.toolManager)
-		details, err := detector.DetectFileType(file.Path)
-		if err == nil && details.FileType == FileTypeLivePhoto {
-			s.converter.logger.Debug("HEIF/HEIC是Live Photo，跳过处理", zap.String("file", file.Path))
-			// 品质模式：Live Photo跳过处理
-			return file.Path, nil
-		} else {
-			s.converter.logger.Debug("HEIF/HEIC是静态图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换静态HEIF/HEIC为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
-	default:
-		// 其他格式检测动静图：动图转AVIF，静图转JXL
-		if s.converter.isAnimated(file.Path) {
-			s.converter.logger.Debug("其他格式是动图，转换为AVIF", zap.String("file", file.Path))
-			// 品质模式：转换其他动态格式为AVIF (无损)
-			return s.converter.convertToAVIF(file, 100)
-		} else {
-			s.converter.logger.Debug("其他格式是静图，转换为JXL", zap.String("file", file.Path))
-			// 品质模式：转换其他静态格式为JXL (无损)
-			return s.converter.convertToJXLLossless(file)
-		}
+		// Live Photo静态图同样转换，配对标识由配对转换保留
+		return Route{Strategy: RouteStrategyQuality, Action: ActionJXLLossless, TargetExt: ".jxl", Reason: "HEIF/HEIC静图无损转换为JXL"}
+	}
+
+	// 其他格式检测动静图：动图转AVIF，静图转JXL（JXL完全支持透明度且压缩效率更优）
+	if s.converter.isAnimated(file.Path) {
+		return Route{Strategy: RouteStrategyQuality, Action: ActionAVIFLossless, TargetExt: ".avif", Reason: "动图无损转换为AVIF"}
 	}
+	return Route{Strategy: RouteStrategyQuality, Action: ActionJXLLossless, TargetExt: ".jxl", Reason: "静图无损转换为JXL"}
 }
 
-func (s *QualityStrategy) ConvertVideo(file *MediaFile) (string, error) {
-	// 视频重包装为MOV
-	// 品质模式：视频重包装为MOV
-	return s.converter.convertToMOV(file)
+// RouteVideo 品质模式：视频重包装为MOV
+func (s *QualityStrategy) RouteVideo(file *MediaFile) Route {
+	return Route{Strategy: RouteStrategyVideo, Action: ActionMOVRemux, TargetExt: ".mov", Reason: "视频重包装为MOV"}
 }
 
 // ConvertAudio方法已删除 - 根据README要求，本程序不处理音频文件
@@ -1255,50 +1096,46 @@ func (s *EmojiStrategy) GetName() string {
 }
 
 func (s *EmojiStrategy) ConvertImage(file *MediaFile) (string, error) {
+	return s.converter.executeRoute(file, s.RouteImage(file))
+}
+
+func (s *EmojiStrategy) ConvertVideo(file *MediaFile) (string, error) {
+	return s.converter.executeRoute(file, s.RouteVideo(file))
+}
+
+// RouteImage 根据README规定：表情包模式下工具链优先级
+// 静态图: 使用 AVIF 官方组件 (avifenc)
+// 动图: 使用 ffmpeg 转换为 AVIF
+func (s *EmojiStrategy) RouteImage(file *MediaFile) Route {
 	ext := strings.ToLower(file.Extension)
 
 	// 检查是否已经是目标格式
 	if s.converter.IsTargetFormat(ext) {
-		// 表情包模式：文件已是目标格式，跳过转换
-		return file.Path, nil
+		return skipRoute("已是目标格式")
 	}
 
 	// 跳过已经是高效格式的文件（AVIF, JXL, WebP）
 	// 这些格式不需要进一步压缩，avifenc也不支持JXL作为输入
 	if ext == ".webp" || ext == ".avif" || ext == ".jxl" {
-		// 表情包模式跳过高效格式文件
-		return file.Path, nil
+		return skipRoute("已是高效格式")
 	}
 
-	// 根据README规定：表情包模式下工具链优先级
-	// 静态图: 使用 AVIF 官方组件 (avifenc)
-	// 动图: 使用 ffmpeg 转换为 AVIF
-	switch ext {
-	case ".gif":
-		if s.converter.isAnimated(file.Path) {
-			// 动图使用ffmpeg处理
-			// 表情包模式：动图使用ffmpeg处理
-			return s.converter.ConvertToAVIFAnimated(file) // 使用ffmpeg
-		} else {
-			// 静态GIF使用极限压缩策略
-			// 表情包模式：静态图使用极限压缩策略
-			return s.tryAggressiveAVIF(file)
-		}
-	default:
-		// 所有其他静态图片使用极限压缩策略
-		// 表情包模式：静态图使用极限压缩策略
-		return s.tryAggressiveAVIF(file)
+	if ext == ".gif" && s.converter.isAnimated(file.Path) {
+		return Route{Strategy: RouteStrategyEmoji, Action: ActionEmojiAnimatedAVIF, TargetExt: ".avif", Reason: "动图CRF搜索压缩为AVIF"}
 	}
+	return Route{Strategy: RouteStrategyEmoji, Action: ActionEmojiAVIF, TargetExt: ".avif", Reason: "静图极限压缩为AVIF"}
 }
 
-func (s *EmojiStrategy) ConvertVideo(file *MediaFile) (string, error) {
-	// 根据README规定：表情包模式下视频文件必须被直接跳过，不得进行任何处理
-	// 表情包模式跳过视频文件
-	return file.Path, nil
+// RouteVideo 根据README规定：表情包模式下视频文件必须被直接跳过，不得进行任何处理
+func (s *EmojiStrategy) RouteVideo(file *MediaFile) Route {
+	return skipRoute("表情包模式跳过视频文件")
 }
 
 // ConvertAudio方法已删除 - 根据README要求，本程序不处理音频文件
 
+// emojiMinReduction 表情包模式替换规则：体积至少减小7%才视为成功
+const emojiMinReduction = 0.07
+
 // tryAggressiveAVIF 尝试激进的AVIF压缩
 func (s *EmojiStrategy) tryAggressiveAVIF(file *MediaFile) (string, error) {
 	// 优先尝试无损压缩和重包装
@@ -1308,57 +1145,52 @@ func (s *EmojiStrategy) tryAggressiveAVIF(file *MediaFile) (string, error) {
 		}
 	}
 
-	// 比平衡优化更激进的有损压缩范围进行探底
-	// 只要转换后文件体积相比原图减小 7%-13% 或更多，即视为成功
-	aggressiveLevels := []int{60, 50, 40, 30, 25, 20}
+	// 比平衡优化更激进的有损压缩区间内二分搜索
+	probe := s.converter.searchLossyQuality(file, 20, 60, func(quality int) (string, error) {
+		return s.converter.withProbeLink(file, fmt.Sprintf("q%d", quality), func(probeFile *MediaFile) (string, error) {
+			return s.converter.convertToAVIF(probeFile, quality)
+		})
+	})
+
+	return s.acceptProbe(file, probe, ".avif")
+}
+
+// tryAggressiveAnimatedAVIF 对动图在FFmpeg CRF空间内二分搜索
+func (s *EmojiStrategy) tryAggressiveAnimatedAVIF(file *MediaFile) (string, error) {
+	probe := s.converter.searchLossyQuality(file, 20, 70, func(quality int) (string, error) {
+		return s.converter.withProbeLink(file, fmt.Sprintf("q%d", quality), func(probeFile *MediaFile) (string, error) {
+			return s.converter.convertToAVIFAnimatedCRF(probeFile, qualitysearch.CRF(quality, 63))
+		})
+	})
+
+	return s.acceptProbe(file, probe, ".avif")
+}
+
+// acceptProbe 检查搜索结果是否满足表情包替换规则，满足则移动到最终输出路径
+func (s *EmojiStrategy) acceptProbe(file *MediaFile, probe *ProbeResult, targetExt string) (string, error) {
+	if probe == nil {
+		return file.Path, fmt.Errorf("无法找到合适的压缩级别")
+	}
 
 	originalStat, err := os.Stat(file.Path)
 	if err != nil {
+		os.Remove(probe.Path)
 		return file.Path, s.errorHandler.WrapError("无法获取原文件信息", err)
 	}
 	originalSize := originalStat.Size()
+	if float64(originalSize-probe.Size)/float64(originalSize) < emojiMinReduction {
+		os.Remove(probe.Path)
+		return file.Path, fmt.Errorf("无法找到合适的压缩级别")
+	}
 
-	for _, quality := range aggressiveLevels {
-		// 尝试AVIF质量级别
-
-		result, err := s.converter.convertToAVIF(file, quality)
-		if err != nil {
-			s.converter.logger.Warn("AVIF转换失败，尝试下一个质量级别",
-				zap.String("file", file.Path),
-				zap.Int("quality", quality),
-				zap.Error(err))
-			continue
-		}
-
-		// 检查转换后的文件
-		newStat, err := os.Stat(result)
-		if err != nil {
-			s.converter.logger.Warn("无法获取转换后文件信息",
-				zap.String("file", result),
-				zap.Error(err))
-			continue
-		}
-
-		newSize := newStat.Size()
-
-		// 检查压缩效果：优先选择7%-13%范围，如果没有则选择最接近的
-		reductionRatio := float64(originalSize-newSize) / float64(originalSize)
-		// 检查压缩比例
-
-		// 理想范围：7%-13%
-		if reductionRatio >= 0.07 && reductionRatio <= 0.13 {
-			// 找到理想的AVIF压缩级别
-			return result, nil
-		}
-
-		// 如果压缩比例超过13%但文件确实变小了，也接受（表情包模式追求极限压缩）
-		if reductionRatio > 0.13 && quality == 60 { // 使用最高质量的结果
-			// 使用高压缩比AVIF结果
-			return result, nil
-		}
+	outputPath, err := s.converter.promoteProbeResult(file, probe, targetExt)
+	if err != nil {
+		os.Remove(probe.Path)
+		return file.Path, err
 	}
 
-	return file.Path, s.errorHandler.WrapError("无法找到合适的压缩级别", nil)
+	file.recordQualityScore(probe.Score)
+	return outputPath, nil
 }
 
 // checkAggressiveReduction 检查是否达到7%-13%的体积减小
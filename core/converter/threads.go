@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"pixly/pkg/scheduler"
+
+	"go.uber.org/zap"
+)
+
+// poolSizing 按可用CPU（容器中为cgroup配额）决定工作池的最大规模：
+// 池只在CPU配额内扩容到配置并发数的两倍
+func poolSizing(workers int) int {
+	workers = max(workers, 1)
+	return max(workers, min(workers*2, scheduler.AvailableCPUs()))
+}
+
+// newThreadPlanner 按配置的线程分配策略创建编码线程分配器，slots为工作池的最大并发任务数。
+// 执行中的路由把分配到的线程数随ToolJob传给工具管理器，不在路由中执行的编码器进程使用策略的默认线程数
+func newThreadPlanner(c *Converter, slots int) *scheduler.ThreadPlanner {
+	planner := scheduler.NewThreadPlanner(c.config.Concurrency.ThreadPlan, scheduler.AvailableCPUs(), slots)
+	c.logger.Debug("编码线程分配策略",
+		zap.String("plan", planner.Plan()),
+		zap.Int("cpus", scheduler.AvailableCPUs()),
+		zap.Int("slots", slots),
+		zap.Int("default_threads", planner.DefaultThreads()))
+	return planner
+}
+
+// queueThreadPlan 登记待处理文件的工作量，作为auto策略分配线程时的队列组成；只使用头部解析，不启动ffprobe
+func (c *Converter) queueThreadPlan(files []*MediaFile) {
+	if c.threadPlanner == nil || c.threadPlanner.Plan() != scheduler.ThreadPlanAuto {
+		return
+	}
+	for _, file := range files {
+		job := scheduler.Job{Frames: 1, Size: file.Size}
+		if info, err := c.prober.Get(file.Path).Header(); err == nil {
+			job.Pixels = int64(info.Width) * int64(info.Height)
+			job.Frames = max(info.FrameCount, 1)
+		}
+		c.threadPlanner.Queue(file.Path, job)
+	}
+}
+
+// assignThreads 为开始编码的任务分配线程数，返回线程数与释放函数
+func (c *Converter) assignThreads(file *MediaFile, job scheduler.Job) (int, func()) {
+	if c.threadPlanner == nil {
+		return 0, func() {}
+	}
+	threads := c.threadPlanner.Assign(file.Path, job)
+	c.logger.Debug("分配编码线程",
+		zap.String("file", file.Path),
+		zap.String("encoder", job.Encoder),
+		zap.Int("threads", threads))
+	return threads, func() { c.threadPlanner.Release(file.Path) }
+}
+
+// ThreadStats 返回编码线程分配统计
+func (c *Converter) ThreadStats() scheduler.ThreadStats {
+	return c.threadPlanner.Stats()
+}
@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pixly/pkg/processmonitor"
+
+	"go.uber.org/zap"
+)
+
+// timingFormat 耗时样本的源格式：小写扩展名
+func timingFormat(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// timingSample 描述路由的一次处理：编码器为动作与目标格式（视频重新编码另加目标编码），
+// 努力程度为JXL的effort或AVIF的速度。无法获取尺寸时返回false
+func (c *Converter) timingSample(file *MediaFile, route Route) (processmonitor.TimingSample, bool) {
+	media, err := c.prober.Get(file.Path).Media(c.ctx)
+	if err != nil || media.Width <= 0 || media.Height <= 0 {
+		return processmonitor.TimingSample{}, false
+	}
+
+	encoder := string(route.Action) + route.TargetExt
+	if route.Action == ActionVideoTranscode {
+		encoder += ":" + c.config.Conversion.Video.Codec
+	}
+	effort := 0
+	switch route.TargetExt {
+	case ".jxl":
+		effort = c.encoderProfile(file).JXLEffort
+	case ".avif":
+		effort = c.encoderProfile(file).AVIFSpeed
+	}
+
+	return processmonitor.TimingSample{
+		Format:  timingFormat(file.Path),
+		Pixels:  int64(media.Width) * int64(media.Height),
+		Frames:  max(media.FrameCount, 1),
+		Encoder: encoder,
+		Effort:  effort,
+	}, true
+}
+
+// observeTiming 记录一次成功转换的耗时样本；跳过与保持原样的结果没有编码耗时，不记录
+func (c *Converter) observeTiming(file *MediaFile, route Route, outputPath string, duration time.Duration) {
+	if c.timing == nil || route.Action == ActionSkip || outputPath == "" || outputPath == file.Path {
+		return
+	}
+	sample, ok := c.timingSample(file, route)
+	if !ok {
+		return
+	}
+	sample.Duration = duration
+	c.timing.Observe(sample)
+}
+
+// attachTimingState 打开全局状态数据库，耗时模型从中载入样本并写入新样本；
+// 其他进程正在使用该数据库时本次运行只在内存中记录样本
+func (c *Converter) attachTimingState() {
+	if c.timing == nil || c.stateDB != nil {
+		return
+	}
+	db, err := c.sessionStore.OpenState()
+	if err != nil {
+		c.logger.Warn("打开状态数据库失败，本次运行只在内存中记录耗时样本", zap.Error(err))
+		return
+	}
+	if err := c.timing.Attach(db); err != nil {
+		c.logger.Warn("载入处理耗时样本失败，本次运行只在内存中记录样本", zap.Error(err))
+		db.Close()
+		return
+	}
+	c.stateDB = db
+}
+
+// detachTimingState 耗时模型停止写入后关闭全局状态数据库
+func (c *Converter) detachTimingState() {
+	if c.stateDB == nil {
+		return
+	}
+	c.timing.Detach()
+	if err := c.stateDB.Close(); err != nil {
+		c.logger.Warn("关闭状态数据库失败", zap.Error(err))
+	}
+	c.stateDB = nil
+}
+
+// watchFile 让看门狗监控文件的处理时限：耗时模型能预测时按预测的高分位耗时（限制在估算超时的上下限之间），
+// 否则按文件大小使用固定超时
+func (c *Converter) watchFile(file *MediaFile, route Route) func() {
+	if c.watchdog == nil {
+		return func() {}
+	}
+	var timeout time.Duration
+	if sample, ok := c.timingSample(file, route); ok {
+		if prediction, ok := c.timing.Predict(sample); ok {
+			timeout = processmonitor.ClampTimeout(prediction.Timeout())
+			c.logger.Debug("按耗时模型设置文件超时",
+				zap.String("file", file.Path),
+				zap.String("encoder", sample.Encoder),
+				zap.Int("samples", prediction.Samples),
+				zap.Duration("expected", prediction.Expected),
+				zap.Duration("timeout", timeout))
+		}
+	}
+	return c.watchdog.WatchFile(file.Path, file.Size, timeout)
+}
+
+// etaTracker 按耗时模型估计剩余时间：每个文件的权重为预测耗时（无法预测时取已知预测的平均值），
+// 剩余时间 = 已用时间 × 剩余权重 / 已完成权重，并发度与模型偏差由已用时间自动校准
+type etaTracker struct {
+	start     time.Time
+	weights   map[string]float64
+	completed float64
+	remaining float64
+	mutex     sync.Mutex
+}
+
+// newETATracker 为待处理文件创建剩余时间估计；没有可用的耗时模型时返回nil
+func (c *Converter) newETATracker(files []*MediaFile) *etaTracker {
+	if c.timing == nil {
+		return nil
+	}
+
+	weights := make(map[string]float64, len(files))
+	var known, knownSum float64
+	for _, file := range files {
+		weights[file.Path] = 0
+		// 只使用头部解析，不为估计剩余时间启动ffprobe
+		info, err := c.prober.Get(file.Path).Header()
+		if err != nil {
+			continue
+		}
+		prediction, ok := c.timing.Predict(processmonitor.TimingSample{
+			Format: timingFormat(file.Path),
+			Pixels: int64(info.Width) * int64(info.Height),
+			Frames: max(info.FrameCount, 1),
+		})
+		if ok {
+			weights[file.Path] = prediction.Expected.Seconds()
+			known++
+			knownSum += prediction.Expected.Seconds()
+		}
+	}
+	if known == 0 {
+		return nil
+	}
+
+	tracker := &etaTracker{start: time.Now(), weights: weights}
+	for path, weight := range weights {
+		if weight == 0 {
+			weights[path] = knownSum / known
+		}
+		tracker.remaining += weights[path]
+	}
+	return tracker
+}
+
+// Done 记录文件处理完成，返回进度消息中的剩余时间；无法估计时为空
+func (t *etaTracker) Done(path string) string {
+	if t == nil {
+		return ""
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if weight, ok := t.weights[path]; ok {
+		delete(t.weights, path)
+		t.completed += weight
+		t.remaining -= weight
+	}
+	if t.completed <= 0 {
+		return ""
+	}
+	remaining := time.Duration(float64(time.Since(t.start)) * max(t.remaining, 0) / t.completed)
+	return "，预计剩余 " + remaining.Round(time.Second).String()
+}
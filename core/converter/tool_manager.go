@@ -1,14 +1,19 @@
 package converter
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"pixly/config"
+	"pixly/pkg/scheduler"
 
 	"go.uber.org/zap"
 )
@@ -18,16 +23,39 @@ type ToolManager struct {
 	config       *config.Config
 	logger       *zap.Logger
 	toolCache    map[string]bool
+	versionCache map[string]string
 	cacheMutex   sync.RWMutex
 	errorHandler *ErrorHandler
 }
 
+// ToolJob 工具进程所属的编码任务：进程运行期间登记到任务的内存准入，使调度器按进程计量任务的实际内存；
+// 编码器进程使用任务分配到的线程数
+type ToolJob struct {
+	Ticket  *scheduler.Ticket // 为nil时不登记（探测等不经内存调度的命令）
+	Threads int               // 编码器线程数，0表示由编码器自行决定（通常使用全部核心）
+}
+
+// run 启动命令并在其运行期间登记到任务，返回合并的标准输出与标准错误
+func (job ToolJob) run(cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	untrack := job.Ticket.Track(cmd.Process.Pid)
+	err := cmd.Wait()
+	untrack()
+	return output.Bytes(), err
+}
+
 // NewToolManager 创建新的工具管理器
 func NewToolManager(config *config.Config, logger *zap.Logger, errorHandler *ErrorHandler) *ToolManager {
 	return &ToolManager{
 		config:       config,
 		logger:       logger,
 		toolCache:    make(map[string]bool),
+		versionCache: make(map[string]string),
 		errorHandler: errorHandler,
 	}
 }
@@ -84,6 +112,34 @@ func (tm *ToolManager) checkTool(toolPath string) bool {
 	return err == nil
 }
 
+// Version 返回工具版本输出的第一行（每个工具只查询一次），无法查询时为空
+func (tm *ToolManager) Version(ctx context.Context, toolPath string) string {
+	tm.cacheMutex.RLock()
+	version, exists := tm.versionCache[toolPath]
+	tm.cacheMutex.RUnlock()
+	if exists {
+		return version
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for _, flag := range []string{"--version", "-version"} {
+		output, err := ToolJob{}.run(exec.CommandContext(ctx, toolPath, flag))
+		if err != nil {
+			continue
+		}
+		if line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n"); line != "" {
+			version = strings.TrimSpace(line)
+			break
+		}
+	}
+
+	tm.cacheMutex.Lock()
+	tm.versionCache[toolPath] = version
+	tm.cacheMutex.Unlock()
+	return version
+}
+
 // FindToolInPath 在系统PATH中查找工具
 func (tm *ToolManager) FindToolInPath(toolName string) (string, error) {
 	path, err := exec.LookPath(toolName)
@@ -152,8 +208,67 @@ func (tm *ToolManager) validateAndNormalizePath(path string) (string, error) {
 	return normalizedPath, nil
 }
 
-// ExecuteWithPathValidation 执行工具命令，自动验证和规范化路径参数
-func (tm *ToolManager) ExecuteWithPathValidation(toolPath string, args ...string) ([]byte, error) {
+// WithThreadArgs 为cjxl（--num_threads）、avifenc（-j）与ffmpeg（-threads，作为输出选项放在输出路径之前）
+// 加入线程数参数；threads为0（未分配线程）时不加参数，使用工具自身的默认值；其他工具或参数中已指定线程数时原样返回
+func (tm *ToolManager) WithThreadArgs(toolPath string, threads int, args []string) []string {
+	switch tm.toolKind(toolPath) {
+	case "cjxl":
+		if threads == 0 || hasArgPrefix(args, "--num_threads") {
+			return args
+		}
+		return append(append([]string{}, args...), "--num_threads="+strconv.Itoa(threads))
+	case "avifenc":
+		if threads == 0 || hasArgPrefix(args, "-j", "--jobs") {
+			return args
+		}
+		return append([]string{"-j", strconv.Itoa(threads)}, args...)
+	case "ffmpeg":
+		if threads == 0 || len(args) == 0 || hasArgPrefix(args, "-threads") {
+			return args
+		}
+		last := len(args) - 1
+		withThreads := append(append([]string{}, args[:last]...), "-threads", strconv.Itoa(threads))
+		return append(withThreads, args[last])
+	default:
+		return args
+	}
+}
+
+// toolKind 按配置的工具路径或可执行文件名识别编码器
+func (tm *ToolManager) toolKind(toolPath string) string {
+	tools := tm.config.Tools
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(toolPath)), ".exe")
+	switch {
+	case toolPath == tools.CjxlPath || name == "cjxl":
+		return "cjxl"
+	case toolPath == tools.AvifencPath || name == "avifenc":
+		return "avifenc"
+	case toolPath == tools.FFmpegPath || name == "ffmpeg":
+		return "ffmpeg"
+	default:
+		return ""
+	}
+}
+
+// hasArgPrefix 参数中是否已有以任一前缀开头的选项
+func hasArgPrefix(args []string, prefixes ...string) bool {
+	for _, arg := range args {
+		for _, prefix := range prefixes {
+			if arg == prefix || strings.HasPrefix(arg, prefix+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run 在 ctx 下执行一次编码工具命令（不重试）：加入任务的线程数参数，进程登记到所属任务
+func (tm *ToolManager) Run(ctx context.Context, job ToolJob, toolPath string, args ...string) ([]byte, error) {
+	return job.run(exec.CommandContext(ctx, toolPath, tm.WithThreadArgs(toolPath, job.Threads, args)...))
+}
+
+// ExecuteWithPathValidation 执行工具命令，自动验证和规范化路径参数，按任务加入线程数参数并登记进程；ctx 取消时终止进程且不再重试
+func (tm *ToolManager) ExecuteWithPathValidation(ctx context.Context, job ToolJob, toolPath string, args ...string) ([]byte, error) {
 	// 对所有参数进行路径验证和规范化
 	validatedArgs := make([]string, len(args))
 	for i, arg := range args {
@@ -173,21 +288,31 @@ func (tm *ToolManager) ExecuteWithPathValidation(toolPath string, args ...string
 		}
 	}
 
-	return tm.Execute(toolPath, validatedArgs...)
+	return tm.execute(ctx, job, toolPath, tm.WithThreadArgs(toolPath, job.Threads, validatedArgs)...)
 }
 
 // Execute 执行单个工具命令，带重试机制和超时控制
 func (tm *ToolManager) Execute(toolPath string, args ...string) ([]byte, error) {
+	return tm.ExecuteContext(context.Background(), toolPath, args...)
+}
+
+// ExecuteContext 在 ctx 下执行单个工具命令，带重试机制和超时控制
+func (tm *ToolManager) ExecuteContext(parent context.Context, toolPath string, args ...string) ([]byte, error) {
+	return tm.execute(parent, ToolJob{}, toolPath, args...)
+}
+
+// execute 带重试机制和超时控制执行工具命令，每次启动的进程登记到所属任务
+func (tm *ToolManager) execute(parent context.Context, job ToolJob, toolPath string, args ...string) ([]byte, error) {
 	// 最多重试3次
 	var output []byte
 	var err error
 
 	for i := 0; i < 3; i++ {
 		// 创建带超时的上下文（30秒超时）
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(parent, 30*time.Second)
 		cmd := exec.CommandContext(ctx, toolPath, args...)
 
-		output, err = cmd.CombinedOutput()
+		output, err = job.run(cmd)
 		cancel() // 立即释放资源
 
 		if err == nil {
@@ -198,6 +323,14 @@ func (tm *ToolManager) Execute(toolPath string, args ...string) ([]byte, error)
 			return output, nil
 		}
 
+		// 调用方已取消：进程被终止，返回取消原因且不重试
+		if parentErr := parent.Err(); parentErr != nil {
+			tm.logger.Warn("工具执行被调用方取消",
+				zap.String("tool", toolPath),
+				zap.Error(parentErr))
+			return output, parentErr
+		}
+
 		// 检查是否为context canceled错误，如果是则立即返回不重试
 		if errors.Is(err, context.Canceled) {
 			tm.logger.Warn("工具执行被取消",
@@ -206,11 +339,14 @@ func (tm *ToolManager) Execute(toolPath string, args ...string) ([]byte, error)
 			return output, err
 		}
 
-		// 检查是否为context deadline exceeded错误，如果是则立即返回不重试
-		if errors.Is(err, context.DeadlineExceeded) {
+		// 超时被终止的进程返回的是ExitError，改为携带context.DeadlineExceeded以便按超时处理，且不重试
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			tm.logger.Warn("工具执行超时",
 				zap.String("tool", toolPath),
 				zap.Error(err))
+			if !errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+			}
 			return output, err
 		}
 
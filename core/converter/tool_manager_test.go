@@ -2,9 +2,14 @@ package converter
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"pixly/config"
+
 	"go.uber.org/zap"
 )
 
@@ -98,4 +103,57 @@ func TestToolManagerExecuteNormalOperation(t *testing.T) {
 	}
 
 	t.Logf("Execute normal operation succeeded, output: %s", output)
-}
\ No newline at end of file
+}
+// TestToolManagerVersion 测试版本查询取输出首行、只查询一次，且无法查询时为空
+func TestToolManagerVersion(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	tool := filepath.Join(dir, "cjxl")
+	script := "#!/bin/sh\necho x >> " + calls + "\n[ \"$1\" = --version ] || exit 1\nprintf 'cjxl v0.11.1 [AVX2]\\nCopyright\\n'\n"
+	if err := os.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := NewToolManager(&config.Config{}, zap.NewNop(), nil)
+	for i := 0; i < 2; i++ {
+		if got := tm.Version(context.Background(), tool); got != "cjxl v0.11.1 [AVX2]" {
+			t.Errorf("Version = %q, 期望 %q", got, "cjxl v0.11.1 [AVX2]")
+		}
+	}
+	if data, _ := os.ReadFile(calls); strings.Count(string(data), "x") != 1 {
+		t.Errorf("版本查询执行了 %d 次，期望 1 次", strings.Count(string(data), "x"))
+	}
+	if got := tm.Version(context.Background(), filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("工具不存在时 Version = %q, 期望为空", got)
+	}
+}
+
+// TestWithThreadArgs 测试按工具加入线程数参数，未分配线程或已指定时不修改参数
+func TestWithThreadArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		threads int
+		args    []string
+		want    string
+	}{
+		{"cjxl", "/usr/bin/cjxl", 4, []string{"in.png", "out.jxl"}, "in.png out.jxl --num_threads=4"},
+		{"cjxl未分配线程", "/usr/bin/cjxl", 0, []string{"in.png", "out.jxl"}, "in.png out.jxl"},
+		{"cjxl已指定线程数", "/usr/bin/cjxl", 4, []string{"--num_threads=2", "in.png", "out.jxl"}, "--num_threads=2 in.png out.jxl"},
+		{"avifenc", "/usr/bin/avifenc", 4, []string{"in.png", "out.avif"}, "-j 4 in.png out.avif"},
+		{"avifenc未分配线程", "/usr/bin/avifenc", 0, []string{"in.png", "out.avif"}, "in.png out.avif"},
+		{"avifenc已指定线程数", "/usr/bin/avifenc", 4, []string{"--jobs", "2", "in.png", "out.avif"}, "--jobs 2 in.png out.avif"},
+		{"ffmpeg放在输出之前", "/usr/bin/ffmpeg", 4, []string{"-i", "in.mov", "out.mkv"}, "-i in.mov -threads 4 out.mkv"},
+		{"ffmpeg未分配线程", "/usr/bin/ffmpeg", 0, []string{"-i", "in.mov", "out.mkv"}, "-i in.mov out.mkv"},
+		{"ffmpeg已指定线程数", "/usr/bin/ffmpeg", 4, []string{"-threads", "2", "-i", "in.mov", "out.mkv"}, "-threads 2 -i in.mov out.mkv"},
+		{"其他工具", "/usr/bin/exiftool", 4, []string{"-all=", "a.jpg"}, "-all= a.jpg"},
+	}
+	tm := NewToolManager(&config.Config{}, zap.NewNop(), nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tm.WithThreadArgs(tt.tool, tt.threads, tt.args), " "); got != tt.want {
+				t.Errorf("WithThreadArgs = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,82 +21,26 @@ type VideoInfo struct {
 
 // getVideoInfo 获取视频信息
 func (c *Converter) getVideoInfo(path string) (*VideoInfo, error) {
-	// 使用FFprobe获取视频信息
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "compact",
-		"-show_entries", "format=duration,size:stream=width,height,r_frame_rate,bit_rate,codec_name,codec_type",
-		path,
-	}
-
-	cmd := exec.Command(c.config.Tools.FFprobePath, args...)
-	output, err := cmd.Output()
+	// 使用共享的FFprobe结果
+	probeData, err := c.prober.Get(path).FFprobe(c.ctx)
 	if err != nil {
 		return nil, c.errorHandler.WrapError("ffprobe failed", err)
 	}
 
-	info := &VideoInfo{}
-	outputStr := string(output)
-	lines := strings.Split(outputStr, "\n")
-
-	// 解析compact格式输出
-	for _, line := range lines {
-		if strings.Contains(line, "format|") {
-			// 解析format行: format|duration=10.5|size=1024000
-			parts := strings.Split(line, "|")
-			for _, part := range parts {
-				if strings.HasPrefix(part, "duration=") {
-					info.Duration = parseFloat(strings.TrimPrefix(part, "duration="))
-				} else if strings.HasPrefix(part, "size=") {
-					info.FileSize = int64(parseInt(strings.TrimPrefix(part, "size=")))
-				}
-			}
-		} else if strings.Contains(line, "stream|") {
-			// 解析stream行
-			parts := strings.Split(line, "|")
-			codecType := ""
-			for _, part := range parts {
-				if strings.HasPrefix(part, "codec_type=") {
-					codecType = strings.TrimPrefix(part, "codec_type=")
-				}
-			}
-
-			switch codecType {
-			case "video":
-				for _, part := range parts {
-					if strings.HasPrefix(part, "width=") {
-						info.Width = parseInt(strings.TrimPrefix(part, "width="))
-					} else if strings.HasPrefix(part, "height=") {
-						info.Height = parseInt(strings.TrimPrefix(part, "height="))
-					} else if strings.HasPrefix(part, "r_frame_rate=") {
-						frameRateStr := strings.TrimPrefix(part, "r_frame_rate=")
-						if strings.Contains(frameRateStr, "/") {
-							frameParts := strings.Split(frameRateStr, "/")
-							if len(frameParts) == 2 {
-								num := parseFloat(frameParts[0])
-								den := parseFloat(frameParts[1])
-								if den > 0 {
-									info.FrameRate = num / den
-								}
-							}
-						} else {
-							info.FrameRate = parseFloat(frameRateStr)
-						}
-					} else if strings.HasPrefix(part, "bit_rate=") {
-						info.Bitrate = parseInt(strings.TrimPrefix(part, "bit_rate=")) / 1000 // 转换为kbps
-					} else if strings.HasPrefix(part, "codec_name=") {
-						info.Codec = strings.TrimPrefix(part, "codec_name=")
-					}
-				}
-			case "audio":
-				info.HasAudio = true
-				for _, part := range parts {
-					if strings.HasPrefix(part, "codec_name=") {
-						info.AudioCodec = strings.TrimPrefix(part, "codec_name=")
-					}
-				}
-			}
-		}
+	info := &VideoInfo{
+		Duration: probeData.Duration(),
+		FileSize: int64(parseInt(probeData.Format.Size)),
+	}
+	if video := probeData.VideoStream(); video != nil {
+		info.Width = video.Width
+		info.Height = video.Height
+		info.FrameRate = video.FrameRate()
+		info.Bitrate = parseInt(video.BitRate) / 1000 // 转换为kbps
+		info.Codec = video.CodecName
+	}
+	if audio := probeData.AudioStream(); audio != nil {
+		info.HasAudio = true
+		info.AudioCodec = audio.CodecName
 	}
 
 	// 设置默认值（如果解析失败）
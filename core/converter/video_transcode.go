@@ -0,0 +1,455 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+	"pixly/pkg/qualitysearch"
+	"pixly/pkg/videoquality"
+
+	"go.uber.org/zap"
+)
+
+// videoEncoderSpec 视频编码器参数
+type videoEncoderSpec struct {
+	Name           string // ffmpeg编码器名称
+	Codec          string // 输出编码：av1、hevc
+	CRFLimit       int    // 编码器CRF上限
+	MinCRF, MaxCRF int    // 默认搜索区间
+	DefaultCRF     int    // 无法采样评分时使用
+	Preset         string // 默认预设
+}
+
+// videoEncoders 支持的视频编码器
+var videoEncoders = map[string]videoEncoderSpec{
+	"libsvtav1":  {Name: "libsvtav1", Codec: "av1", CRFLimit: 63, MinCRF: 20, MaxCRF: 50, DefaultCRF: 32, Preset: "6"},
+	"libaom-av1": {Name: "libaom-av1", Codec: "av1", CRFLimit: 63, MinCRF: 20, MaxCRF: 50, DefaultCRF: 32, Preset: "4"},
+	"libx265":    {Name: "libx265", Codec: "hevc", CRFLimit: 51, MinCRF: 14, MaxCRF: 32, DefaultCRF: 24, Preset: "medium"},
+}
+
+// videoContainer 输出容器的扩展名与ffmpeg封装格式
+type videoContainer struct {
+	Name  string
+	Ext   string
+	Muxer string
+}
+
+// videoContainers 支持的输出容器
+var videoContainers = map[string]videoContainer{
+	"mkv": {Name: "mkv", Ext: ".mkv", Muxer: "matroska"},
+	"mp4": {Name: "mp4", Ext: ".mp4", Muxer: "mp4"},
+	"mov": {Name: "mov", Ext: ".mov", Muxer: "mov"},
+}
+
+// efficientVideoCodecs 已是高效编码的源视频，重新编码收益有限且会累积损失
+var efficientVideoCodecs = map[string]bool{"av1": true, "hevc": true, "vp9": true}
+
+// textSubtitleCodecs 可转换为mov_text的文本字幕
+var textSubtitleCodecs = map[string]bool{"subrip": true, "ass": true, "ssa": true, "mov_text": true, "webvtt": true, "text": true}
+
+// mp4AudioCodecs MP4可直接复制的音频编码；MOV另外支持PCM
+var mp4AudioCodecs = map[string]bool{"aac": true, "mp3": true, "ac3": true, "eac3": true, "alac": true, "opus": true, "flac": true}
+
+// videoCRFChoice CRF搜索结论
+type videoCRFChoice struct {
+	CRF      int
+	Metric   videoquality.Metric
+	Score    float64
+	Scored   bool // 是否有采样评分
+	Attempts int
+}
+
+// transcodeContainer 返回配置的输出容器（AV1不支持MOV封装，改用MP4）
+func (c *Converter) transcodeContainer() videoContainer {
+	vc := c.config.Conversion.Video
+	if vc.Codec == "av1" && vc.Container == "mov" {
+		return videoContainers["mp4"]
+	}
+	if container, ok := videoContainers[vc.Container]; ok {
+		return container
+	}
+	return videoContainers["mkv"]
+}
+
+// videoTranscodeRoute 重新编码模式的视频路由：已是高效编码的源视频跳过
+func (c *Converter) videoTranscodeRoute(file *MediaFile) Route {
+	vc := c.config.Conversion.Video
+	if media, err := c.prober.Get(file.Path).Media(c.ctx); err == nil && efficientVideoCodecs[media.Codec] {
+		return skipRoute(fmt.Sprintf("已是高效编码（%s）", media.Codec))
+	}
+	return Route{
+		Strategy:  RouteStrategyVideo,
+		Action:    ActionVideoTranscode,
+		TargetExt: c.transcodeContainer().Ext,
+		Reason:    fmt.Sprintf("视频重新编码为%s（按采样片段感知评分选择CRF）", strings.ToUpper(vc.Codec)),
+	}
+}
+
+// videoCapabilities 查询一次ffmpeg的编码器与libvmaf支持
+func (c *Converter) videoCapabilities() *videoquality.Capabilities {
+	c.videoCapsOnce.Do(func() {
+		caps, err := videoquality.Detect(c.ctx, c.toolRunner(ToolJob{}), c.config.Tools.FFmpegPath) // 查询命令不占用编码线程
+		if err != nil {
+			c.logger.Warn("查询ffmpeg能力失败", zap.Error(err))
+			caps = &videoquality.Capabilities{}
+		}
+		c.videoCaps = caps
+	})
+	return c.videoCaps
+}
+
+// toolRunner 经工具管理器在任务中执行ffmpeg：进程登记到任务并使用其线程数
+func (c *Converter) toolRunner(job ToolJob) videoquality.CommandRunner {
+	return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return c.toolManager.Run(ctx, job, name, args...)
+	}
+}
+
+// videoEncoder 按配置选择可用的编码器，并应用配置的CRF区间与预设
+func (c *Converter) videoEncoder() (videoEncoderSpec, error) {
+	vc := c.config.Conversion.Video
+	candidates := []string{"libx265"}
+	if vc.Codec == "av1" {
+		candidates = []string{"libsvtav1", "libaom-av1"}
+		if vc.AV1Encoder != "" {
+			candidates = []string{vc.AV1Encoder}
+		}
+	}
+
+	caps := c.videoCapabilities()
+	for _, name := range candidates {
+		if !caps.HasEncoder(name) {
+			continue
+		}
+		spec := videoEncoders[name]
+		if vc.MinCRF > 0 {
+			spec.MinCRF = min(vc.MinCRF, spec.CRFLimit)
+		}
+		if vc.MaxCRF > 0 {
+			spec.MaxCRF = min(vc.MaxCRF, spec.CRFLimit)
+		}
+		spec.MinCRF = min(spec.MinCRF, spec.MaxCRF)
+		spec.DefaultCRF = max(spec.MinCRF, min(spec.DefaultCRF, spec.MaxCRF))
+		if vc.Preset != "" {
+			spec.Preset = vc.Preset
+		}
+		return spec, nil
+	}
+	return videoEncoderSpec{}, fmt.Errorf("ffmpeg不支持%s编码器: %s", vc.Codec, strings.Join(candidates, ", "))
+}
+
+// transcodeVideo 重新编码视频：采样选择CRF后整段编码，复制音频、字幕、章节与元数据；
+// 体积未达到最小减小比例时保留原文件
+func (c *Converter) transcodeVideo(file *MediaFile) (string, error) {
+	encoder, err := c.videoEncoder()
+	if err != nil {
+		return "", err
+	}
+
+	probeData, err := c.prober.Get(file.Path).FFprobe(c.ctx)
+	if err != nil {
+		return "", c.errorHandler.WrapError("ffprobe failed", err)
+	}
+	video := probeData.VideoStream()
+	if video == nil {
+		return "", fmt.Errorf("没有视频流: %s", file.Path)
+	}
+
+	job := c.toolJob(file)
+	pixFmt := transcodePixelFormat(encoder, video)
+	color := c.sourceColor(file.Path)
+	colorArgs, encoderParams := videoColorArgs(encoder, video, color)
+	choice := c.searchVideoCRF(file, encoder, pixFmt, probeData.Duration())
+
+	container := c.transcodeContainer()
+	outputPath := c.getOutputPath(file, container.Ext)
+	if outputPath == "" {
+		return "", fmt.Errorf("无法确定输出路径: %s", file.Path)
+	}
+	tempPath := outputPath + ".tmp"
+	if err := c.fileOpHandler.EnsureOutputDirectory(outputPath); err != nil {
+		return "", c.errorHandler.WrapError("failed to create output directory", err)
+	}
+	defer os.Remove(tempPath) // 成功时已重命名，失败时清理残留
+
+	args := []string{"-hide_banner", "-nostats", "-y", "-i", file.Path}
+	args = append(args, c.streamMappingArgs(file, container, probeData)...)
+	args = append(args, videoCodecArgs(encoder, choice.CRF, pixFmt, job.Threads, encoderParams)...)
+	args = append(args, colorArgs...)
+	if encoder.Codec == "hevc" && container.Name != "mkv" {
+		args = append(args, "-tag:v", "hvc1") // Apple设备识别HEVC需要hvc1标签
+	}
+	if container.Name != "mkv" {
+		args = append(args, "-movflags", "+faststart+use_metadata_tags")
+	}
+	args = append(args, "-f", container.Muxer, tempPath)
+
+	c.logger.Info("开始视频重新编码",
+		zap.String("file", file.Path),
+		zap.String("encoder", encoder.Name),
+		zap.Int("crf", choice.CRF))
+	if output, err := c.toolManager.Run(c.fileContext(file), job, c.config.Tools.FFmpegPath, args...); err != nil {
+		return "", c.errorHandler.WrapErrorWithOutput("video transcode failed", err, output)
+	}
+
+	stat, err := os.Stat(tempPath)
+	if err != nil {
+		return "", c.errorHandler.WrapError("failed to stat transcoded video", err)
+	}
+	minReduction := c.config.Conversion.Video.MinReduction / 100
+	if file.Size > 0 && float64(file.Size-stat.Size())/float64(file.Size) < minReduction {
+		c.logger.Info("重新编码体积未达到最小减小比例，保留原文件",
+			zap.String("file", file.Path),
+			zap.Int64("original", file.Size),
+			zap.Int64("transcoded", stat.Size()))
+		return file.Path, nil
+	}
+
+	if !c.verifyOutputFile(tempPath, file.Size) {
+		return "", fmt.Errorf("重新编码输出验证失败: %s", tempPath)
+	}
+	if err := c.verifyColorPreserved(color, tempPath); err != nil {
+		return "", err
+	}
+	if err := NewConversionFramework(c).finalizeTempFile(tempPath, outputPath); err != nil {
+		return "", err
+	}
+
+	if choice.Scored {
+		file.QualityMetric = string(choice.Metric)
+		file.QualityScore = choice.Score
+	}
+	return outputPath, nil
+}
+
+// searchVideoCRF 在CRF区间内二分搜索采样片段平均评分达到目标的最高CRF（体积最小）。
+// ffmpeg带libvmaf时按VMAF评分，否则按SSIM；无法采样时使用编码器默认CRF，
+// 区间内都未达标时使用最低CRF
+func (c *Converter) searchVideoCRF(file *MediaFile, encoder videoEncoderSpec, pixFmt string, duration float64) videoCRFChoice {
+	vc := c.config.Conversion.Video
+	choice := videoCRFChoice{CRF: encoder.DefaultCRF}
+
+	samples := videoquality.PlanSamples(duration, vc.SampleCount, vc.SampleSeconds)
+	if len(samples) == 0 {
+		c.logger.Debug("视频时长未知，使用默认CRF", zap.String("file", file.Path), zap.Int("crf", choice.CRF))
+		return choice
+	}
+
+	choice.Metric, choice.Score = videoquality.MetricSSIM, vc.TargetSSIM
+	if c.videoCapabilities().LibVMAF {
+		choice.Metric, choice.Score = videoquality.MetricVMAF, vc.TargetVMAF
+	}
+	target := choice.Score
+	job := c.toolJob(file)
+	scorer := &videoquality.Scorer{
+		FFmpegPath: c.config.Tools.FFmpegPath,
+		Metric:     choice.Metric,
+		Threads:    job.Threads,
+		Run:        c.toolRunner(job),
+	}
+
+	tempDir, err := os.MkdirTemp("", "pixly-video-")
+	if err != nil {
+		c.logger.Warn("创建采样目录失败，使用默认CRF", zap.Error(err))
+		return videoCRFChoice{CRF: encoder.DefaultCRF}
+	}
+	defer os.RemoveAll(tempDir)
+
+	score := func(crf int) (float64, int64, error) {
+		var total float64
+		var size int64
+		for i, sample := range samples {
+			samplePath := filepath.Join(tempDir, fmt.Sprintf("crf%d_%d.mkv", crf, i))
+			if err := c.encodeVideoSample(c.fileContext(file), job, file.Path, samplePath, sample, encoder, crf, pixFmt); err != nil {
+				return 0, 0, err
+			}
+			if stat, err := os.Stat(samplePath); err == nil {
+				size += stat.Size()
+			}
+			value, err := scorer.Score(c.fileContext(file), file.Path, sample, samplePath)
+			os.Remove(samplePath)
+			if err != nil {
+				return 0, 0, err
+			}
+			total += value
+		}
+		c.logger.Debug("视频采样评分",
+			zap.String("file", file.Path),
+			zap.Int("crf", crf),
+			zap.String("metric", string(choice.Metric)),
+			zap.Float64("score", total/float64(len(samples))))
+		return total / float64(len(samples)), size, nil
+	}
+
+	result, ok := searchCRF(encoder, c.config.Conversion.QualitySearch.MaxSteps, target, score)
+	if !ok {
+		c.logger.Warn("视频采样评分失败，使用默认CRF", zap.String("file", file.Path), zap.Int("crf", result.CRF))
+		return result
+	}
+	choice.CRF, choice.Score, choice.Scored, choice.Attempts = result.CRF, result.Score, result.Scored, result.Attempts
+	c.logger.Info("视频CRF搜索完成",
+		zap.String("file", file.Path),
+		zap.Int("crf", choice.CRF),
+		zap.String("metric", string(choice.Metric)),
+		zap.Float64("score", choice.Score),
+		zap.Float64("target", target),
+		zap.Int("attempts", choice.Attempts))
+	return choice
+}
+
+// searchCRF 在编码器CRF区间内二分搜索score达到target的最高CRF（体积最小）。
+// 区间内都未达标时取最低CRF；全部评分失败时返回默认CRF与false
+func searchCRF(encoder videoEncoderSpec, maxSteps int, target float64, score func(crf int) (float64, int64, error)) (videoCRFChoice, bool) {
+	scores := make(map[int]float64)
+	result, _ := qualitysearch.Run(func(crf int) (qualitysearch.Candidate, error) {
+		value, size, err := score(crf)
+		if err != nil {
+			return qualitysearch.Candidate{}, err
+		}
+		scores[crf] = value
+		return qualitysearch.Candidate{Setting: crf, Size: size}, nil
+	}, qualitysearch.Options{
+		Min:         encoder.MinCRF,
+		Max:         encoder.MaxCRF,
+		MaxSteps:    maxSteps,
+		PreferLower: false, // CRF越高体积越小：寻找仍达标的最高CRF
+		Meets: func(candidate qualitysearch.Candidate) bool {
+			return scores[candidate.Setting] >= target
+		},
+	})
+
+	choice := videoCRFChoice{CRF: encoder.DefaultCRF, Attempts: result.Attempts}
+	switch {
+	case result.Best != nil:
+		choice.CRF = result.Best.Setting
+	case len(scores) > 0:
+		choice.CRF = encoder.MinCRF
+	default:
+		return choice, false
+	}
+	choice.Score, choice.Scored = scores[choice.CRF]
+	return choice, true
+}
+
+// encodeVideoSample 按给定CRF编码一个采样片段（仅视频流）
+func (c *Converter) encodeVideoSample(ctx context.Context, job ToolJob, sourcePath, outputPath string, sample videoquality.Sample, encoder videoEncoderSpec, crf int, pixFmt string) error {
+	args := []string{"-hide_banner", "-nostats", "-y"}
+	args = append(args, sample.SeekArgs()...)
+	args = append(args, "-i", sourcePath, "-map", "0:v:0", "-an", "-sn", "-dn")
+	args = append(args, videoCodecArgs(encoder, crf, pixFmt, job.Threads, nil)...) // HDR元数据只影响显示，不影响采样评分
+	args = append(args, "-f", "matroska", outputPath)
+
+	output, err := c.toolManager.Run(ctx, job, c.config.Tools.FFmpegPath, args...)
+	if err != nil {
+		return c.errorHandler.WrapErrorWithOutput("video sample encode failed", err, output)
+	}
+	return nil
+}
+
+// videoCodecArgs 视频编码参数，params为编码器私有参数（svtav1-params/x265-params）。
+// SVT-AV1与x265不使用ffmpeg的-threads，分配到的线程数threads通过私有参数lp/pools传入；0表示不限制
+func videoCodecArgs(encoder videoEncoderSpec, crf int, pixFmt string, threads int, params []string) []string {
+	args := []string{"-c:v", encoder.Name, "-crf", strconv.Itoa(crf)}
+	switch encoder.Name {
+	case "libsvtav1":
+		args = append(args, "-preset", encoder.Preset)
+		if threads > 0 {
+			params = append([]string{"lp=" + strconv.Itoa(threads)}, params...)
+		}
+		if len(params) > 0 {
+			args = append(args, "-svtav1-params", strings.Join(params, ":"))
+		}
+	case "libaom-av1":
+		args = append(args, "-b:v", "0", "-cpu-used", encoder.Preset, "-row-mt", "1")
+	case "libx265":
+		params = append([]string{"log-level=error"}, params...)
+		if threads > 0 {
+			params = append(params, "pools="+strconv.Itoa(threads))
+		}
+		args = append(args, "-preset", encoder.Preset, "-x265-params", strings.Join(params, ":"))
+	}
+	return append(args, "-pix_fmt", pixFmt)
+}
+
+// transcodePixelFormat 输出像素格式：AV1统一10位（同码率下更少色带），HEVC跟随源位深
+func transcodePixelFormat(encoder videoEncoderSpec, video *mediaprobe.Stream) string {
+	if encoder.Codec == "av1" || isHighBitDepth(video) {
+		return "yuv420p10le"
+	}
+	return "yuv420p"
+}
+
+// isHighBitDepth 源视频是否高于8位
+func isHighBitDepth(video *mediaprobe.Stream) bool {
+	if bits, err := strconv.Atoi(video.BitsPerRawSample); err == nil && bits > 8 {
+		return true
+	}
+	return mediaprobe.PixelFormatBitDepth(video.PixFmt) > 8
+}
+
+// streamMappingArgs 流映射：首个视频流重新编码，音频与字幕按容器支持复制或转换，
+// 保留章节与元数据；容器无法容纳的字幕流被丢弃
+func (c *Converter) streamMappingArgs(file *MediaFile, container videoContainer, probeData *mediaprobe.FFprobeData) []string {
+	args := []string{"-map", "0:v:0", "-map_metadata", "0", "-map_chapters", "0"}
+
+	audioIndex, subtitleIndex := 0, 0
+	for _, stream := range probeData.Streams {
+		switch stream.CodecType {
+		case "audio":
+			codec := audioCodecFor(container, stream.CodecName)
+			args = append(args, "-map", "0:"+strconv.Itoa(stream.Index), "-c:a:"+strconv.Itoa(audioIndex), codec)
+			if codec == "aac" {
+				args = append(args, "-b:a:"+strconv.Itoa(audioIndex), "256k")
+			}
+			audioIndex++
+		case "subtitle":
+			codec, ok := subtitleCodecFor(container, stream.CodecName)
+			if !ok {
+				c.logger.Warn("输出容器不支持该字幕格式，字幕流将被丢弃",
+					zap.String("file", file.Path),
+					zap.String("codec", stream.CodecName),
+					zap.String("container", container.Name))
+				continue
+			}
+			args = append(args, "-map", "0:"+strconv.Itoa(stream.Index), "-c:s:"+strconv.Itoa(subtitleIndex), codec)
+			subtitleIndex++
+		}
+	}
+
+	// MKV可保留附件（如字幕字体）
+	if container.Name == "mkv" {
+		args = append(args, "-map", "0:t?", "-c:t", "copy")
+	}
+	return args
+}
+
+// audioCodecFor 音频流在目标容器中的编码：可直接封装时复制，否则转为AAC
+func audioCodecFor(container videoContainer, codec string) string {
+	switch {
+	case container.Name == "mkv", mp4AudioCodecs[codec]:
+		return "copy"
+	case container.Name == "mov" && strings.HasPrefix(codec, "pcm_"):
+		return "copy"
+	default:
+		return "aac"
+	}
+}
+
+// subtitleCodecFor 字幕流在目标容器中的编码；MP4/MOV只支持文本字幕（转为mov_text）
+func subtitleCodecFor(container videoContainer, codec string) (string, bool) {
+	if container.Name == "mkv" {
+		if codec == "mov_text" {
+			return "srt", true // Matroska不支持mov_text
+		}
+		return "copy", true
+	}
+	if textSubtitleCodecs[codec] {
+		return "mov_text", true
+	}
+	return "", false
+}
@@ -0,0 +1,170 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"pixly/config"
+)
+
+func TestSearchCRF(t *testing.T) {
+	encoder := videoEncoders["libsvtav1"] // 搜索区间20-50，默认32
+
+	// linearScore 评分随CRF线性下降：CRF 20为80分，CRF 50为50分
+	linearScore := func(crf int) (float64, int64, error) {
+		return float64(100 - crf), int64(1000 - crf), nil
+	}
+
+	tests := []struct {
+		name       string
+		maxSteps   int
+		target     float64
+		score      func(crf int) (float64, int64, error)
+		wantCRF    int
+		wantScored bool
+		wantOK     bool
+	}{
+		{"收敛到达标的最高CRF", 0, 70, linearScore, 30, true, true},
+		{"目标在上界", 0, 50, linearScore, 50, true, true},
+		{"目标在下界", 0, 80, linearScore, 20, true, true},
+		{"都未达标时取最低CRF", 0, 95, linearScore, 20, true, true},
+		{"步数不足以采样最低CRF时不带评分", 2, 95, linearScore, 20, false, true},
+		{"步数限制时取已知达标的最高CRF", 2, 60, linearScore, 35, true, true},
+		{"评分全部失败时用默认CRF", 0, 70, func(int) (float64, int64, error) {
+			return 0, 0, errors.New("评分失败")
+		}, 32, false, false},
+		{"部分评分失败视为未达标", 0, 70, func(crf int) (float64, int64, error) {
+			if crf == 35 {
+				return 0, 0, errors.New("评分失败")
+			}
+			return linearScore(crf)
+		}, 30, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tried := make(map[int]bool)
+			choice, ok := searchCRF(encoder, tt.maxSteps, tt.target, func(crf int) (float64, int64, error) {
+				if crf < encoder.MinCRF || crf > encoder.MaxCRF {
+					t.Errorf("采样了区间外的CRF %d", crf)
+				}
+				tried[crf] = true
+				return tt.score(crf)
+			})
+			if ok != tt.wantOK || choice.CRF != tt.wantCRF || choice.Scored != tt.wantScored {
+				t.Errorf("searchCRF = %+v, %v, 期望 CRF %d, Scored %v, %v", choice, ok, tt.wantCRF, tt.wantScored, tt.wantOK)
+			}
+			if choice.Scored && choice.Score != float64(100-choice.CRF) {
+				t.Errorf("Score = %v, 期望 CRF %d 的采样评分 %d", choice.Score, choice.CRF, 100-choice.CRF)
+			}
+			if choice.Attempts != len(tried) {
+				t.Errorf("Attempts = %d, 实际采样 %d 次", choice.Attempts, len(tried))
+			}
+		})
+	}
+}
+
+func TestTranscodeContainer(t *testing.T) {
+	tests := []struct {
+		codec     string
+		container string
+		want      string
+	}{
+		{"hevc", "mov", "mov"},
+		{"hevc", "mp4", "mp4"},
+		{"av1", "mov", "mp4"}, // AV1不支持MOV封装
+		{"av1", "mkv", "mkv"},
+		{"av1", "webm", "mkv"}, // 未知容器
+		{"hevc", "", "mkv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec+"_"+tt.container, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Conversion.Video.Codec = tt.codec
+			cfg.Conversion.Video.Container = tt.container
+			c := &Converter{config: cfg}
+			got := c.transcodeContainer()
+			if got.Name != tt.want || got.Ext != "."+tt.want {
+				t.Errorf("transcodeContainer = %+v, 期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioCodecFor(t *testing.T) {
+	tests := []struct {
+		container string
+		codec     string
+		want      string
+	}{
+		{"mkv", "dts", "copy"},
+		{"mkv", "pcm_s16le", "copy"},
+		{"mp4", "aac", "copy"},
+		{"mp4", "opus", "copy"},
+		{"mp4", "dts", "aac"},
+		{"mp4", "pcm_s16le", "aac"},
+		{"mov", "pcm_s24le", "copy"},
+		{"mov", "alac", "copy"},
+		{"mov", "vorbis", "aac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.container+"_"+tt.codec, func(t *testing.T) {
+			if got := audioCodecFor(videoContainers[tt.container], tt.codec); got != tt.want {
+				t.Errorf("audioCodecFor(%s, %s) = %s, 期望 %s", tt.container, tt.codec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitleCodecFor(t *testing.T) {
+	tests := []struct {
+		container string
+		codec     string
+		want      string
+		ok        bool
+	}{
+		{"mkv", "hdmv_pgs_subtitle", "copy", true},
+		{"mkv", "subrip", "copy", true},
+		{"mkv", "mov_text", "srt", true},
+		{"mp4", "subrip", "mov_text", true},
+		{"mp4", "ass", "mov_text", true},
+		{"mov", "mov_text", "mov_text", true},
+		{"mp4", "hdmv_pgs_subtitle", "", false}, // 图形字幕无法放入MP4
+		{"mov", "dvd_subtitle", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.container+"_"+tt.codec, func(t *testing.T) {
+			got, ok := subtitleCodecFor(videoContainers[tt.container], tt.codec)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("subtitleCodecFor(%s, %s) = %s, %v, 期望 %s, %v", tt.container, tt.codec, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVideoCodecArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoder string
+		threads int
+		params  []string
+		want    string
+	}{
+		{"SVT-AV1", "libsvtav1", 0, nil, "-c:v libsvtav1 -crf 30 -preset 6 -pix_fmt yuv420p10le"},
+		{"SVT-AV1私有参数", "libsvtav1", 0, []string{"enable-hdr=1", "mastering-display=x"}, "-c:v libsvtav1 -crf 30 -preset 6 -svtav1-params enable-hdr=1:mastering-display=x -pix_fmt yuv420p10le"},
+		{"SVT-AV1线程数", "libsvtav1", 4, nil, "-c:v libsvtav1 -crf 30 -preset 6 -svtav1-params lp=4 -pix_fmt yuv420p10le"},
+		{"SVT-AV1线程数与私有参数", "libsvtav1", 2, []string{"enable-hdr=1"}, "-c:v libsvtav1 -crf 30 -preset 6 -svtav1-params lp=2:enable-hdr=1 -pix_fmt yuv420p10le"},
+		{"libaom忽略私有参数", "libaom-av1", 4, []string{"enable-hdr=1"}, "-c:v libaom-av1 -crf 30 -b:v 0 -cpu-used 4 -row-mt 1 -pix_fmt yuv420p10le"},
+		{"x265", "libx265", 0, nil, "-c:v libx265 -crf 30 -preset medium -x265-params log-level=error -pix_fmt yuv420p10le"},
+		{"x265私有参数", "libx265", 0, []string{"hdr10=1"}, "-c:v libx265 -crf 30 -preset medium -x265-params log-level=error:hdr10=1 -pix_fmt yuv420p10le"},
+		{"x265线程数", "libx265", 4, []string{"hdr10=1"}, "-c:v libx265 -crf 30 -preset medium -x265-params log-level=error:hdr10=1:pools=4 -pix_fmt yuv420p10le"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(videoCodecArgs(videoEncoders[tt.encoder], 30, "yuv420p10le", tt.threads, tt.params), " ")
+			if got != tt.want {
+				t.Errorf("videoCodecArgs = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// minWatchTick 稳定检查的最小间隔
+const minWatchTick = 250 * time.Millisecond
+
+// WatchOptions 监视选项
+type WatchOptions struct {
+	Settle      time.Duration // 文件最后一次写入后等待的稳定时间，为0时使用配置
+	InitialScan bool          // 启动时处理目录中已有的文件
+}
+
+// watchPending 等待写入稳定的文件
+type watchPending struct {
+	lastEvent time.Time
+	size      int64
+	modTime   time.Time
+}
+
+// folderWatcher 目录监视状态，只在监视循环的goroutine中访问
+type folderWatcher struct {
+	converter *Converter
+	watcher   *fsnotify.Watcher
+	settle    time.Duration
+	pending   map[string]*watchPending
+}
+
+// Watch 监视目录树：新增或修改的媒体文件在写入稳定后按当前模式转换，结果记录到检查点数据库。
+// ctx 取消或转换器停止时，等待进行中的批次完成后生成报告并返回；逐文件结果只保存在检查点数据库中，报告给出汇总统计。
+func (c *Converter) Watch(ctx context.Context, inputDir string, opts WatchOptions) error {
+	if opts.Settle <= 0 {
+		opts.Settle = time.Duration(c.config.Watch.SettleSeconds) * time.Second
+	}
+
+	c.setInputRoot(inputDir)
+	c.settingsKey = c.settingsFingerprint()
+
+	if err := c.checkPathPermissions(inputDir); err != nil {
+		return c.errorHandler.WrapError("路径权限检查失败", err)
+	}
+	if err := c.openCheckpoint(c.inputRoot); err != nil {
+		return c.errorHandler.WrapError("打开检查点数据库失败", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return c.errorHandler.WrapError("创建目录监视器失败", err)
+	}
+	defer watcher.Close()
+
+	// 启动信号处理器
+	c.signalHandler.Start()
+	defer c.signalHandler.Stop()
+
+	c.mutex.Lock()
+	c.stats.StartTime = time.Now()
+	c.mutex.Unlock()
+
+	if err := c.checkpointMgr.StartSession(c.inputRoot, string(c.mode), 0); err != nil {
+		return c.errorHandler.WrapError("启动转换会话失败", err)
+	}
+
+	c.emitScanStarted()
+
+	fw := &folderWatcher{
+		converter: c,
+		watcher:   watcher,
+		settle:    opts.Settle,
+		pending:   make(map[string]*watchPending),
+	}
+	if err := fw.addTree(c.inputRoot, opts.InitialScan); err != nil {
+		return c.errorHandler.WrapError("监视目录失败", err)
+	}
+
+	c.logger.Info("开始监视目录",
+		zap.String("dir", c.inputRoot),
+		zap.String("mode", string(c.mode)),
+		zap.Duration("settle", opts.Settle),
+		zap.Bool("initial_scan", opts.InitialScan))
+
+	tick := opts.Settle / 2
+	if tick < minWatchTick {
+		tick = minWatchTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	// 同一时间只处理一个批次，处理期间继续接收事件
+	batchDone := make(chan struct{}, 1)
+	running := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fw.stop(running, batchDone)
+		case <-c.ctx.Done():
+			return fw.stop(running, batchDone)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fw.stop(running, batchDone)
+			}
+			fw.handleEvent(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fw.stop(running, batchDone)
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// 事件队列溢出时可能漏掉文件，重新扫描整个目录树
+				c.logger.Warn("监视事件溢出，重新扫描目录", zap.String("dir", c.inputRoot))
+				if err := fw.addTree(c.inputRoot, true); err != nil {
+					c.logger.Warn("重新扫描目录失败", zap.Error(err))
+				}
+				continue
+			}
+			c.logger.Warn("目录监视错误", zap.Error(err))
+		case now := <-ticker.C:
+			if running {
+				continue
+			}
+			ready := fw.settled(now)
+			if len(ready) == 0 {
+				continue
+			}
+			running = true
+			go func() {
+				fw.process(ready)
+				batchDone <- struct{}{}
+			}()
+		case <-batchDone:
+			running = false
+		}
+	}
+}
+
+// stop 等待进行中的批次完成，生成报告并清理会话
+func (fw *folderWatcher) stop(running bool, batchDone chan struct{}) error {
+	if running {
+		<-batchDone
+	}
+	fw.converter.logger.Info("停止监视目录", zap.String("dir", fw.converter.inputRoot))
+	fw.converter.finishSession()
+	return nil
+}
+
+// addTree 监视目录及其所有子目录，scanFiles 为true时将已有文件加入待处理
+func (fw *folderWatcher) addTree(root string, scanFiles bool) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fw.converter.logger.Warn("无法访问文件", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+		if path != root && isIgnoredWatchPath(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if err := fw.watcher.Add(path); err != nil {
+				fw.converter.logger.Warn("监视子目录失败", zap.String("dir", path), zap.Error(err))
+			}
+			return nil
+		}
+		if scanFiles {
+			// 已有文件无需等待写入，首次检查即可处理
+			fw.pending[path] = &watchPending{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+}
+
+// handleEvent 处理文件系统事件：新目录加入监视，新增或写入的文件重新计时
+func (fw *folderWatcher) handleEvent(event fsnotify.Event) {
+	if isIgnoredWatchPath(event.Name) {
+		return
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// 移走的文件不再处理；移入的新名称会收到Create事件
+		delete(fw.pending, event.Name)
+		return
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		delete(fw.pending, event.Name)
+		return
+	}
+	if info.IsDir() {
+		// 整个目录移入时其中的文件不会产生单独的事件
+		if event.Has(fsnotify.Create) {
+			if err := fw.addTree(event.Name, true); err != nil {
+				fw.converter.logger.Warn("监视新目录失败", zap.String("dir", event.Name), zap.Error(err))
+			}
+		}
+		return
+	}
+
+	fw.pending[event.Name] = &watchPending{
+		lastEvent: time.Now(),
+		size:      info.Size(),
+		modTime:   info.ModTime(),
+	}
+}
+
+// settled 取出写入已稳定的文件：距最后一次事件超过稳定时间，且大小与修改时间未再变化
+func (fw *folderWatcher) settled(now time.Time) []*MediaFile {
+	var ready []*MediaFile
+	for path, pending := range fw.pending {
+		if now.Sub(pending.lastEvent) < fw.settle {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(fw.pending, path)
+			continue
+		}
+		if info.Size() != pending.size || !info.ModTime().Equal(pending.modTime) {
+			// 仍在写入（没有产生事件的写入方式，如网络共享），重新计时
+			pending.lastEvent = now
+			pending.size = info.Size()
+			pending.modTime = info.ModTime()
+			continue
+		}
+
+		delete(fw.pending, path)
+		if file := fw.converter.watchedFile(path, info); file != nil {
+			ready = append(ready, file)
+		}
+	}
+	return ready
+}
+
+// process 通过高级池转换一批就绪的文件，结果写入检查点数据库与统计
+func (fw *folderWatcher) process(files []*MediaFile) {
+	c := fw.converter
+
+	c.mutex.Lock()
+	c.stats.TotalFiles += len(files)
+	c.mutex.Unlock()
+	if err := c.checkpointMgr.AddTotalFiles(len(files)); err != nil {
+		c.logger.Warn("更新会话文件数失败", zap.Error(err))
+	}
+
+	// 内容缓存：未变更且已使用相同设置处理过的文件直接跳过
+	batchProcessor := NewBatchProcessor(c, c.logger)
+	files = batchProcessor.skipCachedFiles(files)
+	if len(files) == 0 {
+		return
+	}
+
+	// 与批量转换共用高级池，并发与内存准入由同一处控制
+	c.queueThreadPlan(files)
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		results = make([]*ConversionResult, 0, len(files))
+	)
+	for _, file := range files {
+		file := file
+		wg.Add(1)
+		err := c.advancedPool.SubmitWithPriority(func() {
+			defer wg.Done()
+			defer c.threadPlanner.Release(file.Path)
+
+			// 在归还到内存池之前创建快照
+			result := c.processFile(file)
+			snapshot := *result
+			c.memoryPool.PutConversionResult(result)
+
+			mutex.Lock()
+			results = append(results, &snapshot)
+			mutex.Unlock()
+		}, fileTaskPriority(file), fileTaskID(file))
+
+		if err != nil {
+			wg.Done()
+			c.threadPlanner.Release(file.Path)
+			c.logger.Error("提交任务到高级池失败", zap.String("file", file.Path), zap.Error(err))
+			result := &ConversionResult{
+				OriginalFile: file,
+				OriginalSize: file.Size,
+				Error:        err,
+				Success:      false,
+			}
+			c.UpdateStats(result)
+			mutex.Lock()
+			results = append(results, result)
+			mutex.Unlock()
+		}
+	}
+	wg.Wait()
+
+	// 结果已写入检查点数据库与统计，记录日志后即丢弃，长期监视时不在内存中累积
+	for _, result := range results {
+		c.logger.Info("监视目录文件处理完成",
+			zap.String("file", result.OriginalFile.Path),
+			zap.Bool("success", result.Success),
+			zap.Bool("skipped", result.Skipped),
+			zap.String("output", result.OutputPath),
+			zap.Error(result.Error))
+	}
+}
+
+// watchedFile 为就绪的文件创建任务；非媒体文件、目标格式文件与本程序生成的输出（如Live Photo提取的视频）返回nil
+func (c *Converter) watchedFile(path string, info os.FileInfo) *MediaFile {
+	if !c.isMediaFile(path) || c.isEngineOutput(path) {
+		return nil
+	}
+	file := c.newMediaFile(path, info)
+	if c.isTargetFile(file.Path, file.Extension) {
+		return nil
+	}
+	return file
+}
+
+// isIgnoredWatchPath 忽略隐藏文件与目录：编辑器临时文件、._ 元数据文件以及原件备份目录 .pixly_originals
+func isIgnoredWatchPath(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
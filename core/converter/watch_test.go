@@ -0,0 +1,254 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"pixly/config"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// newTestWatcher 创建只用于事件去抖与稳定判断的监视状态
+func newTestWatcher(t *testing.T, settle time.Duration) *folderWatcher {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Conversion.SupportedExtensions = []string{".png", ".jpg", ".mp4", ".mov", ".jxl"}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	return &folderWatcher{
+		converter: &Converter{config: cfg, logger: zap.NewNop()},
+		watcher:   watcher,
+		settle:    settle,
+		pending:   make(map[string]*watchPending),
+	}
+}
+
+// writeWatchFile 写入文件并返回路径
+func writeWatchFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readyPaths 就绪文件的路径，按名称排序
+func readyPaths(files []*MediaFile) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestWatchSettle(t *testing.T) {
+	const settle = time.Second
+	tests := []struct {
+		name  string
+		run   func(t *testing.T, fw *folderWatcher, path string) []*MediaFile
+		ready bool
+	}{
+		{"稳定时间内不处理", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			return fw.settled(time.Now())
+		}, false},
+		{"稳定后处理", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			return fw.settled(time.Now().Add(settle))
+		}, true},
+		{"新的写入事件重新计时", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			fw.pending[path].lastEvent = time.Now().Add(-2 * settle)
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+			return fw.settled(time.Now())
+		}, false},
+		{"没有事件的写入导致大小变化时重新计时", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			writeWatchFile(t, filepath.Dir(path), filepath.Base(path), "png data, still copying")
+			now := time.Now().Add(settle)
+			if ready := fw.settled(now); len(ready) != 0 {
+				t.Error("大小变化后不应立即处理")
+			}
+			if fw.pending[path] == nil || !fw.pending[path].lastEvent.Equal(now) {
+				t.Error("大小变化后应重新计时")
+			}
+			return fw.settled(now.Add(settle))
+		}, true},
+		{"移走的文件不处理", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Rename})
+			return fw.settled(time.Now().Add(settle))
+		}, false},
+		{"稳定前被删除的文件不处理", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+			os.Remove(path)
+			return fw.settled(time.Now().Add(settle))
+		}, false},
+		{"只修改权限的事件不处理", func(t *testing.T, fw *folderWatcher, path string) []*MediaFile {
+			fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+			return fw.settled(time.Now().Add(settle))
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw := newTestWatcher(t, settle)
+			path := writeWatchFile(t, t.TempDir(), "a.png", "png data")
+
+			ready := tt.run(t, fw, path)
+			if got := len(ready) == 1 && ready[0].Path == path; got != tt.ready {
+				t.Errorf("就绪文件 = %v, 期望就绪 %v", readyPaths(ready), tt.ready)
+			}
+			if tt.ready {
+				if _, ok := fw.pending[path]; ok {
+					t.Error("就绪的文件应移出待处理")
+				}
+				if ready[0].Size != int64(len(mustRead(t, path))) {
+					t.Errorf("Size = %d, 期望当前文件大小", ready[0].Size)
+				}
+			}
+		})
+	}
+}
+
+// mustRead 读取文件内容
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestWatchIgnoredPaths(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/photos/a.png", false},
+		{"/photos/.a.png.swp", true},
+		{"/photos/._a.png", true},
+		{"/photos/" + OriginalsDirName, true},
+		{"/photos/.hidden/a.png", false}, // 只看最后一级，隐藏目录在加入监视时整体跳过
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isIgnoredWatchPath(filepath.FromSlash(tt.path)); got != tt.want {
+				t.Errorf("isIgnoredWatchPath(%q) = %v, 期望 %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	fw := newTestWatcher(t, time.Second)
+	path := writeWatchFile(t, t.TempDir(), ".a.png.swp", "temp")
+	fw.handleEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+	if len(fw.pending) != 0 {
+		t.Error("隐藏文件的事件不应加入待处理")
+	}
+}
+
+func TestWatchAddTree(t *testing.T) {
+	root := t.TempDir()
+	a := writeWatchFile(t, root, "a.png", "a")
+	b := writeWatchFile(t, root, filepath.Join("trip", "b.jpg"), "b")
+	writeWatchFile(t, root, "notes.txt", "not media")
+	writeWatchFile(t, root, filepath.Join(OriginalsDirName, "session", "c.png"), "backup")
+	writeWatchFile(t, root, ".d.png", "hidden")
+
+	// 不扫描已有文件时只监视目录
+	fw := newTestWatcher(t, time.Hour)
+	if err := fw.addTree(root, false); err != nil {
+		t.Fatalf("addTree: %v", err)
+	}
+	if len(fw.pending) != 0 {
+		t.Errorf("不扫描时待处理 = %d, 期望 0", len(fw.pending))
+	}
+	watched := fw.watcher.WatchList()
+	sort.Strings(watched)
+	if want := []string{root, filepath.Join(root, "trip")}; len(watched) != 2 || watched[0] != want[0] || watched[1] != want[1] {
+		t.Errorf("监视目录 = %v, 期望 %v（跳过原件备份目录）", watched, want)
+	}
+
+	// 已有文件无需等待稳定时间，首次检查即可处理；非媒体文件在就绪时过滤
+	fw = newTestWatcher(t, time.Hour)
+	if err := fw.addTree(root, true); err != nil {
+		t.Fatalf("addTree: %v", err)
+	}
+	got := readyPaths(fw.settled(time.Now()))
+	if want := []string{a, b}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("就绪文件 = %v, 期望 %v", got, want)
+	}
+	if len(fw.pending) != 0 {
+		t.Errorf("检查后待处理 = %d, 期望 0", len(fw.pending))
+	}
+}
+
+func TestWatchNewDirectory(t *testing.T) {
+	root := t.TempDir()
+	fw := newTestWatcher(t, time.Hour)
+	if err := fw.addTree(root, false); err != nil {
+		t.Fatalf("addTree: %v", err)
+	}
+
+	// 整个目录移入时其中的文件不会产生单独的事件，随目录一并加入
+	dir := filepath.Join(root, "imported")
+	path := writeWatchFile(t, dir, "a.png", "a")
+	fw.handleEvent(fsnotify.Event{Name: dir, Op: fsnotify.Create})
+	if got := readyPaths(fw.settled(time.Now())); len(got) != 1 || got[0] != path {
+		t.Errorf("就绪文件 = %v, 期望 [%s]", got, path)
+	}
+}
+
+func TestWatchedFileExcludesOwnOutputs(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestCheckpoint(t, dir)
+	fw := newTestWatcher(t, time.Second)
+	c := fw.converter
+	c.checkpointMgr = cm
+	c.config.Conversion.Video.Mode = "transcode"
+
+	still := writeWatchFile(t, dir, "IMG_0001.jpg", "motion photo")
+	motion := writeWatchFile(t, dir, "IMG_0001.mov", "extracted video")
+	video := writeWatchFile(t, dir, "clip.mp4", "video")
+	output := writeWatchFile(t, dir, "a.jxl", "jxl")
+	text := writeWatchFile(t, dir, "notes.txt", "text")
+
+	// Live Photo提取的视频登记为静态图的输出
+	c.recordOutputPath(motion, &MediaFile{Path: still})
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"源文件", still, true},
+		{"Live Photo提取的视频", motion, false},
+		{"未登记的视频", video, true},
+		{"目标格式", output, false},
+		{"非媒体文件", text, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := os.Stat(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.watchedFile(tt.path, info) != nil; got != tt.want {
+				t.Errorf("watchedFile(%s) 处理 = %v, 期望 %v", filepath.Base(tt.path), got, tt.want)
+			}
+		})
+	}
+}
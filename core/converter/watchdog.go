@@ -29,15 +29,12 @@ type WatchdogConfig struct {
 	LargeFileTimeout int
 	// 大文件阈值（MB）
 	LargeFileThreshold int64
-	// 单个文件处理超时时间（秒）
+	// 单个文件处理超时时间（秒），耗时模型无法预测时使用
 	FileProcessingTimeout int
 	// 是否启用看门狗
 	Enabled bool
 	// 看门狗模式
 	Mode WatchdogMode
-
-	// 内存使用限制（MB）
-	MemoryLimit int
 }
 
 // ProgressWatchdog 进度看门狗
@@ -97,6 +94,10 @@ func (w *ProgressWatchdog) Start() {
 // Stop 停止看门狗
 func (w *ProgressWatchdog) Stop() {
 	w.cancel()
+	if !w.config.Enabled {
+		// 未启用时监控goroutine从未启动，无需等待
+		return
+	}
 	select {
 	case <-w.stopped:
 		// 看门狗已停止
@@ -165,13 +166,42 @@ func (w *ProgressWatchdog) fileTimeoutMonitor(currentFile string) {
 			w.mutex.Unlock()
 
 			// 触发文件处理超时处理
-			w.handleFileTimeout(currentFile)
+			w.handleFileTimeout(currentFile, time.Duration(w.config.FileProcessingTimeout)*time.Second)
 			return
 		}
 		w.mutex.Unlock()
 	}
 }
 
+// WatchFile 按给定时限监控单个文件的处理，并发处理的各文件独立计时；返回的函数在文件处理结束时调用。
+// timeout不大于0时按文件大小使用配置的固定超时
+func (w *ProgressWatchdog) WatchFile(currentFile string, fileSize int64, timeout time.Duration) func() {
+	if !w.config.Enabled {
+		return func() {}
+	}
+	if timeout <= 0 {
+		timeout = w.staticFileTimeout(fileSize)
+	}
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(timeout, func() {
+		if w.ctx.Err() == nil {
+			w.handleFileTimeout(currentFile, timeout)
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// staticFileTimeout 没有耗时预测时的单文件超时：大文件使用大文件超时，其他文件使用单文件处理超时
+func (w *ProgressWatchdog) staticFileTimeout(fileSize int64) time.Duration {
+	if fileSize > w.config.LargeFileThreshold*1024*1024 {
+		return time.Duration(w.config.LargeFileTimeout) * time.Second
+	}
+	return time.Duration(w.config.FileProcessingTimeout) * time.Second
+}
+
 // handleStagnation 处理进度停滞
 func (w *ProgressWatchdog) handleStagnation(currentFile string, duration time.Duration, isLargeFile bool) {
 	// 根据看门狗模式采取不同行动
@@ -243,14 +273,14 @@ func (w *ProgressWatchdog) handleStagnation(currentFile string, duration time.Du
 }
 
 // handleFileTimeout 处理文件处理超时
-func (w *ProgressWatchdog) handleFileTimeout(currentFile string) {
+func (w *ProgressWatchdog) handleFileTimeout(currentFile string, timeout time.Duration) {
 	// 根据看门狗模式采取不同行动
 	switch w.config.Mode {
 	case ModeUserInteraction:
 		// 用户交互场景（弱作用）：提供更多上下文信息
 		w.logger.Warn("⏰ 文件处理超时（用户交互模式）",
 			zap.String("current_file", currentFile),
-			zap.Duration("timeout", time.Duration(w.config.FileProcessingTimeout)*time.Second))
+			zap.Duration("timeout", timeout))
 
 		// 提供文件大小信息
 		// 文件信息
@@ -263,7 +293,7 @@ func (w *ProgressWatchdog) handleFileTimeout(currentFile string) {
 		// 默认情况：记录警告
 		w.logger.Warn("⏰ 文件处理超时（默认模式）",
 			zap.String("current_file", currentFile),
-			zap.Duration("timeout", time.Duration(w.config.FileProcessingTimeout)*time.Second))
+			zap.Duration("timeout", timeout))
 	}
 }
 
@@ -290,13 +320,7 @@ func (w *ProgressWatchdog) monitor() {
 	ticker = time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
-	// 添加内存监控
-	memTicker := time.NewTicker(30 * time.Second)
-	defer memTicker.Stop()
-
-	// 添加系统资源压力检查（每分钟检查一次）
-	resourceTicker := time.NewTicker(60 * time.Second)
-	defer resourceTicker.Stop()
+	// 内存由编码任务的内存调度器按预算控制，看门狗只检查进度
 
 	for {
 		select {
@@ -309,82 +333,7 @@ func (w *ProgressWatchdog) monitor() {
 		case <-ticker.C:
 			// 进度停滞检查
 			w.checkStagnation()
-		case <-memTicker.C:
-			// 内存使用检查
-			w.checkMemoryUsage()
-		case <-resourceTicker.C:
-			// 系统资源压力检查
-			// 这里可以添加更复杂的系统资源检查逻辑
-			var m runtime.MemStats
-			runtime.ReadMemStats(&m)
-			memLimitMB := uint64(w.config.MemoryLimit)
-			currentMB := m.Alloc / (1024 * 1024)
-
-			// 如果内存使用超过限制的90%，处理资源压力
-			if memLimitMB > 0 && currentMB > memLimitMB*90/100 {
-				w.handleSystemResourcePressure()
-			}
-		}
-	}
-}
-
-// checkMemoryUsage 检查内存使用情况
-func (w *ProgressWatchdog) checkMemoryUsage() {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	// 检查是否超过配置的内存限制
-	memLimitMB := uint64(w.config.MemoryLimit)
-	currentMB := m.Alloc / (1024 * 1024)
-
-	// 在所有模式下都记录内存使用情况（但级别不同）
-	switch w.config.Mode {
-	case ModeUserInteraction:
-		// 内存使用情况（用户模式）
-
-		// 用户模式下，如果内存使用超过限制的80%，给出警告
-		if memLimitMB > 0 && currentMB > memLimitMB*80/100 {
-			w.logger.Warn("⚠️  内存使用接近限制",
-				zap.Uint64("current_mb", currentMB),
-				zap.Uint64("limit_mb", memLimitMB),
-				zap.Uint64("threshold_mb", memLimitMB*80/100))
-
-			// 如果内存使用超过限制的95%，提供更强烈的警告
-			if currentMB > memLimitMB*95/100 {
-				w.logger.Error("🚨 内存使用严重接近限制，建议释放资源",
-					zap.Uint64("current_mb", currentMB),
-					zap.Uint64("limit_mb", memLimitMB))
-			}
 		}
-	default:
-		// 内存使用情况（默认模式）
-	}
-}
-
-// SetMemoryLimit 设置内存限制（MB）
-func (w *ProgressWatchdog) SetMemoryLimit(limit int) {
-	w.config.MemoryLimit = limit
-}
-
-// handleSystemResourcePressure 处理系统资源紧张情况
-func (w *ProgressWatchdog) handleSystemResourcePressure() {
-	// 根据模式采取不同行动
-	switch w.config.Mode {
-	case ModeUserInteraction:
-		// 用户交互模式：记录警告并提供优化建议
-		w.logger.Warn("⚠️  系统资源紧张，建议优化处理")
-
-		// 提供优化建议
-		// 优化建议
-
-		// 询问用户是否需要自动调整
-		if w.askUserForAction("是否需要自动减少并发处理数量以释放资源？(y/N): ") {
-			// 这里可以添加自动调整逻辑
-			// 已建议用户手动优化系统资源
-		}
-	default:
-		// 默认模式：仅记录信息
-		// 系统资源使用情况
 	}
 }
 
@@ -429,11 +378,10 @@ func (w *ProgressWatchdog) checkStagnation() {
 // GetDefaultWatchdogConfig 获取默认看门狗配置
 func GetDefaultWatchdogConfig() *WatchdogConfig {
 	return &WatchdogConfig{
-		StagnantTimeout:       60,   // 进度停滞检测时间：用户模式60秒
-		LargeFileTimeout:      180,  // 大文件处理超时：用户模式180秒
-		LargeFileThreshold:    50,   // 50MB以上视为大文件
-		FileProcessingTimeout: 120,  // 单个文件处理超时：用户模式120秒
-		MemoryLimit:           8192, // 默认内存限制：8GB
+		StagnantTimeout:       60,  // 进度停滞检测时间：用户模式60秒
+		LargeFileTimeout:      180, // 大文件处理超时：用户模式180秒
+		LargeFileThreshold:    50,  // 50MB以上视为大文件
+		FileProcessingTimeout: 120, // 单个文件处理超时：用户模式120秒
 		Enabled:               true,
 		Mode:                  ModeUserInteraction, // 默认为用户交互模式
 	}
@@ -442,11 +390,10 @@ func GetDefaultWatchdogConfig() *WatchdogConfig {
 // GetEnhancedUserWatchdogConfig 获取增强的用户交互看门狗配置
 func GetEnhancedUserWatchdogConfig() *WatchdogConfig {
 	return &WatchdogConfig{
-		StagnantTimeout:       60,   // 进度停滞检测时间：用户模式60秒
-		LargeFileTimeout:      180,  // 大文件处理超时：用户模式180秒
-		LargeFileThreshold:    50,   // 50MB以上视为大文件
-		FileProcessingTimeout: 120,  // 单个文件处理超时：用户模式120秒
-		MemoryLimit:           8192, // 默认内存限制：8GB
+		StagnantTimeout:       60,  // 进度停滞检测时间：用户模式60秒
+		LargeFileTimeout:      180, // 大文件处理超时：用户模式180秒
+		LargeFileThreshold:    50,  // 50MB以上视为大文件
+		FileProcessingTimeout: 120, // 单个文件处理超时：用户模式120秒
 		Enabled:               true,
 		Mode:                  ModeUserInteraction, // 用户交互模式
 	}
@@ -455,11 +402,10 @@ func GetEnhancedUserWatchdogConfig() *WatchdogConfig {
 // GetExtremeCaseWatchdogConfig 获取极端情况处理看门狗配置
 func GetExtremeCaseWatchdogConfig() *WatchdogConfig {
 	return &WatchdogConfig{
-		StagnantTimeout:       30,   // 进度停滞检测时间：30秒
-		LargeFileTimeout:      90,   // 大文件处理超时：90秒
-		LargeFileThreshold:    50,   // 50MB以上视为大文件
-		FileProcessingTimeout: 60,   // 单个文件处理超时：60秒
-		MemoryLimit:           4096, // 内存限制：4GB
+		StagnantTimeout:       30, // 进度停滞检测时间：30秒
+		LargeFileTimeout:      90, // 大文件处理超时：90秒
+		LargeFileThreshold:    50, // 50MB以上视为大文件
+		FileProcessingTimeout: 60, // 单个文件处理超时：60秒
 		Enabled:               true,
 		Mode:                  ModeUserInteraction, // 用户交互模式但更敏感
 	}
@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"pixly/pkg/scheduler"
+
 	"github.com/panjf2000/ants/v2"
 	"go.uber.org/zap"
 )
@@ -203,7 +204,7 @@ type EnhancedWorkerPool struct {
 func NewResourceManager() *ResourceManager {
 	return &ResourceManager{
 		enabled:           false,
-		usedThreadCount:   runtime.NumCPU(),
+		usedThreadCount:   scheduler.AvailableCPUs(),
 		optimizationRules: make([]OptimizationRule, 0),
 	}
 }
@@ -314,7 +315,7 @@ func (tm *ThreadManager) getBurstThreadPool(workers, cores int) []int {
 // NewEnhancedWorkerPool 创建增强的工作器池
 func NewEnhancedWorkerPool(maxWorkers int, logger *zap.Logger, signals WorkerSignals) (*EnhancedWorkerPool, error) {
     if maxWorkers <= 0 {
-        maxWorkers = runtime.NumCPU()
+        maxWorkers = scheduler.AvailableCPUs()
     }
     
 	ctx, cancel := context.WithCancel(context.Background())
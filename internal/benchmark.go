@@ -2,14 +2,19 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"time"
 
 	"github.com/spf13/cobra"
+	"pixly/config"
+	"pixly/core/converter"
 	"pixly/internal/cmd"
+	"pixly/internal/logger"
 	"pixly/internal/ui"
+	"pixly/pkg/scheduler"
 )
 
 // benchmarkCmd 定义benchmark命令
@@ -22,7 +27,8 @@ var benchmarkCmd = &cobra.Command{
 - 不同转换模式的性能
 - 并发处理能力
 - 内存使用效率
-- 各种文件格式的处理速度`,
+- 各种文件格式的处理速度
+- 编码线程分配策略（--thread-plans）对总耗时的影响`,
 	Example: `  # 使用默认测试文件运行基准测试
   pixly benchmark
 
@@ -33,7 +39,10 @@ var benchmarkCmd = &cobra.Command{
   pixly benchmark --quick
 
   # 运行详细基准测试
-  pixly benchmark --detailed`,
+  pixly benchmark --detailed
+
+  # 比较编码线程分配策略
+  pixly benchmark --thread-plans auto,single,even,off`,
 	RunE: runBenchmarkCommand,
 }
 
@@ -43,6 +52,7 @@ var (
 	benchmarkDetailed bool
 	benchmarkOutput   string
 	benchmarkModes    []string
+	benchmarkPlans    []string
 )
 
 func init() {
@@ -51,6 +61,7 @@ func init() {
 	benchmarkCmd.Flags().BoolVar(&benchmarkDetailed, "detailed", false, "运行详细基准测试")
 	benchmarkCmd.Flags().StringVar(&benchmarkOutput, "output", "", "基准测试结果输出文件")
 	benchmarkCmd.Flags().StringSliceVar(&benchmarkModes, "modes", []string{"auto+", "quality", "emoji"}, "要测试的转换模式")
+	benchmarkCmd.Flags().StringSliceVar(&benchmarkPlans, "thread-plans", []string{scheduler.ThreadPlanAuto}, "要比较的编码线程分配策略 (auto, single, even, off)")
 
 	// 添加到根命令
 	cmd.AddCommand(benchmarkCmd)
@@ -70,6 +81,13 @@ func runBenchmarkCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("测试目录不存在: %s", testDir)
 	}
 
+	// 检查线程分配策略
+	for _, plan := range benchmarkPlans {
+		if !scheduler.IsThreadPlan(plan) {
+			return fmt.Errorf("未知的线程分配策略: %s (可选: %v)", plan, scheduler.ThreadPlans)
+		}
+	}
+
 	// 显示系统信息
 	showSystemInfo()
 
@@ -97,7 +115,7 @@ func runBenchmarkCommand(cmd *cobra.Command, args []string) error {
 func showSystemInfo() {
 	fmt.Println("\n💻 系统信息:")
 	fmt.Printf("   操作系统: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Printf("   CPU核心数: %d\n", runtime.NumCPU())
+	fmt.Printf("   CPU核心数: %d (可用: %d)\n", runtime.NumCPU(), scheduler.AvailableCPUs())
 	fmt.Printf("   Go版本: %s\n", runtime.Version())
 
 	// 获取内存信息
@@ -109,6 +127,7 @@ func showSystemInfo() {
 
 type BenchmarkResult struct {
 	Mode             string
+	ThreadPlan       string
 	FilesProcessed   int
 	TotalTime        time.Duration
 	AvgTimePerFile   time.Duration
@@ -117,6 +136,8 @@ type BenchmarkResult struct {
 	CompressionRatio float64
 	MemoryUsed       uint64
 	Errors           int
+	AvgThreads       float64 // 每个编码任务的平均线程数
+	SingleThreaded   int64   // 单线程编码的任务数
 }
 
 type BenchmarkSuite struct {
@@ -142,18 +163,20 @@ func runBenchmarkSuite(testDir string) (*BenchmarkSuite, error) {
 
 	fmt.Printf("📁 找到 %d 个测试文件\n\n", len(testFiles))
 
-	// 为每个模式运行基准测试
+	// 为每个模式与线程分配策略运行基准测试
 	for _, mode := range benchmarkModes {
-		fmt.Printf("🧪 测试模式: %s\n", mode)
+		for _, plan := range benchmarkPlans {
+			fmt.Printf("🧪 测试模式: %s (线程分配: %s)\n", mode, plan)
 
-		result, err := runModebenchmark(mode, testFiles)
-		if err != nil {
-			fmt.Printf("❌ 模式 %s 测试失败: %v\n", mode, err)
-			continue
-		}
+			result, err := runModebenchmark(mode, plan, testFiles)
+			if err != nil {
+				fmt.Printf("❌ 模式 %s (线程分配: %s) 测试失败: %v\n", mode, plan, err)
+				continue
+			}
 
-		suite.Results = append(suite.Results, *result)
-		fmt.Printf("✅ 模式 %s 测试完成\n\n", mode)
+			suite.Results = append(suite.Results, *result)
+			fmt.Printf("✅ 模式 %s (线程分配: %s) 测试完成\n\n", mode, plan)
+		}
 	}
 
 	suite.EndTime = time.Now()
@@ -197,87 +220,121 @@ func isSupportedFormat(ext string) bool {
 	return false
 }
 
-func runModebenchmark(mode string, testFiles []string) (*BenchmarkResult, error) {
+func runModebenchmark(mode, threadPlan string, testFiles []string) (*BenchmarkResult, error) {
 	result := &BenchmarkResult{
-		Mode: mode,
+		Mode:       mode,
+		ThreadPlan: threadPlan,
 	}
 
-	startTime := time.Now()
-
-	// 记录初始内存状态
-	var startMem runtime.MemStats
-	runtime.ReadMemStats(&startMem)
-
-	// 创建临时输出目录
+	// 创建临时工作目录：转换在测试文件的副本上进行，不修改测试集
 	tempDir, err := os.MkdirTemp("", "pixly-benchmark-*")
 	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(tempDir)
 
-	// 注意：这里简化了转换器创建逻辑，实际应该使用完整的转换器
-
-	// 处理文件
-	processedCount := 0
-	errorCount := 0
-	totalSizeBefore := int64(0)
-	totalSizeAfter := int64(0)
-
+	copiedCount := 0
 	for _, file := range testFiles {
 		// 限制快速测试的文件数量
-		if benchmarkQuick && processedCount >= 10 {
+		if benchmarkQuick && copiedCount >= 10 {
 			break
 		}
 
-		// 获取原始文件大小
-		info, err := os.Stat(file)
-		if err != nil {
-			errorCount++
+		// 加序号避免不同子目录中的同名文件互相覆盖
+		target := filepath.Join(tempDir, fmt.Sprintf("%04d_%s", copiedCount, filepath.Base(file)))
+		if err := copyBenchmarkFile(file, target); err != nil {
+			result.Errors++
 			continue
 		}
-		totalSizeBefore += info.Size()
+		copiedCount++
+	}
+	if copiedCount == 0 {
+		return nil, fmt.Errorf("没有可用于测试的文件")
+	}
 
-		// 执行转换
-		outputFile := filepath.Join(tempDir, filepath.Base(file))
-		// 注意：这里使用简化的测试逻辑，实际应该调用转换器的处理方法
-		if _, err := os.Stat(file); err != nil {
-			errorCount++
-			continue
-		}
+	loggerInstance, err := logger.NewLoggerWithConfig(logger.DefaultLoggerConfig())
+	if err != nil {
+		return nil, fmt.Errorf("创建日志器失败: %w", err)
+	}
+	defer func() {
+		_ = loggerInstance.Sync()
+	}()
 
-		// 获取转换后文件大小
-		outputInfo, err := os.Stat(outputFile)
-		if err == nil {
-			totalSizeAfter += outputInfo.Size()
-		}
+	cfg, err := config.NewConfig("", loggerInstance)
+	if err != nil {
+		return nil, fmt.Errorf("创建配置失败: %w", err)
+	}
+	cfg.Concurrency.ThreadPlan = threadPlan
 
-		processedCount++
+	conv, err := converter.NewConverter(cfg, loggerInstance, mode)
+	if err != nil {
+		return nil, fmt.Errorf("创建转换器失败: %w", err)
 	}
+	defer conv.Close()
+
+	// 记录初始内存状态
+	var startMem runtime.MemStats
+	runtime.ReadMemStats(&startMem)
 
-	// 记录结束时间和内存
-	endTime := time.Now()
+	startTime := time.Now()
+	if err := conv.Convert(tempDir); err != nil {
+		return nil, fmt.Errorf("转换失败: %w", err)
+	}
+	totalTime := time.Since(startTime)
+
+	// 记录结束内存
 	var endMem runtime.MemStats
 	runtime.ReadMemStats(&endMem)
 
 	// 计算结果
-	result.FilesProcessed = processedCount
-	result.TotalTime = endTime.Sub(startTime)
-	result.Errors = errorCount
-	result.TotalSizeBefore = totalSizeBefore
-	result.TotalSizeAfter = totalSizeAfter
-	result.MemoryUsed = endMem.Alloc - startMem.Alloc
+	stats := conv.GetStats()
+	threadStats := conv.ThreadStats()
+	result.FilesProcessed = stats.ProcessedFiles
+	result.TotalTime = totalTime
+	result.Errors += stats.FailedFiles
+	result.TotalSizeBefore = stats.TotalSize
+	result.TotalSizeAfter = stats.CompressedSize
+	result.AvgThreads = threadStats.AvgThreads
+	result.SingleThreaded = threadStats.SingleThreaded
+	if endMem.TotalAlloc > startMem.TotalAlloc {
+		result.MemoryUsed = endMem.TotalAlloc - startMem.TotalAlloc
+	}
 
-	if processedCount > 0 {
-		result.AvgTimePerFile = result.TotalTime / time.Duration(processedCount)
+	if result.FilesProcessed > 0 {
+		result.AvgTimePerFile = result.TotalTime / time.Duration(result.FilesProcessed)
 	}
 
-	if totalSizeBefore > 0 {
-		result.CompressionRatio = float64(totalSizeAfter) / float64(totalSizeBefore)
+	if result.TotalSizeBefore > 0 {
+		result.CompressionRatio = float64(result.TotalSizeAfter) / float64(result.TotalSizeBefore)
 	}
 
 	return result, nil
 }
 
+// copyBenchmarkFile 复制测试文件到临时工作目录
+func copyBenchmarkFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// benchmarkLabel 结果的显示名称：转换模式与线程分配策略
+func benchmarkLabel(result BenchmarkResult) string {
+	return result.Mode + "/" + result.ThreadPlan
+}
+
 func showBenchmarkResults(suite *BenchmarkSuite) {
 	fmt.Println("📊 基准测试结果")
 	fmt.Println("================")
@@ -285,6 +342,7 @@ func showBenchmarkResults(suite *BenchmarkSuite) {
 
 	for _, result := range suite.Results {
 		fmt.Printf("🔧 模式: %s\n", result.Mode)
+		fmt.Printf("   线程分配: %s (平均 %.1f 线程/任务，单线程任务 %d)\n", result.ThreadPlan, result.AvgThreads, result.SingleThreaded)
 		fmt.Printf("   处理文件: %d\n", result.FilesProcessed)
 		fmt.Printf("   总耗时: %v\n", result.TotalTime)
 		fmt.Printf("   平均耗时: %v/文件\n", result.AvgTimePerFile)
@@ -305,14 +363,14 @@ func showPerformanceRanking(results []BenchmarkResult) {
 	// 按平均处理时间排序
 	fmt.Println("⚡ 速度排名 (平均处理时间):")
 	for i, result := range results {
-		fmt.Printf("   %d. %s: %v/文件\n", i+1, result.Mode, result.AvgTimePerFile)
+		fmt.Printf("   %d. %s: %v/文件\n", i+1, benchmarkLabel(result), result.AvgTimePerFile)
 	}
 	fmt.Println()
 
 	// 按压缩比排序
 	fmt.Println("🗜️  压缩效果排名:")
 	for i, result := range results {
-		fmt.Printf("   %d. %s: %.2f%%\n", i+1, result.Mode, result.CompressionRatio*100)
+		fmt.Printf("   %d. %s: %.2f%%\n", i+1, benchmarkLabel(result), result.CompressionRatio*100)
 	}
 	fmt.Println()
 }
@@ -342,6 +400,9 @@ func saveBenchmarkResults(suite *BenchmarkSuite, filename string) error {
 		if _, err := fmt.Fprintf(file, "模式: %s\n", result.Mode); err != nil {
 			return fmt.Errorf("写入模式信息失败: %w", err)
 		}
+		if _, err := fmt.Fprintf(file, "线程分配: %s (平均 %.1f 线程/任务，单线程任务 %d)\n", result.ThreadPlan, result.AvgThreads, result.SingleThreaded); err != nil {
+			return fmt.Errorf("写入线程分配失败: %w", err)
+		}
 		if _, err := fmt.Fprintf(file, "处理文件: %d\n", result.FilesProcessed); err != nil {
 			return fmt.Errorf("写入处理文件数失败: %w", err)
 		}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pixly/core/converter"
+)
+
+// eventsSpec --events 参数：ndjson 输出到标准输出，ndjson:<path> 输出到文件
+var eventsSpec string
+
+// addEventsFlag 为转换类命令添加 --events 标志
+func addEventsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&eventsSpec, "events", "", "输出机器可读的事件流：ndjson（标准输出）或 ndjson:<文件路径>")
+}
+
+// eventsToStdout 事件流是否占用标准输出，此时应关闭界面输出
+func eventsToStdout() bool {
+	return eventsSpec == "ndjson" || eventsSpec == "ndjson:-"
+}
+
+// newEventSink 按 --events 参数创建事件接收器，未指定时返回nil
+func newEventSink() (*converter.NDJSONSink, error) {
+	if eventsSpec == "" {
+		return nil, nil
+	}
+
+	format, path, _ := strings.Cut(eventsSpec, ":")
+	if format != "ndjson" {
+		return nil, fmt.Errorf("不支持的事件格式: %s（可用: ndjson）", format)
+	}
+	if path == "" || path == "-" {
+		return converter.NewNDJSONSink(os.Stdout, nil), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开事件输出文件失败: %w", err)
+	}
+	return converter.NewNDJSONSink(file, file), nil
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"pixly/core/converter"
+	"pixly/internal/i18n"
+	"pixly/internal/ui"
+	"pixly/pkg/scheduler"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan.json>",
+	Short: "按 convert --plan 生成的计划执行转换",
+	Long: `按 convert --plan 生成的计划执行转换，不重新决策。
+
+计划生成后被修改过的文件、或转换设置发生变化时，对应文件不会被处理。
+
+示例：
+  pixly convert --plan --plan-output plan.json ./images
+  pixly apply plan.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	convertCmd.Flags().Bool("plan", false, "仅生成转换计划（JSON与表格），不转换任何文件")
+	convertCmd.Flags().String("plan-output", "", "计划文件路径，\"-\" 表示输出到标准输出 (默认: reports/plans/pixly_plan_<时间>.json)")
+
+	applyCmd.Flags().StringVarP(&outputDir, "output", "o", "", i18n.T(i18n.TextOutputDirectory)+" (须与生成计划时一致)")
+	applyCmd.Flags().IntVarP(&concurrent, "concurrent", "c", scheduler.AvailableCPUs(), i18n.T(i18n.TextConcurrency)+" (默认: 可用CPU核心数)")
+	applyCmd.Flags().BoolP("silent", "s", false, "静默模式 (仅输出JSON统计)")
+	addEventsFlag(applyCmd)
+
+	rootCmd.AddCommand(applyCmd)
+}
+
+// runPlan 生成转换计划并保存
+func runPlan(targetDir, planOutput string) error {
+	conv, err := createConverter()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conv.Close(); err != nil {
+			log.Error("Failed to close converter", zap.Error(err))
+		}
+	}()
+
+	plan, err := conv.Plan(targetDir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化计划失败: %w", err)
+	}
+
+	if planOutput == "-" {
+		displayPlanTable(plan)
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+
+	if planOutput == "" {
+		var builder strings.Builder
+		builder.WriteString("pixly_plan_")
+		builder.WriteString(time.Now().Format("20060102_150405"))
+		builder.WriteString(".json")
+		planOutput = filepath.Join("reports", "plans", builder.String())
+	}
+	if err := os.MkdirAll(filepath.Dir(planOutput), 0755); err != nil {
+		return fmt.Errorf("创建计划目录失败: %w", err)
+	}
+	if err := os.WriteFile(planOutput, data, 0644); err != nil {
+		return fmt.Errorf("保存计划失败: %w", err)
+	}
+
+	displayPlanTable(plan)
+	fmt.Fprintf(os.Stderr, "📄 转换计划已保存到: %s\n", planOutput)
+	fmt.Fprintf(os.Stderr, "   执行计划: pixly apply %s\n", planOutput)
+	return nil
+}
+
+// displayPlanTable 以表格形式显示计划
+func displayPlanTable(plan *converter.Plan) {
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "文件\t类型\t品质\t策略\t动作\t目标\t原始大小\t预估大小\t原因")
+	for _, entry := range plan.Entries {
+		path := entry.Path
+		if rel, err := filepath.Rel(plan.InputDir, entry.Path); err == nil {
+			path = rel
+		}
+		detected := entry.DetectedType
+		if detected == "" {
+			detected = string(entry.MediaType)
+		}
+		target := entry.TargetExt
+		if target == "" {
+			target = "-"
+		}
+		quality := entry.QualityLevel
+		if quality == "" {
+			quality = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			path, detected, quality, entry.Strategy, entry.Action, target,
+			formatPlanSize(entry.Size), formatPlanSize(entry.EstimatedSize), entry.Reason)
+	}
+	writer.Flush()
+
+	summary := plan.Summary
+	fmt.Fprintf(os.Stderr, "\n📋 共 %d 个文件：转换 %d，跳过 %d；预估 %s → %s\n",
+		summary.TotalFiles, summary.ConvertFiles, summary.SkipFiles,
+		formatPlanSize(summary.OriginalSize), formatPlanSize(summary.EstimatedSize))
+}
+
+// formatPlanSize 格式化文件大小
+func formatPlanSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return strconv.FormatFloat(float64(size)/(1024*1024*1024), 'f', 2, 64) + " GB"
+	case size >= 1024*1024:
+		return strconv.FormatFloat(float64(size)/(1024*1024), 'f', 2, 64) + " MB"
+	case size >= 1024:
+		return strconv.FormatFloat(float64(size)/1024, 'f', 1, 64) + " KB"
+	default:
+		return strconv.FormatInt(size, 10) + " B"
+	}
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("读取计划失败: %w", err)
+	}
+
+	var plan converter.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("解析计划失败: %w", err)
+	}
+
+	// 使用计划中的模式，设置指纹由转换器校验
+	mode = plan.Mode
+
+	silent, _ := cmd.Flags().GetBool("silent")
+	silent = silent || eventsToStdout()
+	if silent {
+		cfg.Advanced.UI.SilentMode = true
+	} else {
+		ui.DisplayBanner("按计划执行转换", "info")
+		ui.DisplayInfo("计划: " + args[0])
+		ui.DisplayInfo("目录: " + plan.InputDir)
+		ui.DisplayInfo("模式: " + plan.Mode)
+	}
+
+	conv, err := createConverter()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conv.Close(); err != nil {
+			log.Error("Failed to close converter", zap.Error(err))
+		}
+	}()
+
+	if err := conv.ApplyPlan(&plan); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	displayConversionStats(conv.GetStats(), silent)
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +20,7 @@ import (
 	"pixly/internal/theme"
 	"pixly/internal/ui"
 	"pixly/internal/version"
+	"pixly/pkg/scheduler"
 )
 
 // 全局变量
@@ -95,7 +95,9 @@ var convertCmd = &cobra.Command{
 示例：
   pixly convert /path/to/media/files
   pixly convert --mode quality ./images
-  pixly convert --mode emoji ./gifs --verbose`,
+  pixly convert --mode emoji ./gifs --verbose
+  pixly convert --plan --plan-output plan.json ./images
+  pixly convert --events ndjson ./images > events.ndjson`,
     Args: cobra.MaximumNArgs(1),
     RunE: runConverter,
 }
@@ -133,15 +135,16 @@ func init() {
     // 本地标志（仅 root 命令交互模式使用）
     rootCmd.Flags().StringVarP(&mode, "mode", "m", "auto+", i18n.T(i18n.TextMode)+": auto+, quality, emoji")
     rootCmd.Flags().StringVarP(&outputDir, "output", "o", "", i18n.T(i18n.TextOutputDirectory)+" (默认: "+i18n.T(i18n.TextDirectory)+")")
-    rootCmd.Flags().IntVarP(&concurrent, "concurrent", "c", runtime.NumCPU(), i18n.T(i18n.TextConcurrency)+" (默认: CPU核心数)")
+    rootCmd.Flags().IntVarP(&concurrent, "concurrent", "c", scheduler.AvailableCPUs(), i18n.T(i18n.TextConcurrency)+" (默认: 可用CPU核心数)")
 
     // convert 子命令及专用标志（与 root 一致，避免依赖 PersistentFlags 影响其他子命令）
 	convertCmd.Flags().StringVarP(&mode, "mode", "m", "auto+", i18n.T(i18n.TextMode)+": auto+, quality, emoji")
 	convertCmd.Flags().StringVarP(&outputDir, "output", "o", "", i18n.T(i18n.TextOutputDirectory)+" (默认: "+i18n.T(i18n.TextDirectory)+")")
-	convertCmd.Flags().IntVarP(&concurrent, "concurrent", "c", runtime.NumCPU(), i18n.T(i18n.TextConcurrency)+" (默认: CPU核心数)")
+	convertCmd.Flags().IntVarP(&concurrent, "concurrent", "c", scheduler.AvailableCPUs(), i18n.T(i18n.TextConcurrency)+" (默认: 可用CPU核心数)")
 	convertCmd.Flags().BoolP("silent", "s", false, i18n.T(i18n.TextSilentMode)+" (不显示进度条)")
 	convertCmd.Flags().BoolP("quiet", "q", false, i18n.T(i18n.TextQuietMode)+" (减少输出信息)")
 	convertCmd.Flags().Bool("no-ui", false, i18n.T(i18n.TextDisableUI)+" (禁用所有UI输出)")
+	addEventsFlag(convertCmd)
 
 	rootCmd.AddCommand(convertCmd)
 }
@@ -326,7 +329,7 @@ func createConverter() (*converter.Converter, error) {
 	} else {
 		// 如果没有指定并发数，确保使用配置中的默认值
 		if cfg.Concurrency.ConversionWorkers <= 0 {
-			cfg.Concurrency.ConversionWorkers = runtime.NumCPU()
+			cfg.Concurrency.ConversionWorkers = scheduler.AvailableCPUs()
 		}
 	}
 
@@ -335,11 +338,23 @@ func createConverter() (*converter.Converter, error) {
 		cfg.Output.DirectoryTemplate = outputDir
 	}
 
+	// 事件流输出
+	sink, err := newEventSink()
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建转换器
 	conv, err := converter.NewConverter(cfg, log, mode)
 	if err != nil {
+		if sink != nil {
+			sink.Close()
+		}
 		return nil, fmt.Errorf("failed to create converter: %w", err)
 	}
+	if sink != nil {
+		conv.SetEventSink(sink)
+	}
 
 	return conv, nil
 }
@@ -1135,6 +1150,12 @@ func runConverter(cmd *cobra.Command, args []string) error {
 	quiet, _ := cmd.Flags().GetBool("quiet")
 	disableUI, _ := cmd.Flags().GetBool("no-ui")
 
+	// 事件流占用标准输出时关闭界面输出
+	if eventsToStdout() {
+		silent = true
+		disableUI = true
+	}
+
 	// 更新配置
 	if silent {
 		cfg.Advanced.UI.SilentMode = true
@@ -1146,6 +1167,12 @@ func runConverter(cmd *cobra.Command, args []string) error {
 		cfg.Advanced.UI.DisableUI = true
 	}
 
+	// 计划模式：只生成转换计划，不改动任何文件
+	if planMode, _ := cmd.Flags().GetBool("plan"); planMode {
+		planOutput, _ := cmd.Flags().GetString("plan-output")
+		return runPlan(targetDir, planOutput)
+	}
+
 	// 仅在非静默模式下显示启动信息
 	if !silent && !disableUI {
 		ui.DisplayBanner(i18n.T(i18n.TextStartingConversion), "info")
@@ -1176,11 +1203,15 @@ func runConverter(cmd *cobra.Command, args []string) error {
 
 	fmt.Fprintln(os.Stderr)
 
-	// 显示统计信息
-	stats := conv.GetStats()
+	displayConversionStats(conv.GetStats(), silent || disableUI)
+
+	return nil
+}
 
+// displayConversionStats 显示转换统计；compact为true时仅输出简洁的JSON
+func displayConversionStats(stats *converter.ConversionStats, compact bool) {
 	// 仅在非静默模式下显示统计信息
-	if !silent && !disableUI {
+	if !compact {
 		// 调试信息：打印统计数据
 		if verbose {
 			fmt.Printf("Debug - Stats: TotalFiles=%d, SuccessfulFiles=%d, FailedFiles=%d, SkippedFiles=%d, ProcessedFiles=%d, TotalSize=%d, CompressedSize=%d\n",
@@ -1228,8 +1259,6 @@ func runConverter(cmd *cobra.Command, args []string) error {
 				stats.TotalFiles, stats.SuccessfulFiles, stats.FailedFiles, stats.SkippedFiles, 0, 0)
 		}
 	}
-
-	return nil
 }
 
 // 设置相关辅助函数
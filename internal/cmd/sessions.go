@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"pixly/core/converter"
+	"pixly/internal/i18n"
+	"pixly/internal/ui"
+	"pixly/pkg/scheduler"
+)
+
+// sessionsCmd represents the sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "管理转换会话（断点续传记录）",
+	Long: `管理转换会话：列出、查看、恢复、清理与导出断点续传记录。
+
+每个目标目录使用独立的检查点数据库，保存在用户状态目录下
+（配置项 state.dir，默认 $XDG_STATE_HOME/pixly 或 ~/.local/state/pixly），
+不同目录上的转换可以同时运行。
+
+示例：
+  pixly sessions list
+  pixly sessions show photos_1760000000
+  pixly sessions resume photos_1760000000
+  pixly sessions prune --older-than 168h
+  pixly sessions export photos_1760000000 -o session.json`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "列出所有会话",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runSessionsList,
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:          "show <session>",
+	Short:        "查看会话详情与失败文件",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runSessionsShow,
+}
+
+var sessionsResumeCmd = &cobra.Command{
+	Use:          "resume <session>",
+	Short:        "恢复未完成的会话，跳过已完成的文件",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runSessionsResume,
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:          "prune",
+	Short:        "清理过期或目标目录已不存在的会话",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runSessionsPrune,
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:          "export <session>",
+	Short:        "以JSON导出会话、文件记录与撤销日志",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runSessionsExport,
+}
+
+func init() {
+	sessionsResumeCmd.Flags().StringVarP(&outputDir, "output", "o", "", i18n.T(i18n.TextOutputDirectory)+" (须与会话开始时一致)")
+	sessionsResumeCmd.Flags().IntVarP(&concurrent, "concurrent", "c", scheduler.AvailableCPUs(), i18n.T(i18n.TextConcurrency)+" (默认: 可用CPU核心数)")
+	sessionsResumeCmd.Flags().BoolP("silent", "s", false, "静默模式 (仅输出JSON统计)")
+	addEventsFlag(sessionsResumeCmd)
+
+	sessionsPruneCmd.Flags().Duration("older-than", 0, "清理最近更新早于该时长的会话 (默认: state.session_retention_days)")
+	sessionsPruneCmd.Flags().Bool("all", false, "清理所有未运行的会话")
+	sessionsPruneCmd.Flags().Bool("dry-run", false, "只列出将清理的会话，不做任何修改")
+
+	sessionsExportCmd.Flags().StringP("output", "o", "-", "导出文件路径，\"-\" 表示输出到标准输出")
+
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsResumeCmd, sessionsPruneCmd, sessionsExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+// newSessionStore 按配置创建会话存储
+func newSessionStore() *converter.SessionStore {
+	return converter.NewSessionStore(log, cfg.State.Dir, converter.NewErrorHandler(log))
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) error {
+	sessions, err := newSessionStore().List()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(os.Stderr, "没有会话记录")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "会话\t目录\t模式\t进度\t失败\t最近更新")
+	for _, session := range sessions {
+		if session.Running {
+			fmt.Fprintf(writer, "%s\t%s\t-\t-\t-\t-\n", "(运行中)", filepath.Base(session.DBPath))
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			session.SessionID,
+			session.TargetDir,
+			session.Mode,
+			formatSessionProgress(&session.SessionInfo),
+			strconv.Itoa(session.Failed),
+			session.LastUpdate.Format("2006-01-02 15:04:05"))
+	}
+	return writer.Flush()
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) error {
+	export, err := newSessionStore().Export(args[0])
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[converter.FileStatus]int)
+	for _, record := range export.Files {
+		counts[record.Status]++
+	}
+
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "会话\t%s\n", args[0])
+	if session := export.Session; session != nil {
+		fmt.Fprintf(writer, "目录\t%s\n", session.TargetDir)
+		fmt.Fprintf(writer, "模式\t%s\n", session.Mode)
+		fmt.Fprintf(writer, "开始\t%s\n", session.StartTime.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(writer, "最近更新\t%s\n", session.LastUpdate.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(writer, "进度\t%s\n", formatSessionProgress(session))
+	} else {
+		fmt.Fprintf(writer, "状态\t已完成（仅保留撤销日志）\n")
+	}
+	fmt.Fprintf(writer, "文件记录\t完成 %d，跳过 %d，失败 %d，中断 %d\n",
+		counts[converter.StatusCompleted], counts[converter.StatusSkipped],
+		counts[converter.StatusFailed], counts[converter.StatusProcessing])
+	fmt.Fprintf(writer, "可撤销\t%d\n", len(export.Journal))
+	fmt.Fprintf(writer, "数据库\t%s\n", export.DBPath)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if counts[converter.StatusFailed] == 0 {
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "\n失败文件：")
+	for _, record := range export.Files {
+		if record.Status == converter.StatusFailed {
+			fmt.Fprintf(os.Stderr, "❌ %s: %s\n", record.FilePath, record.ErrorMessage)
+		}
+	}
+	return nil
+}
+
+func runSessionsResume(cmd *cobra.Command, args []string) error {
+	session, err := newSessionStore().FindSession(args[0])
+	if err != nil {
+		return err
+	}
+
+	// 使用会话开始时的模式
+	mode = session.Mode
+
+	silent, _ := cmd.Flags().GetBool("silent")
+	silent = silent || eventsToStdout()
+	if silent {
+		cfg.Advanced.UI.SilentMode = true
+	} else {
+		ui.DisplayBanner("恢复转换会话", "info")
+		ui.DisplayInfo("会话: " + session.SessionID)
+		ui.DisplayInfo("目录: " + session.TargetDir)
+		ui.DisplayInfo("模式: " + session.Mode)
+	}
+
+	conv, err := createConverter()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conv.Close(); err != nil {
+			log.Error("Failed to close converter", zap.Error(err))
+		}
+	}()
+
+	if err := conv.Resume(session.SessionID); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	displayConversionStats(conv.GetStats(), silent)
+	return nil
+}
+
+func runSessionsPrune(cmd *cobra.Command, args []string) error {
+	olderThan, _ := cmd.Flags().GetDuration("older-than")
+	if olderThan <= 0 {
+		olderThan = time.Duration(cfg.State.SessionRetentionDays) * 24 * time.Hour
+	}
+	all, _ := cmd.Flags().GetBool("all")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	pruned, err := newSessionStore().Prune(converter.PruneOptions{
+		OlderThan: olderThan,
+		All:       all,
+		DryRun:    dryRun,
+	})
+	for _, session := range pruned {
+		fmt.Fprintf(os.Stderr, "🗑️  %s (%s, 最近更新 %s)\n",
+			session.SessionID, session.TargetDir, session.LastUpdate.Format("2006-01-02 15:04"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "\n将清理 %d 个会话\n", len(pruned))
+	} else {
+		fmt.Fprintf(os.Stderr, "\n已清理 %d 个会话\n", len(pruned))
+	}
+	return nil
+}
+
+func runSessionsExport(cmd *cobra.Command, args []string) error {
+	export, err := newSessionStore().Export(args[0])
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化会话失败: %w", err)
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("保存会话导出失败: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "📄 会话已导出到: %s\n", output)
+	return nil
+}
+
+// formatSessionProgress 格式化会话进度：已结束文件数/总文件数
+func formatSessionProgress(session *converter.SessionInfo) string {
+	done := session.Completed + session.Skipped + session.Failed
+	return strconv.Itoa(done) + "/" + strconv.Itoa(session.TotalFiles)
+}
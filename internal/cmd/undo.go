@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"pixly/core/converter"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [session]",
+	Short: "撤销原地转换会话，恢复被替换的原件",
+	Long: `撤销原地转换会话：删除转换输出并将原件恢复到原位置。
+
+恢复前会校验原件备份与输出文件的哈希，输出文件在转换后被修改过时默认拒绝撤销。
+不指定会话时列出所有可撤销的会话。
+
+示例：
+  pixly undo
+  pixly undo photos_1760000000
+  pixly undo photos_1760000000 --match "*.png" --dry-run`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runUndo,
+}
+
+func init() {
+	undoCmd.Flags().StringSlice("match", nil, "仅撤销匹配的源文件（glob，匹配完整路径或文件名，可重复）")
+	undoCmd.Flags().Bool("dry-run", false, "只校验并列出将撤销的文件，不做任何修改")
+	undoCmd.Flags().Bool("force", false, "输出文件已被修改时仍然撤销")
+
+	rootCmd.AddCommand(undoCmd)
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	store := converter.NewSessionStore(log, cfg.State.Dir, converter.NewErrorHandler(log))
+	if len(args) == 0 {
+		return listUndoSessions(store)
+	}
+
+	checkpointMgr, err := store.OpenJournal(args[0])
+	if err != nil {
+		return err
+	}
+	defer checkpointMgr.Close()
+
+	patterns, _ := cmd.Flags().GetStringSlice("match")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	force, _ := cmd.Flags().GetBool("force")
+
+	results, err := checkpointMgr.Undo(args[0], converter.UndoOptions{
+		Patterns: patterns,
+		DryRun:   dryRun,
+		Force:    force,
+	})
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "没有匹配的文件")
+		return nil
+	}
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", result.Entry.SourcePath, result.Error)
+		case result.Restored:
+			fmt.Fprintf(os.Stderr, "↩️  %s ← %s\n", result.Entry.SourcePath, result.Entry.OutputPath)
+		default:
+			fmt.Fprintf(os.Stderr, "✅ %s (可撤销)\n", result.Entry.SourcePath)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "\n共 %d 个文件，失败 %d\n", len(results), failed)
+	if failed > 0 {
+		log.Warn("部分文件撤销失败", zap.String("session", args[0]), zap.Int("failed", failed))
+		return fmt.Errorf("%d 个文件撤销失败", failed)
+	}
+	return nil
+}
+
+// listUndoSessions 列出所有检查点数据库中可撤销的会话，列出前按保留期清理
+func listUndoSessions(store *converter.SessionStore) error {
+	retention := time.Duration(cfg.Undo.RetentionDays) * 24 * time.Hour
+
+	var sessions []*converter.JournalSession
+	busy, err := store.ForEach(func(checkpointMgr *converter.CheckpointManager) error {
+		if _, err := checkpointMgr.PruneJournal(retention); err != nil {
+			log.Warn("清理过期撤销日志失败", zap.String("db", checkpointMgr.DBPath()), zap.Error(err))
+		}
+		journal, err := checkpointMgr.ListJournalSessions()
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, journal...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("读取撤销日志失败: %w", err)
+	}
+	for _, path := range busy {
+		fmt.Fprintf(os.Stderr, "⚠️  检查点数据库正被使用，已跳过: %s\n", path)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastAt.After(sessions[j].LastAt)
+	})
+
+	if len(sessions) == 0 {
+		fmt.Fprintln(os.Stderr, "没有可撤销的会话")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "会话\t文件数\t原件备份\t最近记录\t过期时间")
+	for _, session := range sessions {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			session.SessionID,
+			strconv.Itoa(session.Entries),
+			formatPlanSize(session.BackupSize),
+			session.LastAt.Format("2006-01-02 15:04:05"),
+			session.FirstAt.Add(retention).Format("2006-01-02 15:04"))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"pixly/core/converter"
+	"pixly/internal/i18n"
+	"pixly/internal/ui"
+	"pixly/pkg/scheduler"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch <directory>",
+	Short: "监视目录，新文件写入完成后自动转换",
+	Long: `监视目录树（包括之后新建的子目录），新增或修改的媒体文件在写入稳定后
+按所选模式自动转换，结果记录到该目录的检查点数据库。
+
+文件在最后一次写入后等待 --settle 时长，且大小与修改时间不再变化才会处理，
+适合作为拍摄素材的收件箱目录。按 Ctrl+C 停止，未完成的会话可用 pixly sessions resume 恢复。
+
+示例：
+  pixly watch ~/Pictures/inbox
+  pixly watch ~/Pictures/inbox --mode quality --settle 10s`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().StringVarP(&mode, "mode", "m", "auto+", i18n.T(i18n.TextMode)+": auto+, quality, emoji")
+	watchCmd.Flags().StringVarP(&outputDir, "output", "o", "", i18n.T(i18n.TextOutputDirectory)+" (默认: "+i18n.T(i18n.TextDirectory)+")")
+	watchCmd.Flags().IntVarP(&concurrent, "concurrent", "c", scheduler.AvailableCPUs(), i18n.T(i18n.TextConcurrency)+" (默认: 可用CPU核心数)")
+	watchCmd.Flags().Duration("settle", 0, "文件最后一次写入后等待的时长 (默认: watch.settle_seconds)")
+	watchCmd.Flags().Bool("skip-existing", false, "不处理启动时目录中已有的文件")
+	addEventsFlag(watchCmd)
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	targetDir := args[0]
+	if normalized, err := converter.GlobalPathUtils.NormalizePath(targetDir); err == nil {
+		targetDir = normalized
+	}
+	if info, err := os.Stat(targetDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s: %s", i18n.T(i18n.TextDirectoryNotFound), targetDir)
+	}
+
+	settle, _ := cmd.Flags().GetDuration("settle")
+	skipExisting, _ := cmd.Flags().GetBool("skip-existing")
+	initialScan := cfg.Watch.InitialScan && !skipExisting
+
+	// 守护模式不显示进度条
+	cfg.Advanced.UI.SilentMode = true
+
+	// 事件流占用标准输出时只输出事件
+	compact := eventsToStdout()
+	if !compact {
+		ui.DisplayBanner("监视目录", "info")
+		ui.DisplayInfo(i18n.T(i18n.TextDirectory) + ": " + targetDir)
+		ui.DisplayInfo(i18n.T(i18n.TextMode) + ": " + mode)
+	}
+
+	conv, err := createConverter()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conv.Close(); err != nil {
+			log.Error("Failed to close converter", zap.Error(err))
+		}
+	}()
+
+	if err := conv.Watch(context.Background(), targetDir, converter.WatchOptions{
+		Settle:      settle,
+		InitialScan: initialScan,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	displayConversionStats(conv.GetStats(), compact)
+	return nil
+}
@@ -93,10 +93,18 @@ func FinishNamedDynamicProgress(name string) {
 	GetDynamicProgressManager().FinishBar(name)
 }
 
+// suppressed 是否不显示进度条：启动时的配置，或命令行参数修改后的全局配置
+func (dpm *DynamicProgressManager) suppressed() bool {
+	if dpm.silentMode || dpm.disableUI {
+		return true
+	}
+	return globalConfig != nil && (globalConfig.Advanced.UI.SilentMode || globalConfig.Advanced.UI.DisableUI)
+}
+
 // StartBar 启动进度条
 func (dpm *DynamicProgressManager) StartBar(id string, total int64, message string) {
 	// 如果处于静默模式或禁用UI，直接返回
-	if dpm.silentMode || dpm.disableUI {
+	if dpm.suppressed() {
 		return
 	}
 
@@ -141,7 +149,7 @@ func (dpm *DynamicProgressManager) StartBar(id string, total int64, message stri
 // UpdateBar 更新进度条
 func (dpm *DynamicProgressManager) UpdateBar(id string, current int64, message string) {
 	// 如果处于静默模式或禁用UI，直接返回
-	if dpm.silentMode || dpm.disableUI {
+	if dpm.suppressed() {
 		return
 	}
 
@@ -200,7 +208,7 @@ func (dpm *DynamicProgressManager) UpdateBar(id string, current int64, message s
 // FinishBar 完成进度条
 func (dpm *DynamicProgressManager) FinishBar(id string) {
 	// 如果处于静默模式或禁用UI，直接返回
-	if dpm.silentMode || dpm.disableUI {
+	if dpm.suppressed() {
 		return
 	}
 
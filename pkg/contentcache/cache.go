@@ -0,0 +1,533 @@
+package contentcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pixly/config"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// DBFileName 默认缓存数据库文件名
+const DBFileName = "content_cache.db"
+
+// entriesBucket 条目bucket，键为规范化后的文件路径
+const entriesBucket = "entries"
+
+// 条目编码前缀
+const (
+	encodingJSON byte = 'j'
+	encodingGzip byte = 'z'
+)
+
+// 转换结果状态
+const (
+	StatusConverted = "converted"
+	StatusSkipped   = "skipped"
+	StatusFailed    = "failed"
+)
+
+// Options 缓存选项
+type Options struct {
+	Dir      string        // 缓存目录，为空时使用用户缓存目录下的pixly
+	Name     string        // 数据库文件名，为空时使用DBFileName；条目类型不同的调用方应使用不同文件
+	MaxBytes int64         // 条目总大小上限，超出时按最近访问时间淘汰
+	TTL      time.Duration // 条目有效期，<=0表示不过期
+	Compress bool          // 是否gzip压缩条目
+}
+
+// OptionsFromConfig 从高级缓存配置构造选项（max_size单位MB，ttl单位小时）
+func OptionsFromConfig(cfg config.CacheConfig) Options {
+	return Options{
+		Dir:      cfg.CacheDir,
+		MaxBytes: int64(cfg.MaxSize) * 1024 * 1024,
+		TTL:      time.Duration(cfg.TTL) * time.Hour,
+		Compress: cfg.Compress,
+	}
+}
+
+// DefaultDir 默认缓存目录
+func DefaultDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "pixly")
+	}
+	return filepath.Join(os.TempDir(), "pixly_cache")
+}
+
+// Outcome 最终转换结果
+type Outcome struct {
+	Settings       string  `json:"settings"` // 转换设置指纹，设置变化后结果失效
+	Status         string  `json:"status"`
+	OutputPath     string  `json:"output_path,omitempty"`
+	OutputSize     int64   `json:"output_size,omitempty"`
+	Method         string  `json:"method,omitempty"`
+	SkipReason     string  `json:"skip_reason,omitempty"`
+	QualityMetric  string  `json:"quality_metric,omitempty"`
+	QualityScore   float64 `json:"quality_score,omitempty"`
+	QualitySetting int     `json:"quality_setting,omitempty"`
+}
+
+// Entry 缓存条目，由(path, size, mtime, content hash)共同确定
+type Entry struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	ContentHash string    `json:"content_hash"`
+
+	// 形态与品质分析结果，由调用方以各自的类型编码
+	Morphology json.RawMessage `json:"morphology,omitempty"`
+	Quality    json.RawMessage `json:"quality,omitempty"`
+	Outcome    *Outcome        `json:"outcome,omitempty"`
+
+	CachedAt   time.Time `json:"cached_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// DecodeMorphology 解码形态分析结果，不存在或解码失败时返回false
+func (e *Entry) DecodeMorphology(v interface{}) bool {
+	return len(e.Morphology) > 0 && json.Unmarshal(e.Morphology, v) == nil
+}
+
+// DecodeQuality 解码品质评估结果，不存在或解码失败时返回false
+func (e *Entry) DecodeQuality(v interface{}) bool {
+	return len(e.Quality) > 0 && json.Unmarshal(e.Quality, v) == nil
+}
+
+// SetMorphology 编码形态分析结果，v为nil时保留原值
+func (e *Entry) SetMorphology(v interface{}) {
+	if data, ok := marshalOptional(v); ok {
+		e.Morphology = data
+	}
+}
+
+// SetQuality 编码品质评估结果，v为nil时保留原值
+func (e *Entry) SetQuality(v interface{}) {
+	if data, ok := marshalOptional(v); ok {
+		e.Quality = data
+	}
+}
+
+func marshalOptional(v interface{}) (json.RawMessage, bool) {
+	if v == nil {
+		return nil, false
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil, false
+	}
+	return data, true
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits    int64
+	Misses  int64
+	Evicted int64
+}
+
+// Cache 基于bbolt的持久化内容哈希缓存，跨运行复用分析与转换结果
+type Cache struct {
+	db     *bbolt.DB
+	logger *zap.Logger
+	opts   Options
+
+	mutex   sync.Mutex
+	touched map[string]time.Time // 待回写的访问时间，关闭时统一写入
+	size    int64                // 条目总大小（近似）
+	stats   Stats
+}
+
+// ErrClosed 缓存已关闭
+var ErrClosed = errors.New("content cache closed")
+
+// Open 打开缓存数据库并执行过期与容量淘汰
+func Open(opts Options, logger *zap.Logger) (*Cache, error) {
+	if opts.Dir == "" {
+		opts.Dir = DefaultDir()
+	}
+	if opts.Name == "" {
+		opts.Name = DBFileName
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// 缓存是可选的：数据库被其他进程占用时快速失败，不阻塞转换
+	db, err := bbolt.Open(filepath.Join(opts.Dir, opts.Name), 0600, &bbolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(entriesBucket))
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	cache := &Cache{
+		db:      db,
+		logger:  logger,
+		opts:    opts,
+		touched: make(map[string]time.Time),
+	}
+	if err := cache.prune(); err != nil {
+		logger.Warn("内容缓存淘汰失败", zap.Error(err))
+	}
+	return cache, nil
+}
+
+// Lookup 查找条目：路径、大小、修改时间与内容指纹全部一致且未过期时命中
+func (c *Cache) Lookup(path string, size int64, modTime time.Time) (*Entry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	entry, err := c.get(path)
+	if err != nil || entry == nil || entry.Size != size || !entry.ModTime.Equal(modTime) || c.expired(entry) {
+		c.countMiss()
+		return nil, false
+	}
+
+	// 大小与修改时间一致后再计算指纹，未命中的文件不产生额外读取
+	hash, err := Fingerprint(path, size)
+	if err != nil || hash != entry.ContentHash {
+		c.countMiss()
+		return nil, false
+	}
+
+	now := time.Now()
+	c.mutex.Lock()
+	c.stats.Hits++
+	c.touched[path] = now
+	c.mutex.Unlock()
+
+	entry.AccessedAt = now
+	return entry, true
+}
+
+// NewEntry 为文件创建新条目（计算内容指纹）
+func (c *Cache) NewEntry(path string, size int64, modTime time.Time) (*Entry, error) {
+	hash, err := Fingerprint(path, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		Path:        path,
+		Size:        size,
+		ModTime:     modTime,
+		ContentHash: hash,
+	}, nil
+}
+
+// Store 写入条目，超出容量上限时触发淘汰
+func (c *Cache) Store(entry *Entry) error {
+	if c == nil {
+		return ErrClosed
+	}
+
+	now := time.Now()
+	if entry.CachedAt.IsZero() {
+		entry.CachedAt = now
+	}
+	entry.AccessedAt = now
+
+	value, err := c.encode(entry)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(entry.Path)
+	var delta int64
+	err = c.db.Batch(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(entriesBucket))
+		if bucket == nil {
+			return ErrClosed
+		}
+		delta = int64(len(key) + len(value))
+		if old := bucket.Get(key); old != nil {
+			delta -= int64(len(key) + len(old))
+		}
+		return bucket.Put(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.size += delta
+	delete(c.touched, entry.Path)
+	overLimit := c.opts.MaxBytes > 0 && c.size > c.opts.MaxBytes
+	c.mutex.Unlock()
+
+	if overLimit {
+		return c.prune()
+	}
+	return nil
+}
+
+// Delete 删除条目
+func (c *Cache) Delete(path string) error {
+	if c == nil {
+		return ErrClosed
+	}
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(entriesBucket))
+		if bucket == nil {
+			return ErrClosed
+		}
+		return bucket.Delete([]byte(path))
+	})
+}
+
+// Stats 返回命中统计
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// Close 回写访问时间并关闭数据库
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.flushTouched(); err != nil {
+		c.logger.Warn("回写缓存访问时间失败", zap.Error(err))
+	}
+	return c.db.Close()
+}
+
+// Fingerprint 计算内容指纹：文件大小 + 全部内容的SHA256（只比较头尾会漏掉中间被修改的文件）
+func Fingerprint(path string, size int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	hasher.Write([]byte(strconv.FormatInt(size, 10)))
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (c *Cache) get(path string) (*Entry, error) {
+	var entry *Entry
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(entriesBucket))
+		if bucket == nil {
+			return ErrClosed
+		}
+		value := bucket.Get([]byte(path))
+		if value == nil {
+			return nil
+		}
+		decoded, err := decode(value)
+		if err != nil {
+			return err
+		}
+		entry = decoded
+		return nil
+	})
+	return entry, err
+}
+
+func (c *Cache) countMiss() {
+	c.mutex.Lock()
+	c.stats.Misses++
+	c.mutex.Unlock()
+}
+
+func (c *Cache) expired(entry *Entry) bool {
+	return c.opts.TTL > 0 && time.Since(entry.CachedAt) > c.opts.TTL
+}
+
+// prune 删除过期条目；总大小超过上限时按最近访问时间淘汰至上限的90%
+func (c *Cache) prune() error {
+	if err := c.flushTouched(); err != nil {
+		return err
+	}
+
+	type candidate struct {
+		key        string
+		size       int64
+		accessedAt time.Time
+	}
+
+	var evicted int64
+	var total int64
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(entriesBucket))
+		if bucket == nil {
+			return ErrClosed
+		}
+
+		var expired [][]byte
+		var live []candidate
+		err := bucket.ForEach(func(k, v []byte) error {
+			entry, err := decode(v)
+			if err != nil || c.expired(entry) {
+				expired = append(expired, append([]byte(nil), k...))
+				return nil
+			}
+			size := int64(len(k) + len(v))
+			total += size
+			live = append(live, candidate{key: string(k), size: size, accessedAt: entry.AccessedAt})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			evicted++
+		}
+
+		if c.opts.MaxBytes <= 0 || total <= c.opts.MaxBytes {
+			return nil
+		}
+
+		sort.Slice(live, func(i, j int) bool {
+			return live[i].accessedAt.Before(live[j].accessedAt)
+		})
+		target := c.opts.MaxBytes * 9 / 10
+		for _, item := range live {
+			if total <= target {
+				break
+			}
+			if err := bucket.Delete([]byte(item.key)); err != nil {
+				return err
+			}
+			total -= item.size
+			evicted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	c.size = total
+	c.stats.Evicted += evicted
+	c.mutex.Unlock()
+
+	if evicted > 0 {
+		c.logger.Debug("内容缓存已淘汰条目",
+			zap.Int64("evicted", evicted),
+			zap.Int64("size", total))
+	}
+	return nil
+}
+
+// flushTouched 将命中时记录的访问时间写回数据库
+func (c *Cache) flushTouched() error {
+	c.mutex.Lock()
+	touched := c.touched
+	c.touched = make(map[string]time.Time)
+	c.mutex.Unlock()
+
+	if len(touched) == 0 {
+		return nil
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(entriesBucket))
+		if bucket == nil {
+			return ErrClosed
+		}
+		for path, accessedAt := range touched {
+			value := bucket.Get([]byte(path))
+			if value == nil {
+				continue
+			}
+			entry, err := decode(value)
+			if err != nil {
+				continue
+			}
+			entry.AccessedAt = accessedAt
+			encoded, err := c.encode(entry)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(path), encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Cache) encode(entry *Entry) ([]byte, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !c.opts.Compress {
+		return append([]byte{encodingJSON}, data...), nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte(encodingGzip)
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func decode(value []byte) (*Entry, error) {
+	if len(value) == 0 {
+		return nil, errors.New("empty cache entry")
+	}
+
+	data := value[1:]
+	switch value[0] {
+	case encodingJSON:
+	case encodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unknown cache entry encoding")
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
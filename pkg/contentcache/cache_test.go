@@ -0,0 +1,223 @@
+package contentcache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// openTestCache 在临时目录中打开缓存
+func openTestCache(t *testing.T, opts Options) *Cache {
+	t.Helper()
+	opts.Dir = t.TempDir()
+	cache, err := Open(opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// writeSource 写入源文件并返回其大小与修改时间
+func writeSource(t *testing.T, path string, data []byte) (int64, time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stat.Size(), stat.ModTime()
+}
+
+// storeSource 为源文件写入带转换结果的条目
+func storeSource(t *testing.T, cache *Cache, path string, size int64, modTime time.Time) {
+	t.Helper()
+	entry, err := cache.NewEntry(path, size, modTime)
+	if err != nil {
+		t.Fatalf("NewEntry: %v", err)
+	}
+	entry.Outcome = &Outcome{Settings: "s1", Status: StatusConverted, OutputPath: path + ".jxl"}
+	if err := cache.Store(entry); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+}
+
+func TestFingerprintWholeContent(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("pixly"), 100_000) // 约500KB，超过头尾采样范围
+	path := filepath.Join(dir, "a.png")
+	size, _ := writeSource(t, path, data)
+	original, err := Fingerprint(path, size)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	// 只修改中间一个字节，头尾不变
+	modified := append([]byte(nil), data...)
+	modified[len(modified)/2] ^= 0xff
+	other := filepath.Join(dir, "b.png")
+	writeSource(t, other, modified)
+	changed, err := Fingerprint(other, size)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	if changed == original {
+		t.Error("中间内容变化后指纹应不同")
+	}
+
+	same := filepath.Join(dir, "c.png")
+	writeSource(t, same, data)
+	if again, _ := Fingerprint(same, size); again != original {
+		t.Error("相同内容的指纹应一致")
+	}
+	if _, err := Fingerprint(filepath.Join(dir, "missing.png"), 0); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestCacheLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, path string, size int64, modTime time.Time) (int64, time.Time)
+		hit    bool
+	}{
+		{"未变化时命中", func(t *testing.T, path string, size int64, modTime time.Time) (int64, time.Time) {
+			return size, modTime
+		}, true},
+		{"大小变化", func(t *testing.T, path string, size int64, modTime time.Time) (int64, time.Time) {
+			return size + 1, modTime
+		}, false},
+		{"修改时间变化", func(t *testing.T, path string, size int64, modTime time.Time) (int64, time.Time) {
+			return size, modTime.Add(time.Second)
+		}, false},
+		{"内容变化但大小与修改时间不变", func(t *testing.T, path string, size int64, modTime time.Time) (int64, time.Time) {
+			data, _ := os.ReadFile(path)
+			data[len(data)/2] ^= 0xff
+			writeSource(t, path, data)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			return size, modTime
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := openTestCache(t, Options{})
+			path := filepath.Join(t.TempDir(), "photo.png")
+			size, modTime := writeSource(t, path, bytes.Repeat([]byte{1, 2, 3}, 200_000))
+			storeSource(t, cache, path, size, modTime)
+
+			size, modTime = tt.modify(t, path, size, modTime)
+			entry, ok := cache.Lookup(path, size, modTime)
+			if ok != tt.hit {
+				t.Fatalf("Lookup命中 = %v, 期望 %v", ok, tt.hit)
+			}
+			if ok && (entry.Outcome == nil || entry.Outcome.Status != StatusConverted) {
+				t.Errorf("命中的条目 = %+v", entry)
+			}
+			stats := cache.Stats()
+			if stats.Hits+stats.Misses != 1 || (stats.Hits == 1) != tt.hit {
+				t.Errorf("Stats = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestCacheTTLAndEviction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	size, modTime := writeSource(t, path, []byte("content"))
+
+	// 过期条目在查找时未命中，重新打开时被淘汰
+	cache := openTestCache(t, Options{TTL: time.Hour})
+	entry, _ := cache.NewEntry(path, size, modTime)
+	entry.CachedAt = time.Now().Add(-2 * time.Hour)
+	if err := cache.Store(entry); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, ok := cache.Lookup(path, size, modTime); ok {
+		t.Error("过期条目不应命中")
+	}
+	if err := cache.prune(); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if got, _ := cache.get(path); got != nil || cache.Stats().Evicted != 1 {
+		t.Errorf("过期条目应被淘汰，Stats = %+v", cache.Stats())
+	}
+
+	// 超出容量时按访问时间淘汰最久未用的条目
+	small := openTestCache(t, Options{MaxBytes: 1200})
+	var paths []string
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(dir, string(rune('b'+i))+".png")
+		s, m := writeSource(t, p, []byte(p))
+		storeSource(t, small, p, s, m)
+		paths = append(paths, p)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if small.Stats().Evicted == 0 {
+		t.Fatal("超出容量时应淘汰条目")
+	}
+	if got, _ := small.get(paths[0]); got != nil {
+		t.Error("最早写入的条目应被淘汰")
+	}
+	if got, _ := small.get(paths[len(paths)-1]); got == nil {
+		t.Error("最近写入的条目不应被淘汰")
+	}
+}
+
+func TestCacheCompressAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	size, modTime := writeSource(t, path, []byte("content"))
+
+	opts := Options{Dir: filepath.Join(dir, "cache"), Compress: true}
+	cache, err := Open(opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	entry, _ := cache.NewEntry(path, size, modTime)
+	entry.SetMorphology(map[string]string{"type": "static"})
+	entry.SetQuality(nil) // nil不覆盖
+	if err := cache.Store(entry); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	cache.Close()
+
+	reopened, err := Open(opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("重新打开: %v", err)
+	}
+	defer reopened.Close()
+	got, ok := reopened.Lookup(path, size, modTime)
+	if !ok {
+		t.Fatal("重新打开后应命中")
+	}
+	var morphology map[string]string
+	if !got.DecodeMorphology(&morphology) || morphology["type"] != "static" {
+		t.Errorf("形态结果 = %v", morphology)
+	}
+	var quality map[string]string
+	if got.DecodeQuality(&quality) {
+		t.Error("未设置的品质结果不应可解码")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var cache *Cache
+	if _, ok := cache.Lookup("a", 1, time.Now()); ok {
+		t.Error("nil缓存不应命中")
+	}
+	if err := cache.Store(&Entry{}); err != ErrClosed {
+		t.Errorf("Store = %v, 期望 ErrClosed", err)
+	}
+	if cache.Stats() != (Stats{}) || cache.Close() != nil {
+		t.Error("nil缓存的Stats与Close应为空操作")
+	}
+}
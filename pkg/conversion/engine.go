@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	appconfig "pixly/config"
 	"pixly/pkg/core/config"
 	"pixly/pkg/core/state"
 	"pixly/pkg/core/types"
@@ -127,8 +128,8 @@ type EngineConfig struct {
 	DryRun              bool
 }
 
-// NewConversionEngine 创建新的转换引擎
-func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResults types.ToolCheckResults, uiInterface *interactive.Interface) *ConversionEngine {
+// NewConversionEngine 创建新的转换引擎；appCfg为已加载的主配置（含--config与PIXLY_环境变量覆盖）
+func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, appCfg *appconfig.Config, toolResults types.ToolCheckResults, uiInterface *interactive.Interface) *ConversionEngine {
 	engineCfg := &EngineConfig{
 		Mode:                modularCfg.Mode,
 		TargetDir:           modularCfg.TargetDir,
@@ -162,6 +163,7 @@ func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResu
 		toolResults.FfmpegStablePath, // 使用稳定版ffmpeg路径作为ffprobe
 		toolResults.FfmpegStablePath, // 使用稳定版ffmpeg路径
 		false,                        // 非快速模式，进行完整检测
+		nil,                          // 独立的探测缓存：该流程不经过转换器
 	)
 
 	// 创建临时目录用于平衡优化
@@ -169,7 +171,7 @@ func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResu
 	_ = os.MkdirAll(tempDir, 0755) // 忽略错误，如果目录已存在
 
 	// 创建平衡优化器
-	balanceOpt := engine.NewBalanceOptimizer(logger, toolResults, tempDir)
+	balanceOpt := engine.NewBalanceOptimizer(logger, toolResults, tempDir, appCfg)
 
 	// 创建自动模式+路由器
 	autoPlusRtr := engine.NewAutoPlusRouter(logger, qualityEng, balanceOpt, uiInterface, toolResults, modularCfg.DebugMode)
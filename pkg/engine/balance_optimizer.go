@@ -6,10 +6,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	appconfig "pixly/config"
 	"pixly/pkg/core/types"
+	"pixly/pkg/perceptual"
+	"pixly/pkg/qualitysearch"
 
 	"go.uber.org/zap"
 )
@@ -20,6 +24,12 @@ type BalanceOptimizer struct {
 	toolPaths types.ToolCheckResults
 	tempDir   string
 	debugMode bool
+	scorer    *perceptual.Scorer // 感知质量评分器，为nil时不做门限检查
+
+	// 有损参数搜索设置
+	searchGoal      qualitysearch.Goal
+	targetReduction float64 // 体积目标（0-1）
+	maxSearchSteps  int
 }
 
 // OptimizationResult 优化结果
@@ -33,24 +43,69 @@ type OptimizationResult struct {
 	Quality      string // 质量参数
 	ProcessTime  time.Duration
 	Error        error
-}
 
-// OptimizationAttempt 单次优化尝试
-type OptimizationAttempt struct {
-	Method       string // "lossless_repack", "lossless_math", "lossy_high", "lossy_medium", "lossy_low"
-	Format       string // "jxl", "avif", "webp"
-	Parameters   map[string]string
-	ExpectedSize int64 // 预期文件大小（用于快速跳过）
+	// 感知质量评分（仅有损结果）
+	QualityMetric string
+	QualityScore  float64
 }
 
-// NewBalanceOptimizer 创建平衡优化器
-func NewBalanceOptimizer(logger *zap.Logger, toolPaths types.ToolCheckResults, tempDir string) *BalanceOptimizer {
-	return &BalanceOptimizer{
+// NewBalanceOptimizer 创建平衡优化器，感知质量门限（conversion.perceptual）与有损参数搜索（conversion.quality_search）
+// 取自主配置，与主转换器使用相同的指标、下限与体积目标
+func NewBalanceOptimizer(logger *zap.Logger, toolPaths types.ToolCheckResults, tempDir string, cfg *appconfig.Config) *BalanceOptimizer {
+	bo := &BalanceOptimizer{
 		logger:    logger,
 		toolPaths: toolPaths,
 		tempDir:   tempDir,
 		debugMode: os.Getenv("PIXLY_DEBUG") == "true",
 	}
+	bo.applyQualitySearch(cfg)
+	bo.applyPerceptual(cfg)
+	return bo
+}
+
+// SetPerceptualScorer 替换感知质量评分器，传入nil可关闭门限检查
+func (bo *BalanceOptimizer) SetPerceptualScorer(scorer *perceptual.Scorer) {
+	bo.scorer = scorer
+}
+
+// applyQualitySearch 按主配置的有损参数搜索设置搜索目标，未知目标按感知质量处理
+func (bo *BalanceOptimizer) applyQualitySearch(cfg *appconfig.Config) {
+	search := cfg.Conversion.QualitySearch
+	goal, ok := qualitysearch.ParseGoal(search.Target)
+	if !ok {
+		goal = qualitysearch.GoalPerceptual
+	}
+	bo.SetQualitySearch(goal, search.TargetReduction/100, search.MaxSteps)
+}
+
+// applyPerceptual 按主配置的感知质量门限设置评分器，门限关闭时不做评分
+func (bo *BalanceOptimizer) applyPerceptual(cfg *appconfig.Config) {
+	pc := cfg.Conversion.Perceptual
+	if !pc.Enabled {
+		bo.SetPerceptualScorer(nil)
+		return
+	}
+
+	metric, ok := perceptual.ParseMetric(pc.Metric)
+	if !ok {
+		metric = perceptual.MetricMSSSIM
+	}
+	bo.SetPerceptualScorer(perceptual.NewScorer(perceptual.Options{
+		Metric:          metric,
+		FFmpegPath:      bo.toolPaths.FfmpegStablePath,
+		SSIMULACRA2Path: cfg.Tools.Ssimulacra2Path,
+		ButteraugliPath: cfg.Tools.ButteraugliPath,
+		MinSSIM:         pc.MinSSIM,
+		MinSSIMULACRA2:  pc.MinSSIMULACRA2,
+		MaxButteraugli:  pc.MaxButteraugli,
+	}, bo.logger))
+}
+
+// SetQualitySearch 设置有损参数搜索目标：targetReduction为体积减小比例（0-1），maxSteps为单格式最大编码次数
+func (bo *BalanceOptimizer) SetQualitySearch(goal qualitysearch.Goal, targetReduction float64, maxSteps int) {
+	bo.searchGoal = goal
+	bo.targetReduction = targetReduction
+	bo.maxSearchSteps = maxSteps
 }
 
 // OptimizeFile 执行平衡优化 - README要求的核心平衡优化逻辑
@@ -76,7 +131,7 @@ func (bo *BalanceOptimizer) OptimizeFile(ctx context.Context, filePath string, m
 	// README要求的平衡优化步骤：
 	// 1. 无损重新包装优先
 	// 2. 数学无损压缩
-	// 3. 有损探测（多个质量点）
+	// 3. 有损探测（二分搜索达标参数）
 	// 4. 最优选择决策
 
 	// 步骤1: 无损重新包装优先
@@ -128,6 +183,8 @@ func (bo *BalanceOptimizer) OptimizeFile(ctx context.Context, filePath string, m
 			zap.String("file", filepath.Base(filePath)),
 			zap.String("method", bestResult.Method),
 			zap.String("quality", bestResult.Quality),
+			zap.String("quality_metric", bestResult.QualityMetric),
+			zap.Float64("quality_score", bestResult.QualityScore),
 			zap.Int64("original_size", originalSize),
 			zap.Int64("new_size", bestResult.NewSize),
 			zap.Int64("saved", originalSize-bestResult.NewSize))
@@ -138,6 +195,8 @@ func (bo *BalanceOptimizer) OptimizeFile(ctx context.Context, filePath string, m
 		result.SpaceSaved = originalSize - bestResult.NewSize
 		result.Method = bestResult.Method
 		result.Quality = bestResult.Quality
+		result.QualityMetric = bestResult.QualityMetric
+		result.QualityScore = bestResult.QualityScore
 		result.ProcessTime = time.Since(startTime)
 		return result, nil
 	}
@@ -233,114 +292,185 @@ func (bo *BalanceOptimizer) tryJXLMathematicalLossless(ctx context.Context, file
 	return &OptimizationResult{Success: false}
 }
 
-// performMultiPointLossyProbing 执行多点有损探测 - README要求的核心逻辑优化
+// performMultiPointLossyProbing 执行多点有损探测 - 对每种目标格式二分搜索最便宜的达标参数
 func (bo *BalanceOptimizer) performMultiPointLossyProbing(ctx context.Context, filePath string, mediaType types.MediaType, originalSize int64) *OptimizationResult {
-	// README要求：至少分两组尝试多个质量点
-	// 高品质组: 90, 85, 75 - 智能选择策略
-	// 中等品质组: 60, 55 - 激进压缩策略
-
-	// 根据文件大小智能选择质量点组合
+	// 根据文件大小智能选择各格式的搜索区间
 	fileSizeMB := float64(originalSize) / (1024 * 1024)
-	attempts := bo.generateSmartQualityAttempts(mediaType, fileSizeMB)
+	plans := bo.generateSearchPlans(mediaType, fileSizeMB)
 
 	var bestResult *OptimizationResult
-	bestSpaceSaved := int64(0)
 
-	// 按优先级顺序尝试（高品质组优先）
-	for i, attempt := range attempts {
+	for _, plan := range plans {
 		select {
 		case <-ctx.Done():
 			return bestResult
 		default:
 		}
 
-		var result *OptimizationResult
+		result := bo.searchFormat(ctx, filePath, originalSize, plan)
+		if result == nil {
+			continue
+		}
+
+		spaceSaved := originalSize - result.NewSize
+		bo.logger.Debug("有损探测结果",
+			zap.String("file", filepath.Base(filePath)),
+			zap.String("method", result.Method),
+			zap.String("quality", result.Quality),
+			zap.Int64("space_saved", spaceSaved),
+			zap.Float64("compression_ratio", float64(spaceSaved)/float64(originalSize)*100))
+
+		// 各格式的达标结果中选择体积最小的
+		if bestResult == nil || result.NewSize < bestResult.NewSize {
+			if bestResult != nil {
+				os.Remove(bestResult.OutputPath) // 清理之前的结果
+			}
+			bestResult = result
+		} else {
+			os.Remove(result.OutputPath) // 清理较差的结果
+		}
+	}
 
-		switch attempt.Format {
+	return bestResult
+}
+
+// searchFormat 在单一目标格式上二分搜索，返回满足目标的最便宜结果
+func (bo *BalanceOptimizer) searchFormat(ctx context.Context, filePath string, originalSize int64, plan searchPlan) *OptimizationResult {
+	results := make(map[string]*OptimizationResult)
+
+	encode := func(setting int) (qualitysearch.Candidate, error) {
+		params := map[string]string{
+			"quality":  strconv.Itoa(setting),
+			"distance": strconv.FormatFloat(qualitysearch.JXLDistance(setting), 'f', 2, 64),
+			"crf":      strconv.Itoa(qualitysearch.CRF(setting, 63)),
+		}
+
+		var result *OptimizationResult
+		switch plan.Format {
 		case "jxl":
-			result = bo.tryJXLLossyCompression(ctx, filePath, attempt.Parameters)
+			result = bo.tryJXLLossyCompression(ctx, filePath, params)
 		case "avif":
-			result = bo.tryAVIFLossyCompression(ctx, filePath, attempt.Parameters)
+			result = bo.tryAVIFLossyCompression(ctx, filePath, params)
 		case "webp":
-			result = bo.tryWebPLossyCompression(ctx, filePath, attempt.Parameters)
+			result = bo.tryWebPLossyCompression(ctx, filePath, params)
 		default:
-			continue
+			return qualitysearch.Candidate{}, fmt.Errorf("不支持的格式: %s", plan.Format)
+		}
+		if !result.Success {
+			return qualitysearch.Candidate{}, result.Error
 		}
 
-		if result.Success && result.NewSize < originalSize {
-			spaceSaved := originalSize - result.NewSize
-			bo.logger.Debug("有损探测结果",
-				zap.String("file", filepath.Base(filePath)),
-				zap.String("method", attempt.Method),
-				zap.String("quality", attempt.Parameters["quality"]),
-				zap.Int64("space_saved", spaceSaved),
-				zap.Float64("compression_ratio", float64(spaceSaved)/float64(originalSize)*100))
-
-			// README要求：选择体积最小的结果（哪怕仅1KB也算成功）
-			if spaceSaved > bestSpaceSaved {
-				if bestResult != nil {
-					os.Remove(bestResult.OutputPath) // 清理之前的结果
-				}
-				bestResult = result
-				bestResult.Method = attempt.Method
-				bestResult.Quality = attempt.Parameters["quality"]
-				bestSpaceSaved = spaceSaved
-
-				// 高品质组达到满意结果时可以提前结束
-				if i < 3 && spaceSaved > originalSize/4 { // 前3个高品质尝试且节省>25%
-					bo.logger.Debug("高品质组达到满意结果，提前结束探测",
-						zap.String("file", filepath.Base(filePath)),
-						zap.Float64("savings_percent", float64(spaceSaved)/float64(originalSize)*100))
-					break
-				}
-			} else {
-				os.Remove(result.OutputPath) // 清理较差的结果
-			}
+		result.Method = plan.Method
+		result.Quality = params["quality"]
+		results[result.OutputPath] = result
+		return qualitysearch.Candidate{Setting: setting, Path: result.OutputPath, Size: result.NewSize}, nil
+	}
+
+	// 感知目标需要评分器，缺失时退回体积目标
+	goal := bo.searchGoal
+	if goal == qualitysearch.GoalPerceptual && bo.scorer == nil {
+		goal = qualitysearch.GoalSizeReduction
+	}
+
+	meetsSize := qualitysearch.SizeReductionMeets(originalSize, bo.targetReduction)
+	meets := meetsSize
+	if goal == qualitysearch.GoalPerceptual {
+		meets = func(c qualitysearch.Candidate) bool {
+			return c.Size < originalSize && bo.passesPerceptualGate(ctx, filePath, results[c.Path])
 		}
 	}
 
-	return bestResult
+	search, err := qualitysearch.Run(encode, qualitysearch.Options{
+		Min:         plan.Min,
+		Max:         plan.Max,
+		MaxSteps:    bo.maxSearchSteps,
+		PreferLower: goal == qualitysearch.GoalPerceptual,
+		Meets:       meets,
+		Discard: func(c qualitysearch.Candidate) {
+			os.Remove(c.Path)
+			delete(results, c.Path)
+		},
+	})
+	if err != nil || search.Best == nil {
+		return nil
+	}
+
+	best := results[search.Best.Path]
+	bo.logger.Debug("有损参数搜索完成",
+		zap.String("file", filepath.Base(filePath)),
+		zap.String("format", plan.Format),
+		zap.Int("setting", search.Best.Setting),
+		zap.Int("attempts", search.Attempts))
+
+	// 体积目标下仍需通过感知质量门限
+	if goal == qualitysearch.GoalSizeReduction && !bo.passesPerceptualGate(ctx, filePath, best) {
+		os.Remove(best.OutputPath)
+		return nil
+	}
+	return best
 }
 
-// generateSmartQualityAttempts 生成智能质量点尝试组合
-func (bo *BalanceOptimizer) generateSmartQualityAttempts(mediaType types.MediaType, fileSizeMB float64) []OptimizationAttempt {
-	var attempts []OptimizationAttempt
+// passesPerceptualGate 对有损候选评分并写入结果；评分失败时拒绝候选，由无损结果参与最终选择
+func (bo *BalanceOptimizer) passesPerceptualGate(ctx context.Context, filePath string, result *OptimizationResult) bool {
+	if bo.scorer == nil {
+		return true
+	}
 
-	// README要求的高品质组 (90, 85, 75) - 智能调整
-	if mediaType == types.MediaTypeImage {
-		// JXL高品质组 - 根据文件大小调整distance参数
-		if fileSizeMB > 10 { // 大文件使用更保守的参数
-			attempts = append(attempts,
-				OptimizationAttempt{Method: "lossy_jxl_conservative", Format: "jxl", Parameters: map[string]string{"distance": "0.3", "quality": "92"}},
-				OptimizationAttempt{Method: "lossy_jxl_high", Format: "jxl", Parameters: map[string]string{"distance": "0.6", "quality": "88"}},
-				OptimizationAttempt{Method: "lossy_jxl_balanced", Format: "jxl", Parameters: map[string]string{"distance": "1.0", "quality": "80"}},
-			)
-		} else {
-			attempts = append(attempts,
-				OptimizationAttempt{Method: "lossy_jxl_high", Format: "jxl", Parameters: map[string]string{"distance": "0.5", "quality": "90"}},
-				OptimizationAttempt{Method: "lossy_jxl_good", Format: "jxl", Parameters: map[string]string{"distance": "0.8", "quality": "85"}},
-				OptimizationAttempt{Method: "lossy_jxl_balanced", Format: "jxl", Parameters: map[string]string{"distance": "1.2", "quality": "75"}},
-			)
-		}
+	score, err := bo.scorer.Compare(ctx, filePath, result.OutputPath)
+	if err != nil {
+		bo.logger.Warn("感知质量评分失败，拒绝有损候选",
+			zap.String("file", filepath.Base(filePath)),
+			zap.Error(err))
+		return false
+	}
 
-		// AVIF高品质组作为备选
-		attempts = append(attempts,
-			OptimizationAttempt{Method: "lossy_avif_high", Format: "avif", Parameters: map[string]string{"crf": "18", "quality": "88"}},
-			OptimizationAttempt{Method: "lossy_avif_good", Format: "avif", Parameters: map[string]string{"crf": "23", "quality": "80"}},
-		)
+	result.QualityMetric = string(score.Metric)
+	result.QualityScore = score.Value
+
+	if !bo.scorer.Passes(score) {
+		bo.logger.Debug("有损候选未达到感知质量门限",
+			zap.String("file", filepath.Base(filePath)),
+			zap.String("metric", result.QualityMetric),
+			zap.Float64("score", result.QualityScore))
+		return false
+	}
+	return true
+}
+
+// searchPlan 单一目标格式的参数搜索区间（统一质量刻度）
+type searchPlan struct {
+	Method   string
+	Format   string
+	Min, Max int
+}
+
+// generateSearchPlans 生成各目标格式的搜索区间，取代固定质量阶梯
+func (bo *BalanceOptimizer) generateSearchPlans(mediaType types.MediaType, fileSizeMB float64) []searchPlan {
+	var plans []searchPlan
+
+	// 小文件不值得激进压缩，只在高品质区间内搜索
+	minQuality := 75
+	if fileSizeMB > 5 {
+		minQuality = 55
 	}
 
-	// README要求的中等品质组 (60, 55) - 激进压缩
-	if fileSizeMB > 5 { // 大文件才使用激进压缩
-		attempts = append(attempts,
-			OptimizationAttempt{Method: "lossy_jxl_aggressive", Format: "jxl", Parameters: map[string]string{"distance": "2.0", "quality": "60"}},
-			OptimizationAttempt{Method: "lossy_jxl_extreme", Format: "jxl", Parameters: map[string]string{"distance": "2.5", "quality": "55"}},
-			OptimizationAttempt{Method: "lossy_avif_aggressive", Format: "avif", Parameters: map[string]string{"crf": "35", "quality": "60"}},
-			OptimizationAttempt{Method: "lossy_webp_aggressive", Format: "webp", Parameters: map[string]string{"quality": "60"}},
+	if mediaType == types.MediaTypeImage {
+		maxQuality := 95
+		if fileSizeMB > 10 { // 大文件使用更保守的上限起点
+			maxQuality = 92
+		}
+		plans = append(plans,
+			searchPlan{Method: "lossy_jxl_search", Format: "jxl", Min: minQuality, Max: maxQuality},
+			searchPlan{Method: "lossy_avif_search", Format: "avif", Min: minQuality, Max: 90},
+		)
+	} else if fileSizeMB > 5 {
+		plans = append(plans,
+			searchPlan{Method: "lossy_avif_search", Format: "avif", Min: minQuality, Max: 90},
+			searchPlan{Method: "lossy_webp_search", Format: "webp", Min: minQuality, Max: 90},
 		)
 	}
 
-	return attempts
+	return plans
 }
 
 // tryWebPLossyCompression 尝试WebP有损压缩
@@ -404,13 +534,13 @@ func (bo *BalanceOptimizer) tryJXLLossyCompression(ctx context.Context, filePath
 // tryAVIFLossyCompression 尝试AVIF有损压缩
 func (bo *BalanceOptimizer) tryAVIFLossyCompression(ctx context.Context, filePath string, params map[string]string) *OptimizationResult {
 	outputPath := bo.generateTempPath(filePath, ".avif")
-	quality := params["quality"]
+	crf := params["crf"]
 
 	// 使用FFmpeg进行AVIF压缩
 	cmd := exec.CommandContext(ctx, bo.toolPaths.FfmpegStablePath,
 		"-i", filePath,
 		"-c:v", "libaom-av1",
-		"-crf", quality,
+		"-crf", crf,
 		"-cpu-used", "6", // 平衡速度和质量
 		"-y",
 		outputPath)
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	appconfig "pixly/config"
 	"pixly/pkg/core/config"
 	"pixly/pkg/core/state"
 	"pixly/pkg/core/types"
@@ -144,8 +145,8 @@ type EngineConfig struct {
 	DryRun              bool
 }
 
-// NewConversionEngine 创建新的转换引擎
-func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResults types.ToolCheckResults, uiInterface *interactive.Interface) *ConversionEngine {
+// NewConversionEngine 创建新的转换引擎；appCfg为已加载的主配置（含--config与PIXLY_环境变量覆盖）
+func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, appCfg *appconfig.Config, toolResults types.ToolCheckResults, uiInterface *interactive.Interface) *ConversionEngine {
 	engineCfg := &EngineConfig{
 		Mode:                modularCfg.Mode,
 		TargetDir:           modularCfg.TargetDir,
@@ -171,6 +172,7 @@ func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResu
 		toolResults.FfmpegStablePath, // 使用稳定版ffmpeg路径作为ffprobe
 		toolResults.FfmpegStablePath, // 使用稳定版ffmpeg路径
 		false,                        // 非快速模式，进行完整检测
+		nil,                          // 独立的探测缓存：该流程不经过转换器
 	)
 
 	// 创建临时目录用于平衡优化
@@ -178,7 +180,7 @@ func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResu
 	os.MkdirAll(tempDir, 0755)
 
 	// 创建平衡优化器
-	balanceOpt := NewBalanceOptimizer(logger, toolResults, tempDir)
+	balanceOpt := NewBalanceOptimizer(logger, toolResults, tempDir, appCfg)
 
 	// 创建自动模式+路由器
 	autoPlusRtr := NewAutoPlusRouter(logger, qualityEng, balanceOpt, uiInterface, toolResults, false)
@@ -187,7 +189,7 @@ func NewConversionEngine(logger *zap.Logger, modularCfg *config.Config, toolResu
 	progressMgr := progress.NewProgressManager(logger)
 
 	// 创建进程监控器（README要求的防卡死机制）
-	procMonitor := processmonitor.NewProcessMonitor(logger)
+	procMonitor := processmonitor.NewProcessMonitor(logger, uiInterface == nil)
 
 	// 设置缓存目录
 	cacheDir := filepath.Join(modularCfg.TargetDir, ".pixly_cache")
@@ -317,10 +319,40 @@ func (e *ConversionEngine) executeConversionPipeline(ctx context.Context) error
 		e.logger.Warn("保存会话信息失败", zap.Error(err))
 	}
 
+	routedTasks, decisions, corrupted, err := e.routePipeline(pipelineCtx)
+	if err != nil {
+		return err
+	}
+
+	// 干运行：只输出计划，不修改任何文件
+	if e.config.DryRun {
+		e.printPlan(buildPlan(e.config.TargetDir, e.config.Mode, routedTasks, decisions, corrupted))
+		return nil
+	}
+	e.deleteRoutedFiles(decisions)
+
+	// 步骤4: 执行转换
+	results := e.executeConversion(pipelineCtx, routedTasks)
+
+	// 步骤5: 生成报告
+	e.generateReport(results)
+
+	// 清理平衡优化器临时文件
+	e.CleanupBalanceOptimizer()
+
+	e.logger.Info("转换管道执行完成")
+	return nil
+}
+
+// routePipeline 扫描、评估并路由目标目录中的文件，不修改任何文件；转换与计划共用。
+// 返回路由后的任务、自动模式+的路由决策（其他模式为nil）与评估为损坏的文件
+func (e *ConversionEngine) routePipeline(ctx context.Context) ([]ConversionTask, map[string]*types.RoutingDecision, []string, error) {
+	var decisions map[string]*types.RoutingDecision
+
 	// 步骤1: 扫描文件
 	mediaFiles, err := e.scanDirectory(e.config.TargetDir)
 	if err != nil {
-		return fmt.Errorf("文件扫描失败: %w", err)
+		return nil, nil, nil, fmt.Errorf("文件扫描失败: %w", err)
 	}
 
 	// 将 []*types.MediaInfo 转换为 []string
@@ -329,16 +361,18 @@ func (e *ConversionEngine) executeConversionPipeline(ctx context.Context) error
 		files = append(files, mediaFile.Path)
 	}
 
-	// 保存扫描的文件信息
+	// 保存扫描的文件信息（生成计划时不打开状态管理器）
 	// 注意：这里需要将 []*types.MediaInfo 转换为合适的格式
-	if err := e.stateManager.SaveMediaFiles(mediaFiles); err != nil {
-		e.logger.Warn("保存媒体文件信息失败", zap.Error(err))
+	if e.stateManager != nil {
+		if err := e.stateManager.SaveMediaFiles(mediaFiles); err != nil {
+			e.logger.Warn("保存媒体文件信息失败", zap.Error(err))
+		}
 	}
 
 	if len(files) == 0 {
 		e.logger.Info("未发现需要处理的媒体文件")
 		fmt.Println("📄 未发现需要处理的媒体文件")
-		return nil
+		return nil, nil, nil, nil
 	}
 
 	e.logger.Info("文件扫描完成", zap.Int("total_files", len(files)))
@@ -351,9 +385,9 @@ func (e *ConversionEngine) executeConversionPipeline(ctx context.Context) error
 		mediaInfoFiles = append(mediaInfoFiles, &types.MediaInfo{Path: file})
 	}
 	
-	tasks, _, _, err := e.assessFiles(files)
+	tasks, corruptedFiles, _, err := e.assessFiles(files)
 	if err != nil {
-		return fmt.Errorf("文件评估失败: %w", err)
+		return nil, nil, nil, fmt.Errorf("文件评估失败: %w", err)
 	}
 
 	// 步骤2.6: 使用自动模式+路由器处理智能路由（仅在自动模式+时）
@@ -367,10 +401,11 @@ func (e *ConversionEngine) executeConversionPipeline(ctx context.Context) error
 		}
 
 		// 执行智能路由
-		routingDecisions, _, err := e.autoPlusRouter.RouteFiles(pipelineCtx, filePaths)
+		routingDecisions, _, err := e.autoPlusRouter.RouteFiles(ctx, filePaths)
 		if err != nil {
-			return fmt.Errorf("自动模式+路由失败: %w", err)
+			return nil, nil, nil, fmt.Errorf("自动模式+路由失败: %w", err)
 		}
+		decisions = routingDecisions
 
 		// 根据路由决策更新任务
 		tasks = e.applyRoutingDecisions(tasks, routingDecisions)
@@ -389,17 +424,7 @@ func (e *ConversionEngine) executeConversionPipeline(ctx context.Context) error
 	routedTasks := e.routeTasks(tasks)
 	e.logger.Info("任务路由完成", zap.Int("routed_tasks", len(routedTasks)))
 
-	// 步骤4: 执行转换
-	results := e.executeConversion(pipelineCtx, routedTasks)
-
-	// 步骤5: 生成报告
-	e.generateReport(results)
-
-	// 清理平衡优化器临时文件
-	e.CleanupBalanceOptimizer()
-
-	e.logger.Info("转换管道执行完成")
-	return nil
+	return routedTasks, decisions, corruptedFiles, nil
 }
 
 // scanDirectory 扫描目录获取文件列表
@@ -915,8 +940,8 @@ func (e *ConversionEngine) processTask(ctx context.Context, task ConversionTask)
 		return result
 	}
 
-	// 如果是调试模式或干运行模式，只模拟处理
-	if e.config.DebugMode || e.config.DryRun {
+	// 调试模式只模拟处理（干运行在路由后输出计划，不会执行到这里）
+	if e.config.DebugMode {
 		e.logger.Info("模拟转换模式",
 			zap.String("file", filepath.Base(task.SourcePath)),
 			zap.String("target_format", task.TargetFormat))
@@ -2032,9 +2057,7 @@ func (e *ConversionEngine) applyRoutingDecisions(tasks []ConversionTask, decisio
 				// 跳过此任务
 				continue
 			case "delete":
-				// 删除文件并跳过任务
-				os.Remove(task.SourcePath)
-				e.logger.Info("删除低品质文件", zap.String("file", filepath.Base(task.SourcePath)))
+				// 由deleteRoutedFiles删除，计划模式下不修改文件
 				continue
 			default:
 				// 更新任务的目标格式
@@ -2051,6 +2074,20 @@ func (e *ConversionEngine) applyRoutingDecisions(tasks []ConversionTask, decisio
 	return updatedTasks
 }
 
+// deleteRoutedFiles 删除自动模式+路由为删除的低品质文件
+func (e *ConversionEngine) deleteRoutedFiles(decisions map[string]*types.RoutingDecision) {
+	for path, decision := range decisions {
+		if decision.Strategy != "delete" {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			e.logger.Warn("删除低品质文件失败", zap.String("file", filepath.Base(path)), zap.Error(err))
+			continue
+		}
+		e.logger.Info("删除低品质文件", zap.String("file", filepath.Base(path)))
+	}
+}
+
 // performBalanceOptimization 执行平衡优化 - 集成README要求的完整平衡优化逻辑
 func (e *ConversionEngine) performBalanceOptimization(ctx context.Context, task ConversionTask) error {
 	e.logger.Debug("开始平衡优化", zap.String("file", filepath.Base(task.SourcePath)))
@@ -2091,6 +2128,8 @@ func (e *ConversionEngine) performBalanceOptimization(ctx context.Context, task
 		zap.String("file", filepath.Base(task.SourcePath)),
 		zap.String("method", result.Method),
 		zap.String("quality", result.Quality),
+		zap.String("quality_metric", result.QualityMetric),
+		zap.Float64("quality_score", result.QualityScore),
 		zap.Int64("space_saved", result.SpaceSaved),
 		zap.Duration("process_time", result.ProcessTime))
 
@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"pixly/pkg/core/types"
+
+	"go.uber.org/zap"
+)
+
+// PlanVersion 引擎计划格式版本
+const PlanVersion = 1
+
+// 计划中的处理策略
+const (
+	PlanConvert = "convert"
+	PlanSkip    = "skip"
+	PlanDelete  = "delete"
+)
+
+// Plan 引擎转换计划：执行与转换相同的扫描、品质评估与路由，但不修改任何文件；ApplyPlan按原样执行
+type Plan struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	TargetDir string      `json:"target_dir"`
+	Mode      string      `json:"mode"`
+	Entries   []PlanEntry `json:"entries"`
+	Summary   PlanSummary `json:"summary"`
+}
+
+// PlanEntry 单个文件的计划
+type PlanEntry struct {
+	Path          string    `json:"path"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mod_time"`
+	MediaType     string    `json:"media_type,omitempty"`
+	QualityLevel  string    `json:"quality_level,omitempty"`
+	Strategy      string    `json:"strategy"`
+	TargetFormat  string    `json:"target_format,omitempty"`
+	EstimatedSize int64     `json:"estimated_size"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+// PlanSummary 计划汇总
+type PlanSummary struct {
+	TotalFiles    int   `json:"total_files"`
+	ConvertFiles  int   `json:"convert_files"`
+	SkipFiles     int   `json:"skip_files"`
+	DeleteFiles   int   `json:"delete_files"`
+	OriginalSize  int64 `json:"original_size"`
+	EstimatedSize int64 `json:"estimated_size"`
+}
+
+// estimatedRatios 各目标格式的经验体积比例（输出/输入），与主转换器的计划预估一致
+var estimatedRatios = map[string]float64{
+	"jxl_lossless":    0.8,
+	"jxl_balanced":    0.6,
+	"avif_balanced":   0.6,
+	"avif_compressed": 0.35,
+	"remux":           1.0,
+	"jxl":             0.8, // 自动模式+路由器的目标格式
+	"avif":            0.6,
+	"mov":             1.0,
+}
+
+// estimateSize 按目标格式的经验比例预估输出体积，未知格式按原大小
+func estimateSize(targetFormat string, size int64) int64 {
+	ratio, ok := estimatedRatios[targetFormat]
+	if !ok {
+		ratio = 1.0
+	}
+	return int64(float64(size) * ratio)
+}
+
+// Plan 生成转换计划：扫描、评估并路由目标目录中的文件，不转换、不删除任何文件
+func (e *ConversionEngine) Plan(ctx context.Context) (*Plan, error) {
+	if err := e.validateConfig(); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	tasks, decisions, corrupted, err := e.routePipeline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := buildPlan(e.config.TargetDir, e.config.Mode, tasks, decisions, corrupted)
+
+	e.logger.Info("转换计划生成完成",
+		zap.Int("total", plan.Summary.TotalFiles),
+		zap.Int("convert", plan.Summary.ConvertFiles),
+		zap.Int("skip", plan.Summary.SkipFiles),
+		zap.Int("delete", plan.Summary.DeleteFiles))
+	return plan, nil
+}
+
+// buildPlan 由路由后的任务、自动模式+的路由决策与损坏文件生成计划
+func buildPlan(targetDir, mode string, tasks []ConversionTask, decisions map[string]*types.RoutingDecision, corrupted []string) *Plan {
+	plan := &Plan{
+		Version:   PlanVersion,
+		CreatedAt: time.Now(),
+		TargetDir: targetDir,
+		Mode:      mode,
+	}
+
+	for _, task := range tasks {
+		entry := newPlanEntry(task.SourcePath)
+		entry.MediaType = task.MediaType
+		entry.QualityLevel = task.Quality
+		entry.Strategy = PlanConvert
+		entry.TargetFormat = task.TargetFormat
+		entry.Reason = "按" + mode + "模式路由"
+		if decision := decisions[task.SourcePath]; decision != nil && decision.Reason != "" {
+			entry.Reason = decision.Reason
+		}
+		if task.TargetFormat == "skip" {
+			entry.Strategy, entry.TargetFormat = PlanSkip, ""
+			entry.Reason = "根据模式配置跳过处理"
+		}
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	// 自动模式+路由为跳过或删除的文件不在任务中，单独列出
+	for path, decision := range decisions {
+		if decision.Strategy != PlanSkip && decision.Strategy != PlanDelete {
+			continue
+		}
+		entry := newPlanEntry(path)
+		entry.QualityLevel = decision.QualityLevel.String()
+		entry.Strategy = decision.Strategy
+		entry.Reason = decision.Reason
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	for _, path := range corrupted {
+		entry := newPlanEntry(path)
+		entry.Strategy = PlanSkip
+		entry.Reason = "文件已损坏"
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		switch entry.Strategy {
+		case PlanConvert:
+			entry.EstimatedSize = estimateSize(entry.TargetFormat, entry.Size)
+		case PlanSkip:
+			entry.EstimatedSize = entry.Size
+		}
+	}
+
+	sort.Slice(plan.Entries, func(i, j int) bool {
+		return plan.Entries[i].Path < plan.Entries[j].Path
+	})
+	plan.summarize()
+	return plan
+}
+
+// newPlanEntry 读取文件大小与修改时间创建计划条目，ApplyPlan据此判断文件是否变更
+func newPlanEntry(path string) PlanEntry {
+	entry := PlanEntry{Path: path}
+	if stat, err := os.Stat(path); err == nil {
+		entry.Size = stat.Size()
+		entry.ModTime = stat.ModTime()
+	}
+	return entry
+}
+
+// summarize 汇总计划
+func (p *Plan) summarize() {
+	summary := PlanSummary{TotalFiles: len(p.Entries)}
+	for _, entry := range p.Entries {
+		summary.OriginalSize += entry.Size
+		summary.EstimatedSize += entry.EstimatedSize
+		switch entry.Strategy {
+		case PlanConvert:
+			summary.ConvertFiles++
+		case PlanDelete:
+			summary.DeleteFiles++
+		default:
+			summary.SkipFiles++
+		}
+	}
+	p.Summary = summary
+}
+
+// ApplyPlan 按计划执行：直接使用计划中的目标格式与策略而不重新评估路由，计划生成后变更过的文件不处理
+func (e *ConversionEngine) ApplyPlan(ctx context.Context, plan *Plan) error {
+	if plan.Version != PlanVersion {
+		return fmt.Errorf("不支持的计划版本: %d", plan.Version)
+	}
+	if plan.Mode != e.config.Mode {
+		return fmt.Errorf("计划模式与当前模式不一致: %s", plan.Mode)
+	}
+	if err := e.performPreflightChecks(); err != nil {
+		return fmt.Errorf("预检失败: %w", err)
+	}
+
+	// 初始化状态管理器（转换时读取ICC配置）
+	if e.stateManager == nil {
+		e.InitStateManager()
+	}
+	defer e.stateManager.Close()
+
+	var tasks []ConversionTask
+	var results []ConversionResult
+	for _, entry := range plan.Entries {
+		if entry.Strategy == PlanSkip {
+			results = append(results, skippedResult(entry.Path, entry.Reason))
+			continue
+		}
+		if stat, err := os.Stat(entry.Path); err != nil || stat.Size() != entry.Size || !stat.ModTime().Equal(entry.ModTime) {
+			e.logger.Warn("文件自计划生成后已变更，跳过", zap.String("file", entry.Path))
+			results = append(results, skippedResult(entry.Path, "文件自计划生成后已变更"))
+			continue
+		}
+
+		switch entry.Strategy {
+		case PlanDelete:
+			if err := os.Remove(entry.Path); err != nil {
+				e.logger.Warn("删除文件失败", zap.String("file", entry.Path), zap.Error(err))
+				continue
+			}
+			e.logger.Info("按计划删除文件", zap.String("file", filepath.Base(entry.Path)), zap.String("reason", entry.Reason))
+		case PlanConvert:
+			tasks = append(tasks, ConversionTask{
+				SourcePath:   entry.Path,
+				TargetFormat: entry.TargetFormat,
+				Mode:         plan.Mode,
+				Status:       "pending",
+				Quality:      entry.QualityLevel,
+				MediaType:    entry.MediaType,
+			})
+		}
+	}
+
+	results = append(results, e.executeConversion(ctx, tasks)...)
+	e.generateReport(results)
+	e.CleanupBalanceOptimizer()
+	return nil
+}
+
+// skippedResult 计划中跳过的文件的结果
+func skippedResult(path, reason string) ConversionResult {
+	now := time.Now()
+	return ConversionResult{
+		SourcePath: path,
+		Status:     "skipped",
+		Message:    reason,
+		StartTime:  now,
+		EndTime:    now,
+	}
+}
+
+// printPlan 以表格形式输出计划
+func (e *ConversionEngine) printPlan(plan *Plan) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "文件\t类型\t品质\t策略\t目标格式\t原始大小\t预估大小\t原因")
+	for _, entry := range plan.Entries {
+		path := entry.Path
+		if rel, err := filepath.Rel(plan.TargetDir, entry.Path); err == nil {
+			path = rel
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			path, orDash(entry.MediaType), orDash(entry.QualityLevel), entry.Strategy, orDash(entry.TargetFormat),
+			e.formatBytes(entry.Size), e.formatBytes(entry.EstimatedSize), entry.Reason)
+	}
+	writer.Flush()
+
+	summary := plan.Summary
+	fmt.Printf("\n📋 共 %d 个文件：转换 %d，跳过 %d，删除 %d；预估 %s → %s\n",
+		summary.TotalFiles, summary.ConvertFiles, summary.SkipFiles, summary.DeleteFiles,
+		e.formatBytes(summary.OriginalSize), e.formatBytes(summary.EstimatedSize))
+}
+
+// orDash 空值显示为-
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"pixly/pkg/core/types"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		format string
+		want   int64
+	}{
+		{"jxl_lossless", 800},
+		{"jxl_balanced", 600},
+		{"avif_balanced", 600},
+		{"avif_compressed", 350},
+		{"remux", 1000},
+		{"jxl", 800},
+		{"avif", 600},
+		{"mov", 1000},
+		{"unknown", 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := estimateSize(tt.format, 1000); got != tt.want {
+				t.Errorf("estimateSize(%q) = %d, 期望 %d", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+// writePlanFile 在目录中写入指定大小的文件并返回路径
+func writePlanFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildPlan(t *testing.T) {
+	dir := t.TempDir()
+	png := writePlanFile(t, dir, "a.png", 1000)
+	jpg := writePlanFile(t, dir, "b.jpg", 2000)
+	gif := writePlanFile(t, dir, "c.gif", 500)
+	low := writePlanFile(t, dir, "d.jpg", 300)
+	broken := writePlanFile(t, dir, "e.png", 100)
+	skipped := writePlanFile(t, dir, "f.webp", 400)
+
+	tasks := []ConversionTask{
+		{SourcePath: png, TargetFormat: "jxl", MediaType: "image", Quality: "高品质"},
+		{SourcePath: jpg, TargetFormat: "avif", MediaType: "image"},
+		{SourcePath: gif, TargetFormat: "skip", MediaType: "image"},
+	}
+	decisions := map[string]*types.RoutingDecision{
+		png:     {Strategy: PlanConvert, TargetFormat: "jxl", QualityLevel: types.QualityHigh, Reason: "高品质无损转换"},
+		low:     {Strategy: PlanDelete, QualityLevel: types.QualityVeryLow, Reason: "极低品质文件"},
+		skipped: {Strategy: PlanSkip, QualityLevel: types.QualityHigh, Reason: "已是高效格式"},
+	}
+
+	plan := buildPlan(dir, "auto+", tasks, decisions, []string{broken})
+
+	want := []struct {
+		path          string
+		strategy      string
+		targetFormat  string
+		estimatedSize int64
+		reason        string
+	}{
+		{png, PlanConvert, "jxl", 800, "高品质无损转换"},
+		{jpg, PlanConvert, "avif", 1200, "按auto+模式路由"},
+		{gif, PlanSkip, "", 500, "根据模式配置跳过处理"},
+		{low, PlanDelete, "", 0, "极低品质文件"},
+		{broken, PlanSkip, "", 100, "文件已损坏"},
+		{skipped, PlanSkip, "", 400, "已是高效格式"},
+	}
+	if len(plan.Entries) != len(want) {
+		t.Fatalf("计划条目数 = %d, 期望 %d", len(plan.Entries), len(want))
+	}
+	for i, w := range want {
+		entry := plan.Entries[i]
+		if entry.Path != w.path || entry.Strategy != w.strategy || entry.TargetFormat != w.targetFormat ||
+			entry.EstimatedSize != w.estimatedSize || entry.Reason != w.reason {
+			t.Errorf("条目 %d = %+v, 期望 %+v", i, entry, w)
+		}
+		if entry.ModTime.IsZero() {
+			t.Errorf("条目 %s 缺少修改时间", entry.Path)
+		}
+	}
+	if plan.Entries[3].QualityLevel != types.QualityVeryLow.String() {
+		t.Errorf("删除条目的品质 = %q, 期望 %q", plan.Entries[3].QualityLevel, types.QualityVeryLow.String())
+	}
+
+	wantSummary := PlanSummary{
+		TotalFiles:    6,
+		ConvertFiles:  2,
+		SkipFiles:     3,
+		DeleteFiles:   1,
+		OriginalSize:  4300,
+		EstimatedSize: 3000,
+	}
+	if plan.Summary != wantSummary {
+		t.Errorf("Summary = %+v, 期望 %+v", plan.Summary, wantSummary)
+	}
+	if plan.Version != PlanVersion || plan.Mode != "auto+" || plan.TargetDir != dir {
+		t.Errorf("计划头 = %d %q %q", plan.Version, plan.Mode, plan.TargetDir)
+	}
+}
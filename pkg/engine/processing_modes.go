@@ -54,8 +54,8 @@ type ProcessingStrategy struct {
 	Reason         string                 `json:"reason"`
 }
 
-// NewProcessingModeManager 创建处理模式管理器
-func NewProcessingModeManager(logger *zap.Logger, toolPaths types.ToolCheckResults, qualityEngine *quality.QualityEngine) *ProcessingModeManager {
+// NewProcessingModeManager 创建处理模式管理器，nonInteractive 传给各模式的进程监控器
+func NewProcessingModeManager(logger *zap.Logger, toolPaths types.ToolCheckResults, qualityEngine *quality.QualityEngine, nonInteractive bool) *ProcessingModeManager {
 	manager := &ProcessingModeManager{
 		logger:        logger,
 		toolPaths:     toolPaths,
@@ -63,9 +63,9 @@ func NewProcessingModeManager(logger *zap.Logger, toolPaths types.ToolCheckResul
 	}
 
 	// 初始化三大处理模式
-	manager.autoPlusMode = NewAutoPlusMode(logger, toolPaths, qualityEngine)
-	manager.qualityMode = NewQualityMode(logger, toolPaths)
-	manager.emojiMode = NewEmojiMode(logger, toolPaths)
+	manager.autoPlusMode = NewAutoPlusMode(logger, toolPaths, qualityEngine, nonInteractive)
+	manager.qualityMode = NewQualityMode(logger, toolPaths, nonInteractive)
+	manager.emojiMode = NewEmojiMode(logger, toolPaths, nonInteractive)
 
 	return manager
 }
@@ -161,14 +161,14 @@ type AutoPlusMode struct {
 }
 
 // NewAutoPlusMode 创建自动模式+实例
-func NewAutoPlusMode(logger *zap.Logger, toolPaths types.ToolCheckResults, qualityEngine *quality.QualityEngine) *AutoPlusMode {
+func NewAutoPlusMode(logger *zap.Logger, toolPaths types.ToolCheckResults, qualityEngine *quality.QualityEngine, nonInteractive bool) *AutoPlusMode {
 	return &AutoPlusMode{
 		logger:           logger,
 		toolPaths:        toolPaths,
 		qualityEngine:    qualityEngine,
-		qualityMode:      NewQualityMode(logger, toolPaths),
-		emojiMode:        NewEmojiMode(logger, toolPaths),
-		conversionEngine: NewSimpleConverter(logger, toolPaths),
+		qualityMode:      NewQualityMode(logger, toolPaths, nonInteractive),
+		emojiMode:        NewEmojiMode(logger, toolPaths, nonInteractive),
+		conversionEngine: NewSimpleConverter(logger, toolPaths, nonInteractive),
 	}
 }
 
@@ -557,11 +557,11 @@ type QualityMode struct {
 }
 
 // NewQualityMode 创建品质模式实例
-func NewQualityMode(logger *zap.Logger, toolPaths types.ToolCheckResults) *QualityMode {
+func NewQualityMode(logger *zap.Logger, toolPaths types.ToolCheckResults, nonInteractive bool) *QualityMode {
 	return &QualityMode{
 		logger:           logger,
 		toolPaths:        toolPaths,
-		conversionEngine: NewSimpleConverter(logger, toolPaths),
+		conversionEngine: NewSimpleConverter(logger, toolPaths, nonInteractive),
 	}
 }
 
@@ -707,11 +707,11 @@ type EmojiMode struct {
 }
 
 // NewEmojiMode 创建表情包模式实例
-func NewEmojiMode(logger *zap.Logger, toolPaths types.ToolCheckResults) *EmojiMode {
+func NewEmojiMode(logger *zap.Logger, toolPaths types.ToolCheckResults, nonInteractive bool) *EmojiMode {
 	return &EmojiMode{
 		logger:           logger,
 		toolPaths:        toolPaths,
-		conversionEngine: NewSimpleConverter(logger, toolPaths),
+		conversionEngine: NewSimpleConverter(logger, toolPaths, nonInteractive),
 	}
 }
 
@@ -4,14 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"pixly/pkg/core/types"
+	"pixly/pkg/jpegquality"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -29,6 +29,8 @@ type QualityEngine struct {
 	ffprobePath string      // ffprobe可执行文件路径，用于深度媒体分析
 	ffmpegPath  string      // ffmpeg可执行文件路径，备用工具
 	fastMode    bool        // 快速模式：true=跳过深度分析，false=启用5%深度验证
+
+	prober *mediaprobe.Prober // 共享探测缓存，同一文件的头部与ffprobe结果只生成一次
 }
 
 // QualityAssessment 品质评估结果
@@ -60,18 +62,23 @@ type QualityAssessment struct {
 //   - ffprobePath: ffprobe工具路径，用于深度验证（可为空，但会影响准确性）
 //   - ffmpegPath: ffmpeg工具路径，备用工具（可为空）
 //   - fastMode: 是否启用快速模式，true=仅预判，false=双阶段分析
+//   - prober: 共享探测缓存（如转换器的缓存），与扫描和转换阶段复用同一份探测结果；为nil时创建独立的缓存
 //
 // 返回配置完成的品质判断引擎实例
-func NewQualityEngine(logger *zap.Logger, ffprobePath, ffmpegPath string, fastMode bool) *QualityEngine {
+func NewQualityEngine(logger *zap.Logger, ffprobePath, ffmpegPath string, fastMode bool, prober *mediaprobe.Prober) *QualityEngine {
 	if logger == nil {
 		panic("QualityEngine: logger不能为nil")
 	}
+	if prober == nil {
+		prober = mediaprobe.NewProber(ffprobePath, "")
+	}
 
 	return &QualityEngine{
 		logger:      logger,
 		ffprobePath: ffprobePath,
 		ffmpegPath:  ffmpegPath,
 		fastMode:    fastMode,
+		prober:      prober,
 	}
 }
 
@@ -185,6 +192,10 @@ func (qe *QualityEngine) performQuickPreAssessment(assessment *QualityAssessment
 	switch ext {
 	case ".jpg", ".jpeg":
 		assessment.Format = "jpeg"
+		// 优先读取量化表获得真实编码品质（只解析文件头，开销与大小判断相当）
+		if qe.applyJpegHeader(assessment, filePath) {
+			break
+		}
 		// JPEG文件的快速品质预判 - 降低敏感度
 		if fileSizeMB > 3 {
 			assessment.Score = 0.8 // 预估高品质
@@ -305,8 +316,14 @@ func (qe *QualityEngine) needsDeepVerification(assessment *QualityAssessment) bo
 	return false
 }
 
-// performDeepVerification 执行深度验证 - 仅对5%可疑文件使用ffmpeg
+// performDeepVerification 执行深度验证 - 图像优先解析容器头部，其余5%可疑文件使用ffmpeg
 func (qe *QualityEngine) performDeepVerification(ctx context.Context, assessment *QualityAssessment, filePath string) error {
+	if info, err := qe.prober.Get(filePath).Header(); err == nil {
+		qe.applyHeaderInfo(assessment, info)
+		assessment.Confidence = 0.95
+		return nil
+	}
+
 	// 使用 ffprobe 获取精确媒体信息
 	mediaInfo, err := qe.getMediaInfoWithFFprobe(ctx, filePath)
 	if err != nil {
@@ -344,59 +361,38 @@ func (qe *QualityEngine) determineMediaTypeFromExtension(ext string) types.Media
 
 // getMediaInfoWithFFprobe 使用 ffprobe 获取精确媒体信息（仅用于深度验证）
 func (qe *QualityEngine) getMediaInfoWithFFprobe(ctx context.Context, filePath string) (map[string]interface{}, error) {
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
-	}
-
-	cmd := exec.CommandContext(ctx, qe.ffprobePath, args...)
-	output, err := cmd.Output()
+	probeData, err := qe.prober.Get(filePath).FFprobe(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("ffprobe 执行失败: %w", err)
+		return nil, err
 	}
 
-	// 简化的JSON解析 - 在实际实现中应使用 json.Unmarshal
 	info := make(map[string]interface{})
 
-	// 解析关键信息（简化版本）
-	outputStr := string(output)
-
-	// 提取宽度和高度
-	if width := qe.extractNumber(outputStr, `"width":\s*(\d+)`); width > 0 {
-		info["width"] = width
-	}
-	if height := qe.extractNumber(outputStr, `"height":\s*(\d+)`); height > 0 {
-		info["height"] = height
+	// 宽高、帧率与编解码器取自第一个视频流
+	if video := probeData.VideoStream(); video != nil {
+		if video.Width > 0 && video.Height > 0 {
+			info["width"] = video.Width
+			info["height"] = video.Height
+		}
+		if frameRate := video.FrameRate(); frameRate > 0 {
+			info["frame_rate"] = frameRate
+		}
+		if video.CodecName != "" {
+			info["codec"] = video.CodecName
+		}
 	}
 
-	// 提取时长
-	if duration := qe.extractFloat(outputStr, `"duration":\s*"([0-9.]+)"`); duration > 0 {
+	// 时长、格式与比特率取自容器
+	if duration := probeData.Duration(); duration > 0 {
 		info["duration"] = duration
 	}
-
-	// 提取格式
-	if format := qe.extractString(outputStr, `"format_name":\s*"([^"]+)"`); format != "" {
-		info["format"] = format
+	if probeData.Format.FormatName != "" {
+		info["format"] = probeData.Format.FormatName
 	}
-
-	// 提取比特率
-	if bitRate := qe.extractNumber(outputStr, `"bit_rate":\s*"(\d+)"`); bitRate > 0 {
+	if bitRate, err := strconv.ParseInt(probeData.Format.BitRate, 10, 64); err == nil && bitRate > 0 {
 		info["bit_rate"] = bitRate
 	}
 
-	// 提取帧率
-	if frameRate := qe.extractFloat(outputStr, `"avg_frame_rate":\s*"([0-9.]+)"`); frameRate > 0 {
-		info["frame_rate"] = frameRate
-	}
-
-	// 提取编解码器
-	if codec := qe.extractString(outputStr, `"codec_name":\s*"([^"]+)"`); codec != "" {
-		info["codec"] = codec
-	}
-
 	return info, nil
 }
 
@@ -446,6 +442,26 @@ func (qe *QualityEngine) parseAndUpdateMediaInfo(assessment *QualityAssessment,
 	return nil
 }
 
+// applyHeaderInfo 使用容器头部信息更新评估：尺寸、像素密度与动静类型
+func (qe *QualityEngine) applyHeaderInfo(assessment *QualityAssessment, info *mediaprobe.Info) {
+	assessment.Width = info.Width
+	assessment.Height = info.Height
+	if fileSizeMB := float64(assessment.FileSize) / (1024 * 1024); fileSizeMB > 0 {
+		assessment.PixelDensity = float64(info.Width*info.Height) / (fileSizeMB * 1000000) // 像素/MB
+	}
+
+	if info.Animated {
+		assessment.MediaType = types.MediaTypeAnimated
+	} else {
+		assessment.MediaType = types.MediaTypeImage
+	}
+
+	if info.Format == "jpeg" && assessment.JpegQuality == 0 {
+		assessment.JpegQuality = qe.estimateJpegQuality(assessment)
+	}
+	assessment.Details["header"] = info
+}
+
 // determineMediaTypeFromFFprobe 基于ffprobe结果确定媒体类型
 func (qe *QualityEngine) determineMediaTypeFromFFprobe(assessment *QualityAssessment) types.MediaType {
 	format := strings.ToLower(assessment.Format)
@@ -753,8 +769,39 @@ func (qe *QualityEngine) recommendMode(assessment *QualityAssessment) {
 	}
 }
 
-// estimateJpegQuality 估算JPEG品质
+// applyJpegHeader 解析JPEG量化表，填充编码品质与尺寸并据此预判品质；解析失败时返回false
+func (qe *QualityEngine) applyJpegHeader(assessment *QualityAssessment, filePath string) bool {
+	info, err := jpegquality.AnalyzeFile(filePath)
+	if err != nil || info.Quality == 0 {
+		return false
+	}
+
+	assessment.JpegQuality = info.Quality
+	assessment.Width = info.Width
+	assessment.Height = info.Height
+	if fileSizeMB := float64(assessment.FileSize) / (1024 * 1024); fileSizeMB > 0 {
+		assessment.PixelDensity = float64(info.Width*info.Height) / (fileSizeMB * 1000000)
+	}
+
+	assessment.Score = float64(info.Quality) / 100
+	if info.Resaved {
+		assessment.Score -= 0.05 // 重新编码的文件已损失细节
+	}
+	assessment.Confidence = 0.95 // 量化表是编码器写入的真实参数
+
+	if assessment.Details == nil {
+		assessment.Details = make(map[string]interface{})
+	}
+	assessment.Details["jpeg"] = info
+	return true
+}
+
+// estimateJpegQuality 估算JPEG品质：优先解析量化表，失败时按每像素字节数估算
 func (qe *QualityEngine) estimateJpegQuality(assessment *QualityAssessment) int {
+	if info, err := jpegquality.AnalyzeFile(assessment.FilePath); err == nil && info.Quality > 0 {
+		return info.Quality
+	}
+
 	// 简化的JPEG品质估算基于文件大小和分辨率
 	if assessment.Width == 0 || assessment.Height == 0 || assessment.FileSize == 0 {
 		return 0
@@ -811,37 +858,6 @@ func (qe *QualityEngine) BatchAssess(ctx context.Context, filePaths []string, ca
 }
 
 // 辅助函数
-func (qe *QualityEngine) extractNumber(text, pattern string) int {
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		if num, err := strconv.Atoi(matches[1]); err == nil {
-			return num
-		}
-	}
-	return 0
-}
-
-func (qe *QualityEngine) extractFloat(text, pattern string) float64 {
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		if num, err := strconv.ParseFloat(matches[1], 64); err == nil {
-			return num
-		}
-	}
-	return 0
-}
-
-func (qe *QualityEngine) extractString(text, pattern string) string {
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
-
 func max(a, b float64) float64 {
 	if a > b {
 		return a
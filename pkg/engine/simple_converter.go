@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"pixly/pkg/core/types"
+	"pixly/pkg/mediaprobe"
 	"pixly/pkg/processmonitor"
 
 	"go.uber.org/zap"
@@ -26,11 +27,25 @@ type SimpleConverter struct {
 // NewSimpleConverter 创建简化转换器的新实例。
 // nonInteractive 参数用于控制进程监控器是否以非交互模式运行。
 func NewSimpleConverter(logger *zap.Logger, toolPaths types.ToolCheckResults, nonInteractive bool) *SimpleConverter {
+	monitor := processmonitor.NewProcessMonitor(logger, nonInteractive) // 传入 nonInteractive 标志。
+
+	// 超时按本次运行累积的耗时样本估算；该流程没有状态数据库，样本不跨运行保存。
+	monitor.SetTimingModel(processmonitor.NewTimingModel(logger))
+
 	return &SimpleConverter{
 		logger:         logger,
 		toolPaths:      toolPaths,
-		processMonitor: processmonitor.NewProcessMonitor(logger, nonInteractive), // 传入 nonInteractive 标志。
+		processMonitor: monitor,
+	}
+}
+
+// imageWorkload 返回图像的像素数与帧数，供进程监控按耗时模型估算超时；无法解析头部时像素数为0。
+func imageWorkload(path string) (int64, int) {
+	info, err := mediaprobe.ProbeFile(path)
+	if err != nil {
+		return 0, 0
 	}
+	return int64(info.Width) * int64(info.Height), max(info.FrameCount, 1)
 }
 
 // ConvertToJXL 将源文件转换为 JXL 格式。
@@ -52,6 +67,7 @@ func (sc *SimpleConverter) ConvertToJXL(ctx context.Context, sourcePath, targetP
 	var args []string
 	args = append(args, sourcePath, targetPath)
 
+	effort := 8
 	if lossless {
 		// 无损模式：根据源文件类型选择不同的无损参数。
 		ext := strings.ToLower(filepath.Ext(sourcePath))
@@ -62,13 +78,15 @@ func (sc *SimpleConverter) ConvertToJXL(ctx context.Context, sourcePath, targetP
 			// 其他格式无损模式：使用 -q 100 (最高质量)。
 			args = append(args, "-q", "100")
 		}
-		args = append(args, "-e", "7") // 适中的努力值，平衡压缩时间和文件大小。
+		effort = 7 // 适中的努力值，平衡压缩时间和文件大小。
+		args = append(args, "-e", fmt.Sprintf("%d", effort))
 	} else {
 		// 平衡模式（有损）：使用 -q 85 (中等质量) 和 -e 8 (较高努力值)。
-		args = append(args, "-q", "85", "-e", "8")
+		args = append(args, "-q", "85", "-e", fmt.Sprintf("%d", effort))
 	}
 
 	// 创建进程上下文，用于进程监控。
+	pixels, frames := imageWorkload(sourcePath)
 	processCtx := &processmonitor.ProcessContext{
 		Operation:       "jxl_conversion",
 		SourceFile:      sourcePath,
@@ -77,6 +95,10 @@ func (sc *SimpleConverter) ConvertToJXL(ctx context.Context, sourcePath, targetP
 		ComplexityLevel: processmonitor.ComplexityMedium,
 		Priority:        processmonitor.PriorityNormal,
 		Metadata:        map[string]string{"lossless": fmt.Sprintf("%t", lossless)},
+		Encoder:         "cjxl",
+		Effort:          effort,
+		Pixels:          pixels,
+		Frames:          frames,
 	}
 
 	// 执行 cjxl 转换命令。
@@ -132,6 +154,7 @@ func (sc *SimpleConverter) ConvertToAVIF(ctx context.Context, sourcePath, target
 
 	var tool string
 	var args []string
+	var speed int
 
 	// 优先使用 avifenc 处理静态图片，因为它通常对静态图片有更好的优化。
 	if sc.toolPaths.HasAvifenc && mediaType == types.MediaTypeImage {
@@ -141,11 +164,14 @@ func (sc *SimpleConverter) ConvertToAVIF(ctx context.Context, sourcePath, target
 		// 根据模式设置 avifenc 参数。
 		switch mode {
 		case "compressed":
-			args = append(args, "-q", "50", "-s", "6") // 较低质量，较快速度。
+			speed = 6 // 较低质量，较快速度。
+			args = append(args, "-q", "50", "-s", "6")
 		case "balanced":
-			args = append(args, "-q", "35", "-s", "8") // 平衡质量和速度。
+			speed = 8 // 平衡质量和速度。
+			args = append(args, "-q", "35", "-s", "8")
 		default: // 默认为 "lossless" 或其他未指定模式。
-			args = append(args, "-q", "25", "-s", "10") // 较高质量，较慢速度。
+			speed = 10 // 较高质量，较慢速度。
+			args = append(args, "-q", "25", "-s", "10")
 		}
 	} else if sc.toolPaths.HasFfmpeg {
 		// 回退到 FFmpeg 处理，尤其适用于动图和 avifenc 不可用的情况。
@@ -161,6 +187,7 @@ func (sc *SimpleConverter) ConvertToAVIF(ctx context.Context, sourcePath, target
 	}
 
 	// 创建进程上下文，用于进程监控。
+	pixels, frames := imageWorkload(sourcePath)
 	processCtx := &processmonitor.ProcessContext{
 		Operation:       "avif_conversion",
 		SourceFile:      sourcePath,
@@ -169,6 +196,10 @@ func (sc *SimpleConverter) ConvertToAVIF(ctx context.Context, sourcePath, target
 		ComplexityLevel: processmonitor.ComplexityHigh, // AVIF 转换通常复杂度较高。
 		Priority:        processmonitor.PriorityNormal,
 		Metadata:        map[string]string{"tool": tool, "mode": mode},
+		Encoder:         tool,
+		Effort:          speed,
+		Pixels:          pixels,
+		Frames:          frames,
 	}
 
 	// 执行转换命令。
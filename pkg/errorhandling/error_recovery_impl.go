@@ -2,20 +2,28 @@ package errorhandling
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"math"
-	"os"
-	"path/filepath"
-	"strings"
+	"net"
+	"os/exec"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// NewRetryManager 创建重试管理器
-func NewRetryManager(logger *zap.Logger) *RetryManager {
+// NewRetryManager 创建重试管理器，config提供退避参数，为nil时使用默认配置
+func NewRetryManager(logger *zap.Logger, config *RecoveryConfig) *RetryManager {
+	if config == nil {
+		config = DefaultRecoveryConfig()
+	}
 	return &RetryManager{
 		logger:        logger,
+		config:        config,
+		operations:    make(map[string]RetryableOperation),
 		activeRetries: make(map[string]*RetryContext),
 		retryStats:    make(map[ErrorType]*RetryStatistics),
 	}
@@ -25,11 +33,40 @@ func NewRetryManager(logger *zap.Logger) *RetryManager {
 func NewFallbackManager(logger *zap.Logger) *FallbackManager {
 	return &FallbackManager{
 		logger:         logger,
+		handlers:       make(map[FallbackType]FallbackHandler),
 		fallbackChains: make(map[string][]FallbackAction),
 		fallbackStats:  make(map[FallbackType]*FallbackStatistics),
 	}
 }
 
+// RegisterOperation 注册可重试的操作
+func (rm *RetryManager) RegisterOperation(operationID string, operation RetryableOperation) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.operations[operationID] = operation
+}
+
+// UnregisterOperation 移除已注册的操作
+func (rm *RetryManager) UnregisterOperation(operationID string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	delete(rm.operations, operationID)
+}
+
+// HasOperation 操作是否已注册
+func (rm *RetryManager) HasOperation(operationID string) bool {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.operations[operationID] != nil
+}
+
+// RegisterHandler 注册后备类型的处理器
+func (fm *FallbackManager) RegisterHandler(fallbackType FallbackType, handler FallbackHandler) {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	fm.handlers[fallbackType] = handler
+}
+
 // initializeDefaultStrategies 初始化默认恢复策略
 func (erm *ErrorRecoveryManager) initializeDefaultStrategies() {
 	// 文件损坏错误策略
@@ -170,42 +207,64 @@ func (erm *ErrorRecoveryManager) initializeStatistics() {
 	}
 }
 
-// analyzeError 分析错误类型和严重程度
-func (erm *ErrorRecoveryManager) analyzeError(err error) (ErrorType, ErrorSeverity) {
-	errorMessage := strings.ToLower(err.Error())
-
-	// 错误模式匹配
-	patterns := map[string]struct {
-		errorType ErrorType
-		severity  ErrorSeverity
-	}{
-		"corrupted":     {ErrorTypeFileCorrupted, SeverityHigh},
-		"invalid":       {ErrorTypeFormatUnsupported, SeverityMedium},
-		"unsupported":   {ErrorTypeFormatUnsupported, SeverityMedium},
-		"out of memory": {ErrorTypeMemoryExhausted, SeverityCritical},
-		"memory":        {ErrorTypeMemoryExhausted, SeverityHigh},
-		"timeout":       {ErrorTypeProcessTimeout, SeverityMedium},
-		"deadline":      {ErrorTypeProcessTimeout, SeverityMedium},
-		"permission":    {ErrorTypePermissionDenied, SeverityHigh},
-		"access denied": {ErrorTypePermissionDenied, SeverityHigh},
-		"network":       {ErrorTypeNetworkFailure, SeverityMedium},
-		"connection":    {ErrorTypeNetworkFailure, SeverityMedium},
-		"ffmpeg":        {ErrorTypeFFmpegCrash, SeverityHigh},
-		"metadata":      {ErrorTypeMetadataCorrupted, SeverityLow},
-		"quality":       {ErrorTypeQualityTooLow, SeverityLow},
-		"too large":     {ErrorTypeFileTooLarge, SeverityMedium},
-		"file size":     {ErrorTypeFileTooLarge, SeverityMedium},
-	}
-
-	for pattern, info := range patterns {
-		if strings.Contains(errorMessage, pattern) {
-			return info.errorType, info.severity
-		}
+// ClassifiedError 调用方已确定恢复分类的错误，analyzeError 直接采用其类型与严重程度
+type ClassifiedError struct {
+	Err      error
+	Type     ErrorType
+	Severity ErrorSeverity
+}
+
+func (e *ClassifiedError) Error() string { return e.Err.Error() }
+
+func (e *ClassifiedError) Unwrap() error { return e.Err }
+
+// Classify 为错误标记恢复分类，err为nil时返回nil
+func Classify(err error, errorType ErrorType, severity ErrorSeverity) error {
+	if err == nil {
+		return nil
 	}
+	return &ClassifiedError{Err: err, Type: errorType, Severity: severity}
+}
 
+// analyzeError 按错误链中的类型化错误判断错误类型和严重程度，不解析错误消息文本
+func (erm *ErrorRecoveryManager) analyzeError(err error) (ErrorType, ErrorSeverity) {
+	var (
+		classified *ClassifiedError
+		exitErr    *exec.ExitError
+		netErr     net.Error
+	)
+	switch {
+	case errors.As(err, &classified):
+		return classified.Type, classified.Severity
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeProcessTimeout, SeverityMedium
+	case errors.Is(err, syscall.ENOMEM):
+		return ErrorTypeMemoryExhausted, SeverityCritical
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorTypePermissionDenied, SeverityHigh
+	case errors.Is(err, syscall.EFBIG):
+		return ErrorTypeFileTooLarge, SeverityMedium
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorTypeFileCorrupted, SeverityHigh
+	case errors.As(err, &exitErr):
+		return analyzeExitError(exitErr)
+	case errors.As(err, &netErr):
+		return ErrorTypeNetworkFailure, SeverityMedium
+	}
 	return ErrorTypeUnknown, SeverityMedium
 }
 
+// analyzeExitError 外部工具的退出状态：SIGKILL多来自OOM killer，其他信号视为工具崩溃，非零退出码为工具处理失败
+func analyzeExitError(exitErr *exec.ExitError) (ErrorType, ErrorSeverity) {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		if status.Signal() == syscall.SIGKILL {
+			return ErrorTypeMemoryExhausted, SeverityHigh
+		}
+		return ErrorTypeFFmpegCrash, SeverityHigh
+	}
+	return ErrorTypeFFmpegCrash, SeverityMedium
+}
+
 // handleCriticalError 处理关键错误
 func (erm *ErrorRecoveryManager) handleCriticalError(ctx context.Context, errorRecord *ErrorRecord) (*RecoveryResult, error) {
 	erm.logger.Error("检测到关键错误",
@@ -223,31 +282,26 @@ func (erm *ErrorRecoveryManager) handleCriticalError(ctx context.Context, errorR
 		}, fmt.Errorf("关键错误导致操作中止: %s", errorRecord.ErrorMessage)
 
 	case ActionSkip:
+		// 跳过只是放弃处理，不计为恢复成功
 		return &RecoveryResult{
-			Success:      true,
+			Success:      false,
 			Strategy:     "critical_skip",
 			ActionsTaken: []string{"跳过文件"},
 			FinalState:   "文件跳过",
 		}, nil
 
-	case ActionFallback:
-		return erm.fallbackRecovery(ctx, errorRecord)
-
 	default:
 		return erm.fallbackRecovery(ctx, errorRecord)
 	}
 }
 
-// fallbackRecovery 后备恢复方案
-func (erm *ErrorRecoveryManager) fallbackRecovery(ctx context.Context, errorRecord *ErrorRecord) (*RecoveryResult, error) {
-	erm.logger.Debug("执行后备恢复方案", zap.String("error_id", errorRecord.ID))
-
-	// 通用后备动作
-	fallbackActions := []FallbackAction{
+// genericFallbackActions 通用后备动作：降低品质、保留原文件，最后跳过
+func genericFallbackActions() []FallbackAction {
+	return []FallbackAction{
 		{
 			Type:        FallbackLowerQuality,
 			Name:        "降级品质处理",
-			Description: "使用最低品质设置进行处理",
+			Description: "使用更低的品质设置进行处理",
 			Priority:    1,
 			Enabled:     true,
 		},
@@ -266,298 +320,251 @@ func (erm *ErrorRecoveryManager) fallbackRecovery(ctx context.Context, errorReco
 			Enabled:     true,
 		},
 	}
+}
 
-	result, err := erm.fallbackManager.ExecuteFallback(ctx, fallbackActions, errorRecord)
-	if err != nil {
-		return &RecoveryResult{
-			Success:      false,
-			Strategy:     "fallback_recovery",
-			ActionsTaken: []string{"后备恢复失败"},
-			FinalState:   "恢复失败",
-		}, err
+// mergeFallbackActions 在策略的后备动作之后追加尚未出现的通用动作，同一类型只执行一次
+func mergeFallbackActions(actions, generic []FallbackAction) []FallbackAction {
+	seen := make(map[FallbackType]bool, len(actions))
+	merged := make([]FallbackAction, 0, len(actions)+len(generic))
+	for _, action := range append(append([]FallbackAction{}, actions...), generic...) {
+		if seen[action.Type] {
+			continue
+		}
+		seen[action.Type] = true
+		merged = append(merged, action)
 	}
-
-	return &RecoveryResult{
-		Success:      result.Success,
-		Strategy:     "fallback_recovery",
-		ActionsTaken: []string{fmt.Sprintf("后备方案: %s", result.FallbackType.String())},
-		TimeTaken:    result.TimeTaken,
-		FinalState:   "后备恢复",
-		Details:      result.Details,
-	}, nil
+	return merged
 }
 
-// AttemptRetry 尝试重试
-func (rm *RetryManager) AttemptRetry(ctx context.Context, errorRecord *ErrorRecord, strategy *RecoveryStrategy) (bool, error) {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
+// fallbackRecovery 后备恢复方案
+func (erm *ErrorRecoveryManager) fallbackRecovery(ctx context.Context, errorRecord *ErrorRecord) (*RecoveryResult, error) {
+	erm.logger.Debug("执行后备恢复方案", zap.String("error_id", errorRecord.ID))
 
-	retryCtx, exists := rm.activeRetries[errorRecord.ID]
-	if !exists {
-		retryCtx = &RetryContext{
-			OperationID:    errorRecord.ID,
-			ErrorType:      errorRecord.ErrorType,
-			CurrentAttempt: 0,
-			MaxAttempts:    strategy.MaxAttempts,
-			BackoffDelay:   strategy.RetryDelay,
-		}
-		rm.activeRetries[errorRecord.ID] = retryCtx
+	if !erm.config.EnableFallback {
+		return &RecoveryResult{
+			Success:    false,
+			Strategy:   "fallback_recovery",
+			FinalState: "恢复失败",
+		}, fmt.Errorf("后备方案已禁用")
 	}
+	result, err := erm.fallbackManager.ExecuteFallback(ctx, genericFallbackActions(), errorRecord)
+	return fallbackRecoveryResult("fallback_recovery", nil, result), err
+}
 
-	retryCtx.CurrentAttempt++
-	retryCtx.LastAttempt = time.Now()
+// fallbackRecoveryResult 由后备结果生成恢复结果：跳过文件不算恢复成功
+func fallbackRecoveryResult(strategy string, actions []string, fallback *FallbackResult) *RecoveryResult {
+	result := &RecoveryResult{
+		Strategy:     strategy,
+		ActionsTaken: actions,
+		FinalState:   "恢复失败",
+	}
+	if fallback == nil {
+		return result
+	}
+	result.Details = fallback.Details
+	switch {
+	case fallback.Success && fallback.FallbackType == FallbackSkipFile:
+		result.ActionsTaken = append(result.ActionsTaken, "跳过文件")
+		result.FinalState = "文件跳过"
+	case fallback.Success:
+		result.Success = true
+		result.Fallback = fallback
+		result.ActionsTaken = append(result.ActionsTaken, fmt.Sprintf("后备方案: %s", fallback.FallbackType.String()))
+		result.FinalState = "后备方案解决"
+	default:
+		result.ActionsTaken = append(result.ActionsTaken, "后备方案均失败")
+	}
+	return result
+}
+
+// retryAttempts 策略的重试次数，不超过配置的最大重试次数
+func (erm *ErrorRecoveryManager) retryAttempts(strategy *RecoveryStrategy) int {
+	attempts := strategy.MaxAttempts
+	if erm.config.MaxRetries < attempts {
+		attempts = erm.config.MaxRetries
+	}
+	return attempts
+}
 
-	if retryCtx.CurrentAttempt > retryCtx.MaxAttempts {
+// AttemptRetry 重新执行错误记录对应的已注册操作，最多attempts次，每次之前按指数退避等待。
+// 任一次成功即返回true；重试次数写入errorRecord.RetryCount
+func (rm *RetryManager) AttemptRetry(ctx context.Context, errorRecord *ErrorRecord, strategy *RecoveryStrategy, attempts int) (bool, error) {
+	rm.mutex.Lock()
+	operation := rm.operations[errorRecord.Operation]
+	retryCtx := &RetryContext{
+		OperationID:   errorRecord.ID,
+		ErrorType:     errorRecord.ErrorType,
+		MaxAttempts:   attempts,
+		OriginalError: errors.New(errorRecord.ErrorMessage),
+	}
+	rm.activeRetries[errorRecord.ID] = retryCtx
+	rm.mutex.Unlock()
+
+	defer func() {
+		rm.mutex.Lock()
 		delete(rm.activeRetries, errorRecord.ID)
-		return false, fmt.Errorf("超过最大重试次数: %d", retryCtx.MaxAttempts)
+		rm.mutex.Unlock()
+	}()
+
+	if operation == nil {
+		return false, fmt.Errorf("操作未注册，无法重试: %s", errorRecord.Operation)
 	}
 
-	// 计算退避延迟
-	if retryCtx.CurrentAttempt > 1 {
-		backoffDelay := time.Duration(float64(strategy.RetryDelay) * math.Pow(2.0, float64(retryCtx.CurrentAttempt-1)))
-		if backoffDelay > 30*time.Second {
-			backoffDelay = 30 * time.Second
-		}
-		retryCtx.BackoffDelay = backoffDelay
-		retryCtx.NextAttempt = time.Now().Add(backoffDelay)
+	startTime := time.Now()
+	var totalDelay time.Duration
+	lastErr := retryCtx.OriginalError
+	for attempt := 1; attempt <= attempts; attempt++ {
+		delay := rm.retryDelay(strategy, attempt)
+		rm.mutex.Lock()
+		retryCtx.CurrentAttempt = attempt
+		retryCtx.BackoffDelay = delay
+		retryCtx.NextAttempt = time.Now().Add(delay)
+		rm.mutex.Unlock()
 
 		rm.logger.Debug("重试延迟等待",
 			zap.String("operation_id", errorRecord.ID),
-			zap.Int("attempt", retryCtx.CurrentAttempt),
-			zap.Duration("delay", backoffDelay))
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay))
 
 		select {
-		case <-time.After(backoffDelay):
+		case <-time.After(delay):
 		case <-ctx.Done():
+			rm.updateRetryStats(errorRecord.ErrorType, attempt-1, false, totalDelay, time.Since(startTime))
 			return false, ctx.Err()
 		}
-	}
-
-	rm.logger.Info("开始重试操作",
-		zap.String("operation_id", errorRecord.ID),
-		zap.Int("attempt", retryCtx.CurrentAttempt),
-		zap.Int("max_attempts", retryCtx.MaxAttempts))
-
-	// 模拟重试逻辑（实际应该调用具体的重试操作）
-	success := rm.simulateRetry(ctx, errorRecord)
+		totalDelay += delay
 
-	// 更新统计信息
-	rm.updateRetryStats(errorRecord.ErrorType, retryCtx.CurrentAttempt, success)
+		rm.logger.Info("开始重试操作",
+			zap.String("operation_id", errorRecord.ID),
+			zap.String("operation", errorRecord.Operation),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", attempts))
+
+		rm.mutex.Lock()
+		retryCtx.LastAttempt = time.Now()
+		rm.mutex.Unlock()
+		errorRecord.RetryCount = attempt
+
+		lastErr = operation(ctx)
+		if lastErr == nil {
+			rm.updateRetryStats(errorRecord.ErrorType, attempt, true, totalDelay, time.Since(startTime))
+			rm.logger.Info("重试成功",
+				zap.String("operation_id", errorRecord.ID),
+				zap.Int("attempt", attempt))
+			return true, nil
+		}
 
-	if success {
-		delete(rm.activeRetries, errorRecord.ID)
-		rm.logger.Info("重试成功",
+		rm.logger.Debug("重试失败",
 			zap.String("operation_id", errorRecord.ID),
-			zap.Int("attempt", retryCtx.CurrentAttempt))
-		return true, nil
+			zap.Int("attempt", attempt),
+			zap.Error(lastErr))
 	}
 
-	rm.logger.Debug("重试失败",
-		zap.String("operation_id", errorRecord.ID),
-		zap.Int("attempt", retryCtx.CurrentAttempt))
-
-	return false, fmt.Errorf("重试失败，尝试次数: %d", retryCtx.CurrentAttempt)
+	rm.updateRetryStats(errorRecord.ErrorType, attempts, false, totalDelay, time.Since(startTime))
+	return false, fmt.Errorf("重试%d次均失败: %w", attempts, lastErr)
 }
 
-// simulateRetry 模拟重试逻辑
-func (rm *RetryManager) simulateRetry(ctx context.Context, errorRecord *ErrorRecord) bool {
-	// 根据错误类型模拟不同的重试成功率
-	successRates := map[ErrorType]float64{
-		ErrorTypeFileCorrupted:     0.2, // 20%成功率
-		ErrorTypeFormatUnsupported: 0.1, // 10%成功率
-		ErrorTypeMemoryExhausted:   0.3, // 30%成功率
-		ErrorTypeProcessTimeout:    0.5, // 50%成功率
-		ErrorTypePermissionDenied:  0.8, // 80%成功率
-		ErrorTypeNetworkFailure:    0.7, // 70%成功率
-		ErrorTypeFFmpegCrash:       0.4, // 40%成功率
-		ErrorTypeMetadataCorrupted: 0.6, // 60%成功率
-		ErrorTypeQualityTooLow:     0.9, // 90%成功率
-		ErrorTypeFileTooLarge:      0.3, // 30%成功率
-		ErrorTypeUnknown:           0.2, // 20%成功率
+// retryDelay 第attempt次重试前的等待时间：策略延迟（未设置时为基础延迟）按退避倍数递增，不超过最大延迟
+func (rm *RetryManager) retryDelay(strategy *RecoveryStrategy, attempt int) time.Duration {
+	base := strategy.RetryDelay
+	if base <= 0 {
+		base = rm.config.BaseRetryDelay
 	}
-
-	successRate, exists := successRates[errorRecord.ErrorType]
-	if !exists {
-		successRate = 0.2
+	multiplier := rm.config.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = 1
 	}
-
-	// 模拟重试操作的执行时间
-	time.Sleep(100 * time.Millisecond)
-
-	// 基于成功率随机决定是否成功
-	return time.Now().UnixNano()%100 < int64(successRate*100)
+	delay := time.Duration(float64(base) * math.Pow(multiplier, float64(attempt-1)))
+	if rm.config.MaxRetryDelay > 0 && delay > rm.config.MaxRetryDelay {
+		delay = rm.config.MaxRetryDelay
+	}
+	return delay
 }
 
-// ExecuteFallback 执行后备方案
+// ExecuteFallback 按顺序执行后备动作，直到一个成功；跳过文件总能成功，作为链的终点
 func (fm *FallbackManager) ExecuteFallback(ctx context.Context, actions []FallbackAction, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	fm.mutex.Lock()
-	defer fm.mutex.Unlock()
-
 	startTime := time.Now()
 
+	var lastErr error
 	for _, action := range actions {
 		if !action.Enabled {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			lastErr = err
+			break
+		}
 
 		fm.logger.Debug("执行后备动作",
 			zap.String("error_id", errorRecord.ID),
 			zap.String("action_type", action.Type.String()),
 			zap.String("action_name", action.Name))
 
+		actionStart := time.Now()
 		result, err := fm.executeFallbackAction(ctx, &action, errorRecord)
-		if err == nil && result.Success {
-			fm.updateFallbackStats(action.Type, true, time.Since(startTime))
+		if err == nil && result != nil && result.Success {
+			result.FallbackType = action.Type
+			result.TimeTaken = time.Since(actionStart)
+			fm.updateFallbackStats(action.Type, true, result.TimeTaken)
 			return result, nil
 		}
+		if err == nil {
+			err = fmt.Errorf("后备动作未成功: %s", action.Name)
+		}
+		lastErr = err
 
-		fm.updateFallbackStats(action.Type, false, time.Since(startTime))
+		fm.updateFallbackStats(action.Type, false, time.Since(actionStart))
 		fm.logger.Debug("后备动作失败",
 			zap.String("error_id", errorRecord.ID),
 			zap.String("action_type", action.Type.String()),
 			zap.Error(err))
 	}
 
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用的后备动作")
+	}
 	return &FallbackResult{
 		Success:      false,
 		FallbackType: FallbackSkipFile,
 		TimeTaken:    time.Since(startTime),
 		Details:      map[string]interface{}{"reason": "所有后备方案都失败"},
-	}, fmt.Errorf("所有后备方案都失败")
+	}, fmt.Errorf("所有后备方案都失败: %w", lastErr)
 }
 
-// executeFallbackAction 执行具体的后备动作
+// executeFallbackAction 执行具体的后备动作：动作自带处理器优先，其次是按类型注册的处理器；
+// 跳过文件不需要处理器，其余类型没有处理器时失败
 func (fm *FallbackManager) executeFallbackAction(ctx context.Context, action *FallbackAction, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	startTime := time.Now()
-
-	switch action.Type {
-	case FallbackLowerQuality:
-		return fm.executeLowerQualityFallback(ctx, errorRecord)
-
-	case FallbackDifferentFormat:
-		return fm.executeDifferentFormatFallback(ctx, errorRecord)
-
-	case FallbackSimpleConversion:
-		return fm.executeSimpleConversionFallback(ctx, errorRecord)
-
-	case FallbackCopyOriginal:
-		return fm.executeCopyOriginalFallback(ctx, errorRecord)
-
-	case FallbackSkipFile:
-		return &FallbackResult{
-			Success:      true,
-			FallbackType: FallbackSkipFile,
-			OutputPath:   "",
-			QualityLevel: "skipped",
-			TimeTaken:    time.Since(startTime),
-			Details:      map[string]interface{}{"action": "文件已跳过"},
-		}, nil
-
-	default:
-		return nil, fmt.Errorf("未知的后备动作类型: %v", action.Type)
+	handler := action.Handler
+	if handler == nil {
+		fm.mutex.RLock()
+		handler = fm.handlers[action.Type]
+		fm.mutex.RUnlock()
+	}
+
+	if handler == nil {
+		if action.Type == FallbackSkipFile {
+			return &FallbackResult{
+				Success:      true,
+				FallbackType: FallbackSkipFile,
+				QualityLevel: "skipped",
+				Details:      map[string]interface{}{"action": "文件已跳过"},
+			}, nil
+		}
+		return nil, fmt.Errorf("未注册后备处理器: %s", action.Type.String())
 	}
-}
-
-// executeLowerQualityFallback 执行降低品质后备方案
-func (fm *FallbackManager) executeLowerQualityFallback(ctx context.Context, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	// 模拟降低品质处理
-	time.Sleep(200 * time.Millisecond)
 
-	outputPath := strings.Replace(errorRecord.SourceFile, filepath.Ext(errorRecord.SourceFile), "_lowquality"+filepath.Ext(errorRecord.SourceFile), 1)
-
-	return &FallbackResult{
-		Success:      true,
-		FallbackType: FallbackLowerQuality,
-		OutputPath:   outputPath,
-		QualityLevel: "low",
-		TimeTaken:    200 * time.Millisecond,
-		Details: map[string]interface{}{
-			"quality_reduction": "50%",
-			"original_file":     errorRecord.SourceFile,
-		},
-	}, nil
-}
-
-// executeDifferentFormatFallback 执行不同格式后备方案
-func (fm *FallbackManager) executeDifferentFormatFallback(ctx context.Context, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	// 模拟格式转换
-	time.Sleep(300 * time.Millisecond)
-
-	baseName := strings.TrimSuffix(errorRecord.SourceFile, filepath.Ext(errorRecord.SourceFile))
-	outputPath := baseName + ".jpg" // 转换为通用JPEG格式
-
-	return &FallbackResult{
-		Success:      true,
-		FallbackType: FallbackDifferentFormat,
-		OutputPath:   outputPath,
-		QualityLevel: "standard",
-		TimeTaken:    300 * time.Millisecond,
-		Details: map[string]interface{}{
-			"target_format":   "jpeg",
-			"original_format": filepath.Ext(errorRecord.SourceFile),
-		},
-	}, nil
-}
-
-// executeSimpleConversionFallback 执行简单转换后备方案
-func (fm *FallbackManager) executeSimpleConversionFallback(ctx context.Context, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	// 模拟简单转换
-	time.Sleep(150 * time.Millisecond)
-
-	outputPath := strings.Replace(errorRecord.SourceFile, filepath.Ext(errorRecord.SourceFile), "_simple"+filepath.Ext(errorRecord.SourceFile), 1)
-
-	return &FallbackResult{
-		Success:      true,
-		FallbackType: FallbackSimpleConversion,
-		OutputPath:   outputPath,
-		QualityLevel: "basic",
-		TimeTaken:    150 * time.Millisecond,
-		Details: map[string]interface{}{
-			"conversion_method": "simple",
-			"features_disabled": []string{"advanced_filters", "metadata_processing"},
-		},
-	}, nil
-}
-
-// executeCopyOriginalFallback 执行复制原文件后备方案
-func (fm *FallbackManager) executeCopyOriginalFallback(ctx context.Context, errorRecord *ErrorRecord) (*FallbackResult, error) {
-	startTime := time.Now()
-
-	// 生成输出路径
-	outputDir := filepath.Dir(errorRecord.SourceFile)
-	outputName := "copy_" + filepath.Base(errorRecord.SourceFile)
-	outputPath := filepath.Join(outputDir, outputName)
-
-	// 复制文件
-	input, err := os.Open(errorRecord.SourceFile)
-	if err != nil {
-		return nil, fmt.Errorf("打开源文件失败: %w", err)
+	params := make(map[string]interface{}, len(errorRecord.Context)+len(action.Parameters)+3)
+	for key, value := range errorRecord.Context {
+		params[key] = value
 	}
-	defer input.Close()
-
-	output, err := os.Create(outputPath)
-	if err != nil {
-		return nil, fmt.Errorf("创建输出文件失败: %w", err)
+	for key, value := range action.Parameters {
+		params[key] = value
 	}
-	defer output.Close()
+	params["source_file"] = errorRecord.SourceFile
+	params["operation"] = errorRecord.Operation
+	params["error"] = errorRecord.ErrorMessage
 
-	// 这里简化实现，实际应该进行文件复制
-	// _, err = io.Copy(output, input)
-	// if err != nil {
-	//     return nil, fmt.Errorf("复制文件失败: %w", err)
-	// }
-
-	return &FallbackResult{
-		Success:      true,
-		FallbackType: FallbackCopyOriginal,
-		OutputPath:   outputPath,
-		QualityLevel: "original",
-		TimeTaken:    time.Since(startTime),
-		Details: map[string]interface{}{
-			"operation": "file_copy",
-			"preserved": "all_original_properties",
-		},
-	}, nil
+	return handler(ctx, action, params)
 }
 
 // 辅助方法
@@ -592,6 +599,9 @@ func (erm *ErrorRecoveryManager) updateStatistics(errorRecord *ErrorRecord, resu
 		} else {
 			erm.errorStats.FailedRecoveries++
 		}
+		// 平均恢复时间按全部恢复尝试累计
+		attempts := time.Duration(erm.errorStats.RecoveryAttempts)
+		erm.errorStats.AverageRecoveryTime += (errorRecord.TotalRecoveryTime - erm.errorStats.AverageRecoveryTime) / attempts
 	}
 
 	// 更新成功率
@@ -609,7 +619,8 @@ func (erm *ErrorRecoveryManager) updateStatistics(errorRecord *ErrorRecord, resu
 
 		strategyStats.UsageCount++
 		strategyStats.LastUsed = time.Now()
-		strategyStats.AverageTime = result.TimeTaken
+		strategyStats.TotalTime += result.TimeTaken
+		strategyStats.AverageTime = strategyStats.TotalTime / time.Duration(strategyStats.UsageCount)
 
 		if result.Success {
 			strategyStats.SuccessCount++
@@ -617,36 +628,46 @@ func (erm *ErrorRecoveryManager) updateStatistics(errorRecord *ErrorRecord, resu
 			strategyStats.FailureCount++
 		}
 
-		if strategyStats.UsageCount > 0 {
-			strategyStats.SuccessRate = float64(strategyStats.SuccessCount) / float64(strategyStats.UsageCount)
-		}
+		strategyStats.SuccessRate = float64(strategyStats.SuccessCount) / float64(strategyStats.UsageCount)
 	}
 }
 
-func (rm *RetryManager) updateRetryStats(errorType ErrorType, attempt int, success bool) {
+// updateRetryStats 记录一次重试过程：attempts为实际执行的重试次数，成功时最后一次为成功
+func (rm *RetryManager) updateRetryStats(errorType ErrorType, attempts int, success bool, delay, elapsed time.Duration) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
 	stats, exists := rm.retryStats[errorType]
 	if !exists {
 		stats = &RetryStatistics{}
 		rm.retryStats[errorType] = stats
 	}
 
-	stats.TotalRetries++
+	stats.Sequences++
+	stats.TotalRetries += attempts
 	if success {
 		stats.SuccessfulRetries++
+		stats.FailedRetries += attempts - 1
 	} else {
-		stats.FailedRetries++
+		stats.FailedRetries += attempts
 	}
 
-	if attempt > stats.MaxRetries {
-		stats.MaxRetries = attempt
+	if attempts > stats.MaxRetries {
+		stats.MaxRetries = attempts
 	}
 
+	stats.AverageRetries = float64(stats.TotalRetries) / float64(stats.Sequences)
+	stats.TotalRetryTime += elapsed
 	if stats.TotalRetries > 0 {
-		stats.AverageRetries = float64(stats.TotalRetries) / float64(len(rm.activeRetries)+1)
+		// 平均延迟按每次重试前的等待累计
+		stats.AverageDelay = (stats.AverageDelay*time.Duration(stats.TotalRetries-attempts) + delay) / time.Duration(stats.TotalRetries)
 	}
 }
 
 func (fm *FallbackManager) updateFallbackStats(fallbackType FallbackType, success bool, duration time.Duration) {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+
 	stats, exists := fm.fallbackStats[fallbackType]
 	if !exists {
 		stats = &FallbackStatistics{}
@@ -675,9 +696,48 @@ func (erm *ErrorRecoveryManager) GetErrorStatistics() *ErrorStatistics {
 
 	// 返回副本
 	stats := *erm.errorStats
+	stats.ErrorsByType = make(map[ErrorType]int, len(erm.errorStats.ErrorsByType))
+	for errorType, count := range erm.errorStats.ErrorsByType {
+		stats.ErrorsByType[errorType] = count
+	}
+	stats.ErrorsBySeverity = make(map[ErrorSeverity]int, len(erm.errorStats.ErrorsBySeverity))
+	for severity, count := range erm.errorStats.ErrorsBySeverity {
+		stats.ErrorsBySeverity[severity] = count
+	}
+	stats.StrategyStats = make(map[string]*StrategyStats, len(erm.errorStats.StrategyStats))
+	for name, strategyStats := range erm.errorStats.StrategyStats {
+		strategyCopy := *strategyStats
+		stats.StrategyStats[name] = &strategyCopy
+	}
 	return &stats
 }
 
+// GetRetryStatistics 获取各错误类型的重试统计副本
+func (erm *ErrorRecoveryManager) GetRetryStatistics() map[ErrorType]RetryStatistics {
+	rm := erm.retryManager
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	stats := make(map[ErrorType]RetryStatistics, len(rm.retryStats))
+	for errorType, retryStats := range rm.retryStats {
+		stats[errorType] = *retryStats
+	}
+	return stats
+}
+
+// GetFallbackStatistics 获取各后备类型的使用统计副本
+func (erm *ErrorRecoveryManager) GetFallbackStatistics() map[FallbackType]FallbackStatistics {
+	fm := erm.fallbackManager
+	fm.mutex.RLock()
+	defer fm.mutex.RUnlock()
+
+	stats := make(map[FallbackType]FallbackStatistics, len(fm.fallbackStats))
+	for fallbackType, fallbackStats := range fm.fallbackStats {
+		stats[fallbackType] = *fallbackStats
+	}
+	return stats
+}
+
 // GetRecoveryStrategy 获取恢复策略
 func (erm *ErrorRecoveryManager) GetRecoveryStrategy(errorType ErrorType) (*RecoveryStrategy, bool) {
 	erm.mutex.RLock()
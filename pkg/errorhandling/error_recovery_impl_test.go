@@ -0,0 +1,57 @@
+package errorhandling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// exitError 运行shell脚本并返回其ExitError
+func exitError(t *testing.T, script string) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", script).Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Skipf("无法得到进程退出错误: %v", err)
+	}
+	return err
+}
+
+func TestAnalyzeError(t *testing.T) {
+	erm := &ErrorRecoveryManager{}
+
+	tests := []struct {
+		name     string
+		err      error
+		want     ErrorType
+		severity ErrorSeverity
+	}{
+		{"显式分类", fmt.Errorf("迁移失败: %w", Classify(errors.New("exiftool"), ErrorTypeMetadataCorrupted, SeverityLow)), ErrorTypeMetadataCorrupted, SeverityLow},
+		{"上下文超时", fmt.Errorf("cjxl failed: %w", context.DeadlineExceeded), ErrorTypeProcessTimeout, SeverityMedium},
+		{"内存不足", &os.SyscallError{Syscall: "mmap", Err: syscall.ENOMEM}, ErrorTypeMemoryExhausted, SeverityCritical},
+		{"权限不足", &fs.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}, ErrorTypePermissionDenied, SeverityHigh},
+		{"文件过大", &fs.PathError{Op: "write", Path: "/x", Err: syscall.EFBIG}, ErrorTypeFileTooLarge, SeverityMedium},
+		{"文件被截断", fmt.Errorf("读取失败: %w", io.ErrUnexpectedEOF), ErrorTypeFileCorrupted, SeverityHigh},
+		{"网络错误", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorTypeNetworkFailure, SeverityMedium},
+		{"工具非零退出", exitError(t, "exit 3"), ErrorTypeFFmpegCrash, SeverityMedium},
+		{"工具崩溃", exitError(t, "kill -SEGV $$"), ErrorTypeFFmpegCrash, SeverityHigh},
+		{"工具被SIGKILL终止", exitError(t, "kill -KILL $$"), ErrorTypeMemoryExhausted, SeverityHigh},
+		// 消息文本不参与分类
+		{"消息中的关键词", errors.New("invalid memory permission timeout ffmpeg"), ErrorTypeUnknown, SeverityMedium},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errorType, severity := erm.analyzeError(tt.err)
+			if errorType != tt.want || severity != tt.severity {
+				t.Errorf("analyzeError = %s/%d, 期望 %s/%d", errorType, severity, tt.want, tt.severity)
+			}
+		})
+	}
+}
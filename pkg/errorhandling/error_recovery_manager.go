@@ -64,17 +64,20 @@ type RecoveryStrategy struct {
 	Handler         RecoveryHandler  `json:"-"`                // 处理器函数
 }
 
-// RetryManager 重试管理器
+// RetryManager 重试管理器：重试时重新执行调用方注册的操作闭包，未注册的操作不重试
 type RetryManager struct {
 	logger        *zap.Logger
+	config        *RecoveryConfig
+	operations    map[string]RetryableOperation
 	activeRetries map[string]*RetryContext
 	retryStats    map[ErrorType]*RetryStatistics
 	mutex         sync.RWMutex
 }
 
-// FallbackManager 后备方案管理器
+// FallbackManager 后备方案管理器：后备动作交给调用方按类型注册的处理器执行，未注册的类型视为失败
 type FallbackManager struct {
 	logger         *zap.Logger
+	handlers       map[FallbackType]FallbackHandler
 	fallbackChains map[string][]FallbackAction
 	fallbackStats  map[FallbackType]*FallbackStatistics
 	mutex          sync.RWMutex
@@ -135,8 +138,9 @@ type ErrorStatistics struct {
 	TrendAnalysis        *ErrorTrendAnalysis       `json:"trend_analysis"`
 }
 
-// RetryStatistics 重试统计
+// RetryStatistics 重试统计：TotalRetries为实际执行的重试次数，Sequences为发起重试的错误数
 type RetryStatistics struct {
+	Sequences         int           `json:"sequences"`
 	TotalRetries      int           `json:"total_retries"`
 	SuccessfulRetries int           `json:"successful_retries"`
 	FailedRetries     int           `json:"failed_retries"`
@@ -163,6 +167,7 @@ type StrategyStats struct {
 	FailureCount int           `json:"failure_count"`
 	SuccessRate  float64       `json:"success_rate"`
 	AverageTime  time.Duration `json:"average_time"`
+	TotalTime    time.Duration `json:"total_time"`
 	LastUsed     time.Time     `json:"last_used"`
 }
 
@@ -179,8 +184,13 @@ type ErrorTrendAnalysis struct {
 
 // 函数类型定义
 type RecoveryHandler func(context.Context, *ErrorRecord) (*RecoveryResult, error)
+
+// FallbackHandler 后备处理器：params包含source_file、operation、error以及HandleErrorWithContext传入的上下文
 type FallbackHandler func(context.Context, *FallbackAction, map[string]interface{}) (*FallbackResult, error)
 
+// RetryableOperation 可重试的操作：重试时重新执行，返回nil表示操作成功
+type RetryableOperation func(context.Context) error
+
 // RecoveryResult 恢复结果
 type RecoveryResult struct {
 	Success        bool                   `json:"success"`
@@ -190,6 +200,7 @@ type RecoveryResult struct {
 	FinalState     string                 `json:"final_state"`
 	Details        map[string]interface{} `json:"details"`
 	Recommendation string                 `json:"recommendation"`
+	Fallback       *FallbackResult        `json:"fallback,omitempty"` // 成功的后备结果，重试解决时为空
 }
 
 // FallbackResult 后备结果
@@ -248,21 +259,26 @@ const (
 	FallbackSkipFile                             // 跳过文件
 )
 
+// DefaultRecoveryConfig 默认恢复配置
+func DefaultRecoveryConfig() *RecoveryConfig {
+	return &RecoveryConfig{
+		MaxRetries:          3,
+		BaseRetryDelay:      1 * time.Second,
+		MaxRetryDelay:       30 * time.Second,
+		BackoffMultiplier:   2.0,
+		EnableFallback:      true,
+		EnableLearning:      true,
+		HistoryLimit:        1000,
+		RecoveryTimeout:     5 * time.Minute,
+		CriticalErrorAction: ActionFallback,
+		AutoRecoveryEnabled: true,
+	}
+}
+
 // NewErrorRecoveryManager 创建错误恢复管理器
 func NewErrorRecoveryManager(logger *zap.Logger, config *RecoveryConfig) *ErrorRecoveryManager {
 	if config == nil {
-		config = &RecoveryConfig{
-			MaxRetries:          3,
-			BaseRetryDelay:      1 * time.Second,
-			MaxRetryDelay:       30 * time.Second,
-			BackoffMultiplier:   2.0,
-			EnableFallback:      true,
-			EnableLearning:      true,
-			HistoryLimit:        1000,
-			RecoveryTimeout:     5 * time.Minute,
-			CriticalErrorAction: ActionFallback,
-			AutoRecoveryEnabled: true,
-		}
+		config = DefaultRecoveryConfig()
 	}
 
 	manager := &ErrorRecoveryManager{
@@ -275,7 +291,7 @@ func NewErrorRecoveryManager(logger *zap.Logger, config *RecoveryConfig) *ErrorR
 	}
 
 	// 初始化子管理器
-	manager.retryManager = NewRetryManager(logger)
+	manager.retryManager = NewRetryManager(logger, config)
 	manager.fallbackManager = NewFallbackManager(logger)
 
 	// 初始化默认恢复策略
@@ -292,13 +308,39 @@ func NewErrorRecoveryManager(logger *zap.Logger, config *RecoveryConfig) *ErrorR
 	return manager
 }
 
+// RegisterOperation 注册可重试的操作，operationID与HandleError的operation参数对应
+func (erm *ErrorRecoveryManager) RegisterOperation(operationID string, operation RetryableOperation) {
+	erm.retryManager.RegisterOperation(operationID, operation)
+}
+
+// UnregisterOperation 移除已注册的操作
+func (erm *ErrorRecoveryManager) UnregisterOperation(operationID string) {
+	erm.retryManager.UnregisterOperation(operationID)
+}
+
+// RegisterFallback 注册后备类型的处理器，替换已有的处理器
+func (erm *ErrorRecoveryManager) RegisterFallback(fallbackType FallbackType, handler FallbackHandler) {
+	erm.fallbackManager.RegisterHandler(fallbackType, handler)
+}
+
 // HandleError 处理错误 - README核心功能：智能错误恢复
 func (erm *ErrorRecoveryManager) HandleError(ctx context.Context, err error, operation, filePath string) (*RecoveryResult, error) {
+	return erm.HandleErrorWithContext(ctx, err, operation, filePath, nil)
+}
+
+// HandleErrorWithContext 处理错误，details随错误记录保存并传给后备处理器。
+// 只有重试或后备动作真正成功时才返回nil错误；跳过文件不算恢复，返回原错误
+func (erm *ErrorRecoveryManager) HandleErrorWithContext(ctx context.Context, err error, operation, filePath string, details map[string]interface{}) (*RecoveryResult, error) {
 	if !erm.enabled {
 		return nil, err
 	}
 
 	startTime := time.Now()
+	if erm.config.RecoveryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, erm.config.RecoveryTimeout)
+		defer cancel()
+	}
 
 	// 分析错误类型
 	errorType, severity := erm.analyzeError(err)
@@ -312,7 +354,10 @@ func (erm *ErrorRecoveryManager) HandleError(ctx context.Context, err error, ope
 		Operation:    operation,
 		Timestamp:    startTime,
 		Severity:     severity,
-		Context:      make(map[string]interface{}),
+		Context:      make(map[string]interface{}, len(details)),
+	}
+	for key, value := range details {
+		errorRecord.Context[key] = value
 	}
 
 	erm.logger.Info("处理错误",
@@ -322,20 +367,22 @@ func (erm *ErrorRecoveryManager) HandleError(ctx context.Context, err error, ope
 		zap.String("operation", operation),
 		zap.String("file", filePath))
 
-	// 检查是否为关键错误
+	// 关键错误按配置的动作处理，其余错误按策略重试与后备
+	var result *RecoveryResult
+	var recoveryErr error
 	if severity == SeverityCritical {
-		return erm.handleCriticalError(ctx, errorRecord)
+		result, recoveryErr = erm.handleCriticalError(ctx, errorRecord)
+	} else {
+		result, recoveryErr = erm.attemptRecovery(ctx, errorRecord)
 	}
 
-	// 尝试恢复
-	result, err := erm.attemptRecovery(ctx, errorRecord)
-
 	// 更新错误记录
 	errorRecord.RecoveryAttempted = true
 	errorRecord.RecoverySuccess = result != nil && result.Success
 	errorRecord.TotalRecoveryTime = time.Since(startTime)
 
 	if result != nil {
+		result.TimeTaken = errorRecord.TotalRecoveryTime
 		errorRecord.RecoveryStrategy = result.Strategy
 		errorRecord.Resolution = result.FinalState
 	}
@@ -350,22 +397,30 @@ func (erm *ErrorRecoveryManager) HandleError(ctx context.Context, err error, ope
 		erm.logger.Info("错误恢复成功",
 			zap.String("error_id", errorRecord.ID),
 			zap.String("strategy", result.Strategy),
+			zap.String("final_state", result.FinalState),
 			zap.Duration("recovery_time", errorRecord.TotalRecoveryTime))
 		return result, nil
 	}
 
-	erm.logger.Error("错误恢复失败",
+	erm.logger.Warn("错误未能恢复",
 		zap.String("error_id", errorRecord.ID),
 		zap.String("error_type", errorType.String()),
-		zap.Duration("recovery_time", errorRecord.TotalRecoveryTime))
+		zap.Int("retries", errorRecord.RetryCount),
+		zap.Duration("recovery_time", errorRecord.TotalRecoveryTime),
+		zap.NamedError("recovery_error", recoveryErr))
 
+	if recoveryErr != nil && severity == SeverityCritical && erm.config.CriticalErrorAction == ActionAbort {
+		return result, recoveryErr
+	}
 	return result, err
 }
 
 // attemptRecovery 尝试恢复 - README核心功能：多级后备转换策略
 func (erm *ErrorRecoveryManager) attemptRecovery(ctx context.Context, errorRecord *ErrorRecord) (*RecoveryResult, error) {
+	erm.mutex.RLock()
 	strategy, exists := erm.recoveryStrategies[errorRecord.ErrorType]
-	if !exists {
+	erm.mutex.RUnlock()
+	if !exists || !strategy.Enabled {
 		return erm.fallbackRecovery(ctx, errorRecord)
 	}
 
@@ -373,37 +428,41 @@ func (erm *ErrorRecoveryManager) attemptRecovery(ctx context.Context, errorRecor
 		zap.String("error_id", errorRecord.ID),
 		zap.String("strategy", strategy.Name))
 
-	// 第一阶段：重试机制
-	if strategy.MaxAttempts > 0 {
-		retryResult, err := erm.retryManager.AttemptRetry(ctx, errorRecord, strategy)
-		if err == nil && retryResult {
+	// 第一阶段：重新执行已注册的操作
+	var actions []string
+	if attempts := erm.retryAttempts(strategy); attempts > 0 && erm.retryManager.HasOperation(errorRecord.Operation) {
+		retried, err := erm.retryManager.AttemptRetry(ctx, errorRecord, strategy, attempts)
+		if retried {
 			return &RecoveryResult{
 				Success:      true,
 				Strategy:     strategy.Name,
-				ActionsTaken: []string{"重试成功"},
-				TimeTaken:    time.Since(errorRecord.Timestamp),
+				ActionsTaken: []string{fmt.Sprintf("第%d次重试成功", errorRecord.RetryCount)},
 				FinalState:   "重试解决",
 			}, nil
 		}
-	}
-
-	// 第二阶段：后备方案
-	if erm.config.EnableFallback && len(strategy.FallbackActions) > 0 {
-		fallbackResult, err := erm.fallbackManager.ExecuteFallback(ctx, strategy.FallbackActions, errorRecord)
-		if err == nil && fallbackResult != nil && fallbackResult.Success {
+		actions = append(actions, fmt.Sprintf("重试%d次失败", errorRecord.RetryCount))
+		if ctx.Err() != nil {
 			return &RecoveryResult{
-				Success:      true,
+				Success:      false,
 				Strategy:     strategy.Name,
-				ActionsTaken: []string{fmt.Sprintf("后备方案: %s", fallbackResult.FallbackType.String())},
-				TimeTaken:    fallbackResult.TimeTaken,
-				FinalState:   "后备方案解决",
-				Details:      fallbackResult.Details,
-			}, nil
+				ActionsTaken: actions,
+				FinalState:   "恢复中断",
+			}, err
 		}
 	}
 
-	// 第三阶段：通用后备恢复
-	return erm.fallbackRecovery(ctx, errorRecord)
+	// 第二阶段：策略的后备动作，之后是尚未尝试的通用后备动作
+	if !erm.config.EnableFallback {
+		return &RecoveryResult{
+			Success:      false,
+			Strategy:     strategy.Name,
+			ActionsTaken: actions,
+			FinalState:   "恢复失败",
+		}, fmt.Errorf("重试未能解决且后备方案已禁用")
+	}
+	chain := mergeFallbackActions(strategy.FallbackActions, genericFallbackActions())
+	fallbackResult, err := erm.fallbackManager.ExecuteFallback(ctx, chain, errorRecord)
+	return fallbackRecoveryResult(strategy.Name, actions, fallbackResult), err
 }
 
 // 字符串方法
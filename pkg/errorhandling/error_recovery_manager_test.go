@@ -0,0 +1,273 @@
+package errorhandling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// noFallback 所有记录的后备处理器都失败
+const noFallback FallbackType = -1
+
+// newTestRecoveryManager 创建重试延迟为1毫秒的恢复管理器
+func newTestRecoveryManager(maxRetries int, fallback bool) *ErrorRecoveryManager {
+	config := DefaultRecoveryConfig()
+	config.MaxRetries = maxRetries
+	config.EnableFallback = fallback
+	config.BaseRetryDelay = time.Millisecond
+	config.MaxRetryDelay = time.Millisecond
+	config.RecoveryTimeout = 0
+	return NewErrorRecoveryManager(zap.NewNop(), config)
+}
+
+// recordFallbacks 为跳过文件以外的后备类型注册记录调用顺序的处理器，只有succeed类型成功
+func recordFallbacks(erm *ErrorRecoveryManager, succeed FallbackType) *[]FallbackType {
+	called := &[]FallbackType{}
+	for _, fallbackType := range []FallbackType{FallbackLowerQuality, FallbackDifferentFormat, FallbackSimpleConversion, FallbackCopyOriginal} {
+		fallbackType := fallbackType
+		erm.RegisterFallback(fallbackType, func(ctx context.Context, action *FallbackAction, params map[string]interface{}) (*FallbackResult, error) {
+			*called = append(*called, fallbackType)
+			if params["source_file"] != "/in/a.png" || params["route"] != "jxl" {
+				return nil, fmt.Errorf("后备参数 = %v", params)
+			}
+			if fallbackType != succeed {
+				return nil, errors.New("后备失败")
+			}
+			return &FallbackResult{Success: true, OutputPath: "/out/a." + fallbackType.String()}, nil
+		})
+	}
+	return called
+}
+
+// failingOperation 前failures次调用失败、之后成功的操作，返回调用计数
+func failingOperation(failures int) (RetryableOperation, *int) {
+	calls := 0
+	return func(ctx context.Context) error {
+		calls++
+		if calls <= failures {
+			return fmt.Errorf("第%d次执行失败", calls)
+		}
+		return nil
+	}, &calls
+}
+
+func TestHandleErrorRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		errorType  ErrorType
+		maxRetries int
+		failures   int
+		register   bool
+		wantCalls  int
+		wantState  string
+		wantRetry  RetryStatistics
+		wantChain  []FallbackType
+	}{
+		{"首次重试成功", ErrorTypeFFmpegCrash, 3, 0, true, 1, "重试解决",
+			RetryStatistics{Sequences: 1, TotalRetries: 1, SuccessfulRetries: 1, MaxRetries: 1}, nil},
+		{"第二次重试成功", ErrorTypeFFmpegCrash, 3, 1, true, 2, "重试解决",
+			RetryStatistics{Sequences: 1, TotalRetries: 2, SuccessfulRetries: 1, FailedRetries: 1, MaxRetries: 2}, nil},
+		{"重试次数不超过策略", ErrorTypeFFmpegCrash, 3, 5, true, 2, "文件跳过",
+			RetryStatistics{Sequences: 1, TotalRetries: 2, FailedRetries: 2, MaxRetries: 2},
+			[]FallbackType{FallbackDifferentFormat, FallbackCopyOriginal, FallbackLowerQuality}},
+		{"重试次数不超过配置", ErrorTypeMemoryExhausted, 1, 5, true, 1, "文件跳过",
+			RetryStatistics{Sequences: 1, TotalRetries: 1, FailedRetries: 1, MaxRetries: 1},
+			[]FallbackType{FallbackLowerQuality, FallbackSimpleConversion, FallbackCopyOriginal}},
+		{"配置不重试", ErrorTypeFFmpegCrash, 0, 5, true, 0, "文件跳过",
+			RetryStatistics{}, []FallbackType{FallbackDifferentFormat, FallbackCopyOriginal, FallbackLowerQuality}},
+		{"未注册的操作不重试", ErrorTypeProcessTimeout, 3, 0, false, 0, "文件跳过",
+			RetryStatistics{}, []FallbackType{FallbackSimpleConversion, FallbackCopyOriginal, FallbackLowerQuality}},
+		{"没有策略的错误只走通用后备", ErrorTypeUnknown, 3, 0, true, 0, "文件跳过",
+			RetryStatistics{}, []FallbackType{FallbackLowerQuality, FallbackCopyOriginal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			erm := newTestRecoveryManager(tt.maxRetries, true)
+			called := recordFallbacks(erm, noFallback)
+			operation, calls := failingOperation(tt.failures)
+			if tt.register {
+				erm.RegisterOperation("convert:/in/a.png", operation)
+			}
+
+			original := Classify(errors.New("转换失败"), tt.errorType, SeverityMedium)
+			result, err := erm.HandleErrorWithContext(context.Background(), original, "convert:/in/a.png", "/in/a.png", map[string]interface{}{"route": "jxl"})
+			if result == nil || result.FinalState != tt.wantState {
+				t.Fatalf("HandleErrorWithContext = %+v, 期望最终状态 %s", result, tt.wantState)
+			}
+			if wantSuccess := tt.wantState == "重试解决"; result.Success != wantSuccess || (err == nil) != wantSuccess {
+				t.Errorf("Success = %v, err = %v, 期望成功 %v", result.Success, err, wantSuccess)
+			}
+			if err != nil && err != original {
+				t.Errorf("未恢复时 err = %v, 期望原错误", err)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("操作执行 %d 次, 期望 %d", *calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(*called, tt.wantChain) && len(*called)+len(tt.wantChain) > 0 {
+				t.Errorf("后备顺序 = %v, 期望 %v", *called, tt.wantChain)
+			}
+
+			retry := erm.GetRetryStatistics()[tt.errorType]
+			retry.AverageRetries, retry.AverageDelay, retry.TotalRetryTime = 0, 0, 0
+			if retry != tt.wantRetry {
+				t.Errorf("重试统计 = %+v, 期望 %+v", retry, tt.wantRetry)
+			}
+			fallbacks := erm.GetFallbackStatistics()
+			for _, fallbackType := range tt.wantChain {
+				if stats := fallbacks[fallbackType]; stats.TotalUsage != 1 || stats.FailedUsage != 1 {
+					t.Errorf("%s 后备统计 = %+v, 期望失败1次", fallbackType, stats)
+				}
+			}
+			// 后备都失败时以跳过文件结束
+			wantKinds := 0
+			if len(tt.wantChain) > 0 {
+				wantKinds = len(tt.wantChain) + 1
+				if stats := fallbacks[FallbackSkipFile]; stats.SuccessfulUsage != 1 {
+					t.Errorf("跳过文件统计 = %+v, 期望成功1次", stats)
+				}
+			}
+			if len(fallbacks) != wantKinds {
+				t.Errorf("后备统计 = %v, 期望 %d 种后备", fallbacks, wantKinds)
+			}
+		})
+	}
+}
+
+func TestHandleErrorFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		errorType ErrorType
+		succeed   FallbackType
+		wantChain []FallbackType
+	}{
+		{"策略的第一个后备成功", ErrorTypeMemoryExhausted, FallbackLowerQuality, []FallbackType{FallbackLowerQuality}},
+		{"策略的后续后备成功", ErrorTypeMemoryExhausted, FallbackSimpleConversion, []FallbackType{FallbackLowerQuality, FallbackSimpleConversion}},
+		{"策略之后的通用后备成功", ErrorTypeFormatUnsupported, FallbackLowerQuality, []FallbackType{FallbackDifferentFormat, FallbackCopyOriginal, FallbackLowerQuality}},
+		{"通用后备不重复执行策略已有的动作", ErrorTypeProcessTimeout, FallbackLowerQuality, []FallbackType{FallbackSimpleConversion, FallbackCopyOriginal, FallbackLowerQuality}},
+		{"没有策略时的通用后备", ErrorTypeUnknown, FallbackCopyOriginal, []FallbackType{FallbackLowerQuality, FallbackCopyOriginal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			erm := newTestRecoveryManager(1, true)
+			called := recordFallbacks(erm, tt.succeed)
+			operation, calls := failingOperation(10)
+			erm.RegisterOperation("convert:/in/a.png", operation)
+
+			original := Classify(errors.New("转换失败"), tt.errorType, SeverityHigh)
+			result, err := erm.HandleErrorWithContext(context.Background(), original, "convert:/in/a.png", "/in/a.png", map[string]interface{}{"route": "jxl"})
+			if err != nil || result == nil || !result.Success || result.FinalState != "后备方案解决" {
+				t.Fatalf("HandleErrorWithContext = %+v, %v, 期望后备成功", result, err)
+			}
+			if result.Fallback == nil || result.Fallback.FallbackType != tt.succeed || result.Fallback.OutputPath != "/out/a."+tt.succeed.String() {
+				t.Errorf("Fallback = %+v, 期望 %s", result.Fallback, tt.succeed)
+			}
+			if !reflect.DeepEqual(*called, tt.wantChain) {
+				t.Errorf("后备顺序 = %v, 期望 %v", *called, tt.wantChain)
+			}
+			if _, hasStrategy := erm.GetRecoveryStrategy(tt.errorType); hasStrategy != (*calls == 1) {
+				t.Errorf("操作执行 %d 次, 有策略 %v", *calls, hasStrategy)
+			}
+
+			fallbacks := erm.GetFallbackStatistics()
+			for i, fallbackType := range tt.wantChain {
+				stats := fallbacks[fallbackType]
+				want := FallbackStatistics{TotalUsage: 1, FailedUsage: 1}
+				if i == len(tt.wantChain)-1 {
+					want = FallbackStatistics{TotalUsage: 1, SuccessfulUsage: 1, SuccessRate: 1}
+				}
+				if stats.TotalUsage != want.TotalUsage || stats.SuccessfulUsage != want.SuccessfulUsage ||
+					stats.FailedUsage != want.FailedUsage || stats.SuccessRate != want.SuccessRate {
+					t.Errorf("%s 后备统计 = %+v, 期望 %+v", fallbackType, stats, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleErrorFallbackDisabled(t *testing.T) {
+	erm := newTestRecoveryManager(1, false)
+	called := recordFallbacks(erm, FallbackLowerQuality)
+	operation, calls := failingOperation(10)
+	erm.RegisterOperation("convert:/in/a.png", operation)
+
+	original := Classify(errors.New("转换失败"), ErrorTypeMemoryExhausted, SeverityHigh)
+	result, err := erm.HandleErrorWithContext(context.Background(), original, "convert:/in/a.png", "/in/a.png", nil)
+	if err != original || result == nil || result.Success {
+		t.Errorf("HandleErrorWithContext = %+v, %v, 期望返回原错误", result, err)
+	}
+	if *calls != 1 || len(*called) != 0 {
+		t.Errorf("操作执行 %d 次, 后备 %v, 期望只重试不后备", *calls, *called)
+	}
+}
+
+func TestHandleErrorCanceled(t *testing.T) {
+	erm := newTestRecoveryManager(3, true)
+	erm.config.BaseRetryDelay = time.Hour
+	erm.config.MaxRetryDelay = time.Hour
+	called := recordFallbacks(erm, FallbackLowerQuality)
+	operation, calls := failingOperation(0)
+	erm.RegisterOperation("convert:/in/a.png", operation)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	original := Classify(errors.New("转换失败"), ErrorTypeFFmpegCrash, SeverityMedium)
+	result, err := erm.HandleErrorWithContext(ctx, original, "convert:/in/a.png", "/in/a.png", nil)
+	if err == nil || result == nil || result.FinalState != "恢复中断" {
+		t.Errorf("HandleErrorWithContext = %+v, %v, 期望恢复中断", result, err)
+	}
+	if *calls != 0 || len(*called) != 0 {
+		t.Errorf("操作执行 %d 次, 后备 %v, 取消后不应继续", *calls, *called)
+	}
+	if retry := erm.GetRetryStatistics()[ErrorTypeFFmpegCrash]; retry.Sequences != 1 || retry.TotalRetries != 0 {
+		t.Errorf("重试统计 = %+v, 期望1个未执行的重试过程", retry)
+	}
+}
+
+func TestErrorStatistics(t *testing.T) {
+	erm := newTestRecoveryManager(2, true)
+	recordFallbacks(erm, FallbackCopyOriginal)
+
+	// 重试解决、后备解决、后备全部失败各一次
+	handle := func(failures int, errorType ErrorType, fallback bool) {
+		erm.config.EnableFallback = fallback
+		operation, _ := failingOperation(failures)
+		erm.RegisterOperation("convert:/in/a.png", operation)
+		defer erm.UnregisterOperation("convert:/in/a.png")
+		erm.HandleErrorWithContext(context.Background(), Classify(errors.New("转换失败"), errorType, SeverityMedium), "convert:/in/a.png", "/in/a.png", map[string]interface{}{"route": "jxl"})
+	}
+	handle(1, ErrorTypeFFmpegCrash, true)
+	handle(10, ErrorTypeFFmpegCrash, true)
+	handle(10, ErrorTypeMemoryExhausted, false)
+
+	stats := erm.GetErrorStatistics()
+	if stats.TotalErrors != 3 || stats.RecoveryAttempts != 3 || stats.SuccessfulRecoveries != 2 || stats.FailedRecoveries != 1 {
+		t.Errorf("错误统计 = %+v, 期望3个错误中恢复2个", stats)
+	}
+	if stats.RecoverySuccessRate != 2.0/3.0 {
+		t.Errorf("RecoverySuccessRate = %v, 期望 %v", stats.RecoverySuccessRate, 2.0/3.0)
+	}
+	if stats.ErrorsByType[ErrorTypeFFmpegCrash] != 2 || stats.ErrorsByType[ErrorTypeMemoryExhausted] != 1 {
+		t.Errorf("ErrorsByType = %v", stats.ErrorsByType)
+	}
+	if strategy := stats.StrategyStats["FFmpeg崩溃恢复策略"]; strategy == nil || strategy.UsageCount != 2 || strategy.SuccessCount != 2 {
+		t.Errorf("FFmpeg崩溃恢复策略统计 = %+v, 期望使用2次成功2次", strategy)
+	}
+	if strategy := stats.StrategyStats["内存耗尽恢复策略"]; strategy == nil || strategy.UsageCount != 1 || strategy.FailureCount != 1 {
+		t.Errorf("内存耗尽恢复策略统计 = %+v, 期望使用1次失败1次", strategy)
+	}
+
+	retry := erm.GetRetryStatistics()[ErrorTypeFFmpegCrash]
+	if retry.Sequences != 2 || retry.TotalRetries != 4 || retry.SuccessfulRetries != 1 || retry.FailedRetries != 3 || retry.AverageRetries != 2 {
+		t.Errorf("FFmpeg崩溃重试统计 = %+v, 期望2个过程共4次重试", retry)
+	}
+	// 第二个错误的后备链：不同格式失败后复制原文件成功
+	fallbacks := erm.GetFallbackStatistics()
+	if fallbacks[FallbackDifferentFormat].FailedUsage != 1 || fallbacks[FallbackCopyOriginal].SuccessfulUsage != 1 || len(fallbacks) != 2 {
+		t.Errorf("后备统计 = %+v", fallbacks)
+	}
+}
@@ -425,6 +425,45 @@ func (fsm *FormatSupportManager) initializeSupportedFormats() {
 		ConversionTargets: []string{"jpeg", "webp", "avif"},
 	})
 
+	// 相机RAW格式 - 按conversion.raw.policy处理：keep保持原样，preview提取嵌入预览为JXL旁车文件，
+	// dng_jxl将DNG无损转换为JPEG XL压缩的DNG
+	fsm.addFormat(&FormatInfo{
+		Name:              "DNG",
+		Extensions:        []string{".dng"},
+		MimeTypes:         []string{"image/x-adobe-dng"},
+		Category:          CategoryRaw,
+		SupportLevel:      SupportPartial,
+		InputSupported:    true,
+		OutputSupported:   true,
+		Quality:           QualityLossless,
+		Features:          []FormatFeature{FeatureMetadata, FeatureCompression, FeatureColorProfile},
+		RecommendedUse:    "开放的相机RAW格式，可无损转换为JPEG XL压缩的DNG",
+		ConversionTargets: []string{"dng", "jxl"},
+		ProcessingHints: &ProcessingHints{
+			PerformanceNotes:   []string{"dng_jxl需要DNG转换工具（tools.dng_converter_path）"},
+			CompatibilityNotes: []string{"JPEG XL压缩的DNG需要DNG 1.7及以上的软件读取"},
+			BestPractices:      []string{"原件移入备份目录，可撤销", "使用exiftool保留元数据"},
+		},
+	})
+
+	fsm.addFormat(&FormatInfo{
+		Name:              "Camera RAW",
+		Extensions:        []string{".cr2", ".nef", ".arw"},
+		MimeTypes:         []string{"image/x-canon-cr2", "image/x-nikon-nef", "image/x-sony-arw"},
+		Category:          CategoryRaw,
+		SupportLevel:      SupportPartial,
+		InputSupported:    true,
+		OutputSupported:   false,
+		Quality:           QualityLossless,
+		Features:          []FormatFeature{FeatureMetadata},
+		RecommendedUse:    "厂商专有RAW格式，RAW本身保持原样，可提取嵌入预览为JXL旁车文件",
+		ConversionTargets: []string{"jxl"},
+		ProcessingHints: &ProcessingHints{
+			PerformanceNotes:   []string{"预览为无损重压缩的嵌入JPEG，不重新解码RAW数据"},
+			CompatibilityNotes: []string{"与同名机内JPEG/HEIF配对时不生成预览"},
+		},
+	})
+
 	// 视频格式
 	fsm.addFormat(&FormatInfo{
 		Name:              "MP4",
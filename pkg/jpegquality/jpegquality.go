@@ -0,0 +1,446 @@
+package jpegquality
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotJPEG 数据不是JPEG
+var ErrNotJPEG = errors.New("不是JPEG文件")
+
+// 标记
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerDQT  = 0xDB
+	markerDRI  = 0xDD
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+	markerAPPD = 0xED
+	markerAPPE = 0xEE
+	markerCOM  = 0xFE
+)
+
+// zigzag DQT中系数的Z字形顺序到自然顺序的映射
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// stdLuminance JPEG标准（Annex K）亮度量化表，自然顺序
+var stdLuminance = [64]int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+// stdChrominance JPEG标准（Annex K）色度量化表，自然顺序
+var stdChrominance = [64]int{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// Info JPEG编码信息，全部来自文件头的段，不解码图像数据
+type Info struct {
+	Width      int `json:"width"`
+	Height     int `json:"height"`
+	Precision  int `json:"precision"`  // 采样精度（位）
+	Components int `json:"components"` // 颜色分量数
+
+	Quality       int  `json:"quality"`        // IJG等效品质（1-100），以亮度表为准
+	LumaQuality   int  `json:"luma_quality"`   // 亮度表的IJG等效品质
+	ChromaQuality int  `json:"chroma_quality"` // 色度表的IJG等效品质，无色度表时为0
+	StandardTable bool `json:"standard_table"` // 量化表与IJG标准缩放完全一致（libjpeg系编码器）
+
+	Subsampling     string `json:"subsampling"` // 4:4:4、4:2:2、4:2:0、4:1:1、4:4:0、4:0:0（灰度）
+	Progressive     bool   `json:"progressive"`
+	Arithmetic      bool   `json:"arithmetic"` // 算术编码
+	Lossless        bool   `json:"lossless"`   // 无损JPEG（SOF3等）
+	RestartInterval int    `json:"restart_interval"`
+
+	JFIF      bool `json:"jfif"`
+	EXIF      bool `json:"exif"`
+	XMP       bool `json:"xmp"`
+	ICC       bool `json:"icc"`
+	Photoshop bool `json:"photoshop"` // APP13 Photoshop IRB
+	Adobe     bool `json:"adobe"`     // APP14 Adobe
+
+	Resaved     bool     `json:"resaved"`                // 有迹象表明文件经软件重新编码
+	ResaveHints []string `json:"resave_hints,omitempty"` // 判断依据
+}
+
+// quantTable 量化表（自然顺序）
+type quantTable struct {
+	values [64]int
+	wide   bool // 16位精度
+}
+
+// component 帧头中的颜色分量
+type component struct {
+	h, v  int
+	table int
+}
+
+// AnalyzeFile 解析JPEG文件的段并估算编码品质
+func AnalyzeFile(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Analyze(file)
+}
+
+// Analyze 解析JPEG数据的段并估算编码品质，读取到第一个扫描段为止
+func Analyze(r io.Reader) (*Info, error) {
+	reader := bufio.NewReader(r)
+
+	var soi [2]byte
+	if _, err := io.ReadFull(reader, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return nil, ErrNotJPEG
+	}
+
+	info := &Info{}
+	tables := make(map[int]*quantTable)
+	var components []component
+	sawFrame := false
+	exifMake := false
+
+	for {
+		marker, err := nextMarker(reader)
+		if err != nil {
+			return nil, fmt.Errorf("读取JPEG标记失败: %w", err)
+		}
+		if marker == markerEOI {
+			break
+		}
+		// 无长度的独立标记
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(reader, lengthBytes[:]); err != nil {
+			return nil, fmt.Errorf("读取JPEG段长度失败: %w", err)
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes[:])) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("无效的JPEG段长度: 0x%02X", marker)
+		}
+		if marker == markerSOS {
+			// 扫描段之后是熵编码数据，需要的信息都已在之前的段中
+			break
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(reader, segment); err != nil {
+			return nil, fmt.Errorf("读取JPEG段失败: %w", err)
+		}
+
+		switch {
+		case marker == markerDQT:
+			if err := parseDQT(segment, tables); err != nil {
+				return nil, err
+			}
+		case isSOF(marker):
+			parsed, err := parseSOF(segment, info)
+			if err != nil {
+				return nil, err
+			}
+			components = parsed
+			sawFrame = true
+			info.Progressive = marker == 0xC2 || marker == 0xC6 || marker == 0xCA || marker == 0xCE
+			info.Arithmetic = marker >= 0xC9
+			info.Lossless = marker == 0xC3 || marker == 0xC7 || marker == 0xCB || marker == 0xCF
+		case marker == markerDRI:
+			if len(segment) >= 2 {
+				info.RestartInterval = int(binary.BigEndian.Uint16(segment))
+			}
+		case marker == markerAPP0:
+			info.JFIF = info.JFIF || bytes.HasPrefix(segment, []byte("JFIF\x00"))
+		case marker == markerAPP1:
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				info.EXIF = true
+				exifMake = exifMake || hasCameraMake(segment[6:])
+			} else if bytes.HasPrefix(segment, []byte("http://ns.adobe.com/xap/1.0/")) {
+				info.XMP = true
+			}
+		case marker == markerAPP2:
+			info.ICC = info.ICC || bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00"))
+		case marker == markerAPPD:
+			info.Photoshop = info.Photoshop || bytes.HasPrefix(segment, []byte("Photoshop 3.0\x00"))
+		case marker == markerAPPE:
+			info.Adobe = info.Adobe || bytes.HasPrefix(segment, []byte("Adobe"))
+		}
+	}
+
+	if !sawFrame {
+		return nil, fmt.Errorf("JPEG缺少帧头")
+	}
+
+	info.Subsampling = subsampling(components)
+	estimateQuality(info, tables, components)
+	detectResave(info, exifMake)
+	return info, nil
+}
+
+// nextMarker 读取下一个标记，跳过填充字节
+func nextMarker(reader *bufio.Reader) (byte, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, fmt.Errorf("期望标记前缀0xFF，实际为0x%02X", b)
+	}
+	for {
+		b, err = reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xFF {
+			return b, nil
+		}
+	}
+}
+
+// isSOF 是否为帧头标记（排除DHT、JPG与DAC）
+func isSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// parseDQT 解析量化表段，一个段可包含多张表
+func parseDQT(segment []byte, tables map[int]*quantTable) error {
+	for len(segment) > 0 {
+		precision := segment[0] >> 4
+		id := int(segment[0] & 0x0F)
+		segment = segment[1:]
+
+		table := &quantTable{wide: precision == 1}
+		size := 64
+		if table.wide {
+			size = 128
+		}
+		if len(segment) < size {
+			return fmt.Errorf("量化表数据不完整")
+		}
+		for i := 0; i < 64; i++ {
+			if table.wide {
+				table.values[zigzag[i]] = int(binary.BigEndian.Uint16(segment[i*2:]))
+			} else {
+				table.values[zigzag[i]] = int(segment[i])
+			}
+		}
+		tables[id] = table
+		segment = segment[size:]
+	}
+	return nil
+}
+
+// parseSOF 解析帧头：尺寸与各分量的采样因子、量化表
+func parseSOF(segment []byte, info *Info) ([]component, error) {
+	if len(segment) < 6 {
+		return nil, fmt.Errorf("帧头数据不完整")
+	}
+	info.Precision = int(segment[0])
+	info.Height = int(binary.BigEndian.Uint16(segment[1:]))
+	info.Width = int(binary.BigEndian.Uint16(segment[3:]))
+	info.Components = int(segment[5])
+
+	if len(segment) < 6+info.Components*3 {
+		return nil, fmt.Errorf("帧头分量数据不完整")
+	}
+	components := make([]component, info.Components)
+	for i := range components {
+		offset := 6 + i*3
+		components[i] = component{
+			h:     int(segment[offset+1] >> 4),
+			v:     int(segment[offset+1] & 0x0F),
+			table: int(segment[offset+2]),
+		}
+	}
+	return components, nil
+}
+
+// subsampling 由亮度与色度分量的采样因子得到色度抽样方式
+func subsampling(components []component) string {
+	if len(components) == 1 {
+		return "4:0:0"
+	}
+	if len(components) < 3 {
+		return ""
+	}
+	luma, chroma := components[0], components[1]
+	if chroma.h == 0 || chroma.v == 0 {
+		return ""
+	}
+	switch h, v := luma.h/chroma.h, luma.v/chroma.v; {
+	case h == 1 && v == 1:
+		return "4:4:4"
+	case h == 2 && v == 1:
+		return "4:2:2"
+	case h == 2 && v == 2:
+		return "4:2:0"
+	case h == 4 && v == 1:
+		return "4:1:1"
+	case h == 1 && v == 2:
+		return "4:4:0"
+	default:
+		return ""
+	}
+}
+
+// estimateQuality 估算IJG等效品质：先查找与标准缩放完全一致的品质，否则按整表平均缩放比例换算
+func estimateQuality(info *Info, tables map[int]*quantTable, components []component) {
+	if len(components) == 0 {
+		return
+	}
+	luma, ok := tables[components[0].table]
+	if !ok {
+		return
+	}
+
+	lumaQuality, lumaExact := tableQuality(luma, &stdLuminance)
+	info.LumaQuality = lumaQuality
+	info.Quality = lumaQuality
+	info.StandardTable = lumaExact
+
+	if len(components) >= 3 {
+		if chroma, ok := tables[components[1].table]; ok {
+			chromaQuality, chromaExact := tableQuality(chroma, &stdChrominance)
+			info.ChromaQuality = chromaQuality
+			info.StandardTable = info.StandardTable && chromaExact
+		}
+	}
+}
+
+// tableQuality 返回量化表对应的IJG品质，以及是否与该品质的标准缩放完全一致
+func tableQuality(table *quantTable, std *[64]int) (int, bool) {
+	maxValue := 255
+	if table.wide {
+		maxValue = 32767
+	}
+	for quality := 100; quality >= 1; quality-- {
+		if scaledTableEquals(table, std, quality, maxValue) {
+			return quality, true
+		}
+	}
+
+	// 非标准表（相机、Photoshop等自定义表）：按整表平均缩放比例换算
+	sumActual, sumStd := 0, 0
+	for i := 0; i < 64; i++ {
+		sumActual += table.values[i]
+		sumStd += std[i]
+	}
+	scale := float64(sumActual) * 100 / float64(sumStd)
+	var quality float64
+	if scale <= 100 {
+		quality = (200 - scale) / 2
+	} else {
+		quality = 5000 / scale
+	}
+	return clampQuality(int(quality + 0.5)), false
+}
+
+// scaledTableEquals 判断量化表是否等于标准表按IJG公式缩放到指定品质的结果
+func scaledTableEquals(table *quantTable, std *[64]int, quality, maxValue int) bool {
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	for i := 0; i < 64; i++ {
+		value := (std[i]*scale + 50) / 100
+		if value < 1 {
+			value = 1
+		} else if value > maxValue {
+			value = maxValue
+		}
+		if table.values[i] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// detectResave 根据段信息判断是否经软件重新编码：
+// 相机使用自定义量化表且只写EXIF，编辑软件重新保存后通常变为IJG标准表、补写JFIF或Adobe段
+func detectResave(info *Info, exifMake bool) {
+	if exifMake && info.StandardTable {
+		info.ResaveHints = append(info.ResaveHints, "相机EXIF与IJG标准量化表并存")
+	}
+	if exifMake && info.JFIF {
+		info.ResaveHints = append(info.ResaveHints, "相机EXIF与JFIF段并存")
+	}
+	if info.Photoshop || info.Adobe {
+		info.ResaveHints = append(info.ResaveHints, "包含Adobe/Photoshop段")
+	}
+	info.Resaved = len(info.ResaveHints) > 0
+}
+
+// hasCameraMake 检查EXIF的IFD0是否包含相机厂商（Make）标签
+func hasCameraMake(tiff []byte) bool {
+	if len(tiff) < 8 {
+		return false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return false
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return false
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return false
+		}
+		// 0x010F: Make
+		if order.Uint16(tiff[entry:]) == 0x010F {
+			return order.Uint32(tiff[entry+4:]) > 1
+		}
+	}
+	return false
+}
+
+// clampQuality 将品质限制在1-100
+func clampQuality(quality int) int {
+	if quality < 1 {
+		return 1
+	}
+	if quality > 100 {
+		return 100
+	}
+	return quality
+}
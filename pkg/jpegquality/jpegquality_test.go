@@ -0,0 +1,292 @@
+package jpegquality
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// encodeJPEG 用标准库编码测试图像：image/jpeg使用Annex K表按IJG公式缩放，彩色图为4:2:0抽样
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("编码JPEG失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testImage 带渐变的彩色测试图像
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), 128, 255})
+		}
+	}
+	return img
+}
+
+// segment 构造带长度的JPEG段
+func segment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// dqt 构造8位量化表段，values为自然顺序
+func dqt(id byte, values [64]int) []byte {
+	payload := []byte{id}
+	for i := 0; i < 64; i++ {
+		payload = append(payload, byte(values[zigzag[i]]))
+	}
+	return segment(markerDQT, payload)
+}
+
+// sof 构造帧头段：每个分量为 (采样因子, 量化表号)
+func sof(marker byte, width, height int, components ...[2]byte) []byte {
+	payload := []byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), byte(len(components))}
+	for i, c := range components {
+		payload = append(payload, byte(i+1), c[0], c[1])
+	}
+	return segment(marker, payload)
+}
+
+// exifWithMake 构造只含Make标签的EXIF APP1段
+func exifWithMake(camera string) []byte {
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0}
+	tiff = append(tiff, ifdEntry(0x010F, 2, uint32(len(camera)+1), 26)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, append([]byte(camera), 0)...)
+	return segment(markerAPP1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// ifdEntry 构造小端序IFD条目
+func ifdEntry(tag, typ uint16, count, value uint32) []byte {
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry, tag)
+	binary.LittleEndian.PutUint16(entry[2:], typ)
+	binary.LittleEndian.PutUint32(entry[4:], count)
+	binary.LittleEndian.PutUint32(entry[8:], value)
+	return entry
+}
+
+// jpegFile 拼接SOI、各段与SOS
+func jpegFile(segments ...[]byte) []byte {
+	data := []byte{0xFF, markerSOI}
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return append(data, segment(markerSOS, []byte{1, 1, 0, 0, 63, 0})...)
+}
+
+// scaledTable 按IJG公式将标准表缩放到指定品质
+func scaledTable(std *[64]int, quality int) [64]int {
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var out [64]int
+	for i, v := range std {
+		out[i] = min(max((v*scale+50)/100, 1), 255)
+	}
+	return out
+}
+
+func TestAnalyzeStandardTables(t *testing.T) {
+	tests := []struct {
+		name        string
+		img         image.Image
+		quality     int
+		subsampling string
+		components  int
+	}{
+		{"彩色q10", testImage(32, 24), 10, "4:2:0", 3},
+		{"彩色q50", testImage(32, 24), 50, "4:2:0", 3},
+		{"彩色q75", testImage(32, 24), 75, "4:2:0", 3},
+		{"彩色q92", testImage(32, 24), 92, "4:2:0", 3},
+		{"彩色q100", testImage(32, 24), 100, "4:2:0", 3},
+		{"灰度q85", image.NewGray(image.Rect(0, 0, 16, 16)), 85, "4:0:0", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Analyze(bytes.NewReader(encodeJPEG(t, tt.img, tt.quality)))
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			bounds := tt.img.Bounds()
+			if info.Width != bounds.Dx() || info.Height != bounds.Dy() {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, bounds.Dx(), bounds.Dy())
+			}
+			if info.Quality != tt.quality || info.LumaQuality != tt.quality {
+				t.Errorf("Quality = %d, LumaQuality = %d, 期望 %d", info.Quality, info.LumaQuality, tt.quality)
+			}
+			if !info.StandardTable {
+				t.Error("标准库编码的量化表应识别为IJG标准表")
+			}
+			if tt.components == 3 && info.ChromaQuality != tt.quality {
+				t.Errorf("ChromaQuality = %d, 期望 %d", info.ChromaQuality, tt.quality)
+			}
+			if tt.components == 1 && info.ChromaQuality != 0 {
+				t.Errorf("灰度图 ChromaQuality = %d, 期望 0", info.ChromaQuality)
+			}
+			if info.Components != tt.components || info.Subsampling != tt.subsampling {
+				t.Errorf("分量 = %d / %s, 期望 %d / %s", info.Components, info.Subsampling, tt.components, tt.subsampling)
+			}
+			if info.Progressive || info.Arithmetic || info.Lossless || info.Resaved {
+				t.Errorf("基线JPEG的标志位有误: %+v", info)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSyntheticSegments(t *testing.T) {
+	// 相机常见的自定义表：标准表的0.6倍再整体加1，不等于任何IJG品质的缩放结果
+	var custom [64]int
+	for i, v := range stdLuminance {
+		custom[i] = v*6/10 + 1
+	}
+	luma444 := [2]byte{0x11, 0}
+	chroma := [2]byte{0x11, 1}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		wantQuality   int
+		wantChroma    int
+		wantStandard  bool
+		subsampling   string
+		progressive   bool
+		arithmetic    bool
+		lossless      bool
+		restart       int
+		wantResaved   bool
+		wantEXIF      bool
+		wantICCAdobe  bool
+		qualityApprox bool
+	}{
+		{
+			name:         "4:4:4渐进式标准表",
+			data:         jpegFile(dqt(0, scaledTable(&stdLuminance, 80)), dqt(1, scaledTable(&stdChrominance, 80)), sof(0xC2, 64, 48, luma444, chroma, chroma)),
+			wantQuality:  80,
+			wantChroma:   80,
+			wantStandard: true,
+			subsampling:  "4:4:4",
+			progressive:  true,
+		},
+		{
+			name:         "4:2:2算术编码与重启间隔",
+			data:         jpegFile(dqt(0, scaledTable(&stdLuminance, 60)), dqt(1, scaledTable(&stdChrominance, 60)), segment(markerDRI, []byte{0, 16}), sof(0xC9, 64, 48, [2]byte{0x21, 0}, chroma, chroma)),
+			wantQuality:  60,
+			wantChroma:   60,
+			wantStandard: true,
+			subsampling:  "4:2:2",
+			arithmetic:   true,
+			restart:      16,
+		},
+		{
+			name:         "无损JPEG",
+			data:         jpegFile(dqt(0, scaledTable(&stdLuminance, 100)), sof(0xC3, 8, 8, luma444)),
+			wantQuality:  100,
+			wantStandard: true,
+			subsampling:  "4:0:0",
+			lossless:     true,
+		},
+		{
+			name:          "相机自定义表按平均缩放估算",
+			data:          jpegFile(dqt(0, custom), sof(0xC0, 8, 8, luma444)),
+			wantQuality:   70,
+			subsampling:   "4:0:0",
+			qualityApprox: true,
+		},
+		{
+			name:         "相机EXIF与标准表并存判为重新保存",
+			data:         jpegFile(exifWithMake("Canon"), dqt(0, scaledTable(&stdLuminance, 90)), sof(0xC0, 8, 8, luma444)),
+			wantQuality:  90,
+			wantStandard: true,
+			subsampling:  "4:0:0",
+			wantResaved:  true,
+			wantEXIF:     true,
+		},
+		{
+			name:         "ICC与Adobe段",
+			data:         jpegFile(segment(markerAPP2, []byte("ICC_PROFILE\x00\x01\x01")), segment(markerAPPE, []byte("Adobe\x00")), dqt(0, scaledTable(&stdLuminance, 95)), sof(0xC0, 8, 8, luma444)),
+			wantQuality:  95,
+			wantStandard: true,
+			subsampling:  "4:0:0",
+			wantResaved:  true,
+			wantICCAdobe: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Analyze(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if tt.qualityApprox {
+				if diff := info.Quality - tt.wantQuality; diff < -3 || diff > 3 {
+					t.Errorf("Quality = %d, 期望约 %d", info.Quality, tt.wantQuality)
+				}
+			} else if info.Quality != tt.wantQuality {
+				t.Errorf("Quality = %d, 期望 %d", info.Quality, tt.wantQuality)
+			}
+			if info.ChromaQuality != tt.wantChroma {
+				t.Errorf("ChromaQuality = %d, 期望 %d", info.ChromaQuality, tt.wantChroma)
+			}
+			if info.StandardTable != tt.wantStandard {
+				t.Errorf("StandardTable = %v, 期望 %v", info.StandardTable, tt.wantStandard)
+			}
+			if info.Subsampling != tt.subsampling {
+				t.Errorf("Subsampling = %q, 期望 %q", info.Subsampling, tt.subsampling)
+			}
+			if info.Progressive != tt.progressive || info.Arithmetic != tt.arithmetic || info.Lossless != tt.lossless {
+				t.Errorf("编码方式 = 渐进%v/算术%v/无损%v", info.Progressive, info.Arithmetic, info.Lossless)
+			}
+			if info.RestartInterval != tt.restart {
+				t.Errorf("RestartInterval = %d, 期望 %d", info.RestartInterval, tt.restart)
+			}
+			if info.Resaved != tt.wantResaved || info.EXIF != tt.wantEXIF {
+				t.Errorf("Resaved = %v (%v), EXIF = %v", info.Resaved, info.ResaveHints, info.EXIF)
+			}
+			if tt.wantICCAdobe && (!info.ICC || !info.Adobe) {
+				t.Errorf("ICC = %v, Adobe = %v, 期望均为true", info.ICC, info.Adobe)
+			}
+		})
+	}
+}
+
+func TestAnalyzeInvalidInput(t *testing.T) {
+	valid := encodeJPEG(t, testImage(16, 16), 75)
+	sosAt := bytes.Index(valid, []byte{0xFF, markerSOS})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		notJPEG bool
+	}{
+		{"空数据", nil, true},
+		{"PNG签名", []byte("\x89PNG\r\n\x1a\n"), true},
+		{"只有SOI", []byte{0xFF, markerSOI}, false},
+		{"段长度被截断", []byte{0xFF, markerSOI, 0xFF, markerDQT, 0x00}, false},
+		{"段内容被截断", valid[:sosAt/2], false},
+		{"量化表不完整", []byte{0xFF, markerSOI, 0xFF, markerDQT, 0x00, 0x0A, 0x00, 1, 2, 3, 4, 5, 6, 7}, false},
+		{"缺少帧头", jpegFile(dqt(0, stdLuminance)), false},
+		{"标记前缀错误", []byte{0xFF, markerSOI, 0x00, markerDQT}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Analyze(bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatalf("期望错误，实际解析成功: %+v", info)
+			}
+			if tt.notJPEG != errors.Is(err, ErrNotJPEG) {
+				t.Errorf("errors.Is(err, ErrNotJPEG) = %v, 期望 %v (err: %v)", errors.Is(err, ErrNotJPEG), tt.notJPEG, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package mediaprobe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CICP色彩参数（ITU-T H.273）常用取值
+const (
+	PrimariesBT709     = 1
+	PrimariesBT2020    = 9
+	PrimariesDCIP3     = 11 // SMPTE RP 431-2（DCI白点）
+	PrimariesDisplayP3 = 12 // SMPTE EG 432-1（D65白点）
+
+	TransferBT709  = 1
+	TransferLinear = 8
+	TransferSRGB   = 13
+	TransferPQ     = 16 // SMPTE ST 2084
+	TransferHLG    = 18 // ARIB STD-B67
+
+	MatrixIdentity  = 0
+	MatrixBT709     = 1
+	MatrixBT601     = 6
+	MatrixBT2020NCL = 9
+)
+
+// ffprobe色彩名称到CICP取值的映射
+var (
+	ffprobePrimaries = map[string]int{
+		"bt709": 1, "bt470m": 4, "bt470bg": 5, "smpte170m": 6, "smpte240m": 7, "film": 8,
+		"bt2020": 9, "smpte428": 10, "smpte431": 11, "smpte432": 12, "jedec-p22": 22, "ebu3213": 22,
+	}
+	ffprobeTransfers = map[string]int{
+		"bt709": 1, "gamma22": 4, "gamma28": 5, "smpte170m": 6, "smpte240m": 7, "linear": 8,
+		"log100": 9, "log316": 10, "iec61966-2-4": 11, "bt1361e": 12, "iec61966-2-1": 13,
+		"bt2020-10": 14, "bt2020-12": 15, "smpte2084": 16, "smpte428": 17, "arib-std-b67": 18,
+	}
+	ffprobeMatrices = map[string]int{
+		"gbr": 0, "bt709": 1, "fcc": 4, "bt470bg": 5, "smpte170m": 6, "smpte240m": 7, "ycgco": 8,
+		"bt2020nc": 9, "bt2020c": 10, "smpte2085": 11, "chroma-derived-nc": 12, "chroma-derived-c": 13, "ictcp": 14,
+	}
+)
+
+// 广色域与HDR的ICC配置描述关键字（小写）
+var (
+	wideGamutICCKeywords = []string{"p3", "2020", "2100", "adobe rgb", "adobergb", "prophoto", "wide gamut", "dci"}
+	hdrICCKeywords       = []string{" pq", "hlg", "2084"}
+)
+
+// Color 色彩描述：CICP参数、位深、ICC配置与HDR母版元数据
+type Color struct {
+	Primaries int  // CICP色域，未声明时为0
+	Transfer  int  // CICP传输特性，未声明时为0
+	Matrix    int  // CICP矩阵系数
+	FullRange bool // 全范围
+	BitDepth  int  // 每通道位深
+
+	ICC            bool   // 嵌入了ICC配置
+	ICCDescription string // ICC配置描述（需要exiftool），如"Display P3"
+
+	Mastering *MasteringDisplay // 母版显示器元数据（SMPTE ST 2086），未声明时为nil
+	MaxCLL    int               // 最大内容亮度（cd/m²）
+	MaxFALL   int               // 最大帧平均亮度（cd/m²）
+}
+
+// MasteringDisplay 母版显示器的三原色、白点（CIE 1931 xy）与亮度范围（cd/m²）
+type MasteringDisplay struct {
+	Red, Green, Blue, WhitePoint [2]float64
+	MaxLuminance, MinLuminance   float64
+}
+
+// HDR 是否为PQ或HLG传输特性（CICP或ICC配置）
+func (c *Color) HDR() bool {
+	if c.Transfer == TransferPQ || c.Transfer == TransferHLG {
+		return true
+	}
+	return containsAny(c.ICCDescription, hdrICCKeywords)
+}
+
+// WideGamut 是否为超出sRGB的色域（Display P3、Rec.2020、Adobe RGB等）
+func (c *Color) WideGamut() bool {
+	switch c.Primaries {
+	case PrimariesBT2020, PrimariesDCIP3, PrimariesDisplayP3:
+		return true
+	}
+	return containsAny(c.ICCDescription, wideGamutICCKeywords)
+}
+
+// HighBitDepth 是否高于8位
+func (c *Color) HighBitDepth() bool {
+	return c.BitDepth > 8
+}
+
+// NeedsPreservation 是否需要在转换中显式保留色彩信息
+func (c *Color) NeedsPreservation() bool {
+	return c != nil && (c.HDR() || c.WideGamut() || c.HighBitDepth())
+}
+
+// String 简要描述，用于日志
+func (c *Color) String() string {
+	parts := []string{fmt.Sprintf("%d/%d/%d", c.Primaries, c.Transfer, c.Matrix), fmt.Sprintf("%dbit", c.BitDepth)}
+	if c.ICC {
+		parts = append(parts, "icc")
+		if c.ICCDescription != "" {
+			parts = append(parts, strconv.Quote(c.ICCDescription))
+		}
+	}
+	if c.HDR() {
+		parts = append(parts, "hdr")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Color 汇总文件的色彩描述：图像优先使用头部的CICP、位深与ICC标志，视频与不支持的格式使用FFprobe；
+// HDR文件额外读取FFprobe的母版元数据，嵌入ICC时读取exiftool的配置描述（exiftool不可用时忽略）
+func (r *ProbeResult) Color(ctx context.Context) (*Color, error) {
+	color := &Color{}
+	if info, err := r.Header(); err == nil {
+		color.Primaries = info.ColorPrimaries
+		color.Transfer = info.TransferCharacteristics
+		color.Matrix = info.MatrixCoefficients
+		color.FullRange = info.FullRange
+		color.BitDepth = info.BitDepth
+		color.ICC = info.HasICC
+		if color.HDR() {
+			if data, err := r.FFprobe(ctx); err == nil {
+				if video := data.VideoStream(); video != nil {
+					color.applySideData(video.SideDataList)
+				}
+			}
+		}
+	} else {
+		data, err := r.FFprobe(ctx)
+		if err != nil {
+			return nil, err
+		}
+		video := data.VideoStream()
+		if video == nil {
+			return nil, fmt.Errorf("没有视频流: %s", r.Path)
+		}
+		color.applyStream(video)
+	}
+
+	if color.ICC {
+		if exif, err := r.Exif(ctx); err == nil {
+			color.ICCDescription = exif.String("ProfileDescription")
+		}
+	}
+	return color, nil
+}
+
+// applyStream 读取ffprobe流的色彩参数、位深与附加数据
+func (c *Color) applyStream(stream *Stream) {
+	c.Primaries = ffprobePrimaries[stream.ColorPrimaries]
+	c.Transfer = ffprobeTransfers[stream.ColorTransfer]
+	c.Matrix = ffprobeMatrices[stream.ColorSpace]
+	c.FullRange = stream.ColorRange == "pc"
+	c.BitDepth = PixelFormatBitDepth(stream.PixFmt)
+	if bits, err := strconv.Atoi(stream.BitsPerRawSample); err == nil && bits > 0 {
+		c.BitDepth = bits
+	}
+	c.applySideData(stream.SideDataList)
+	for _, side := range stream.SideDataList {
+		if side["side_data_type"] == "ICC profile" {
+			c.ICC = true
+		}
+	}
+}
+
+// applySideData 读取母版显示器与内容亮度元数据
+func (c *Color) applySideData(sideData []map[string]any) {
+	for _, side := range sideData {
+		switch side["side_data_type"] {
+		case "Mastering display metadata":
+			mastering := &MasteringDisplay{
+				Red:          [2]float64{sideRational(side, "red_x"), sideRational(side, "red_y")},
+				Green:        [2]float64{sideRational(side, "green_x"), sideRational(side, "green_y")},
+				Blue:         [2]float64{sideRational(side, "blue_x"), sideRational(side, "blue_y")},
+				WhitePoint:   [2]float64{sideRational(side, "white_point_x"), sideRational(side, "white_point_y")},
+				MaxLuminance: sideRational(side, "max_luminance"),
+				MinLuminance: sideRational(side, "min_luminance"),
+			}
+			if mastering.MaxLuminance > 0 {
+				c.Mastering = mastering
+			}
+		case "Content light level metadata":
+			c.MaxCLL = int(sideRational(side, "max_content"))
+			c.MaxFALL = int(sideRational(side, "max_average"))
+		}
+	}
+}
+
+// sideRational 读取附加数据中"分子/分母"、字符串或数字形式的值
+func sideRational(side map[string]any, key string) float64 {
+	switch value := side[key].(type) {
+	case float64:
+		return value
+	case string:
+		return parseRate(value)
+	}
+	return 0
+}
+
+// PixelFormatBitDepth 由ffmpeg像素格式名估计每通道位深，无法识别时为8
+func PixelFormatBitDepth(pixFmt string) int {
+	for _, depth := range []struct {
+		marker string
+		bits   int
+	}{
+		{"f32", 32}, {"f16", 16}, {"p16", 16}, {"p016", 16}, {"gray16", 16}, {"ya16", 16}, {"48", 16}, {"64", 16},
+		{"p14", 14}, {"p12", 12}, {"p012", 12}, {"gray12", 12},
+		{"p10", 10}, {"p010", 10}, {"gray10", 10}, {"x2rgb10", 10}, {"x2bgr10", 10},
+	} {
+		if strings.Contains(pixFmt, depth.marker) {
+			return depth.bits
+		}
+	}
+	return 8
+}
+
+// containsAny 不区分大小写判断是否包含任一关键字
+func containsAny(s string, keywords []string) bool {
+	if s == "" {
+		return false
+	}
+	s = " " + strings.ToLower(s)
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
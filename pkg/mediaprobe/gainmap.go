@@ -0,0 +1,166 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// 增益图类型：携带增益图的照片在支持HDR的显示器上按增益图还原HDR渲染，
+// 不理解增益图的编码器转换时只保留SDR基础图像
+const (
+	GainMapUltraHDR = "ultrahdr" // JPEG：MPF副图 + hdrgm XMP（Ultra HDR / Adobe增益图）
+	GainMapISO      = "iso21496" // ISO 21496-1：AVIF/HEIF的tmap条目、JXL的jhgm盒、JPEG的ISO元数据段
+	GainMapApple    = "apple"    // Apple HDR增益图：HEIC辅助图像或JPEG的MPF副图
+)
+
+// 增益图标识
+var (
+	xmpSignature        = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	mpfSignature        = []byte("MPF\x00")
+	isoGainMapSignature = []byte("urn:iso:std:iso:ts:21496:-1\x00")
+	hdrgmNamespace      = []byte("http://ns.adobe.com/hdr-gain-map/1.0/")
+	appleGainMapMarker  = []byte("HDRGainMap")
+	appleGainMapAuxType = "urn:com:apple:photo:2020:aux:hdrgainmap"
+)
+
+// JPEG段扫描限制
+const (
+	jpegMaxSegments  = 64
+	mpfEntrySize     = 16
+	mpfMaxEntries    = 16
+	tiffTagMPEntries = 0xB002
+)
+
+// detectJPEGGainMap 在主图像与MPF副图的APP段中查找增益图元数据
+func detectJPEGGainMap(r io.ReaderAt, size int64) string {
+	primary := scanJPEGSegments(r, 0, size)
+	switch {
+	case primary.hdrgm:
+		return GainMapUltraHDR
+	case primary.iso:
+		return GainMapISO
+	case primary.mpfStart == 0:
+		return ""
+	}
+
+	for _, offset := range readMPFOffsets(r, primary.mpfStart, size) {
+		secondary := scanJPEGSegments(r, primary.mpfStart+offset, size)
+		switch {
+		case secondary.hdrgm:
+			return GainMapUltraHDR
+		case secondary.iso:
+			return GainMapISO
+		case secondary.apple:
+			return GainMapApple
+		}
+	}
+	return ""
+}
+
+// jpegSegments 单个JPEG图像头部段中的增益图线索
+type jpegSegments struct {
+	hdrgm    bool   // XMP声明了hdrgm命名空间
+	iso      bool   // ISO 21496-1元数据段
+	apple    bool   // Apple HDRGainMap XMP
+	mpfStart int64  // MPF段中TIFF头的位置，MPF偏移量以此为基准；没有MPF时为0
+	xmp      []byte // 第一个XMP段的内容
+}
+
+// scanJPEGSegments 扫描start处JPEG图像在扫描段之前的APP1/APP2段
+func scanJPEGSegments(r io.ReaderAt, start, size int64) jpegSegments {
+	var found jpegSegments
+	soi, err := readAt(r, start, 2)
+	if err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return found
+	}
+
+	offset := start + 2
+	for i := 0; i < jpegMaxSegments && offset+4 <= size; i++ {
+		header, err := readAt(r, offset, 4)
+		if err != nil || header[0] != 0xFF {
+			break
+		}
+		marker := header[1]
+		if marker == 0xFF { // 填充字节
+			offset++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // SOS/EOI
+			break
+		}
+		length := int64(be16(header[2:]))
+		if length < 2 {
+			break
+		}
+		if marker == 0xE1 || marker == 0xE2 {
+			segment, err := readAt(r, offset+4, int(length-2))
+			if err != nil {
+				break
+			}
+			switch {
+			case bytes.HasPrefix(segment, xmpSignature):
+				if found.xmp == nil {
+					found.xmp = segment[len(xmpSignature):]
+				}
+				found.hdrgm = found.hdrgm || bytes.Contains(segment, hdrgmNamespace)
+				found.apple = found.apple || bytes.Contains(segment, appleGainMapMarker)
+			case bytes.HasPrefix(segment, isoGainMapSignature):
+				found.iso = true
+			case bytes.HasPrefix(segment, mpfSignature) && found.mpfStart == 0:
+				found.mpfStart = offset + 4 + int64(len(mpfSignature))
+			}
+		}
+		offset += 2 + length
+	}
+	return found
+}
+
+// readMPFOffsets 读取MPF索引中各副图相对TIFF头的偏移（主图像偏移为0，不返回）
+func readMPFOffsets(r io.ReaderAt, tiffStart, size int64) []int64 {
+	header, err := readAt(r, tiffStart, 8)
+	if err != nil {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch string(header[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	ifd := tiffStart + int64(order.Uint32(header[4:]))
+	countBytes, err := readAt(r, ifd, 2)
+	if err != nil {
+		return nil
+	}
+	count := int(order.Uint16(countBytes))
+	entries, err := readAt(r, ifd+2, count*12)
+	if err != nil {
+		return nil
+	}
+
+	for i := 0; i+12 <= len(entries); i += 12 {
+		entry := entries[i : i+12]
+		if order.Uint16(entry[0:]) != tiffTagMPEntries {
+			continue
+		}
+		images := min(int(order.Uint32(entry[4:]))/mpfEntrySize, mpfMaxEntries)
+		data, err := readAt(r, tiffStart+int64(order.Uint32(entry[8:])), images*mpfEntrySize)
+		if err != nil {
+			return nil
+		}
+		var offsets []int64
+		for j := 0; j < images; j++ {
+			offset := int64(order.Uint32(data[j*mpfEntrySize+8:]))
+			if offset > 0 && tiffStart+offset < size {
+				offsets = append(offsets, offset)
+			}
+		}
+		return offsets
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// jpegSegment 构造带长度的JPEG段
+func jpegSegment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// jpegImage 由若干APP段与一个空扫描段组成的JPEG图像
+func jpegImage(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0x00, 0x00, 0xFF, 0xD9)
+}
+
+// xmpSegment XMP APP1段
+func xmpSegment(body string) []byte {
+	return jpegSegment(0xE1, append(append([]byte{}, xmpSignature...), body...))
+}
+
+// mpfSegment 大端MPF APP2段：MPEntry索引包含主图像与各副图相对TIFF头的偏移
+func mpfSegment(offsets ...uint32) []byte {
+	entries := len(offsets) + 1
+	tiff := []byte("MM\x00*\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, tiffTagMPEntries)
+	tiff = binary.BigEndian.AppendUint16(tiff, 7) // UNDEFINED
+	tiff = binary.BigEndian.AppendUint32(tiff, uint32(entries*mpfEntrySize))
+	tiff = binary.BigEndian.AppendUint32(tiff, 8+2+12+4)
+	tiff = binary.BigEndian.AppendUint32(tiff, 0)
+	for _, offset := range append([]uint32{0}, offsets...) {
+		entry := make([]byte, mpfEntrySize)
+		binary.BigEndian.PutUint32(entry[8:], offset)
+		tiff = append(tiff, entry...)
+	}
+	return jpegSegment(0xE2, append(append([]byte{}, mpfSignature...), tiff...))
+}
+
+// withSecondary 主图像后附加副图，并把MPF中第一个副图偏移改为其相对TIFF头的实际位置
+func withSecondary(primary, secondary []byte) []byte {
+	tiffStart := bytes.Index(primary, mpfSignature) + len(mpfSignature)
+	entry := tiffStart + 8 + 2 + 12 + 4 + mpfEntrySize
+	binary.BigEndian.PutUint32(primary[entry+8:], uint32(len(primary)-tiffStart))
+	return append(primary, secondary...)
+}
+
+func TestDetectJPEGGainMap(t *testing.T) {
+	hdrgmXMP := xmpSegment(`<x:xmpmeta xmlns:hdrgm="http://ns.adobe.com/hdr-gain-map/1.0/" hdrgm:Version="1.0"/>`)
+	appleXMP := xmpSegment(`<rdf:Description apdi:AuxiliaryImageType="urn:com:apple:photo:2020:aux:hdrgainmap" HDRGainMap="1"/>`)
+	isoSegment := jpegSegment(0xE2, append(append([]byte{}, isoGainMapSignature...), 0, 0))
+
+	truncatedMPF := jpegImage(mpfSegment(0))
+	// MPEntry声明的条目数远超段长度
+	countAt := bytes.Index(truncatedMPF, mpfSignature) + len(mpfSignature) + 8 + 2 + 4
+	binary.BigEndian.PutUint32(truncatedMPF[countAt:], 1<<20)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"无增益图", jpegImage(xmpSegment(`<x:xmpmeta/>`)), ""},
+		{"主图像hdrgm", jpegImage(hdrgmXMP), GainMapUltraHDR},
+		{"主图像ISO元数据段", jpegImage(isoSegment), GainMapISO},
+		{"MPF副图hdrgm", withSecondary(jpegImage(mpfSegment(0)), jpegImage(hdrgmXMP)), GainMapUltraHDR},
+		{"MPF副图ISO", withSecondary(jpegImage(mpfSegment(0)), jpegImage(isoSegment)), GainMapISO},
+		{"MPF副图Apple增益图", withSecondary(jpegImage(mpfSegment(0)), jpegImage(appleXMP)), GainMapApple},
+		{"MPF副图没有增益图", withSecondary(jpegImage(mpfSegment(0)), jpegImage()), ""},
+		{"MPF偏移超出文件", jpegImage(mpfSegment(1 << 20)), ""},
+		{"MPF条目被截断", truncatedMPF, ""},
+		{"副图被截断", withSecondary(jpegImage(mpfSegment(0)), []byte{0xFF}), ""},
+		{"不是JPEG", []byte("\x89PNG\r\n\x1a\n"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectJPEGGainMap(bytes.NewReader(tt.data), int64(len(tt.data))); got != tt.want {
+				t.Errorf("detectJPEGGainMap = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMPFOffsets(t *testing.T) {
+	image := jpegImage(mpfSegment(100, 200))
+	tiffStart := int64(bytes.Index(image, mpfSignature) + len(mpfSignature))
+	padded := append(image, make([]byte, 256)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []int64
+	}{
+		{"两个副图", padded, []int64{100, 200}},
+		{"偏移超出文件的副图被忽略", image, nil},
+		{"TIFF头被截断", image[:tiffStart+4], nil},
+		{"IFD被截断", image[:tiffStart+12], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readMPFOffsets(bytes.NewReader(tt.data), tiffStart, int64(len(tt.data)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("readMPFOffsets = %v, 期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("readMPFOffsets = %v, 期望 %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package mediaprobe
+
+import (
+	"bufio"
+	"io"
+)
+
+// GIF块标识
+const (
+	gifExtension       = 0x21
+	gifImageDescriptor = 0x2C
+	gifTrailer         = 0x3B
+	gifGraphicControl  = 0xF9
+)
+
+// probeGIF 逐块跳读GIF统计帧数（不做LZW解码），图形控制扩展声明透明色时视为有透明通道
+func probeGIF(r io.ReaderAt, size int64) (*Info, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r, 0, size))
+
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, ErrMalformed
+	}
+	info := &Info{
+		Format:     "gif",
+		Codec:      "gif",
+		Width:      le16(header[6:]),
+		Height:     le16(header[8:]),
+		BitDepth:   8,
+		ColorModel: "palette",
+	}
+	if header[10]&0x80 != 0 {
+		if _, err := reader.Discard(3 << (header[10]&0x07 + 1)); err != nil {
+			return nil, ErrMalformed
+		}
+	}
+
+	frames := 0
+	for done := false; !done; {
+		block, err := reader.ReadByte()
+		if err != nil {
+			break // 截断的文件按已读取的帧计算
+		}
+
+		switch block {
+		case gifExtension:
+			label, err := reader.ReadByte()
+			if err != nil {
+				done = true
+				break
+			}
+			first, err := readSubBlock(reader)
+			if err != nil {
+				done = true
+				break
+			}
+			if label == gifGraphicControl && len(first) >= 4 && first[0]&0x01 != 0 {
+				info.HasAlpha = true
+			}
+			if len(first) > 0 && skipSubBlocks(reader) != nil {
+				done = true
+			}
+
+		case gifImageDescriptor:
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(reader, descriptor); err != nil {
+				done = true
+				break
+			}
+			if descriptor[8]&0x80 != 0 {
+				if _, err := reader.Discard(3 << (descriptor[8]&0x07 + 1)); err != nil {
+					done = true
+					break
+				}
+			}
+			// LZW最小码长之后是图像数据子块
+			if _, err := reader.ReadByte(); err != nil {
+				done = true
+				break
+			}
+			if skipSubBlocks(reader) != nil {
+				done = true
+				break
+			}
+			frames++
+
+		case gifTrailer:
+			done = true
+
+		default:
+			// 数据损坏，按已读取的帧计算
+			done = true
+		}
+	}
+
+	if frames == 0 {
+		return nil, ErrMalformed
+	}
+	info.FrameCount = frames
+	info.Animated = frames > 1
+	return info, nil
+}
+
+// readSubBlock 读取一个数据子块，长度为0（块结束）时返回空切片
+func readSubBlock(reader *bufio.Reader) ([]byte, error) {
+	length, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// skipSubBlocks 跳过数据子块直到块结束标记
+func skipSubBlocks(reader *bufio.Reader) error {
+	for {
+		length, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if length == 0 {
+			return nil
+		}
+		if _, err := reader.Discard(int(length)); err != nil {
+			return err
+		}
+	}
+}
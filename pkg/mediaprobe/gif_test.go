@@ -0,0 +1,97 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// gifHeader GIF文件头与逻辑屏幕描述符，globalTable为全局色表的大小指数（-1表示没有色表）
+func gifHeader(width, height uint16, globalTable int) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, width)
+	data = binary.LittleEndian.AppendUint16(data, height)
+	flags := byte(0)
+	if globalTable >= 0 {
+		flags = 0x80 | byte(globalTable)
+	}
+	data = append(data, flags, 0, 0)
+	if globalTable >= 0 {
+		data = append(data, make([]byte, 3<<(globalTable+1))...)
+	}
+	return data
+}
+
+// gifFrame 一帧：可选的图形控制扩展（transparent声明透明色）+ 图像描述符 + 图像数据子块
+func gifFrame(transparent bool) []byte {
+	flags := byte(0)
+	if transparent {
+		flags = 0x01
+	}
+	data := []byte{gifExtension, gifGraphicControl, 4, flags, 10, 0, 0, 0}
+	data = append(data, gifImageDescriptor, 0, 0, 0, 0, 1, 0, 1, 0, 0)
+	return append(data, 2, 2, 0x4C, 0x01, 0)
+}
+
+// gifFile 文件头 + 各帧 + 结束标记
+func gifFile(header []byte, frames ...[]byte) []byte {
+	data := append([]byte(nil), header...)
+	for _, frame := range frames {
+		data = append(data, frame...)
+	}
+	return append(data, gifTrailer)
+}
+
+func TestProbeGIF(t *testing.T) {
+	comment := []byte{gifExtension, 0xFE, 5, 'h', 'e', 'l', 'l', 'o', 3, 'a', 'b', 'c', 0}
+	withComment := gifFile(gifHeader(8, 8, 1), comment, gifFrame(false))
+	threeFrames := gifFile(gifHeader(320, 240, 7), gifFrame(false), gifFrame(true), gifFrame(false))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		width    int
+		height   int
+		frames   int
+		animated bool
+		alpha    bool
+		fail     bool
+	}{
+		{"单帧无色表", gifFile(gifHeader(16, 16, -1), gifFrame(false)), 16, 16, 1, false, false, false},
+		{"三帧带透明", threeFrames, 320, 240, 3, true, true, false},
+		{"注释扩展多个子块", withComment, 8, 8, 1, false, false, false},
+		{"缺少结束标记时按已读取的帧", threeFrames[:len(threeFrames)-1], 320, 240, 3, true, true, false},
+		{"第三帧被截断时保留前两帧", threeFrames[:len(threeFrames)-4], 320, 240, 2, true, true, false},
+		{"未知块时停止", append(append(gifHeader(8, 8, -1), gifFrame(false)...), 0x99, gifImageDescriptor), 8, 8, 1, false, false, false},
+		{"没有帧", gifFile(gifHeader(8, 8, -1)), 0, 0, 0, false, false, true},
+		{"逻辑屏幕描述符被截断", []byte("GIF89a\x08\x00\x08"), 0, 0, 0, false, false, true},
+		{"全局色表被截断", gifHeader(8, 8, 7)[:100], 0, 0, 0, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.fail {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("期望 ErrMalformed，实际为 %v (%+v)", err, info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != "gif" || info.Codec != "gif" || info.ColorModel != "palette" || info.BitDepth != 8 {
+				t.Errorf("格式 = %+v", info)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.FrameCount != tt.frames || info.Animated != tt.animated {
+				t.Errorf("FrameCount/Animated = %d/%v, 期望 %d/%v", info.FrameCount, info.Animated, tt.frames, tt.animated)
+			}
+			if info.HasAlpha != tt.alpha {
+				t.Errorf("HasAlpha = %v, 期望 %v", info.HasAlpha, tt.alpha)
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"io"
+)
+
+// 表示透明通道辅助图像的auxC类型
+var alphaAuxTypes = []string{
+	"urn:mpeg:mpegB:cicp:systems:auxiliary:alpha",
+	"urn:mpeg:hevc:2015:auxid:1",
+}
+
+// box ISOBMFF盒
+type box struct {
+	kind string
+	data int64 // 数据起始位置
+	end  int64
+}
+
+// itemProperty ipco中的图像属性
+type itemProperty struct {
+	kind          string
+	width, height int
+	bitDepth      int
+	channels      int
+	icc           bool
+	nclx          []byte // 原始nclx参数：primaries(2) transfer(2) matrix(2) flags(1)
+	alpha         bool
+	gainMap       bool // Apple HDR增益图辅助图像
+}
+
+// isobmffImage 从meta与moov收集的信息
+type isobmffImage struct {
+	brands     []string
+	primary    uint32
+	properties []itemProperty
+	// 条目ID -> 属性序号（从1开始）
+	associations map[uint32][]int
+	alphaAux     bool
+	gainMap      string
+
+	trackFrames        int
+	trackWidth         int
+	trackHeight        int
+	trackAlpha         bool
+	trackCodec         string
+	sawMeta, sawTracks bool
+}
+
+// probeISOBMFF 解析AVIF/HEIF：ftyp品牌、meta中主图像的ispe/pixi/colr/auxC属性，以及图像序列轨道的帧数
+func probeISOBMFF(r io.ReaderAt, size int64) (*Info, error) {
+	image := &isobmffImage{associations: make(map[uint32][]int)}
+
+	for offset := int64(0); offset+8 <= size; {
+		b, err := readBox(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		switch b.kind {
+		case "ftyp":
+			if err := image.parseFtyp(r, b); err != nil {
+				return nil, err
+			}
+			if format, _ := image.format(); format == "" {
+				return nil, ErrUnsupported // MP4/MOV等视频容器
+			}
+		case "meta":
+			if err := image.parseMeta(r, b); err != nil {
+				return nil, err
+			}
+		case "moov":
+			if err := image.parseMoov(r, b); err != nil {
+				return nil, err
+			}
+		}
+		offset = b.end
+	}
+
+	format, codec := image.format()
+	if format == "" || (!image.sawMeta && !image.sawTracks) {
+		return nil, ErrMalformed
+	}
+	info := &Info{Format: format, Codec: codec, BitDepth: 8, ColorModel: "ycbcr"}
+	image.applyPrimary(info)
+
+	if image.trackFrames > 1 {
+		info.Animated = true
+		info.FrameCount = image.trackFrames
+		if info.Width == 0 {
+			info.Width, info.Height = image.trackWidth, image.trackHeight
+		}
+		if image.trackAlpha {
+			info.HasAlpha = true
+		}
+		if image.trackCodec != "" {
+			info.Codec = image.trackCodec
+		}
+	} else {
+		info.FrameCount = 1
+	}
+	if info.Width == 0 || info.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return info, nil
+}
+
+// readBox 读取offset处的盒头
+func readBox(r io.ReaderAt, offset, limit int64) (box, error) {
+	header, err := readAt(r, offset, 8)
+	if err != nil {
+		return box{}, err
+	}
+	b := box{kind: string(header[4:8]), data: offset + 8}
+	size := be32(header)
+	switch size {
+	case 0:
+		b.end = limit // 延伸到文件末尾
+	case 1:
+		large, err := readAt(r, offset+8, 8)
+		if err != nil {
+			return box{}, err
+		}
+		b.data += 8
+		b.end = offset + (be32(large[0:4])<<32 | be32(large[4:8]))
+	default:
+		b.end = offset + size
+	}
+	if b.end < b.data || b.end > limit {
+		return box{}, ErrMalformed
+	}
+	return b, nil
+}
+
+// children 遍历容器盒的子盒，skip为子盒数据前需要跳过的字节数（FullBox为4）
+func children(r io.ReaderAt, parent box, skip int64, visit func(box) error) error {
+	for offset := parent.data + skip; offset+8 <= parent.end; {
+		b, err := readBox(r, offset, parent.end)
+		if err != nil {
+			return err
+		}
+		if err := visit(b); err != nil {
+			return err
+		}
+		offset = b.end
+	}
+	return nil
+}
+
+// boxData 读取盒的全部数据（用于很小的属性盒）
+func boxData(r io.ReaderAt, b box) ([]byte, error) {
+	if b.end-b.data > 1<<16 {
+		return nil, ErrMalformed
+	}
+	return readAt(r, b.data, int(b.end-b.data))
+}
+
+// parseFtyp 读取主品牌与兼容品牌
+func (img *isobmffImage) parseFtyp(r io.ReaderAt, b box) error {
+	data, err := boxData(r, b)
+	if err != nil {
+		return err
+	}
+	if len(data) < 8 {
+		return ErrMalformed
+	}
+	img.brands = append(img.brands, string(data[0:4]))
+	for i := 8; i+4 <= len(data); i += 4 {
+		img.brands = append(img.brands, string(data[i:i+4]))
+	}
+	return nil
+}
+
+// format 按品牌确定格式与默认编解码器，不是图像品牌时返回空字符串
+func (img *isobmffImage) format() (string, string) {
+	switch {
+	case img.hasBrand("avif", "avis"):
+		return "avif", "av1"
+	case img.hasBrand("heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1"):
+		return "heif", "hevc"
+	}
+	return "", ""
+}
+
+// hasBrand 是否包含任一品牌
+func (img *isobmffImage) hasBrand(brands ...string) bool {
+	for _, have := range img.brands {
+		for _, want := range brands {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseMeta 解析pitm与iprp（ipco属性、ipma关联）
+func (img *isobmffImage) parseMeta(r io.ReaderAt, meta box) error {
+	img.sawMeta = true
+	return children(r, meta, 4, func(b box) error {
+		switch b.kind {
+		case "pitm":
+			data, err := boxData(r, b)
+			if err != nil {
+				return err
+			}
+			if len(data) >= 6 && data[0] == 0 {
+				img.primary = uint32(be16(data[4:]))
+			} else if len(data) >= 8 {
+				img.primary = uint32(be32(data[4:]))
+			}
+		case "iprp":
+			return children(r, b, 0, func(child box) error {
+				switch child.kind {
+				case "ipco":
+					return children(r, child, 0, func(property box) error {
+						parsed, err := parseProperty(r, property)
+						if err != nil {
+							return err
+						}
+						if parsed.alpha {
+							img.alphaAux = true
+						}
+						if parsed.gainMap && img.gainMap == "" {
+							img.gainMap = GainMapApple
+						}
+						img.properties = append(img.properties, parsed)
+						return nil
+					})
+				case "ipma":
+					return img.parseIpma(r, child)
+				}
+				return nil
+			})
+		case "iinf":
+			return img.parseIinf(r, b)
+		}
+		return nil
+	})
+}
+
+// parseIinf 查找ISO 21496-1增益图条目（条目类型tmap）
+func (img *isobmffImage) parseIinf(r io.ReaderAt, iinf box) error {
+	version, err := readAt(r, iinf.data, 1)
+	if err != nil {
+		return err
+	}
+	skip := int64(4 + 2) // FullBox头 + 条目数
+	if version[0] != 0 {
+		skip = 4 + 4
+	}
+	return children(r, iinf, skip, func(infe box) error {
+		if infe.kind != "infe" {
+			return nil
+		}
+		header, err := readAt(r, infe.data, 4)
+		if err != nil || header[0] < 2 {
+			return nil // 版本0/1的条目没有条目类型
+		}
+		typeOffset := infe.data + 4 + 2 + 2 // 条目ID(2) + 保护序号(2)
+		if header[0] >= 3 {
+			typeOffset += 2 // 版本3的条目ID为4字节
+		}
+		itemType, err := readAt(r, typeOffset, 4)
+		if err == nil && string(itemType) == "tmap" {
+			img.gainMap = GainMapISO
+		}
+		return nil
+	})
+}
+
+// parseProperty 解析单个属性盒，未识别的属性只记录类型以保持序号
+func parseProperty(r io.ReaderAt, b box) (itemProperty, error) {
+	property := itemProperty{kind: b.kind}
+	switch b.kind {
+	case "ispe", "pixi", "colr", "auxC":
+	default:
+		return property, nil
+	}
+
+	data, err := boxData(r, b)
+	if err != nil {
+		return property, err
+	}
+	switch b.kind {
+	case "ispe":
+		if len(data) >= 12 {
+			property.width = int(be32(data[4:]))
+			property.height = int(be32(data[8:]))
+		}
+	case "pixi":
+		if len(data) >= 6 {
+			property.channels = int(data[4])
+			property.bitDepth = int(data[5])
+		}
+	case "colr":
+		if len(data) >= 4 {
+			switch string(data[0:4]) {
+			case "nclx":
+				if len(data) >= 11 {
+					property.nclx = data[4:11]
+				}
+			case "rICC", "prof":
+				property.icc = true
+			}
+		}
+	case "auxC":
+		if len(data) > 4 {
+			auxType := string(bytes.TrimRight(data[4:], "\x00"))
+			for _, alpha := range alphaAuxTypes {
+				if auxType == alpha {
+					property.alpha = true
+				}
+			}
+			property.gainMap = auxType == appleGainMapAuxType
+		}
+	}
+	return property, nil
+}
+
+// parseIpma 解析条目与属性的关联
+func (img *isobmffImage) parseIpma(r io.ReaderAt, b box) error {
+	data, err := boxData(r, b)
+	if err != nil {
+		return err
+	}
+	if len(data) < 8 {
+		return ErrMalformed
+	}
+	version := data[0]
+	largeIndex := data[3]&0x01 != 0
+	count := int(be32(data[4:]))
+	pos := 8
+
+	for i := 0; i < count; i++ {
+		var itemID uint32
+		if version < 1 {
+			if pos+2 > len(data) {
+				return ErrMalformed
+			}
+			itemID = uint32(be16(data[pos:]))
+			pos += 2
+		} else {
+			if pos+4 > len(data) {
+				return ErrMalformed
+			}
+			itemID = uint32(be32(data[pos:]))
+			pos += 4
+		}
+		if pos >= len(data) {
+			return ErrMalformed
+		}
+		associations := int(data[pos])
+		pos++
+
+		for j := 0; j < associations; j++ {
+			var index int
+			if largeIndex {
+				if pos+2 > len(data) {
+					return ErrMalformed
+				}
+				index = be16(data[pos:]) & 0x7FFF
+				pos += 2
+			} else {
+				if pos >= len(data) {
+					return ErrMalformed
+				}
+				index = int(data[pos] & 0x7F)
+				pos++
+			}
+			if index > 0 {
+				img.associations[itemID] = append(img.associations[itemID], index)
+			}
+		}
+	}
+	return nil
+}
+
+// applyPrimary 以主图像关联的属性填充信息；没有关联信息时取最大的ispe
+func (img *isobmffImage) applyPrimary(info *Info) {
+	var properties []itemProperty
+	if indexes, ok := img.associations[img.primary]; ok {
+		for _, index := range indexes {
+			if index <= len(img.properties) {
+				properties = append(properties, img.properties[index-1])
+			}
+		}
+	} else {
+		var largest *itemProperty
+		for i := range img.properties {
+			p := &img.properties[i]
+			if p.kind == "ispe" && (largest == nil || p.width*p.height > largest.width*largest.height) {
+				largest = p
+			}
+		}
+		if largest != nil {
+			properties = append(properties, *largest)
+		}
+	}
+
+	for _, property := range properties {
+		switch property.kind {
+		case "ispe":
+			info.Width, info.Height = property.width, property.height
+		case "pixi":
+			if property.bitDepth > 0 {
+				info.BitDepth = property.bitDepth
+			}
+			if property.channels == 1 {
+				info.ColorModel = "gray"
+			}
+		case "colr":
+			if property.icc {
+				info.HasICC = true
+			}
+			if len(property.nclx) == 7 {
+				info.ColorPrimaries = be16(property.nclx[0:])
+				info.TransferCharacteristics = be16(property.nclx[2:])
+				info.MatrixCoefficients = be16(property.nclx[4:])
+				info.FullRange = property.nclx[6]&0x80 != 0
+				if info.MatrixCoefficients == 0 && info.ColorModel == "ycbcr" {
+					info.ColorModel = "rgb" // 矩阵系数0为恒等变换
+				}
+			}
+		}
+	}
+	info.HasAlpha = img.alphaAux
+	info.GainMap = img.gainMap
+}
+
+// parseMoov 读取图像序列轨道（pict/vide）的样本数与尺寸，auxv轨道表示透明通道
+func (img *isobmffImage) parseMoov(r io.ReaderAt, moov box) error {
+	img.sawTracks = true
+	return children(r, moov, 0, func(trak box) error {
+		if trak.kind != "trak" {
+			return nil
+		}
+
+		var handler, codec string
+		var width, height, samples int
+		err := children(r, trak, 0, func(b box) error {
+			switch b.kind {
+			case "tkhd":
+				data, err := boxData(r, b)
+				if err != nil {
+					return err
+				}
+				if len(data) >= 8 {
+					// 宽高为结尾的两个16.16定点数
+					width = int(be32(data[len(data)-8:]) >> 16)
+					height = int(be32(data[len(data)-4:]) >> 16)
+				}
+			case "mdia":
+				return children(r, b, 0, func(child box) error {
+					switch child.kind {
+					case "hdlr":
+						data, err := readAt(r, child.data, 12)
+						if err != nil {
+							return err
+						}
+						handler = string(data[8:12])
+					case "minf":
+						return children(r, child, 0, func(stblParent box) error {
+							if stblParent.kind != "stbl" {
+								return nil
+							}
+							return children(r, stblParent, 0, func(table box) error {
+								switch table.kind {
+								case "stsz":
+									data, err := readAt(r, table.data, 12)
+									if err != nil {
+										return err
+									}
+									samples = int(be32(data[8:]))
+								case "stsd":
+									// FullBox + 条目数，之后是第一个样本条目的盒头
+									data, err := readAt(r, table.data+8, 8)
+									if err == nil {
+										codec = sampleEntryCodec(string(data[4:8]))
+									}
+								}
+								return nil
+							})
+						})
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		switch handler {
+		case "pict", "vide":
+			if samples > img.trackFrames {
+				img.trackFrames = samples
+				img.trackWidth, img.trackHeight = width, height
+				img.trackCodec = codec
+			}
+		case "auxv":
+			img.trackAlpha = true
+		}
+		return nil
+	})
+}
+
+// sampleEntryCodec 样本条目类型对应的编解码器名称
+func sampleEntryCodec(entry string) string {
+	switch entry {
+	case "av01":
+		return "av1"
+	case "hvc1", "hev1":
+		return "hevc"
+	}
+	return ""
+}
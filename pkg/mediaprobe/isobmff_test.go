@@ -0,0 +1,217 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// isoBox 构造ISOBMFF盒
+func isoBox(kind string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, kind...), body...)
+}
+
+// fullBox 带版本与标志的盒
+func fullBox(kind string, version byte, payload ...[]byte) []byte {
+	return isoBox(kind, append([][]byte{{version, 0, 0, 0}}, payload...)...)
+}
+
+// be32Bytes 大端32位整数
+func be32Bytes(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// ftypBox 主品牌与兼容品牌
+func ftypBox(major string, compatible ...string) []byte {
+	data := append([]byte(major), 0, 0, 0, 0)
+	for _, brand := range compatible {
+		data = append(data, brand...)
+	}
+	return isoBox("ftyp", data)
+}
+
+// ispeBox 图像尺寸属性
+func ispeBox(width, height uint32) []byte {
+	return fullBox("ispe", 0, be32Bytes(width), be32Bytes(height))
+}
+
+// pixiBox 通道数与位深属性
+func pixiBox(channels, depth byte) []byte {
+	data := []byte{channels}
+	for i := byte(0); i < channels; i++ {
+		data = append(data, depth)
+	}
+	return fullBox("pixi", 0, data)
+}
+
+// nclxBox CICP色彩属性
+func nclxBox(primaries, transfer, matrix uint16, fullRange bool) []byte {
+	data := []byte("nclx")
+	data = binary.BigEndian.AppendUint16(data, primaries)
+	data = binary.BigEndian.AppendUint16(data, transfer)
+	data = binary.BigEndian.AppendUint16(data, matrix)
+	flags := byte(0)
+	if fullRange {
+		flags = 0x80
+	}
+	return isoBox("colr", append(data, flags))
+}
+
+// auxCBox 辅助图像类型属性
+func auxCBox(auxType string) []byte {
+	return fullBox("auxC", 0, append([]byte(auxType), 0))
+}
+
+// ipmaBox 版本0的属性关联：条目ID -> 属性序号（从1开始）
+func ipmaBox(associations map[uint16][]byte) []byte {
+	data := be32Bytes(uint32(len(associations)))
+	for _, item := range []uint16{1, 2, 3} {
+		indexes, ok := associations[item]
+		if !ok {
+			continue
+		}
+		data = binary.BigEndian.AppendUint16(data, item)
+		data = append(data, byte(len(indexes)))
+		data = append(data, indexes...)
+	}
+	return fullBox("ipma", 0, data)
+}
+
+// heifMeta 包含主图像条目1与给定属性、关联的meta盒
+func heifMeta(ipma []byte, properties ...[]byte) []byte {
+	pitm := fullBox("pitm", 0, []byte{0, 1})
+	children := [][]byte{pitm, isoBox("iprp", isoBox("ipco", properties...), ipma)}
+	if ipma == nil {
+		children[1] = isoBox("iprp", isoBox("ipco", properties...))
+	}
+	return fullBox("meta", 0, children...)
+}
+
+// imageSequence 图像序列的moov：handler轨道类型、样本数与样本条目类型
+func imageSequence(handler, entry string, samples, width, height uint32) []byte {
+	tkhd := fullBox("tkhd", 0, make([]byte, 72), be32Bytes(width<<16), be32Bytes(height<<16))
+	hdlr := fullBox("hdlr", 0, make([]byte, 4), []byte(handler), make([]byte, 13))
+	stsd := fullBox("stsd", 0, be32Bytes(1), isoBox(entry, make([]byte, 78)))
+	stsz := fullBox("stsz", 0, be32Bytes(0), be32Bytes(samples))
+	stbl := isoBox("stbl", stsd, stsz)
+	mdia := isoBox("mdia", hdlr, isoBox("minf", stbl))
+	return isoBox("trak", tkhd, mdia)
+}
+
+func TestProbeISOBMFF(t *testing.T) {
+	avifFtyp := ftypBox("avif", "mif1", "avif", "miaf")
+	still := heifMeta(ipmaBox(map[uint16][]byte{1: {1, 0x80 | 2, 3}, 2: {4, 5}}),
+		ispeBox(4000, 3000), pixiBox(3, 10), nclxBox(9, 16, 9, true),
+		ispeBox(4000, 3000), auxCBox(alphaAuxTypes[0]))
+	gray := heifMeta(ipmaBox(map[uint16][]byte{1: {1, 2, 3}}), ispeBox(64, 64), pixiBox(1, 8), nclxBox(1, 13, 6, false))
+	identity := heifMeta(ipmaBox(map[uint16][]byte{1: {1, 2, 3}}), ispeBox(64, 64), pixiBox(3, 8), nclxBox(1, 13, 0, false))
+	// 没有关联信息时取最大的ispe（缩略图与主图像）
+	noIpma := heifMeta(nil, ispeBox(160, 120), ispeBox(1920, 1080))
+	iccMeta := heifMeta(ipmaBox(map[uint16][]byte{1: {1, 2}}), ispeBox(100, 100), isoBox("colr", []byte("prof"), []byte("icc")))
+	appleGainMap := heifMeta(ipmaBox(map[uint16][]byte{1: {1}, 2: {2, 3}}), ispeBox(4032, 3024), ispeBox(2016, 1512), auxCBox(appleGainMapAuxType))
+	iinf := fullBox("iinf", 0, []byte{0, 2},
+		fullBox("infe", 2, []byte{0, 1, 0, 0}, []byte("av01"), []byte{0}),
+		fullBox("infe", 2, []byte{0, 2, 0, 0}, []byte("tmap"), []byte{0}))
+	isoGainMap := fullBox("meta", 0, fullBox("pitm", 0, []byte{0, 1}), iinf,
+		isoBox("iprp", isoBox("ipco", ispeBox(800, 600)), ipmaBox(map[uint16][]byte{1: {1}})))
+	sequence := isoBox("moov", imageSequence("pict", "av01", 24, 320, 240), imageSequence("auxv", "av01", 24, 320, 240))
+
+	// size为1的64位长度盒
+	large := append(be32Bytes(1), "meta"...)
+	largeBody := bytes.Join([][]byte{{0, 0, 0, 0}, fullBox("pitm", 0, []byte{0, 1}), isoBox("iprp", isoBox("ipco", ispeBox(50, 40)))}, nil)
+	large = binary.BigEndian.AppendUint64(large, uint64(16+len(largeBody)))
+	large = append(large, largeBody...)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		format     string
+		codec      string
+		width      int
+		height     int
+		frames     int
+		animated   bool
+		bitDepth   int
+		colorModel string
+		alpha      bool
+		icc        bool
+		cicp       [3]int
+		fullRange  bool
+		gainMap    string
+	}{
+		{"AVIF主图像的关联属性", bytes.Join([][]byte{avifFtyp, still}, nil), "avif", "av1", 4000, 3000, 1, false, 10, "ycbcr", true, false, [3]int{9, 16, 9}, true, ""},
+		{"单通道为灰度", bytes.Join([][]byte{avifFtyp, gray}, nil), "avif", "av1", 64, 64, 1, false, 8, "gray", false, false, [3]int{1, 13, 6}, false, ""},
+		{"矩阵系数0为RGB", bytes.Join([][]byte{avifFtyp, identity}, nil), "avif", "av1", 64, 64, 1, false, 8, "rgb", false, false, [3]int{1, 13, 0}, false, ""},
+		{"HEIC品牌", bytes.Join([][]byte{ftypBox("heic", "mif1", "heic"), iccMeta}, nil), "heif", "hevc", 100, 100, 1, false, 8, "ycbcr", false, true, [3]int{}, false, ""},
+		{"只有mif1兼容品牌", bytes.Join([][]byte{ftypBox("mif1", "mif1"), noIpma}, nil), "heif", "hevc", 1920, 1080, 1, false, 8, "ycbcr", false, false, [3]int{}, false, ""},
+		{"Apple增益图辅助图像", bytes.Join([][]byte{ftypBox("heic", "mif1"), appleGainMap}, nil), "heif", "hevc", 4032, 3024, 1, false, 8, "ycbcr", false, false, [3]int{}, false, GainMapApple},
+		{"ISO增益图tmap条目", bytes.Join([][]byte{avifFtyp, isoGainMap}, nil), "avif", "av1", 800, 600, 1, false, 8, "ycbcr", false, false, [3]int{}, false, GainMapISO},
+		{"AVIF图像序列", bytes.Join([][]byte{ftypBox("avis", "avis", "msf1"), sequence}, nil), "avif", "av1", 320, 240, 24, true, 8, "ycbcr", true, false, [3]int{}, false, ""},
+		{"64位长度盒", bytes.Join([][]byte{avifFtyp, large}, nil), "avif", "av1", 50, 40, 1, false, 8, "ycbcr", false, false, [3]int{}, false, ""},
+		{"长度0的盒延伸到文件末尾", bytes.Join([][]byte{avifFtyp, identity, append(be32Bytes(0), "mdat"...), make([]byte, 32)}, nil), "avif", "av1", 64, 64, 1, false, 8, "rgb", false, false, [3]int{1, 13, 0}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != tt.format || info.Codec != tt.codec {
+				t.Errorf("格式 = %s/%s, 期望 %s/%s", info.Format, info.Codec, tt.format, tt.codec)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.FrameCount != tt.frames || info.Animated != tt.animated {
+				t.Errorf("FrameCount/Animated = %d/%v, 期望 %d/%v", info.FrameCount, info.Animated, tt.frames, tt.animated)
+			}
+			if info.BitDepth != tt.bitDepth || info.ColorModel != tt.colorModel {
+				t.Errorf("位深/色彩模型 = %d/%s, 期望 %d/%s", info.BitDepth, info.ColorModel, tt.bitDepth, tt.colorModel)
+			}
+			if info.HasAlpha != tt.alpha || info.HasICC != tt.icc {
+				t.Errorf("透明/ICC = %v/%v, 期望 %v/%v", info.HasAlpha, info.HasICC, tt.alpha, tt.icc)
+			}
+			cicp := [3]int{info.ColorPrimaries, info.TransferCharacteristics, info.MatrixCoefficients}
+			if cicp != tt.cicp || info.FullRange != tt.fullRange {
+				t.Errorf("CICP = %v/%v, 期望 %v/%v", cicp, info.FullRange, tt.cicp, tt.fullRange)
+			}
+			if info.GainMap != tt.gainMap {
+				t.Errorf("GainMap = %q, 期望 %q", info.GainMap, tt.gainMap)
+			}
+		})
+	}
+}
+
+func TestProbeISOBMFFMalformed(t *testing.T) {
+	avifFtyp := ftypBox("avif", "mif1")
+	meta := heifMeta(ipmaBox(map[uint16][]byte{1: {1}}), ispeBox(64, 64))
+	truncatedIpma := fullBox("meta", 0, fullBox("pitm", 0, []byte{0, 1}),
+		isoBox("iprp", isoBox("ipco", ispeBox(64, 64)), fullBox("ipma", 0, be32Bytes(2), []byte{0, 1, 1, 1})))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"MP4视频容器", bytes.Join([][]byte{ftypBox("isom", "isom", "mp42"), isoBox("moov")}, nil), ErrUnsupported},
+		{"ftyp过短", bytes.Join([][]byte{isoBox("ftyp", []byte("avif")), meta}, nil), ErrMalformed},
+		{"没有meta与moov", avifFtyp, ErrMalformed},
+		{"没有尺寸属性", bytes.Join([][]byte{avifFtyp, heifMeta(nil, pixiBox(3, 8))}, nil), ErrMalformed},
+		{"盒长度越过文件末尾", bytes.Join([][]byte{avifFtyp, meta[:len(meta)-4]}, nil), ErrMalformed},
+		{"盒长度小于盒头", bytes.Join([][]byte{avifFtyp, be32Bytes(4), []byte("meta")}, nil), ErrMalformed},
+		{"ipma条目被截断", bytes.Join([][]byte{avifFtyp, truncatedIpma}, nil), ErrMalformed},
+		{"属性盒过大", bytes.Join([][]byte{avifFtyp, heifMeta(nil, isoBox("ispe", make([]byte, 1<<16+1)))}, nil), ErrMalformed},
+		{"轨道样本表被截断", bytes.Join([][]byte{ftypBox("avis", "avis"), isoBox("moov", isoBox("trak", isoBox("mdia", isoBox("hdlr", []byte{0, 0}))))}, nil), ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("期望 %v，实际为 %v (%+v)", tt.want, err, info)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package mediaprobe
+
+import (
+	"errors"
+	"io"
+
+	"pixly/pkg/jpegquality"
+)
+
+// probeJPEG 复用量化表解析器读取帧头与APP段
+func probeJPEG(r io.ReaderAt, size int64) (*Info, error) {
+	jpeg, err := jpegquality.Analyze(io.NewSectionReader(r, 0, size))
+	if errors.Is(err, jpegquality.ErrNotJPEG) {
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	info := &Info{
+		Format:      "jpeg",
+		Codec:       "mjpeg",
+		Width:       jpeg.Width,
+		Height:      jpeg.Height,
+		FrameCount:  1,
+		BitDepth:    jpeg.Precision,
+		HasICC:      jpeg.ICC,
+		GainMap:     detectJPEGGainMap(r, size),
+		MotionPhoto: detectJPEGMotionPhoto(r, size),
+	}
+	switch jpeg.Components {
+	case 1:
+		info.ColorModel = "gray"
+	case 4:
+		info.ColorModel = "cmyk"
+	default:
+		info.ColorModel = "ycbcr"
+	}
+	return info, nil
+}
@@ -0,0 +1,291 @@
+package mediaprobe
+
+import "io"
+
+// jxlHeaderLimit 读取的码流头部上限，足以覆盖尺寸头与图像元数据
+const jxlHeaderLimit = 4096
+
+// JXL枚举值
+const (
+	jxlColourGrey = 1
+	jxlColourXYB  = 2
+	jxlCustom     = 2 // 自定义白点/原色
+	jxlAlpha      = 0 // 额外通道类型：透明
+	jxlSpotColour = 2
+	jxlCFA        = 5
+)
+
+// jxlRatios SizeHeader中宽高比编码（序号1-7）对应的宽/高
+var jxlRatios = [8][2]uint64{{0, 0}, {1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+
+// dist U32字段的一种分布：offset + 读取bits位
+type dist struct {
+	offset uint64
+	bits   int
+}
+
+// val 固定值分布
+func val(v uint64) dist { return dist{offset: v} }
+
+// bitsOffset 读取bits位再加offset
+func bitsOffset(bits int, offset uint64) dist { return dist{offset: offset, bits: bits} }
+
+// bitReader 按JXL规定的低位优先顺序读取比特
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// bits 读取n位（n<=64），越界时记录错误并返回0
+func (b *bitReader) bits(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		if b.pos>>3 >= len(b.data) {
+			b.err = ErrMalformed
+			return 0
+		}
+		v |= uint64(b.data[b.pos>>3]>>(b.pos&7)&1) << i
+		b.pos++
+	}
+	return v
+}
+
+// bool 读取1位
+func (b *bitReader) bool() bool { return b.bits(1) == 1 }
+
+// u32 读取2位选择子后按对应分布取值
+func (b *bitReader) u32(d0, d1, d2, d3 dist) uint64 {
+	d := [4]dist{d0, d1, d2, d3}[b.bits(2)]
+	return d.offset + b.bits(d.bits)
+}
+
+// enum 读取枚举值
+func (b *bitReader) enum() uint64 {
+	return b.u32(val(0), val(1), bitsOffset(4, 2), bitsOffset(6, 18))
+}
+
+// probeJXLContainer 在ISOBMFF容器中查找jxlc（完整码流）或第一个jxlp（分段码流），以及增益图盒jhgm
+func probeJXLContainer(r io.ReaderAt, size int64) (*Info, error) {
+	var info *Info
+	gainMap := false
+	for offset := int64(0); offset+8 <= size; {
+		b, err := readBox(r, offset, size)
+		if err != nil {
+			if info != nil {
+				break // 码流之后的盒损坏时保留已解析的头部
+			}
+			return nil, err
+		}
+		switch b.kind {
+		case "jxlc":
+			if info, err = probeJXLCodestream(r, b.data, b.end); err != nil {
+				return nil, err
+			}
+		case "jxlp":
+			if info == nil {
+				if info, err = probeJXLCodestream(r, b.data+4, b.end); err != nil { // 跳过4字节序号
+					return nil, err
+				}
+			}
+		case "jhgm":
+			gainMap = true
+		}
+		offset = b.end
+	}
+	if info == nil {
+		return nil, ErrMalformed
+	}
+	if gainMap {
+		info.GainMap = GainMapISO
+	}
+	return info, nil
+}
+
+// probeJXLCodestream 解析码流签名之后的SizeHeader与ImageMetadata
+func probeJXLCodestream(r io.ReaderAt, start, end int64) (*Info, error) {
+	data, err := readAt(r, start, int(min(end-start, jxlHeaderLimit)))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0x0A {
+		return nil, ErrMalformed
+	}
+
+	reader := &bitReader{data: data[2:]}
+	info := &Info{Format: "jxl", Codec: "jpegxl", FrameCount: 1}
+	info.Width, info.Height = readJXLSize(reader)
+
+	if reader.bool() {
+		// 全部默认：8位sRGB，无透明、无动画
+		info.BitDepth = 8
+		info.ColorModel = "rgb"
+		info.ColorPrimaries = 1
+		info.TransferCharacteristics = 13
+	} else {
+		readJXLMetadata(reader, info)
+	}
+
+	if reader.err != nil || info.Width == 0 || info.Height == 0 {
+		return nil, ErrMalformed
+	}
+	if info.Animated {
+		info.FrameCount = 0 // 帧数需要逐帧解析帧头，头部不记录
+	}
+	return info, nil
+}
+
+// readJXLSize 读取SizeHeader
+func readJXLSize(b *bitReader) (int, int) {
+	size := bitsOffset(9, 1)
+	small := b.bool()
+
+	var height uint64
+	if small {
+		height = (b.bits(5) + 1) * 8
+	} else {
+		height = b.u32(size, bitsOffset(13, 1), bitsOffset(18, 1), bitsOffset(30, 1))
+	}
+
+	var width uint64
+	if ratio := b.bits(3); ratio != 0 {
+		width = height * jxlRatios[ratio][0] / jxlRatios[ratio][1]
+	} else if small {
+		width = (b.bits(5) + 1) * 8
+	} else {
+		width = b.u32(size, bitsOffset(13, 1), bitsOffset(18, 1), bitsOffset(30, 1))
+	}
+	return int(width), int(height)
+}
+
+// readJXLMetadata 读取非默认的ImageMetadata：动画标志、位深、额外通道与色彩编码
+func readJXLMetadata(b *bitReader, info *Info) {
+	if b.bool() { // extra_fields
+		b.bits(3) // orientation
+		if b.bool() {
+			readJXLSize(b) // 固有尺寸
+		}
+		if b.bool() {
+			skipJXLPreview(b)
+		}
+		if b.bool() {
+			info.Animated = true
+			b.u32(val(100), val(1000), bitsOffset(10, 1), bitsOffset(30, 1)) // tps_numerator
+			b.u32(val(1), val(1001), bitsOffset(8, 1), bitsOffset(10, 1))    // tps_denominator
+			b.u32(val(0), bitsOffset(3, 0), bitsOffset(16, 0), bitsOffset(32, 0))
+			b.bool() // have_timecodes
+		}
+	}
+
+	info.BitDepth = readJXLBitDepth(b)
+	b.bool() // modular_16_bit_buffer_sufficient
+
+	channels := b.u32(val(0), val(1), bitsOffset(4, 2), bitsOffset(12, 1))
+	for i := uint64(0); i < channels && b.err == nil; i++ {
+		if readJXLExtraChannel(b) == jxlAlpha {
+			info.HasAlpha = true
+		}
+	}
+
+	b.bool() // xyb_encoded
+	readJXLColour(b, info)
+}
+
+// skipJXLPreview 跳过PreviewHeader
+func skipJXLPreview(b *bitReader) {
+	div8 := b.bool()
+	readDim := func() {
+		if div8 {
+			b.u32(val(16), val(32), bitsOffset(5, 1), bitsOffset(9, 33))
+		} else {
+			b.u32(bitsOffset(6, 1), bitsOffset(8, 65), bitsOffset(10, 321), bitsOffset(12, 1345))
+		}
+	}
+	readDim()
+	if b.bits(3) == 0 {
+		readDim()
+	}
+}
+
+// readJXLBitDepth 读取BitDepth，返回每样本位数
+func readJXLBitDepth(b *bitReader) int {
+	if !b.bool() {
+		return int(b.u32(val(8), val(10), val(12), bitsOffset(6, 1)))
+	}
+	bits := b.u32(val(32), val(16), val(24), bitsOffset(6, 1))
+	b.bits(4) // exponent_bits_per_sample
+	return int(bits)
+}
+
+// readJXLExtraChannel 读取ExtraChannelInfo，返回通道类型
+func readJXLExtraChannel(b *bitReader) uint64 {
+	if b.bool() {
+		return jxlAlpha // 全部默认为8位透明通道
+	}
+
+	kind := b.enum()
+	readJXLBitDepth(b)
+	b.u32(val(0), val(3), val(4), bitsOffset(3, 1)) // dim_shift
+	nameLength := b.u32(val(0), bitsOffset(4, 0), bitsOffset(5, 16), bitsOffset(10, 48))
+	for i := uint64(0); i < nameLength && b.err == nil; i++ {
+		b.bits(8)
+	}
+
+	switch kind {
+	case jxlAlpha:
+		b.bool() // alpha_associated
+	case jxlSpotColour:
+		b.bits(16 * 4)
+	case jxlCFA:
+		b.u32(val(1), bitsOffset(2, 0), bitsOffset(4, 3), bitsOffset(8, 19))
+	}
+	return kind
+}
+
+// readJXLColour 读取ColourEncoding：ICC标志、色彩空间与传输特性
+func readJXLColour(b *bitReader, info *Info) {
+	if b.bool() {
+		info.ColorModel = "rgb"
+		info.ColorPrimaries = 1
+		info.TransferCharacteristics = 13
+		return
+	}
+
+	info.HasICC = b.bool()
+	space := b.enum()
+	switch space {
+	case jxlColourGrey:
+		info.ColorModel = "gray"
+	default:
+		info.ColorModel = "rgb"
+	}
+	if info.HasICC {
+		return
+	}
+
+	customXY := func() {
+		for i := 0; i < 2; i++ {
+			b.u32(bitsOffset(19, 0), bitsOffset(19, 524288), bitsOffset(20, 1048576), bitsOffset(21, 2097152))
+		}
+	}
+	if space != jxlColourXYB {
+		if b.enum() == jxlCustom {
+			customXY() // 白点
+		}
+		if space != jxlColourGrey {
+			primaries := b.enum()
+			if primaries == jxlCustom {
+				for i := 0; i < 3; i++ {
+					customXY()
+				}
+			} else {
+				info.ColorPrimaries = int(primaries)
+			}
+		}
+		if b.bool() {
+			b.bits(24) // gamma
+		} else {
+			info.TransferCharacteristics = int(b.enum())
+		}
+	}
+}
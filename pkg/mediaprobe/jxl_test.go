@@ -0,0 +1,185 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// bitWriter 按JXL的低位优先顺序写入比特
+type bitWriter struct {
+	data []byte
+	pos  int
+}
+
+// bits 写入v的低n位
+func (w *bitWriter) bits(v uint64, n int) *bitWriter {
+	for i := 0; i < n; i++ {
+		if w.pos>>3 >= len(w.data) {
+			w.data = append(w.data, 0)
+		}
+		w.data[w.pos>>3] |= byte(v>>i&1) << (w.pos & 7)
+		w.pos++
+	}
+	return w
+}
+
+// bool 写入1位
+func (w *bitWriter) bool(v bool) *bitWriter {
+	if v {
+		return w.bits(1, 1)
+	}
+	return w.bits(0, 1)
+}
+
+// u32 写入选择子与对应分布的额外位
+func (w *bitWriter) u32(selector uint64, extra uint64, n int) *bitWriter {
+	return w.bits(selector, 2).bits(extra, n)
+}
+
+// codestream 加上码流签名
+func (w *bitWriter) codestream() []byte {
+	return append([]byte{0xFF, 0x0A}, w.data...)
+}
+
+// smallSize 写入小尺寸SizeHeader（8的倍数）
+func (w *bitWriter) smallSize(width, height uint64) *bitWriter {
+	return w.bool(true).bits(height/8-1, 5).bits(0, 3).bits(width/8-1, 5)
+}
+
+// jxlContainer 构造JXL容器：签名盒、ftyp与给定的盒
+func jxlContainer(boxes ...[]byte) []byte {
+	return bytes.Join(append([][]byte{jxlContainerSignature, isoBox("ftyp", []byte("jxl "), make([]byte, 4), []byte("jxl "))}, boxes...), nil)
+}
+
+func TestProbeJXL(t *testing.T) {
+	smallDefault := (&bitWriter{}).smallSize(64, 32).bool(true).codestream()
+
+	// 非小尺寸：高度 u32 选择子0（9位+1），宽度按16:9比例
+	ratio := (&bitWriter{}).bool(false).u32(0, 99, 9).bits(5, 3).bool(true).codestream()
+
+	// 非默认元数据：10位、一个默认（透明）额外通道、Rec.2020原色与PQ传输
+	wide := (&bitWriter{}).smallSize(8, 8).
+		bool(false).                           // all_default
+		bool(false).                           // extra_fields
+		bool(false).u32(1, 0, 0).              // 整数位深10
+		bool(true).                            // modular_16_bit_buffer_sufficient
+		u32(1, 0, 0).bool(true).               // 1个额外通道，全部默认
+		bool(false).                           // xyb_encoded
+		bool(false).bool(false).               // 色彩非默认，无ICC
+		u32(0, 0, 0).                          // 色彩空间RGB
+		u32(1, 0, 0).                          // 白点D65
+		u32(2, 7, 4).                          // 原色9
+		bool(false).u32(2, 14, 4).codestream() // 传输特性16
+
+	// 内嵌ICC的灰度图像
+	grayICC := (&bitWriter{}).smallSize(16, 16).
+		bool(false).bool(false).
+		bool(false).u32(0, 0, 0).bool(true).
+		u32(0, 0, 0). // 无额外通道
+		bool(false).
+		bool(false).bool(true).u32(1, 0, 0).codestream()
+
+	// 专色额外通道不是透明
+	spot := (&bitWriter{}).smallSize(8, 8).
+		bool(false).bool(false).
+		bool(false).u32(0, 0, 0).bool(true).
+		u32(1, 0, 0).
+		bool(false).u32(2, 0, 4). // 类型2：专色
+		bool(false).u32(0, 0, 0). // 位深8
+		u32(0, 0, 0).             // dim_shift
+		u32(1, 2, 4).bits('a', 8).bits('b', 8).
+		bits(0, 64). // 专色值
+		bool(false).bool(true).codestream()
+
+	// 动画：extra_fields中带AnimationHeader
+	animated := (&bitWriter{}).smallSize(8, 8).
+		bool(false).
+		bool(true).bits(0, 3).bool(false).bool(false).bool(true). // 方向、无固有尺寸、无预览、有动画
+		u32(0, 0, 0).u32(0, 0, 0).u32(0, 0, 0).bool(false).
+		bool(false).u32(0, 0, 0).bool(true).
+		u32(0, 0, 0).bool(false).bool(true).codestream()
+
+	partial := append([]byte{0, 0, 0, 0}, smallDefault...) // jxlp的4字节序号
+
+	tests := []struct {
+		name       string
+		data       []byte
+		width      int
+		height     int
+		bitDepth   int
+		colorModel string
+		alpha      bool
+		icc        bool
+		primaries  int
+		transfer   int
+		animated   bool
+		frames     int
+		gainMap    string
+	}{
+		{"小尺寸全部默认", smallDefault, 64, 32, 8, "rgb", false, false, 1, 13, false, 1, ""},
+		{"宽高比编码", ratio, 177, 100, 8, "rgb", false, false, 1, 13, false, 1, ""},
+		{"非默认元数据", wide, 8, 8, 10, "rgb", true, false, 9, 16, false, 1, ""},
+		{"ICC灰度", grayICC, 16, 16, 8, "gray", false, true, 0, 0, false, 1, ""},
+		{"专色通道", spot, 8, 8, 8, "rgb", false, false, 1, 13, false, 1, ""},
+		{"动画不记录帧数", animated, 8, 8, 8, "rgb", false, false, 1, 13, true, 0, ""},
+		{"容器中的jxlc", jxlContainer(isoBox("jxlc", smallDefault)), 64, 32, 8, "rgb", false, false, 1, 13, false, 1, ""},
+		{"容器中的jxlp与增益图", jxlContainer(isoBox("jxlp", partial), isoBox("jhgm", []byte{0}), isoBox("jxlp", partial)), 64, 32, 8, "rgb", false, false, 1, 13, false, 1, GainMapISO},
+		{"码流之后的盒损坏", jxlContainer(isoBox("jxlc", smallDefault), []byte{0, 0, 0, 0x40, 'b', 'r', 'o', 'b'}), 64, 32, 8, "rgb", false, false, 1, 13, false, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != "jxl" || info.Codec != "jpegxl" {
+				t.Errorf("格式 = %s/%s, 期望 jxl/jpegxl", info.Format, info.Codec)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.BitDepth != tt.bitDepth || info.ColorModel != tt.colorModel {
+				t.Errorf("位深/色彩模型 = %d/%s, 期望 %d/%s", info.BitDepth, info.ColorModel, tt.bitDepth, tt.colorModel)
+			}
+			if info.HasAlpha != tt.alpha || info.HasICC != tt.icc {
+				t.Errorf("透明/ICC = %v/%v, 期望 %v/%v", info.HasAlpha, info.HasICC, tt.alpha, tt.icc)
+			}
+			if info.ColorPrimaries != tt.primaries || info.TransferCharacteristics != tt.transfer {
+				t.Errorf("原色/传输 = %d/%d, 期望 %d/%d", info.ColorPrimaries, info.TransferCharacteristics, tt.primaries, tt.transfer)
+			}
+			if info.Animated != tt.animated || info.FrameCount != tt.frames {
+				t.Errorf("Animated/FrameCount = %v/%d, 期望 %v/%d", info.Animated, info.FrameCount, tt.animated, tt.frames)
+			}
+			if info.GainMap != tt.gainMap {
+				t.Errorf("GainMap = %q, 期望 %q", info.GainMap, tt.gainMap)
+			}
+		})
+	}
+}
+
+func TestProbeJXLMalformed(t *testing.T) {
+	smallDefault := (&bitWriter{}).smallSize(64, 32).bool(true).codestream()
+	nonDefault := (&bitWriter{}).smallSize(8, 8).bool(false).bool(false).bool(false).u32(1, 0, 0).bool(true).u32(1, 0, 0).codestream()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"码流只有签名", []byte{0xFF, 0x0A}},
+		{"尺寸头被截断", smallDefault[:3]},
+		{"元数据被截断", nonDefault[:len(nonDefault)-1]},
+		{"容器中没有码流", jxlContainer(isoBox("jhgm", []byte{0}))},
+		{"jxlc的签名错误", jxlContainer(isoBox("jxlc", []byte{0xFF, 0x0B, 0, 0}))},
+		{"jxlc被截断", jxlContainer(isoBox("jxlc", smallDefault[:3]))},
+		{"码流前的盒越过文件末尾", jxlContainer([]byte{0, 0, 0, 0x40, 'j', 'x', 'l', 'c'})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("期望 ErrMalformed，实际为 %v (%+v)", err, info)
+			}
+		})
+	}
+}
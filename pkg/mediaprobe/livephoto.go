@@ -0,0 +1,230 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// Motion Photo厂商：视频以MP4形式附加在JPEG主图像之后
+const (
+	MotionPhotoGoogle  = "google"  // XMP Container目录中的MotionPhoto条目或旧版MicroVideo
+	MotionPhotoSamsung = "samsung" // MotionPhoto_Data标记之后的MP4
+)
+
+// MotionPhoto 嵌入在照片尾部的视频
+type MotionPhoto struct {
+	Vendor string `json:"vendor"`
+	Offset int64  `json:"offset"` // 视频在文件中的起始位置
+	Length int64  `json:"length"` // 视频长度（至文件末尾或厂商尾部数据之前）
+}
+
+// appleContentIdentifierKey Live Photo的MOV中关联静态图的元数据键（moov/meta的mdta键）
+const appleContentIdentifierKey = "com.apple.quicktime.content.identifier"
+
+// Motion Photo标识
+var (
+	samsungMotionMarker = []byte("MotionPhoto_Data")
+	samsungSEFTrailer   = []byte("SEFT")
+	googleMotionPhoto   = regexp.MustCompile(`GCamera:MotionPhoto(?:="|>)\s*1`)
+	googleMicroVideo    = regexp.MustCompile(`GCamera:MicroVideo(?:="|>)\s*1`)
+	googleMicroOffset   = regexp.MustCompile(`GCamera:MicroVideoOffset(?:="|>)\s*(\d+)`)
+	googleMotionItem    = regexp.MustCompile(`<[^<>]*Item:Semantic="MotionPhoto"[^<>]*>`)
+	googleItemLength    = regexp.MustCompile(`Item:Length="(\d+)"`)
+)
+
+// 三星尾部查找限制
+const (
+	samsungTrailerWindow = 64 << 20 // 只在文件末尾64MB内查找MotionPhoto_Data
+	samsungTrailerChunk  = 1 << 20
+	motionPhotoMinVideo  = 32 // 小于此长度的"视频"视为误判
+)
+
+// detectJPEGMotionPhoto 根据主图像的XMP或三星尾部标记定位嵌入的视频，视频须以ftyp盒开头
+func detectJPEGMotionPhoto(r io.ReaderAt, size int64) *MotionPhoto {
+	xmp := scanJPEGSegments(r, 0, size).xmp
+	var video *MotionPhoto
+	switch {
+	case googleMotionPhoto.Match(xmp):
+		if item := googleMotionItem.Find(xmp); item != nil {
+			if length := submatchInt(googleItemLength, item); length > 0 {
+				video = &MotionPhoto{Vendor: MotionPhotoGoogle, Offset: size - length, Length: length}
+			}
+		}
+	case googleMicroVideo.Match(xmp):
+		if length := submatchInt(googleMicroOffset, xmp); length > 0 {
+			video = &MotionPhoto{Vendor: MotionPhotoGoogle, Offset: size - length, Length: length}
+		}
+	}
+	if video == nil && hasSEFTrailer(r, size) {
+		if offset := findLastMarker(r, size, samsungMotionMarker); offset >= 0 {
+			start := offset + int64(len(samsungMotionMarker))
+			video = &MotionPhoto{Vendor: MotionPhotoSamsung, Offset: start, Length: size - start}
+		}
+	}
+
+	if video == nil || video.Offset <= 0 || video.Length < motionPhotoMinVideo || video.Offset+video.Length > size {
+		return nil
+	}
+	if header, err := readAt(r, video.Offset+4, 4); err != nil || string(header) != "ftyp" {
+		return nil
+	}
+	return video
+}
+
+// submatchInt 返回正则第一个分组解析出的整数，没有匹配时为0
+func submatchInt(re *regexp.Regexp, data []byte) int64 {
+	match := re.FindSubmatch(data)
+	if match == nil {
+		return 0
+	}
+	value, _ := strconv.ParseInt(string(match[1]), 10, 64)
+	return value
+}
+
+// hasSEFTrailer 文件是否以三星SEF尾部结束（MotionPhoto_Data位于其中）
+func hasSEFTrailer(r io.ReaderAt, size int64) bool {
+	tail, err := readAt(r, size-4, 4)
+	return err == nil && bytes.Equal(tail, samsungSEFTrailer)
+}
+
+// findLastMarker 在文件末尾窗口内从后向前查找标记，返回其位置，未找到时为-1
+func findLastMarker(r io.ReaderAt, size int64, marker []byte) int64 {
+	floor := max(size-samsungTrailerWindow, 0)
+	for end := size; end > floor; {
+		start := max(end-int64(samsungTrailerChunk), floor)
+		// 与后一块重叠标记长度，避免标记跨块
+		chunk, err := readAt(r, start, int(min(end+int64(len(marker))-1, size)-start))
+		if err != nil {
+			return -1
+		}
+		if index := bytes.LastIndex(chunk, marker); index >= 0 {
+			return start + int64(index)
+		}
+		end = start
+	}
+	return -1
+}
+
+// QuickTimeContentIdentifier 读取MOV/MP4的moov/meta中Live Photo的ContentIdentifier；
+// 不是ISOBMFF容器时返回 ErrUnsupported，没有该键时返回空字符串
+func QuickTimeContentIdentifier(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	return quickTimeContentIdentifier(file, stat.Size())
+}
+
+// quickTimeContentIdentifier 遍历顶层盒找到moov，再读取其meta中的keys与ilst
+func quickTimeContentIdentifier(r io.ReaderAt, size int64) (string, error) {
+	header, err := readAt(r, 4, 4)
+	if err != nil || (string(header) != "ftyp" && string(header) != "moov" && string(header) != "wide" && string(header) != "mdat") {
+		return "", ErrUnsupported
+	}
+
+	var identifier string
+	for offset := int64(0); offset+8 <= size; {
+		b, err := readBox(r, offset, size)
+		if err != nil {
+			return "", err
+		}
+		if b.kind == "moov" {
+			err := children(r, b, 0, func(child box) error {
+				if child.kind != "meta" || identifier != "" {
+					return nil
+				}
+				value, err := mdtaValue(r, child, appleContentIdentifierKey)
+				identifier = value
+				return err
+			})
+			return identifier, err
+		}
+		offset = b.end
+	}
+	return "", nil
+}
+
+// mdtaValue 读取QuickTime元数据（keys + ilst）中指定键的字符串值
+func mdtaValue(r io.ReaderAt, meta box, key string) (string, error) {
+	// QuickTime的meta不是FullBox，MP4的meta带4字节版本与标志
+	var skip int64
+	if probe, err := readAt(r, meta.data, 8); err == nil && string(probe[4:8]) != "hdlr" {
+		skip = 4
+	}
+
+	index := 0
+	var value string
+	err := children(r, meta, skip, func(b box) error {
+		switch b.kind {
+		case "keys":
+			data, err := boxData(r, b)
+			if err != nil || len(data) < 8 {
+				return ErrMalformed
+			}
+			count := int(be32(data[4:8]))
+			for i, pos := 1, 8; i <= count && pos+8 <= len(data); i++ {
+				entrySize := int(be32(data[pos:]))
+				if entrySize < 8 || pos+entrySize > len(data) {
+					return ErrMalformed
+				}
+				if string(data[pos+8:pos+entrySize]) == key {
+					index = i
+				}
+				pos += entrySize
+			}
+		case "ilst":
+			if index == 0 {
+				return nil
+			}
+			return children(r, b, 0, func(item box) error {
+				// ilst条目的盒类型是keys中的序号（大端32位）
+				if int(be32([]byte(item.kind))) != index {
+					return nil
+				}
+				return children(r, item, 0, func(data box) error {
+					if data.kind != "data" || data.end-data.data < 8 {
+						return nil
+					}
+					payload, err := boxData(r, data)
+					if err != nil {
+						return err
+					}
+					value = string(payload[8:]) // 类型指示(4) + 区域(4)
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+	return value, err
+}
+
+// ContentIdentifier 返回Live Photo的关联标识：MOV/MP4直接解析容器元数据，
+// 静态图读取exiftool输出中的Apple MakerNotes ContentIdentifier；没有标识时返回空字符串
+func (r *ProbeResult) ContentIdentifier(ctx context.Context) (string, error) {
+	if r.statErr != nil {
+		return "", r.statErr
+	}
+	// 图像头部无法解析的ISOBMFF文件是MOV/MP4视频；HEIC等静态图走exiftool
+	if _, err := r.Header(); errors.Is(err, ErrUnsupported) {
+		identifier, err := QuickTimeContentIdentifier(r.Path)
+		if !errors.Is(err, ErrUnsupported) {
+			return identifier, err
+		}
+	}
+	exif, err := r.Exif(ctx)
+	if err != nil {
+		return "", err
+	}
+	return exif.String("ContentIdentifier"), nil
+}
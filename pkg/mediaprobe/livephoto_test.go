@@ -0,0 +1,94 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// keysBox QuickTime元数据的keys盒，键均为mdta命名空间
+func keysBox(keys ...string) []byte {
+	data := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0}, uint32(len(keys)))
+	for _, key := range keys {
+		data = binary.BigEndian.AppendUint32(data, uint32(8+len(key)))
+		data = append(data, "mdta"...)
+		data = append(data, key...)
+	}
+	return isoBox("keys", data)
+}
+
+// ilstItem ilst中第index个键的UTF-8字符串值
+func ilstItem(index uint32, value string) []byte {
+	kind := string(binary.BigEndian.AppendUint32(nil, index))
+	return isoBox(kind, isoBox("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value)))
+}
+
+// quickTimeMovie ftyp + 带meta的moov + mdat；fullBox为true时meta按MP4带版本与标志
+func quickTimeMovie(brand string, fullBox bool, metaChildren ...[]byte) []byte {
+	ftyp := isoBox("ftyp", []byte(brand), []byte{0, 0, 0, 0}, []byte(brand))
+	hdlr := isoBox("hdlr", make([]byte, 8), []byte("mdta"), make([]byte, 13))
+	payload := [][]byte{hdlr}
+	if fullBox {
+		payload = [][]byte{{0, 0, 0, 0}, hdlr}
+	}
+	meta := isoBox("meta", append(payload, metaChildren...)...)
+	moov := isoBox("moov", isoBox("mvhd", make([]byte, 100)), meta)
+	return bytes.Join([][]byte{ftyp, moov, isoBox("mdat", make([]byte, 16))}, nil)
+}
+
+func TestQuickTimeContentIdentifier(t *testing.T) {
+	const id = "5A7C1F2E-9B3D-4E6A-8C1B-2D3F4A5B6C7D"
+	keys := keysBox("com.apple.quicktime.make", appleContentIdentifierKey)
+	values := isoBox("ilst", ilstItem(1, "Apple"), ilstItem(2, id))
+
+	truncatedKeys := quickTimeMovie("qt  ", false, keys, values)
+	// 第一个键的长度超出keys盒
+	keysAt := bytes.Index(truncatedKeys, []byte("keys")) + 4 + 8
+	binary.BigEndian.PutUint32(truncatedKeys[keysAt:], 4096)
+
+	full := quickTimeMovie("qt  ", false, keys, values)
+	moovEnd := bytes.Index(full, []byte("mdat")) - 4
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"QuickTime元数据", quickTimeMovie("qt  ", false, keys, values), id, nil},
+		{"MP4 FullBox元数据", quickTimeMovie("isom", true, keys, values), id, nil},
+		{"值位于前面的键", quickTimeMovie("qt  ", false, keysBox(appleContentIdentifierKey), isoBox("ilst", ilstItem(1, id))), id, nil},
+		{"没有该键", quickTimeMovie("qt  ", false, keysBox("com.apple.quicktime.make"), isoBox("ilst", ilstItem(1, "Apple"))), "", nil},
+		{"没有ilst", quickTimeMovie("qt  ", false, keys), "", nil},
+		{"moov没有meta", bytes.Join([][]byte{isoBox("ftyp", []byte("qt  ")), isoBox("moov", isoBox("mvhd", make([]byte, 100)))}, nil), "", nil},
+		{"moov被截断", full[:moovEnd-10], "", ErrMalformed},
+		{"keys条目长度越界", truncatedKeys, "", ErrMalformed},
+		{"不是ISOBMFF", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}, "", ErrUnsupported},
+		{"文件过短", []byte{0, 0, 0}, "", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quickTimeContentIdentifier(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v (%q)", tt.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("quickTimeContentIdentifier: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ContentIdentifier = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeQuickTimeUnsupported(t *testing.T) {
+	movie := quickTimeMovie("qt  ", false)
+	if _, err := Probe(bytes.NewReader(movie), int64(len(movie))); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("MOV应返回 ErrUnsupported 以回退到ffprobe，实际为 %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package mediaprobe
+
+import "io"
+
+// PNG颜色类型
+const (
+	pngGray      = 0
+	pngRGB       = 2
+	pngPalette   = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+// probePNG 解析IDAT之前的块：IHDR、acTL（APNG帧数）、tRNS、iCCP、sRGB与cICP
+func probePNG(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: "png", Codec: "png", FrameCount: 1}
+	sawHeader := false
+
+	for offset := int64(len(pngSignature)); offset+8 <= size; {
+		header, err := readAt(r, offset, 8)
+		if err != nil {
+			return nil, err
+		}
+		length := be32(header)
+		chunk := string(header[4:8])
+		data := offset + 8
+
+		switch chunk {
+		case "IHDR":
+			ihdr, err := readAt(r, data, 13)
+			if err != nil {
+				return nil, err
+			}
+			info.Width = int(be32(ihdr[0:]))
+			info.Height = int(be32(ihdr[4:]))
+			info.BitDepth = int(ihdr[8])
+			switch ihdr[9] {
+			case pngGray:
+				info.ColorModel = "gray"
+			case pngGrayAlpha:
+				info.ColorModel = "gray"
+				info.HasAlpha = true
+			case pngPalette:
+				info.ColorModel = "palette"
+			case pngRGBA:
+				info.ColorModel = "rgb"
+				info.HasAlpha = true
+			default:
+				info.ColorModel = "rgb"
+			}
+			sawHeader = true
+		case "acTL":
+			actl, err := readAt(r, data, 8)
+			if err != nil {
+				return nil, err
+			}
+			info.Format = "apng"
+			info.Codec = "apng"
+			info.FrameCount = int(be32(actl))
+			info.Animated = info.FrameCount > 1
+		case "tRNS":
+			info.HasAlpha = true
+		case "iCCP":
+			info.HasICC = true
+		case "sRGB":
+			if info.ColorPrimaries == 0 {
+				info.ColorPrimaries = 1
+				info.TransferCharacteristics = 13
+			}
+		case "cICP":
+			cicp, err := readAt(r, data, 4)
+			if err != nil {
+				return nil, err
+			}
+			info.ColorPrimaries = int(cicp[0])
+			info.TransferCharacteristics = int(cicp[1])
+			info.MatrixCoefficients = int(cicp[2])
+			info.FullRange = cicp[3] != 0
+		case "IDAT", "IEND":
+			// 动画控制与色彩块都在图像数据之前
+			if !sawHeader {
+				return nil, ErrMalformed
+			}
+			return info, nil
+		}
+
+		if !sawHeader {
+			return nil, ErrMalformed // IHDR必须是第一个块
+		}
+		offset = data + length + 4 // 数据 + CRC
+	}
+	return nil, ErrMalformed
+}
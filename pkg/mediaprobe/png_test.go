@@ -0,0 +1,118 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// pngChunk PNG块：长度 + 类型 + 数据 + CRC（解析不校验CRC，写0）
+func pngChunk(kind string, data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	out = append(out, kind...)
+	out = append(out, data...)
+	return append(out, 0, 0, 0, 0)
+}
+
+// pngIHDR IHDR块
+func pngIHDR(width, height uint32, bitDepth, colorType byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, width)
+	data = binary.BigEndian.AppendUint32(data, height)
+	return pngChunk("IHDR", append(data, bitDepth, colorType, 0, 0, 0))
+}
+
+// pngACTL APNG动画控制块
+func pngACTL(frames uint32) []byte {
+	data := binary.BigEndian.AppendUint32(nil, frames)
+	return pngChunk("acTL", binary.BigEndian.AppendUint32(data, 0))
+}
+
+// pngFile 签名 + 各块
+func pngFile(chunks ...[]byte) []byte {
+	return bytes.Join(append([][]byte{pngSignature}, chunks...), nil)
+}
+
+func TestProbePNG(t *testing.T) {
+	idat := pngChunk("IDAT", []byte{0x78, 0x9C})
+	iend := pngChunk("IEND", nil)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		format     string
+		width      int
+		height     int
+		frames     int
+		animated   bool
+		bitDepth   int
+		colorModel string
+		alpha      bool
+		icc        bool
+		primaries  int
+		transfer   int
+	}{
+		{"8位RGBA", pngFile(pngIHDR(640, 480, 8, pngRGBA), idat, iend), "png", 640, 480, 1, false, 8, "rgb", true, false, 0, 0},
+		{"16位灰度", pngFile(pngIHDR(10, 20, 16, pngGray), idat), "png", 10, 20, 1, false, 16, "gray", false, false, 0, 0},
+		{"灰度带透明", pngFile(pngIHDR(10, 20, 8, pngGrayAlpha), idat), "png", 10, 20, 1, false, 8, "gray", true, false, 0, 0},
+		{"调色板带tRNS", pngFile(pngIHDR(32, 32, 8, pngPalette), pngChunk("PLTE", make([]byte, 6)), pngChunk("tRNS", []byte{0}), idat), "png", 32, 32, 1, false, 8, "palette", true, false, 0, 0},
+		{"RGB带iCCP", pngFile(pngIHDR(8, 8, 8, pngRGB), pngChunk("iCCP", []byte("icc\x00\x00")), idat), "png", 8, 8, 1, false, 8, "rgb", false, true, 0, 0},
+		{"sRGB块", pngFile(pngIHDR(8, 8, 8, pngRGB), pngChunk("sRGB", []byte{0}), idat), "png", 8, 8, 1, false, 8, "rgb", false, false, 1, 13},
+		{"cICP覆盖sRGB", pngFile(pngIHDR(8, 8, 16, pngRGB), pngChunk("sRGB", []byte{0}), pngChunk("cICP", []byte{9, 16, 0, 1}), idat), "png", 8, 8, 1, false, 16, "rgb", false, false, 9, 16},
+		{"APNG五帧", pngFile(pngIHDR(100, 50, 8, pngRGBA), pngACTL(5), idat), "apng", 100, 50, 5, true, 8, "rgb", true, false, 0, 0},
+		{"APNG单帧不算动画", pngFile(pngIHDR(100, 50, 8, pngRGB), pngACTL(1), idat), "apng", 100, 50, 1, false, 8, "rgb", false, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != tt.format || info.Codec != tt.format {
+				t.Errorf("格式 = %s/%s, 期望 %s", info.Format, info.Codec, tt.format)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.FrameCount != tt.frames || info.Animated != tt.animated {
+				t.Errorf("FrameCount/Animated = %d/%v, 期望 %d/%v", info.FrameCount, info.Animated, tt.frames, tt.animated)
+			}
+			if info.BitDepth != tt.bitDepth || info.ColorModel != tt.colorModel {
+				t.Errorf("位深/色彩模型 = %d/%s, 期望 %d/%s", info.BitDepth, info.ColorModel, tt.bitDepth, tt.colorModel)
+			}
+			if info.HasAlpha != tt.alpha || info.HasICC != tt.icc {
+				t.Errorf("透明/ICC = %v/%v, 期望 %v/%v", info.HasAlpha, info.HasICC, tt.alpha, tt.icc)
+			}
+			if info.ColorPrimaries != tt.primaries || info.TransferCharacteristics != tt.transfer {
+				t.Errorf("原色/传输特性 = %d/%d, 期望 %d/%d", info.ColorPrimaries, info.TransferCharacteristics, tt.primaries, tt.transfer)
+			}
+		})
+	}
+}
+
+func TestProbePNGMalformed(t *testing.T) {
+	ihdr := pngIHDR(8, 8, 8, pngRGB)
+	idat := pngChunk("IDAT", nil)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"只有签名", pngSignature},
+		{"IHDR不是第一个块", pngFile(pngChunk("sRGB", []byte{0}), ihdr, idat)},
+		{"IHDR之前就是IDAT", pngFile(idat)},
+		{"IHDR数据被截断", pngFile(ihdr[:8+6])},
+		{"acTL数据被截断", pngFile(ihdr, pngACTL(3)[:8+4])},
+		{"cICP数据被截断", pngFile(ihdr, pngChunk("cICP", []byte{9, 16, 0, 1})[:8+2])},
+		{"没有图像数据", pngFile(ihdr)},
+		{"块长度越过文件末尾", pngFile(ihdr, pngChunk("tEXt", make([]byte, 100))[:20])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("期望 ErrMalformed，实际为 %v (%+v)", err, info)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package mediaprobe 纯Go的图像容器头部解析：只读取文件头即可得到尺寸、帧数、透明通道、位深、
+// 色彩信息与ICC配置，用于替代逐文件启动ffprobe（尤其是逐帧解码的 -count_frames）。
+// 不支持的格式返回 ErrUnsupported，调用方应回退到ffprobe。
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+var (
+	// ErrUnsupported 不是可解析的图像格式（视频容器、BMP等），应回退到ffprobe
+	ErrUnsupported = errors.New("不支持的图像格式")
+	// ErrMalformed 头部损坏或被截断
+	ErrMalformed = errors.New("图像头部损坏")
+)
+
+// 文件签名
+var (
+	pngSignature          = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+	jxlContainerSignature = []byte{0, 0, 0, 0x0C, 'J', 'X', 'L', ' ', '\r', '\n', 0x87, '\n'}
+)
+
+// Info 图像头部信息
+type Info struct {
+	Format     string `json:"format"` // gif、png、apng、webp、avif、heif、jxl、tiff、jpeg
+	Codec      string `json:"codec"`  // 与ffprobe一致的编解码器名称：gif、png、apng、webp、av1、hevc、jpegxl、tiff、mjpeg
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	FrameCount int    `json:"frame_count"` // 帧数（TIFF为全分辨率页数，不含缩略图）；动画JXL不在头部记录帧数，为0
+	Animated   bool   `json:"animated"`
+	HasAlpha   bool   `json:"has_alpha"`
+	BitDepth   int    `json:"bit_depth"`   // 每通道位深
+	ColorModel string `json:"color_model"` // rgb、gray、palette、ycbcr、cmyk
+	HasICC     bool   `json:"has_icc"`
+
+	// CICP色彩参数（ITU-T H.273），头部未声明时为0
+	ColorPrimaries          int  `json:"color_primaries,omitempty"`
+	TransferCharacteristics int  `json:"transfer_characteristics,omitempty"`
+	MatrixCoefficients      int  `json:"matrix_coefficients,omitempty"`
+	FullRange               bool `json:"full_range,omitempty"`
+
+	// 增益图类型（GainMapUltraHDR、GainMapISO、GainMapApple），没有增益图时为空
+	GainMap string `json:"gain_map,omitempty"`
+	// 嵌入在JPEG尾部的Motion Photo视频，没有时为nil
+	MotionPhoto *MotionPhoto `json:"motion_photo,omitempty"`
+	// 相机RAW格式（RawDNG、RawCR2、RawNEF、RawARW），普通TIFF为空
+	Raw string `json:"raw,omitempty"`
+	// TIFF带有Photoshop图层数据（ImageSourceData），转换只会保留合成图
+	Layered bool `json:"layered,omitempty"`
+}
+
+// ProbeFile 解析文件头部
+func ProbeFile(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Probe(file, stat.Size())
+}
+
+// Probe 按文件签名识别格式并解析头部
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	header := make([]byte, 16)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return probeGIF(r, size)
+	case bytes.HasPrefix(header, pngSignature):
+		return probePNG(r, size)
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return probeWebP(r, size)
+	case bytes.HasPrefix(header, jxlContainerSignature):
+		return probeJXLContainer(r, size)
+	case bytes.HasPrefix(header, []byte{0xFF, 0x0A}):
+		return probeJXLCodestream(r, 0, size)
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return probeISOBMFF(r, size)
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return probeTIFF(r, size)
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8}):
+		return probeJPEG(r, size)
+	}
+	return nil, ErrUnsupported
+}
+
+// readAt 读取指定位置的n个字节，不足时返回 ErrMalformed
+func readAt(r io.ReaderAt, offset int64, n int) ([]byte, error) {
+	if offset < 0 || n < 0 {
+		return nil, ErrMalformed
+	}
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if read < n {
+		if err == nil || errors.Is(err, io.EOF) {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// be16/be32/le16/le24/le32 整数解码
+func be16(b []byte) int { return int(binary.BigEndian.Uint16(b)) }
+func be32(b []byte) int64 {
+	return int64(binary.BigEndian.Uint32(b))
+}
+func le16(b []byte) int { return int(binary.LittleEndian.Uint16(b)) }
+func le24(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+func le32(b []byte) int64 {
+	return int64(binary.LittleEndian.Uint32(b))
+}
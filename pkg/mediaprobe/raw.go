@@ -0,0 +1,305 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"pixly/pkg/jpegquality"
+)
+
+// 相机RAW格式（均为TIFF结构）
+const (
+	RawDNG = "dng"
+	RawCR2 = "cr2"
+	RawNEF = "nef"
+	RawARW = "arw"
+)
+
+// DNGCompressionJXL DNG 1.7定义的JPEG XL压缩方式
+const DNGCompressionJXL = 52546
+
+// TIFF标签（RAW结构）
+const (
+	tiffNewSubfileType = 254
+	tiffCompression    = 259
+	tiffMake           = 271
+	tiffStripOffsets   = 273
+	tiffStripCounts    = 279
+	tiffSubIFDs        = 330
+	tiffJPEGOffset     = 513
+	tiffJPEGLength     = 514
+	tiffDNGVersion     = 50706
+)
+
+// TIFF字段类型（IFD偏移）
+const tiffIFDType = 13
+
+// RAW结构遍历限制，防止损坏文件的IFD链成环或过深
+const (
+	rawMaxIFDs     = 64
+	rawMaxSubDepth = 2
+)
+
+// RawInfo 相机RAW文件的结构信息
+type RawInfo struct {
+	Format      string       `json:"format"`      // RawDNG等
+	Width       int          `json:"width"`       // 主图像（NewSubfileType为0的最大图像）宽度
+	Height      int          `json:"height"`      // 主图像高度
+	Compression int          `json:"compression"` // 主图像的压缩方式，DNG中JPEG XL为 DNGCompressionJXL
+	Photometric int          `json:"photometric"` // 主图像的光度解释：CFA(32803)或LinearRaw(34892)
+	Previews    []RawPreview `json:"previews"`    // 嵌入的JPEG预览，按像素数从大到小
+}
+
+// RawPreview 嵌入在RAW中的JPEG预览
+type RawPreview struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+}
+
+// tiffIFD RAW识别所需的IFD标签
+type tiffIFD struct {
+	subfileType  int
+	compression  int
+	photometric  int
+	width        int
+	height       int
+	stripOffset  int64 // 仅记录单条带图像
+	stripLength  int64
+	jpegOffset   int64
+	jpegLength   int64
+	subIFDs      []int64
+	make         string
+	isDNGVersion bool
+}
+
+// ProbeRawFile 解析相机RAW文件的结构；不是可识别的RAW时返回 ErrUnsupported
+func ProbeRawFile(path string) (*RawInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ProbeRaw(file, stat.Size())
+}
+
+// ProbeRaw 遍历IFD链与SubIFD，按CR2签名、DNGVersion标签与相机厂商识别RAW格式，并定位嵌入的JPEG预览
+func ProbeRaw(r io.ReaderAt, size int64) (*RawInfo, error) {
+	header, err := readAt(r, 0, 16)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(header, []byte("II*\x00")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(header, []byte("MM\x00*")):
+		order = binary.BigEndian
+	default:
+		return nil, ErrUnsupported
+	}
+
+	type pending struct {
+		offset int64
+		depth  int
+	}
+	queue := []pending{{int64(order.Uint32(header[4:])), 0}}
+	visited := make(map[int64]bool)
+	var ifds []*tiffIFD
+	for len(queue) > 0 && len(ifds) < rawMaxIFDs {
+		next := queue[0]
+		queue = queue[1:]
+		if next.offset <= 0 || next.offset >= size || visited[next.offset] {
+			continue
+		}
+		visited[next.offset] = true
+
+		ifd, following, err := readRawIFD(r, order, next.offset)
+		if err != nil {
+			if len(ifds) == 0 {
+				return nil, err
+			}
+			continue
+		}
+		ifds = append(ifds, ifd)
+		if next.depth == 0 {
+			queue = append(queue, pending{following, 0})
+		}
+		if next.depth < rawMaxSubDepth {
+			for _, sub := range ifd.subIFDs {
+				queue = append(queue, pending{sub, next.depth + 1})
+			}
+		}
+	}
+
+	info := &RawInfo{Format: rawFormat(header, ifds)}
+	if info.Format == "" {
+		return nil, ErrUnsupported
+	}
+
+	seen := make(map[int64]bool)
+	for _, ifd := range ifds {
+		if ifd.subfileType == 0 && ifd.width*ifd.height > info.Width*info.Height {
+			info.Width, info.Height = ifd.width, ifd.height
+			info.Compression = ifd.compression
+			info.Photometric = ifd.photometric
+		}
+
+		candidates := [][2]int64{{ifd.jpegOffset, ifd.jpegLength}}
+		if ifd.compression == 6 || ifd.compression == 7 {
+			candidates = append(candidates, [2]int64{ifd.stripOffset, ifd.stripLength})
+		}
+		for _, candidate := range candidates {
+			offset, length := candidate[0], candidate[1]
+			if offset <= 0 || length <= 0 || offset+length > size || seen[offset] {
+				continue
+			}
+			seen[offset] = true
+			if preview, ok := rawJPEGPreview(r, offset, length); ok {
+				info.Previews = append(info.Previews, preview)
+			}
+		}
+	}
+	sort.Slice(info.Previews, func(i, j int) bool {
+		return info.Previews[i].Width*info.Previews[i].Height > info.Previews[j].Width*info.Previews[j].Height
+	})
+	return info, nil
+}
+
+// FullSizePreview 返回全尺寸JPEG预览：长边不小于主图像长边的一半（排除缩略图与低分辨率预览），没有时为nil
+func (r *RawInfo) FullSizePreview() *RawPreview {
+	if len(r.Previews) == 0 {
+		return nil
+	}
+	preview := r.Previews[0]
+	if 2*max(preview.Width, preview.Height) < max(r.Width, r.Height) {
+		return nil
+	}
+	return &preview
+}
+
+// rawFormat 按CR2签名、DNGVersion标签与IFD0的相机厂商识别RAW格式，普通TIFF返回空字符串
+func rawFormat(header []byte, ifds []*tiffIFD) string {
+	if string(header[8:10]) == "CR" && header[10] == 2 {
+		return RawCR2
+	}
+	for _, ifd := range ifds {
+		if ifd.isDNGVersion {
+			return RawDNG
+		}
+	}
+	if len(ifds) == 0 {
+		return ""
+	}
+	switch maker := ifds[0].make; {
+	case strings.HasPrefix(maker, "NIKON"):
+		return RawNEF
+	case strings.HasPrefix(maker, "SONY"):
+		return RawARW
+	}
+	return ""
+}
+
+// readRawIFD 读取一个IFD中RAW识别所需的标签，返回IFD与链上下一个IFD的偏移
+func readRawIFD(r io.ReaderAt, order binary.ByteOrder, offset int64) (*tiffIFD, int64, error) {
+	countBytes, err := readAt(r, offset, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := int(order.Uint16(countBytes))
+	entries, err := readAt(r, offset+2, count*12+4)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ifd := &tiffIFD{}
+	for i := 0; i < count; i++ {
+		entry := entries[i*12 : i*12+12]
+		tag := order.Uint16(entry[0:])
+		kind := order.Uint16(entry[2:])
+		n := order.Uint32(entry[4:])
+
+		value := int64(0)
+		switch kind {
+		case tiffShort:
+			value = int64(order.Uint16(entry[8:]))
+		case tiffLong, tiffIFDType:
+			value = int64(order.Uint32(entry[8:]))
+		}
+
+		switch tag {
+		case tiffNewSubfileType:
+			ifd.subfileType = int(value)
+		case tiffCompression:
+			ifd.compression = int(value)
+		case tiffPhotometric:
+			ifd.photometric = int(value)
+		case tiffImageWidth:
+			ifd.width = int(value)
+		case tiffImageLength:
+			ifd.height = int(value)
+		case tiffStripOffsets:
+			if n == 1 {
+				ifd.stripOffset = value
+			}
+		case tiffStripCounts:
+			if n == 1 {
+				ifd.stripLength = value
+			}
+		case tiffJPEGOffset:
+			ifd.jpegOffset = value
+		case tiffJPEGLength:
+			ifd.jpegLength = value
+		case tiffDNGVersion:
+			ifd.isDNGVersion = true
+		case tiffMake:
+			ifd.make = readTIFFASCII(r, order, entry, n)
+		case tiffSubIFDs:
+			if kind != tiffLong && kind != tiffIFDType {
+				break
+			}
+			if n == 1 {
+				ifd.subIFDs = []int64{value}
+			} else if data, err := readAt(r, int64(order.Uint32(entry[8:])), int(min(n, 16))*4); err == nil {
+				for j := 0; j+4 <= len(data); j += 4 {
+					ifd.subIFDs = append(ifd.subIFDs, int64(order.Uint32(data[j:])))
+				}
+			}
+		}
+	}
+	return ifd, int64(order.Uint32(entries[count*12:])), nil
+}
+
+// readTIFFASCII 读取ASCII标签的值（4字节以内内联存储）
+func readTIFFASCII(r io.ReaderAt, order binary.ByteOrder, entry []byte, n uint32) string {
+	if n == 0 || n > 256 {
+		return ""
+	}
+	data := entry[8 : 8+min(n, 4)]
+	if n > 4 {
+		var err error
+		if data, err = readAt(r, int64(order.Uint32(entry[8:])), int(n)); err != nil {
+			return ""
+		}
+	}
+	return string(bytes.TrimRight(data, "\x00 "))
+}
+
+// rawJPEGPreview 检查嵌入的数据是否为可直接使用的JPEG预览（排除RAW数据使用的无损JPEG）
+func rawJPEGPreview(r io.ReaderAt, offset, length int64) (RawPreview, bool) {
+	jpeg, err := jpegquality.Analyze(io.NewSectionReader(r, offset, length))
+	if err != nil || jpeg.Lossless || jpeg.Width == 0 || jpeg.Height == 0 {
+		return RawPreview{}, false
+	}
+	return RawPreview{Offset: offset, Length: length, Width: jpeg.Width, Height: jpeg.Height}, true
+}
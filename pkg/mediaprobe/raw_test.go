@@ -0,0 +1,200 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeJPEG 编码指定尺寸的基线JPEG；lossless为true时把SOF0改为SOF3，模拟RAW数据使用的无损JPEG
+func encodeJPEG(t *testing.T, width, height int, lossless bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if lossless {
+		data[bytes.Index(data, []byte{0xFF, 0xC0})+1] = 0xC3
+	}
+	return data
+}
+
+// rawPage 测试RAW的一个IFD及其嵌入的JPEG，偏移与长度条目由buildRaw追加
+type rawPage struct {
+	tags     []tiffTag
+	preview  []byte // 嵌入的JPEG，为空时没有
+	viaStrip bool   // 以单条带（Compression为7）而不是JPEGInterchangeFormat标签存放
+}
+
+// buildRaw 构造小端RAW文件：各IFD串成链，嵌入的JPEG依次放在IFD之后
+func buildRaw(pages ...rawPage) []byte {
+	build := func(base int) []byte {
+		var ifds [][]tiffTag
+		offset := base
+		for _, page := range pages {
+			tags := append([]tiffTag{}, page.tags...)
+			if len(page.preview) > 0 {
+				if page.viaStrip {
+					tags = append(tags, short(tiffCompression, 7), long(tiffStripOffsets, uint32(offset)), long(tiffStripCounts, uint32(len(page.preview))))
+				} else {
+					tags = append(tags, long(tiffJPEGOffset, uint32(offset)), long(tiffJPEGLength, uint32(len(page.preview))))
+				}
+				offset += len(page.preview)
+			}
+			ifds = append(ifds, tags)
+		}
+		data, _ := buildTIFF(binary.LittleEndian, ifds...)
+		for _, page := range pages {
+			data = append(data, page.preview...)
+		}
+		return data
+	}
+	// IFD部分的长度与偏移值无关，先按0构造得到JPEG的起始位置
+	headerLength := len(build(0))
+	for _, page := range pages {
+		headerLength -= len(page.preview)
+	}
+	return build(headerLength)
+}
+
+// dngMain DNG主图像（NewSubfileType为0）
+func dngMain(width, height, compression uint32) []tiffTag {
+	return []tiffTag{
+		long(tiffNewSubfileType, 0),
+		long(tiffImageWidth, width),
+		long(tiffImageLength, height),
+		short(tiffCompression, compression),
+		short(tiffPhotometric, 32803),
+	}
+}
+
+func TestProbeRaw(t *testing.T) {
+	dngVersion := long(tiffDNGVersion, 0x00070001)
+	reduced := long(tiffNewSubfileType, 1)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		format      string
+		width       int
+		compression int
+		previews    [][2]int // 预览尺寸，按像素数从大到小
+		fullSize    bool
+	}{
+		{"DNG全尺寸预览", buildRaw(
+			rawPage{tags: []tiffTag{reduced, dngVersion, long(tiffImageWidth, 200), long(tiffImageLength, 100)}, preview: encodeJPEG(t, 200, 100, false), viaStrip: true},
+			rawPage{tags: dngMain(400, 200, 7)},
+		), RawDNG, 400, 7, [][2]int{{200, 100}}, true},
+		{"JPEG XL压缩的DNG", buildRaw(
+			rawPage{tags: append(dngMain(400, 200, DNGCompressionJXL), dngVersion)},
+		), RawDNG, 400, DNGCompressionJXL, nil, false},
+		{"只有缩略图", buildRaw(
+			rawPage{tags: append(dngMain(400, 200, 7), dngVersion)},
+			rawPage{tags: []tiffTag{reduced}, preview: encodeJPEG(t, 160, 80, false)},
+		), RawDNG, 400, 7, [][2]int{{160, 80}}, false},
+		{"多个预览按像素数排序", buildRaw(
+			rawPage{tags: append(dngMain(400, 200, 7), dngVersion)},
+			rawPage{tags: []tiffTag{reduced}, preview: encodeJPEG(t, 160, 80, false)},
+			rawPage{tags: []tiffTag{reduced}, preview: encodeJPEG(t, 400, 200, false)},
+		), RawDNG, 400, 7, [][2]int{{400, 200}, {160, 80}}, true},
+		{"无损JPEG不是预览", buildRaw(
+			rawPage{tags: append(dngMain(400, 200, 7), dngVersion)},
+			rawPage{tags: []tiffTag{reduced}, preview: encodeJPEG(t, 400, 200, true)},
+		), RawDNG, 400, 7, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ProbeRaw(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("ProbeRaw: %v", err)
+			}
+			if info.Format != tt.format || info.Width != tt.width || info.Height != tt.width/2 || info.Compression != tt.compression {
+				t.Errorf("ProbeRaw = %+v, 期望 %s %dx%d 压缩方式 %d", info, tt.format, tt.width, tt.width/2, tt.compression)
+			}
+			if info.Photometric != 32803 {
+				t.Errorf("Photometric = %d, 期望 32803", info.Photometric)
+			}
+			if len(info.Previews) != len(tt.previews) {
+				t.Fatalf("Previews = %+v, 期望 %v", info.Previews, tt.previews)
+			}
+			for i, preview := range info.Previews {
+				if preview.Width != tt.previews[i][0] || preview.Height != tt.previews[i][1] {
+					t.Errorf("Previews[%d] = %dx%d, 期望 %dx%d", i, preview.Width, preview.Height, tt.previews[i][0], tt.previews[i][1])
+				}
+				if !bytes.HasPrefix(tt.data[preview.Offset:], []byte{0xFF, 0xD8}) || preview.Offset+preview.Length > int64(len(tt.data)) {
+					t.Errorf("Previews[%d] 的位置 %d+%d 不是JPEG", i, preview.Offset, preview.Length)
+				}
+			}
+			if full := info.FullSizePreview(); (full != nil) != tt.fullSize {
+				t.Errorf("FullSizePreview = %+v, 期望存在 %v", full, tt.fullSize)
+			} else if full != nil && *full != info.Previews[0] {
+				t.Errorf("FullSizePreview = %+v, 期望最大的预览", full)
+			}
+		})
+	}
+}
+
+func TestProbeRawUnsupported(t *testing.T) {
+	plain, _ := buildTIFF(binary.LittleEndian, rgbPage(64, 64))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"普通TIFF", plain},
+		{"不是TIFF", []byte("not a raw file at all")},
+		{"过短", []byte("II*")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProbeRaw(bytes.NewReader(tt.data), int64(len(tt.data))); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("ProbeRaw = %v, 期望 ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestRawFormat(t *testing.T) {
+	tiffHeader := []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00")
+	cr2Header := []byte("II*\x00\x10\x00\x00\x00CR\x02\x00")
+	tests := []struct {
+		name   string
+		header []byte
+		ifds   []*tiffIFD
+		want   string
+	}{
+		{"CR2签名", cr2Header, nil, RawCR2},
+		{"SubIFD中的DNGVersion", tiffHeader, []*tiffIFD{{make: "Canon"}, {isDNGVersion: true}}, RawDNG},
+		{"尼康", tiffHeader, []*tiffIFD{{make: "NIKON CORPORATION"}}, RawNEF},
+		{"索尼", tiffHeader, []*tiffIFD{{make: "SONY"}}, RawARW},
+		{"其他厂商的TIFF", tiffHeader, []*tiffIFD{{make: "Canon"}}, ""},
+		{"没有IFD", tiffHeader, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rawFormat(tt.header, tt.ifds); got != tt.want {
+				t.Errorf("rawFormat = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeRawFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_0001.dng")
+	data := buildRaw(rawPage{tags: append(dngMain(400, 200, 7), long(tiffDNGVersion, 0x00070001))})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := ProbeRawFile(path); err != nil || info.Format != RawDNG {
+		t.Errorf("ProbeRawFile = %+v, %v, 期望 DNG", info, err)
+	}
+	if _, err := ProbeRawFile(filepath.Join(dir, "missing.dng")); !os.IsNotExist(err) {
+		t.Errorf("文件不存在时 err = %v, 期望 IsNotExist", err)
+	}
+}
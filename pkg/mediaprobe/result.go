@@ -0,0 +1,442 @@
+package mediaprobe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 媒体信息来源
+const (
+	SourceHeader  = "header"
+	SourceFFprobe = "ffprobe"
+)
+
+// alphaPixelFormats 带透明通道的ffprobe像素格式前缀
+var alphaPixelFormats = []string{"rgba", "argb", "bgra", "abgr", "yuva", "gbra", "ya"}
+
+// CommandRunner 执行外部命令并返回标准输出
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Prober 会话级探测缓存：每个文件只生成一个 ProbeResult，扫描、类型检测、品质评估与转换各阶段共享。
+// 文件大小或修改时间变化后重新探测。
+type Prober struct {
+	ffprobePath  string
+	exiftoolPath string
+	run          CommandRunner
+
+	mutex   sync.Mutex
+	results map[string]*ProbeResult
+}
+
+// NewProber 创建探测缓存，工具路径为空时对应的探测返回错误
+func NewProber(ffprobePath, exiftoolPath string) *Prober {
+	return &Prober{
+		ffprobePath:  ffprobePath,
+		exiftoolPath: exiftoolPath,
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).Output()
+		},
+		results: make(map[string]*ProbeResult),
+	}
+}
+
+// SetRunner 替换外部命令执行方式（如经工具管理器执行以获得重试与超时控制）
+func (p *Prober) SetRunner(run CommandRunner) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.run = run
+}
+
+// Get 返回文件的共享探测结果；文件无法访问时返回不缓存的结果，其各项探测均返回错误
+func (p *Prober) Get(path string) *ProbeResult {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return &ProbeResult{Path: path, prober: p, statErr: err}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if result, ok := p.results[path]; ok && result.Size == stat.Size() && result.ModTime.Equal(stat.ModTime()) {
+		return result
+	}
+	result := &ProbeResult{Path: path, Size: stat.Size(), ModTime: stat.ModTime(), prober: p}
+	p.results[path] = result
+	return result
+}
+
+// Forget 丢弃文件的探测结果
+func (p *Prober) Forget(path string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.results, path)
+}
+
+// runner 返回当前的命令执行方式
+func (p *Prober) runner() CommandRunner {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.run
+}
+
+// ProbeResult 单个文件的探测结果。头部解析在首次使用时执行一次并缓存；
+// FFprobe与exiftool只缓存成功的结果，失败（包括上下文取消或超时）时下次调用重试。
+type ProbeResult struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+
+	prober  *Prober
+	statErr error
+
+	mutex sync.Mutex
+
+	headDone bool
+	head     []byte
+	headErr  error
+
+	headerDone bool
+	header     *Info
+	headerErr  error
+
+	ffprobe *FFprobeData
+	exif    Exif
+}
+
+// HeadSize Head返回的文件开头长度，足以覆盖内容签名与SVG等文本格式的开头
+const HeadSize = 1024
+
+// Head 返回文件开头至多HeadSize字节，用于按内容签名识别格式；首次使用时读取一次并缓存
+func (r *ProbeResult) Head() ([]byte, error) {
+	if r.statErr != nil {
+		return nil, r.statErr
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.headDone {
+		r.head, r.headErr = readHead(r.Path)
+		r.headDone = true
+	}
+	return r.head, r.headErr
+}
+
+// readHead 读取文件开头至多HeadSize字节
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, HeadSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// Header 返回容器头部解析结果，不支持的格式返回 ErrUnsupported
+func (r *ProbeResult) Header() (*Info, error) {
+	if r.statErr != nil {
+		return nil, r.statErr
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.headerDone {
+		r.header, r.headerErr = ProbeFile(r.Path)
+		r.headerDone = true
+	}
+	return r.header, r.headerErr
+}
+
+// FFprobe 返回 ffprobe -show_format -show_streams 的解析结果
+func (r *ProbeResult) FFprobe(ctx context.Context) (*FFprobeData, error) {
+	if r.statErr != nil {
+		return nil, r.statErr
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.ffprobe != nil {
+		return r.ffprobe, nil
+	}
+
+	data, err := r.runFFprobe(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.ffprobe = data
+	return data, nil
+}
+
+// runFFprobe 执行ffprobe并解析JSON输出
+func (r *ProbeResult) runFFprobe(ctx context.Context) (*FFprobeData, error) {
+	if r.prober.ffprobePath == "" {
+		return nil, errors.New("未配置ffprobe")
+	}
+
+	output, err := r.prober.runner()(ctx, r.prober.ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		r.Path,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe执行失败: %w", err)
+	}
+
+	var data FFprobeData
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("解析ffprobe输出失败: %w", err)
+	}
+	return &data, nil
+}
+
+// Exif 返回 exiftool -json 输出的标签
+func (r *ProbeResult) Exif(ctx context.Context) (Exif, error) {
+	if r.statErr != nil {
+		return nil, r.statErr
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.exif != nil {
+		return r.exif, nil
+	}
+
+	exif, err := r.runExiftool(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.exif = exif
+	return exif, nil
+}
+
+// runExiftool 执行exiftool并解析JSON输出
+func (r *ProbeResult) runExiftool(ctx context.Context) (Exif, error) {
+	if r.prober.exiftoolPath == "" {
+		return nil, errors.New("未配置exiftool")
+	}
+
+	output, err := r.prober.runner()(ctx, r.prober.exiftoolPath, "-json", r.Path)
+	if err != nil {
+		return nil, fmt.Errorf("exiftool执行失败: %w", err)
+	}
+
+	var tags []Exif
+	if err := json.Unmarshal(output, &tags); err != nil {
+		return nil, fmt.Errorf("解析exiftool输出失败: %w", err)
+	}
+	if len(tags) == 0 {
+		return Exif{}, nil
+	}
+	return tags[0], nil
+}
+
+// Media 汇总各阶段常用的媒体信息：优先使用头部解析，不支持的格式使用FFprobe
+func (r *ProbeResult) Media(ctx context.Context) (*Media, error) {
+	if info, err := r.Header(); err == nil {
+		return &Media{
+			Source:     SourceHeader,
+			Format:     info.Format,
+			Codec:      info.Codec,
+			Width:      info.Width,
+			Height:     info.Height,
+			FrameCount: info.FrameCount,
+			Animated:   info.Animated,
+			HasAlpha:   info.HasAlpha,
+			BitDepth:   info.BitDepth,
+		}, nil
+	}
+
+	data, err := r.FFprobe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &Media{
+		Source:   SourceFFprobe,
+		Format:   data.Format.FormatName,
+		Duration: data.Duration(),
+		HasAudio: data.AudioStream() != nil,
+	}
+	if video := data.VideoStream(); video != nil {
+		media.Codec = video.CodecName
+		media.Width = video.Width
+		media.Height = video.Height
+		media.FrameCount = video.Frames(media.Duration)
+		media.Animated = media.FrameCount > 1
+		media.HasAlpha = IsAlphaPixelFormat(video.PixFmt)
+		media.BitDepth, _ = strconv.Atoi(video.BitsPerRawSample)
+	}
+	return media, nil
+}
+
+// Media 媒体概要信息
+type Media struct {
+	Source     string // header 或 ffprobe
+	Format     string // 头部格式名或ffprobe的format_name
+	Codec      string // 第一个视频流的编解码器
+	Width      int
+	Height     int
+	FrameCount int
+	Animated   bool
+	HasAlpha   bool
+	BitDepth   int
+	HasAudio   bool
+	Duration   float64 // 秒，头部解析时为0
+}
+
+// FFprobeData ffprobe JSON输出
+type FFprobeData struct {
+	Streams []Stream `json:"streams"`
+	Format  Format   `json:"format"`
+}
+
+// Stream ffprobe流信息
+type Stream struct {
+	Index            int               `json:"index"`
+	CodecName        string            `json:"codec_name"`
+	CodecType        string            `json:"codec_type"`
+	CodecTagString   string            `json:"codec_tag_string"`
+	Profile          string            `json:"profile"`
+	Width            int               `json:"width"`
+	Height           int               `json:"height"`
+	PixFmt           string            `json:"pix_fmt"`
+	ColorRange       string            `json:"color_range"`
+	ColorSpace       string            `json:"color_space"`
+	ColorTransfer    string            `json:"color_transfer"`
+	ColorPrimaries   string            `json:"color_primaries"`
+	BitsPerRawSample string            `json:"bits_per_raw_sample"`
+	RFrameRate       string            `json:"r_frame_rate"`
+	AvgFrameRate     string            `json:"avg_frame_rate"`
+	NbFrames         string            `json:"nb_frames"`
+	Duration         string            `json:"duration"`
+	BitRate          string            `json:"bit_rate"`
+	Disposition      map[string]int    `json:"disposition"`
+	Tags             map[string]string `json:"tags"`
+	SideDataList     []map[string]any  `json:"side_data_list"`
+}
+
+// Format ffprobe容器信息
+type Format struct {
+	Filename   string            `json:"filename"`
+	FormatName string            `json:"format_name"`
+	Duration   string            `json:"duration"`
+	Size       string            `json:"size"`
+	BitRate    string            `json:"bit_rate"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// VideoStream 返回第一个视频流
+func (d *FFprobeData) VideoStream() *Stream {
+	return d.firstStream("video")
+}
+
+// AudioStream 返回第一个音频流
+func (d *FFprobeData) AudioStream() *Stream {
+	return d.firstStream("audio")
+}
+
+// firstStream 返回第一个指定类型的流
+func (d *FFprobeData) firstStream(codecType string) *Stream {
+	for i := range d.Streams {
+		if d.Streams[i].CodecType == codecType {
+			return &d.Streams[i]
+		}
+	}
+	return nil
+}
+
+// Duration 容器时长（秒），缺失时为0
+func (d *FFprobeData) Duration() float64 {
+	duration, _ := strconv.ParseFloat(d.Format.Duration, 64)
+	return duration
+}
+
+// FrameRate 解析帧率：优先r_frame_rate，其次avg_frame_rate，格式为"分子/分母"或小数
+func (s *Stream) FrameRate() float64 {
+	for _, rate := range []string{s.RFrameRate, s.AvgFrameRate} {
+		if value := parseRate(rate); value > 0 {
+			return value
+		}
+	}
+	return 0
+}
+
+// Frames 帧数：优先nb_frames，缺失时由帧率与时长估算（流时长缺失时使用容器时长）
+func (s *Stream) Frames(containerDuration float64) int {
+	if frames, err := strconv.Atoi(s.NbFrames); err == nil {
+		return frames
+	}
+	duration, err := strconv.ParseFloat(s.Duration, 64)
+	if err != nil || duration <= 0 {
+		duration = containerDuration
+	}
+	if rate := s.FrameRate(); rate > 0 && duration > 0 {
+		return int(duration * rate)
+	}
+	return 0
+}
+
+// parseRate 解析"分子/分母"或小数形式的速率
+func parseRate(rate string) float64 {
+	if numerator, denominator, ok := strings.Cut(rate, "/"); ok {
+		num, err1 := strconv.ParseFloat(numerator, 64)
+		den, err2 := strconv.ParseFloat(denominator, 64)
+		if err1 != nil || err2 != nil || den == 0 {
+			return 0
+		}
+		return num / den
+	}
+	value, _ := strconv.ParseFloat(rate, 64)
+	return value
+}
+
+// IsAlphaPixelFormat 判断ffprobe像素格式是否带透明通道
+func IsAlphaPixelFormat(pixFmt string) bool {
+	for _, format := range alphaPixelFormats {
+		if strings.HasPrefix(pixFmt, format) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exif exiftool输出的标签，键为不带分组的标签名
+type Exif map[string]interface{}
+
+// String 返回标签的字符串形式，不存在时为空
+func (e Exif) String(tag string) string {
+	value, ok := e[tag]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,257 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeRunner 模拟外部命令：按工具名返回输出或错误，并记录调用次数
+type fakeRunner struct {
+	output map[string]string
+	err    error
+	calls  map[string]int
+}
+
+// run 实现 CommandRunner
+func (f *fakeRunner) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	f.calls[name]++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(f.output[name]), nil
+}
+
+// newTestProber 创建使用模拟命令的探测缓存
+func newTestProber() (*Prober, *fakeRunner) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			"ffprobe":  `{"streams":[{"codec_type":"video","codec_name":"h264","width":640,"height":480,"nb_frames":"30"}],"format":{"format_name":"mov,mp4","duration":"1.0"}}`,
+			"exiftool": `[{"Make":"Apple","ISO":100}]`,
+		},
+		calls: make(map[string]int),
+	}
+	prober := NewProber("ffprobe", "exiftool")
+	prober.SetRunner(runner.run)
+	return prober, runner
+}
+
+// writeProbeFile 写入文件并固定修改时间
+func writeProbeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProberMemoises(t *testing.T) {
+	prober, runner := newTestProber()
+	path := filepath.Join(t.TempDir(), "a.gif")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeProbeFile(t, path, gifFile(gifHeader(8, 8, -1), gifFrame(false)), modTime)
+
+	result := prober.Get(path)
+	if again := prober.Get(path); again != result {
+		t.Error("文件未变化时应返回同一个探测结果")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := result.FFprobe(context.Background()); err != nil {
+			t.Fatalf("FFprobe: %v", err)
+		}
+		exif, err := result.Exif(context.Background())
+		if err != nil || exif.String("Make") != "Apple" || exif.String("ISO") != "100" {
+			t.Fatalf("Exif = %v, %v", exif, err)
+		}
+	}
+	if runner.calls["ffprobe"] != 1 || runner.calls["exiftool"] != 1 {
+		t.Errorf("调用次数 = %v, 期望各1次", runner.calls)
+	}
+
+	info, err := result.Header()
+	if err != nil || info.Format != "gif" {
+		t.Fatalf("Header = %+v, %v", info, err)
+	}
+	// 头部解析结果已缓存：即使内容被同大小同修改时间的数据替换也不重新解析
+	writeProbeFile(t, path, make([]byte, len(gifFile(gifHeader(8, 8, -1), gifFrame(false)))), modTime)
+	if cached, err := prober.Get(path).Header(); err != nil || cached != info {
+		t.Errorf("Header = %+v, %v, 期望缓存的结果", cached, err)
+	}
+
+	media, err := result.Media(context.Background())
+	if err != nil || media.Source != SourceHeader || media.Width != 8 {
+		t.Errorf("Media = %+v, %v, 期望来自头部解析", media, err)
+	}
+}
+
+func TestProberInvalidation(t *testing.T) {
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name   string
+		change func(t *testing.T, prober *Prober, path string)
+		fresh  bool
+	}{
+		{"未变化", func(*testing.T, *Prober, string) {}, false},
+		{"大小变化", func(t *testing.T, _ *Prober, path string) {
+			writeProbeFile(t, path, []byte("longer content"), modTime)
+		}, true},
+		{"修改时间变化", func(t *testing.T, _ *Prober, path string) {
+			writeProbeFile(t, path, []byte("content"), modTime.Add(time.Second))
+		}, true},
+		{"Forget", func(_ *testing.T, prober *Prober, path string) {
+			prober.Forget(path)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober, runner := newTestProber()
+			path := filepath.Join(t.TempDir(), "a.mp4")
+			writeProbeFile(t, path, []byte("content"), modTime)
+
+			first := prober.Get(path)
+			if _, err := first.FFprobe(context.Background()); err != nil {
+				t.Fatalf("FFprobe: %v", err)
+			}
+			tt.change(t, prober, path)
+
+			second := prober.Get(path)
+			if (second != first) != tt.fresh {
+				t.Errorf("重新探测 = %v, 期望 %v", second != first, tt.fresh)
+			}
+			if _, err := second.FFprobe(context.Background()); err != nil {
+				t.Fatalf("FFprobe: %v", err)
+			}
+			want := 1
+			if tt.fresh {
+				want = 2
+			}
+			if runner.calls["ffprobe"] != want {
+				t.Errorf("ffprobe调用 %d 次, 期望 %d", runner.calls["ffprobe"], want)
+			}
+		})
+	}
+}
+
+func TestProbeResultFailuresNotCached(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		output  string
+		wantErr error
+	}{
+		{"上下文取消", canceled, nil, "", context.Canceled},
+		{"上下文超时", expired, nil, "", context.DeadlineExceeded},
+		{"命令失败", context.Background(), errors.New("exit status 1"), "", nil},
+		{"输出无法解析", context.Background(), nil, "not json", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober, runner := newTestProber()
+			path := filepath.Join(t.TempDir(), "a.mp4")
+			writeProbeFile(t, path, []byte("content"), time.Now())
+			good := runner.output
+			runner.err = tt.err
+			if tt.output != "" {
+				runner.output = map[string]string{"ffprobe": tt.output, "exiftool": tt.output}
+			}
+
+			result := prober.Get(path)
+			_, ffErr := result.FFprobe(tt.ctx)
+			_, exifErr := result.Exif(tt.ctx)
+			for _, err := range []error{ffErr, exifErr} {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Errorf("err = %v, 期望 %v", err, tt.wantErr)
+				}
+			}
+
+			// 失败不缓存：恢复后重新执行并成功
+			runner.err, runner.output = nil, good
+			if data, err := result.FFprobe(context.Background()); err != nil || data.VideoStream().Width != 640 {
+				t.Errorf("重试FFprobe = %+v, %v", data, err)
+			}
+			if exif, err := result.Exif(context.Background()); err != nil || exif.String("Make") != "Apple" {
+				t.Errorf("重试Exif = %v, %v", exif, err)
+			}
+			if runner.calls["ffprobe"] != 2 || runner.calls["exiftool"] != 2 {
+				t.Errorf("调用次数 = %v, 期望各2次", runner.calls)
+			}
+		})
+	}
+}
+
+func TestProberMissingFile(t *testing.T) {
+	prober, runner := newTestProber()
+	path := filepath.Join(t.TempDir(), "missing.mp4")
+
+	result := prober.Get(path)
+	if _, err := result.Header(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Header err = %v, 期望 ErrNotExist", err)
+	}
+	if _, err := result.Media(context.Background()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Media err = %v, 期望 ErrNotExist", err)
+	}
+	if runner.calls["ffprobe"] != 0 {
+		t.Error("文件无法访问时不应执行ffprobe")
+	}
+
+	// 文件出现后重新探测，且回退到FFprobe
+	writeProbeFile(t, path, []byte("content"), time.Now())
+	media, err := prober.Get(path).Media(context.Background())
+	if err != nil || media.Source != SourceFFprobe || media.Codec != "h264" || media.FrameCount != 30 || !media.Animated {
+		t.Errorf("Media = %+v, %v, 期望来自FFprobe", media, err)
+	}
+}
+
+func TestProbeResultHead(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"超过读取长度时截断", bytes.Repeat([]byte("a"), HeadSize*2), HeadSize},
+		{"短文件", []byte("8BPS"), 4},
+		{"空文件", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober, _ := newTestProber()
+			path := filepath.Join(dir, tt.name)
+			writeProbeFile(t, path, tt.data, modTime)
+
+			result := prober.Get(path)
+			head, err := result.Head()
+			if err != nil || len(head) != tt.want || !bytes.Equal(head, tt.data[:tt.want]) {
+				t.Fatalf("Head = %d 字节, %v, 期望 %d 字节", len(head), err, tt.want)
+			}
+			// 文件头缓存在探测结果中，文件内容变化（大小与修改时间不变）不影响同一结果
+			if tt.want > 0 {
+				writeProbeFile(t, path, bytes.Repeat([]byte("b"), len(tt.data)), modTime)
+				if again, _ := prober.Get(path).Head(); !bytes.Equal(again, head) {
+					t.Error("同一探测结果的Head应只读取一次")
+				}
+			}
+		})
+	}
+
+	prober, _ := newTestProber()
+	if _, err := prober.Get(filepath.Join(dir, "missing")).Head(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("文件不存在时 Head err = %v, 期望 ErrNotExist", err)
+	}
+}
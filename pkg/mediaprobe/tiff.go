@@ -0,0 +1,186 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// TIFF标签
+const (
+	tiffSubfileType     = 255 // 旧式子文件类型，2为缩小分辨率的图像；新式NewSubfileType位0表示同一含义
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffPhotometric     = 262
+	tiffSamplesPerPixel = 277
+	tiffExtraSamples    = 338
+	tiffICCProfile      = 34675
+	tiffImageSourceData = 37724 // Photoshop图层数据
+)
+
+// TIFF字段类型
+const (
+	tiffShort = 3
+	tiffLong  = 4
+)
+
+// tiffMaxPages 页数统计上限，防止损坏文件的IFD链成环
+const tiffMaxPages = 10000
+
+// probeTIFF 解析第一个全分辨率IFD的尺寸、位深与色彩标签，并沿IFD链统计页数；
+// 缩略图等缩小分辨率的IFD不算作页。相机RAW另外标记RAW格式
+func probeTIFF(r io.ReaderAt, size int64) (*Info, error) {
+	header, err := readAt(r, 0, 8)
+	if err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	info := &Info{Format: "tiff", Codec: "tiff", BitDepth: 8}
+	offset := int64(order.Uint32(header[4:]))
+	visited := make(map[int64]bool)
+	pages := 0
+	var reduced []byte // 第一个缩小分辨率IFD的条目，没有全分辨率页时使用
+
+	for ifds := 0; offset != 0; ifds++ {
+		if visited[offset] || ifds >= tiffMaxPages {
+			break
+		}
+		visited[offset] = true
+
+		countBytes, err := readAt(r, offset, 2)
+		if err != nil {
+			if ifds > 0 {
+				break // 后续页损坏时保留已统计的页数
+			}
+			return nil, err
+		}
+		count := int(order.Uint16(countBytes))
+		entries, err := readAt(r, offset+2, count*12+4)
+		if err != nil {
+			if ifds > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		ifd := entries[:count*12]
+		switch {
+		case tiffReducedResolution(order, ifd):
+			if reduced == nil {
+				reduced = ifd
+			}
+		case pages == 0:
+			parseTIFFEntries(r, order, ifd, info)
+			pages++
+		default:
+			pages++
+		}
+		offset = int64(order.Uint32(entries[count*12:]))
+	}
+
+	if pages == 0 && reduced != nil {
+		parseTIFFEntries(r, order, reduced, info)
+		pages = 1
+	}
+	info.FrameCount = pages
+
+	if info.Width == 0 || info.Height == 0 {
+		return nil, ErrMalformed
+	}
+	if raw, err := ProbeRaw(r, size); err == nil {
+		info.Raw = raw.Format
+	}
+	return info, nil
+}
+
+// tiffReducedResolution IFD是否为缩小分辨率的图像（NewSubfileType位0或SubfileType为2）
+func tiffReducedResolution(order binary.ByteOrder, entries []byte) bool {
+	for i := 0; i+12 <= len(entries); i += 12 {
+		entry := entries[i : i+12]
+		var value uint32
+		switch order.Uint16(entry[2:]) {
+		case tiffShort:
+			value = uint32(order.Uint16(entry[8:]))
+		case tiffLong:
+			value = order.Uint32(entry[8:])
+		default:
+			continue
+		}
+		switch order.Uint16(entry[0:]) {
+		case tiffNewSubfileType:
+			return value&1 != 0
+		case tiffSubfileType:
+			return value == 2
+		}
+	}
+	return false
+}
+
+// parseTIFFEntries 读取IFD条目中的图像标签
+func parseTIFFEntries(r io.ReaderAt, order binary.ByteOrder, entries []byte, info *Info) {
+	photometric := -1
+	samples := 1
+	for i := 0; i+12 <= len(entries); i += 12 {
+		entry := entries[i : i+12]
+		tag := order.Uint16(entry[0:])
+		kind := order.Uint16(entry[2:])
+		count := order.Uint32(entry[4:])
+
+		value := func() int {
+			switch kind {
+			case tiffShort:
+				return int(order.Uint16(entry[8:]))
+			case tiffLong:
+				return int(order.Uint32(entry[8:]))
+			}
+			return 0
+		}
+
+		switch tag {
+		case tiffImageWidth:
+			info.Width = value()
+		case tiffImageLength:
+			info.Height = value()
+		case tiffBitsPerSample:
+			if kind != tiffShort {
+				break
+			}
+			if count <= 2 {
+				info.BitDepth = value()
+			} else if data, err := readAt(r, int64(order.Uint32(entry[8:])), 2); err == nil {
+				info.BitDepth = int(order.Uint16(data)) // 多个样本时取第一个
+			}
+		case tiffPhotometric:
+			photometric = value()
+		case tiffSamplesPerPixel:
+			samples = value()
+		case tiffExtraSamples:
+			info.HasAlpha = true
+		case tiffICCProfile:
+			info.HasICC = true
+		case tiffImageSourceData:
+			info.Layered = true
+		}
+	}
+
+	switch photometric {
+	case 0, 1:
+		info.ColorModel = "gray"
+	case 3:
+		info.ColorModel = "palette"
+	case 5:
+		info.ColorModel = "cmyk"
+	case 6:
+		info.ColorModel = "ycbcr"
+	default:
+		info.ColorModel = "rgb"
+	}
+	// 没有ExtraSamples标签时，灰度2样本或RGB 4样本同样带透明通道
+	if (info.ColorModel == "gray" && samples == 2) || (info.ColorModel == "rgb" && samples == 4) {
+		info.HasAlpha = true
+	}
+}
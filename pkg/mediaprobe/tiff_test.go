@@ -0,0 +1,187 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// tiffTag 测试用IFD条目，值直接存放在条目中
+type tiffTag struct {
+	tag   uint16
+	kind  uint16
+	value uint32
+}
+
+// short/long 构造SHORT与LONG类型的单值条目
+func short(tag uint16, value uint32) tiffTag { return tiffTag{tag, tiffShort, value} }
+func long(tag uint16, value uint32) tiffTag  { return tiffTag{tag, tiffLong, value} }
+
+// tiffOrder 测试构造TIFF使用的字节序
+type tiffOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// buildTIFF 按顺序排列各页的IFD并串成链，返回文件内容与各IFD的位置
+func buildTIFF(order tiffOrder, pages ...[]tiffTag) ([]byte, []int) {
+	data := []byte("II*\x00\x08\x00\x00\x00")
+	if order.String() == binary.BigEndian.String() {
+		data = []byte("MM\x00*\x00\x00\x00\x08")
+	}
+
+	offsets := make([]int, len(pages))
+	offset := 8
+	for i, tags := range pages {
+		offsets[i] = offset
+		offset += 2 + len(tags)*12 + 4
+	}
+
+	for i, tags := range pages {
+		data = order.AppendUint16(data, uint16(len(tags)))
+		for _, tag := range tags {
+			data = order.AppendUint16(data, tag.tag)
+			data = order.AppendUint16(data, tag.kind)
+			data = order.AppendUint32(data, 1)
+			if tag.kind == tiffShort {
+				data = order.AppendUint16(data, uint16(tag.value))
+				data = order.AppendUint16(data, 0)
+			} else {
+				data = order.AppendUint32(data, tag.value)
+			}
+		}
+		next := uint32(0)
+		if i+1 < len(pages) {
+			next = uint32(offsets[i+1])
+		}
+		data = order.AppendUint32(data, next)
+	}
+	return data, offsets
+}
+
+// rgbPage 8位RGB页
+func rgbPage(width, height uint32) []tiffTag {
+	return []tiffTag{
+		long(tiffImageWidth, width),
+		long(tiffImageLength, height),
+		short(tiffBitsPerSample, 8),
+		short(tiffPhotometric, 2),
+		short(tiffSamplesPerPixel, 3),
+	}
+}
+
+func TestProbeTIFF(t *testing.T) {
+	var le, be tiffOrder = binary.LittleEndian, binary.BigEndian
+
+	gray16 := []tiffTag{
+		short(tiffImageWidth, 300),
+		short(tiffImageLength, 200),
+		short(tiffBitsPerSample, 16),
+		short(tiffPhotometric, 1),
+		short(tiffSamplesPerPixel, 2),
+	}
+	cmykLayered := append(rgbPage(100, 100)[:3],
+		short(tiffPhotometric, 5),
+		short(tiffSamplesPerPixel, 4),
+		long(tiffICCProfile, 0),
+		long(tiffImageSourceData, 0),
+	)
+
+	thumbnail := append(rgbPage(160, 120), long(tiffNewSubfileType, 1))
+	oldThumbnail := append(rgbPage(160, 120), short(tiffSubfileType, 2))
+	fullPage := append(rgbPage(4000, 3000), long(tiffNewSubfileType, 0))
+
+	looped, offsets := buildTIFF(le, rgbPage(64, 64), rgbPage(64, 64))
+	// 第二页的下一IFD指回第一页
+	le.PutUint32(looped[len(looped)-4:], uint32(offsets[0]))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		width      int
+		height     int
+		frames     int
+		bitDepth   int
+		colorModel string
+		alpha      bool
+		icc        bool
+		layered    bool
+	}{
+		{"小端单页RGB", first(buildTIFF(le, rgbPage(640, 480))), 640, 480, 1, 8, "rgb", false, false, false},
+		{"大端三页16位灰度带透明", first(buildTIFF(be, gray16, gray16, gray16)), 300, 200, 3, 16, "gray", true, false, false},
+		{"CMYK带ICC与图层", first(buildTIFF(le, cmykLayered)), 100, 100, 1, 8, "cmyk", false, true, true},
+		{"IFD链成环", looped, 64, 64, 2, 8, "rgb", false, false, false},
+		{"缩略图IFD不计为页", first(buildTIFF(le, rgbPage(4000, 3000), thumbnail)), 4000, 3000, 1, 8, "rgb", false, false, false},
+		{"缩略图在前时取全分辨率页", first(buildTIFF(be, thumbnail, fullPage, oldThumbnail, fullPage)), 4000, 3000, 2, 8, "rgb", false, false, false},
+		{"只有缩略图IFD", first(buildTIFF(le, thumbnail)), 160, 120, 1, 8, "rgb", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != "tiff" || info.Codec != "tiff" {
+				t.Errorf("格式 = %s/%s", info.Format, info.Codec)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.FrameCount != tt.frames {
+				t.Errorf("FrameCount = %d, 期望 %d", info.FrameCount, tt.frames)
+			}
+			if info.BitDepth != tt.bitDepth || info.ColorModel != tt.colorModel {
+				t.Errorf("位深/色彩模型 = %d/%s, 期望 %d/%s", info.BitDepth, info.ColorModel, tt.bitDepth, tt.colorModel)
+			}
+			if info.HasAlpha != tt.alpha || info.HasICC != tt.icc || info.Layered != tt.layered {
+				t.Errorf("透明/ICC/图层 = %v/%v/%v, 期望 %v/%v/%v",
+					info.HasAlpha, info.HasICC, info.Layered, tt.alpha, tt.icc, tt.layered)
+			}
+			if info.Raw != "" {
+				t.Errorf("普通TIFF不应标记为RAW: %s", info.Raw)
+			}
+		})
+	}
+}
+
+func TestProbeTIFFTruncated(t *testing.T) {
+	var le tiffOrder = binary.LittleEndian
+	twoPages, offsets := buildTIFF(le, rgbPage(640, 480), rgbPage(640, 480))
+	noSize := first(buildTIFF(le, []tiffTag{short(tiffBitsPerSample, 8)}))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		frames int  // 期望成功时的页数
+		fail   bool // 期望返回 ErrMalformed
+	}{
+		{"只有文件头", twoPages[:8], 0, true},
+		{"文件头不完整", twoPages[:6], 0, true},
+		{"第一页条目被截断", twoPages[:offsets[0]+2+12*2], 0, true},
+		{"第二页被截断时保留第一页", twoPages[:offsets[1]+6], 1, false},
+		{"缺少尺寸标签", noSize, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.fail {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("期望 ErrMalformed，实际为 %v (%+v)", err, info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.FrameCount != tt.frames {
+				t.Errorf("FrameCount = %d, 期望 %d", info.FrameCount, tt.frames)
+			}
+		})
+	}
+}
+
+// first 返回多返回值中的第一个
+func first(data []byte, _ []int) []byte {
+	return data
+}
@@ -0,0 +1,116 @@
+package mediaprobe
+
+import "io"
+
+// VP8X特性标志
+const (
+	webpFlagAnimation = 0x02
+	webpFlagAlpha     = 0x10
+	webpFlagICC       = 0x20
+)
+
+// probeWebP 遍历RIFF块：简单格式读取VP8/VP8L位流头，扩展格式读取VP8X画布并统计ANMF帧
+func probeWebP(r io.ReaderAt, size int64) (*Info, error) {
+	riff, err := readAt(r, 4, 4)
+	if err != nil {
+		return nil, err
+	}
+	end := min(size, 8+le32(riff))
+
+	info := &Info{Format: "webp", Codec: "webp", BitDepth: 8}
+	extended := false
+	frames := 0
+
+	for offset := int64(12); offset+8 <= end; {
+		header, err := readAt(r, offset, 8)
+		if err != nil {
+			return nil, err
+		}
+		chunk := string(header[0:4])
+		length := le32(header[4:])
+		data := offset + 8
+
+		switch chunk {
+		case "VP8X":
+			vp8x, err := readAt(r, data, 10)
+			if err != nil {
+				return nil, err
+			}
+			extended = true
+			info.HasICC = vp8x[0]&webpFlagICC != 0
+			info.HasAlpha = vp8x[0]&webpFlagAlpha != 0
+			info.Width = le24(vp8x[4:]) + 1
+			info.Height = le24(vp8x[7:]) + 1
+		case "VP8 ", "VP8L":
+			if err := parseWebPBitstream(r, chunk, data, info, !extended); err != nil {
+				return nil, err
+			}
+			if !extended {
+				info.FrameCount = 1
+				return info, nil
+			}
+		case "ALPH":
+			info.HasAlpha = true
+		case "ANMF":
+			// 帧头16字节，之后是该帧的位流块；色彩模型取自第一帧
+			if frames == 0 {
+				if sub, err := readAt(r, data+16, 8); err == nil {
+					subChunk := string(sub[0:4])
+					if subChunk == "VP8 " || subChunk == "VP8L" {
+						_ = parseWebPBitstream(r, subChunk, data+24, info, false)
+					}
+				}
+			}
+			frames++
+		}
+		offset = data + length + length&1 // 块按偶数字节对齐
+	}
+
+	if !extended || info.Width == 0 {
+		return nil, ErrMalformed
+	}
+	if frames > 0 {
+		info.FrameCount = frames
+		info.Animated = frames > 1
+	} else {
+		info.FrameCount = 1
+	}
+	return info, nil
+}
+
+// parseWebPBitstream 读取VP8（有损）或VP8L（无损）位流头；withSize为true时同时取尺寸
+func parseWebPBitstream(r io.ReaderAt, chunk string, offset int64, info *Info, withSize bool) error {
+	if chunk == "VP8 " {
+		// 3字节帧标签 + 起始码 9D 01 2A + 各14位的宽高
+		header, err := readAt(r, offset, 10)
+		if err != nil {
+			return err
+		}
+		if header[3] != 0x9D || header[4] != 0x01 || header[5] != 0x2A {
+			return ErrMalformed
+		}
+		info.ColorModel = "ycbcr"
+		if withSize {
+			info.Width = le16(header[6:]) & 0x3FFF
+			info.Height = le16(header[8:]) & 0x3FFF
+		}
+		return nil
+	}
+
+	// 签名0x2F + 14位宽-1、14位高-1、1位alpha、3位版本
+	header, err := readAt(r, offset, 5)
+	if err != nil {
+		return err
+	}
+	if header[0] != 0x2F {
+		return ErrMalformed
+	}
+	bits := le32(header[1:])
+	info.ColorModel = "rgb"
+	if withSize {
+		info.Width = int(bits&0x3FFF) + 1
+		info.Height = int(bits>>14&0x3FFF) + 1
+		info.HasAlpha = bits>>28&0x01 != 0
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// webpChunk RIFF块，奇数长度补齐一个字节
+func webpChunk(kind string, data []byte) []byte {
+	out := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// webpFile RIFF头 + 各块
+func webpFile(chunks ...[]byte) []byte {
+	body := bytes.Join(chunks, nil)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	return append(append(data, "WEBP"...), body...)
+}
+
+// webpVP8 有损位流：帧标签 + 起始码 + 宽高
+func webpVP8(width, height uint16) []byte {
+	data := []byte{0, 0, 0, 0x9D, 0x01, 0x2A}
+	data = binary.LittleEndian.AppendUint16(data, width)
+	return webpChunk("VP8 ", binary.LittleEndian.AppendUint16(data, height))
+}
+
+// webpVP8L 无损位流：签名 + 宽-1、高-1与透明标志
+func webpVP8L(width, height uint32, alpha bool) []byte {
+	bits := (width - 1) | (height-1)<<14
+	if alpha {
+		bits |= 1 << 28
+	}
+	return webpChunk("VP8L", binary.LittleEndian.AppendUint32([]byte{0x2F}, bits))
+}
+
+// webpVP8X 扩展格式头：特性标志 + 画布宽-1、高-1（各24位）
+func webpVP8X(flags byte, width, height uint32) []byte {
+	data := []byte{flags, 0, 0, 0}
+	data = append(data, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	data = append(data, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+	return webpChunk("VP8X", data)
+}
+
+// webpANMF 动画帧：16字节帧头 + 帧位流块
+func webpANMF(bitstream []byte) []byte {
+	return webpChunk("ANMF", append(make([]byte, 16), bitstream...))
+}
+
+func TestProbeWebP(t *testing.T) {
+	oversized := webpFile(webpVP8(8, 8))
+	binary.LittleEndian.PutUint32(oversized[4:], 0xFFFF)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		width      int
+		height     int
+		frames     int
+		animated   bool
+		colorModel string
+		alpha      bool
+		icc        bool
+	}{
+		{"简单有损", webpFile(webpVP8(400, 300)), 400, 300, 1, false, "ycbcr", false, false},
+		{"简单无损带透明", webpFile(webpVP8L(1000, 16383, true)), 1000, 16383, 1, false, "rgb", true, false},
+		{"扩展格式带ICC", webpFile(webpVP8X(webpFlagICC, 640, 480), webpChunk("ICCP", []byte("icc")), webpVP8(640, 480)), 640, 480, 1, false, "ycbcr", false, true},
+		{"扩展格式ALPH块", webpFile(webpVP8X(0, 64, 64), webpChunk("ALPH", []byte{0}), webpVP8(64, 64)), 64, 64, 1, false, "ycbcr", true, false},
+		{"扩展格式尺寸取画布", webpFile(webpVP8X(webpFlagAlpha, 1<<24, 2), webpVP8L(10, 10, false)), 1 << 24, 2, 1, false, "rgb", true, false},
+		{"三帧动画取第一帧色彩模型", webpFile(webpVP8X(webpFlagAnimation|webpFlagAlpha, 320, 240), webpChunk("ANIM", make([]byte, 6)),
+			webpANMF(webpVP8L(320, 240, true)), webpANMF(webpVP8(320, 240)), webpANMF(webpVP8(320, 240))), 320, 240, 3, true, "rgb", true, false},
+		{"单帧动画不算动画", webpFile(webpVP8X(webpFlagAnimation, 32, 32), webpANMF(webpVP8(32, 32))), 32, 32, 1, false, "ycbcr", false, false},
+		{"第一帧位流损坏时仍计帧", webpFile(webpVP8X(webpFlagAnimation, 32, 32), webpANMF([]byte("JUNK")), webpANMF(webpVP8(32, 32))), 32, 32, 2, true, "", false, false},
+		{"RIFF长度超出文件时按文件大小", oversized, 8, 8, 1, false, "ycbcr", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if info.Format != "webp" || info.Codec != "webp" || info.BitDepth != 8 {
+				t.Errorf("格式 = %+v", info)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("尺寸 = %dx%d, 期望 %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.FrameCount != tt.frames || info.Animated != tt.animated {
+				t.Errorf("FrameCount/Animated = %d/%v, 期望 %d/%v", info.FrameCount, info.Animated, tt.frames, tt.animated)
+			}
+			if info.ColorModel != tt.colorModel || info.HasAlpha != tt.alpha || info.HasICC != tt.icc {
+				t.Errorf("色彩模型/透明/ICC = %s/%v/%v, 期望 %s/%v/%v",
+					info.ColorModel, info.HasAlpha, info.HasICC, tt.colorModel, tt.alpha, tt.icc)
+			}
+		})
+	}
+}
+
+func TestProbeWebPMalformed(t *testing.T) {
+	badVP8 := webpChunk("VP8 ", []byte{0, 0, 0, 0x00, 0x01, 0x2A, 8, 0, 8, 0})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"没有块", webpFile()},
+		{"VP8起始码错误", webpFile(badVP8)},
+		{"VP8L签名错误", webpFile(webpChunk("VP8L", []byte{0x00, 0, 0, 0, 0}))},
+		{"VP8位流被截断", webpFile(webpVP8(8, 8)[:8+6])},
+		{"VP8X被截断", webpFile(webpVP8X(0, 8, 8)[:8+6])},
+		{"扩展格式没有画布尺寸", webpFile(webpChunk("ALPH", []byte{0}))},
+		{"扩展格式的位流损坏", webpFile(webpVP8X(0, 8, 8), badVP8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("期望 ErrMalformed，实际为 %v (%+v)", err, info)
+			}
+		})
+	}
+}
@@ -72,7 +72,10 @@ type ColorSpaceInfo struct {
 	ColorSpace         string  `json:"color_space"`
 	WhitePoint         string  `json:"white_point"`
 	Primaries          string  `json:"primaries"`
+	Transfer           string  `json:"transfer"` // HEIF/AVIF nclx的传输特性
 	Gamma              float64 `json:"gamma"`
+	HDR                bool    `json:"hdr"`        // PQ或HLG传输特性
+	WideGamut          bool    `json:"wide_gamut"` // Display P3、Rec.2020等超出sRGB的色域
 	ICCProfileEmbedded bool    `json:"icc_profile_embedded"`
 	NeedsConversion    bool    `json:"needs_conversion"`
 	AddedSRGB          bool    `json:"added_srgb"`
@@ -383,7 +386,11 @@ func (mm *MetadataMigrator) analyzeColorSpace(ctx context.Context, filePath stri
 		}
 	}
 
-	// 提取ICC配置文件信息
+	// 提取ICC配置文件信息（exiftool的扁平输出为ProfileDescription）
+	if desc, ok := metadata["ProfileDescription"].(string); ok && desc != "" {
+		colorSpaceInfo.ICCProfileEmbedded = true
+		colorSpaceInfo.ProfileName = desc
+	}
 	if iccProfile, exists := metadata["ICC_Profile"]; exists {
 		colorSpaceInfo.ICCProfileEmbedded = true
 		if profile, ok := iccProfile.(map[string]interface{}); ok {
@@ -409,6 +416,14 @@ func (mm *MetadataMigrator) analyzeColorSpace(ctx context.Context, filePath stri
 		}
 	}
 
+	// 提取HEIF/AVIF nclx色彩参数
+	if primaries, ok := metadata["ColorPrimaries"].(string); ok && colorSpaceInfo.Primaries == "" {
+		colorSpaceInfo.Primaries = primaries
+	}
+	if transfer, ok := metadata["TransferCharacteristics"].(string); ok {
+		colorSpaceInfo.Transfer = transfer
+	}
+
 	// 提取Gamma值
 	if gamma, exists := metadata["Gamma"]; exists {
 		if g, ok := gamma.(float64); ok {
@@ -420,8 +435,14 @@ func (mm *MetadataMigrator) analyzeColorSpace(ctx context.Context, filePath stri
 		}
 	}
 
-	// README要求：判断是否需要添加sRGB标签
-	if colorSpaceInfo.ColorSpace == "" || colorSpaceInfo.ColorSpace == "Uncalibrated" {
+	colorSpaceInfo.HDR = containsAnyFold(colorSpaceInfo.Transfer+" "+colorSpaceInfo.ProfileName, hdrKeywords)
+	colorSpaceInfo.WideGamut = containsAnyFold(colorSpaceInfo.Primaries+" "+colorSpaceInfo.ProfileName, wideGamutKeywords)
+
+	// README要求：判断是否需要添加sRGB标签。
+	// 广色域文件的EXIF ColorSpace通常为Uncalibrated，色彩由ICC配置或nclx描述，不能回退为sRGB
+	describedElsewhere := colorSpaceInfo.ICCProfileEmbedded || colorSpaceInfo.HDR || colorSpaceInfo.WideGamut
+	if mm.colorSpaceConfig.FallbackToSRGB && !describedElsewhere &&
+		(colorSpaceInfo.ColorSpace == "" || colorSpaceInfo.ColorSpace == "Uncalibrated") {
 		colorSpaceInfo.NeedsConversion = true
 		colorSpaceInfo.AddedSRGB = true
 		mm.logger.Debug("检测到无色彩空间信息，将添加sRGB标签",
@@ -431,6 +452,23 @@ func (mm *MetadataMigrator) analyzeColorSpace(ctx context.Context, filePath stri
 	return colorSpaceInfo, nil
 }
 
+// hdrKeywords/wideGamutKeywords HDR传输特性与广色域的描述关键字（小写）
+var (
+	hdrKeywords       = []string{"2084", "pq)", "hlg", "b67"}
+	wideGamutKeywords = []string{"p3", "2020", "2100", "adobe rgb", "prophoto", "dci"}
+)
+
+// containsAnyFold 不区分大小写判断是否包含任一关键字
+func containsAnyFold(s string, keywords []string) bool {
+	s = strings.ToLower(s)
+	for _, keyword := range keywords {
+		if strings.Contains(s, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
 // transformMetadataForTarget 根据目标格式转换元数据
 func (mm *MetadataMigrator) transformMetadataForTarget(targetFormat string, metadata map[string]interface{}) (map[string]interface{}, error) {
 	formatInfo, exists := mm.formatMappings[targetFormat]
@@ -0,0 +1,174 @@
+package pathtemplate
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultDateLayout {date}未指定布局时使用的Go时间布局
+const DefaultDateLayout = "2006/01/02"
+
+// 支持的模板变量
+const (
+	TokenFilename = "filename" // 源文件名（不含扩展名）
+	TokenExt      = "ext"      // 源文件扩展名（不含点，小写）
+	TokenRelPath  = "relpath"  // 源文件相对输入根目录的目录
+	TokenDate     = "date"     // 拍摄时间（EXIF DateTimeOriginal，缺失时为修改时间），可带Go时间布局
+	TokenCamera   = "camera"   // 相机型号（EXIF Make/Model）
+	TokenMode     = "mode"     // 转换模式
+	TokenQuality  = "quality"  // 被采用的质量设置，无损时为lossless
+)
+
+var knownTokens = map[string]bool{
+	TokenFilename: true,
+	TokenExt:      true,
+	TokenRelPath:  true,
+	TokenDate:     true,
+	TokenCamera:   true,
+	TokenMode:     true,
+	TokenQuality:  true,
+}
+
+// tokenPattern 匹配 {name} 或 {name:arg}
+var tokenPattern = regexp.MustCompile(`\{([a-z]+)(?::([^{}]*))?\}`)
+
+// Vars 模板展开所需的变量
+type Vars struct {
+	Filename string
+	Ext      string
+	RelPath  string
+	Date     time.Time
+	Camera   string
+	Mode     string
+	Quality  string
+}
+
+// HasTokens 模板是否包含变量（不含变量的目录模板按字面目录处理）
+func HasTokens(tmpl string) bool {
+	return tokenPattern.MatchString(tmpl)
+}
+
+// NeedsMetadata 模板是否引用了需要读取EXIF的变量
+func NeedsMetadata(tmpl string) bool {
+	for _, match := range tokenPattern.FindAllStringSubmatch(tmpl, -1) {
+		if match[1] == TokenDate || match[1] == TokenCamera {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验模板：变量必须已知，花括号必须成对，{date}布局不能包含路径分隔符或..
+func Validate(tmpl string) error {
+	for _, match := range tokenPattern.FindAllStringSubmatch(tmpl, -1) {
+		if !knownTokens[match[1]] {
+			return fmt.Errorf("未知的模板变量: {%s}", match[1])
+		}
+		if match[2] != "" && match[1] != TokenDate {
+			return fmt.Errorf("模板变量 {%s} 不支持参数", match[1])
+		}
+		if !validLayout(match[2]) {
+			return fmt.Errorf("日期布局不能包含路径分隔符或..: {%s:%s}", match[1], match[2])
+		}
+	}
+
+	rest := tokenPattern.ReplaceAllString(tmpl, "")
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("模板花括号不匹配: %s", tmpl)
+	}
+	return nil
+}
+
+// ValidateFilename 校验文件名模板：除Validate外，还不能包含路径分隔符或{relpath}
+func ValidateFilename(tmpl string) error {
+	if err := Validate(tmpl); err != nil {
+		return err
+	}
+	rest := tokenPattern.ReplaceAllStringFunc(tmpl, func(token string) string {
+		if strings.HasPrefix(token, "{"+TokenRelPath) {
+			return "/"
+		}
+		return ""
+	})
+	if strings.ContainsAny(rest, `/\`) {
+		return fmt.Errorf("文件名模板不能包含目录: %s", tmpl)
+	}
+	return nil
+}
+
+// Expand 展开模板；变量值中的路径分隔符会被替换，{relpath}与默认日期布局中的分隔符除外
+func Expand(tmpl string, vars Vars) string {
+	return tokenPattern.ReplaceAllStringFunc(tmpl, func(token string) string {
+		match := tokenPattern.FindStringSubmatch(token)
+		switch match[1] {
+		case TokenFilename:
+			return sanitize(vars.Filename, "unnamed")
+		case TokenExt:
+			return sanitize(strings.ToLower(strings.TrimPrefix(vars.Ext, ".")), "noext")
+		case TokenRelPath:
+			if vars.RelPath == "" || vars.RelPath == "." {
+				return "."
+			}
+			return vars.RelPath
+		case TokenDate:
+			if vars.Date.IsZero() {
+				return "unknown-date"
+			}
+			if match[2] == "" {
+				return vars.Date.Format(DefaultDateLayout)
+			}
+			if !validLayout(match[2]) {
+				return "invalid-date-layout"
+			}
+			return vars.Date.Format(match[2])
+		case TokenCamera:
+			return sanitize(vars.Camera, "unknown-camera")
+		case TokenMode:
+			return sanitize(vars.Mode, "unknown")
+		case TokenQuality:
+			return sanitize(vars.Quality, "lossless")
+		default:
+			return token
+		}
+	})
+}
+
+// validLayout 自定义日期布局不能包含路径分隔符或..，避免展开后的目录越出输出根目录
+func validLayout(layout string) bool {
+	return !strings.ContainsAny(layout, `/\`) && !strings.Contains(layout, "..")
+}
+
+// Root 模板中第一个变量之前的静态目录，展开结果必须位于其中；模板以变量开头时为"."
+func Root(tmpl string) string {
+	loc := tokenPattern.FindStringIndex(tmpl)
+	if loc == nil {
+		return filepath.Clean(tmpl)
+	}
+	prefix := tmpl[:loc[0]]
+	if prefix == "" {
+		return "."
+	}
+	if strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, string(filepath.Separator)) {
+		return filepath.Clean(prefix)
+	}
+	return filepath.Dir(prefix)
+}
+
+// Within 判断清理后的path是否位于root之内（含root本身）
+func Within(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// sanitize 去除值中的路径分隔符与首尾空白，空值使用fallback
+func sanitize(value, fallback string) string {
+	value = strings.TrimSpace(value)
+	value = strings.NewReplacer("/", "_", `\`, "_", ":", "-").Replace(value)
+	if value == "" || value == "." || value == ".." {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,150 @@
+package pathtemplate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr bool
+	}{
+		{"空模板", "", false},
+		{"字面目录", "/out/photos", false},
+		{"全部变量", "/out/{relpath}/{date}/{camera}/{mode}-{quality}/{filename}.{ext}", false},
+		{"日期布局", "/out/{date:2006}/{date:01-02}", false},
+		{"未知变量", "/out/{author}", true},
+		{"非日期变量带参数", "/out/{camera:short}", true},
+		{"花括号不匹配", "/out/{date", true},
+		{"多余的右花括号", "/out/date}", true},
+		{"日期布局含斜杠", "/out/{date:2006/01}", true},
+		{"日期布局含反斜杠", `/out/{date:2006\01}`, true},
+		{"日期布局含..", "/out/{date:..}", true},
+		{"日期布局为绝对路径", "{date:/etc}", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.tmpl); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) = %v, 期望错误 %v", tt.tmpl, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr bool
+	}{
+		{"文件名变量", "{date:20060102}_{filename}", false},
+		{"包含目录", "sub/{filename}", true},
+		{"包含反斜杠", `sub\{filename}`, true},
+		{"包含relpath", "{relpath}_{filename}", true},
+		{"未知变量", "{author}", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFilename(tt.tmpl); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFilename(%q) = %v, 期望错误 %v", tt.tmpl, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	date := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+	vars := Vars{
+		Filename: "IMG_0001",
+		Ext:      ".HEIC",
+		RelPath:  "trip/day1",
+		Date:     date,
+		Camera:   "Apple iPhone 15/Pro",
+		Mode:     "auto+",
+		Quality:  "q85",
+	}
+
+	tests := []struct {
+		name string
+		tmpl string
+		vars Vars
+		want string
+	}{
+		{"默认日期布局", "/out/{date}/{filename}", vars, "/out/2024/03/09/IMG_0001"},
+		{"自定义日期布局", "/out/{date:2006}/{date:01}", vars, "/out/2024/03"},
+		{"带时刻的日期布局", "{date:20060102_150405}", vars, "20240309_143000"},
+		{"扩展名小写且不含点", "{filename}.{ext}", vars, "IMG_0001.heic"},
+		{"relpath保留目录", "/out/{relpath}", vars, "/out/trip/day1"},
+		{"相机型号中的分隔符被替换", "{camera}", vars, "Apple iPhone 15_Pro"},
+		{"模式与质量", "{mode}-{quality}", vars, "auto+-q85"},
+		{"缺失日期", "{date}", Vars{}, "unknown-date"},
+		{"缺失相机", "{camera}", Vars{}, "unknown-camera"},
+		{"缺失质量按无损", "{quality}", Vars{}, "lossless"},
+		{"空relpath", "/out/{relpath}", Vars{}, "/out/."},
+		{"文件名为..", "{filename}", Vars{Filename: ".."}, "unnamed"},
+		{"非法日期布局不展开", "{date:../x}", vars, "invalid-date-layout"},
+		{"字面内容不变", "/out/photos", vars, "/out/photos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expand(tt.tmpl, tt.vars); got != tt.want {
+				t.Errorf("Expand(%q) = %q, 期望 %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasTokensAndNeedsMetadata(t *testing.T) {
+	tests := []struct {
+		tmpl          string
+		hasTokens     bool
+		needsMetadata bool
+	}{
+		{"/out/photos", false, false},
+		{"/out/{relpath}", true, false},
+		{"/out/{date:2006}", true, true},
+		{"/out/{camera}/{filename}", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			if got := HasTokens(tt.tmpl); got != tt.hasTokens {
+				t.Errorf("HasTokens = %v, 期望 %v", got, tt.hasTokens)
+			}
+			if got := NeedsMetadata(tt.tmpl); got != tt.needsMetadata {
+				t.Errorf("NeedsMetadata = %v, 期望 %v", got, tt.needsMetadata)
+			}
+		})
+	}
+}
+
+func TestRootAndWithin(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmpl     string
+		expanded string
+		wantRoot string
+		within   bool
+	}{
+		{"以目录结尾的前缀", "/out/{date}", "/out/2024/03/09", "/out", true},
+		{"前缀含部分文件名", "/out/photos-{date:2006}", "/out/photos-2024", "/out", true},
+		{"以变量开头", "{relpath}/{date:2006}", "trip/2024", ".", true},
+		{"展开后越出根目录", "/out/{relpath}", "/etc", "/out", false},
+		{"展开后为根目录的兄弟", "/out/{relpath}", "/out-other", "/out", false},
+		{"相对根目录中的..", "{relpath}", "../x", ".", false},
+		{"展开后为根目录本身", "/out/{relpath}", "/out", "/out", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := Root(tt.tmpl)
+			if root != filepath.FromSlash(tt.wantRoot) {
+				t.Errorf("Root(%q) = %q, 期望 %q", tt.tmpl, root, tt.wantRoot)
+			}
+			if got := Within(root, filepath.Clean(tt.expanded)); got != tt.within {
+				t.Errorf("Within(%q, %q) = %v, 期望 %v", root, tt.expanded, got, tt.within)
+			}
+		})
+	}
+}
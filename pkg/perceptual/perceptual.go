@@ -0,0 +1,272 @@
+package perceptual
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Metric 感知质量指标
+type Metric string
+
+const (
+	MetricSSIM        Metric = "ssim"
+	MetricMSSSIM      Metric = "ms-ssim"
+	MetricSSIMULACRA2 Metric = "ssimulacra2"
+	MetricButteraugli Metric = "butteraugli"
+)
+
+// maxScoringSide 评分前的最大长边，超过时按整数倍下采样
+const maxScoringSide = 2048
+
+// ParseMetric 解析指标名称，未知名称返回false
+func ParseMetric(name string) (Metric, bool) {
+	switch Metric(name) {
+	case MetricSSIM, MetricMSSSIM, MetricSSIMULACRA2, MetricButteraugli:
+		return Metric(name), true
+	default:
+		return "", false
+	}
+}
+
+// HigherIsBetter 指标是否越大越好（butteraugli为距离，越小越好）
+func (m Metric) HigherIsBetter() bool {
+	return m != MetricButteraugli
+}
+
+// Score 单次感知评分结果
+type Score struct {
+	Metric Metric  `json:"metric"`
+	Value  float64 `json:"value"`
+}
+
+// Normalized 将评分映射到0-1区间，便于与压缩比加权
+func (s Score) Normalized() float64 {
+	var v float64
+	switch s.Metric {
+	case MetricSSIMULACRA2:
+		v = s.Value / 100
+	case MetricButteraugli:
+		v = 1 / (1 + s.Value)
+	default:
+		v = s.Value
+	}
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Options 评分器配置
+type Options struct {
+	Metric          Metric
+	FFmpegPath      string
+	SSIMULACRA2Path string
+	ButteraugliPath string
+
+	// 门限：SSIM/MS-SSIM与SSIMULACRA2为下限，butteraugli为上限
+	MinSSIM        float64
+	MinSSIMULACRA2 float64
+	MaxButteraugli float64
+
+	Timeout time.Duration
+}
+
+// Scorer 感知质量评分器：Go内计算SSIM/MS-SSIM，外部工具存在时可用SSIMULACRA2/butteraugli
+type Scorer struct {
+	opts   Options
+	logger *zap.Logger
+}
+
+// NewScorer 创建评分器
+func NewScorer(opts Options, logger *zap.Logger) *Scorer {
+	if opts.Metric == "" {
+		opts.Metric = MetricMSSSIM
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	return &Scorer{opts: opts, logger: logger}
+}
+
+// Metric 返回实际使用的指标（外部工具缺失时回退到MS-SSIM）
+func (s *Scorer) Metric() Metric {
+	switch s.opts.Metric {
+	case MetricSSIMULACRA2:
+		if toolAvailable(s.opts.SSIMULACRA2Path) {
+			return MetricSSIMULACRA2
+		}
+		return MetricMSSSIM
+	case MetricButteraugli:
+		if toolAvailable(s.opts.ButteraugliPath) {
+			return MetricButteraugli
+		}
+		return MetricMSSSIM
+	default:
+		return s.opts.Metric
+	}
+}
+
+// Passes 判断评分是否达到配置的门限
+func (s *Scorer) Passes(score Score) bool {
+	switch score.Metric {
+	case MetricSSIMULACRA2:
+		return score.Value >= s.opts.MinSSIMULACRA2
+	case MetricButteraugli:
+		return s.opts.MaxButteraugli <= 0 || score.Value <= s.opts.MaxButteraugli
+	default:
+		return score.Value >= s.opts.MinSSIM
+	}
+}
+
+// Compare 解码参考图与候选图并计算感知评分
+func (s *Scorer) Compare(ctx context.Context, referencePath, candidatePath string) (Score, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	refPNG, err := s.decodeToPNG(ctx, referencePath)
+	if err != nil {
+		return Score{}, fmt.Errorf("解码参考图失败: %w", err)
+	}
+	candPNG, err := s.decodeToPNG(ctx, candidatePath)
+	if err != nil {
+		return Score{}, fmt.Errorf("解码候选图失败: %w", err)
+	}
+
+	metric := s.Metric()
+	switch metric {
+	case MetricSSIMULACRA2, MetricButteraugli:
+		return s.compareExternal(ctx, metric, refPNG, candPNG)
+	default:
+		return compareInProcess(metric, refPNG, candPNG)
+	}
+}
+
+// compareInProcess 在进程内计算SSIM/MS-SSIM
+func compareInProcess(metric Metric, refPNG, candPNG []byte) (Score, error) {
+	refImg, err := png.Decode(bytes.NewReader(refPNG))
+	if err != nil {
+		return Score{}, err
+	}
+	candImg, err := png.Decode(bytes.NewReader(candPNG))
+	if err != nil {
+		return Score{}, err
+	}
+	if refImg.Bounds().Size() != candImg.Bounds().Size() {
+		return Score{}, fmt.Errorf("尺寸不一致: %v vs %v", refImg.Bounds().Size(), candImg.Bounds().Size())
+	}
+
+	ref := lumaPlane(refImg).shrinkTo(maxScoringSide)
+	cand := lumaPlane(candImg).shrinkTo(maxScoringSide)
+
+	if metric == MetricSSIM {
+		return Score{Metric: MetricSSIM, Value: computeSSIM(ref, cand)}, nil
+	}
+	return Score{Metric: MetricMSSSIM, Value: computeMSSSIM(ref, cand)}, nil
+}
+
+// CompareImages 直接比较两幅已解码图像
+func CompareImages(metric Metric, ref, cand image.Image) (Score, error) {
+	if ref.Bounds().Size() != cand.Bounds().Size() {
+		return Score{}, fmt.Errorf("尺寸不一致: %v vs %v", ref.Bounds().Size(), cand.Bounds().Size())
+	}
+	x := lumaPlane(ref).shrinkTo(maxScoringSide)
+	y := lumaPlane(cand).shrinkTo(maxScoringSide)
+	if metric == MetricSSIM {
+		return Score{Metric: MetricSSIM, Value: computeSSIM(x, y)}, nil
+	}
+	return Score{Metric: MetricMSSSIM, Value: computeMSSSIM(x, y)}, nil
+}
+
+// numberPattern 匹配外部工具输出中的第一个数值
+var numberPattern = regexp.MustCompile(`-?\d+(\.\d+)?([eE][-+]?\d+)?`)
+
+// compareExternal 调用ssimulacra2/butteraugli外部工具
+func (s *Scorer) compareExternal(ctx context.Context, metric Metric, refPNG, candPNG []byte) (Score, error) {
+	refFile, err := writeTemp(refPNG)
+	if err != nil {
+		return Score{}, err
+	}
+	defer os.Remove(refFile)
+	candFile, err := writeTemp(candPNG)
+	if err != nil {
+		return Score{}, err
+	}
+	defer os.Remove(candFile)
+
+	tool := s.opts.SSIMULACRA2Path
+	if metric == MetricButteraugli {
+		tool = s.opts.ButteraugliPath
+	}
+
+	output, err := exec.CommandContext(ctx, tool, refFile, candFile).Output()
+	if err != nil {
+		return Score{}, fmt.Errorf("%s执行失败: %w", metric, err)
+	}
+
+	match := numberPattern.Find(output)
+	if match == nil {
+		return Score{}, fmt.Errorf("无法解析%s输出: %q", metric, output)
+	}
+	value, err := strconv.ParseFloat(string(match), 64)
+	if err != nil {
+		return Score{}, err
+	}
+	return Score{Metric: metric, Value: value}, nil
+}
+
+// decodeToPNG 使用FFmpeg将首帧解码为RGB PNG（支持AVIF/JXL等Go标准库无法解码的格式）
+func (s *Scorer) decodeToPNG(ctx context.Context, path string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, s.opts.FFmpegPath,
+		"-v", "error",
+		"-i", path,
+		"-frames:v", "1",
+		"-pix_fmt", "rgb24",
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("FFmpeg未输出任何数据")
+	}
+	return output, nil
+}
+
+func writeTemp(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "pixly_perceptual_*.png")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func toolAvailable(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := exec.LookPath(path)
+	return err == nil
+}
@@ -0,0 +1,184 @@
+package perceptual
+
+import (
+	"image"
+	"math"
+)
+
+// plane 单通道浮点图像平面（亮度）
+type plane struct {
+	w, h int
+	pix  []float64
+}
+
+// SSIM常量（8位动态范围）
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// msssimWeights MS-SSIM各尺度权重（Wang et al. 2003）
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// gaussianKernel 11抽头高斯核，sigma=1.5
+var gaussianKernel = func() []float64 {
+	const radius = 5
+	const sigma = 1.5
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := -radius; i <= radius; i++ {
+		v := math.Exp(-float64(i*i) / (2 * sigma * sigma))
+		kernel[i+radius] = v
+		sum += v
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}()
+
+// lumaPlane 提取BT.601亮度平面
+func lumaPlane(img image.Image) *plane {
+	b := img.Bounds()
+	p := &plane{w: b.Dx(), h: b.Dy(), pix: make([]float64, b.Dx()*b.Dy())}
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// RGBA返回16位值，转换到8位范围
+			p.pix[y*p.w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257.0
+		}
+	}
+	return p
+}
+
+// downsample 2x2均值下采样
+func (p *plane) downsample() *plane {
+	w, h := p.w/2, p.h/2
+	out := &plane{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := 2*y*p.w + 2*x
+			out.pix[y*w+x] = (p.pix[i] + p.pix[i+1] + p.pix[i+p.w] + p.pix[i+p.w+1]) / 4
+		}
+	}
+	return out
+}
+
+// shrinkTo 按整数倍下采样直到长边不超过maxSide，避免大图评分过慢
+func (p *plane) shrinkTo(maxSide int) *plane {
+	out := p
+	for maxSide > 0 && (out.w > maxSide || out.h > maxSide) && out.w >= 2 && out.h >= 2 {
+		out = out.downsample()
+	}
+	return out
+}
+
+// blur 可分离高斯模糊，边缘采用钳位
+func (p *plane) blur() *plane {
+	radius := len(gaussianKernel) / 2
+	tmp := make([]float64, len(p.pix))
+	for y := 0; y < p.h; y++ {
+		row := p.pix[y*p.w : (y+1)*p.w]
+		for x := 0; x < p.w; x++ {
+			sum := 0.0
+			for k, weight := range gaussianKernel {
+				sx := clampIndex(x+k-radius, p.w)
+				sum += row[sx] * weight
+			}
+			tmp[y*p.w+x] = sum
+		}
+	}
+	out := &plane{w: p.w, h: p.h, pix: make([]float64, len(p.pix))}
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			sum := 0.0
+			for k, weight := range gaussianKernel {
+				sy := clampIndex(y+k-radius, p.h)
+				sum += tmp[sy*p.w+x] * weight
+			}
+			out.pix[y*p.w+x] = sum
+		}
+	}
+	return out
+}
+
+// product 逐像素乘积
+func product(a, b *plane) *plane {
+	out := &plane{w: a.w, h: a.h, pix: make([]float64, len(a.pix))}
+	for i := range a.pix {
+		out.pix[i] = a.pix[i] * b.pix[i]
+	}
+	return out
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// ssimComponents 计算平均SSIM与平均对比度-结构项(cs)
+func ssimComponents(x, y *plane) (ssim, cs float64) {
+	muX := x.blur()
+	muY := y.blur()
+	sigmaXX := product(x, x).blur()
+	sigmaYY := product(y, y).blur()
+	sigmaXY := product(x, y).blur()
+
+	var ssimSum, csSum float64
+	for i := range x.pix {
+		mx, my := muX.pix[i], muY.pix[i]
+		vx := sigmaXX.pix[i] - mx*mx
+		vy := sigmaYY.pix[i] - my*my
+		cov := sigmaXY.pix[i] - mx*my
+
+		csMap := (2*cov + ssimC2) / (vx + vy + ssimC2)
+		lum := (2*mx*my + ssimC1) / (mx*mx + my*my + ssimC1)
+		ssimSum += lum * csMap
+		csSum += csMap
+	}
+	n := float64(len(x.pix))
+	return ssimSum / n, csSum / n
+}
+
+// computeSSIM 计算两幅图像亮度平面的SSIM
+func computeSSIM(ref, dist *plane) float64 {
+	ssim, _ := ssimComponents(ref, dist)
+	return ssim
+}
+
+// computeMSSSIM 计算多尺度SSIM，尺寸不足时自动减少尺度并重新归一化权重
+func computeMSSSIM(ref, dist *plane) float64 {
+	scales := len(msssimWeights)
+	minSide := ref.w
+	if ref.h < minSide {
+		minSide = ref.h
+	}
+	for scales > 1 && minSide>>(scales-1) < len(gaussianKernel) {
+		scales--
+	}
+
+	weights := msssimWeights[:scales]
+	weightSum := 0.0
+	for _, w := range weights {
+		weightSum += w
+	}
+
+	result := 1.0
+	x, y := ref, dist
+	for i := 0; i < scales; i++ {
+		ssim, cs := ssimComponents(x, y)
+		weight := weights[i] / weightSum
+		if i == scales-1 {
+			result *= math.Pow(math.Max(ssim, 0), weight)
+		} else {
+			result *= math.Pow(math.Max(cs, 0), weight)
+			x, y = x.downsample(), y.downsample()
+		}
+	}
+	return result
+}
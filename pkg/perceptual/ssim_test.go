@@ -0,0 +1,152 @@
+package perceptual
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// texturedImage 构造固定种子的随机纹理灰度图
+func texturedImage(size int) *image.Gray {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(256))
+	}
+	return img
+}
+
+// uniformImage 构造纯色灰度图
+func uniformImage(size int, value uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = value
+	}
+	return img
+}
+
+// shiftedImage 将图像整体右移dx个像素，左侧以边缘像素填充
+func shiftedImage(src *image.Gray, dx int) *image.Gray {
+	b := src.Bounds()
+	img := image.NewGray(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			img.SetGray(x, y, src.GrayAt(max(x-dx, 0), y))
+		}
+	}
+	return img
+}
+
+// blurredImage 对图像做box模糊
+func blurredImage(src *image.Gray, radius int) *image.Gray {
+	b := src.Bounds()
+	img := image.NewGray(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sum, n := 0, 0
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					sx, sy := x+dx, y+dy
+					if sx < 0 || sy < 0 || sx >= b.Dx() || sy >= b.Dy() {
+						continue
+					}
+					sum += int(src.GrayAt(sx, sy).Y)
+					n++
+				}
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+	return img
+}
+
+func TestCompareImagesIdentical(t *testing.T) {
+	img := texturedImage(128)
+	for _, metric := range []Metric{MetricSSIM, MetricMSSSIM} {
+		t.Run(string(metric), func(t *testing.T) {
+			score, err := CompareImages(metric, img, img)
+			if err != nil {
+				t.Fatalf("CompareImages: %v", err)
+			}
+			if score.Metric != metric || math.Abs(score.Value-1) > 1e-9 {
+				t.Errorf("相同图像评分 = %+v, 期望 %s 1.0", score, metric)
+			}
+		})
+	}
+}
+
+func TestCompareImagesUniform(t *testing.T) {
+	// 纯色图像的方差与协方差为0，SSIM只剩亮度项 (2ab+C1)/(a²+b²+C1)
+	const a, b = 100.0, 110.0
+	lum := (2*a*b + ssimC1) / (a*a + b*b + ssimC1)
+	ref, dist := uniformImage(256, a), uniformImage(256, b)
+
+	score, err := CompareImages(MetricSSIM, ref, dist)
+	if err != nil {
+		t.Fatalf("CompareImages: %v", err)
+	}
+	if math.Abs(score.Value-lum) > 1e-6 {
+		t.Errorf("SSIM = %v, 期望 %v", score.Value, lum)
+	}
+
+	// 256像素足够5个尺度：各尺度cs为1，只有最粗尺度的亮度项计入
+	weightSum := 0.0
+	for _, w := range msssimWeights {
+		weightSum += w
+	}
+	want := math.Pow(lum, msssimWeights[len(msssimWeights)-1]/weightSum)
+	score, err = CompareImages(MetricMSSSIM, ref, dist)
+	if err != nil {
+		t.Fatalf("CompareImages: %v", err)
+	}
+	if math.Abs(score.Value-want) > 1e-6 {
+		t.Errorf("MS-SSIM = %v, 期望 %v", score.Value, want)
+	}
+}
+
+func TestCompareImagesDegraded(t *testing.T) {
+	ref := texturedImage(128)
+	scorer := NewScorer(Options{MinSSIM: 0.95}, nil)
+
+	tests := []struct {
+		name string
+		dist image.Image
+	}{
+		{"平移2像素", shiftedImage(ref, 2)},
+		{"模糊", blurredImage(ref, 1)},
+	}
+	for _, tt := range tests {
+		for _, metric := range []Metric{MetricSSIM, MetricMSSSIM} {
+			t.Run(tt.name+"/"+string(metric), func(t *testing.T) {
+				score, err := CompareImages(metric, ref, tt.dist)
+				if err != nil {
+					t.Fatalf("CompareImages: %v", err)
+				}
+				if score.Value >= 0.95 {
+					t.Errorf("评分 = %v, 期望低于门限0.95", score.Value)
+				}
+				if scorer.Passes(score) {
+					t.Errorf("评分 %v 不应通过门限", score.Value)
+				}
+			})
+		}
+	}
+}
+
+func TestCompareImagesSizeMismatch(t *testing.T) {
+	if _, err := CompareImages(MetricSSIM, uniformImage(16, 0), uniformImage(32, 0)); err == nil {
+		t.Error("尺寸不一致时应返回错误")
+	}
+}
+
+func TestComputeMSSSIMScales(t *testing.T) {
+	// 小图自动减少尺度，相同图像仍为1
+	for _, size := range []int{16, 40, 100} {
+		p := lumaPlane(texturedImage(size))
+		if got := computeMSSSIM(p, p); math.Abs(got-1) > 1e-9 {
+			t.Errorf("%d像素相同图像 MS-SSIM = %v, 期望 1", size, got)
+		}
+	}
+}
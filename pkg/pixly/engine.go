@@ -0,0 +1,310 @@
+// Package pixly 可嵌入的转换引擎：在进程内评估与转换媒体文件，不读写终端。
+package pixly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pixly/config"
+	"pixly/core/converter"
+
+	"go.uber.org/zap"
+)
+
+// 转换模式
+const (
+	ModeAutoPlus = string(converter.ModeAutoPlus)
+	ModeQuality  = string(converter.ModeQuality)
+	ModeEmoji    = string(converter.ModeEmoji)
+)
+
+// Event 转换事件，与 --events 输出的NDJSON事件相同
+type Event = converter.Event
+
+// EventType 事件类型
+type EventType = converter.EventType
+
+// 事件类型
+const (
+	EventScanStarted    = converter.EventScanStarted
+	EventFileAssessed   = converter.EventFileAssessed
+	EventFileConverted  = converter.EventFileConverted
+	EventFileSkipped    = converter.EventFileSkipped
+	EventFileFailed     = converter.EventFileFailed
+	EventSessionSummary = converter.EventSessionSummary
+)
+
+// PlanEntry 单个文件的计划
+type PlanEntry = converter.PlanEntry
+
+// PlanSummary 计划汇总
+type PlanSummary = converter.PlanSummary
+
+// Observer 事件观察者，OnEvent 可能被多个goroutine并发调用
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc 函数形式的观察者
+type ObserverFunc func(event Event)
+
+// OnEvent 实现 Observer
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// Options 引擎选项，零值可用
+type Options struct {
+	Mode      string         // auto+、quality、emoji，默认 auto+
+	Config    *config.Config // 为nil时与命令行相同方式加载（~/.pixly.yaml、PIXLY_ 环境变量，均不存在时使用默认值）
+	Logger    *zap.Logger    // 为nil时不输出日志
+	OutputDir string         // 输出目录或模板，覆盖配置；为空时原地转换
+	Workers   int            // Plan 的并发数，覆盖配置
+	Observer  Observer       // 事件观察者，可为nil
+}
+
+// Plan 转换计划
+type Plan struct {
+	Entries     []PlanEntry `json:"entries"`
+	Unsupported []string    `json:"unsupported,omitempty"` // 非媒体文件，未评估
+	Summary     PlanSummary `json:"summary"`
+}
+
+// Result 单个文件的转换结果
+type Result struct {
+	Path          string        `json:"path"`
+	OutputPath    string        `json:"output_path,omitempty"`
+	OriginalSize  int64         `json:"original_size"`
+	OutputSize    int64         `json:"output_size"`
+	Method        string        `json:"method,omitempty"`
+	Duration      time.Duration `json:"duration"`
+	Skipped       bool          `json:"skipped"`
+	SkipReason    string        `json:"skip_reason,omitempty"`
+	QualityMetric string        `json:"quality_metric,omitempty"`
+	QualityScore  float64       `json:"quality_score,omitempty"`
+}
+
+// Engine 转换引擎，方法可并发调用
+type Engine struct {
+	converter *converter.Converter
+	workers   int
+}
+
+// NewEngine 创建转换引擎；使用完毕后调用 Close
+func NewEngine(opts Options) (*Engine, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = ModeAutoPlus
+	}
+	switch mode {
+	case ModeAutoPlus, ModeQuality, ModeEmoji:
+	default:
+		return nil, fmt.Errorf("不支持的转换模式: %s", mode)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	var cfg config.Config
+	if opts.Config != nil {
+		cfg = *opts.Config
+	} else {
+		loaded, err := config.NewConfig("", logger)
+		if err != nil {
+			return nil, fmt.Errorf("加载配置失败: %w", err)
+		}
+		cfg = *loaded
+	}
+	if opts.OutputDir != "" {
+		cfg.Output.DirectoryTemplate = opts.OutputDir
+	}
+	if opts.Workers > 0 {
+		cfg.Concurrency.ConversionWorkers = opts.Workers
+	}
+	if cfg.Concurrency.ConversionWorkers <= 0 {
+		cfg.Concurrency.ConversionWorkers = 1
+	}
+	cfg.Advanced.UI.SilentMode = true
+	cfg.Advanced.UI.DisableUI = true
+
+	// 看门狗的交互模式会读取标准输入，嵌入时关闭
+	watchdogConfig := converter.GetDefaultWatchdogConfig()
+	watchdogConfig.Enabled = false
+
+	conv, err := converter.NewConverterWithWatchdog(&cfg, logger, mode, watchdogConfig)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Observer != nil {
+		conv.SetEventSink(observerSink{opts.Observer})
+	}
+
+	return &Engine{
+		converter: conv,
+		workers:   cfg.Concurrency.ConversionWorkers,
+	}, nil
+}
+
+// Plan 评估文件与目录（递归，忽略隐藏文件）并生成转换计划，不改动任何文件
+func (e *Engine) Plan(ctx context.Context, paths []string) (*Plan, error) {
+	files, err := collectFiles(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]PlanEntry, len(files))
+	errs := make([]error, len(files))
+	semaphore := make(chan struct{}, e.workers)
+	var wg sync.WaitGroup
+	for i, path := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			entries[i], errs[i] = e.converter.PlanFile(path)
+		}(i, path)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	for i, path := range files {
+		switch {
+		case errors.Is(errs[i], converter.ErrUnsupportedFile):
+			plan.Unsupported = append(plan.Unsupported, path)
+		case errs[i] != nil:
+			return nil, errs[i]
+		default:
+			plan.Entries = append(plan.Entries, entries[i])
+		}
+	}
+	sort.Slice(plan.Entries, func(i, j int) bool {
+		return plan.Entries[i].Path < plan.Entries[j].Path
+	})
+
+	plan.Summary.TotalFiles = len(plan.Entries)
+	for _, entry := range plan.Entries {
+		plan.Summary.OriginalSize += entry.Size
+		plan.Summary.EstimatedSize += entry.EstimatedSize
+		if entry.Action == converter.ActionSkip {
+			plan.Summary.SkipFiles++
+		} else {
+			plan.Summary.ConvertFiles++
+		}
+	}
+	return plan, nil
+}
+
+// Convert 转换单个文件。转换失败时返回的错误来自转换器，可用 errors.As 取得 *converter.PixlyError；
+// 文件已是目标格式或按路由保留原文件时 Result.Skipped 为true。
+// ctx 取消时终止该文件进行中的编码器进程并返回 ctx.Err()。
+func (e *Engine) Convert(ctx context.Context, file string) (Result, error) {
+	conversion, err := e.converter.ConvertFileContext(ctx, file)
+	if err != nil {
+		return Result{Path: file}, err
+	}
+
+	result := Result{
+		Path:          conversion.OriginalFile.Path,
+		OutputPath:    conversion.OutputPath,
+		OriginalSize:  conversion.OriginalSize,
+		OutputSize:    conversion.CompressedSize,
+		Method:        conversion.Method,
+		Duration:      conversion.Duration,
+		Skipped:       conversion.Skipped,
+		SkipReason:    conversion.SkipReason,
+		QualityMetric: conversion.QualityMetric,
+		QualityScore:  conversion.QualityScore,
+	}
+	if !conversion.Success {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if conversion.Error != nil {
+			return result, conversion.Error
+		}
+		return result, fmt.Errorf("转换失败: %s", result.Path)
+	}
+	return result, nil
+}
+
+// Close 关闭引擎并释放工作池与缓存
+func (e *Engine) Close() error {
+	return e.converter.Close()
+}
+
+// observerSink 将转换器事件转发给观察者
+type observerSink struct {
+	observer Observer
+}
+
+// Emit 实现 converter.EventSink
+func (s observerSink) Emit(event Event) {
+	s.observer.OnEvent(event)
+}
+
+// collectFiles 展开目录，返回去重后的文件列表
+func collectFiles(ctx context.Context, paths []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, root := range paths {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析路径 %s: %w", root, err)
+		}
+		info, err := os.Stat(absRoot)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(absRoot)
+			continue
+		}
+
+		err = filepath.WalkDir(absRoot, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if path != absRoot && strings.HasPrefix(entry.Name(), ".") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.Type().IsRegular() {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,199 @@
+package pixly
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"pixly/config"
+	"pixly/core/converter"
+
+	"go.uber.org/zap"
+)
+
+// newTestEngine 使用默认配置创建引擎：HOME指向临时目录，不读取用户配置，也不写入用户缓存
+func newTestEngine(t *testing.T, opts Options) *Engine {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := config.NewConfig("", zap.NewNop())
+	if err != nil {
+		t.Fatalf("加载默认配置失败: %v", err)
+	}
+	cfg.Advanced.Cache.Enabled = false
+	opts.Config = cfg
+
+	engine, err := NewEngine(opts)
+	if err != nil {
+		t.Fatalf("创建引擎失败: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+// writeFile 写入测试文件并返回其绝对路径
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
+
+func TestNewEngineMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{ModeAutoPlus, false},
+		{ModeQuality, false},
+		{ModeEmoji, false},
+		{"fast", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if tt.wantErr {
+				if _, err := NewEngine(Options{Mode: tt.mode, Config: &config.Config{}}); err == nil {
+					t.Fatalf("模式 %q 应被拒绝", tt.mode)
+				}
+				return
+			}
+			newTestEngine(t, Options{Mode: tt.mode})
+		})
+	}
+}
+
+func TestConvertCanceledContext(t *testing.T) {
+	engine := newTestEngine(t, Options{})
+	file := writeFile(t, filepath.Join(t.TempDir(), "photo.png"), "not really a png")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := engine.Convert(ctx, file)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消的上下文应返回 context.Canceled，实际为 %v", err)
+	}
+	if result.Path != file {
+		t.Errorf("Result.Path = %q, 期望 %q", result.Path, file)
+	}
+	if _, statErr := os.Stat(file); statErr != nil {
+		t.Errorf("取消的转换不应改动源文件: %v", statErr)
+	}
+}
+
+func TestConvertUnsupportedFile(t *testing.T) {
+	engine := newTestEngine(t, Options{})
+	file := writeFile(t, filepath.Join(t.TempDir(), "notes.txt"), "plain text")
+
+	_, err := engine.Convert(context.Background(), file)
+	if !errors.Is(err, converter.ErrUnsupportedFile) {
+		t.Fatalf("非媒体文件应返回 ErrUnsupportedFile，实际为 %v", err)
+	}
+}
+
+func TestConvertTargetFormatSkipped(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		events []EventType
+	)
+	observer := ObserverFunc(func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event.Type)
+	})
+	engine := newTestEngine(t, Options{Observer: observer})
+	file := writeFile(t, filepath.Join(t.TempDir(), "photo.jxl"), "jxl payload")
+
+	result, err := engine.Convert(context.Background(), file)
+	if err != nil {
+		t.Fatalf("目标格式文件不应返回错误: %v", err)
+	}
+	if !result.Skipped || result.OutputPath != file || result.OutputSize != result.OriginalSize {
+		t.Errorf("目标格式文件应原样跳过，实际结果 %+v", result)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !reflect.DeepEqual(events, []EventType{EventFileSkipped}) {
+		t.Errorf("观察者收到的事件 = %v, 期望 [%s]", events, EventFileSkipped)
+	}
+}
+
+func TestPlanUnsupportedFiles(t *testing.T) {
+	engine := newTestEngine(t, Options{})
+	dir := t.TempDir()
+	notes := writeFile(t, filepath.Join(dir, "notes.txt"), "plain text")
+	writeFile(t, filepath.Join(dir, ".hidden", "secret.txt"), "hidden")
+
+	plan, err := engine.Plan(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatalf("生成计划失败: %v", err)
+	}
+	if len(plan.Entries) != 0 {
+		t.Errorf("不应有可转换的条目: %+v", plan.Entries)
+	}
+	if !reflect.DeepEqual(plan.Unsupported, []string{notes}) {
+		t.Errorf("Unsupported = %v, 期望 [%s]", plan.Unsupported, notes)
+	}
+	if plan.Summary.TotalFiles != 0 {
+		t.Errorf("Summary.TotalFiles = %d, 期望 0", plan.Summary.TotalFiles)
+	}
+}
+
+func TestPlanCanceledContext(t *testing.T) {
+	engine := newTestEngine(t, Options{})
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.txt"), "plain text")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.Plan(ctx, []string{dir}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消的上下文应返回 context.Canceled，实际为 %v", err)
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, filepath.Join(dir, "a.png"), "a")
+	b := writeFile(t, filepath.Join(dir, "sub", "b.jpg"), "b")
+	writeFile(t, filepath.Join(dir, ".hidden.png"), "hidden")
+	writeFile(t, filepath.Join(dir, ".cache", "c.png"), "hidden dir")
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"目录递归且忽略隐藏文件", []string{dir}, []string{a, b}},
+		{"单个文件", []string{a}, []string{a}},
+		{"重复路径去重", []string{a, dir}, []string{a, b}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := collectFiles(context.Background(), tt.paths)
+			if err != nil {
+				t.Fatalf("collectFiles: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectFiles = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := collectFiles(context.Background(), []string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("不存在的路径应返回错误")
+	}
+}
@@ -13,9 +13,7 @@ import (
 	"bufio"
 
 	"go.uber.org/zap"
-	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/process"
-	"github.com/shirou/gopsutil/v3/disk"
 )
 
 // ProcessMonitor 进程监控器 - README要求的防卡死机制
@@ -80,13 +78,17 @@ func (mp *MonitoredProcess) Process() (*process.Process, error) {
 // ProcessContext 进程上下文信息
 type ProcessContext struct {
 	SourceFile      string            `json:"source_file"`      // 源文件路径
-	TargetFile      string            `json:target_file`      // 目标文件路径
+	TargetFile      string            `json:"target_file"`      // 目标文件路径
 	FileSize        int64             `json:"file_size"`        // 文件大小
 	FileFormat      string            `json:"file_format"`      // 文件格式
 	Operation       string            `json:"operation"`        // 操作类型
 	ComplexityLevel ComplexityLevel   `json:"complexity_level"` // 复杂度等级
 	Priority        Priority          `json:"priority"`         // 优先级
 	Metadata        map[string]string `json:"metadata"`         // 附加元数据
+	Encoder         string            `json:"encoder"`          // 编码器，耗时模型按编码器拟合；为空时使用静态估算
+	Effort          int               `json:"effort"`           // 编码努力程度（cjxl -e、avifenc --speed等）
+	Pixels          int64             `json:"pixels"`           // 单帧像素数
+	Frames          int               `json:"frames"`           // 帧数，静态图片为1
 }
 
 // ActivityChecker 活动检查器接口
@@ -113,7 +115,8 @@ type TimeoutEstimator struct {
 	complexityMultiplier map[ComplexityLevel]float64 // 复杂度倍数
 	formatTimeMultiplier map[string]float64          // 格式处理时间倍数
 	historicalData       []ProcessingRecord          // 历史处理记录
-	adaptiveEnabled      bool                        // 是否启用自适应估算
+	adaptiveEnabled      bool                        // 是否启用自适应估算（记录样本并使用学习到的耗时模型）
+	model                *TimingModel                // 学习到的耗时模型（未设置时只使用静态估算）
 	minTimeout           time.Duration               // 最小超时时间
 	maxTimeout           time.Duration               // 最大超时时间
 }
@@ -132,7 +135,7 @@ type ProcessingRecord struct {
 // MonitoringStats 监控统计
 type MonitoringStats struct {
 	TotalProcesses      int                        `json:"total_processes"`
-	CompletedProcesses  int                        `json:completed_processes`
+	CompletedProcesses  int                        `json:"completed_processes"`
 	TerminatedProcesses int                        `json:"terminated_processes"`
 	HungProcesses       int                        `json:"hung_processes"`
 	AverageProcessTime  time.Duration              `json:"average_process_time"`
@@ -222,6 +225,17 @@ func NewProcessMonitor(logger *zap.Logger, nonInteractive bool) *ProcessMonitor
 	return monitor
 }
 
+// 估算超时的下限与上限：静态估算与学习到的耗时模型得到的超时都限制在此范围内
+const (
+	MinEstimatedTimeout = 30 * time.Second
+	MaxEstimatedTimeout = 2 * time.Hour
+)
+
+// ClampTimeout 将超时限制在估算超时的上下限之间，供不经过估算器使用耗时模型的调用方
+func ClampTimeout(timeout time.Duration) time.Duration {
+	return min(max(timeout, MinEstimatedTimeout), MaxEstimatedTimeout)
+}
+
 // NewTimeoutEstimator 创建超时估算器
 func NewTimeoutEstimator(logger *zap.Logger) *TimeoutEstimator {
 	return &TimeoutEstimator{
@@ -251,8 +265,8 @@ func NewTimeoutEstimator(logger *zap.Logger) *TimeoutEstimator {
 		},
 		historicalData:  make([]ProcessingRecord, 0),
 		adaptiveEnabled: true,
-		minTimeout:      30 * time.Second, // 最小30秒
-		maxTimeout:      2 * time.Hour,    // 最大2小时
+		minTimeout:      MinEstimatedTimeout,
+		maxTimeout:      MaxEstimatedTimeout,
 	}
 }
 
@@ -320,7 +334,7 @@ func (pm *ProcessMonitor) MonitorCommand(ctx context.Context, cmd *exec.Cmd, pro
 	if process, exists := pm.processes[processID]; exists {
 		if err != nil {
 			process.Status = StatusFailed
-			errmsg := fmt.Sprintf("error: %v, stderr: %s", err, stderr.String())
+			errMsg := fmt.Sprintf("error: %v, stderr: %s", err, stderr.String())
 			process.ErrorMessage = errMsg
 		} else {
 			process.Status = StatusCompleted
@@ -538,7 +552,12 @@ func (pm *ProcessMonitor) terminateProcess(process *MonitoredProcess, force bool
 }
 
 // EstimateTimeout 估算超时时间 - README要求的动态时限估算
+// 上下文带有编码器与像素数、且该编码器已有足够样本时使用学习到的耗时模型，否则按文件大小、复杂度与格式静态估算
 func (te *TimeoutEstimator) EstimateTimeout(ctx *ProcessContext) time.Duration {
+	if timeout, ok := te.learnedTimeout(ctx); ok {
+		return timeout
+	}
+
 	// 基于文件大小确定基础超时
 	sizeCategory := te.categorizeFileSize(ctx.FileSize)
 	baseTimeout := te.baseSizeTimeout[sizeCategory]
@@ -553,21 +572,7 @@ func (te *TimeoutEstimator) EstimateTimeout(ctx *ProcessContext) time.Duration {
 	}
 
 	// 计算估算时间
-	estimatedTimeout := time.Duration(float64(baseTimeout) * complexityMultiplier * formatMultiplier)
-
-	// 应用自适应学习（如果启用）
-	if te.adaptiveEnabled {
-		adaptiveMultiplier := te.getAdaptiveMultiplier(ctx)
-		estimatedTimeout = time.Duration(float64(estimatedTimeout) * adaptiveMultiplier)
-	}
-
-	// 限制在合理范围内
-	if estimatedTimeout < te.minTimeout {
-		estimatedTimeout = te.minTimeout
-	}
-	if estimatedTimeout > te.maxTimeout {
-		estimatedTimeout = te.maxTimeout
-	}
+	estimatedTimeout := te.clampTimeout(time.Duration(float64(baseTimeout) * complexityMultiplier * formatMultiplier))
 
 	te.logger.Debug("估算处理超时时间",
 		zap.String("operation", ctx.Operation),
@@ -581,6 +586,117 @@ func (te *TimeoutEstimator) EstimateTimeout(ctx *ProcessContext) time.Duration {
 	return estimatedTimeout
 }
 
+// EstimateDuration 按学习到的耗时模型估计处理时间，模型不可用时返回false
+func (te *TimeoutEstimator) EstimateDuration(ctx *ProcessContext) (time.Duration, bool) {
+	if te.model == nil || !te.adaptiveEnabled {
+		return 0, false
+	}
+	prediction, ok := te.model.Predict(sampleFromContext(ctx, 0))
+	if !ok {
+		return 0, false
+	}
+	return prediction.Expected, true
+}
+
+// learnedTimeout 学习到的超时：预测的高分位耗时加上余量，仍受最小、最大超时限制
+func (te *TimeoutEstimator) learnedTimeout(ctx *ProcessContext) (time.Duration, bool) {
+	if te.model == nil || !te.adaptiveEnabled || ctx.Encoder == "" || ctx.Pixels <= 0 {
+		return 0, false
+	}
+	prediction, ok := te.model.Predict(sampleFromContext(ctx, 0))
+	if !ok {
+		return 0, false
+	}
+
+	timeout := te.clampTimeout(prediction.Timeout())
+	te.logger.Debug("按耗时模型估算超时时间",
+		zap.String("operation", ctx.Operation),
+		zap.String("encoder", ctx.Encoder),
+		zap.Int64("pixels", ctx.Pixels),
+		zap.Int("frames", ctx.Frames),
+		zap.Int("samples", prediction.Samples),
+		zap.Duration("expected", prediction.Expected),
+		zap.Duration("estimated_timeout", timeout))
+	return timeout, true
+}
+
+// clampTimeout 限制在合理范围内
+func (te *TimeoutEstimator) clampTimeout(timeout time.Duration) time.Duration {
+	if timeout < te.minTimeout {
+		return te.minTimeout
+	}
+	if timeout > te.maxTimeout {
+		return te.maxTimeout
+	}
+	return timeout
+}
+
+// categorizeFileSize 文件大小分档：<10MB、10MB-100MB、100MB-1GB、>1GB
+func (te *TimeoutEstimator) categorizeFileSize(size int64) string {
+	switch {
+	case size < 10<<20:
+		return "small"
+	case size < 100<<20:
+		return "medium"
+	case size < 1<<30:
+		return "large"
+	default:
+		return "huge"
+	}
+}
+
+// SetTimingModel 设置学习到的耗时模型：超时估算使用它，成功完成的进程记录为样本
+func (pm *ProcessMonitor) SetTimingModel(model *TimingModel) {
+	pm.estimator.model = model
+}
+
+// removeProcess 移除已结束的进程
+func (pm *ProcessMonitor) removeProcess(processID string) {
+	pm.processMutex.Lock()
+	defer pm.processMutex.Unlock()
+	delete(pm.processes, processID)
+}
+
+// maxHistoricalRecords 内存中保留的处理记录数
+const maxHistoricalRecords = 1000
+
+// recordProcessingData 记录处理数据：成功完成且带有编码器信息的进程作为耗时样本交给模型；
+// 被终止或失败的进程耗时不完整，不作为样本
+func (pm *ProcessMonitor) recordProcessingData(process *MonitoredProcess, successful bool) {
+	te := pm.estimator
+	duration := time.Since(process.StartTime)
+	ctx := process.Context
+
+	te.historicalData = append(te.historicalData, ProcessingRecord{
+		FileSize:        ctx.FileSize,
+		Format:          ctx.FileFormat,
+		Operation:       ctx.Operation,
+		ComplexityLevel: ctx.ComplexityLevel,
+		ActualDuration:  duration,
+		Successful:      successful,
+		Timestamp:       time.Now(),
+	})
+	if len(te.historicalData) > maxHistoricalRecords {
+		te.historicalData = te.historicalData[len(te.historicalData)-maxHistoricalRecords:]
+	}
+
+	if successful && te.model != nil && ctx.Encoder != "" && ctx.Pixels > 0 {
+		te.model.Observe(sampleFromContext(ctx, duration))
+	}
+}
+
+// sampleFromContext 由进程上下文构造耗时样本
+func sampleFromContext(ctx *ProcessContext, duration time.Duration) TimingSample {
+	return TimingSample{
+		Format:   ctx.FileFormat,
+		Pixels:   ctx.Pixels,
+		Frames:   ctx.Frames,
+		Encoder:  ctx.Encoder,
+		Effort:   ctx.Effort,
+		Duration: duration,
+	}
+}
+
 // 辅助方法
 func (pm *ProcessMonitor) generateProcessID() string {
 	return fmt.Sprintf("proc_%d_%d", time.Now().UnixNano(), len(pm.processes))
@@ -754,7 +870,7 @@ func (pm *ProcessMonitor) askUserForAction(process *MonitoredProcess) UserChoice
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(strings.ToLower(input))
 
-		sswitch input {
+		switch input {
 		case "w":
 			return UserChoiceWait
 		case "t":
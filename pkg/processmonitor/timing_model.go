@@ -0,0 +1,338 @@
+package processmonitor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// TimingBucket 状态数据库中保存耗时样本的bucket，其下每个编码器一个子bucket，键为递增序号
+const TimingBucket = "timing"
+
+const (
+	// maxSamplesPerEncoder 每个编码器保留的样本数，超出时淘汰最早的样本
+	maxSamplesPerEncoder = 512
+	// minSamplesForFit 拟合所需的最少样本数
+	minSamplesForFit = 8
+	// ridgeLambda 岭回归正则系数：样本集中在少数尺寸或effort时保持系数稳定
+	ridgeLambda = 1e-3
+	// minResidualSigma 残差标准差下限（对数空间），样本过于一致时仍保留余量
+	minResidualSigma = 0.15
+	// upperSigmas 高分位耗时取预测均值以上的标准差倍数
+	upperSigmas = 3.0
+)
+
+// TimingSample 一次成功处理的耗时样本
+type TimingSample struct {
+	Format    string        `json:"format"`
+	Pixels    int64         `json:"pixels"`
+	Frames    int           `json:"frames"`
+	Encoder   string        `json:"encoder"`
+	Effort    int           `json:"effort"`
+	Duration  time.Duration `json:"duration"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// Prediction 耗时预测：Expected为期望耗时，Upper为高分位耗时（用于超时）
+type Prediction struct {
+	Expected time.Duration
+	Upper    time.Duration
+	Samples  int
+}
+
+// 超时相对高分位耗时的倍数与固定余量（系统负载波动、冷缓存）
+const (
+	timeoutFactor = 2.0
+	timeoutSlack  = 10 * time.Second
+)
+
+// Timeout 按预测得到的处理超时：高分位耗时的倍数加上固定余量
+func (p Prediction) Timeout() time.Duration {
+	return time.Duration(float64(p.Upper)*timeoutFactor) + timeoutSlack
+}
+
+// timingFit 单个编码器的回归结果：ln(秒) = b0 + b1·ln(像素×帧数) + b2·effort
+type timingFit struct {
+	coef  [3]float64
+	sigma float64
+	count int
+}
+
+// TimingModel 按编码器拟合的处理耗时模型，样本持久化在调用方的状态数据库中，跨运行累积
+type TimingModel struct {
+	logger *zap.Logger
+
+	mutex   sync.Mutex
+	db      *bbolt.DB                 // 样本写入的状态数据库，未附加时只在内存中累积
+	samples map[string][]TimingSample // 按编码器分组，按时间先后排列
+	fits    map[string]*timingFit     // 拟合结果缓存，样本变化时失效
+}
+
+// NewTimingModel 创建耗时模型，附加状态数据库之前只在内存中累积样本
+func NewTimingModel(logger *zap.Logger) *TimingModel {
+	return &TimingModel{
+		logger:  logger,
+		samples: make(map[string][]TimingSample),
+		fits:    make(map[string]*timingFit),
+	}
+}
+
+// Attach 附加状态数据库（由调用方打开与关闭）：以其中保存的样本替换内存中的样本，之后的样本写入该数据库
+func (m *TimingModel) Attach(db *bbolt.DB) error {
+	samples := make(map[string][]TimingSample)
+	err := db.Update(func(tx *bbolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(TimingBucket))
+		if err != nil {
+			return err
+		}
+		return root.ForEachBucket(func(encoder []byte) error {
+			return root.Bucket(encoder).ForEach(func(_, value []byte) error {
+				var sample TimingSample
+				if json.Unmarshal(value, &sample) == nil && sample.valid() {
+					samples[string(encoder)] = append(samples[string(encoder)], sample)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.db = db
+	m.samples = samples
+	m.fits = make(map[string]*timingFit)
+
+	m.logger.Debug("已载入处理耗时样本",
+		zap.String("path", db.Path()),
+		zap.Int("encoders", len(samples)))
+	return nil
+}
+
+// Detach 停止写入状态数据库，须在调用方关闭数据库之前调用；已载入的样本仍用于预测
+func (m *TimingModel) Detach() {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	m.db = nil
+	m.mutex.Unlock()
+}
+
+// valid 样本是否可用于拟合
+func (s TimingSample) valid() bool {
+	return s.Encoder != "" && s.Pixels > 0 && s.Duration > 0
+}
+
+// workload 样本的工作量：像素数×帧数
+func (s TimingSample) workload() float64 {
+	frames := s.Frames
+	if frames < 1 {
+		frames = 1
+	}
+	return float64(s.Pixels) * float64(frames)
+}
+
+// Observe 记录一次成功处理的耗时，持久化失败只记录日志
+func (m *TimingModel) Observe(sample TimingSample) {
+	if m == nil || !sample.valid() {
+		return
+	}
+	if sample.Timestamp.IsZero() {
+		sample.Timestamp = time.Now()
+	}
+
+	m.mutex.Lock()
+	samples := append(m.samples[sample.Encoder], sample)
+	if len(samples) > maxSamplesPerEncoder {
+		samples = samples[len(samples)-maxSamplesPerEncoder:]
+	}
+	m.samples[sample.Encoder] = samples
+	delete(m.fits, sample.Encoder)
+	db := m.db
+	m.mutex.Unlock()
+
+	if db == nil {
+		return
+	}
+	if err := persistTimingSample(db, sample); err != nil {
+		m.logger.Warn("保存处理耗时样本失败", zap.String("encoder", sample.Encoder), zap.Error(err))
+	}
+}
+
+// persistTimingSample 写入样本并淘汰超出上限的最早样本
+func persistTimingSample(db *bbolt.DB, sample TimingSample) error {
+	value, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(TimingBucket))
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists([]byte(sample.Encoder))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := bucket.Put(key, value); err != nil {
+			return err
+		}
+
+		// 键为递增序号：只保留最近的maxSamplesPerEncoder个序号
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && binary.BigEndian.Uint64(k)+maxSamplesPerEncoder <= seq; k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Predict 预测样本描述的处理耗时（忽略Duration）。Encoder为空时使用该格式样本最多的编码器；
+// 样本不足时返回false
+func (m *TimingModel) Predict(sample TimingSample) (Prediction, bool) {
+	if m == nil || sample.Pixels <= 0 {
+		return Prediction{}, false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	encoder := sample.Encoder
+	if encoder == "" {
+		encoder = m.encoderForFormat(sample.Format)
+	}
+	fit := m.fit(encoder)
+	if fit == nil {
+		return Prediction{}, false
+	}
+
+	mu := fit.coef[0] + fit.coef[1]*math.Log(sample.workload()) + fit.coef[2]*float64(sample.Effort)
+	return Prediction{
+		Expected: secondsToDuration(math.Exp(mu + fit.sigma*fit.sigma/2)),
+		Upper:    secondsToDuration(math.Exp(mu + upperSigmas*fit.sigma)),
+		Samples:  fit.count,
+	}, true
+}
+
+// encoderForFormat 返回该格式样本最多的编码器
+func (m *TimingModel) encoderForFormat(format string) string {
+	best, bestCount := "", 0
+	for encoder, samples := range m.samples {
+		count := 0
+		for _, s := range samples {
+			if s.Format == format {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = encoder, count
+		}
+	}
+	return best
+}
+
+// fit 拟合编码器的回归模型（调用方持有锁），样本不足时返回nil
+func (m *TimingModel) fit(encoder string) *timingFit {
+	if fit, ok := m.fits[encoder]; ok {
+		return fit
+	}
+	samples := m.samples[encoder]
+	if len(samples) < minSamplesForFit {
+		return nil
+	}
+
+	// 正规方程 (XᵀX + λI)β = Xᵀy，截距不参与正则
+	var xtx [3][3]float64
+	var xty [3]float64
+	rows := make([][3]float64, len(samples))
+	ys := make([]float64, len(samples))
+	for i, s := range samples {
+		rows[i] = [3]float64{1, math.Log(s.workload()), float64(s.Effort)}
+		ys[i] = math.Log(s.Duration.Seconds())
+		for a := 0; a < 3; a++ {
+			xty[a] += rows[i][a] * ys[i]
+			for b := 0; b < 3; b++ {
+				xtx[a][b] += rows[i][a] * rows[i][b]
+			}
+		}
+	}
+	for a := 1; a < 3; a++ {
+		xtx[a][a] += ridgeLambda * float64(len(samples))
+	}
+	coef, ok := solve3(xtx, xty)
+	if !ok {
+		return nil
+	}
+
+	var sse float64
+	for i := range samples {
+		residual := ys[i] - (coef[0] + coef[1]*rows[i][1] + coef[2]*rows[i][2])
+		sse += residual * residual
+	}
+	sigma := math.Sqrt(sse / float64(len(samples)-1))
+	if sigma < minResidualSigma {
+		sigma = minResidualSigma
+	}
+
+	fit := &timingFit{coef: coef, sigma: sigma, count: len(samples)}
+	m.fits[encoder] = fit
+	return fit
+}
+
+// solve3 高斯消元求解3×3线性方程组，矩阵奇异时返回false
+func solve3(a [3][3]float64, b [3]float64) ([3]float64, bool) {
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for row := col + 1; row < 3; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return [3]float64{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < 3; row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < 3; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+
+	var x [3]float64
+	for row := 2; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < 3; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, true
+}
+
+// secondsToDuration 秒数转换为时长，避免溢出
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
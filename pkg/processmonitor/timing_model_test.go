@@ -0,0 +1,184 @@
+package processmonitor
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// lawDuration 测试用的耗时规律：秒 = 2e-7 × 像素×帧数 × e^(0.15·effort)
+func lawDuration(pixels int64, frames, effort int) time.Duration {
+	seconds := 2e-7 * float64(pixels) * float64(frames) * math.Exp(0.15*float64(effort))
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// lawSamples 覆盖多种尺寸、帧数与effort的无噪声样本
+func lawSamples(encoder string) []TimingSample {
+	var samples []TimingSample
+	for _, pixels := range []int64{64 * 64, 640 * 480, 1920 * 1080, 6000 * 4000} {
+		for _, frames := range []int{1, 12} {
+			for _, effort := range []int{3, 7, 9} {
+				samples = append(samples, TimingSample{
+					Format:   "png",
+					Pixels:   pixels,
+					Frames:   frames,
+					Encoder:  encoder,
+					Effort:   effort,
+					Duration: lawDuration(pixels, frames, effort),
+				})
+			}
+		}
+	}
+	return samples
+}
+
+// withinRatio 两个时长之比是否在[1/ratio, ratio]内
+func withinRatio(got, want time.Duration, ratio float64) bool {
+	r := float64(got) / float64(want)
+	return r >= 1/ratio && r <= ratio
+}
+
+func TestTimingModelFit(t *testing.T) {
+	model := NewTimingModel(zap.NewNop())
+	for _, sample := range lawSamples("convert.jxl") {
+		model.Observe(sample)
+	}
+
+	model.mutex.Lock()
+	fit := model.fit("convert.jxl")
+	model.mutex.Unlock()
+	if fit == nil {
+		t.Fatal("样本足够时应能拟合")
+	}
+	want := [3]float64{math.Log(2e-7), 1, 0.15}
+	for i := range want {
+		if math.Abs(fit.coef[i]-want[i]) > 0.01 {
+			t.Errorf("系数b%d = %.4f, 期望 %.4f", i, fit.coef[i], want[i])
+		}
+	}
+	if fit.sigma != minResidualSigma {
+		t.Errorf("无噪声样本的残差标准差应取下限 %.2f，实际为 %.4f", minResidualSigma, fit.sigma)
+	}
+}
+
+func TestTimingModelPredict(t *testing.T) {
+	model := NewTimingModel(zap.NewNop())
+	for _, sample := range lawSamples("convert.jxl") {
+		model.Observe(sample)
+	}
+	for _, sample := range lawSamples("convert.avif")[:minSamplesForFit-1] {
+		model.Observe(sample)
+	}
+
+	tests := []struct {
+		name   string
+		sample TimingSample
+		want   time.Duration // 为0时期望无法预测
+	}{
+		{"样本范围内", TimingSample{Encoder: "convert.jxl", Pixels: 1920 * 1080, Frames: 1, Effort: 7}, lawDuration(1920*1080, 1, 7)},
+		{"未见过的尺寸与effort", TimingSample{Encoder: "convert.jxl", Pixels: 3000 * 2000, Frames: 4, Effort: 5}, lawDuration(3000*2000, 4, 5)},
+		{"按格式选择编码器", TimingSample{Format: "png", Pixels: 640 * 480, Frames: 1}, lawDuration(640*480, 1, 0)},
+		{"样本不足", TimingSample{Encoder: "convert.avif", Pixels: 640 * 480, Frames: 1, Effort: 6}, 0},
+		{"未知编码器", TimingSample{Encoder: "convert.webp", Pixels: 640 * 480, Frames: 1}, 0},
+		{"没有像素数", TimingSample{Encoder: "convert.jxl", Frames: 1, Effort: 7}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prediction, ok := model.Predict(tt.sample)
+			if tt.want == 0 {
+				if ok {
+					t.Fatalf("不应给出预测: %+v", prediction)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("应给出预测")
+			}
+			// 期望耗时含对数正态的方差修正 e^(σ²/2)
+			if !withinRatio(prediction.Expected, tt.want, 1.05) {
+				t.Errorf("Expected = %v, 期望约 %v", prediction.Expected, tt.want)
+			}
+			if !withinRatio(prediction.Upper, time.Duration(float64(tt.want)*math.Exp(upperSigmas*minResidualSigma)), 1.05) {
+				t.Errorf("Upper = %v, 期望约 %v的e^(3σ)倍", prediction.Upper, tt.want)
+			}
+			if prediction.Timeout() != time.Duration(float64(prediction.Upper)*timeoutFactor)+timeoutSlack {
+				t.Errorf("Timeout = %v", prediction.Timeout())
+			}
+		})
+	}
+}
+
+func TestTimingModelSingleEffort(t *testing.T) {
+	// effort列为常数时XᵀX奇异，岭回归仍应给出稳定解
+	model := NewTimingModel(zap.NewNop())
+	for _, sample := range lawSamples("convert.avif") {
+		if sample.Effort == 7 {
+			model.Observe(sample)
+		}
+	}
+	prediction, ok := model.Predict(TimingSample{Encoder: "convert.avif", Pixels: 1920 * 1080, Frames: 1, Effort: 7})
+	if !ok {
+		t.Fatal("单一effort的样本应能拟合")
+	}
+	if want := lawDuration(1920*1080, 1, 7); !withinRatio(prediction.Expected, want, 1.05) {
+		t.Errorf("Expected = %v, 期望约 %v", prediction.Expected, want)
+	}
+}
+
+func TestTimingModelAttach(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "state.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	model := NewTimingModel(zap.NewNop())
+	if err := model.Attach(db); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	for i := 0; i < maxSamplesPerEncoder+5; i++ {
+		model.Observe(TimingSample{Encoder: "convert.jxl", Pixels: int64(i + 1), Duration: time.Second})
+	}
+	model.Observe(TimingSample{Encoder: "convert.avif", Pixels: 100, Duration: time.Second})
+	model.Detach()
+	model.Observe(TimingSample{Encoder: "convert.avif", Pixels: 200, Duration: time.Second})
+
+	reloaded := NewTimingModel(zap.NewNop())
+	if err := reloaded.Attach(db); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	jxl := reloaded.samples["convert.jxl"]
+	if len(jxl) != maxSamplesPerEncoder {
+		t.Fatalf("每个编码器应保留 %d 个样本，实际为 %d", maxSamplesPerEncoder, len(jxl))
+	}
+	if jxl[0].Pixels != 6 || jxl[len(jxl)-1].Pixels != maxSamplesPerEncoder+5 {
+		t.Errorf("应淘汰最早的样本，保留的样本像素数为 %d..%d", jxl[0].Pixels, jxl[len(jxl)-1].Pixels)
+	}
+	if avif := reloaded.samples["convert.avif"]; len(avif) != 1 || avif[0].Pixels != 100 {
+		t.Errorf("分离数据库后的样本不应写入，实际保存 %+v", avif)
+	}
+}
+
+func TestClampTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"低于下限", time.Second, MinEstimatedTimeout},
+		{"范围之内", 5 * time.Minute, 5 * time.Minute},
+		{"高于上限", 100 * time.Hour, MaxEstimatedTimeout},
+		{"预测溢出", time.Duration(math.MaxInt64), MaxEstimatedTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClampTimeout(tt.timeout); got != tt.want {
+				t.Errorf("ClampTimeout(%v) = %v, 期望 %v", tt.timeout, got, tt.want)
+			}
+		})
+	}
+}
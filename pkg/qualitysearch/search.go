@@ -0,0 +1,147 @@
+package qualitysearch
+
+import (
+	"errors"
+	"math"
+)
+
+// Goal 搜索目标类型
+type Goal string
+
+const (
+	// GoalPerceptual 感知质量目标：在达到感知门限的设置中取最低质量（体积最小）
+	GoalPerceptual Goal = "perceptual"
+	// GoalSizeReduction 体积目标：在达到体积减小比例的设置中取最高质量（视觉损失最小）
+	GoalSizeReduction Goal = "size"
+)
+
+// ParseGoal 解析目标名称，未知名称返回false
+func ParseGoal(name string) (Goal, bool) {
+	switch Goal(name) {
+	case GoalPerceptual, GoalSizeReduction:
+		return Goal(name), true
+	default:
+		return "", false
+	}
+}
+
+// Candidate 单次编码候选
+type Candidate struct {
+	Setting int    // 统一质量刻度（1-100），由编码器映射为distance/quantizer/CRF
+	Path    string // 候选输出路径
+	Size    int64  // 候选体积
+}
+
+// Encoder 按给定设置编码一次并返回候选
+type Encoder func(setting int) (Candidate, error)
+
+// Options 搜索参数
+type Options struct {
+	Min, Max int // 质量刻度搜索区间（闭区间）
+	MaxSteps int // 最大编码次数，<=0时不限制（二分本身最多log2(Max-Min)+1次）
+
+	// PreferLower 为true时寻找满足Meets的最低设置，否则寻找满足Meets的最高设置
+	// Meets必须对设置单调：PreferLower时高设置满足则更高也满足，反之亦然
+	PreferLower bool
+	Meets       func(Candidate) bool
+
+	// Discard 清理未被采用的候选（可选）
+	Discard func(Candidate)
+}
+
+// Result 搜索结果
+type Result struct {
+	Best     *Candidate // 满足目标的最便宜候选，未找到时为nil
+	Attempts int        // 实际编码次数
+}
+
+// ErrInvalidRange 搜索区间无效
+var ErrInvalidRange = errors.New("invalid quality search range")
+
+// Run 在质量刻度上二分搜索满足目标的最便宜设置
+// 编码失败的设置视为不满足目标，搜索继续向更保守的一侧收缩
+func Run(encode Encoder, opts Options) (Result, error) {
+	if opts.Min > opts.Max || opts.Meets == nil {
+		return Result{}, ErrInvalidRange
+	}
+
+	var result Result
+	lo, hi := opts.Min, opts.Max
+
+	for lo <= hi {
+		if opts.MaxSteps > 0 && result.Attempts >= opts.MaxSteps {
+			break
+		}
+
+		mid := lo + (hi-lo)/2
+		candidate, err := encode(mid)
+		result.Attempts++
+
+		meets := err == nil && opts.Meets(candidate)
+		if meets {
+			if result.Best != nil {
+				discard(opts, *result.Best)
+			}
+			c := candidate
+			result.Best = &c
+		} else if err == nil {
+			discard(opts, candidate)
+		}
+
+		// 满足目标时向更便宜的一侧继续搜索，否则向更保守的一侧收缩
+		if meets == opts.PreferLower {
+			hi = mid - 1
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	return result, nil
+}
+
+func discard(opts Options, c Candidate) {
+	if opts.Discard != nil && c.Path != "" {
+		opts.Discard(c)
+	}
+}
+
+// SizeReductionMeets 构造体积目标判定：相对原始体积减小至少minReduction（0-1）
+func SizeReductionMeets(originalSize int64, minReduction float64) func(Candidate) bool {
+	return func(c Candidate) bool {
+		if originalSize <= 0 || c.Size <= 0 {
+			return false
+		}
+		return float64(originalSize-c.Size)/float64(originalSize) >= minReduction
+	}
+}
+
+// JXLDistance 将质量刻度映射为cjxl的--distance（与cjxl -q的换算一致，100为数学无损）
+func JXLDistance(quality int) float64 {
+	switch {
+	case quality >= 100:
+		return 0
+	case quality >= 30:
+		return 0.1 + float64(100-quality)*0.09
+	case quality > 0:
+		q := float64(quality)
+		return 53.0/3000.0*q*q - 23.0/20.0*q + 25.0
+	default:
+		return 25
+	}
+}
+
+// CRF 将质量刻度映射为FFmpeg CRF（0为最高质量，maxCRF为最低质量）
+func CRF(quality, maxCRF int) int {
+	q := clamp(quality, 0, 100)
+	return int(math.Round(float64(100-q) * float64(maxCRF) / 100))
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
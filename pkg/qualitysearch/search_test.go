@@ -0,0 +1,220 @@
+package qualitysearch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// fakeScorer 模拟评分：设置越高评分越高，记录每次编码的设置
+type fakeScorer struct {
+	threshold int          // 评分达标的最低设置
+	fail      map[int]bool // 编码失败的设置
+	tried     []int
+}
+
+// encode 按设置产出候选，体积随设置单调增大
+func (f *fakeScorer) encode(setting int) (Candidate, error) {
+	f.tried = append(f.tried, setting)
+	if f.fail[setting] {
+		return Candidate{}, errors.New("编码失败")
+	}
+	return Candidate{Setting: setting, Path: fmt.Sprintf("q%d", setting), Size: int64(setting) * 100}, nil
+}
+
+// meets 感知目标：设置达到阈值即达标
+func (f *fakeScorer) meets(c Candidate) bool {
+	return c.Setting >= f.threshold
+}
+
+func TestRunPerceptual(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		min, max  int
+		maxSteps  int
+		fail      map[int]bool
+		want      int // 0表示没有达标候选
+		attempts  int
+	}{
+		{"收敛到最低达标设置", 63, 1, 100, 0, nil, 63, 6},
+		{"阈值在下界", 1, 1, 100, 0, nil, 1, 6},
+		{"阈值在上界", 100, 1, 100, 0, nil, 100, 7},
+		{"都未达标", 101, 1, 100, 0, nil, 0, 7},
+		{"单点区间", 50, 50, 50, 0, nil, 50, 1},
+		{"步数限制时返回已知最优", 63, 1, 100, 3, nil, 75, 3},
+		{"编码失败视为未达标", 63, 1, 100, 0, map[int]bool{50: true}, 63, 6},
+		{"最低达标设置编码失败时取下一个", 63, 1, 100, 0, map[int]bool{63: true}, 64, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeScorer{threshold: tt.threshold, fail: tt.fail}
+			var discarded []int
+			result, err := Run(f.encode, Options{
+				Min:         tt.min,
+				Max:         tt.max,
+				MaxSteps:    tt.maxSteps,
+				PreferLower: true,
+				Meets:       f.meets,
+				Discard:     func(c Candidate) { discarded = append(discarded, c.Setting) },
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			got := 0
+			if result.Best != nil {
+				got = result.Best.Setting
+			}
+			if got != tt.want {
+				t.Errorf("Best = %d, 期望 %d（尝试 %v）", got, tt.want, f.tried)
+			}
+			if result.Attempts != tt.attempts || len(f.tried) != tt.attempts {
+				t.Errorf("Attempts = %d, 期望 %d", result.Attempts, tt.attempts)
+			}
+			for _, setting := range f.tried {
+				if setting < tt.min || setting > tt.max {
+					t.Errorf("尝试了区间外的设置 %d", setting)
+				}
+			}
+			// 编码成功的候选除最优外都应被清理
+			succeeded := 0
+			for _, setting := range f.tried {
+				if !tt.fail[setting] {
+					succeeded++
+				}
+			}
+			if want := succeeded - boolInt(result.Best != nil); len(discarded) != want {
+				t.Errorf("清理了 %d 个候选 %v, 期望 %d 个", len(discarded), discarded, want)
+			}
+			for _, setting := range discarded {
+				if result.Best != nil && setting == result.Best.Setting {
+					t.Error("最优候选被清理")
+				}
+			}
+		})
+	}
+}
+
+func TestRunSizeReduction(t *testing.T) {
+	// 体积目标：设置越高体积越大，寻找仍满足体积减小比例的最高设置
+	const original = 10000
+	meets := SizeReductionMeets(original, 0.30)
+	tried := 0
+	result, err := Run(func(setting int) (Candidate, error) {
+		tried++
+		return Candidate{Setting: setting, Size: int64(setting) * 100}, nil
+	}, Options{Min: 1, Max: 100, Meets: meets})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Best == nil || result.Best.Setting != 70 {
+		t.Errorf("Best = %+v, 期望设置 70", result.Best)
+	}
+	if result.Attempts != tried || tried > 7 {
+		t.Errorf("Attempts = %d, 编码 %d 次，期望不超过 7", result.Attempts, tried)
+	}
+}
+
+func TestRunInvalidRange(t *testing.T) {
+	encode := func(int) (Candidate, error) { return Candidate{}, nil }
+	meets := func(Candidate) bool { return true }
+	if _, err := Run(encode, Options{Min: 10, Max: 1, Meets: meets}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("Min > Max 时 err = %v, 期望 ErrInvalidRange", err)
+	}
+	if _, err := Run(encode, Options{Min: 1, Max: 10}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("缺少Meets时 err = %v, 期望 ErrInvalidRange", err)
+	}
+}
+
+func TestSizeReductionMeets(t *testing.T) {
+	tests := []struct {
+		name     string
+		original int64
+		size     int64
+		want     bool
+	}{
+		{"刚好达到比例", 1000, 900, true},
+		{"未达到比例", 1000, 901, false},
+		{"比原文件大", 1000, 1200, false},
+		{"原始体积未知", 0, 100, false},
+		{"候选体积未知", 1000, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SizeReductionMeets(tt.original, 0.10)(Candidate{Size: tt.size}); got != tt.want {
+				t.Errorf("SizeReductionMeets = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJXLDistance(t *testing.T) {
+	tests := []struct {
+		quality int
+		want    float64
+	}{
+		{100, 0},
+		{120, 0},
+		{99, 0.19},
+		{90, 1.0},
+		{30, 6.4},
+		{29, 53.0/3000.0*29*29 - 23.0/20.0*29 + 25},
+		{1, 53.0/3000.0 - 23.0/20.0 + 25},
+		{0, 25},
+		{-5, 25},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.quality), func(t *testing.T) {
+			if got := JXLDistance(tt.quality); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("JXLDistance(%d) = %v, 期望 %v", tt.quality, got, tt.want)
+			}
+		})
+	}
+
+	// 质量越高距离越小
+	for q := 1; q < 100; q++ {
+		if JXLDistance(q+1) > JXLDistance(q) {
+			t.Errorf("JXLDistance(%d) = %v 大于 JXLDistance(%d) = %v", q+1, JXLDistance(q+1), q, JXLDistance(q))
+		}
+	}
+}
+
+func TestCRF(t *testing.T) {
+	tests := []struct {
+		quality, maxCRF, want int
+	}{
+		{100, 63, 0},
+		{0, 63, 63},
+		{50, 63, 32},
+		{75, 51, 13},
+		{150, 63, 0},
+		{-10, 63, 63},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d_%d", tt.quality, tt.maxCRF), func(t *testing.T) {
+			if got := CRF(tt.quality, tt.maxCRF); got != tt.want {
+				t.Errorf("CRF(%d, %d) = %d, 期望 %d", tt.quality, tt.maxCRF, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGoal(t *testing.T) {
+	for _, name := range []string{"perceptual", "size"} {
+		if goal, ok := ParseGoal(name); !ok || string(goal) != name {
+			t.Errorf("ParseGoal(%q) = %q, %v", name, goal, ok)
+		}
+	}
+	if _, ok := ParseGoal("fastest"); ok {
+		t.Error("未知目标应返回false")
+	}
+}
+
+// boolInt 将布尔值转为0或1
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
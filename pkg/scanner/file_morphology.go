@@ -2,15 +2,14 @@ package scanner
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"pixly/pkg/core/types"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -21,7 +20,7 @@ import (
 //   - 超越文件扩展名，基于真实内容进行分析
 //   - 精确区分"静图"、"动图"、"视频"三种核心形态
 //   - 处理容器格式的多形态问题（GIF、APNG、WebP、HEIC等）
-//   - 使用ffprobe作为核心分析工具
+//   - 图像解析容器头部，视频与无法解析的格式使用ffprobe
 //   - 识别特殊类型（Live Photo、空间图片等）
 //
 // 性能特点：
@@ -37,6 +36,7 @@ type FileMorphologyClassifier struct {
 	cacheEnabled   bool                         // 启用分析结果缓存
 	cache          map[string]*MorphologyResult // 内存缓存
 	timeoutSeconds int                          // ffprobe超时时间（秒）
+	prober         *mediaprobe.Prober           // 共享探测缓存，同一文件只探测一次
 }
 
 // MorphologyResult 形态分析结果
@@ -54,44 +54,27 @@ type MorphologyResult struct {
 	IsSpatial      bool                   `json:"is_spatial"`      // 是否为空间图片/视频
 	HasAudio       bool                   `json:"has_audio"`       // 是否包含音轨
 	Confidence     float64                `json:"confidence"`      // 分类置信度
-	AnalysisMethod string                 `json:"analysis_method"` // 分析方法："extension", "ffprobe", "exiftool"
+	AnalysisMethod string                 `json:"analysis_method"` // 分析方法："extension", "header", "ffprobe", "exiftool"
 	AnalysisTime   time.Duration          `json:"analysis_time"`   // 分析耗时
 	Details        map[string]interface{} `json:"details"`         // 详细信息
 	Warnings       []string               `json:"warnings"`        // 警告信息
 }
 
-// FFProbeOutput ffprobe输出结构
-type FFProbeOutput struct {
-	Streams []FFProbeStream `json:"streams"`
-	Format  FFProbeFormat   `json:"format"`
-}
+// FFProbeOutput ffprobe输出结构，与共享探测结果使用同一模型
+type FFProbeOutput = mediaprobe.FFprobeData
 
 // FFProbeStream ffprobe流信息
-type FFProbeStream struct {
-	Index        int            `json:"index"`
-	CodecName    string         `json:"codec_name"`
-	CodecType    string         `json:"codec_type"`
-	Width        int            `json:"width"`
-	Height       int            `json:"height"`
-	Duration     string         `json:"duration"`
-	NbFrames     string         `json:"nb_frames"`
-	AvgFrameRate string         `json:"avg_frame_rate"`
-	RFrameRate   string         `json:"r_frame_rate"`
-	Disposition  map[string]int `json:"disposition"`
-}
+type FFProbeStream = mediaprobe.Stream
 
 // FFProbeFormat ffprobe格式信息
-type FFProbeFormat struct {
-	Filename   string            `json:"filename"`
-	FormatName string            `json:"format_name"`
-	Duration   string            `json:"duration"`
-	Size       string            `json:"size"`
-	BitRate    string            `json:"bit_rate"`
-	Tags       map[string]string `json:"tags"`
-}
+type FFProbeFormat = mediaprobe.Format
 
-// NewFileMorphologyClassifier 创建文件形态分类器
-func NewFileMorphologyClassifier(logger *zap.Logger, ffprobePath, exiftoolPath string) *FileMorphologyClassifier {
+// NewFileMorphologyClassifier 创建文件形态分类器；prober为共享探测缓存（如转换器的缓存），
+// 使扫描与转换阶段复用同一份探测结果，为nil时创建独立的缓存
+func NewFileMorphologyClassifier(logger *zap.Logger, ffprobePath, exiftoolPath string, prober *mediaprobe.Prober) *FileMorphologyClassifier {
+	if prober == nil {
+		prober = mediaprobe.NewProber(ffprobePath, exiftoolPath)
+	}
 	return &FileMorphologyClassifier{
 		logger:         logger,
 		ffprobePath:    ffprobePath,
@@ -100,6 +83,7 @@ func NewFileMorphologyClassifier(logger *zap.Logger, ffprobePath, exiftoolPath s
 		cacheEnabled:   true,
 		cache:          make(map[string]*MorphologyResult),
 		timeoutSeconds: 30, // 30秒超时
+		prober:         prober,
 	}
 }
 
@@ -125,8 +109,11 @@ func (fmc *FileMorphologyClassifier) ClassifyFile(ctx context.Context, filePath
 	// 阶段1：基于扩展名的快速预判
 	fmc.performExtensionBasedClassification(result)
 
-	// 阶段2：ffprobe深度分析（README核心要求）
-	if !fmc.fastMode && fmc.ffprobePath != "" {
+	// 阶段2：图像容器头部解析，成功时无需启动ffprobe；视频与无法解析的格式使用ffprobe深度分析（README核心要求）
+	if fmc.performHeaderAnalysis(result) {
+		result.AnalysisMethod = "header"
+		result.Confidence = 0.95
+	} else if !fmc.fastMode && fmc.ffprobePath != "" {
 		if err := fmc.performFFProbeAnalysis(ctx, result); err != nil {
 			fmc.logger.Warn("ffprobe分析失败，使用扩展名结果",
 				zap.String("file", filepath.Base(filePath)),
@@ -242,35 +229,44 @@ func (fmc *FileMorphologyClassifier) performExtensionBasedClassification(result
 	}
 }
 
+// performHeaderAnalysis 解析图像容器头部获取帧数与尺寸，格式无法解析时返回false
+func (fmc *FileMorphologyClassifier) performHeaderAnalysis(result *MorphologyResult) bool {
+	info, err := fmc.prober.Get(result.FilePath).Header()
+	if err != nil {
+		return false
+	}
+
+	result.TrueFormat = info.Format
+	result.CodecName = info.Codec
+	result.Width = info.Width
+	result.Height = info.Height
+	result.FrameCount = info.FrameCount
+	result.IsAnimated = info.Animated
+	if info.Animated {
+		result.MediaType = types.MediaTypeAnimated
+	} else {
+		result.MediaType = types.MediaTypeImage
+	}
+
+	result.Details["header_format"] = info.Format
+	result.Details["has_alpha"] = info.HasAlpha
+	result.Details["bit_depth"] = info.BitDepth
+	return true
+}
+
 // performFFProbeAnalysis 执行ffprobe深度分析 - README核心功能
 func (fmc *FileMorphologyClassifier) performFFProbeAnalysis(ctx context.Context, result *MorphologyResult) error {
 	// 创建带超时的上下文
 	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(fmc.timeoutSeconds)*time.Second)
 	defer cancel()
 
-	// 构建ffprobe命令
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		result.FilePath,
-	}
-
-	cmd := exec.CommandContext(timeoutCtx, fmc.ffprobePath, args...)
-	output, err := cmd.Output()
+	ffprobeOutput, err := fmc.prober.Get(result.FilePath).FFprobe(timeoutCtx)
 	if err != nil {
-		return fmt.Errorf("ffprobe执行失败: %w", err)
-	}
-
-	// 解析JSON输出
-	var ffprobeOutput FFProbeOutput
-	if err := json.Unmarshal(output, &ffprobeOutput); err != nil {
-		return fmt.Errorf("ffprobe输出解析失败: %w", err)
+		return err
 	}
 
 	// 分析流信息
-	return fmc.analyzeFFProbeStreams(result, &ffprobeOutput)
+	return fmc.analyzeFFProbeStreams(result, ffprobeOutput)
 }
 
 // analyzeFFProbeStreams 分析ffprobe流信息 - README要求的精确形态区分
@@ -366,34 +362,20 @@ func (fmc *FileMorphologyClassifier) detectSpecialTypes(ctx context.Context, res
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	args := []string{
-		"-json",
-		"-ContentIdentifier",
-		"-MediaGroupUUID",
-		"-SpatialOvercaptureIdentifier",
-		result.FilePath,
-	}
-
-	cmd := exec.CommandContext(timeoutCtx, fmc.exiftoolPath, args...)
-	output, err := cmd.Output()
+	exif, err := fmc.prober.Get(result.FilePath).Exif(timeoutCtx)
 	if err != nil {
 		result.Warnings = append(result.Warnings, fmt.Sprintf("exiftool检测失败: %v", err))
 		return
 	}
 
-	// 简化的特殊类型检测
-	outputStr := string(output)
-
 	// Live Photo检测
-	if strings.Contains(outputStr, "ContentIdentifier") ||
-		strings.Contains(outputStr, "MediaGroupUUID") {
+	if exif.String("ContentIdentifier") != "" || exif.String("MediaGroupUUID") != "" {
 		result.IsLivePhoto = true
 		result.Warnings = append(result.Warnings, "检测到Live Photo")
 	}
 
 	// 空间媒体检测
-	if strings.Contains(outputStr, "SpatialOvercaptureIdentifier") ||
-		strings.Contains(outputStr, "spatial") {
+	if exif.String("SpatialOvercaptureIdentifier") != "" || hasSpatialTag(exif) {
 		result.IsSpatial = true
 		result.Warnings = append(result.Warnings, "检测到空间媒体")
 	}
@@ -401,6 +383,19 @@ func (fmc *FileMorphologyClassifier) detectSpecialTypes(ctx context.Context, res
 	result.Details["exiftool_analysis"] = "completed"
 }
 
+// hasSpatialTag 检查元数据标签值是否声明空间媒体（忽略文件路径类标签）
+func hasSpatialTag(exif mediaprobe.Exif) bool {
+	for tag := range exif {
+		if tag == "SourceFile" || tag == "Directory" || strings.HasPrefix(tag, "File") {
+			continue
+		}
+		if strings.Contains(exif.String(tag), "spatial") {
+			return true
+		}
+	}
+	return false
+}
+
 // determineMediaType 最终形态确定 - README要求的智能跳过机制
 func (fmc *FileMorphologyClassifier) determineMediaType(result *MorphologyResult) {
 	// README要求的智能跳过规则
@@ -423,7 +418,7 @@ func (fmc *FileMorphologyClassifier) determineMediaType(result *MorphologyResult
 	}
 
 	// 提升置信度（经过完整分析）
-	if result.AnalysisMethod == "ffprobe" {
+	if result.AnalysisMethod == "ffprobe" || result.AnalysisMethod == "header" {
 		result.Confidence = 0.95
 	}
 }
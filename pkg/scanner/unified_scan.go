@@ -3,15 +3,18 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
-	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"pixly/config"
+	"pixly/pkg/contentcache"
 	"pixly/pkg/core/types"
 	"pixly/pkg/engine/quality"
+	"pixly/pkg/scheduler"
 
 	"go.uber.org/zap"
 )
@@ -50,12 +53,14 @@ type UnifiedScanArchitecture struct {
 }
 
 // ScanCacheManager 扫描缓存管理器 - 智能缓存分析结果
+// 内存缓存未命中时回退到cacheDir下的持久化内容缓存，跨运行复用分析结果
 type ScanCacheManager struct {
 	logger         *zap.Logger
 	cacheDir       string
 	memoryCache    map[string]*CachedScanResult // 内存缓存
 	persistentMode bool                         // 持久化模式
 	maxMemoryItems int                          // 最大内存缓存项数
+	store          *contentcache.Cache          // 持久化缓存（未启用时为nil）
 	mutex          sync.RWMutex                 // 读写锁
 }
 
@@ -138,11 +143,11 @@ func NewUnifiedScanArchitecture(
 	fileScanner *Scanner,
 	morphologyClassifier *FileMorphologyClassifier,
 	qualityEngine *quality.QualityEngine,
-	cacheDir string,
+	cacheCfg config.CacheConfig,
 ) *UnifiedScanArchitecture {
 
-	// README要求：高并发扫描（CPU核心数 x 2）
-	maxWorkers := runtime.NumCPU() * 2
+	// README要求：高并发扫描（可用CPU核数 x 2，容器中按cgroup配额）
+	maxWorkers := scheduler.AvailableCPUs() * 2
 
 	arch := &UnifiedScanArchitecture{
 		logger:               logger,
@@ -162,24 +167,44 @@ func NewUnifiedScanArchitecture(
 	}
 
 	// 初始化缓存管理器
-	arch.cacheManager = NewScanCacheManager(logger, cacheDir)
+	arch.cacheManager = NewScanCacheManager(logger, cacheCfg)
 
 	logger.Info("统一扫描架构初始化完成",
 		zap.Int("max_workers", maxWorkers),
-		zap.String("cache_dir", cacheDir))
+		zap.String("cache_dir", cacheCfg.CacheDir))
 
 	return arch
 }
 
-// NewScanCacheManager 创建扫描缓存管理器
-func NewScanCacheManager(logger *zap.Logger, cacheDir string) *ScanCacheManager {
-	return &ScanCacheManager{
+// Close 关闭扫描缓存，调用方不再扫描时调用
+func (usa *UnifiedScanArchitecture) Close() error {
+	return usa.cacheManager.Close()
+}
+
+// NewScanCacheManager 创建扫描缓存管理器，缓存未启用时仅使用内存缓存；
+// 持久化缓存的大小上限与有效期来自高级缓存配置
+func NewScanCacheManager(logger *zap.Logger, cacheCfg config.CacheConfig) *ScanCacheManager {
+	scm := &ScanCacheManager{
 		logger:         logger,
-		cacheDir:       cacheDir,
+		cacheDir:       cacheCfg.CacheDir,
 		memoryCache:    make(map[string]*CachedScanResult),
-		persistentMode: true,
+		persistentMode: cacheCfg.Enabled,
 		maxMemoryItems: 10000, // 最大缓存1万项
 	}
+
+	if scm.persistentMode {
+		options := contentcache.OptionsFromConfig(cacheCfg)
+		options.Name = "scan_cache.db"
+		store, err := contentcache.Open(options, logger)
+		if err != nil {
+			logger.Warn("打开持久化扫描缓存失败，仅使用内存缓存", zap.Error(err))
+			scm.persistentMode = false
+		} else {
+			scm.store = store
+		}
+	}
+
+	return scm
 }
 
 // ExecuteUnifiedScan 执行统一扫描 - README要求的核心新流程
@@ -579,17 +604,38 @@ func (usa *UnifiedScanArchitecture) generateProcessingRecommendations(mediaFiles
 
 // 缓存管理器方法
 func (scm *ScanCacheManager) GetCachedResult(filePath string) *CachedScanResult {
-	scm.mutex.RLock()
-	defer scm.mutex.RUnlock()
-
+	scm.mutex.Lock()
 	if result, exists := scm.memoryCache[filePath]; exists {
 		result.AccessCount++
+		scm.mutex.Unlock()
 		return result
 	}
-	return nil
+	scm.mutex.Unlock()
+
+	// 内存未命中时查询持久化缓存（路径、大小、修改时间与内容指纹均一致才命中）；
+	// 计算内容指纹需要读取文件，在锁外进行，不阻塞其他扫描工作线程
+	result := scm.loadPersistent(filePath)
+	if result == nil {
+		return nil
+	}
+
+	scm.mutex.Lock()
+	defer scm.mutex.Unlock()
+	if existing, exists := scm.memoryCache[filePath]; exists {
+		existing.AccessCount++
+		return existing
+	}
+	if len(scm.memoryCache) >= scm.maxMemoryItems {
+		scm.evictLeastUsed()
+	}
+	scm.memoryCache[filePath] = result
+	return result
 }
 
 func (scm *ScanCacheManager) SetCachedResult(filePath string, result *CachedScanResult) {
+	// 持久化前先计算内容指纹（读取文件），在锁外进行
+	scm.storePersistent(filePath, result)
+
 	scm.mutex.Lock()
 	defer scm.mutex.Unlock()
 
@@ -602,6 +648,73 @@ func (scm *ScanCacheManager) SetCachedResult(filePath string, result *CachedScan
 	scm.memoryCache[filePath] = result
 }
 
+// Close 关闭持久化缓存
+func (scm *ScanCacheManager) Close() error {
+	if scm.store == nil {
+		return nil
+	}
+	return scm.store.Close()
+}
+
+// loadPersistent 从持久化缓存加载形态与品质分析结果
+func (scm *ScanCacheManager) loadPersistent(filePath string) *CachedScanResult {
+	if scm.store == nil {
+		return nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil
+	}
+	entry, ok := scm.store.Lookup(filePath, info.Size(), info.ModTime())
+	if !ok {
+		return nil
+	}
+
+	var morphology MorphologyResult
+	if !entry.DecodeMorphology(&morphology) {
+		return nil
+	}
+	result := &CachedScanResult{
+		FilePath:         filePath,
+		FileHash:         entry.ContentHash,
+		LastModified:     entry.ModTime,
+		FileSize:         entry.Size,
+		MorphologyResult: &morphology,
+		CacheTime:        entry.CachedAt,
+		IsValid:          true,
+	}
+	var assessment quality.QualityAssessment
+	if entry.DecodeQuality(&assessment) {
+		result.QualityAssessment = &assessment
+	}
+	return result
+}
+
+// storePersistent 将分析结果写入持久化缓存
+func (scm *ScanCacheManager) storePersistent(filePath string, result *CachedScanResult) {
+	if scm.store == nil || result.MorphologyResult == nil {
+		return
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	entry, err := scm.store.NewEntry(filePath, info.Size(), info.ModTime())
+	if err != nil {
+		scm.logger.Debug("计算内容指纹失败", zap.String("file", filePath), zap.Error(err))
+		return
+	}
+	entry.SetMorphology(result.MorphologyResult)
+	entry.SetQuality(result.QualityAssessment)
+	result.FileHash = entry.ContentHash
+
+	if err := scm.store.Store(entry); err != nil {
+		scm.logger.Debug("写入持久化扫描缓存失败", zap.String("file", filePath), zap.Error(err))
+	}
+}
+
 func (scm *ScanCacheManager) evictLeastUsed() {
 	minAccess := int(^uint(0) >> 1) // 最大int值
 	var evictKey string
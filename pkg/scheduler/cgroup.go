@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// cgroupRoot cgroup文件系统挂载点，procCgroupFile 进程所属cgroup的描述文件（测试时替换为临时目录）
+var (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procCgroupFile = "/proc/self/cgroup"
+)
+
+// cgroupV1Unlimited cgroup v1未限制时的值接近int64上限（按页对齐）
+const cgroupV1Unlimited = 1 << 62
+
+// procCgroupPaths 解析/proc/self/cgroup：键为控制器名（cgroup v2统一层级的键为空），值为相对挂载点的路径
+func procCgroupPaths() map[string]string {
+	paths := make(map[string]string)
+	file, err := os.Open(procCgroupFile)
+	if err != nil {
+		return paths
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式为 "层级ID:控制器列表:路径"
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	return paths
+}
+
+// cgroupHierarchy 从进程所在cgroup目录到挂载点的各级目录（限制逐级生效，取最严格的一级）。
+// 容器内通常有独立的cgroup命名空间，进程路径为"/"或在挂载点下不存在，此时只检查挂载点
+func cgroupHierarchy(mount, relative string) []string {
+	dir := filepath.Join(mount, relative)
+	if _, err := os.Stat(dir); err != nil || !strings.HasPrefix(dir, mount) {
+		dir = mount
+	}
+
+	var dirs []string
+	for ; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == mount {
+			return dirs
+		}
+	}
+}
+
+// cgroupV2Dirs cgroup v2统一层级中进程所在目录及其各级父目录，不是cgroup v2时为空
+func cgroupV2Dirs() []string {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil
+	}
+	return cgroupHierarchy(cgroupRoot, procCgroupPaths()[""])
+}
+
+// cgroupV1Dirs cgroup v1中控制器的进程所在目录及其各级父目录
+func cgroupV1Dirs(controller string) []string {
+	return cgroupHierarchy(filepath.Join(cgroupRoot, controller), procCgroupPaths()[controller])
+}
+
+// readCgroupValue 读取cgroup接口文件的内容
+func readCgroupValue(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(data)), true
+}
+
+// CgroupMemoryLimit 当前cgroup的内存上限：cgroup v2取各级memory.max中的最小值，
+// 其次cgroup v1的memory.limit_in_bytes；未限制时返回false
+func CgroupMemoryLimit() (uint64, bool) {
+	if dirs := cgroupV2Dirs(); dirs != nil {
+		var limit uint64
+		for _, dir := range dirs {
+			value, ok := readCgroupValue(filepath.Join(dir, "memory.max"))
+			if !ok || value == "max" {
+				continue
+			}
+			if parsed, err := strconv.ParseUint(value, 10, 64); err == nil && parsed > 0 && (limit == 0 || parsed < limit) {
+				limit = parsed
+			}
+		}
+		return limit, limit > 0
+	}
+
+	var limit uint64
+	for _, dir := range cgroupV1Dirs("memory") {
+		value, ok := readCgroupValue(filepath.Join(dir, "memory.limit_in_bytes"))
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err == nil && parsed > 0 && parsed < cgroupV1Unlimited && (limit == 0 || parsed < limit) {
+			limit = parsed
+		}
+	}
+	return limit, limit > 0
+}
+
+// cgroupMemoryUsage 当前cgroup的内存用量（cgroup v2为memory.current，v1为memory.usage_in_bytes）
+func cgroupMemoryUsage() (uint64, bool) {
+	path := filepath.Join(cgroupV1Dirs("memory")[0], "memory.usage_in_bytes")
+	if dirs := cgroupV2Dirs(); dirs != nil {
+		path = filepath.Join(dirs[0], "memory.current")
+	}
+	value, ok := readCgroupValue(path)
+	if !ok {
+		return 0, false
+	}
+	usage, err := strconv.ParseUint(value, 10, 64)
+	return usage, err == nil
+}
+
+// CgroupCPUQuota 当前cgroup的CPU配额（核数，可为小数）：cgroup v2取各级cpu.max中的最小值，
+// 其次cgroup v1的cpu.cfs_quota_us/cpu.cfs_period_us；未限制时返回false
+func CgroupCPUQuota() (float64, bool) {
+	if dirs := cgroupV2Dirs(); dirs != nil {
+		quota := 0.0
+		for _, dir := range dirs {
+			value, ok := readCgroupValue(filepath.Join(dir, "cpu.max"))
+			if !ok {
+				continue
+			}
+			fields := strings.Fields(value)
+			if len(fields) != 2 || fields[0] == "max" {
+				continue
+			}
+			if cores, ok := parseQuota(fields[0], fields[1]); ok && (quota == 0 || cores < quota) {
+				quota = cores
+			}
+		}
+		return quota, quota > 0
+	}
+
+	quota := 0.0
+	for _, dir := range cgroupV1Dirs("cpu") {
+		quotaValue, ok := readCgroupValue(filepath.Join(dir, "cpu.cfs_quota_us"))
+		if !ok {
+			continue
+		}
+		periodValue, ok := readCgroupValue(filepath.Join(dir, "cpu.cfs_period_us"))
+		if !ok {
+			continue
+		}
+		if cores, ok := parseQuota(quotaValue, periodValue); ok && (quota == 0 || cores < quota) {
+			quota = cores
+		}
+	}
+	return quota, quota > 0
+}
+
+// parseQuota 配额与周期（微秒）换算为核数，配额为负（v1未限制）时返回false
+func parseQuota(quotaValue, periodValue string) (float64, bool) {
+	quota, err := strconv.ParseFloat(quotaValue, 64)
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	period, err := strconv.ParseFloat(periodValue, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return quota / period, true
+}
+
+// AvailableCPUs 可用的CPU核数：CPU亲和性（runtime.NumCPU）与cgroup配额（向上取整）中的较小者，至少为1。
+// 容器中runtime.NumCPU返回宿主机核数，按它设定并发会远超配额
+func AvailableCPUs() int {
+	cpus := runtime.NumCPU()
+	if quota, ok := CgroupCPUQuota(); ok {
+		cpus = min(cpus, int(math.Ceil(quota)))
+	}
+	return max(cpus, 1)
+}
+
+// VirtualMemory 容器感知的内存状态：cgroup内存上限低于主机内存时，总量、用量与可用量按cgroup计算
+func VirtualMemory() (*mem.VirtualMemoryStat, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	limit, ok := CgroupMemoryLimit()
+	if !ok || limit >= vm.Total {
+		return vm, nil
+	}
+
+	usage, ok := cgroupMemoryUsage()
+	if !ok {
+		return vm, nil
+	}
+	usage = min(usage, limit)
+	vm.Total = limit
+	vm.Used = usage
+	vm.Available = limit - usage
+	vm.Free = vm.Available
+	vm.UsedPercent = float64(usage) / float64(limit) * 100
+	return vm, nil
+}
@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// cgroupV2Controllers 构造cgroup v2统一层级的标志文件
+const cgroupV2Controllers = "cgroup.controllers"
+
+// fakeCgroup 在临时目录中构造cgroup挂载点与/proc/self/cgroup，files的键为相对挂载点的路径
+func fakeCgroup(t *testing.T, procCgroup string, files map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	mount := filepath.Join(root, "cgroup")
+	if err := os.MkdirAll(mount, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(mount, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proc := filepath.Join(root, "proc_cgroup")
+	if err := os.WriteFile(proc, []byte(procCgroup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldRoot, oldProc := cgroupRoot, procCgroupFile
+	cgroupRoot, procCgroupFile = mount, proc
+	t.Cleanup(func() { cgroupRoot, procCgroupFile = oldRoot, oldProc })
+}
+
+func TestProcCgroupPaths(t *testing.T) {
+	fakeCgroup(t, "12:cpu,cpuacct:/docker/abc\n4:memory:/docker/abc\n1:name=systemd:/init.scope\n0::/user.slice\n格式错误\n", nil)
+
+	paths := procCgroupPaths()
+	want := map[string]string{
+		"cpu":          "/docker/abc",
+		"cpuacct":      "/docker/abc",
+		"memory":       "/docker/abc",
+		"name=systemd": "/init.scope",
+		"":             "/user.slice",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("procCgroupPaths = %v, 期望 %v", paths, want)
+	}
+	for controller, path := range want {
+		if paths[controller] != path {
+			t.Errorf("控制器 %q 的路径 = %q, 期望 %q", controller, paths[controller], path)
+		}
+	}
+}
+
+func TestCgroupMemoryLimit(t *testing.T) {
+	const (
+		gib              = 1 << 30
+		v1UnlimitedValue = "9223372036854771712"
+	)
+
+	tests := []struct {
+		name  string
+		proc  string
+		files map[string]string
+		want  uint64 // 0表示未限制
+	}{
+		{"v2 max表示未限制", "0::/", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"memory.max":        "max",
+		}, 0},
+		{"v2 单级限制", "0::/", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"memory.max":        "1073741824",
+		}, gib},
+		{"v2 父级更严格", "0::/kubepods/pod1/ctr", map[string]string{
+			cgroupV2Controllers:            "cpu memory",
+			"kubepods/memory.max":          "4294967296",
+			"kubepods/pod1/memory.max":     "2147483648",
+			"kubepods/pod1/ctr/memory.max": "max",
+		}, 2 * gib},
+		{"v2 子级更严格", "0::/kubepods/pod1/ctr", map[string]string{
+			cgroupV2Controllers:            "cpu memory",
+			"kubepods/memory.max":          "4294967296",
+			"kubepods/pod1/memory.max":     "max",
+			"kubepods/pod1/ctr/memory.max": "536870912",
+		}, gib / 2},
+		{"v2 无法解析的值被忽略", "0::/app", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"app/memory.max":    "一吉字节",
+			"memory.max":        "3221225472",
+		}, 3 * gib},
+		{"v2 cgroup命名空间中只检查挂载点", "0::/system.slice/docker-abc.scope", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"memory.max":        "1073741824",
+			"other/memory.max":  "1048576",
+		}, gib},
+		{"v2 路径越出挂载点", "0::/../../..", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"memory.max":        "1073741824",
+		}, gib},
+		{"v1 接近int64上限表示未限制", "4:memory:/docker/abc", map[string]string{
+			"memory/memory.limit_in_bytes":            v1UnlimitedValue,
+			"memory/docker/abc/memory.limit_in_bytes": v1UnlimitedValue,
+		}, 0},
+		{"v1 父级更严格", "4:memory:/docker/abc", map[string]string{
+			"memory/memory.limit_in_bytes":            v1UnlimitedValue,
+			"memory/docker/memory.limit_in_bytes":     "2147483648",
+			"memory/docker/abc/memory.limit_in_bytes": v1UnlimitedValue,
+		}, 2 * gib},
+		{"v1 子级更严格", "4:memory:/docker/abc", map[string]string{
+			"memory/docker/memory.limit_in_bytes":     "2147483648",
+			"memory/docker/abc/memory.limit_in_bytes": "1073741824",
+		}, gib},
+		{"没有cgroup文件", "", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeCgroup(t, tt.proc, tt.files)
+			limit, ok := CgroupMemoryLimit()
+			if ok != (tt.want > 0) || limit != tt.want {
+				t.Errorf("CgroupMemoryLimit = %d, %v, 期望 %d", limit, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCgroupCPUQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		proc  string
+		files map[string]string
+		want  float64 // 0表示未限制
+	}{
+		{"v2 max表示未限制", "0::/", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"cpu.max":           "max 100000",
+		}, 0},
+		{"v2 小数核", "0::/", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"cpu.max":           "150000 100000",
+		}, 1.5},
+		{"v2 父级更严格", "0::/kubepods/pod1", map[string]string{
+			cgroupV2Controllers:     "cpu memory",
+			"kubepods/cpu.max":      "50000 100000",
+			"kubepods/pod1/cpu.max": "200000 100000",
+		}, 0.5},
+		{"v2 父级未限制时取子级", "0::/kubepods/pod1", map[string]string{
+			cgroupV2Controllers:     "cpu memory",
+			"cpu.max":               "max 100000",
+			"kubepods/cpu.max":      "max 100000",
+			"kubepods/pod1/cpu.max": "250000 100000",
+		}, 2.5},
+		{"v2 格式错误被忽略", "0::/", map[string]string{
+			cgroupV2Controllers: "cpu memory",
+			"cpu.max":           "100000",
+		}, 0},
+		{"v1 配额为-1表示未限制", "3:cpu,cpuacct:/docker/abc", map[string]string{
+			"cpu/cpu.cfs_quota_us":             "-1",
+			"cpu/cpu.cfs_period_us":            "100000",
+			"cpu/docker/abc/cpu.cfs_quota_us":  "-1",
+			"cpu/docker/abc/cpu.cfs_period_us": "100000",
+		}, 0},
+		{"v1 各级周期不同时按核数比较", "3:cpu,cpuacct:/docker/abc", map[string]string{
+			"cpu/docker/cpu.cfs_quota_us":      "100000",
+			"cpu/docker/cpu.cfs_period_us":     "50000",
+			"cpu/docker/abc/cpu.cfs_quota_us":  "300000",
+			"cpu/docker/abc/cpu.cfs_period_us": "100000",
+		}, 2},
+		{"v1 缺少周期的一级被忽略", "3:cpu,cpuacct:/docker/abc", map[string]string{
+			"cpu/docker/cpu.cfs_quota_us":      "10000",
+			"cpu/docker/abc/cpu.cfs_quota_us":  "400000",
+			"cpu/docker/abc/cpu.cfs_period_us": "100000",
+		}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeCgroup(t, tt.proc, tt.files)
+			quota, ok := CgroupCPUQuota()
+			if ok != (tt.want > 0) || quota != tt.want {
+				t.Errorf("CgroupCPUQuota = %v, %v, 期望 %v", quota, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailableCPUs(t *testing.T) {
+	tests := []struct {
+		name   string
+		cpuMax string
+		want   int
+	}{
+		{"未限制", "max 100000", runtime.NumCPU()},
+		{"配额向上取整", "150000 100000", min(runtime.NumCPU(), 2)},
+		{"配额不足一核", "20000 100000", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeCgroup(t, "0::/", map[string]string{
+				cgroupV2Controllers: "cpu memory",
+				"cpu.max":           tt.cpuMax,
+			})
+			if got := AvailableCPUs(); got != tt.want {
+				t.Errorf("AvailableCPUs = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+	"go.uber.org/zap"
+)
+
+// 编码器名称：内存特征差异很大，分别估算与校正
+const (
+	EncoderCjxl    = "cjxl"
+	EncoderAvifenc = "avifenc"
+	EncoderFFmpeg  = "ffmpeg"
+	EncoderRemux   = "remux" // 视频重包装，不解码
+	EncoderDNG     = "dng_converter"
+)
+
+const (
+	// jobOverhead 每个任务的固定开销：编码器进程本身、预处理的中间文件与探测工具
+	jobOverhead = 64 << 20
+	// ffmpegLookahead ffmpeg编码时同时缓存的帧数上限（AV1编码器的lookahead）
+	ffmpegLookahead = 48
+	// hostBudgetRatio 未配置内存限制时可用于编码的主机或cgroup内存比例
+	hostBudgetRatio = 0.8
+	// sampleInterval 子进程RSS采样间隔
+	sampleInterval = 500 * time.Millisecond
+	// recheckInterval 等待中的任务重新检查预算的间隔（RSS变化不会唤醒等待者）
+	recheckInterval = time.Second
+	// correctionWeight 实测RSS对编码器校正系数的更新权重
+	correctionWeight = 0.3
+	// 校正系数范围
+	minCorrection = 0.25
+	maxCorrection = 8.0
+)
+
+// Job 一个编码任务的内存特征
+type Job struct {
+	Encoder string // 编码器名称（EncoderCjxl等）
+	Effort  int    // cjxl的effort；其他编码器不使用
+	Pixels  int64  // 每帧像素数，未知时为0
+	Frames  int    // 帧数，静态图为1
+	Size    int64  // 源文件大小，像素数未知时用于估算
+}
+
+// Ticket 已获准运行的任务，完成后交回Release。任务启动的编码器进程通过Track登记，
+// 采样时只把这些进程（连同其子进程）的RSS计入该任务
+type Ticket struct {
+	scheduler *MemoryScheduler
+	job       Job
+	estimate  uint64             // 校正后的估算峰值RSS
+	base      uint64             // 校正前的估算峰值RSS
+	pids      map[int32]struct{} // 运行中的编码器进程
+	peakRSS   uint64             // 运行期间实测的最大RSS，未采样到时为0
+}
+
+// Stats 调度统计
+type Stats struct {
+	Budget   uint64 // 内存预算（字节）
+	Reserved uint64 // 运行中任务的估算总量
+	Measured uint64 // 最近一次采样的子进程RSS总量
+	Running  int
+	Waiting  int
+	Waits    int64 // 因预算不足而等待过的任务数
+}
+
+// MemoryScheduler 按内存预算准入编码任务：每个任务按像素数、帧数与编码器估算峰值RSS，
+// 运行中任务的估算总量（或实测RSS，取较大者）加上新任务不超过预算时才运行。
+// 运行期间用gopsutil采样各任务登记的编码器进程RSS，按编码器校正之后的估算。
+// 它是编码并发的唯一内存限制：工作池只在没有任务等待内存时扩容
+type MemoryScheduler struct {
+	budget uint64
+	logger *zap.Logger
+
+	mutex       sync.Mutex
+	running     map[*Ticket]struct{}
+	reserved    uint64
+	measured    uint64
+	waiting     int
+	waits       int64
+	corrections map[string]float64
+	wake        chan struct{} // 有任务完成时关闭并替换，唤醒等待者
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMemoryScheduler 创建内存调度器并开始采样子进程RSS；budget为0时不限制
+func NewMemoryScheduler(budget uint64, logger *zap.Logger) *MemoryScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &MemoryScheduler{
+		budget:      budget,
+		logger:      logger,
+		running:     make(map[*Ticket]struct{}),
+		corrections: make(map[string]float64),
+		wake:        make(chan struct{}),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go s.sample(ctx)
+	return s
+}
+
+// MemoryBudget 编码任务的内存预算：配置的限制（MB，<=0表示未配置）、cgroup内存限制与主机内存中的最小值；
+// 后两者只使用其中的hostBudgetRatio，为系统与本进程留出余量。都无法获取时返回0（不限制）
+func MemoryBudget(limitMB int) uint64 {
+	var budget uint64
+	consider := func(limit uint64) {
+		if limit > 0 && (budget == 0 || limit < budget) {
+			budget = limit
+		}
+	}
+
+	if limitMB > 0 {
+		consider(uint64(limitMB) << 20)
+	}
+	if limit, ok := CgroupMemoryLimit(); ok {
+		consider(uint64(float64(limit) * hostBudgetRatio))
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		consider(uint64(float64(vm.Total) * hostBudgetRatio))
+	}
+	return budget
+}
+
+// Estimate 任务的估算峰值RSS（含按实测校正）
+func (s *MemoryScheduler) Estimate(job Job) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	estimate, _ := s.estimate(job)
+	return estimate
+}
+
+// estimate 返回校正后与校正前的估算值（调用方持有锁）
+func (s *MemoryScheduler) estimate(job Job) (uint64, uint64) {
+	base := baseEstimate(job)
+	correction, ok := s.corrections[job.Encoder]
+	if !ok {
+		correction = 1
+	}
+	return uint64(float64(base) * correction), base
+}
+
+// baseEstimate 按编码器的经验内存占用估算峰值RSS：cjxl effort 9约为avifenc的10倍，
+// ffmpeg按lookahead缓存的帧数计算，重包装不解码，只有固定开销
+func baseEstimate(job Job) uint64 {
+	pixels := float64(job.Pixels)
+	if pixels <= 0 {
+		// 头部无法解析时以文件大小近似像素数（压缩图像每像素通常不足1字节）
+		pixels = float64(job.Size)
+	}
+	frames := float64(max(job.Frames, 1))
+
+	var bytes float64
+	switch job.Encoder {
+	case EncoderCjxl:
+		bytes = pixels * frames * cjxlBytesPerPixel(job.Effort)
+	case EncoderAvifenc:
+		bytes = pixels * 10
+	case EncoderFFmpeg:
+		bytes = pixels * (8 + 4*min(frames, ffmpegLookahead))
+	case EncoderRemux:
+		bytes = 0
+	default:
+		bytes = pixels * 16
+	}
+	return jobOverhead + uint64(bytes)
+}
+
+// cjxlBytesPerPixel cjxl每像素内存：effort 8起启用更大的搜索，effort 9的模块化编码占用成倍增加
+func cjxlBytesPerPixel(effort int) float64 {
+	switch {
+	case effort >= 9:
+		return 100
+	case effort == 8:
+		return 24
+	default:
+		return 12
+	}
+}
+
+// Acquire 等待预算允许后准入任务；没有任务运行时总是准入（超出预算的单个任务也要能完成）。
+// ctx取消时返回错误
+func (s *MemoryScheduler) Acquire(ctx context.Context, job Job) (*Ticket, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	waited := false
+	for {
+		s.mutex.Lock()
+		estimate, base := s.estimate(job)
+		used := max(s.reserved, s.measured)
+		if s.budget == 0 || len(s.running) == 0 || used+estimate <= s.budget {
+			ticket := &Ticket{scheduler: s, job: job, estimate: estimate, base: base, pids: make(map[int32]struct{})}
+			s.running[ticket] = struct{}{}
+			s.reserved += estimate
+			if waited {
+				s.waiting--
+			}
+			s.mutex.Unlock()
+			return ticket, nil
+		}
+		if !waited {
+			waited = true
+			s.waiting++
+			s.waits++
+			s.logger.Debug("内存预算不足，等待运行中的任务完成",
+				zap.String("encoder", job.Encoder),
+				zap.Int64("pixels", job.Pixels),
+				zap.Uint64("estimate_mb", estimate>>20),
+				zap.Uint64("used_mb", used>>20),
+				zap.Uint64("budget_mb", s.budget>>20))
+		}
+		wake := s.wake
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			s.mutex.Lock()
+			s.waiting--
+			s.mutex.Unlock()
+			return nil, ctx.Err()
+		case <-wake:
+		case <-time.After(recheckInterval):
+		}
+	}
+}
+
+// Release 任务完成：释放预算，并按运行期间的实测RSS更新该编码器的校正系数
+func (s *MemoryScheduler) Release(ticket *Ticket) {
+	if s == nil || ticket == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.running[ticket]; !ok {
+		return
+	}
+	delete(s.running, ticket)
+	s.reserved -= ticket.estimate
+	if len(s.running) == 0 {
+		s.measured = 0 // 空闲时不再采样，避免过时的实测值阻塞下一批任务
+	}
+
+	if ticket.peakRSS > 0 && ticket.base > 0 {
+		ratio := float64(ticket.peakRSS) / float64(ticket.base)
+		correction, ok := s.corrections[ticket.job.Encoder]
+		if !ok {
+			correction = ratio
+		} else {
+			correction = correction*(1-correctionWeight) + ratio*correctionWeight
+		}
+		s.corrections[ticket.job.Encoder] = min(max(correction, minCorrection), maxCorrection)
+	}
+
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
+
+// Track 登记任务启动的进程，返回的函数在进程退出后调用；ticket为nil时不登记
+func (t *Ticket) Track(pid int) func() {
+	if t == nil || t.scheduler == nil {
+		return func() {}
+	}
+	s := t.scheduler
+	s.mutex.Lock()
+	t.pids[int32(pid)] = struct{}{}
+	s.mutex.Unlock()
+	return func() {
+		s.mutex.Lock()
+		delete(t.pids, int32(pid))
+		s.mutex.Unlock()
+	}
+}
+
+// sample 定期采样本进程所有子进程的RSS：总量用于准入，各任务只计入自己登记的进程
+func (s *MemoryScheduler) sample(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		s.logger.Debug("无法采样子进程内存，只使用估算值", zap.Error(err))
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mutex.Lock()
+		idle := len(s.running) == 0
+		s.mutex.Unlock()
+		if idle {
+			continue
+		}
+
+		subtree := make(map[int32]uint64)
+		rss := descendantRSS(ctx, self, subtree)
+
+		s.mutex.Lock()
+		s.measured = rss
+		for ticket := range s.running {
+			s.attribute(ticket, subtree)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// attribute 将任务登记的进程（连同其子进程）的实测RSS计入任务的峰值（调用方持有锁）
+func (s *MemoryScheduler) attribute(ticket *Ticket, subtree map[int32]uint64) {
+	var rss uint64
+	for pid := range ticket.pids {
+		rss += subtree[pid]
+	}
+	ticket.peakRSS = max(ticket.peakRSS, rss)
+}
+
+// descendantRSS 进程所有后代进程（编码器及其子进程）的RSS总和；subtree记录每个后代进程连同其子进程的RSS
+func descendantRSS(ctx context.Context, proc *process.Process, subtree map[int32]uint64) uint64 {
+	children, err := proc.ChildrenWithContext(ctx)
+	if err != nil {
+		return 0
+	}
+	var total uint64
+	for _, child := range children {
+		var rss uint64
+		if info, err := child.MemoryInfoWithContext(ctx); err == nil {
+			rss = info.RSS
+		}
+		rss += descendantRSS(ctx, child, subtree)
+		subtree[child.Pid] = rss
+		total += rss
+	}
+	return total
+}
+
+// Stats 返回调度统计
+func (s *MemoryScheduler) Stats() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Stats{
+		Budget:   s.budget,
+		Reserved: s.reserved,
+		Measured: s.measured,
+		Running:  len(s.running),
+		Waiting:  s.waiting,
+		Waits:    s.waits,
+	}
+}
+
+// Close 停止采样
+func (s *MemoryScheduler) Close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
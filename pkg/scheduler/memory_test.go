@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"go.uber.org/zap"
+)
+
+func TestBaseEstimate(t *testing.T) {
+	const megapixels = 1_000_000
+
+	tests := []struct {
+		name string
+		job  Job
+		want uint64
+	}{
+		{"cjxl effort 7", Job{Encoder: EncoderCjxl, Effort: 7, Pixels: megapixels, Frames: 1}, jobOverhead + 12*megapixels},
+		{"cjxl effort 8", Job{Encoder: EncoderCjxl, Effort: 8, Pixels: megapixels, Frames: 1}, jobOverhead + 24*megapixels},
+		{"cjxl effort 9", Job{Encoder: EncoderCjxl, Effort: 9, Pixels: megapixels, Frames: 1}, jobOverhead + 100*megapixels},
+		{"cjxl 动图按帧数计", Job{Encoder: EncoderCjxl, Effort: 7, Pixels: megapixels, Frames: 10}, jobOverhead + 120*megapixels},
+		{"avifenc", Job{Encoder: EncoderAvifenc, Pixels: megapixels, Frames: 1}, jobOverhead + 10*megapixels},
+		{"ffmpeg 静态图", Job{Encoder: EncoderFFmpeg, Pixels: megapixels, Frames: 1}, jobOverhead + 12*megapixels},
+		{"ffmpeg 帧数超过lookahead", Job{Encoder: EncoderFFmpeg, Pixels: megapixels, Frames: 500}, jobOverhead + (8+4*ffmpegLookahead)*megapixels},
+		{"重包装只有固定开销", Job{Encoder: EncoderRemux, Pixels: 4 * megapixels, Frames: 1000}, jobOverhead},
+		{"未知编码器", Job{Encoder: EncoderDNG, Pixels: megapixels, Frames: 1}, jobOverhead + 16*megapixels},
+		{"像素数未知时按文件大小", Job{Encoder: EncoderAvifenc, Size: 2 * megapixels}, jobOverhead + 20*megapixels},
+		{"帧数为0按单帧", Job{Encoder: EncoderCjxl, Effort: 7, Pixels: megapixels}, jobOverhead + 12*megapixels},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseEstimate(tt.job); got != tt.want {
+				t.Errorf("baseEstimate = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySchedulerAcquire(t *testing.T) {
+	job := Job{Encoder: EncoderAvifenc, Pixels: 10_000_000, Frames: 1}
+	estimate := baseEstimate(job)
+	s := NewMemoryScheduler(estimate*3/2, zap.NewNop())
+	defer s.Close()
+
+	first, err := s.Acquire(context.Background(), job)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	// 第二个任务超出预算，等待第一个完成
+	acquired := make(chan *Ticket)
+	go func() {
+		ticket, _ := s.Acquire(context.Background(), job)
+		acquired <- ticket
+	}()
+	waitFor(t, func() bool { return s.Stats().Waiting == 1 })
+	s.Release(first)
+	second := <-acquired
+	if stats := s.Stats(); stats.Running != 1 || stats.Waiting != 0 || stats.Waits != 1 || stats.Reserved != estimate {
+		t.Errorf("Stats = %+v", stats)
+	}
+
+	// 等待中的任务随ctx取消返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, job); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx超时后应返回 DeadlineExceeded，实际为 %v", err)
+	}
+	s.Release(second)
+	if stats := s.Stats(); stats.Running != 0 || stats.Waiting != 0 || stats.Reserved != 0 {
+		t.Errorf("全部释放后 Stats = %+v", stats)
+	}
+}
+
+func TestMemorySchedulerCorrection(t *testing.T) {
+	s := NewMemoryScheduler(0, zap.NewNop())
+	defer s.Close()
+	job := Job{Encoder: EncoderCjxl, Effort: 7, Pixels: 4_000_000, Frames: 1}
+	base := baseEstimate(job)
+
+	run := func(peakRSS uint64) {
+		ticket, err := s.Acquire(context.Background(), job)
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		s.mutex.Lock()
+		ticket.peakRSS = peakRSS
+		s.mutex.Unlock()
+		s.Release(ticket)
+	}
+
+	run(base * 2)
+	if got := s.Estimate(job); got != base*2 {
+		t.Errorf("首次实测后估算 = %d, 期望 %d", got, base*2)
+	}
+	run(base) // 2×0.7 + 1×0.3
+	if got, want := s.Estimate(job), uint64(float64(base)*1.7); got != want {
+		t.Errorf("再次实测后估算 = %d, 期望 %d", got, want)
+	}
+	run(0) // 未采样到的任务不更新校正
+	if got, want := s.Estimate(job), uint64(float64(base)*1.7); got != want {
+		t.Errorf("未采样的任务改变了估算: %d, 期望 %d", got, want)
+	}
+	run(base * 100)
+	if got, want := s.Estimate(job), uint64(float64(base)*maxCorrection); got != want {
+		t.Errorf("校正系数应限制在 %.0f 倍以内，估算为 %d", maxCorrection, got)
+	}
+	if other := (Job{Encoder: EncoderAvifenc, Pixels: 4_000_000, Frames: 1}); s.Estimate(other) != baseEstimate(other) {
+		t.Error("校正系数应按编码器分别记录")
+	}
+}
+
+func TestMemorySchedulerAttribute(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("无法启动子进程: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	s := NewMemoryScheduler(0, zap.NewNop())
+	defer s.Close()
+	job := Job{Encoder: EncoderAvifenc, Pixels: 1_000_000, Frames: 1}
+	tracked, _ := s.Acquire(context.Background(), job)
+	idle, _ := s.Acquire(context.Background(), job)
+	defer s.Release(tracked)
+	defer s.Release(idle)
+	untrack := tracked.Track(cmd.Process.Pid)
+
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Skipf("无法读取进程信息: %v", err)
+	}
+	subtree := make(map[int32]uint64)
+	total := descendantRSS(context.Background(), self, subtree)
+	if subtree[int32(cmd.Process.Pid)] == 0 {
+		t.Skip("无法读取子进程RSS")
+	}
+
+	s.mutex.Lock()
+	s.attribute(tracked, subtree)
+	s.attribute(idle, subtree)
+	trackedRSS, idleRSS := tracked.peakRSS, idle.peakRSS
+	s.mutex.Unlock()
+	if trackedRSS != subtree[int32(cmd.Process.Pid)] || trackedRSS > total {
+		t.Errorf("登记进程的任务实测 = %d, 期望子进程RSS %d（总量 %d）", trackedRSS, subtree[int32(cmd.Process.Pid)], total)
+	}
+	if idleRSS != 0 {
+		t.Errorf("没有登记进程的任务不应分摊RSS，实际为 %d", idleRSS)
+	}
+
+	untrack()
+	s.mutex.Lock()
+	pids := len(tracked.pids)
+	s.mutex.Unlock()
+	if pids != 0 {
+		t.Errorf("进程退出登记后仍有 %d 个进程", pids)
+	}
+	if (*Ticket)(nil).Track(cmd.Process.Pid) == nil {
+		t.Error("nil准入的Track应返回空操作")
+	}
+}
+
+// waitFor 等待条件成立，超时则失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"math"
+	"sync"
+)
+
+// 编码线程分配策略
+const (
+	ThreadPlanAuto   = "auto"   // 按队列组成分配：每个任务至少分到均分的核心，大图按工作量占比多分
+	ThreadPlanSingle = "single" // 每个编码器进程单线程，靠任务并发占满核心
+	ThreadPlanEven   = "even"   // 可用CPU在最大并发任务间均分
+	ThreadPlanOff    = "off"    // 不指定线程数，由编码器自行决定（通常使用全部核心）
+)
+
+// ThreadPlans 支持的线程分配策略
+var ThreadPlans = []string{ThreadPlanAuto, ThreadPlanSingle, ThreadPlanEven, ThreadPlanOff}
+
+// minWorkPerThread 每个编码线程至少分到的像素数（跨帧累计）：cjxl按256×256分组、avifenc按行块并行，
+// 更小的图像多开线程只增加同步开销
+const minWorkPerThread = 1 << 20
+
+// ThreadPlanner 为每个编码任务决定线程数，避免N个并发任务各自再启动N个编码线程。
+// auto策略下每个任务至少分到可用CPU在并发槽位间均分的份额（槽位全部占满时正好用满核心）；
+// 工作量（像素数×帧数）足以让更多线程并行的重任务，再按其在排队与运行中任务总工作量中的占比多分，
+// 只剩少量大文件时由它们分摊空出的核心
+type ThreadPlanner struct {
+	plan  string
+	cpus  int
+	slots int // 最大并发任务数
+
+	mutex       sync.Mutex
+	pending     map[string]float64 // 排队任务的工作量
+	pendingWork float64
+	running     map[string]threadGrant
+	runningWork float64
+	inUse       int // 运行中任务已分配的线程总数
+	jobs        int64
+	threadSum   int64
+	single      int64
+}
+
+// ThreadStats 线程分配统计
+type ThreadStats struct {
+	Plan           string
+	CPUs           int
+	Jobs           int64   // 已分配线程的任务数
+	SingleThreaded int64   // 其中单线程的任务数
+	AvgThreads     float64 // 每个任务的平均线程数（不指定线程数时为0）
+}
+
+// threadGrant 运行中任务的工作量与分配的线程数
+type threadGrant struct {
+	work    float64
+	threads int
+}
+
+// NewThreadPlanner 创建线程分配器：cpus为可用CPU数，slots为工作池的最大并发任务数；未知策略按auto处理
+func NewThreadPlanner(plan string, cpus, slots int) *ThreadPlanner {
+	if !IsThreadPlan(plan) {
+		plan = ThreadPlanAuto
+	}
+	return &ThreadPlanner{
+		plan:    plan,
+		cpus:    max(cpus, 1),
+		slots:   max(slots, 1),
+		pending: make(map[string]float64),
+		running: make(map[string]threadGrant),
+	}
+}
+
+// IsThreadPlan 是否为支持的线程分配策略
+func IsThreadPlan(plan string) bool {
+	for _, known := range ThreadPlans {
+		if plan == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan 返回线程分配策略
+func (p *ThreadPlanner) Plan() string {
+	return p.plan
+}
+
+// DefaultThreads 不在编码任务中运行的编码器进程使用的线程数，也是auto策略下每个任务的最少线程数；0表示不指定
+func (p *ThreadPlanner) DefaultThreads() int {
+	if p == nil {
+		return 0
+	}
+	switch p.plan {
+	case ThreadPlanOff:
+		return 0
+	case ThreadPlanSingle:
+		return 1
+	default:
+		return max(p.cpus/p.slots, 1)
+	}
+}
+
+// Queue 登记排队中的任务，作为之后分配线程时的队列组成
+func (p *ThreadPlanner) Queue(key string, job Job) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dropPending(key)
+	work := workload(job)
+	p.pending[key] = work
+	p.pendingWork += work
+}
+
+// Assign 任务开始运行：返回其编码器进程的线程数（0表示不指定），完成后调用Release
+func (p *ThreadPlanner) Assign(key string, job Job) int {
+	if p == nil {
+		return 0
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dropPending(key)
+	p.dropRunning(key)
+
+	work := workload(job)
+	threads := p.threadsFor(job, work)
+	p.running[key] = threadGrant{work: work, threads: threads}
+	p.runningWork += work
+	p.inUse += threads
+	p.jobs++
+	p.threadSum += int64(threads)
+	if threads == 1 {
+		p.single++
+	}
+	return threads
+}
+
+// threadsFor 按策略计算任务的线程数（调用方持有锁）
+func (p *ThreadPlanner) threadsFor(job Job, work float64) int {
+	switch p.plan {
+	case ThreadPlanOff:
+		return 0
+	case ThreadPlanSingle:
+		return 1
+	case ThreadPlanEven:
+		return p.DefaultThreads()
+	}
+
+	// 重包装不解码也不编码，线程数没有意义
+	if job.Encoder == EncoderRemux {
+		return 1
+	}
+
+	// 轻任务的图像不足以让更多线程并行，只用均分的份额
+	floor := p.DefaultThreads()
+	parallel := int(math.Ceil(work / minWorkPerThread))
+	if parallel <= floor {
+		return floor
+	}
+
+	threads := floor
+	if total := p.pendingWork + p.runningWork + work; total > 0 {
+		threads = max(threads, int(math.Round(float64(p.cpus)*work/total)))
+	}
+	// 队列已不足以占满所有并发槽位时，空出的核心分给剩下的任务
+	if active := len(p.pending) + len(p.running) + 1; active < p.slots {
+		threads = max(threads, p.cpus/active)
+	}
+	// 多分的线程不超过图像本身能并行的程度，也不挤占运行中任务已分配的核心
+	threads = min(threads, parallel, p.cpus-p.inUse)
+	return min(max(threads, floor), p.cpus)
+}
+
+// Release 任务完成或不再需要编码（如跳过）：从运行中与排队中移除
+func (p *ThreadPlanner) Release(key string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.dropPending(key)
+	p.dropRunning(key)
+}
+
+// Stats 返回线程分配统计
+func (p *ThreadPlanner) Stats() ThreadStats {
+	if p == nil {
+		return ThreadStats{}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := ThreadStats{Plan: p.plan, CPUs: p.cpus, Jobs: p.jobs, SingleThreaded: p.single}
+	if p.jobs > 0 {
+		stats.AvgThreads = float64(p.threadSum) / float64(p.jobs)
+	}
+	return stats
+}
+
+// dropPending 移除排队中的任务（调用方持有锁）
+func (p *ThreadPlanner) dropPending(key string) {
+	if work, ok := p.pending[key]; ok {
+		delete(p.pending, key)
+		p.pendingWork -= work
+		if len(p.pending) == 0 {
+			p.pendingWork = 0 // 避免浮点累计误差
+		}
+	}
+}
+
+// dropRunning 移除运行中的任务并归还其线程（调用方持有锁）
+func (p *ThreadPlanner) dropRunning(key string) {
+	if grant, ok := p.running[key]; ok {
+		delete(p.running, key)
+		p.runningWork -= grant.work
+		p.inUse -= grant.threads
+		if len(p.running) == 0 {
+			p.runningWork = 0
+		}
+	}
+}
+
+// workload 任务的工作量：像素数×帧数，像素数未知时以文件大小近似
+func workload(job Job) float64 {
+	pixels := float64(job.Pixels)
+	if pixels <= 0 {
+		pixels = float64(job.Size)
+	}
+	return max(pixels, 1) * float64(max(job.Frames, 1))
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+)
+
+// imageJob 单帧静态图任务
+func imageJob(encoder string, pixels int64) Job {
+	return Job{Encoder: encoder, Pixels: pixels, Frames: 1}
+}
+
+func TestThreadPlannerPlans(t *testing.T) {
+	heavy := imageJob(EncoderCjxl, 24_000_000)
+	tests := []struct {
+		plan string
+		want int
+	}{
+		{ThreadPlanOff, 0},
+		{ThreadPlanSingle, 1},
+		{ThreadPlanEven, 2},
+		{"未知策略", 8}, // 按auto处理
+	}
+	for _, tt := range tests {
+		t.Run(tt.plan, func(t *testing.T) {
+			p := NewThreadPlanner(tt.plan, 8, 4)
+			if got := p.Assign("a", heavy); got != tt.want {
+				t.Errorf("Assign = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadPlannerAuto(t *testing.T) {
+	const (
+		cpus  = 8
+		slots = 4
+		small = 64 * 64
+		large = 24_000_000
+	)
+
+	tests := []struct {
+		name    string
+		queued  []Job // 排队中的其他任务
+		running []Job // 已在运行的其他任务
+		job     Job
+		want    int
+	}{
+		{"小图至少分到均分份额", nil, nil, imageJob(EncoderAvifenc, small), cpus / slots},
+		{"大量小图排队时小图仍用均分份额", repeatJob(imageJob(EncoderCjxl, small), 100), nil, imageJob(EncoderCjxl, small), cpus / slots},
+		{"图像只够均分份额并行时不多分", nil, nil, imageJob(EncoderCjxl, 1_500_000), cpus / slots},
+		{"单独的大图用满全部核心", nil, nil, imageJob(EncoderCjxl, large), cpus},
+		{"大图受自身可并行程度限制", nil, nil, imageJob(EncoderCjxl, 3_000_000), 3},
+		{"大图按工作量占比多分", repeatJob(imageJob(EncoderCjxl, 1_000_000), 10), nil, imageJob(EncoderCjxl, large), 6},
+		{"动图按各帧累计工作量", nil, nil, Job{Encoder: EncoderFFmpeg, Pixels: 640 * 480, Frames: 100}, cpus},
+		{"核心已被运行中的大图占用时只用均分份额", nil, []Job{imageJob(EncoderCjxl, large)}, imageJob(EncoderCjxl, large), cpus / slots},
+		{"重包装单线程", nil, nil, Job{Encoder: EncoderRemux, Pixels: large, Frames: 1000}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewThreadPlanner(ThreadPlanAuto, cpus, slots)
+			for i, job := range tt.queued {
+				p.Queue(fmt.Sprintf("queued%d", i), job)
+			}
+			for i, job := range tt.running {
+				p.Assign(fmt.Sprintf("running%d", i), job)
+			}
+			if got := p.Assign("job", tt.job); got != tt.want {
+				t.Errorf("Assign = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadPlannerFloor(t *testing.T) {
+	// 并发槽位多于核心时每个任务至少单线程
+	p := NewThreadPlanner(ThreadPlanAuto, 2, 8)
+	if got := p.Assign("a", imageJob(EncoderAvifenc, 64*64)); got != 1 {
+		t.Errorf("Assign = %d, 期望 1", got)
+	}
+
+	// 槽位全部占满时均分的份额正好用满核心
+	p = NewThreadPlanner(ThreadPlanAuto, 16, 4)
+	total := 0
+	for i := 0; i < 4; i++ {
+		p.Queue(fmt.Sprintf("f%d", i), imageJob(EncoderCjxl, 800*600))
+	}
+	for i := 0; i < 4; i++ {
+		total += p.Assign(fmt.Sprintf("f%d", i), imageJob(EncoderCjxl, 800*600))
+	}
+	if total != 16 {
+		t.Errorf("占满槽位的小图共分到 %d 个线程，期望 16", total)
+	}
+}
+
+func TestThreadPlannerRelease(t *testing.T) {
+	p := NewThreadPlanner(ThreadPlanAuto, 8, 4)
+	large := imageJob(EncoderCjxl, 24_000_000)
+	if got := p.Assign("a", large); got != 8 {
+		t.Fatalf("Assign = %d, 期望 8", got)
+	}
+	if got := p.Assign("b", large); got != 2 {
+		t.Fatalf("核心被占用时 Assign = %d, 期望 2", got)
+	}
+	p.Release("a")
+	p.Release("b")
+	if got := p.Assign("c", large); got != 8 {
+		t.Errorf("释放后 Assign = %d, 期望 8", got)
+	}
+
+	stats := p.Stats()
+	if stats.Jobs != 3 || stats.SingleThreaded != 0 || stats.AvgThreads != 6 {
+		t.Errorf("Stats = %+v", stats)
+	}
+	var nilPlanner *ThreadPlanner
+	if nilPlanner.Assign("a", large) != 0 || nilPlanner.DefaultThreads() != 0 {
+		t.Error("nil分配器应不指定线程数")
+	}
+}
+
+// repeatJob n个相同的任务
+func repeatJob(job Job, n int) []Job {
+	jobs := make([]Job, n)
+	for i := range jobs {
+		jobs[i] = job
+	}
+	return jobs
+}
@@ -0,0 +1,153 @@
+// Package videoquality 视频片段采样与感知质量评分：按片段截取源视频，
+// 使用ffmpeg的libvmaf（可用时）或SSIM滤镜比较候选编码与源视频的对应区间。
+package videoquality
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Metric 视频质量指标
+type Metric string
+
+const (
+	MetricVMAF Metric = "vmaf" // 0-100
+	MetricSSIM Metric = "ssim" // 0-1
+)
+
+// compareFormat 比较前统一的像素格式：libvmaf与ssim要求两路输入格式一致，10位避免高位深源被截断
+const compareFormat = "yuv420p10le"
+
+var (
+	vmafPattern = regexp.MustCompile(`VMAF score[:=]\s*([0-9.]+)`)
+	ssimPattern = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+)
+
+// CommandRunner 执行ffmpeg并返回合并的标准输出与标准错误（由调用方经工具管理器执行，以登记进程并限制线程数）
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Capabilities ffmpeg的编码器与滤镜支持情况
+type Capabilities struct {
+	Encoders map[string]bool
+	LibVMAF  bool
+}
+
+// Detect 查询ffmpeg支持的编码器与libvmaf滤镜
+func Detect(ctx context.Context, run CommandRunner, ffmpegPath string) (*Capabilities, error) {
+	encoders, err := run(ctx, ffmpegPath, "-hide_banner", "-encoders")
+	if err != nil {
+		return nil, fmt.Errorf("查询ffmpeg编码器失败: %w", err)
+	}
+	filters, err := run(ctx, ffmpegPath, "-hide_banner", "-filters")
+	if err != nil {
+		return nil, fmt.Errorf("查询ffmpeg滤镜失败: %w", err)
+	}
+
+	caps := &Capabilities{Encoders: listedNames(encoders)}
+	caps.LibVMAF = listedNames(filters)["libvmaf"]
+	return caps, nil
+}
+
+// HasEncoder 是否支持指定编码器
+func (c *Capabilities) HasEncoder(name string) bool {
+	return c != nil && c.Encoders[name]
+}
+
+// listedNames 解析 -encoders/-filters 列表：每行为"标志位 名称 描述"，第二列即名称
+func listedNames(output []byte) map[string]bool {
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// Sample 采样片段（秒）
+type Sample struct {
+	Start  float64
+	Length float64
+}
+
+// PlanSamples 在时长内均匀选取count个length秒的片段（避开首尾）；
+// 视频短于全部片段总时长时整段作为一个片段，时长未知时返回nil
+func PlanSamples(duration float64, count int, length float64) []Sample {
+	if duration <= 0 || count <= 0 || length <= 0 {
+		return nil
+	}
+	if duration <= float64(count)*length {
+		return []Sample{{Start: 0, Length: duration}}
+	}
+
+	// 将时长均分为count段，取每段中点附近的片段
+	samples := make([]Sample, 0, count)
+	segment := duration / float64(count)
+	for i := 0; i < count; i++ {
+		start := segment*float64(i) + (segment-length)/2
+		samples = append(samples, Sample{Start: start, Length: length})
+	}
+	return samples
+}
+
+// SeekArgs 输入端的定位参数，放在 -i 之前，编码与评分使用相同的定位以保证帧对齐
+func (s Sample) SeekArgs() []string {
+	return []string{
+		"-ss", strconv.FormatFloat(s.Start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(s.Length, 'f', 3, 64),
+	}
+}
+
+// Scorer 片段评分器
+type Scorer struct {
+	FFmpegPath string
+	Metric     Metric
+	Threads    int // 评分使用的线程数（libvmaf、解码与滤镜），与所属任务分配的编码线程数一致；0表示不指定，使用ffmpeg与libvmaf的默认值
+	Run        CommandRunner
+}
+
+// Score 比较候选片段与源视频对应区间，返回VMAF（0-100）或SSIM（0-1）
+func (s *Scorer) Score(ctx context.Context, sourcePath string, sample Sample, candidatePath string) (float64, error) {
+	compare := "ssim"
+	pattern := ssimPattern
+	if s.Metric == MetricVMAF {
+		compare = "libvmaf"
+		if s.Threads > 0 {
+			compare = fmt.Sprintf("libvmaf=n_threads=%d", s.Threads)
+		}
+		pattern = vmafPattern
+	}
+	graph := fmt.Sprintf("[0:v]format=%[1]s,setpts=PTS-STARTPTS[dist];[1:v]format=%[1]s,setpts=PTS-STARTPTS[ref];[dist][ref]%[2]s",
+		compareFormat, compare)
+
+	// 分配了线程数时两路解码与滤镜图也按该线程数运行，避免评分占用超出任务分配的CPU
+	var threadArgs []string
+	args := []string{"-hide_banner", "-nostats"}
+	if s.Threads > 0 {
+		threadArgs = []string{"-threads", strconv.Itoa(s.Threads)}
+		args = append(args, "-filter_threads", strconv.Itoa(s.Threads))
+	}
+	args = append(args, threadArgs...)
+	args = append(args, "-i", candidatePath)
+	args = append(args, sample.SeekArgs()...)
+	args = append(args, threadArgs...)
+	args = append(args, "-i", sourcePath, "-lavfi", graph, "-f", "null", "-")
+
+	// 评分结果输出在stderr
+	output, err := s.Run(ctx, s.FFmpegPath, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s评分失败: %w", s.Metric, err)
+	}
+	matches := pattern.FindAllSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("未找到%s评分输出", s.Metric)
+	}
+	return strconv.ParseFloat(string(matches[len(matches)-1][1]), 64)
+}
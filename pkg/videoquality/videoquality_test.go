@@ -0,0 +1,131 @@
+package videoquality
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlanSamples(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration float64
+		count    int
+		length   float64
+		want     []Sample
+	}{
+		{"时长未知", 0, 3, 2, nil},
+		{"片段数为0", 60, 0, 2, nil},
+		{"片段长度为0", 60, 3, 0, nil},
+		{"短于片段总时长时整段采样", 5, 3, 2, []Sample{{Start: 0, Length: 5}}},
+		{"刚好等于片段总时长", 6, 3, 2, []Sample{{Start: 0, Length: 6}}},
+		{"均分后取每段中点", 60, 3, 2, []Sample{{Start: 9, Length: 2}, {Start: 29, Length: 2}, {Start: 49, Length: 2}}},
+		{"单个片段", 10, 1, 4, []Sample{{Start: 3, Length: 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PlanSamples(tt.duration, tt.count, tt.length)
+			if len(got) != len(tt.want) {
+				t.Fatalf("PlanSamples = %v, 期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("片段 %d = %+v, 期望 %+v", i, got[i], tt.want[i])
+				}
+				if got[i].Start < 0 || got[i].Start+got[i].Length > tt.duration {
+					t.Errorf("片段 %d 越出时长: %+v", i, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSampleSeekArgs(t *testing.T) {
+	got := strings.Join(Sample{Start: 12.5, Length: 2}.SeekArgs(), " ")
+	if want := "-ss 12.500 -t 2.000"; got != want {
+		t.Errorf("SeekArgs = %q, 期望 %q", got, want)
+	}
+}
+
+func TestScorerScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		metric  Metric
+		threads int
+		output  string
+		err     error
+		want    float64
+		graph   string
+		inputs  string
+		wantErr bool
+	}{
+		{"VMAF按任务线程数", MetricVMAF, 3, "[Parsed_libvmaf_4 @ 0x1] VMAF score: 93.421000\n", nil, 93.421, "[dist][ref]libvmaf=n_threads=3",
+			"-filter_threads 3 -threads 3 -i candidate.mkv -ss 1.000 -t 2.000 -threads 3 -i source.mp4", false},
+		{"VMAF未分配线程时不指定线程数", MetricVMAF, 0, "VMAF score: 90.0\n", nil, 90, "[dist][ref]libvmaf -f",
+			"-nostats -i candidate.mkv -ss 1.000 -t 2.000 -i source.mp4", false},
+		{"VMAF取最后一个评分", MetricVMAF, 2, "VMAF score: 10.0\nVMAF score=95.5\n", nil, 95.5, "libvmaf=n_threads=2",
+			"-threads 2 -i candidate.mkv -ss 1.000 -t 2.000 -threads 2 -i source.mp4", false},
+		{"SSIM", MetricSSIM, 4, "[Parsed_ssim_4 @ 0x1] SSIM Y:0.99 U:0.98 V:0.98 All:0.985123 (18.3)\n", nil, 0.985123, "[dist][ref]ssim",
+			"-threads 4 -i candidate.mkv -ss 1.000 -t 2.000 -threads 4 -i source.mp4", false},
+		{"没有评分输出", MetricSSIM, 1, "Conversion failed!\n", nil, 0, "", "", true},
+		{"ffmpeg失败", MetricVMAF, 1, "", errors.New("exit status 1"), 0, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotArgs []string
+			scorer := &Scorer{
+				FFmpegPath: "ffmpeg",
+				Metric:     tt.metric,
+				Threads:    tt.threads,
+				Run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					gotArgs = args
+					return []byte(tt.output), tt.err
+				},
+			}
+			score, err := scorer.Score(context.Background(), "source.mp4", Sample{Start: 1, Length: 2}, "candidate.mkv")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Score err = %v, 期望错误 %v", err, tt.wantErr)
+			}
+			if score != tt.want {
+				t.Errorf("Score = %v, 期望 %v", score, tt.want)
+			}
+			joined := strings.Join(gotArgs, " ")
+			if !strings.Contains(joined, tt.graph) {
+				t.Errorf("参数 %q 中缺少 %q", joined, tt.graph)
+			}
+			// 候选在前且不定位，源视频按片段定位；分配了线程数时两路解码都限制线程
+			if !strings.Contains(joined, tt.inputs) {
+				t.Errorf("输入参数 %q 中缺少 %q", joined, tt.inputs)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	outputs := map[string]string{
+		"-encoders": " V....D libsvtav1            SVT-AV1\n V....D libx265              H.265 / HEVC\n ------\n",
+		"-filters":  " ... libvmaf           VV->V      Calculate the VMAF\n ... ssim              VV->V      Calculate the SSIM\n",
+	}
+	run := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte(outputs[args[len(args)-1]]), nil
+	}
+	caps, err := Detect(context.Background(), run, "ffmpeg")
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if !caps.HasEncoder("libsvtav1") || !caps.HasEncoder("libx265") || caps.HasEncoder("libaom-av1") || !caps.LibVMAF {
+		t.Errorf("Capabilities = %+v", caps)
+	}
+
+	failing := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}
+	if _, err := Detect(context.Background(), failing, "ffmpeg"); err == nil {
+		t.Error("ffmpeg不可用时应返回错误")
+	}
+	var none *Capabilities
+	if none.HasEncoder("libx265") {
+		t.Error("nil能力不应支持任何编码器")
+	}
+}
@@ -0,0 +1,175 @@
+package whitelist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"pixly/pkg/mediaprobe"
+)
+
+// creativeSourceTypes 创作源文件：工程文件、矢量图与分层图像，转换会丢失图层与可编辑性，任何编码器都不处理
+var creativeSourceTypes = map[string]SkipReason{
+	"psd": {
+		Category:    SkipCreativeSource,
+		Reason:      "创作源文件",
+		Description: "Photoshop工程文件",
+	},
+	"psb": {
+		Category:    SkipCreativeSource,
+		Reason:      "创作源文件",
+		Description: "Photoshop大型文档",
+	},
+	"xcf": {
+		Category:    SkipCreativeSource,
+		Reason:      "创作源文件",
+		Description: "GIMP工程文件",
+	},
+	"kra": {
+		Category:    SkipCreativeSource,
+		Reason:      "创作源文件",
+		Description: "Krita工程文件",
+	},
+	"svg": {
+		Category:    SkipCreativeSource,
+		Reason:      "矢量图",
+		Description: "SVG矢量图",
+	},
+	"svgz": {
+		Category:    SkipCreativeSource,
+		Reason:      "矢量图",
+		Description: "SVG压缩矢量图",
+	},
+	"ai": {
+		Category:    SkipCreativeSource,
+		Reason:      "绘图工程文件",
+		Description: "Adobe Illustrator文件",
+	},
+	"sketch": {
+		Category:    SkipCreativeSource,
+		Reason:      "绘图工程文件",
+		Description: "Sketch设计文件",
+	},
+	"fig": {
+		Category:    SkipCreativeSource,
+		Reason:      "绘图工程文件",
+		Description: "Figma设计文件",
+	},
+	"blend": {
+		Category:    SkipCreativeSource,
+		Reason:      "3D模型文件",
+		Description: "Blender工程文件",
+	},
+	"max": {
+		Category:    SkipCreativeSource,
+		Reason:      "3D模型文件",
+		Description: "3ds Max工程文件",
+	},
+	"maya": {
+		Category:    SkipCreativeSource,
+		Reason:      "3D模型文件",
+		Description: "Maya工程文件",
+	},
+}
+
+// pdfDocument PDF文档：设计素材目录中常见，与创作源文件一起在报告中列出
+var pdfDocument = SkipReason{
+	Category:    SkipNonMedia,
+	Reason:      "文档文件",
+	Description: "PDF文档",
+}
+
+// CreativeByExtension 按扩展名识别创作源文件与PDF文档，不是时返回nil
+func CreativeByExtension(path string) *SkipReason {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if reason, ok := creativeSourceTypes[ext]; ok {
+		return &reason
+	}
+	if ext == "pdf" {
+		reason := pdfDocument
+		return &reason
+	}
+	return nil
+}
+
+// ClassifyCreative 识别创作源文件：内容签名优先（改了扩展名的PSD、SVG等同样识别），其次按扩展名；
+// 带图层或多个IFD的TIFF同样视为创作源文件。文件头取自共享探测结果，不再单独打开文件。不是时返回nil
+func ClassifyCreative(probe *mediaprobe.ProbeResult) *SkipReason {
+	if reason := sniffCreative(probe); reason != nil {
+		return reason
+	}
+	return CreativeByExtension(probe.Path)
+}
+
+// sniffCreative 按文件头识别PSD/PSB、XCF、KRA、PDF/AI、SVG与分层TIFF，无法读取或不是时返回nil
+func sniffCreative(probe *mediaprobe.ProbeResult) *SkipReason {
+	header, err := probe.Head()
+	if err != nil {
+		return nil
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(probe.Path), "."))
+
+	var reason SkipReason
+	switch {
+	case len(header) >= 6 && string(header[:4]) == "8BPS":
+		reason = creativeSourceTypes["psd"]
+		if binary.BigEndian.Uint16(header[4:]) == 2 {
+			reason = creativeSourceTypes["psb"]
+		}
+	case bytes.HasPrefix(header, []byte("gimp xcf")):
+		reason = creativeSourceTypes["xcf"]
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) && bytes.Contains(header, []byte("application/x-krita")):
+		reason = creativeSourceTypes["kra"]
+	case bytes.HasPrefix(header, []byte("%PDF-")), bytes.HasPrefix(header, []byte("%!PS-Adobe")):
+		// Illustrator文件以PDF或PostScript保存，只能靠扩展名与普通文档区分
+		if ext == "ai" {
+			reason = creativeSourceTypes["ai"]
+		} else if bytes.HasPrefix(header, []byte("%PDF-")) {
+			reason = pdfDocument
+		} else {
+			return nil
+		}
+	case isSVG(header):
+		reason = creativeSourceTypes["svg"]
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return layeredTIFF(probe)
+	default:
+		return nil
+	}
+	return &reason
+}
+
+// isSVG 文件头是否为SVG文档（可带XML声明、注释与DOCTYPE）
+func isSVG(header []byte) bool {
+	text := bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xEF\xBB\xBF")), " \t\r\n")
+	if !bytes.HasPrefix(text, []byte("<?xml")) && !bytes.HasPrefix(text, []byte("<svg")) &&
+		!bytes.HasPrefix(text, []byte("<!DOCTYPE svg")) && !bytes.HasPrefix(text, []byte("<!--")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg"))
+}
+
+// layeredTIFF 带Photoshop图层数据或多个全分辨率页的TIFF（缩略图IFD不计）：JXL/AVIF只能保存第一页的合成图。相机RAW不在此列
+func layeredTIFF(probe *mediaprobe.ProbeResult) *SkipReason {
+	info, err := probe.Header()
+	if err != nil || info.Raw != "" {
+		return nil
+	}
+
+	var description string
+	switch {
+	case info.Layered:
+		description = "带Photoshop图层的TIFF"
+	case info.FrameCount > 1:
+		description = fmt.Sprintf("包含%d页的多页TIFF", info.FrameCount)
+	default:
+		return nil
+	}
+	return &SkipReason{
+		Category:    SkipCreativeSource,
+		Reason:      "分层图像",
+		Description: description,
+	}
+}
@@ -0,0 +1,188 @@
+package whitelist
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pixly/pkg/mediaprobe"
+)
+
+// tiffPage 测试TIFF的一页：尺寸、是否为缩略图、是否带Photoshop图层数据与DNGVersion标签
+type tiffPage struct {
+	width, height uint32
+	reduced       bool
+	layers        bool
+	dng           bool
+}
+
+// buildTIFF 构造小端TIFF，各页的IFD依次串成链
+func buildTIFF(pages ...tiffPage) []byte {
+	type entry struct {
+		tag, kind uint16
+		value     uint32
+	}
+	var ifds [][]entry
+	for _, page := range pages {
+		entries := []entry{
+			{256, 4, page.width},
+			{257, 4, page.height},
+			{258, 3, 8}, // BitsPerSample
+			{262, 3, 2}, // RGB
+			{277, 3, 3}, // SamplesPerPixel
+		}
+		if page.reduced {
+			entries = append([]entry{{254, 4, 1}}, entries...)
+		}
+		if page.dng {
+			entries = append(entries, entry{50706, 1, 0x0701}) // DNGVersion
+		}
+		if page.layers {
+			entries = append(entries, entry{37724, 7, 0}) // ImageSourceData
+		}
+		ifds = append(ifds, entries)
+	}
+
+	data := []byte("II*\x00\x08\x00\x00\x00")
+	offset := 8
+	for i, entries := range ifds {
+		offset += 2 + len(entries)*12 + 4
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(entries)))
+		for _, e := range entries {
+			data = binary.LittleEndian.AppendUint16(data, e.tag)
+			data = binary.LittleEndian.AppendUint16(data, e.kind)
+			data = binary.LittleEndian.AppendUint32(data, 1)
+			data = binary.LittleEndian.AppendUint32(data, e.value)
+		}
+		next := uint32(0)
+		if i+1 < len(ifds) {
+			next = uint32(offset)
+		}
+		data = binary.LittleEndian.AppendUint32(data, next)
+	}
+	return data
+}
+
+// probeFile 写入测试文件并返回其探测结果
+func probeFile(t *testing.T, name string, data []byte) *mediaprobe.ProbeResult {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mediaprobe.NewProber("", "").Get(path)
+}
+
+func TestClassifyCreative(t *testing.T) {
+	psd := append([]byte("8BPS\x00\x01"), make([]byte, 20)...)
+	psb := append([]byte("8BPS\x00\x02"), make([]byte, 20)...)
+
+	tests := []struct {
+		name        string
+		file        string
+		data        []byte
+		category    SkipCategory
+		description string // 为空表示不是创作源文件
+	}{
+		{"PSD", "a.psd", psd, SkipCreativeSource, "Photoshop工程文件"},
+		{"改了扩展名的PSD", "a.png", psd, SkipCreativeSource, "Photoshop工程文件"},
+		{"PSB", "a.psb", psb, SkipCreativeSource, "Photoshop大型文档"},
+		{"XCF", "a.jpg", []byte("gimp xcf v011\x00"), SkipCreativeSource, "GIMP工程文件"},
+		{"KRA", "a.zip", []byte("PK\x03\x04\x00\x00mimetypeapplication/x-krita"), SkipCreativeSource, "Krita工程文件"},
+		{"普通ZIP", "a.zip", []byte("PK\x03\x04\x00\x00mimetypetext/plain"), 0, ""},
+		{"PDF", "a.bin", []byte("%PDF-1.7\n"), SkipNonMedia, "PDF文档"},
+		{"PDF格式的AI", "a.ai", []byte("%PDF-1.5\n"), SkipCreativeSource, "Adobe Illustrator文件"},
+		{"PostScript格式的AI", "a.ai", []byte("%!PS-Adobe-3.0\n"), SkipCreativeSource, "Adobe Illustrator文件"},
+		{"PostScript文件", "a.eps", []byte("%!PS-Adobe-3.0\n"), 0, ""},
+		{"改了扩展名的SVG", "a.png", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`), SkipCreativeSource, "SVG矢量图"},
+		{"内容无法识别时按扩展名", "a.sketch", []byte("binary"), SkipCreativeSource, "Sketch设计文件"},
+		{"按扩展名识别PDF", "a.pdf", []byte("binary"), SkipNonMedia, "PDF文档"},
+		{"带图层的TIFF", "a.tif", buildTIFF(tiffPage{width: 64, height: 64, layers: true}), SkipCreativeSource, "带Photoshop图层的TIFF"},
+		{"多页TIFF", "a.tif", buildTIFF(tiffPage{width: 64, height: 64}, tiffPage{width: 64, height: 64}), SkipCreativeSource, "包含2页的多页TIFF"},
+		{"缩略图不算页", "a.tif", buildTIFF(tiffPage{width: 64, height: 64}, tiffPage{width: 16, height: 16, reduced: true}), 0, ""},
+		{"单页TIFF", "a.tif", buildTIFF(tiffPage{width: 64, height: 64}), 0, ""},
+		{"普通图像", "a.png", []byte("\x89PNG\r\n\x1a\n"), 0, ""},
+		{"空文件", "a.png", nil, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := ClassifyCreative(probeFile(t, tt.file, tt.data))
+			if tt.description == "" {
+				if reason != nil {
+					t.Errorf("ClassifyCreative = %+v, 期望nil", reason)
+				}
+				return
+			}
+			if reason == nil || reason.Category != tt.category || reason.Description != tt.description {
+				t.Errorf("ClassifyCreative = %+v, 期望 %s/%s", reason, tt.category, tt.description)
+			}
+		})
+	}
+}
+
+func TestClassifyCreativeMissingFile(t *testing.T) {
+	probe := mediaprobe.NewProber("", "").Get(filepath.Join(t.TempDir(), "missing.psd"))
+	if reason := sniffCreative(probe); reason != nil {
+		t.Errorf("sniffCreative = %+v, 文件不存在时期望nil", reason)
+	}
+	// 无法读取内容时仍按扩展名识别
+	if reason := ClassifyCreative(probe); reason == nil || reason.Description != "Photoshop工程文件" {
+		t.Errorf("ClassifyCreative = %+v, 期望按扩展名识别为PSD", reason)
+	}
+}
+
+func TestIsSVG(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"svg根元素", `<svg xmlns="http://www.w3.org/2000/svg">`, true},
+		{"XML声明", `<?xml version="1.0"?>` + "\n" + `<svg>`, true},
+		{"BOM与空白", "\xEF\xBB\xBF \r\n<svg>", true},
+		{"DOCTYPE", `<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN"><svg>`, true},
+		{"开头注释", `<!-- Generator: Inkscape --><svg>`, true},
+		{"其他XML文档", `<?xml version="1.0"?><plist version="1.0">`, false},
+		{"HTML中的svg", `<html><body><svg>`, false},
+		{"纯文本", "svg", false},
+		{"XML声明但svg在读取范围外", `<?xml version="1.0"?>` + strings.Repeat(" ", mediaprobe.HeadSize), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := []byte(tt.header)
+			if len(header) > mediaprobe.HeadSize {
+				header = header[:mediaprobe.HeadSize]
+			}
+			if got := isSVG(header); got != tt.want {
+				t.Errorf("isSVG(%q) = %v, 期望 %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayeredTIFF(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		description string
+	}{
+		{"带图层", buildTIFF(tiffPage{width: 64, height: 64, layers: true}), "带Photoshop图层的TIFF"},
+		{"三页", buildTIFF(tiffPage{width: 64, height: 64}, tiffPage{width: 32, height: 32}, tiffPage{width: 32, height: 32}), "包含3页的多页TIFF"},
+		{"单页加缩略图", buildTIFF(tiffPage{width: 64, height: 64}, tiffPage{width: 16, height: 16, reduced: true}), ""},
+		{"相机RAW不算多页", buildTIFF(tiffPage{width: 64, height: 64, dng: true}, tiffPage{width: 64, height: 64}), ""},
+		{"损坏", []byte("II*\x00\xff\x00\x00\x00"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := layeredTIFF(probeFile(t, "a.tif", tt.data))
+			switch {
+			case tt.description == "" && reason != nil:
+				t.Errorf("layeredTIFF = %+v, 期望nil", reason)
+			case tt.description != "" && (reason == nil || reason.Description != tt.description || reason.Category != SkipCreativeSource):
+				t.Errorf("layeredTIFF = %+v, 期望 %s", reason, tt.description)
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package whitelist
-package whitelist
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"pixly/pkg/core/types"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -29,6 +30,9 @@ type FormatWhitelist struct {
 	
 	// 统计信息
 	whitelistStats          *WhitelistStats
+	
+	// 共享探测缓存（可选），按内容识别创作源文件时复用文件头
+	prober                  *mediaprobe.Prober
 }
 
 // FormatInfo 格式信息
@@ -61,6 +65,9 @@ const (
 	SkipSystemHidden                       // 系统/隐藏文件
 	SkipUnsupported                        // 不支持的格式
 	SkipCorrupted                          // 损坏文件
+	SkipStrategy                           // 策略判定保持原样（无收益、按配置保留等）
+	SkipCached                             // 已使用相同设置处理过
+	SkipConversionFailed                   // 转换与后备均失败，原文件复制到输出目录
 )
 
 func (sc SkipCategory) String() string {
@@ -79,6 +86,12 @@ func (sc SkipCategory) String() string {
 		return "不支持格式"
 	case SkipCorrupted:
 		return "损坏文件"
+	case SkipStrategy:
+		return "策略保持原样"
+	case SkipCached:
+		return "已处理过"
+	case SkipConversionFailed:
+		return "转换失败保留原文件"
 	default:
 		return "未知"
 	}
@@ -251,6 +264,43 @@ func (fw *FormatWhitelist) initializeSupportedFormats() {
 			ToolRequired: "ffmpeg",
 			Notes:        "基础格式",
 		},
+		// 相机RAW：按conversion.raw.policy处理，默认保持原样
+		"dng": {
+			Extension:    "dng",
+			MimeType:     "image/x-adobe-dng",
+			MediaType:    types.MediaTypeImage,
+			Description:  "DNG相机RAW",
+			IsSupported:  true,
+			ToolRequired: "cjxl,exiftool,dng_converter",
+			Notes:        "preview提取嵌入预览为JXL旁车文件；dng_jxl无损转换为JPEG XL压缩的DNG",
+		},
+		"cr2": {
+			Extension:    "cr2",
+			MimeType:     "image/x-canon-cr2",
+			MediaType:    types.MediaTypeImage,
+			Description:  "Canon相机RAW",
+			IsSupported:  true,
+			ToolRequired: "cjxl,exiftool",
+			Notes:        "preview提取嵌入预览为JXL旁车文件，RAW保持原样",
+		},
+		"nef": {
+			Extension:    "nef",
+			MimeType:     "image/x-nikon-nef",
+			MediaType:    types.MediaTypeImage,
+			Description:  "Nikon相机RAW",
+			IsSupported:  true,
+			ToolRequired: "cjxl,exiftool",
+			Notes:        "preview提取嵌入预览为JXL旁车文件，RAW保持原样",
+		},
+		"arw": {
+			Extension:    "arw",
+			MimeType:     "image/x-sony-arw",
+			MediaType:    types.MediaTypeImage,
+			Description:  "Sony相机RAW",
+			IsSupported:  true,
+			ToolRequired: "cjxl,exiftool",
+			Notes:        "preview提取嵌入预览为JXL旁车文件，RAW保持原样",
+		},
 	}
 	
 	// README 5.1: 视频格式支持
@@ -354,11 +404,6 @@ func (fw *FormatWhitelist) initializeSkipRules() {
 	
 	// README 5.2: 非媒体文件跳过
 	fw.skippedSystemTypes = map[string]SkipReason{
-		"psd": {
-			Category:    SkipCreativeSource,
-			Reason:      "创作源文件",
-			Description: "Photoshop工程文件",
-		},
 		"pdf": {
 			Category:    SkipNonMedia,
 			Reason:      "文档文件",
@@ -392,37 +437,9 @@ func (fw *FormatWhitelist) initializeSkipRules() {
 	}
 	
 	// README 5.2: 创作源文件跳过
-	fw.skippedCreativeTypes = map[string]SkipReason{
-		"blend": {
-			Category:    SkipCreativeSource,
-			Reason:      "3D模型文件",
-			Description: "Blender工程文件",
-		},
-		"max": {
-			Category:    SkipCreativeSource,
-			Reason:      "3D模型文件",
-			Description: "3ds Max工程文件",
-		},
-		"maya": {
-			Category:    SkipCreativeSource,
-			Reason:      "3D模型文件",
-			Description: "Maya工程文件",
-		},
-		"ai": {
-			Category:    SkipCreativeSource,
-			Reason:      "绘图工程文件",
-			Description: "Adobe Illustrator文件",
-		},
-		"sketch": {
-			Category:    SkipCreativeSource,
-			Reason:      "绘图工程文件",
-			Description: "Sketch设计文件",
-		},
-		"fig": {
-			Category:    SkipCreativeSource,
-			Reason:      "绘图工程文件",
-			Description: "Figma设计文件",
-		},
+	fw.skippedCreativeTypes = make(map[string]SkipReason, len(creativeSourceTypes))
+	for ext, reason := range creativeSourceTypes {
+		fw.skippedCreativeTypes[ext] = reason
 	}
 }
 
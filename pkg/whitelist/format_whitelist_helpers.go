@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"pixly/pkg/core/types"
+	"pixly/pkg/mediaprobe"
 
 	"go.uber.org/zap"
 )
@@ -61,6 +62,11 @@ func (fw *FormatWhitelist) checkSkipRules(ext, filePath string) *SkipReason {
 		return &skipReason
 	}
 
+	// 按内容识别改了扩展名的工程文件与分层TIFF
+	if skipReason := sniffCreative(fw.probe(filePath)); skipReason != nil {
+		return skipReason
+	}
+
 	// 特殊路径检查（如果需要）
 	if fw.isSpecialPath(filePath) {
 		return &SkipReason{
@@ -361,3 +367,16 @@ func (fw *FormatWhitelist) AddCustomFormat(extension, mimeType string, mediaType
 		zap.String("media_type", mediaType.String()),
 		zap.Bool("is_supported", isSupported))
 }
+
+// SetProber 设置共享探测缓存，按内容识别创作源文件时复用其他阶段已读取的文件头
+func (fw *FormatWhitelist) SetProber(prober *mediaprobe.Prober) {
+	fw.prober = prober
+}
+
+// probe 返回文件的探测结果，没有共享探测缓存时使用不缓存的一次性结果
+func (fw *FormatWhitelist) probe(path string) *mediaprobe.ProbeResult {
+	if fw.prober != nil {
+		return fw.prober.Get(path)
+	}
+	return mediaprobe.NewProber("", "").Get(path)
+}
@@ -100,7 +100,7 @@ func executeStandardFlow(ctx context.Context, state *StandardFlowState, logger *
 	showStepHeaderAdvanced(state, "核心处理", "⚡", uiManager)
 	uiManager.ShowInfo("⚡ 正在进行核心处理...")
 	unifiedProgress.StartStep(progress.StepProcessing, int64(len(scanResults)), "⚡ 核心处理")
-	results, err := performCoreProcessing(ctx, state.TargetDir, mode, scanResults, logger)
+	results, err := performCoreProcessing(ctx, state.TargetDir, mode, scanResults, logger, isInteractive)
 	if err != nil {
 		return fmt.Errorf("步骤6失败 - 核心处理: %w", err)
 	}
@@ -240,7 +240,7 @@ func performBatchDecisions(reader *bufio.Reader, scanResults []*types.MediaInfo,
 	return nil
 }
 
-func performCoreProcessing(ctx context.Context, targetDir string, mode types.AppMode, scanResults []*types.MediaInfo, logger *zap.Logger) ([]*types.ProcessingResult, error) {
+func performCoreProcessing(ctx context.Context, targetDir string, mode types.AppMode, scanResults []*types.MediaInfo, logger *zap.Logger, isInteractive bool) ([]*types.ProcessingResult, error) {
 	color.White("⚡ 开始核心处理...")
 
 	isTestMode := os.Getenv("PIXLY_TEST_MODE") == "true" || os.Getenv("TEST_MODE") == "true"
@@ -280,8 +280,8 @@ func performCoreProcessing(ctx context.Context, targetDir string, mode types.App
 
 	showToolStatus(toolPaths)
 
-	qualityEngine := quality.NewQualityEngine(logger, "", "", true)
-	processingManager := engine.NewProcessingModeManager(logger, toolPaths, qualityEngine)
+	qualityEngine := quality.NewQualityEngine(logger, "", "", true, nil)
+	processingManager := engine.NewProcessingModeManager(logger, toolPaths, qualityEngine, !isInteractive)
 
 	color.Yellow("🎯 使用模式: %s", mode.String())
 	if isTestMode {